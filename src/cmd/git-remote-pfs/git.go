@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// the sha of the empty blob
+const emptyBlobSha = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+
+// treeEntry is an entry of a git tree, path is relative to the tree.
+type treeEntry struct {
+	mode       string
+	objectType string
+	sha        string
+	path       string
+}
+
+// git runs git with args on the repo git runs us in and returns its output.
+func git(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), err.Error())
+	}
+	return stdout.Bytes(), nil
+}
+
+func gitString(args ...string) (string, error) {
+	output, err := git(nil, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func gitLines(args ...string) ([]string, error) {
+	output, err := gitString(args...)
+	if err != nil || output == "" {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// isAncestor returns true if ancestor is an ancestor of sha, it's false if
+// ancestor isn't in the local repo.
+func isAncestor(ancestor string, sha string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", ancestor, sha).Run() == nil
+}
+
+func hasObject(sha string) bool {
+	return exec.Command("git", "cat-file", "-e", sha).Run() == nil
+}
+
+// writeObject writes an object to the local repo and checks it has sha.
+func writeObject(objectType string, sha string, data []byte) error {
+	output, err := git(data, "hash-object", "-w", "-t", objectType, "--stdin")
+	if err != nil {
+		return err
+	}
+	written := strings.TrimSpace(string(output))
+	if written != sha {
+		return fmt.Errorf("%s %s was fetched as %s", objectType, sha, written)
+	}
+	return nil
+}
+
+// parseCommit returns the tree and parents of a raw commit object.
+func parseCommit(raw []byte) (string, []string) {
+	var tree string
+	var parents []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		// the headers end at the first blank line
+		if line == "" {
+			break
+		}
+		switch {
+		case strings.HasPrefix(line, "tree "):
+			tree = strings.TrimPrefix(line, "tree ")
+		case strings.HasPrefix(line, "parent "):
+			parents = append(parents, strings.TrimPrefix(line, "parent "))
+		}
+	}
+	return tree, parents
+}
+
+// parseTree returns the entries of a raw tree object, which are
+// "<mode> <name>\0<20 byte sha>".
+func parseTree(raw []byte) ([]*treeEntry, error) {
+	var entries []*treeEntry
+	for len(raw) > 0 {
+		space := bytes.IndexByte(raw, ' ')
+		null := bytes.IndexByte(raw, 0)
+		if space < 0 || null < space || len(raw) < null+21 {
+			return nil, fmt.Errorf("malformed tree object")
+		}
+		mode := string(raw[:space])
+		objectType := "blob"
+		switch mode {
+		case "40000":
+			objectType = "tree"
+		case "160000":
+			objectType = "commit"
+		}
+		entries = append(entries, &treeEntry{
+			mode,
+			objectType,
+			hex.EncodeToString(raw[null+1 : null+21]),
+			string(raw[space+1 : null]),
+		})
+		raw = raw[null+21:]
+	}
+	return entries, nil
+}
+
+// lsTree returns the entries under the tree of commit sha recursively, with
+// the trees themselves if withTrees is true.
+func lsTree(sha string, withTrees bool) ([]*treeEntry, error) {
+	args := []string{"ls-tree", "-r", "-z"}
+	if withTrees {
+		args = append(args, "-t")
+	}
+	output, err := git(nil, append(args, sha)...)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*treeEntry
+	for _, line := range strings.Split(string(output), "\x00") {
+		if line == "" {
+			continue
+		}
+		// <mode> SP <type> SP <sha> TAB <path>
+		tab := strings.Index(line, "\t")
+		if tab < 0 {
+			return nil, fmt.Errorf("malformed ls-tree output: %s", line)
+		}
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed ls-tree output: %s", line)
+		}
+		entries = append(entries, &treeEntry{fields[0], fields[1], fields[2], line[tab+1:]})
+	}
+	return entries, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestParseTree(t *testing.T) {
+	dirSha, err := hex.DecodeString("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	require.NoError(t, err)
+	raw := append([]byte("40000 dir\x00"), dirSha...)
+	emptyBlob, err := hex.DecodeString(emptyBlobSha)
+	require.NoError(t, err)
+	raw = append(append(raw, []byte("100644 file name\x00")...), emptyBlob...)
+	entries, err := parseTree(raw)
+	require.NoError(t, err)
+	require.Equal(t, []*treeEntry{
+		{"40000", "tree", "4b825dc642cb6eb9a060e54bf8d69288fbee4904", "dir"},
+		{"100644", "blob", emptyBlobSha, "file name"},
+	}, entries)
+	_, err = parseTree(raw[:len(raw)-1])
+	require.NotNil(t, err)
+}
+
+func TestParseCommit(t *testing.T) {
+	tree, parents := parseCommit([]byte("tree a\nparent b\nparent c\nauthor x\n\nparent d\n"))
+	require.Equal(t, "a", tree)
+	require.Equal(t, []string{"b", "c"}, parents)
+}
+
+func TestRefTag(t *testing.T) {
+	require.Equal(t, "ref-3-refs:heads:master", refTag(3, "refs/heads/master"))
+}
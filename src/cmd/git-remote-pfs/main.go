@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"go.pedge.io/env"
+)
+
+// git-remote-pfs is a git remote helper, git runs it for remotes with
+// pfs:// urls. See gitremote-helpers(1) for the protocol it speaks on stdin
+// and stdout.
+
+type appEnv struct {
+	PfsAddress string `env:"PFS_ADDRESS,default=0.0.0.0:650"`
+}
+
+func main() {
+	env.Main(do, &appEnv{})
+}
+
+func do(appEnvObj interface{}) error {
+	appEnv := appEnvObj.(*appEnv)
+	if len(os.Args) < 2 {
+		return fmt.Errorf("usage: %s remote [url], git runs it for pfs:// urls", os.Args[0])
+	}
+	address, repoName, err := parseURL(os.Args[len(os.Args)-1], appEnv.PfsAddress)
+	if err != nil {
+		return err
+	}
+	apiClient, err := getAPIClient(address)
+	if err != nil {
+		return err
+	}
+	return serve(newRemote(apiClient, repoName), os.Stdin, os.Stdout)
+}
+
+// parseURL parses pfs://repo, which uses the pfsd at defaultAddress, or
+// pfs://host:port/repo.
+func parseURL(url string, defaultAddress string) (string, string, error) {
+	if !strings.HasPrefix(url, "pfs://") {
+		return "", "", fmt.Errorf("%s isn't a pfs:// url", url)
+	}
+	url = strings.TrimPrefix(url, "pfs://")
+	address := defaultAddress
+	if i := strings.LastIndex(url, "/"); i >= 0 {
+		address, url = url[:i], url[i+1:]
+	}
+	if url == "" {
+		return "", "", fmt.Errorf("pfs:// urls must name a repo")
+	}
+	return address, url, nil
+}
+
+// serve answers git's commands from reader until git is done.
+func serve(remote *remote, reader io.Reader, writer io.Writer) error {
+	lines := bufio.NewReader(reader)
+	for {
+		line, err := readLine(lines)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case line == "":
+			return nil
+		case line == "capabilities":
+			if _, err := fmt.Fprint(writer, "fetch\npush\n\n"); err != nil {
+				return err
+			}
+		case line == "list" || line == "list for-push":
+			refs, err := remote.list()
+			if err != nil {
+				return err
+			}
+			for _, ref := range refs {
+				if _, err := fmt.Fprintf(writer, "%s %s\n", ref.sha, ref.name); err != nil {
+					return err
+				}
+			}
+			if head := headRef(refs); head != "" {
+				if _, err := fmt.Fprintf(writer, "@%s HEAD\n", head); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprint(writer, "\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "fetch "):
+			batch, err := readBatch(lines, line)
+			if err != nil {
+				return err
+			}
+			for _, fetch := range batch {
+				fields := strings.Fields(fetch)
+				if len(fields) != 3 {
+					return fmt.Errorf("malformed command: %s", fetch)
+				}
+				if err := remote.fetch(fields[1]); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprint(writer, "\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "push "):
+			batch, err := readBatch(lines, line)
+			if err != nil {
+				return err
+			}
+			for _, push := range batch {
+				refspec := strings.TrimPrefix(push, "push ")
+				force := strings.HasPrefix(refspec, "+")
+				refs := strings.SplitN(strings.TrimPrefix(refspec, "+"), ":", 2)
+				if len(refs) != 2 {
+					return fmt.Errorf("malformed command: %s", push)
+				}
+				// a failed push of one ref is reported to git, which
+				// carries on with the others
+				if err := remote.push(refs[0], refs[1], force); err != nil {
+					if _, err := fmt.Fprintf(writer, "error %s %s\n", refs[1], err.Error()); err != nil {
+						return err
+					}
+					continue
+				}
+				if _, err := fmt.Fprintf(writer, "ok %s\n", refs[1]); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprint(writer, "\n"); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported command: %s", line)
+		}
+	}
+}
+
+// readBatch reads the commands after first up to the blank line which ends
+// a batch of fetches or pushes.
+func readBatch(lines *bufio.Reader, first string) ([]string, error) {
+	batch := []string{first}
+	for {
+		line, err := readLine(lines)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			return batch, nil
+		}
+		batch = append(batch, line)
+	}
+}
+
+func readLine(lines *bufio.Reader) (string, error) {
+	line, err := lines.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\n"), nil
+}
+
+// headRef returns the ref HEAD points to, refs/heads/master if there's one.
+func headRef(refs []*ref) string {
+	for _, ref := range refs {
+		if ref.name == "refs/heads/master" {
+			return ref.name
+		}
+	}
+	for _, ref := range refs {
+		if strings.HasPrefix(ref.name, "refs/heads/") {
+			return ref.name
+		}
+	}
+	return ""
+}
+
+func getAPIClient(address string) (pfs.APIClient, error) {
+	clientConn, err := audit.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewAPIClient(clientConn), nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+)
+
+// Each git commit is stored as a pfs commit of its tree with no parent,
+// tagged git-<sha>. The raw commit and tree objects are kept under metaDir
+// so fetch can write back objects with the same shas.
+//
+// pfs tags can't be moved, so each update of a ref is a new tag
+// ref-<generation>-<ref> of the pfs commit the ref points to, with the /s of
+// the ref replaced by :s which git doesn't allow in refs. The highest
+// generation is the ref's value. Two pushes of the same ref race to tag the
+// next generation and the loser fails.
+const (
+	metaDir         = ".git-pfs"
+	commitTagPrefix = "git-"
+	refTagPrefix    = "ref-"
+)
+
+type ref struct {
+	name       string
+	sha        string
+	generation uint64
+}
+
+type remote struct {
+	apiClient pfs.APIClient
+	repoName  string
+}
+
+func newRemote(apiClient pfs.APIClient, repoName string) *remote {
+	return &remote{apiClient, repoName}
+}
+
+// list returns the refs sorted by name. A repo which doesn't exist yet has
+// no refs, the first push creates it.
+func (r *remote) list() ([]*ref, error) {
+	if _, err := pfsutil.InspectRepo(r.apiClient, r.repoName); err != nil {
+		return nil, nil
+	}
+	refs, _, err := r.refs()
+	if err != nil {
+		return nil, err
+	}
+	var result []*ref
+	for _, ref := range refs {
+		result = append(result, ref)
+	}
+	sort.Sort(sortRefs(result))
+	return result, nil
+}
+
+// refs returns the refs by name and the shas of the git commits which have
+// been pushed.
+func (r *remote) refs() (map[string]*ref, map[string]bool, error) {
+	tagInfos, err := pfsutil.ListTag(r.apiClient, r.repoName)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the shas of the pfs commits by id
+	shas := make(map[string]string)
+	pushed := make(map[string]bool)
+	for _, tagInfo := range tagInfos {
+		if strings.HasPrefix(tagInfo.Tag, commitTagPrefix) {
+			sha := strings.TrimPrefix(tagInfo.Tag, commitTagPrefix)
+			shas[tagInfo.Commit.Id] = sha
+			pushed[sha] = true
+		}
+	}
+	refs := make(map[string]*ref)
+	for _, tagInfo := range tagInfos {
+		if !strings.HasPrefix(tagInfo.Tag, refTagPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(tagInfo.Tag, refTagPrefix), "-", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		generation, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		name := strings.Replace(parts[1], ":", "/", -1)
+		sha, ok := shas[tagInfo.Commit.Id]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s refers to commit %s which isn't a git commit", tagInfo.Tag, tagInfo.Commit.Id)
+		}
+		if existing, ok := refs[name]; ok && existing.generation > generation {
+			continue
+		}
+		refs[name] = &ref{name, sha, generation}
+	}
+	return refs, pushed, nil
+}
+
+// push pushes the commits src has which the repo doesn't and points dst at
+// src. Unless force is true dst has to be an ancestor of src.
+func (r *remote) push(src string, dst string, force bool) error {
+	if src == "" {
+		return errors.New("refs can't be deleted from pfs")
+	}
+	sha, err := gitString("rev-parse", "--verify", src+"^{commit}")
+	if err != nil {
+		return err
+	}
+	if _, err := pfsutil.InspectRepo(r.apiClient, r.repoName); err != nil {
+		if err := pfsutil.CreateRepo(r.apiClient, r.repoName); err != nil {
+			return err
+		}
+	}
+	refs, pushed, err := r.refs()
+	if err != nil {
+		return err
+	}
+	var generation uint64 = 1
+	if old, ok := refs[dst]; ok {
+		if old.sha == sha {
+			return nil
+		}
+		if !force && !isAncestor(old.sha, sha) {
+			return errors.New("non-fast-forward")
+		}
+		generation = old.generation + 1
+	}
+	shas, err := gitLines("rev-list", "--reverse", "--topo-order", sha)
+	if err != nil {
+		return err
+	}
+	for _, sha := range shas {
+		if pushed[sha] {
+			continue
+		}
+		if err := r.pushCommit(sha); err != nil {
+			return err
+		}
+	}
+	return pfsutil.TagCommit(r.apiClient, r.repoName, commitTag(sha), refTag(generation, dst))
+}
+
+// pushCommit stores the git commit sha as a pfs commit, its first parent
+// has to have been pushed already. Files which are unchanged from the first
+// parent are copied rather than sent.
+func (r *remote) pushCommit(sha string) error {
+	raw, err := git(nil, "cat-file", "commit", sha)
+	if err != nil {
+		return err
+	}
+	treeSha, parents := parseCommit(raw)
+	entries, err := lsTree(sha, true)
+	if err != nil {
+		return err
+	}
+	parentBlobs := make(map[string]string)
+	if len(parents) > 0 {
+		parentEntries, err := lsTree(parents[0], false)
+		if err != nil {
+			return err
+		}
+		for _, entry := range parentEntries {
+			parentBlobs[entry.path] = entry.sha
+		}
+	}
+	commit, err := pfsutil.StartCommit(r.apiClient, r.repoName, "")
+	if err != nil {
+		return err
+	}
+	if err := func() error {
+		if err := pfsutil.MakeDirectory(r.apiClient, r.repoName, commit.Id, metaDir); err != nil {
+			return err
+		}
+		if err := pfsutil.MakeDirectory(r.apiClient, r.repoName, commit.Id, path.Join(metaDir, "trees")); err != nil {
+			return err
+		}
+		trees := make(map[string]bool)
+		putTree := func(treeSha string) error {
+			if trees[treeSha] {
+				return nil
+			}
+			trees[treeSha] = true
+			tree, err := git(nil, "cat-file", "tree", treeSha)
+			if err != nil {
+				return err
+			}
+			_, err = pfsutil.PutFile(r.apiClient, r.repoName, commit.Id, treePath(treeSha), 0, bytes.NewReader(tree))
+			return err
+		}
+		if err := putTree(treeSha); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.path == metaDir || strings.HasPrefix(entry.path, metaDir+"/") {
+				return fmt.Errorf("%s has %s which is where git-remote-pfs keeps git's objects", sha, entry.path)
+			}
+			switch entry.objectType {
+			case "tree":
+				if err := pfsutil.MakeDirectory(r.apiClient, r.repoName, commit.Id, entry.path); err != nil {
+					return err
+				}
+				if err := putTree(entry.sha); err != nil {
+					return err
+				}
+			case "blob":
+				if parentBlobs[entry.path] == entry.sha {
+					if err := pfsutil.CopyFile(r.apiClient, r.repoName, commitTag(parents[0]), entry.path, r.repoName, commit.Id, entry.path, false); err != nil {
+						return err
+					}
+					continue
+				}
+				blob, err := git(nil, "cat-file", "blob", entry.sha)
+				if err != nil {
+					return err
+				}
+				if _, err := pfsutil.PutFile(r.apiClient, r.repoName, commit.Id, entry.path, 0, bytes.NewReader(blob)); err != nil {
+					return err
+				}
+			}
+			// submodules are only entries in their tree, there's nothing to store
+		}
+		if _, err := pfsutil.PutFile(r.apiClient, r.repoName, commit.Id, path.Join(metaDir, "commit"), 0, bytes.NewReader(raw)); err != nil {
+			return err
+		}
+		if _, err := pfsutil.FinishCommit(r.apiClient, r.repoName, commit.Id); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		// a partly stored git commit is trashed rather than left for
+		// someone to mistake for a complete one
+		_ = pfsutil.ForceFinishCommit(r.apiClient, r.repoName, commit.Id)
+		_ = pfsutil.DeleteCommit(r.apiClient, r.repoName, commit.Id)
+		return err
+	}
+	if err := pfsutil.TagCommit(r.apiClient, r.repoName, commit.Id, commitTag(sha)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "pushed %s as %s\n", sha, commit.Id)
+	return nil
+}
+
+// fetch writes the git commit sha and its history to the local repo, from
+// the objects stored with it.
+func (r *remote) fetch(sha string) error {
+	if hasObject(sha) {
+		return nil
+	}
+	commitID := commitTag(sha)
+	var raw bytes.Buffer
+	if err := pfsutil.GetFile(r.apiClient, r.repoName, commitID, path.Join(metaDir, "commit"), 0, 0, nil, &raw); err != nil {
+		return err
+	}
+	treeSha, parents := parseCommit(raw.Bytes())
+	for _, parent := range parents {
+		if err := r.fetch(parent); err != nil {
+			return err
+		}
+	}
+	if err := r.fetchTree(commitID, treeSha, ""); err != nil {
+		return err
+	}
+	return writeObject("commit", sha, raw.Bytes())
+}
+
+// fetchTree writes the tree treeSha, at dir in the pfs commit commitID, and
+// what's in it to the local repo.
+func (r *remote) fetchTree(commitID string, treeSha string, dir string) error {
+	if hasObject(treeSha) {
+		return nil
+	}
+	var raw bytes.Buffer
+	if err := pfsutil.GetFile(r.apiClient, r.repoName, commitID, treePath(treeSha), 0, 0, nil, &raw); err != nil {
+		return err
+	}
+	entries, err := parseTree(raw.Bytes())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.path)
+		switch entry.objectType {
+		case "tree":
+			if err := r.fetchTree(commitID, entry.sha, entryPath); err != nil {
+				return err
+			}
+		case "blob":
+			if hasObject(entry.sha) {
+				continue
+			}
+			var blob bytes.Buffer
+			// pfs doesn't store files which were never written to
+			if entry.sha != emptyBlobSha {
+				if err := pfsutil.GetFile(r.apiClient, r.repoName, commitID, entryPath, 0, 0, nil, &blob); err != nil {
+					return err
+				}
+			}
+			if err := writeObject("blob", entry.sha, blob.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+	return writeObject("tree", treeSha, raw.Bytes())
+}
+
+func commitTag(sha string) string {
+	return commitTagPrefix + sha
+}
+
+func refTag(generation uint64, name string) string {
+	return fmt.Sprintf("%s%d-%s", refTagPrefix, generation, strings.Replace(name, "/", ":", -1))
+}
+
+func treePath(treeSha string) string {
+	return path.Join(metaDir, "trees", treeSha)
+}
+
+type sortRefs []*ref
+
+func (s sortRefs) Len() int           { return len(s) }
+func (s sortRefs) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortRefs) Less(i, j int) bool { return s[i].name < s[j].name }
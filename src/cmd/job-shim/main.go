@@ -3,24 +3,53 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/fuse"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/obj"
 	"github.com/pachyderm/pachyderm/src/pps"
+	"github.com/pachyderm/pachyderm/src/pps/ppsutil"
 	"github.com/spf13/cobra"
 	"go.pedge.io/env"
 	"go.pedge.io/pkg/exec"
+	"go.pedge.io/proto/time"
 	"go.pedge.io/protolog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
 
+// debugOutputTTL is how long the output written while debugging a job is
+// kept after it's written.
+const debugOutputTTL = 24 * time.Hour
+
 type appEnv struct {
 	PachydermPfsd1Port string `env:"PACHYDERM_PFSD_1_PORT"`
 	PfsAddress         string `env:"PFS_ADDRESS,default=0.0.0.0:650"`
 	PachydermPpsd1Port string `env:"PACHYDERM_PPSD_1_PORT"`
 	PpsAddress         string `env:"PPS_ADDRESS,default=0.0.0.0:651"`
+	// the node's block cache, shared by every job on the node
+	PfsCacheDir   string `env:"PFS_CACHE_DIR"`
+	PfsCacheBytes uint64 `env:"PFS_CACHE_BYTES,default=10737418240"`
+	// if set the cache is read only, blocks missing from it are requested
+	// here for the pod's --fill-cache container to add
+	PfsCacheRequestDir string `env:"PFS_CACHE_REQUEST_DIR"`
+	// objd's block directory, it only has blocks if we're on objd's node,
+	// only the --fill-cache container is given it
+	PfsBlockDir string `env:"PFS_BLOCK_DIR"`
+	// log the fuse debug event of one in this many ops, 0 logs them all
+	PfsSampleEvents uint64 `env:"PFS_SAMPLE_EVENTS"`
+	// the largest grpc message we'll send or receive
+	MaxMsgSize int `env:"GRPC_MAX_MSG_SIZE,default=67108864"`
+	// kubernetes sets the hostname to the pod's name
+	PodName string `env:"HOSTNAME"`
 }
 
 func main() {
@@ -30,6 +59,10 @@ func main() {
 func do(appEnvObj interface{}) error {
 	protolog.SetLevel(protolog.Level_LEVEL_DEBUG)
 	appEnv := appEnvObj.(*appEnv)
+	grpcutil.SetMaxMsgSize(appEnv.MaxMsgSize)
+	var debugShard int64
+	var speculative bool
+	var fillCache bool
 	rootCmd := &cobra.Command{
 		Use:   os.Args[0] + " job-id",
 		Short: `Pachyderm job-shim, coordinates with ppsd to create an output commit and run user work.`,
@@ -40,23 +73,64 @@ func do(appEnvObj interface{}) error {
 				errorAndExit(err.Error())
 			}
 
+			if fillCache {
+				if err := fuse.FillCache(pfsAPIClient, mounterOptions(appEnv)); err != nil {
+					errorAndExit(err.Error())
+				}
+				return
+			}
+			// the cache filler exits once we won't request any more blocks
+			defer finishCacheRequests()
+
 			ppsAPIClient, err := getPpsAPIClient(getPpsdAddress(appEnv))
 			if err != nil {
 				errorAndExit(err.Error())
 			}
 
+			if debugShard >= 0 {
+				if err := debug(appEnv, pfsAPIClient, ppsAPIClient, args[0], uint64(debugShard)); err != nil {
+					errorAndExit(err.Error())
+				}
+				return
+			}
+
 			response, err := ppsAPIClient.StartJob(
 				context.Background(),
 				&pps.StartJobRequest{
 					Job: &pps.Job{
 						Id: args[0],
-					}})
+					},
+					PodName:     appEnv.PodName,
+					Speculative: speculative,
+				})
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+				finishCacheRequests()
 				os.Exit(0)
 			}
+			if response.Cached {
+				// the output for this shard has already been filled in from
+				// the datum cache, there's no need to run the transform
+				if _, err := ppsAPIClient.FinishJob(
+					context.Background(),
+					&pps.FinishJobRequest{
+						Job: &pps.Job{
+							Id: args[0],
+						},
+						Index:   response.Index,
+						Success: true,
+						Stats: &pps.JobStats{
+							Datums: 1,
+						},
+						Speculative: speculative,
+					},
+				); err != nil {
+					errorAndExit(err.Error())
+				}
+				return
+			}
 
-			mounter := fuse.NewMounter(getPfsdAddress(appEnv), pfsAPIClient)
+			mounter := newMounter(appEnv, pfsAPIClient)
 			ready := make(chan bool)
 			go func() {
 				if err := mounter.Mount(
@@ -73,16 +147,29 @@ func do(appEnvObj interface{}) error {
 					errorAndExit(err.Error())
 				}
 			}()
+			externalMountPoints, err := mountExternalInputs(response.ExternalInputs, response.Shards, response.Index)
+			if err != nil {
+				errorAndExit(err.Error())
+			}
+			defer func() {
+				for _, mountPoint := range externalMountPoints {
+					if err := obj.Unmount(mountPoint); err != nil {
+						errorAndExit(err.Error())
+					}
+				}
+			}()
 			io := pkgexec.IO{
 				Stdin:  strings.NewReader(response.Transform.Stdin),
 				Stdout: os.Stdout,
 				Stderr: os.Stderr,
 			}
 			success := true
-			if err := pkgexec.RunIO(io, response.Transform.Cmd...); err != nil {
+			start := time.Now()
+			if err := runTransform(io, prototime.DurationFromProto(response.Transform.DatumTimeout), response.Transform.Cmd...); err != nil {
 				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 				success = false
 			}
+			mountStats := mounter.Stats()
 			if _, err := ppsAPIClient.FinishJob(
 				context.Background(),
 				&pps.FinishJobRequest{
@@ -91,16 +178,168 @@ func do(appEnvObj interface{}) error {
 					},
 					Index:   response.Index,
 					Success: success,
+					Stats: &pps.JobStats{
+						Datums:       1,
+						ProcessTime:  prototime.DurationToProto(time.Since(start)),
+						BytesRead:    mountStats.BytesRead,
+						BytesWritten: mountStats.BytesWritten,
+					},
+					Speculative: speculative,
 				},
 			); err != nil {
 				errorAndExit(err.Error())
 			}
 		},
 	}
+	rootCmd.Flags().BoolVar(&speculative, "speculative", false, "Run a straggling shard of the job again, ppsd picks the shard.")
+	rootCmd.Flags().BoolVar(&fillCache, "fill-cache", false, "Add the blocks the job's mounts request to the node's cache until the job's container is done, rather than running the job.")
+	rootCmd.Flags().Int64Var(&debugShard, "debug-shard", -1, "Mount what this shard of the job sees, with a scratch output, and wait to be killed rather than running the job.")
 
 	return rootCmd.Execute()
 }
 
+// debug mounts what shard of the job sees at /pfs, with a commit of the job's
+// debug repo as its output, and waits to be killed. It's what pachctl
+// debug-job execs into.
+func debug(appEnv *appEnv, pfsAPIClient pfs.APIClient, ppsAPIClient pps.APIClient, jobID string, shard uint64) error {
+	job := &pps.Job{Id: jobID}
+	jobInfo, err := ppsAPIClient.InspectJob(
+		context.Background(),
+		&pps.InspectJobRequest{
+			Job: job,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	commitMounts := ppsutil.InputCommitMounts(jobInfo.Inputs, jobInfo.Shards, shard)
+	repo := pps.JobDebugRepo(job)
+	if _, err := pfsutil.InspectRepo(pfsAPIClient, repo.Name); err != nil {
+		if err := pfsutil.CreateScratchRepo(pfsAPIClient, repo.Name, debugOutputTTL); err != nil {
+			return err
+		}
+	}
+	outputCommit, err := pfsutil.StartCommit(pfsAPIClient, repo.Name, "")
+	if err != nil {
+		return err
+	}
+	commitMounts = append(commitMounts, &fuse.CommitMount{
+		Commit: outputCommit,
+		Alias:  "out",
+	})
+	mounter := newMounter(appEnv, pfsAPIClient)
+	ready := make(chan bool)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mounter.Mount("/pfs", commitMounts, ready)
+	}()
+	<-ready
+	externalMountPoints, err := mountExternalInputs(jobInfo.ExternalInputs, jobInfo.Shards, shard)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Mounted shard %d of job %s at /pfs, /pfs/out is %s@%s.\n", shard, jobID, repo.Name, outputCommit.Id)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	select {
+	case <-signals:
+	case err := <-errs:
+		return err
+	}
+	if err := mounter.Unmount("/pfs"); err != nil {
+		return err
+	}
+	for _, mountPoint := range externalMountPoints {
+		if err := obj.Unmount(mountPoint); err != nil {
+			return err
+		}
+	}
+	if _, err := pfsutil.FinishCommit(pfsAPIClient, repo.Name, outputCommit.Id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mountExternalInputs mounts the objects of each external input which shard
+// reads at ppsutil.ExternalMountDir/name and returns the mount points. The
+// objects are read from the object store as the transform reads them.
+func mountExternalInputs(externalInputs []*pps.ExternalInput, shards uint64, shard uint64) ([]string, error) {
+	var mountPoints []string
+	for _, externalInput := range externalInputs {
+		client, prefix, err := obj.NewClientFromURL(context.Background(), externalInput.Url)
+		if err != nil {
+			return nil, err
+		}
+		var objects []*obj.Object
+		if err := client.Walk(prefix, func(object *obj.Object) error {
+			if ppsutil.ExternalObjectShard(object.Name, shards) == shard {
+				objects = append(objects, object)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		mountPoint := path.Join(ppsutil.ExternalMountDir, externalInput.Name)
+		ready := make(chan bool)
+		go func() {
+			if err := obj.Mount(client, prefix, objects, mountPoint, ready); err != nil {
+				errorAndExit(err.Error())
+			}
+		}()
+		<-ready
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	return mountPoints, nil
+}
+
+// runTransform runs args like pkgexec.RunIO, but kills it if it's still
+// running after timeout, 0 means no timeout.
+func runTransform(io pkgexec.IO, timeout time.Duration, args ...string) error {
+	if timeout == 0 {
+		return pkgexec.RunIO(io, args...)
+	}
+	if len(args) == 0 {
+		return pkgexec.ErrNoArgs
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = io.Stdin
+	cmd.Stdout = io.Stdout
+	cmd.Stderr = io.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(timeout, func() {
+		_ = cmd.Process.Kill()
+	})
+	err := cmd.Wait()
+	// the timer has already fired if it can't be stopped
+	if !timer.Stop() {
+		return fmt.Errorf("%s: killed after the datum timeout of %s", strings.Join(args, " "), timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", strings.Join(args, " "), err.Error())
+	}
+	return nil
+}
+
+func newMounter(appEnv *appEnv, pfsAPIClient pfs.APIClient) fuse.Mounter {
+	return fuse.NewMounterWithOptions(
+		getPfsdAddress(appEnv),
+		pfsAPIClient,
+		mounterOptions(appEnv),
+	)
+}
+
+func mounterOptions(appEnv *appEnv) fuse.MounterOptions {
+	return fuse.MounterOptions{
+		CacheDir:        appEnv.PfsCacheDir,
+		CacheBytes:      appEnv.PfsCacheBytes,
+		CacheRequestDir: appEnv.PfsCacheRequestDir,
+		BlockDir:        appEnv.PfsBlockDir,
+		SampleEvents:    appEnv.PfsSampleEvents,
+	}
+}
+
 func getPfsdAddress(appEnv *appEnv) string {
 	if pfsdAddr := os.Getenv("PFSD_PORT_650_TCP_ADDR"); pfsdAddr != "" {
 		return fmt.Sprintf("%s:650", pfsdAddr)
@@ -122,7 +361,7 @@ func getPpsdAddress(appEnv *appEnv) string {
 }
 
 func getPfsAPIClient(address string) (pfs.APIClient, error) {
-	clientConn, err := grpc.Dial(address, grpc.WithInsecure())
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
 	if err != nil {
 		return nil, err
 	}
@@ -130,7 +369,7 @@ func getPfsAPIClient(address string) (pfs.APIClient, error) {
 }
 
 func getPpsAPIClient(address string) (pps.APIClient, error) {
-	clientConn, err := grpc.Dial(address, grpc.WithInsecure())
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
 	if err != nil {
 		return nil, err
 	}
@@ -139,5 +378,16 @@ func getPpsAPIClient(address string) (pps.APIClient, error) {
 
 func errorAndExit(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "%s\n", fmt.Sprintf(format, args...))
+	finishCacheRequests()
 	os.Exit(1)
 }
+
+// finishCacheRequests lets the pod's cache filler exit, blocks missed after
+// it has are read from pfs without being cached.
+func finishCacheRequests() {
+	if requestDir := os.Getenv("PFS_CACHE_REQUEST_DIR"); requestDir != "" {
+		if err := fuse.FinishCacheRequests(requestDir); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+	}
+}
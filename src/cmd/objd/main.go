@@ -4,9 +4,13 @@ import (
 	"github.com/pachyderm/pachyderm"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pfs/drive/server"
+	"github.com/pachyderm/pachyderm/src/pkg/admin"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/logsink"
 	"github.com/pachyderm/pachyderm/src/pkg/netutil"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
 	"go.pedge.io/env"
-	"go.pedge.io/proto/server"
+	"go.pedge.io/protolog"
 	"google.golang.org/grpc"
 )
 
@@ -16,6 +20,23 @@ type appEnv struct {
 	Port        int    `env:"OBJ_PORT,default=652"`
 	HTTPPort    int    `env:"OBJ_HTTP_PORT,default=752"`
 	DebugPort   int    `env:"OBJ_TRACE_PORT,default=1050"`
+	// profiles are served on this port to requests with AdminToken, see
+	// admin.Serve, it's only served if AdminToken is set
+	AdminPort  int    `env:"OBJ_ADMIN_PORT,default=1152"`
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// bytes per second shared by all PullDiff streams, this bounds the
+	// replication traffic of the whole cluster, 0 is unlimited
+	PullDiffRate uint64 `env:"OBJ_PULL_DIFF_RATE"`
+	// percent of the blocks checked for bit rot each day, 0, the default,
+	// turns the scrubber off
+	ScrubPercent uint64 `env:"OBJ_SCRUB_PERCENT"`
+	// address of a drive server with the same blocks, corrupt blocks are
+	// fetched again from it
+	ReplicaAddress string `env:"OBJ_REPLICA_ADDRESS"`
+	// space separated urls of sinks to export log events to, see logsink.Setup
+	LogSinks string `env:"LOG_SINKS"`
+	// the largest grpc message we'll send or receive
+	MaxMsgSize int `env:"GRPC_MAX_MSG_SIZE,default=67108864"`
 }
 
 func main() {
@@ -24,7 +45,11 @@ func main() {
 
 func do(appEnvObj interface{}) error {
 	appEnv := appEnvObj.(*appEnv)
-	var err error
+	grpcutil.SetMaxMsgSize(appEnv.MaxMsgSize)
+	streamSink, err := logsink.Setup(appEnv.LogSinks)
+	if err != nil {
+		return err
+	}
 	address := appEnv.Address
 	if address == "" {
 		address, err = netutil.ExternalIP()
@@ -32,17 +57,34 @@ func do(appEnvObj interface{}) error {
 			return err
 		}
 	}
-	apiServer, err := server.NewLocalAPIServer(appEnv.StorageRoot)
+	var replicaClient drive.APIClient
+	if appEnv.ReplicaAddress != "" {
+		clientConn, err := grpc.Dial(appEnv.ReplicaAddress, grpcutil.DialOptions(grpc.WithInsecure())...)
+		if err != nil {
+			return err
+		}
+		replicaClient = drive.NewAPIClient(clientConn)
+	}
+	apiServer, err := server.NewLocalAPIServer(appEnv.StorageRoot, ratelimit.NewLimiter(appEnv.PullDiffRate), appEnv.ScrubPercent, replicaClient)
 	if err != nil {
 		return err
 	}
-
-	return protoserver.Serve(
+	if appEnv.AdminToken != "" {
+		go func() {
+			if err := admin.Serve(uint16(appEnv.AdminPort), appEnv.AdminToken); err != nil {
+				protolog.Printf("Error serving the admin port %s", err.Error())
+			}
+		}()
+	}
+	return grpcutil.Serve(
 		uint16(appEnv.Port),
 		func(s *grpc.Server) {
 			drive.RegisterAPIServer(s, apiServer)
+			if streamSink != nil {
+				logsink.RegisterAPIServer(s, streamSink)
+			}
 		},
-		protoserver.ServeOptions{
+		grpcutil.ServeOptions{
 			HTTPPort:  uint16(appEnv.HTTPPort),
 			DebugPort: uint16(appEnv.DebugPort),
 			Version:   pachyderm.Version,
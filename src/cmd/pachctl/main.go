@@ -9,7 +9,17 @@ import (
 
 	"github.com/pachyderm/pachyderm"
 	pfscmds "github.com/pachyderm/pachyderm/src/pfs/cmds"
+	admincmds "github.com/pachyderm/pachyderm/src/pkg/admin/cmds"
+	applycmds "github.com/pachyderm/pachyderm/src/pkg/apply/cmds"
+	auditcmds "github.com/pachyderm/pachyderm/src/pkg/audit/cmds"
+	configcmds "github.com/pachyderm/pachyderm/src/pkg/config/cmds"
 	deploycmds "github.com/pachyderm/pachyderm/src/pkg/deploy/cmds"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/logsink"
+	logsinkcmds "github.com/pachyderm/pachyderm/src/pkg/logsink/cmds"
+	querycmds "github.com/pachyderm/pachyderm/src/pkg/query/cmds"
+	shardcmds "github.com/pachyderm/pachyderm/src/pkg/shard/cmds"
+	topcmds "github.com/pachyderm/pachyderm/src/pkg/top/cmds"
 	ppscmds "github.com/pachyderm/pachyderm/src/pps/cmds"
 	"github.com/spf13/cobra"
 	"go.pedge.io/env"
@@ -18,6 +28,7 @@ import (
 	"go.pedge.io/proto/version"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	kube "k8s.io/kubernetes/pkg/client/unversioned"
 )
 
 type appEnv struct {
@@ -31,6 +42,10 @@ type appEnv struct {
 	Provider           string `env:"PROVIDER"`
 	GCEProject         string `env:"GCE_PROJECT"`
 	GCEZone            string `env:"GCE_ZONE"`
+	EtcdAddress        string `env:"ETCD_ADDRESS,default=http://0.0.0.0:2379"`
+	MaxMsgSize         int    `env:"GRPC_MAX_MSG_SIZE,default=67108864"`
+	LogSinks           string `env:"LOG_SINKS"`
+	AdminToken         string `env:"ADMIN_TOKEN"`
 }
 
 func main() {
@@ -39,6 +54,15 @@ func main() {
 
 func do(appEnvObj interface{}) error {
 	appEnv := appEnvObj.(*appEnv)
+	grpcutil.SetMaxMsgSize(appEnv.MaxMsgSize)
+	// mount logs fuse events, which can be exported like a server's
+	streamSink, err := logsink.Setup(appEnv.LogSinks)
+	if err != nil {
+		return err
+	}
+	if streamSink != nil {
+		return fmt.Errorf("pachctl can't serve a stream:// sink")
+	}
 	rootCmd := &cobra.Command{
 		Use: os.Args[0],
 		Long: `Access the Pachyderm API.
@@ -51,7 +75,11 @@ Envronment variables:
   KUBERNETES_PASSWORD
   PROVIDER, which provider to use for cluster creation (currently only supports GCE).
   GCE_PROJECT
-  GCE_ZONE`,
+  GCE_ZONE
+  ETCD_ADDRESS=http://0.0.0.0:2379, the etcd server runtime config is stored in.
+  GRPC_MAX_MSG_SIZE=67108864, the largest message in bytes that will be sent or received.
+  LOG_SINKS, space separated urls of sinks to export log events to, ie file:///var/log/pachctl.log.
+  ADMIN_TOKEN, the token the servers' admin ports require.`,
 	}
 	pfsdAddress := getPfsdAddress(appEnv)
 	ppsdAddress := getPpsdAddress(appEnv)
@@ -62,7 +90,12 @@ Envronment variables:
 	for _, cmd := range pfsCmds {
 		rootCmd.AddCommand(cmd)
 	}
-	ppsCmds, err := ppscmds.Cmds(ppsdAddress)
+	ppsCmds, err := ppscmds.Cmds(ppsdAddress, pfsdAddress, &kube.Config{
+		Host:     appEnv.KubernetesAddress,
+		Username: appEnv.KubernetesUsername,
+		Password: appEnv.KubernetesPassword,
+		Insecure: true,
+	})
 	if err != nil {
 		return err
 	}
@@ -84,6 +117,64 @@ Envronment variables:
 	for _, cmd := range deployCmds {
 		rootCmd.AddCommand(cmd)
 	}
+	configCmds, err := configcmds.Cmds(appEnv.EtcdAddress, "namespace")
+	if err != nil {
+		return err
+	}
+	for _, cmd := range configCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	shardCmds, err := shardcmds.Cmds(appEnv.EtcdAddress, "namespace")
+	if err != nil {
+		return err
+	}
+	for _, cmd := range shardCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	// the audit API is served by pfsd
+	auditCmds, err := auditcmds.Cmds(pfsdAddress)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range auditCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	// so is the query API, if it's enabled
+	queryCmds, err := querycmds.Cmds(pfsdAddress)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range queryCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	logsinkCmds, err := logsinkcmds.Cmds(pfsdAddress, ppsdAddress)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range logsinkCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	applyCmds, err := applycmds.Cmds(pfsdAddress, ppsdAddress)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range applyCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	topCmds, err := topcmds.Cmds(pfsdAddress, ppsdAddress)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range topCmds {
+		rootCmd.AddCommand(cmd)
+	}
+	adminCmds, err := admincmds.Cmds(pfsdAddress, ppsdAddress, appEnv.EtcdAddress, "namespace", appEnv.AdminToken)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range adminCmds {
+		rootCmd.AddCommand(cmd)
+	}
 	version := &cobra.Command{
 		Use:   "version",
 		Short: "Return version information.",
@@ -139,7 +230,7 @@ func getPpsdAddress(appEnv *appEnv) string {
 }
 
 func getVersionAPIClient(address string) (protoversion.APIClient, error) {
-	clientConn, err := grpc.Dial(address, grpc.WithInsecure())
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
 	if err != nil {
 		return nil, err
 	}
@@ -6,7 +6,9 @@ import (
 	"os"
 
 	"go.pedge.io/env"
+	"go.pedge.io/protolog"
 
+	"github.com/pachyderm/pachyderm/src/pkg/config"
 	"github.com/pachyderm/pachyderm/src/pkg/discovery"
 	"github.com/pachyderm/pachyderm/src/pkg/shard"
 )
@@ -26,12 +28,20 @@ func do(appEnvObj interface{}) error {
 	if err != nil {
 		return err
 	}
+	configWatcher := config.NewWatcher(discoveryClient, "namespace")
 	sharder := shard.NewSharder(
-		discoveryClient,
+		discovery.NewCachedClient(discoveryClient, shard.RouteDir("namespace")),
 		appEnv.NumShards,
 		appEnv.NumReplicas,
 		"namespace",
 	)
+	configWatcher.Register(config.LogLevelKey, config.SetLogLevel)
+	configWatcher.Register(shard.RetainedVersionsKey, sharder.SetRetainedVersions)
+	go func() {
+		if err := configWatcher.Watch(nil); err != nil {
+			protolog.Printf("Error from configWatcher.Watch %s", err.Error())
+		}
+	}()
 	return sharder.AssignRoles(nil)
 }
 
@@ -3,7 +3,14 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gengo/grpc-gateway/runtime"
 	"github.com/pachyderm/pachyderm"
@@ -12,12 +19,22 @@ import (
 	"github.com/pachyderm/pachyderm/src/pfs/drive/obj"
 	"github.com/pachyderm/pachyderm/src/pfs/route"
 	"github.com/pachyderm/pachyderm/src/pfs/server"
+	"github.com/pachyderm/pachyderm/src/pkg/admin"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	auditserver "github.com/pachyderm/pachyderm/src/pkg/audit/server"
+	"github.com/pachyderm/pachyderm/src/pkg/config"
 	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"github.com/pachyderm/pachyderm/src/pkg/events"
+	"github.com/pachyderm/pachyderm/src/pkg/feature"
 	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/logsink"
 	"github.com/pachyderm/pachyderm/src/pkg/netutil"
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+	queryserver "github.com/pachyderm/pachyderm/src/pkg/query/server"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
 	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"github.com/pachyderm/pachyderm/src/pps"
 	"go.pedge.io/env"
-	"go.pedge.io/proto/server"
 	"go.pedge.io/protolog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -31,6 +48,36 @@ type appEnv struct {
 	Port        int    `env:"PFS_PORT,default=650"`
 	HTTPPort    int    `env:"PFS_HTTP_PORT,default=750"`
 	DebugPort   int    `env:"PFS_TRACE_PORT,default=1050"`
+	// profiles are served on this port to requests with AdminToken, see
+	// admin.Serve, it's only served if AdminToken is set
+	AdminPort  int    `env:"PFS_ADMIN_PORT,default=1150"`
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// file contents are served over plain HTTP on this port to requests
+	// with ContentToken, see server.NewContentHandler, 0, the default,
+	// turns it off
+	ContentPort  int    `env:"PFS_CONTENT_PORT"`
+	ContentToken string `env:"PFS_CONTENT_TOKEN"`
+	// comma separated origins allowed to fetch file contents from a
+	// browser, "*" allows any
+	CORSOrigins string `env:"PFS_CORS_ORIGINS"`
+	// seconds to keep audit events for, 0 keeps them for audit.DefaultTTL
+	AuditTTL uint64 `env:"PFS_AUDIT_TTL"`
+	// seconds between checks for expired scratch repos
+	ReapInterval uint64 `env:"PFS_REAP_INTERVAL,default=300"`
+	// seconds deleted repos and commits can be restored for
+	TrashWindow uint64 `env:"PFS_TRASH_WINDOW,default=604800"`
+	// bytes per second this server may pull diffs at, 0 is unlimited
+	ReplicationRate uint64 `env:"PFS_REPLICATION_RATE"`
+	// where the shards this server hosts are recorded so that they survive
+	// restarts
+	StateDir string `env:"PFS_STATE_DIR,default=/pfs-state"`
+	// turn the SQL query API on by default, it's off since a query can read
+	// whole files into memory, the features config key overrides this
+	QueryEnabled bool `env:"PFS_QUERY_ENABLED"`
+	// space separated urls of sinks to export log events to, see logsink.Setup
+	LogSinks string `env:"LOG_SINKS"`
+	// the largest grpc message we'll send or receive
+	MaxMsgSize int `env:"GRPC_MAX_MSG_SIZE,default=67108864"`
 }
 
 func main() {
@@ -39,6 +86,14 @@ func main() {
 
 func do(appEnvObj interface{}) error {
 	appEnv := appEnvObj.(*appEnv)
+	grpcutil.SetMaxMsgSize(appEnv.MaxMsgSize)
+	if appEnv.ContentPort != 0 && appEnv.ContentToken == "" {
+		return fmt.Errorf("pachyderm: PFS_CONTENT_PORT is set without PFS_CONTENT_TOKEN")
+	}
+	streamSink, err := logsink.Setup(appEnv.LogSinks)
+	if err != nil {
+		return err
+	}
 	discoveryClient, err := getEtcdClient()
 	if err != nil {
 		return err
@@ -52,35 +107,61 @@ func do(appEnvObj interface{}) error {
 	}
 	address = fmt.Sprintf("%s:%d", address, appEnv.Port)
 	sharder := shard.NewSharder(
-		discoveryClient,
+		discovery.NewCachedClient(discoveryClient, shard.RouteDir("namespace")),
 		appEnv.NumShards,
 		appEnv.NumReplicas,
 		"namespace",
 	)
 	var driver drive.Driver
+	// tars are read straight from objd when there is one
+	var driveAPIClient drive.APIClient
+	replicationLimiter := ratelimit.NewLimiter(appEnv.ReplicationRate)
 	switch appEnv.DriverType {
 	case "obj":
 		objdAddress, err := getObjdAddress()
 		if err != nil {
 			return err
 		}
-		clientConn, err := grpc.Dial(objdAddress, grpc.WithInsecure())
+		clientConn, err := grpc.Dial(objdAddress, grpcutil.DialOptions(grpc.WithInsecure())...)
 		if err != nil {
 			return err
 		}
-		objAPIClient := drive.NewAPIClient(clientConn)
-		driver, err = obj.NewDriver(objAPIClient)
+		driveAPIClient = drive.NewAPIClient(clientConn)
+		driver, err = obj.NewDriver(driveAPIClient, replicationLimiter)
 		if err != nil {
 			return err
 		}
 	default:
 		return fmt.Errorf("unknown value for PFS_DRIVER_TYPE: %s", appEnv.DriverType)
 	}
+	auditRecorder := audit.NewRecorder(discoveryClient, "namespace", appEnv.AuditTTL)
+	pipelineAPIClient, err := getPipelineAPIClient()
+	if err != nil {
+		// pfs works without pps, we just can't tell which repos pipelines use
+		protolog.Printf("Not checking pipelines before deleting repos: %s", err.Error())
+	}
+	// the frontend and internal server have to agree on which shard each
+	// file is on so they share a sharder
+	fileSharder := route.NewSharder(
+		appEnv.NumShards,
+		1,
+	)
+	// exports, scratch repo deletes and events go through our own API so that
+	// they're sharded and audited like any other write
+	clientConn, err := grpc.Dial(
+		address,
+		grpcutil.DialOptions(
+			grpc.WithInsecure(),
+			grpc.WithPerRPCCredentials(audit.NewPrincipalCredentials("pfsd")),
+		)...,
+	)
+	if err != nil {
+		return err
+	}
+	pfsAPIClient := pfs.NewAPIClient(clientConn)
+	eventRecorder := events.NewRecorder(pfsAPIClient, pipelineAPIClient)
 	apiServer := server.NewAPIServer(
-		route.NewSharder(
-			appEnv.NumShards,
-			1,
-		),
+		fileSharder,
 		route.NewRouter(
 			sharder,
 			grpcutil.NewDialer(
@@ -88,17 +169,14 @@ func do(appEnvObj interface{}) error {
 			),
 			address,
 		),
+		auditRecorder,
+		eventRecorder,
+		pipelineAPIClient,
+		driveAPIClient,
+		time.Duration(appEnv.TrashWindow)*time.Second,
 	)
-	go func() {
-		if err := sharder.RegisterFrontend(nil, address, apiServer); err != nil {
-			protolog.Printf("Error from sharder.RegisterFrontend %s", err.Error())
-		}
-	}()
 	internalAPIServer := server.NewInternalAPIServer(
-		route.NewSharder(
-			appEnv.NumShards,
-			1,
-		),
+		fileSharder,
 		route.NewRouter(
 			sharder,
 			grpcutil.NewDialer(
@@ -107,19 +185,82 @@ func do(appEnvObj interface{}) error {
 			address,
 		),
 		driver,
+		filepath.Join(appEnv.StateDir, "shards"),
 	)
+	var defaultFeatures []string
+	if appEnv.QueryEnabled {
+		defaultFeatures = append(defaultFeatures, feature.Query)
+	}
+	featureFlags := feature.NewFlags(defaultFeatures...)
+	configWatcher := config.NewWatcher(discoveryClient, "namespace")
+	configWatcher.Register(config.LogLevelKey, config.SetLogLevel)
+	configWatcher.Register(audit.SensitiveReposKey, auditRecorder.SetSensitiveRepos)
+	configWatcher.Register(obj.ReplicationRateKey, replicationLimiter.SetRate)
+	configWatcher.Register(grpcutil.RPCTimeoutKey, grpcutil.SetRPCTimeout)
+	configWatcher.Register(feature.FeaturesKey, featureFlags.SetFeatures)
+	configWatcher.Register(shard.RetainedVersionsKey, sharder.SetRetainedVersions)
+	configWatcher.Register(route.ShardKeysKey, fileSharder.SetShardKeys)
+	configWatcher.Register(server.SlowOpThresholdKey, internalAPIServer.SetSlowOpThreshold)
+	configWatcher.Register(events.RepoKey, eventRecorder.SetRepo)
+	go func() {
+		if err := configWatcher.Watch(nil); err != nil {
+			protolog.Printf("Error from configWatcher.Watch %s", err.Error())
+		}
+	}()
+	cancel := make(chan bool)
+	var registered sync.WaitGroup
+	registered.Add(2)
 	go func() {
-		if err := sharder.Register(nil, address, internalAPIServer); err != nil {
+		defer registered.Done()
+		if err := sharder.RegisterFrontend(cancel, address, apiServer); err != nil && err != shard.ErrCancelled {
+			protolog.Printf("Error from sharder.RegisterFrontend %s", err.Error())
+		}
+	}()
+	go func() {
+		defer registered.Done()
+		if err := sharder.Register(cancel, address, internalAPIServer); err != nil && err != shard.ErrCancelled {
 			protolog.Printf("Error from sharder.Register %s", err.Error())
 		}
 	}()
-	return protoserver.Serve(
+	go shutdownOnSignal(apiServer, internalAPIServer, cancel, &registered)
+	auditAPIServer := auditserver.NewAPIServer(
+		audit.NewReader(discoveryClient, "namespace", appEnv.AuditTTL),
+		pfsAPIClient,
+	)
+	if appEnv.AdminToken != "" {
+		go func() {
+			if err := admin.Serve(uint16(appEnv.AdminPort), appEnv.AdminToken); err != nil {
+				protolog.Printf("Error serving the admin port %s", err.Error())
+			}
+		}()
+	}
+	go server.Reap(pfsAPIClient, time.Duration(appEnv.ReapInterval)*time.Second, cancel)
+	if appEnv.ContentPort != 0 {
+		var corsOrigins []string
+		if appEnv.CORSOrigins != "" {
+			corsOrigins = strings.Split(appEnv.CORSOrigins, ",")
+		}
+		go func() {
+			if err := http.ListenAndServe(
+				fmt.Sprintf(":%d", appEnv.ContentPort),
+				server.NewContentHandler(pfsAPIClient, auditRecorder, appEnv.ContentToken, corsOrigins),
+			); err != nil {
+				protolog.Printf("Error serving file contents %s", err.Error())
+			}
+		}()
+	}
+	return grpcutil.Serve(
 		uint16(appEnv.Port),
 		func(s *grpc.Server) {
 			pfs.RegisterAPIServer(s, apiServer)
 			pfs.RegisterInternalAPIServer(s, internalAPIServer)
+			audit.RegisterAPIServer(s, auditAPIServer)
+			query.RegisterAPIServer(s, queryserver.NewAPIServer(pfsAPIClient, auditRecorder, featureFlags))
+			if streamSink != nil {
+				logsink.RegisterAPIServer(s, streamSink)
+			}
 		},
-		protoserver.ServeOptions{
+		grpcutil.ServeOptions{
 			HTTPPort:  uint16(appEnv.HTTPPort),
 			DebugPort: uint16(appEnv.DebugPort),
 			Version:   pachyderm.Version,
@@ -130,6 +271,27 @@ func do(appEnvObj interface{}) error {
 	)
 }
 
+// shutdownOnSignal waits for SIGTERM or SIGINT, then stops accepting writes,
+// flushes unfinished commits and deregisters from the sharder before exiting.
+func shutdownOnSignal(
+	apiServer server.APIServer,
+	internalAPIServer server.InternalAPIServer,
+	cancel chan bool,
+	registered *sync.WaitGroup,
+) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+	protolog.Printf("Shutting down")
+	apiServer.Shutdown()
+	if err := internalAPIServer.Flush(); err != nil {
+		protolog.Printf("Error from internalAPIServer.Flush %s", err.Error())
+	}
+	close(cancel)
+	registered.Wait()
+	os.Exit(0)
+}
+
 func getEtcdClient() (discovery.Client, error) {
 	etcdAddress, err := getEtcdAddress()
 	if err != nil {
@@ -146,6 +308,26 @@ func getEtcdAddress() (string, error) {
 	return fmt.Sprintf("http://%s:2379", etcdAddr), nil
 }
 
+func getPipelineAPIClient() (pps.PipelineAPIClient, error) {
+	ppsdAddress, err := getPpsdAddress()
+	if err != nil {
+		return nil, err
+	}
+	clientConn, err := grpc.Dial(ppsdAddress, grpcutil.DialOptions(grpc.WithInsecure())...)
+	if err != nil {
+		return nil, err
+	}
+	return pps.NewPipelineAPIClient(clientConn), nil
+}
+
+func getPpsdAddress() (string, error) {
+	ppsdAddr := os.Getenv("PPSD_PORT_651_TCP_ADDR")
+	if ppsdAddr == "" {
+		return "", errors.New("PPSD_PORT_651_TCP_ADDR not set")
+	}
+	return fmt.Sprintf("%s:651", ppsdAddr), nil
+}
+
 func getObjdAddress() (string, error) {
 	objdAddr := os.Getenv("OBJD_PORT_652_TCP_ADDR")
 	if objdAddr == "" {
@@ -5,16 +5,28 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/fsouza/go-dockerclient"
+	"github.com/gengo/grpc-gateway/runtime"
 	"github.com/pachyderm/pachyderm"
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/admin"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/config"
+	"github.com/pachyderm/pachyderm/src/pkg/console"
+	consoleserver "github.com/pachyderm/pachyderm/src/pkg/console/server"
+	"github.com/pachyderm/pachyderm/src/pkg/container"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"github.com/pachyderm/pachyderm/src/pkg/events"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/logsink"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"github.com/pachyderm/pachyderm/src/pps/jobserver"
 	"github.com/pachyderm/pachyderm/src/pps/persist"
 	persistserver "github.com/pachyderm/pachyderm/src/pps/persist/server"
 	"github.com/pachyderm/pachyderm/src/pps/pipelineserver"
 	"go.pedge.io/env"
-	"go.pedge.io/proto/server"
 	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	kube "k8s.io/kubernetes/pkg/client/unversioned"
 )
@@ -28,7 +40,24 @@ type appEnv struct {
 	DatabaseAddress    string `env:"PPS_DATABASE_ADDRESS"`
 	DatabaseName       string `env:"PPS_DATABASE_NAME,default=pachyderm"`
 	DebugPort          int    `env:"PPS_TRACE_PORT,default=1051"`
+	HTTPPort           int    `env:"PPS_HTTP_PORT,default=751"`
 	RemoveContainers   bool   `env:"PPS_REMOVE_CONTAINERS"`
+	// profiles are served on this port to requests with AdminToken, see
+	// admin.Serve, it's only served if AdminToken is set
+	AdminPort  int    `env:"PPS_ADMIN_PORT,default=1151"`
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// build images for pipelines whose transform has a build context using
+	// the docker daemon from DOCKER_HOST
+	BuildImages bool `env:"PPS_BUILD_IMAGES"`
+	// the registry built images are pushed to, the nodes running jobs have
+	// to be able to pull from it
+	BuildRegistry string `env:"PPS_BUILD_REGISTRY"`
+	// seconds to keep audit events for, 0 keeps them forever
+	AuditTTL uint64 `env:"PPS_AUDIT_TTL"`
+	// space separated urls of sinks to export log events to, see logsink.Setup
+	LogSinks string `env:"LOG_SINKS"`
+	// the largest grpc message we'll send or receive
+	MaxMsgSize int `env:"GRPC_MAX_MSG_SIZE,default=67108864"`
 }
 
 func main() {
@@ -37,19 +66,58 @@ func main() {
 
 func do(appEnvObj interface{}) error {
 	appEnv := appEnvObj.(*appEnv)
+	grpcutil.SetMaxMsgSize(appEnv.MaxMsgSize)
+	streamSink, err := logsink.Setup(appEnv.LogSinks)
+	if err != nil {
+		return err
+	}
 	rethinkAPIServer, err := getRethinkAPIServer(appEnv.DatabaseAddress, appEnv.DatabaseName)
 	if err != nil {
 		return err
 	}
+	// runtime config and auditing are optional for ppsd since it doesn't
+	// otherwise need etcd
+	auditRecorder := audit.NewNopRecorder()
+	var configWatcher config.Watcher
+	if etcdAddress, err := getEtcdAddress(); err == nil {
+		discoveryClient := discovery.NewEtcdClient(etcdAddress)
+		auditRecorder = audit.NewRecorder(discoveryClient, "namespace", appEnv.AuditTTL)
+		configWatcher = config.NewWatcher(discoveryClient, "namespace")
+	} else {
+		protolog.Printf("Auditing is disabled: %s", err.Error())
+	}
 	pfsdAddress, err := getPfsdAddress()
 	if err != nil {
 		return err
 	}
-	clientConn, err := grpc.Dial(pfsdAddress, grpc.WithInsecure())
+	clientConn, err := grpc.Dial(
+		pfsdAddress,
+		grpcutil.DialOptions(
+			grpc.WithInsecure(),
+			grpc.WithPerRPCCredentials(audit.NewPrincipalCredentials("ppsd")),
+		)...,
+	)
 	if err != nil {
 		return err
 	}
 	pfsAPIClient := pfs.NewAPIClient(clientConn)
+	// events need runtime config to say which repo they go to
+	eventRecorder := events.NewNopRecorder()
+	if configWatcher != nil {
+		// pipelines are listed from our own server to skip the events of
+		// pipelines downstream of the events repo
+		ppsClientConn, err := grpc.Dial(
+			fmt.Sprintf("localhost:%d", appEnv.Port),
+			grpcutil.DialOptions(
+				grpc.WithInsecure(),
+				grpc.WithPerRPCCredentials(audit.NewPrincipalCredentials("ppsd")),
+			)...,
+		)
+		if err != nil {
+			return err
+		}
+		eventRecorder = events.NewRecorder(pfsAPIClient, pps.NewPipelineAPIClient(ppsClientConn))
+	}
 	kubeClient, err := getKubeClient()
 	if err != nil {
 		return err
@@ -58,22 +126,71 @@ func do(appEnvObj interface{}) error {
 		pfsAPIClient,
 		rethinkAPIServer,
 		kubeClient,
+		auditRecorder,
+		eventRecorder,
+		jobserver.NewScaler(),
 	)
+	if err := jobAPIServer.Start(); err != nil {
+		return err
+	}
+	if configWatcher != nil {
+		configWatcher.Register(config.LogLevelKey, config.SetLogLevel)
+		configWatcher.Register(jobserver.NamespaceQuotasKey, jobAPIServer.SetNamespaceQuotas)
+		configWatcher.Register(grpcutil.RPCTimeoutKey, grpcutil.SetRPCTimeout)
+		configWatcher.Register(events.RepoKey, eventRecorder.SetRepo)
+		go func() {
+			if err := configWatcher.Watch(nil); err != nil {
+				protolog.Printf("Error from configWatcher.Watch %s", err.Error())
+			}
+		}()
+	}
+	var containerClient container.Client
+	if appEnv.BuildImages {
+		if appEnv.BuildRegistry == "" {
+			return fmt.Errorf("PPS_BUILD_REGISTRY must be set to build images")
+		}
+		dockerClient, err := docker.NewClientFromEnv()
+		if err != nil {
+			return err
+		}
+		containerClient = container.NewDockerClient(dockerClient)
+	}
 	jobAPIClient := pps.NewLocalJobAPIClient(jobAPIServer)
-	pipelineAPIServer := pipelineserver.NewAPIServer(pfsAPIClient, jobAPIClient, rethinkAPIServer)
+	pipelineAPIServer := pipelineserver.NewAPIServer(pfsAPIClient, jobAPIClient, rethinkAPIServer, auditRecorder, containerClient, appEnv.BuildRegistry)
 	if err := pipelineAPIServer.Start(); err != nil {
 		return err
 	}
-	return protoserver.Serve(
+	if appEnv.AdminToken != "" {
+		go func() {
+			if err := admin.Serve(uint16(appEnv.AdminPort), appEnv.AdminToken); err != nil {
+				protolog.Printf("Error serving the admin port %s", err.Error())
+			}
+		}()
+	}
+	consoleAPIServer := consoleserver.NewAPIServer(
+		pfsAPIClient,
+		jobAPIClient,
+		pps.NewLocalPipelineAPIClient(pipelineAPIServer),
+	)
+	return grpcutil.Serve(
 		uint16(appEnv.Port),
 		func(s *grpc.Server) {
 			pps.RegisterJobAPIServer(s, jobAPIServer)
 			pps.RegisterInternalJobAPIServer(s, jobAPIServer)
 			pps.RegisterPipelineAPIServer(s, pipelineAPIServer)
+			console.RegisterAPIServer(s, consoleAPIServer)
+			if streamSink != nil {
+				logsink.RegisterAPIServer(s, streamSink)
+			}
 		},
-		protoserver.ServeOptions{
+		grpcutil.ServeOptions{
+			HTTPPort:  uint16(appEnv.HTTPPort),
 			DebugPort: uint16(appEnv.DebugPort),
 			Version:   pachyderm.Version,
+			// the console API is served over HTTP for web dashboards
+			HTTPRegisterFunc: func(ctx context.Context, mux *runtime.ServeMux, clientConn *grpc.ClientConn) error {
+				return console.RegisterAPIHandler(ctx, mux, clientConn)
+			},
 		},
 	)
 }
@@ -100,6 +217,14 @@ func getRethinkAddress() (string, error) {
 	return fmt.Sprintf("%s:28015", rethinkAddr), nil
 }
 
+func getEtcdAddress() (string, error) {
+	etcdAddr := os.Getenv("ETCD_PORT_2379_TCP_ADDR")
+	if etcdAddr == "" {
+		return "", errors.New("ETCD_PORT_2379_TCP_ADDR not set")
+	}
+	return fmt.Sprintf("http://%s:2379", etcdAddr), nil
+}
+
 func getPfsdAddress() (string, error) {
 	pfsdAddr := os.Getenv("PFSD_PORT_650_TCP_ADDR")
 	if pfsdAddr == "" {
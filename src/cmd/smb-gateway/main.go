@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/fuse"
+	"github.com/pachyderm/pachyderm/src/pfs/smb"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"go.pedge.io/env"
+	"google.golang.org/grpc"
+)
+
+type appEnv struct {
+	PachydermPfsd1Port string `env:"PACHYDERM_PFSD_1_PORT"`
+	PfsAddress         string `env:"PFS_ADDRESS,default=0.0.0.0:650"`
+	MountPoint         string `env:"SMB_MOUNT_POINT,default=/pfs"`
+	ConfigPath         string `env:"SMB_CONFIG,default=/etc/samba/smb.conf"`
+	Workgroup          string `env:"SMB_WORKGROUP,default=WORKGROUP"`
+	// the user Windows clients connect as
+	User     string `env:"SMB_USER,default=pachyderm"`
+	Password string `env:"SMB_PASSWORD,required"`
+	ReadOnly bool   `env:"SMB_READ_ONLY"`
+	// space separated repo/commit:alias to share, every repo is shared if
+	// it's empty
+	CommitMounts string `env:"SMB_COMMIT_MOUNTS"`
+	// the largest grpc message we'll send or receive
+	MaxMsgSize int `env:"GRPC_MAX_MSG_SIZE,default=67108864"`
+}
+
+func main() {
+	env.Main(do, &appEnv{})
+}
+
+func do(appEnvObj interface{}) error {
+	appEnv := appEnvObj.(*appEnv)
+	grpcutil.SetMaxMsgSize(appEnv.MaxMsgSize)
+	address := appEnv.PfsAddress
+	if appEnv.PachydermPfsd1Port != "" {
+		address = strings.Replace(appEnv.PachydermPfsd1Port, "tcp://", "", -1)
+	}
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
+	if err != nil {
+		return err
+	}
+	apiClient := pfs.NewAPIClient(clientConn)
+	if err := smb.AddUser(appEnv.User, appEnv.Password); err != nil {
+		return err
+	}
+	// smbd serves as root, so the mount doesn't need allow_other
+	mounter := fuse.NewMounterWithOptions(address, apiClient, fuse.MounterOptions{ReadOnly: appEnv.ReadOnly})
+	ready := make(chan bool)
+	mountErr := make(chan error, 1)
+	go func() {
+		mountErr <- mounter.Mount(appEnv.MountPoint, fuse.ParseCommitMounts(strings.Fields(appEnv.CommitMounts)), ready)
+	}()
+	<-ready
+	select {
+	case err := <-mountErr:
+		return err
+	default:
+	}
+	defer func() {
+		_ = mounter.Unmount(appEnv.MountPoint)
+	}()
+	return smb.Serve(
+		smb.Config{
+			MountPoint: appEnv.MountPoint,
+			Workgroup:  appEnv.Workgroup,
+			User:       appEnv.User,
+			ReadOnly:   appEnv.ReadOnly,
+		},
+		appEnv.ConfigPath,
+	)
+}
@@ -1,18 +1,28 @@
 package cmds
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
 	"math"
+	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pfs/fuse"
+	"github.com/pachyderm/pachyderm/src/pfs/nfs"
 	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
 	"github.com/pachyderm/pachyderm/src/pfs/pretty"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
 	"github.com/spf13/cobra"
 	"go.pedge.io/pkg/cobra"
 	"go.pedge.io/protolog"
@@ -40,6 +50,7 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		cmd.Flags().IntVarP(&blockModulus, "block-modulus", "n", 1, "modulus of block shard")
 	}
 
+	var ttl time.Duration
 	createRepo := &cobra.Command{
 		Use:   "create-repo repo-name",
 		Short: "Create a new repo.",
@@ -49,9 +60,13 @@ func Cmds(address string) ([]*cobra.Command, error) {
 			if err != nil {
 				return err
 			}
+			if ttl != 0 {
+				return pfsutil.CreateScratchRepo(apiClient, args[0], ttl)
+			}
 			return pfsutil.CreateRepo(apiClient, args[0])
 		}),
 	}
+	createRepo.Flags().DurationVar(&ttl, "ttl", 0, "make a scratch repo which is deleted once ttl has passed since its last commit, ie 24h")
 
 	inspectRepo := &cobra.Command{
 		Use:   "inspect-repo repo-name",
@@ -98,19 +113,72 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	dedupStats := &cobra.Command{
+		Use:   "dedup-stats [repo-name...]",
+		Short: "Return how much storage the commits of repos share.",
+		Long: `Return how much storage the commits of repos share, all repos if none are
+given. The dedup ratio is the size of all of a repo's commits, each counting the
+data it has from its ancestors, over the storage they take up.`,
+		Run: pkgcobra.Run(func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			repoDedupStats, err := pfsutil.DedupStats(apiClient, args...)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintRepoDedupStatsHeader(writer)
+			for _, stats := range repoDedupStats {
+				pretty.PrintRepoDedupStats(writer, stats)
+			}
+			return writer.Flush()
+		}),
+	}
+
+	var forceDelete bool
+	var purge bool
 	deleteRepo := &cobra.Command{
 		Use:   "delete-repo repo-name",
 		Short: "Delete a repo.",
-		Long:  "Delete a repo.",
+		Long: `Delete a repo and all of its commits.
+Deleted repos are moved to the trash, they can be brought back with restore
+until the trash is emptied. --purge deletes the repo immediately.
+Repos which pipelines read from or write to are only deleted if --force is given.
+A purge which fails part way through can be finished by running it again.`,
 		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
 				return err
 			}
-			return pfsutil.DeleteRepo(apiClient, args[0])
+			var shardDeletions []*pfs.ShardDeletion
+			if purge {
+				shardDeletions, err = pfsutil.PurgeRepo(apiClient, args[0], forceDelete)
+			} else if forceDelete {
+				shardDeletions, err = pfsutil.ForceDeleteRepo(apiClient, args[0])
+			} else {
+				shardDeletions, err = pfsutil.DeleteRepo(apiClient, args[0])
+			}
+			if err != nil {
+				return err
+			}
+			if len(shardDeletions) == 0 {
+				return nil
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintShardDeletionHeader(writer)
+			for _, shardDeletion := range shardDeletions {
+				pretty.PrintShardDeletion(writer, shardDeletion)
+			}
+			return writer.Flush()
 		}),
 	}
+	deleteRepo.Flags().BoolVarP(&forceDelete, "force", "f", false, "delete the repo even if pipelines read from or write to it")
+	deleteRepo.Flags().BoolVar(&purge, "purge", false, "delete the repo immediately rather than moving it to the trash")
 
+	var transactionID string
+	var startExclusive bool
 	startCommit := &cobra.Command{
 		Use:   "start-commit repo-name [parent-commit-id]",
 		Short: "Start a new commit.",
@@ -124,7 +192,18 @@ func Cmds(address string) ([]*cobra.Command, error) {
 			if len(args) == 2 {
 				parentCommitID = args[1]
 			}
-			commit, err := pfsutil.StartCommit(apiClient, args[0], parentCommitID)
+			var commit *pfs.Commit
+			switch {
+			case transactionID != "":
+				commit, err = pfsutil.StartCommitInTransaction(apiClient, args[0], parentCommitID, transactionID)
+			case startExclusive:
+				if parentCommitID == "" {
+					return fmt.Errorf("--exclusive needs a parent-commit-id")
+				}
+				commit, err = pfsutil.StartCommitExpectingParent(apiClient, args[0], parentCommitID)
+			default:
+				commit, err = pfsutil.StartCommit(apiClient, args[0], parentCommitID)
+			}
 			if err != nil {
 				return err
 			}
@@ -132,19 +211,101 @@ func Cmds(address string) ([]*cobra.Command, error) {
 			return nil
 		}),
 	}
+	startCommit.Flags().StringVarP(&transactionID, "transaction", "t", "", "start the commit in a transaction, it's finished by finish-transaction")
+	startCommit.Flags().BoolVarP(&startExclusive, "exclusive", "x", false, "fail with a conflict if the parent commit already has a finished child")
 
+	var force bool
+	var finishExclusive bool
 	finishCommit := &cobra.Command{
 		Use:   "finish-commit repo-name commit-id",
 		Short: "Finish a started commit.",
-		Long:  "Finish a started commit. Commit-id must be a writeable commit.",
+		Long: `Finish a started commit. Commit-id must be a writeable commit.
+Waits for in-flight reads and writes to the commit unless --force is given.`,
 		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
 				return err
 			}
-			return pfsutil.FinishCommit(apiClient, args[0], args[1])
+			if force {
+				return pfsutil.ForceFinishCommit(apiClient, args[0], args[1])
+			}
+			if finishExclusive {
+				commitInfo, err := pfsutil.InspectCommit(apiClient, args[0], args[1])
+				if err != nil {
+					return err
+				}
+				if commitInfo.ParentCommit == nil {
+					return fmt.Errorf("commit %s/%s has no parent", args[0], args[1])
+				}
+				return pfsutil.FinishCommitExpectingParent(apiClient, args[0], args[1], commitInfo.ParentCommit.Id)
+			}
+			if _, err := pfsutil.FinishCommit(apiClient, args[0], args[1]); err != nil {
+				return err
+			}
+			return nil
+		}),
+	}
+	finishCommit.Flags().BoolVarP(&force, "force", "f", false, "finish without waiting for in-flight reads and writes, the writes will fail")
+	finishCommit.Flags().BoolVarP(&finishExclusive, "exclusive", "x", false, "fail with a conflict, leaving the commit started, if another child of its parent finished first")
+
+	startTransaction := &cobra.Command{
+		Use:   "start-transaction",
+		Short: "Start a transaction.",
+		Long:  "Start a transaction, commits started with start-commit --transaction are finished together by finish-transaction.",
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			transaction, err := pfsutil.StartTransaction(apiClient)
+			if err != nil {
+				return err
+			}
+			fmt.Println(transaction.Id)
+			return nil
+		}),
+	}
+
+	finishTransaction := &cobra.Command{
+		Use:   "finish-transaction transaction-id",
+		Short: "Finish the commits started in a transaction.",
+		Long: `Finish the commits started in a transaction all at once, readers see all of
+them finished or none of them.`,
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			return pfsutil.FinishTransaction(apiClient, args[0])
+		}),
+	}
+
+	var strategy string
+	merge := &cobra.Command{
+		Use:   "merge repo-name our-commit-id their-commit-id",
+		Short: "Merge the changes in one commit into another.",
+		Long: `Make a commit, a child of our-commit-id, with the changes their-commit-id made
+since the two diverged. A file changed on both sides is merged by --strategy:
+error fails the merge, ours keeps our file, theirs replaces it with theirs and
+union appends what theirs appended to it to ours.`,
+		Run: pkgcobra.RunFixedArgs(3, func(args []string) error {
+			mergeStrategy, ok := pfs.MergeStrategy_value["MERGE_STRATEGY_"+strings.ToUpper(strategy)]
+			if !ok {
+				return fmt.Errorf("unknown merge strategy %s, it must be error, ours, theirs or union", strategy)
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			commit, err := pfsutil.MergeCommits(apiClient, args[0], args[1], args[2], pfs.MergeStrategy(mergeStrategy))
+			if err != nil {
+				return err
+			}
+			fmt.Println(commit.Id)
+			return nil
 		}),
 	}
+	merge.Flags().StringVarP(&strategy, "strategy", "s", "error", "how to merge files changed on both sides: error, ours, theirs or union")
 
 	inspectCommit := &cobra.Command{
 		Use:   "inspect-commit repo-name commit-id",
@@ -191,10 +352,48 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	tag := &cobra.Command{
+		Use:   "tag repo-name commit-id tag",
+		Short: "Tag a commit.",
+		Long: `Give a finished commit a tag, the tag can be used anywhere the commit's id can.
+Tags can't be moved to another commit once they've been given.`,
+		Run: pkgcobra.RunFixedArgs(3, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			return pfsutil.TagCommit(apiClient, args[0], args[1], args[2])
+		}),
+	}
+
+	listTag := &cobra.Command{
+		Use:   "list-tag repo-name",
+		Short: "Return all tags in a repo.",
+		Long:  "Return all tags in a repo and the commits they refer to.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			tagInfos, err := pfsutil.ListTag(apiClient, args[0])
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintTagInfoHeader(writer)
+			for _, tagInfo := range tagInfos {
+				pretty.PrintTagInfo(writer, tagInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+
 	deleteCommit := &cobra.Command{
 		Use:   "delete-commit repo-name commit-id",
 		Short: "Delete a commit.",
-		Long:  "Delete a commit.",
+		Long: `Delete a commit, only finished commits without children can be deleted.
+Deleted commits are moved to the trash, they can be brought back with restore
+until the trash is emptied.`,
 		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
@@ -204,6 +403,62 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	restore := &cobra.Command{
+		Use:   "restore repo-name [commit-id]",
+		Short: "Restore a repo or commit from the trash.",
+		Long: `Restore a repo, or a commit if commit-id is given, from the trash.
+A commit can only be restored if its parent isn't in the trash.`,
+		Run: pkgcobra.RunBoundedArgs(pkgcobra.Bounds{Min: 1, Max: 2}, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				return pfsutil.RestoreCommit(apiClient, args[0], args[1])
+			}
+			return pfsutil.RestoreRepo(apiClient, args[0])
+		}),
+	}
+
+	listTrash := &cobra.Command{
+		Use:   "list-trash",
+		Short: "Return the repos and commits in the trash.",
+		Long:  "Return the repos and commits in the trash and when they'll be permanently deleted.",
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			trashInfos, err := pfsutil.ListTrash(apiClient)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintTrashInfoHeader(writer)
+			for _, trashInfo := range trashInfos {
+				pretty.PrintTrashInfo(writer, trashInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+
+	var emptyAll bool
+	emptyTrash := &cobra.Command{
+		Use:   "empty-trash",
+		Short: "Permanently delete what's in the trash.",
+		Long: `Permanently delete the repos and commits which have been in the trash longer
+than the trash window, they can't be restored afterwards. This happens
+periodically on its own, --all deletes everything in the trash.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			return pfsutil.EmptyTrash(apiClient, emptyAll)
+		}),
+	}
+	emptyTrash.Flags().BoolVarP(&emptyAll, "all", "a", false, "delete everything in the trash, not just what's expired")
+
 	mkdir := &cobra.Command{
 		Use:   "mkdir repo-name commit-id path/to/dir",
 		Short: "Make a directory.",
@@ -217,19 +472,68 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	var putFilePath string
+	var putRecursive bool
+	var putParts int
 	putFile := &cobra.Command{
 		Use:   "put-file repo-name commit-id path/to/file",
 		Short: "Put a file from stdin",
-		Long:  "Put a file from stdin. Directories must exist. commit-id must be a writeable commit.",
+		Long: `Put a file from stdin, or from a local file with -f. Directories must exist.
+commit-id must be a writeable commit.
+
+With -r the local directory -f is put under path/to/file, creating directories
+as needed. Files which are already in the commit with the same content, such as
+unchanged files inherited from its parent, are skipped without being sent.
+Files are appended to, so a file which changed has its new content appended
+as without -r.`,
 		Run: pkgcobra.RunFixedArgs(3, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
 				return err
 			}
-			_, err = pfsutil.PutFile(apiClient, args[0], args[1], args[2], 0, os.Stdin)
+			if putRecursive {
+				if putFilePath == "-" {
+					return fmt.Errorf("-r needs a local directory, pass it with -f")
+				}
+				// the files go to the masters of one version even if the
+				// shards move part way through
+				pin, err := pfsutil.PinVersion(apiClient, 0)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if err := pfsutil.UnpinVersion(apiClient, pin); err != nil {
+						protolog.Printf("Error unpinning version %d: %s", pin.Version, err.Error())
+					}
+				}()
+				pinnedAPIClient, err := getAPIClient(address, grpc.WithPerRPCCredentials(pfs.NewVersionPinCredentials(pin)))
+				if err != nil {
+					return err
+				}
+				return putDir(pinnedAPIClient, args[0], args[1], args[2], putFilePath)
+			}
+			if putParts > 1 {
+				if putFilePath == "-" {
+					return fmt.Errorf("--parts needs a local file, pass it with -f")
+				}
+				return putFileParts(apiClient, args[0], args[1], args[2], putFilePath, putParts)
+			}
+			reader := os.Stdin
+			if putFilePath != "-" {
+				if reader, err = os.Open(putFilePath); err != nil {
+					return err
+				}
+				defer func() {
+					_ = reader.Close()
+				}()
+			}
+			_, err = pfsutil.PutFile(apiClient, args[0], args[1], args[2], 0, reader)
 			return err
 		}),
 	}
+	putFile.Flags().StringVarP(&putFilePath, "file", "f", "-", "The local file to put, - reads from stdin.")
+	putFile.Flags().BoolVarP(&putRecursive, "recursive", "r", false, "Put the local directory -f, skipping unchanged files.")
+	putFile.Flags().IntVarP(&putParts, "parts", "p", 1, "Upload the local file -f in this many parts at once, the file mustn't exist yet.")
 
 	getFile := &cobra.Command{
 		Use:   "get-file repo-name commit-id path/to/file",
@@ -245,6 +549,43 @@ func Cmds(address string) ([]*cobra.Command, error) {
 	}
 	addShardFlags(getFile)
 
+	var getDirTar bool
+	var getDirGzip bool
+	var getDirOutput string
+	getDir := &cobra.Command{
+		Use:   "get-dir repo-name commit-id [path/to/dir]",
+		Short: "Download a directory.",
+		Long: `Download a directory, or the whole commit if no path is given.
+The directory is sent as a single tar, in which files made of the same blocks as
+a file already sent are hard links to it. The tar is extracted into --output
+unless --tar is passed, in which case it's written to stdout.`,
+		Run: pkgcobra.RunBoundedArgs(pkgcobra.Bounds{Min: 2, Max: 3}, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			dirPath := ""
+			if len(args) == 3 {
+				dirPath = args[2]
+			}
+			if getDirTar {
+				return pfsutil.GetTar(apiClient, args[0], args[1], dirPath, getDirGzip, os.Stdout)
+			}
+			reader, writer := io.Pipe()
+			go func() {
+				writer.CloseWithError(pfsutil.GetTar(apiClient, args[0], args[1], dirPath, false, writer))
+			}()
+			if err := untar(reader, getDirOutput); err != nil {
+				reader.CloseWithError(err)
+				return err
+			}
+			return nil
+		}),
+	}
+	getDir.Flags().BoolVar(&getDirTar, "tar", false, "write the tar to stdout rather than extracting it")
+	getDir.Flags().BoolVarP(&getDirGzip, "gzip", "z", false, "gzip the tar written by --tar")
+	getDir.Flags().StringVarP(&getDirOutput, "output", "o", ".", "the local directory to extract the files into")
+
 	inspectFile := &cobra.Command{
 		Use:   "inspect-file repo-name commit-id path/to/file",
 		Short: "Return info about a file.",
@@ -269,6 +610,103 @@ func Cmds(address string) ([]*cobra.Command, error) {
 	}
 	addShardFlags(inspectFile)
 
+	var sampleRecords uint64
+	var sampleRandom bool
+	var sampleCSV bool
+	sampleFile := &cobra.Command{
+		Use:   "sample-file repo-name commit-id path/to/file",
+		Short: "Return a sample of the records in a file.",
+		Long:  "Return a sample of the records in a file, by default the first records, records are lines unless --csv is passed.",
+		Run: pkgcobra.RunFixedArgs(3, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			format := pfs.RecordFormat_RECORD_FORMAT_LINES
+			if sampleCSV {
+				format = pfs.RecordFormat_RECORD_FORMAT_CSV
+			}
+			fileSample, err := pfsutil.SampleFile(apiClient, args[0], args[1], args[2], sampleRecords, sampleRandom, format, shard())
+			if err != nil {
+				return err
+			}
+			if fileSample.Header != nil {
+				fmt.Printf("%s\n", fileSample.Header)
+			}
+			for _, record := range fileSample.Record {
+				fmt.Printf("%s\n", record)
+			}
+			return nil
+		}),
+	}
+	sampleFile.Flags().Uint64VarP(&sampleRecords, "records", "n", 10, "the number of records to return")
+	sampleFile.Flags().BoolVar(&sampleRandom, "random", false, "sample records at random from the whole file rather than taking the first ones")
+	sampleFile.Flags().BoolVar(&sampleCSV, "csv", false, "parse the file as CSV, the first row is returned as the header")
+	addShardFlags(sampleFile)
+
+	var statsRecords uint64
+	var statsCSV bool
+	statsFile := &cobra.Command{
+		Use:   "stats-file repo-name commit-id path/to/file",
+		Short: "Return the inferred schema and value distributions of a file.",
+		Long: `Return the inferred schema and value distributions of a file.
+The type, null rate, distinct values, range and most common values of each field
+are computed from a random sample of the file's records. Records are JSON lines,
+whose fields are their top level keys, unless --csv is passed, in which case the
+fields are the columns named by the first row.`,
+		Run: pkgcobra.RunFixedArgs(3, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			format := pfs.RecordFormat_RECORD_FORMAT_LINES
+			if statsCSV {
+				format = pfs.RecordFormat_RECORD_FORMAT_CSV
+			}
+			fileStats, err := pfsutil.StatsFile(apiClient, args[0], args[1], args[2], statsRecords, format)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintFileStatsHeader(writer)
+			pretty.PrintFileStats(writer, fileStats)
+			return writer.Flush()
+		}),
+	}
+	statsFile.Flags().Uint64VarP(&statsRecords, "records", "n", 1000, "the number of records to sample")
+	statsFile.Flags().BoolVar(&statsCSV, "csv", false, "parse the file as CSV, the first row names the fields")
+
+	var statsRepoRecords uint64
+	var statsRepoCSV bool
+	statsRepo := &cobra.Command{
+		Use:   "stats-repo repo-name commit-id",
+		Short: "Return the inferred schema and value distributions of every file in a commit.",
+		Long:  "Return the inferred schema and value distributions of every file in a commit, see stats-file.",
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			format := pfs.RecordFormat_RECORD_FORMAT_LINES
+			if statsRepoCSV {
+				format = pfs.RecordFormat_RECORD_FORMAT_CSV
+			}
+			fileStats, err := pfsutil.StatsRepo(apiClient, args[0], args[1], statsRepoRecords, format)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintFileStatsHeader(writer)
+			for _, stats := range fileStats {
+				pretty.PrintFileStats(writer, stats)
+			}
+			return writer.Flush()
+		}),
+	}
+	statsRepo.Flags().Uint64VarP(&statsRepoRecords, "records", "n", 1000, "the number of records to sample from each file")
+	statsRepo.Flags().BoolVar(&statsRepoCSV, "csv", false, "parse the files as CSV, the first row names the fields")
+
+	var partial bool
 	listFile := &cobra.Command{
 		Use:   "list-file repo-name commit-id path/to/dir",
 		Short: "Return the files in a directory.",
@@ -282,7 +720,16 @@ func Cmds(address string) ([]*cobra.Command, error) {
 			if len(args) == 3 {
 				path = args[2]
 			}
-			fileInfos, err := pfsutil.ListFile(apiClient, args[0], args[1], path, shard())
+			var fileInfos []*pfs.FileInfo
+			if partial {
+				var failedServers []string
+				fileInfos, failedServers, err = pfsutil.PartialListFile(apiClient, args[0], args[1], path, shard())
+				if err == nil && len(failedServers) > 0 {
+					fmt.Fprintf(os.Stderr, "partial listing, missing the files on %s\n", strings.Join(failedServers, ", "))
+				}
+			} else {
+				fileInfos, err = pfsutil.ListFile(apiClient, args[0], args[1], path, shard())
+			}
 			if err != nil {
 				return err
 			}
@@ -295,6 +742,7 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 	addShardFlags(listFile)
+	listFile.Flags().BoolVar(&partial, "partial", false, "list the files on the servers which answer as long as they master a majority of the shards")
 
 	deleteFile := &cobra.Command{
 		Use:   "delete-file repo-name commit-id path/to/file",
@@ -309,73 +757,669 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	var recursive bool
+	cp := &cobra.Command{
+		Use:   "cp src-repo@commit-id:path/to/file dst-repo@commit-id:path/to/file",
+		Short: "Copy files between repos or commits.",
+		Long: `Copy files between repos or commits.
+The copy shares the original's blocks so no data passes through the client.
+The source commit must be finished and the destination commit must be started.
+The source path may contain globs, each match is copied into the destination directory.`,
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			src, err := parseFile(args[0])
+			if err != nil {
+				return err
+			}
+			dst, err := parseFile(args[1])
+			if err != nil {
+				return err
+			}
+			if !hasGlob(src.Path) {
+				return pfsutil.CopyFile(apiClient, src.Commit.Repo.Name, src.Commit.Id, src.Path,
+					dst.Commit.Repo.Name, dst.Commit.Id, dst.Path, recursive)
+			}
+			matches, err := globFiles(apiClient, src)
+			if err != nil {
+				return err
+			}
+			if len(matches) == 0 {
+				return fmt.Errorf("no files match %s", args[0])
+			}
+			for _, match := range matches {
+				if err := pfsutil.CopyFile(apiClient, src.Commit.Repo.Name, src.Commit.Id, match.File.Path,
+					dst.Commit.Repo.Name, dst.Commit.Id, path.Join(dst.Path, path.Base(match.File.Path)),
+					recursive); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	}
+	cp.Flags().BoolVarP(&recursive, "recursive", "r", false, "copy directories recursively")
+
+	concat := &cobra.Command{
+		Use:   "concat dst-repo@commit-id:path/to/file src-repo@commit-id:path/to/file...",
+		Short: "Create a file from the concatenation of files.",
+		Long: `Create a file from the concatenation of files, in the order they're given.
+The new file shares the originals' blocks so no data passes through the client.
+The source commits must be finished, the destination commit must be started and the destination file mustn't exist.`,
+		Run: pkgcobra.Run(func(args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("expected a destination and at least one source, got %d args", len(args))
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			dst, err := parseFile(args[0])
+			if err != nil {
+				return err
+			}
+			var srcs []*pfs.File
+			for _, arg := range args[1:] {
+				src, err := parseFile(arg)
+				if err != nil {
+					return err
+				}
+				srcs = append(srcs, src)
+			}
+			return pfsutil.ConcatFiles(apiClient, srcs, dst)
+		}),
+	}
+
+	var syncDelete bool
+	var syncExcludes []string
+	sync := &cobra.Command{
+		Use:   "sync src dst",
+		Short: "Sync a local directory with a directory in pfs, in either direction.",
+		Long: `Sync a local directory with a directory in pfs, in either direction. One of
+src and dst is a local directory and the other is repo@commit-id:path/to/dir.
+
+Syncing to pfs puts the files which aren't in the commit into a new commit on
+top of it and prints the new commit's id. Files which grew only have what was
+appended sent. pfs files can only be appended to, so if a file changed in any
+other way nothing is synced.
+
+Syncing from pfs gets the files which are missing locally or differ, with
+--delete local files which aren't in the commit are deleted.
+
+Files are compared by hashing them the way pfs stores them, so unchanged files
+aren't sent either way. --exclude patterns are matched against each file's
+path relative to the directory and against its name.`,
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			srcFile, srcErr := parseFile(args[0])
+			dstFile, dstErr := parseFile(args[1])
+			switch {
+			case srcErr != nil && dstErr == nil:
+				if syncDelete {
+					return fmt.Errorf("--delete only applies to syncing from pfs, pfs files can't be deleted")
+				}
+				commit, err := syncUp(apiClient, args[0], dstFile, syncExcludes)
+				if err != nil {
+					return err
+				}
+				if commit == nil {
+					fmt.Println("Nothing to sync.")
+					return nil
+				}
+				fmt.Println(commit.Id)
+				return nil
+			case srcErr == nil && dstErr != nil:
+				if err := os.MkdirAll(args[1], 0755); err != nil {
+					return err
+				}
+				return syncDown(apiClient, srcFile, args[1], syncExcludes, syncDelete)
+			default:
+				return fmt.Errorf("one of src and dst must be a local directory and the other repo@commit-id:path/to/dir")
+			}
+		}),
+	}
+	sync.Flags().BoolVar(&syncDelete, "delete", false, "Delete local files which aren't in pfs.")
+	sync.Flags().StringSliceVar(&syncExcludes, "exclude", nil, "Don't sync files matching this pattern, ie *.tmp.")
+
+	inspectShard := &cobra.Command{
+		Use:   "inspect-shard [shard...]",
+		Short: "Return the replication state of shards.",
+		Long: `Return the replication state of shards, all shards are returned if none are given.
+Each replica is compared to its shard's master, a replica is caught up once
+it's 0 commits behind for every repo.`,
+		Run: pkgcobra.Run(func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			var shards []uint64
+			for _, arg := range args {
+				shard, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					return err
+				}
+				shards = append(shards, shard)
+			}
+			shardInfos, err := pfsutil.InspectShard(apiClient, shards...)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintShardInfoHeader(writer)
+			for _, shardInfo := range shardInfos {
+				pretty.PrintShardInfo(writer, shardInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+
+	listSlowOp := &cobra.Command{
+		Use:   "list-slow-ops",
+		Short: "Return the recent slow rpcs of every server.",
+		Long: `Return the recent slow rpcs of every server, slowest first.
+Each server keeps its last 100 rpcs which took longer than the slow_op_threshold
+config key, 1s by default.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			slowOps, err := pfsutil.ListSlowOp(apiClient)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintSlowOpHeader(writer)
+			for _, slowOp := range slowOps {
+				pretty.PrintSlowOp(writer, slowOp)
+			}
+			return writer.Flush()
+		}),
+	}
+
+	var driveAddress string
+	listOp := &cobra.Command{
+		Use:   "list-ops",
+		Short: "Return the rpcs every server is serving.",
+		Long: `Return the rpcs every server is serving, oldest first.
+With --drive-address the rpcs a drive server is serving are returned instead.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			var ops []*pfs.Op
+			if driveAddress != "" {
+				driveAPIClient, err := getDriveAPIClient(driveAddress)
+				if err != nil {
+					return err
+				}
+				if ops, err = pfsutil.ListDriveOp(driveAPIClient); err != nil {
+					return err
+				}
+				for _, op := range ops {
+					op.Address = driveAddress
+				}
+			} else {
+				apiClient, err := getAPIClient(address)
+				if err != nil {
+					return err
+				}
+				if ops, err = pfsutil.ListOp(apiClient); err != nil {
+					return err
+				}
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintOpHeader(writer)
+			for _, op := range ops {
+				pretty.PrintOp(writer, op)
+			}
+			return writer.Flush()
+		}),
+	}
+	listOp.Flags().StringVar(&driveAddress, "drive-address", "", "The drive server to list the rpcs of.")
+
+	var driveServer bool
+	cancelOp := &cobra.Command{
+		Use:   "cancel-op server-address id",
+		Short: "Cancel an rpc a server is serving.",
+		Long: `Cancel an rpc a server is serving, the server and id are shown by list-ops.
+With --drive the server is a drive server. The rpc's context is cancelled, an
+rpc which is blocked on its client rather than on pfs keeps running.`,
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			id, err := strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			if driveServer {
+				driveAPIClient, err := getDriveAPIClient(args[0])
+				if err != nil {
+					return err
+				}
+				return pfsutil.CancelDriveOp(driveAPIClient, id)
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			return pfsutil.CancelOp(apiClient, args[0], id)
+		}),
+	}
+	cancelOp.Flags().BoolVar(&driveServer, "drive", false, "The server is a drive server.")
+
 	var mountPoint string
+	var sampleEvents uint64
+	mountOptions := fuse.DefaultMountOptions
 	mount := &cobra.Command{
 		Use:   "mount [repo/commit:alias...]",
 		Short: "Mount pfs locally.",
-		Long:  "Mount pfs locally.",
+		Long: `Mount pfs locally.
+Each op the filesystem serves is logged at debug level, with --sample-events=N
+only one in N is and the ops are summed up in metrics logged each minute.
+--allow-other needs user_allow_other set in /etc/fuse.conf unless you're root.`,
 		Run: pkgcobra.Run(func(args []string) error {
 			protolog.SetLevel(protolog.Level_LEVEL_DEBUG)
 			apiClient, err := getAPIClient(address)
 			if err != nil {
 				return err
 			}
-			mounter := fuse.NewMounter(address, apiClient)
-			return mounter.Mount(mountPoint, parseCommitMounts(args), nil)
+			mounter := fuse.NewMounterWithOptions(address, apiClient, fuse.MounterOptions{
+				SampleEvents: sampleEvents,
+				MountOptions: &mountOptions,
+			})
+			return mounter.Mount(mountPoint, fuse.ParseCommitMounts(args), nil)
 		}),
 	}
 	mount.Flags().StringVarP(&mountPoint, "mount-point", "p", "/pfs", "root of mounted filesystem")
+	mount.Flags().Uint64Var(&sampleEvents, "sample-events", 0, "Log one in this many ops, 0 logs every op.")
+	mount.Flags().BoolVar(&mountOptions.AllowOther, "allow-other", mountOptions.AllowOther, "Let users other than you access the mount.")
+	mount.Flags().Uint32Var(&mountOptions.MaxReadahead, "max-readahead", mountOptions.MaxReadahead, "The most bytes the kernel reads ahead, 0 means the kernel's default.")
+	mount.Flags().BoolVar(&mountOptions.AsyncRead, "async-read", mountOptions.AsyncRead, "Let the kernel send several reads of a file at once.")
+	mount.Flags().StringVar(&mountOptions.VolumeName, "volume-name", "", "The name the mount is shown with on OS X, defaults to pfs://address.")
+
+	var all bool
+	unmount := &cobra.Command{
+		Use:   "unmount [mount-point]",
+		Short: "Unmount pfs.",
+		Long:  "Unmount pfs, falling back to a lazy unmount for busy or stale mounts.",
+		Run: pkgcobra.Run(func(args []string) error {
+			var mountPoints []string
+			if all {
+				mountInfos, err := fuse.ListMounts()
+				if err != nil {
+					return err
+				}
+				for _, mountInfo := range mountInfos {
+					mountPoints = append(mountPoints, mountInfo.MountPoint)
+				}
+			} else {
+				if len(args) != 1 {
+					return fmt.Errorf("expected a mount-point or --all")
+				}
+				mountPoints = args
+			}
+			var retErr error
+			for _, mountPoint := range mountPoints {
+				if err := fuse.Unmount(mountPoint); err != nil {
+					fmt.Fprintf(os.Stderr, "error unmounting %s: %s\n", mountPoint, err.Error())
+					retErr = err
+				}
+			}
+			return retErr
+		}),
+	}
+	unmount.Flags().BoolVarP(&all, "all", "a", false, "unmount every pfs mount on this machine")
+
+	listMount := &cobra.Command{
+		Use:   "list-mount",
+		Short: "Return all pfs mounts on this machine.",
+		Long:  "Return all pfs mounts on this machine.",
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			mountInfos, err := fuse.ListMounts()
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintMountInfoHeader(writer)
+			for _, mountInfo := range mountInfos {
+				pretty.PrintMountInfo(writer, mountInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+
+	var nfsAddress string
+	var nfsWritable bool
+	var nfsAllowedClients []string
+	exportNFS := &cobra.Command{
+		Use:   "export-nfs [repo/commit:alias...]",
+		Short: "Export pfs over NFSv3.",
+		Long: `Export pfs over NFSv3, for machines which can't mount it with fuse.
+MOUNT is served on the same port as NFS and there's no lock manager, mount it with:
+	mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock host:/ /pfs
+
+NFS clients aren't authenticated, by default the export is read-only and only
+served on loopback. Serving it on another address needs --allowed-clients, the
+networks other than loopback which may connect, and writing needs --writable.`,
+		Run: pkgcobra.Run(func(args []string) error {
+			options := nfs.ServerOptions{Writable: nfsWritable}
+			for _, allowedClients := range nfsAllowedClients {
+				_, ipNet, err := net.ParseCIDR(allowedClients)
+				if err != nil {
+					return err
+				}
+				options.AllowedClients = append(options.AllowedClients, ipNet)
+			}
+			host, _, err := net.SplitHostPort(nfsAddress)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); (ip == nil || !ip.IsLoopback()) && host != "localhost" && len(options.AllowedClients) == 0 {
+				return fmt.Errorf("%s isn't a loopback address, --allowed-clients must say who can connect", nfsAddress)
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			listener, err := net.Listen("tcp", nfsAddress)
+			if err != nil {
+				return err
+			}
+			return nfs.NewServerWithOptions(fuse.NewFilesystem(apiClient, fuse.ParseCommitMounts(args)), options).Serve(listener)
+		}),
+	}
+	exportNFS.Flags().StringVar(&nfsAddress, "nfs-address", "127.0.0.1:2049", "The address to serve NFS on.")
+	exportNFS.Flags().BoolVar(&nfsWritable, "writable", false, "Let clients create and write files.")
+	exportNFS.Flags().StringSliceVar(&nfsAllowedClients, "allowed-clients", nil, "Networks besides loopback which may connect, ie 10.0.0.0/8.")
 
 	var result []*cobra.Command
 	result = append(result, createRepo)
 	result = append(result, inspectRepo)
 	result = append(result, listRepo)
+	result = append(result, dedupStats)
 	result = append(result, deleteRepo)
 	result = append(result, startCommit)
 	result = append(result, finishCommit)
+	result = append(result, startTransaction)
+	result = append(result, finishTransaction)
+	result = append(result, merge)
 	result = append(result, inspectCommit)
 	result = append(result, listCommit)
+	result = append(result, tag)
+	result = append(result, listTag)
 	result = append(result, deleteCommit)
+	result = append(result, restore)
+	result = append(result, listTrash)
+	result = append(result, emptyTrash)
 	result = append(result, mkdir)
 	result = append(result, putFile)
 	result = append(result, getFile)
+	result = append(result, getDir)
 	result = append(result, inspectFile)
+	result = append(result, sampleFile)
+	result = append(result, statsFile)
+	result = append(result, statsRepo)
 	result = append(result, listFile)
 	result = append(result, deleteFile)
+	result = append(result, cp)
+	result = append(result, concat)
+	result = append(result, sync)
+	result = append(result, inspectShard)
+	result = append(result, listSlowOp)
+	result = append(result, listOp)
+	result = append(result, cancelOp)
 	result = append(result, mount)
+	result = append(result, unmount)
+	result = append(result, listMount)
+	result = append(result, exportNFS)
 	return result, nil
 }
 
-func getAPIClient(address string) (pfs.APIClient, error) {
-	clientConn, err := grpc.Dial(address, grpc.WithInsecure())
+func getAPIClient(address string, dialOptions ...grpc.DialOption) (pfs.APIClient, error) {
+	clientConn, err := audit.Dial(address, dialOptions...)
 	if err != nil {
 		return nil, err
 	}
 	return pfs.NewAPIClient(clientConn), nil
 }
 
+// putFileParts puts the local file localPath in parts uploaded concurrently,
+// the file is only created once every part has been.
+func putFileParts(apiClient pfs.APIClient, repoName string, commitID string, filePath string, localPath string, parts int) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	multipartPut, err := pfsutil.StartMultipartPut(apiClient, repoName, commitID, filePath)
+	if err != nil {
+		return err
+	}
+	partSize := (stat.Size() + int64(parts) - 1) / int64(parts)
+	var wg sync.WaitGroup
+	errs := make(chan error, parts)
+	for i := 0; i < parts; i++ {
+		offset := int64(i) * partSize
+		if offset >= stat.Size() && i > 0 {
+			break
+		}
+		wg.Add(1)
+		go func(number uint64, offset int64) {
+			defer wg.Done()
+			if err := pfsutil.PutPart(apiClient, multipartPut, number, io.NewSectionReader(file, offset, partSize)); err != nil {
+				errs <- err
+			}
+		}(uint64(i), offset)
+	}
+	wg.Wait()
+	close(errs)
+	if err, ok := <-errs; ok {
+		return err
+	}
+	return pfsutil.CompleteMultipartPut(apiClient, multipartPut)
+}
+
+// putDir puts the files under the local directory dir in dirPath, skipping
+// those which are unchanged and appending only what's new to those which have
+// grown. pfs files can only be appended to, so nothing is put if a file has
+// changed in any other way.
+func putDir(apiClient pfs.APIClient, repoName string, commitID string, dirPath string, dir string) error {
+	type change struct {
+		localPath string
+		filePath  string
+		offset    int64
+	}
+	var dirs []string
+	var changes []*change
+	var conflicts []string
+	var skipped int
+	if err := filepath.Walk(dir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, localPath)
+		if err != nil {
+			return err
+		}
+		filePath := path.Join(dirPath, filepath.ToSlash(relPath))
+		if info.IsDir() {
+			if filePath == "" || filePath == "." {
+				return nil
+			}
+			dirs = append(dirs, filePath)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(localPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		size, prefix, err := pfsutil.FilePrefix(apiClient, repoName, commitID, filePath, file)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !prefix || size > info.Size():
+			conflicts = append(conflicts, relPath)
+		case size < info.Size():
+			changes = append(changes, &change{localPath, filePath, size})
+		default:
+			skipped++
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("these files changed other than by being appended to, pfs files can only be appended to: %s", strings.Join(conflicts, ", "))
+	}
+	for _, dir := range dirs {
+		if err := pfsutil.MakeDirectory(apiClient, repoName, commitID, dir); err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		if err := putFileFrom(apiClient, repoName, commitID, change.filePath, change.localPath, change.offset); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Put %d files, skipped %d unchanged.\n", len(changes), skipped)
+	return nil
+}
+
+// untar extracts the tar read from reader into dir.
+func untar(reader io.Reader, dir string) error {
+	tarReader := tar.NewReader(reader)
+	localPath := func(name string) (string, error) {
+		result := filepath.Join(dir, filepath.FromSlash(name))
+		relative, err := filepath.Rel(filepath.Clean(dir), result)
+		if err != nil {
+			return "", err
+		}
+		if relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("pachyderm: %s is outside of %s", name, dir)
+		}
+		return result, nil
+	}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		filePath, err := localPath(header.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeLink:
+			linkPath, err := localPath(header.Linkname)
+			if err != nil {
+				return err
+			}
+			// a file left by an earlier download would make the link fail
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Link(linkPath, filePath); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := func() (retErr error) {
+				file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if err := file.Close(); err != nil && retErr == nil {
+						retErr = err
+					}
+				}()
+				_, err = io.Copy(file, tarReader)
+				return err
+			}(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func getDriveAPIClient(address string) (drive.APIClient, error) {
-	clientConn, err := grpc.Dial(address, grpc.WithInsecure())
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
 	if err != nil {
 		return nil, err
 	}
 	return drive.NewAPIClient(clientConn), nil
 }
 
-func parseCommitMounts(args []string) []*fuse.CommitMount {
-	var result []*fuse.CommitMount
-	for _, arg := range args {
-		commitMount := &fuse.CommitMount{Commit: pfsutil.NewCommit("", "")}
-		repo, commitAlias := path.Split(arg)
-		commitMount.Commit.Repo.Name = path.Clean(repo)
-		split := strings.Split(commitAlias, ":")
-		if len(split) > 0 {
-			commitMount.Commit.Id = split[0]
+// parseFile parses a file of the form repo@commit-id:path/to/file.
+func parseFile(arg string) (*pfs.File, error) {
+	repoCommit := strings.SplitN(arg, ":", 2)
+	split := strings.SplitN(repoCommit[0], "@", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return nil, fmt.Errorf("invalid file %s, expected repo@commit-id:path/to/file", arg)
+	}
+	var filePath string
+	if len(repoCommit) == 2 {
+		// leading slashes are forbidden by pfs
+		filePath = strings.TrimLeft(repoCommit[1], "/")
+	}
+	return pfsutil.NewFile(split[0], split[1], filePath), nil
+}
+
+func hasGlob(filePath string) bool {
+	return strings.ContainsAny(filePath, "*?[")
+}
+
+// globFiles returns the files in file's commit that match file's path.
+func globFiles(apiClient pfs.APIClient, file *pfs.File) ([]*pfs.FileInfo, error) {
+	dir, pattern := path.Split(file.Path)
+	dir = strings.TrimSuffix(dir, "/")
+	var dirs []string
+	if hasGlob(dir) {
+		dirInfos, err := globFiles(apiClient, pfsutil.NewFile(file.Commit.Repo.Name, file.Commit.Id, dir))
+		if err != nil {
+			return nil, err
+		}
+		for _, dirInfo := range dirInfos {
+			if dirInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				dirs = append(dirs, dirInfo.File.Path)
+			}
 		}
-		if len(split) > 1 {
-			commitMount.Alias = split[1]
+	} else {
+		dirs = append(dirs, dir)
+	}
+	var result []*pfs.FileInfo
+	for _, dir := range dirs {
+		fileInfos, err := pfsutil.ListFile(apiClient, file.Commit.Repo.Name, file.Commit.Id, dir, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, fileInfo := range fileInfos {
+			matched, err := path.Match(pattern, path.Base(fileInfo.File.Path))
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				result = append(result, fileInfo)
+			}
 		}
-		result = append(result, commitMount)
 	}
-	return result
+	return result, nil
 }
@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestUntar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pfs-untar")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(wd) }()
+
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "sub/a", Mode: 0644, Size: 3, Typeflag: tar.TypeReg}))
+	_, err = tarWriter.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "b", Linkname: "sub/a", Typeflag: tar.TypeLink}))
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, untar(bytes.NewReader(buffer.Bytes()), "."))
+	for _, name := range []string{"sub/a", "b"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, "foo", string(data))
+	}
+
+	for _, name := range []string{"../a", "sub/../../a", "."} {
+		buffer.Reset()
+		tarWriter = tar.NewWriter(&buffer)
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg}))
+		require.NoError(t, tarWriter.Close())
+		require.True(t, untar(bytes.NewReader(buffer.Bytes()), ".") != nil, name)
+	}
+}
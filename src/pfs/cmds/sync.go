@@ -0,0 +1,288 @@
+package cmds
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"go.pedge.io/proto/time"
+)
+
+// syncUp puts the files under localDir which aren't in file's commit into a
+// new commit on top of it, under file's path, and returns the new commit.
+// Files which grew are only sent what was appended, files which changed
+// otherwise can't be synced since pfs files can only be appended to.
+func syncUp(apiClient pfs.APIClient, localDir string, file *pfs.File, excludes []string) (*pfs.Commit, error) {
+	repoName := file.Commit.Repo.Name
+	type change struct {
+		localPath string
+		filePath  string
+		offset    int64
+	}
+	var dirs []string
+	var changes []*change
+	var conflicts []string
+	if err := filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." {
+			return nil
+		}
+		if excluded(relPath, excludes) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		filePath := path.Join(file.Path, relPath)
+		if info.IsDir() {
+			dirs = append(dirs, filePath)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		localFile, err := os.Open(localPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = localFile.Close()
+		}()
+		size, prefix, err := pfsutil.FilePrefix(apiClient, repoName, file.Commit.Id, filePath, localFile)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !prefix || size > info.Size():
+			conflicts = append(conflicts, relPath)
+		case size < info.Size():
+			changes = append(changes, &change{localPath, filePath, size})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("these files changed other than by being appended to, pfs files can only be appended to: %s", strings.Join(conflicts, ", "))
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	commit, err := pfsutil.StartCommit(apiClient, repoName, file.Commit.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := func() error {
+		for _, dir := range dirs {
+			if err := pfsutil.MakeDirectory(apiClient, repoName, commit.Id, dir); err != nil {
+				return err
+			}
+		}
+		for _, change := range changes {
+			if err := putFileFrom(apiClient, repoName, commit.Id, change.filePath, change.localPath, change.offset); err != nil {
+				return err
+			}
+			if change.offset == 0 {
+				fmt.Printf("put %s\n", change.filePath)
+			} else {
+				fmt.Printf("appended to %s\n", change.filePath)
+			}
+		}
+		if _, err := pfsutil.FinishCommit(apiClient, repoName, commit.Id); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		// the commit has part of the sync, it's trashed rather than left
+		// for someone to mistake for a complete one
+		_ = pfsutil.ForceFinishCommit(apiClient, repoName, commit.Id)
+		_ = pfsutil.DeleteCommit(apiClient, repoName, commit.Id)
+		return nil, err
+	}
+	return commit, nil
+}
+
+// syncDown gets the files under file's path which localDir doesn't have, or
+// has with different content. With remove the local files which aren't in
+// file's commit are deleted.
+func syncDown(apiClient pfs.APIClient, file *pfs.File, localDir string, excludes []string, remove bool) error {
+	remotePaths := make(map[string]bool)
+	if err := walkFiles(apiClient, file, func(fileInfo *pfs.FileInfo) error {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(fileInfo.File.Path, file.Path), "/")
+		if excluded(relPath, excludes) {
+			return filepath.SkipDir
+		}
+		remotePaths[relPath] = true
+		localPath := filepath.Join(localDir, filepath.FromSlash(relPath))
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			return os.MkdirAll(localPath, 0755)
+		}
+		unchanged, err := localFileUnchanged(apiClient, fileInfo, localPath)
+		if err != nil || unchanged {
+			return err
+		}
+		if err := getFileTo(apiClient, fileInfo, localPath); err != nil {
+			return err
+		}
+		fmt.Printf("got %s\n", fileInfo.File.Path)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !remove {
+		return nil
+	}
+	return filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == "." || remotePaths[relPath] {
+			return nil
+		}
+		if excluded(relPath, excludes) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := os.RemoveAll(localPath); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s\n", localPath)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// walkFiles calls f with every file under file's path, depth first. f may
+// return filepath.SkipDir to skip a file or a directory's contents.
+func walkFiles(apiClient pfs.APIClient, file *pfs.File, f func(*pfs.FileInfo) error) error {
+	fileInfos, err := pfsutil.ListFile(apiClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, nil)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		if err := f(fileInfo); err != nil {
+			if err == filepath.SkipDir {
+				continue
+			}
+			return err
+		}
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			if err := walkFiles(apiClient, fileInfo.File, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// localFileUnchanged returns true if localPath has the content of the file
+// fileInfo is about. A local file with the file's size and modification time
+// is taken to be unchanged without being read.
+func localFileUnchanged(apiClient pfs.APIClient, fileInfo *pfs.FileInfo, localPath string) (bool, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if uint64(info.Size()) != fileInfo.SizeBytes {
+		return false, nil
+	}
+	if fileInfo.Modified != nil && info.ModTime().Equal(prototime.TimestampToTime(fileInfo.Modified)) {
+		return true, nil
+	}
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = localFile.Close()
+	}()
+	file := fileInfo.File
+	size, prefix, err := pfsutil.FilePrefix(apiClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, localFile)
+	if err != nil {
+		return false, err
+	}
+	return prefix && size == info.Size(), nil
+}
+
+// getFileTo writes the file fileInfo is about to localPath, it's written to
+// a temporary file first so an interrupted sync doesn't leave half a file.
+func getFileTo(apiClient pfs.APIClient, fileInfo *pfs.FileInfo, localPath string) (retErr error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(localPath), ".pachyderm-sync")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	file := fileInfo.File
+	if err := pfsutil.GetFile(apiClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, 0, 0, nil, tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		return err
+	}
+	if fileInfo.Modified != nil {
+		modified := prototime.TimestampToTime(fileInfo.Modified)
+		return os.Chtimes(localPath, modified, modified)
+	}
+	return nil
+}
+
+// putFileFrom puts localPath from offset on to the file at filePath.
+func putFileFrom(apiClient pfs.APIClient, repoName string, commitID string, filePath string, localPath string, offset int64) error {
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = localFile.Close()
+	}()
+	if _, err := localFile.Seek(offset, 0); err != nil {
+		return err
+	}
+	_, err = pfsutil.PutFile(apiClient, repoName, commitID, filePath, 0, localFile)
+	return err
+}
+
+// excluded returns true if relPath or its name matches one of excludes.
+func excluded(relPath string, excludes []string) bool {
+	for _, exclude := range excludes {
+		if matched, _ := path.Match(exclude, relPath); matched {
+			return true
+		}
+		if matched, _ := path.Match(exclude, path.Base(relPath)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package drive
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+)
+
+// BlockSize is the size blocks are broken at, a block ends with the line
+// which takes it past BlockSize.
+const BlockSize = 128 * 1024 * 1024 // 128 Megabytes
+
+// NewHash returns the hash blocks are named by.
+func NewHash() hash.Hash {
+	return sha512.New()
+}
+
+// NewBlock returns the block named by hash.
+func NewBlock(hash hash.Hash) *Block {
+	return &Block{
+		Hash: base64.URLEncoding.EncodeToString(hash.Sum(nil)),
+	}
+}
+
+// CopyBlock copies lines from reader to writer until more than blockSize
+// bytes have been copied, it returns how many were and whether reader is
+// exhausted.
+func CopyBlock(writer io.Writer, reader *bufio.Reader, blockSize int) (int, bool, error) {
+	var bytesWritten int
+	for {
+		// lines longer than reader's buffer come back in pieces with
+		// ErrBufferFull, blocks are only broken after a whole line
+		bytes, err := reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return bytesWritten, false, err
+		}
+		if _, err := writer.Write(bytes); err != nil {
+			return bytesWritten, false, err
+		}
+		bytesWritten += len(bytes)
+		if err == io.EOF {
+			return bytesWritten, true, nil
+		}
+		if err == nil && bytesWritten > blockSize {
+			return bytesWritten, false, nil
+		}
+	}
+}
+
+// HashBlocks returns the blocks reader is stored as if it's put in one piece,
+// without storing them. Clients compare them to a file's blocks to tell if
+// its content is already stored.
+func HashBlocks(reader io.Reader) ([]*BlockRef, error) {
+	bufioReader := bufio.NewReader(reader)
+	var blockRefs []*BlockRef
+	for {
+		hash := NewHash()
+		size, eof, err := CopyBlock(hash, bufioReader, BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		// like PutBlock, a reader which ends right after a block is broken
+		// gets a last empty block
+		blockRefs = append(blockRefs, &BlockRef{
+			Block: NewBlock(hash),
+			Range: &ByteRange{
+				Lower: 0,
+				Upper: uint64(size),
+			},
+		})
+		if eof {
+			return blockRefs, nil
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"go.pedge.io/google-protobuf"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/shard"
 )
 
 // ReaderAtCloser is an interface that implements both io.ReaderAt and io.Closer.
@@ -20,23 +21,94 @@ type ReaderAtCloser interface {
 
 // Driver represents a low-level pfs storage driver.
 type Driver interface {
-	CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, shards map[uint64]bool) error
+	// CreateRepo creates a repo, a non-nil ttl makes it a scratch repo.
+	CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, ttl *google_protobuf.Duration, shards map[uint64]bool) error
 	InspectRepo(repo *pfs.Repo, shards map[uint64]bool) (*pfs.RepoInfo, error)
 	ListRepo(shards map[uint64]bool) ([]*pfs.RepoInfo, error)
-	DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) error
-	StartCommit(parent *pfs.Commit, commit *pfs.Commit, started *google_protobuf.Timestamp, shards map[uint64]bool) error
-	FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, shards map[uint64]bool) error
+	// DedupStats reports how much storage the commits of repos share, all
+	// repos if it's empty.
+	DedupStats(repos []*pfs.Repo, shards map[uint64]bool) ([]*pfs.RepoDedupStats, error)
+	// DeleteRepo deletes repo and all of its commits from shards. A shard
+	// that fails is reported in its ShardDeletion and the repo stays hidden
+	// until DeleteRepo is retried, even across restarts.
+	DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.ShardDeletion, error)
+	// TrashRepo hides repo until it's restored or deleted, deleted is when
+	// it was moved to the trash.
+	TrashRepo(repo *pfs.Repo, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error
+	RestoreRepo(repo *pfs.Repo, shards map[uint64]bool) error
+	// StartCommit starts commit, as part of transaction unless it's "". If
+	// expectedParent is non nil it fails if that has a finished child.
+	StartCommit(parent *pfs.Commit, commit *pfs.Commit, started *google_protobuf.Timestamp, transaction string, expectedParent *pfs.Commit, shards map[uint64]bool) error
+	// FinishCommit waits for in-flight reads and writes to commit before
+	// finishing it unless force is set, new ones are rejected while it waits.
+	// Commits which are part of a transaction can't be finished by it. If
+	// expectedParent is non nil it fails, leaving commit started, unless
+	// that's commit's parent and none of its other children have finished.
+	FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, force bool, expectedParent *pfs.Commit, shards map[uint64]bool) error
+	// PrepareTransaction finishes the commits started as part of transaction
+	// and persists them, without making them readable. They're all prepared
+	// or none are, writes to them are rejected until the transaction is
+	// released or aborted.
+	PrepareTransaction(transaction string, finished *google_protobuf.Timestamp, shards map[uint64]bool) error
+	// CommitTransaction persists that a prepared transaction will be
+	// released, it can't be aborted after. Once every server has committed
+	// it a restart makes its commits readable even if it wasn't released.
+	CommitTransaction(transaction string, shards map[uint64]bool) error
+	// ReleaseTransaction makes the commits of a committed transaction
+	// readable and returns them.
+	ReleaseTransaction(transaction string, shards map[uint64]bool) ([]*pfs.Commit, error)
+	// AbortTransaction undoes PrepareTransaction, the commits are left open.
+	AbortTransaction(transaction string, shards map[uint64]bool) error
+	// MergeCommits makes commit, a finished child of ours, with the files
+	// theirs changed since the two diverged, files changed on both sides are
+	// merged by strategy. A nil commit only checks that the merge can be made.
+	MergeCommits(ours *pfs.Commit, theirs *pfs.Commit, commit *pfs.Commit, strategy pfs.MergeStrategy, finished *google_protobuf.Timestamp, shards map[uint64]bool) error
 	InspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs.CommitInfo, error)
 	ListCommit(repo []*pfs.Repo, fromCommit []*pfs.Commit, shards map[uint64]bool) ([]*pfs.CommitInfo, error)
-	DeleteCommit(commit *pfs.Commit, shards map[uint64]bool) error
+	// DeleteCommit moves commit to the trash, it must be finished and have
+	// no children.
+	DeleteCommit(commit *pfs.Commit, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error
+	// RestoreCommit restores commit from the trash, its parent must have
+	// been restored first.
+	RestoreCommit(commit *pfs.Commit, shards map[uint64]bool) error
+	// TagCommit gives commit, which must be finished, a tag which can be
+	// used in place of its id. A tag can't be moved to another commit.
+	TagCommit(commit *pfs.Commit, tag string, shards map[uint64]bool) error
+	ListTag(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.TagInfo, error)
+	ListTrash(shards map[uint64]bool) ([]*pfs.TrashInfo, error)
+	// EmptyTrash permanently deletes the repos and commits which were moved
+	// to the trash before before, a nil before empties the whole trash.
+	EmptyTrash(before *google_protobuf.Timestamp, shards map[uint64]bool) error
 	PutFile(file *pfs.File, shard uint64, offset int64, reader io.Reader) error
 	MakeDirectory(file *pfs.File, shards map[uint64]bool) error
 	GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64, size int64, shard uint64) (io.ReadCloser, error)
 	InspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint64) (*pfs.FileInfo, error)
+	// InspectFileBlocks returns the blocks which hold file, in order, the
+	// blocks of an unfinished commit may be appended to afterward.
+	InspectFileBlocks(file *pfs.File, filterShard *pfs.Shard, shard uint64) ([]*BlockRef, error)
 	ListFile(file *pfs.File, filterShard *pfs.Shard, shard uint64) ([]*pfs.FileInfo, error)
 	DeleteFile(file *pfs.File, shard uint64) error
-	AddShard(shard uint64) error
+	// CopyFile adds the blocks of src to dst, src's commit must be finished
+	// and dst's commit must be started. srcShard need not be held locally.
+	CopyFile(src *pfs.File, srcShard uint64, dst *pfs.File, dstShard uint64) error
+	// ConcatFiles creates dst from the blocks of srcs, in order, srcShards
+	// are the shards of srcs. It has the same requirements as CopyFile and
+	// dst mustn't exist yet.
+	ConcatFiles(srcs []*pfs.File, srcShards []uint64, dst *pfs.File, dstShard uint64) error
+	// PutBlocks writes reader to the block store and returns the blocks
+	// which hold it, they aren't part of any file until CreateFile.
+	PutBlocks(reader io.Reader) ([]*BlockRef, error)
+	// CreateFile creates file from blockRefs, in order, file's commit must be
+	// started and file mustn't exist yet.
+	CreateFile(file *pfs.File, shard uint64, blockRefs []*BlockRef) error
+	// AddShard reports the diffs it's pulled to transfer and returns
+	// shard.ErrTransferCancelled, with part of the shard added, if transfer
+	// is cancelled.
+	AddShard(shard uint64, transfer shard.Transfer) error
 	DeleteShard(shard uint64) error
+	// Flush persists diffs for commits that haven't been finished, they're
+	// restored as unfinished by AddShard.
+	Flush() error
 }
 
 func ByteRangeSize(byteRange *ByteRange) uint64 {
@@ -6,9 +6,11 @@
 Package drive is a generated protocol buffer package.
 
 It is generated from these files:
+
 	pfs/drive/drive.proto
 
 It has these top-level messages:
+
 	Block
 	Diff
 	ByteRange
@@ -23,7 +25,11 @@ It has these top-level messages:
 	ListBlockRequest
 	InspectDiffRequest
 	ListDiffRequest
+	PullDiffRequest
+	DiffChunk
 	DeleteDiffRequest
+	RecoveryReport
+	ScrubReport
 */
 package drive
 
@@ -34,6 +40,7 @@ import _ "github.com/gengo/grpc-gateway/third_party/googleapis/google/api"
 import google_protobuf1 "go.pedge.io/google-protobuf"
 import google_protobuf2 "go.pedge.io/google-protobuf"
 import google_protobuf3 "go.pedge.io/google-protobuf"
+import google_protobuf4 "go.pedge.io/google-protobuf"
 import pfs "github.com/pachyderm/pachyderm/src/pfs"
 
 import (
@@ -195,6 +202,32 @@ type DiffInfo struct {
 	// Appends is the BlockRefs which have been append to files indexed by path.
 	Appends   map[string]*Append `protobuf:"bytes,5,rep,name=appends" json:"appends,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	SizeBytes uint64             `protobuf:"varint,6,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	// The following are computed when the diff is finished.
+	FilesAdded uint64 `protobuf:"varint,7,opt,name=files_added" json:"files_added,omitempty"`
+	SizeDelta  int64  `protobuf:"varint,9,opt,name=size_delta" json:"size_delta,omitempty"`
+	// repo_ttl is set on the diff that creates a scratch repo.
+	RepoTtl *google_protobuf4.Duration `protobuf:"bytes,10,opt,name=repo_ttl" json:"repo_ttl,omitempty"`
+	// repo_deleting is set on the diff that creates a repo once the repo
+	// starts being deleted, a repo restored with it set is hidden until the
+	// delete is retried.
+	RepoDeleting bool `protobuf:"varint,11,opt,name=repo_deleting" json:"repo_deleting,omitempty"`
+	// trashed is set when the commit, or the repo if this is the diff that
+	// creates it, is moved to the trash.
+	Trashed *google_protobuf2.Timestamp `protobuf:"bytes,12,opt,name=trashed" json:"trashed,omitempty"`
+	// tags are the names the commit has been tagged with, they're set on the
+	// commit's diff in every shard.
+	Tags []string `protobuf:"bytes,13,rep,name=tags" json:"tags,omitempty"`
+	// merged_commit is set on the diffs of a merge commit to the commit which
+	// was merged into its parent.
+	MergedCommit *pfs.Commit `protobuf:"bytes,14,opt,name=merged_commit" json:"merged_commit,omitempty"`
+	// transaction is set on the diffs of a commit started in a transaction.
+	Transaction string `protobuf:"bytes,15,opt,name=transaction" json:"transaction,omitempty"`
+	// transaction_prepared is set while the diff is finished but its
+	// transaction hasn't been released, it isn't readable until then.
+	TransactionPrepared bool `protobuf:"varint,16,opt,name=transaction_prepared" json:"transaction_prepared,omitempty"`
+	// transaction_committed is set once every server has prepared the
+	// transaction, a diff restored with it set is readable.
+	TransactionCommitted bool `protobuf:"varint,17,opt,name=transaction_committed" json:"transaction_committed,omitempty"`
 }
 
 func (m *DiffInfo) Reset()         { *m = DiffInfo{} }
@@ -236,6 +269,27 @@ func (m *DiffInfo) GetAppends() map[string]*Append {
 	return nil
 }
 
+func (m *DiffInfo) GetRepoTtl() *google_protobuf4.Duration {
+	if m != nil {
+		return m.RepoTtl
+	}
+	return nil
+}
+
+func (m *DiffInfo) GetTrashed() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Trashed
+	}
+	return nil
+}
+
+func (m *DiffInfo) GetMergedCommit() *pfs.Commit {
+	if m != nil {
+		return m.MergedCommit
+	}
+	return nil
+}
+
 type GetBlockRequest struct {
 	Block       *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
 	OffsetBytes uint64 `protobuf:"varint,2,opt,name=offset_bytes" json:"offset_bytes,omitempty"`
@@ -292,12 +346,45 @@ func (m *InspectDiffRequest) GetDiff() *Diff {
 
 type ListDiffRequest struct {
 	Shard uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	// headers_only leaves out the diffs' appends, the full diffs can then be
+	// fetched with PullDiff.
+	HeadersOnly bool `protobuf:"varint,2,opt,name=headers_only" json:"headers_only,omitempty"`
 }
 
 func (m *ListDiffRequest) Reset()         { *m = ListDiffRequest{} }
 func (m *ListDiffRequest) String() string { return proto.CompactTextString(m) }
 func (*ListDiffRequest) ProtoMessage()    {}
 
+type PullDiffRequest struct {
+	Diff *Diff `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
+	// offset_bytes resumes a transfer that was interrupted.
+	OffsetBytes uint64 `protobuf:"varint,2,opt,name=offset_bytes" json:"offset_bytes,omitempty"`
+}
+
+func (m *PullDiffRequest) Reset()         { *m = PullDiffRequest{} }
+func (m *PullDiffRequest) String() string { return proto.CompactTextString(m) }
+func (*PullDiffRequest) ProtoMessage()    {}
+
+func (m *PullDiffRequest) GetDiff() *Diff {
+	if m != nil {
+		return m.Diff
+	}
+	return nil
+}
+
+// DiffChunk is a piece of a serialized DiffInfo.
+type DiffChunk struct {
+	Value []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	// size_bytes and checksum describe the whole serialized DiffInfo, checksum
+	// is its base64 encoded sha512.
+	SizeBytes uint64 `protobuf:"varint,2,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	Checksum  string `protobuf:"bytes,3,opt,name=checksum" json:"checksum,omitempty"`
+}
+
+func (m *DiffChunk) Reset()         { *m = DiffChunk{} }
+func (m *DiffChunk) String() string { return proto.CompactTextString(m) }
+func (*DiffChunk) ProtoMessage()    {}
+
 type DeleteDiffRequest struct {
 	Diff *Diff `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
 }
@@ -313,6 +400,38 @@ func (m *DeleteDiffRequest) GetDiff() *Diff {
 	return nil
 }
 
+// RecoveryReport is logged by a drive server when it starts up, it describes
+// what was left behind by a crash and what was done about it.
+type RecoveryReport struct {
+	// partial blocks removed from the tmp directory
+	OrphanedBlocks uint64 `protobuf:"varint,1,opt,name=orphaned_blocks" json:"orphaned_blocks,omitempty"`
+	OrphanedBytes  uint64 `protobuf:"varint,2,opt,name=orphaned_bytes" json:"orphaned_bytes,omitempty"`
+	DiffsChecked   uint64 `protobuf:"varint,3,opt,name=diffs_checked" json:"diffs_checked,omitempty"`
+	// diffs whose header disagreed with their path and were rewritten
+	DiffsRepaired uint64 `protobuf:"varint,4,opt,name=diffs_repaired" json:"diffs_repaired,omitempty"`
+	// files moved to the quarantine directory, each is "path: reason"
+	Quarantined []string `protobuf:"bytes,5,rep,name=quarantined" json:"quarantined,omitempty"`
+}
+
+func (m *RecoveryReport) Reset()         { *m = RecoveryReport{} }
+func (m *RecoveryReport) String() string { return proto.CompactTextString(m) }
+func (*RecoveryReport) ProtoMessage()    {}
+
+// ScrubReport is logged by a drive server after each pass of its scrubber.
+type ScrubReport struct {
+	BlocksChecked uint64 `protobuf:"varint,1,opt,name=blocks_checked" json:"blocks_checked,omitempty"`
+	BytesChecked  uint64 `protobuf:"varint,2,opt,name=bytes_checked" json:"bytes_checked,omitempty"`
+	// blocks whose content doesn't match their hash, each is "hash: reason",
+	// they're moved to the quarantine directory
+	Corrupt []string `protobuf:"bytes,3,rep,name=corrupt" json:"corrupt,omitempty"`
+	// corrupt blocks which were fetched again from the replica
+	Repaired []string `protobuf:"bytes,4,rep,name=repaired" json:"repaired,omitempty"`
+}
+
+func (m *ScrubReport) Reset()         { *m = ScrubReport{} }
+func (m *ScrubReport) String() string { return proto.CompactTextString(m) }
+func (*ScrubReport) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterType((*Block)(nil), "Block")
 	proto.RegisterType((*Diff)(nil), "Diff")
@@ -328,7 +447,11 @@ func init() {
 	proto.RegisterType((*ListBlockRequest)(nil), "ListBlockRequest")
 	proto.RegisterType((*InspectDiffRequest)(nil), "InspectDiffRequest")
 	proto.RegisterType((*ListDiffRequest)(nil), "ListDiffRequest")
+	proto.RegisterType((*PullDiffRequest)(nil), "PullDiffRequest")
+	proto.RegisterType((*DiffChunk)(nil), "DiffChunk")
 	proto.RegisterType((*DeleteDiffRequest)(nil), "DeleteDiffRequest")
+	proto.RegisterType((*RecoveryReport)(nil), "RecoveryReport")
+	proto.RegisterType((*ScrubReport)(nil), "ScrubReport")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -345,7 +468,10 @@ type APIClient interface {
 	CreateDiff(ctx context.Context, in *DiffInfo, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	InspectDiff(ctx context.Context, in *InspectDiffRequest, opts ...grpc.CallOption) (*DiffInfo, error)
 	ListDiff(ctx context.Context, in *ListDiffRequest, opts ...grpc.CallOption) (API_ListDiffClient, error)
+	PullDiff(ctx context.Context, in *PullDiffRequest, opts ...grpc.CallOption) (API_PullDiffClient, error)
 	DeleteDiff(ctx context.Context, in *DeleteDiffRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	ListOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*pfs.Ops, error)
+	CancelOp(ctx context.Context, in *pfs.CancelOpRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 }
 
 type aPIClient struct {
@@ -490,6 +616,38 @@ func (x *aPIListDiffClient) Recv() (*DiffInfo, error) {
 	return m, nil
 }
 
+func (c *aPIClient) PullDiff(ctx context.Context, in *PullDiffRequest, opts ...grpc.CallOption) (API_PullDiffClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[3], c.cc, "/.API/PullDiff", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIPullDiffClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_PullDiffClient interface {
+	Recv() (*DiffChunk, error)
+	grpc.ClientStream
+}
+
+type aPIPullDiffClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIPullDiffClient) Recv() (*DiffChunk, error) {
+	m := new(DiffChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *aPIClient) DeleteDiff(ctx context.Context, in *DeleteDiffRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
 	out := new(google_protobuf1.Empty)
 	err := grpc.Invoke(ctx, "/.API/DeleteDiff", in, out, c.cc, opts...)
@@ -499,6 +657,24 @@ func (c *aPIClient) DeleteDiff(ctx context.Context, in *DeleteDiffRequest, opts
 	return out, nil
 }
 
+func (c *aPIClient) ListOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*pfs.Ops, error) {
+	out := new(pfs.Ops)
+	err := grpc.Invoke(ctx, "/.API/ListOp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) CancelOp(ctx context.Context, in *pfs.CancelOpRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/.API/CancelOp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for API service
 
 type APIServer interface {
@@ -509,7 +685,10 @@ type APIServer interface {
 	CreateDiff(context.Context, *DiffInfo) (*google_protobuf1.Empty, error)
 	InspectDiff(context.Context, *InspectDiffRequest) (*DiffInfo, error)
 	ListDiff(*ListDiffRequest, API_ListDiffServer) error
+	PullDiff(*PullDiffRequest, API_PullDiffServer) error
 	DeleteDiff(context.Context, *DeleteDiffRequest) (*google_protobuf1.Empty, error)
+	ListOp(context.Context, *google_protobuf1.Empty) (*pfs.Ops, error)
+	CancelOp(context.Context, *pfs.CancelOpRequest) (*google_protobuf1.Empty, error)
 }
 
 func RegisterAPIServer(s *grpc.Server, srv APIServer) {
@@ -632,6 +811,27 @@ func (x *aPIListDiffServer) Send(m *DiffInfo) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _API_PullDiff_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PullDiffRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).PullDiff(m, &aPIPullDiffServer{stream})
+}
+
+type API_PullDiffServer interface {
+	Send(*DiffChunk) error
+	grpc.ServerStream
+}
+
+type aPIPullDiffServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIPullDiffServer) Send(m *DiffChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _API_DeleteDiff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(DeleteDiffRequest)
 	if err := dec(in); err != nil {
@@ -644,6 +844,30 @@ func _API_DeleteDiff_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return out, nil
 }
 
+func _API_ListOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListOp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_CancelOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(pfs.CancelOpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CancelOp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _API_serviceDesc = grpc.ServiceDesc{
 	ServiceName: ".API",
 	HandlerType: (*APIServer)(nil),
@@ -668,6 +892,14 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteDiff",
 			Handler:    _API_DeleteDiff_Handler,
 		},
+		{
+			MethodName: "ListOp",
+			Handler:    _API_ListOp_Handler,
+		},
+		{
+			MethodName: "CancelOp",
+			Handler:    _API_CancelOp_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -685,5 +917,10 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			Handler:       _API_ListDiff_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "PullDiff",
+			Handler:       _API_PullDiff_Handler,
+			ServerStreams: true,
+		},
 	},
 }
@@ -1,48 +1,78 @@
 package obj
 
 import (
+	"crypto/sha512"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"path"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
 	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
+	pkgshard "github.com/pachyderm/pachyderm/src/pkg/shard"
 	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
 )
 
 type driver struct {
-	driveClient drive.APIClient
-	started     diffMap
-	finished    diffMap
-	internals   diffMap
-	leaves      diffMap // commits with no children
-	lock        sync.RWMutex
+	driveClient        drive.APIClient
+	replicationLimiter ratelimit.Limiter
+	started            diffMap
+	finished           diffMap
+	internals          diffMap
+	leaves             diffMap // commits with no children
+	trash              diffMap // commits which have been deleted but can be restored
+	trashedRepos       map[string]*google_protobuf.Timestamp
+	tags               map[string]map[string]*pfs.Commit // repo name -> tag -> commit
+	deleting           map[string]bool
+	transactions       map[string]*transaction // the unreleased transactions, by id
+	lock               sync.RWMutex
+	fences             *fences
 }
 
-func newDriver(driveClient drive.APIClient) (drive.Driver, error) {
+func newDriver(driveClient drive.APIClient, replicationLimiter ratelimit.Limiter) (drive.Driver, error) {
 	return &driver{
 		driveClient,
+		replicationLimiter,
+		make(diffMap),
 		make(diffMap),
 		make(diffMap),
 		make(diffMap),
 		make(diffMap),
+		make(map[string]*google_protobuf.Timestamp),
+		make(map[string]map[string]*pfs.Commit),
+		make(map[string]bool),
+		make(map[string]*transaction),
 		sync.RWMutex{},
+		newFences(),
 	}, nil
 }
 
-func (d *driver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, shards map[uint64]bool) error {
+func (d *driver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, ttl *google_protobuf.Duration, shards map[uint64]bool) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
+	if d.deleting[repo.Name] {
+		return fmt.Errorf("repo %s is being deleted", repo.Name)
+	}
+	if d.trashedRepos[repo.Name] != nil {
+		return fmt.Errorf("repo %s is in the trash, restore it or empty the trash", repo.Name)
+	}
 	if _, ok := d.finished[repo.Name]; ok {
 		return fmt.Errorf("repo %s exists", repo.Name)
 	}
 	d.finished[repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
 	d.started[repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
 	d.leaves[repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
+	d.trash[repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
 
 	var wg sync.WaitGroup
 	var loopErr error
@@ -54,6 +84,7 @@ func (d *driver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp,
 				Shard:  shard,
 			},
 			Finished: created,
+			RepoTtl:  ttl,
 		}
 		if err := d.finished.insert(diffInfo); err != nil {
 			return err
@@ -83,6 +114,9 @@ func (d *driver) ListRepo(shards map[uint64]bool) ([]*pfs.RepoInfo, error) {
 	var result []*pfs.RepoInfo
 	var lock sync.Mutex
 	for repoName := range d.finished {
+		if d.hidden(repoName) {
+			continue
+		}
 		wg.Add(1)
 		repoName := repoName
 		go func() {
@@ -103,39 +137,195 @@ func (d *driver) ListRepo(shards map[uint64]bool) ([]*pfs.RepoInfo, error) {
 	return result, nil
 }
 
-func (d *driver) DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) error {
-	var diffInfos []*drive.DiffInfo
+func (d *driver) DedupStats(repos []*pfs.Repo, shards map[uint64]bool) ([]*pfs.RepoDedupStats, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if len(repos) == 0 {
+		for repoName := range d.finished {
+			if !d.hidden(repoName) {
+				repos = append(repos, &pfs.Repo{Name: repoName})
+			}
+		}
+	}
+	var result []*pfs.RepoDedupStats
+	for _, repo := range repos {
+		if _, ok := d.finished[repo.Name]; !ok || d.hidden(repo.Name) {
+			return nil, fmt.Errorf("repo %s not found", repo.Name)
+		}
+		result = append(result, d.dedupStats(repo, shards))
+	}
+	return result, nil
+}
+
+// dedupStats returns repo's dedup stats in shards, d.lock must be held.
+func (d *driver) dedupStats(repo *pfs.Repo, shards map[uint64]bool) *pfs.RepoDedupStats {
+	result := &pfs.RepoDedupStats{Repo: repo}
+	commits := make(map[string]bool)
+	blockRefs := make(map[string]bool)
+	for shard := range shards {
+		diffInfos := d.finished[repo.Name][shard]
+		// the size of each commit with its ancestors, by id
+		sizes := make(map[string]uint64)
+		var size func(commit *pfs.Commit) uint64
+		size = func(commit *pfs.Commit) uint64 {
+			if commit == nil {
+				return 0
+			}
+			if cached, ok := sizes[commit.Id]; ok {
+				return cached
+			}
+			diffInfo, ok := diffInfos[commit.Id]
+			if !ok {
+				return 0
+			}
+			sizes[commit.Id] = diffInfo.SizeBytes + size(diffInfo.ParentCommit)
+			return sizes[commit.Id]
+		}
+		for _, diffInfo := range diffInfos {
+			if diffInfo.Diff.Commit.Id == "" {
+				// the diff that creates the repo
+				continue
+			}
+			// every commit has a diff in every shard so we count them by id
+			commits[diffInfo.Diff.Commit.Id] = true
+			result.LogicalBytes += size(diffInfo.Diff.Commit)
+			for _, _append := range diffInfo.Appends {
+				for _, blockRef := range _append.BlockRefs {
+					blockRefSize := drive.ByteRangeSize(blockRef.Range)
+					result.BlockRefs++
+					result.ReferencedBytes += blockRefSize
+					key := fmt.Sprintf("%s:%d-%d", blockRef.Block.Hash, blockRef.Range.Lower, blockRef.Range.Upper)
+					if !blockRefs[key] {
+						blockRefs[key] = true
+						result.UniqueBlockRefs++
+						result.PhysicalBytes += blockRefSize
+						result.UniqueBlocks = append(result.UniqueBlocks, &pfs.FileBlock{
+							Hash:  blockRef.Block.Hash,
+							Lower: blockRef.Range.Lower,
+							Upper: blockRef.Range.Upper,
+						})
+					}
+				}
+			}
+		}
+	}
+	result.CommitCount = uint64(len(commits))
+	return result
+}
+
+func (d *driver) DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.ShardDeletion, error) {
+	repoDiffInfos := make(map[uint64]*drive.DiffInfo)
+	diffInfos := make(map[uint64][]*drive.DiffInfo)
 	d.lock.Lock()
+	if _, ok := d.finished[repo.Name]; !ok {
+		d.lock.Unlock()
+		return nil, nil
+	}
+	// the repo is hidden before anything is deleted so that it disappears
+	// all at once rather than a commit at a time
+	d.deleting[repo.Name] = true
 	for shard := range shards {
 		for _, diffInfo := range d.started[repo.Name][shard] {
-			diffInfos = append(diffInfos, diffInfo)
+			diffInfos[shard] = append(diffInfos[shard], diffInfo)
+		}
+		for _, diffInfo := range d.trash[repo.Name][shard] {
+			diffInfos[shard] = append(diffInfos[shard], diffInfo)
+		}
+		for _, diffInfo := range d.finished[repo.Name][shard] {
+			if diffInfo.Diff.Commit.Id == "" {
+				repoDiffInfos[shard] = diffInfo
+			} else {
+				diffInfos[shard] = append(diffInfos[shard], diffInfo)
+			}
 		}
 	}
-	delete(d.started, repo.Name)
-	delete(d.finished, repo.Name)
 	d.lock.Unlock()
-	var loopErr error
-	var wg sync.WaitGroup
-	for _, diffInfo := range diffInfos {
-		diffInfo := diffInfo
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	var deletions []*pfs.ShardDeletion
+	for shard := range shards {
+		deletions = append(deletions, &pfs.ShardDeletion{Shard: shard})
+	}
+	// every shard is marked before any commits are deleted so that a crash
+	// part way through restores the repo hidden rather than with holes in
+	// it, the diff that creates the repo is deleted last so the mark lasts
+	// as long as the repo does
+	eachShardDeletion(deletions, func(deletion *pfs.ShardDeletion) error {
+		repoDiffInfo, ok := repoDiffInfos[deletion.Shard]
+		if !ok {
+			return nil
+		}
+		markedDiffInfo := *repoDiffInfo
+		markedDiffInfo.RepoDeleting = true
+		_, err := d.driveClient.CreateDiff(context.Background(), &markedDiffInfo)
+		return err
+	})
+	if !shardDeletionsOK(deletions) {
+		for _, deletion := range deletions {
+			if deletion.Error == "" {
+				deletion.Error = fmt.Sprintf("repo %s couldn't be marked deleting on every shard", repo.Name)
+			}
+		}
+		return deletions, nil
+	}
+	eachShardDeletion(deletions, func(deletion *pfs.ShardDeletion) error {
+		shardDiffInfos := diffInfos[deletion.Shard]
+		if repoDiffInfo, ok := repoDiffInfos[deletion.Shard]; ok {
+			shardDiffInfos = append(shardDiffInfos, repoDiffInfo)
+		}
+		for _, diffInfo := range shardDiffInfos {
 			if _, err := d.driveClient.DeleteDiff(
 				context.Background(),
 				&drive.DeleteDiffRequest{Diff: diffInfo.Diff},
-			); err != nil && loopErr == nil {
-				loopErr = err
+			); err != nil {
+				return err
 			}
-		}()
+			// diffs are forgotten as they're deleted so a retry only
+			// deletes what's left
+			d.lock.Lock()
+			d.started.pop(diffInfo.Diff)
+			d.finished.pop(diffInfo.Diff)
+			d.trash.pop(diffInfo.Diff)
+			d.lock.Unlock()
+			deletion.DiffsDeleted++
+		}
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		for _, diffMap := range []diffMap{d.started, d.finished, d.leaves, d.internals, d.trash} {
+			if shardMap, ok := diffMap[repo.Name]; ok {
+				delete(shardMap, deletion.Shard)
+			}
+		}
+		return nil
+	})
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if len(d.finished[repo.Name]) == 0 {
+		delete(d.started, repo.Name)
+		delete(d.finished, repo.Name)
+		delete(d.leaves, repo.Name)
+		delete(d.internals, repo.Name)
+		delete(d.trash, repo.Name)
+		delete(d.trashedRepos, repo.Name)
+		delete(d.tags, repo.Name)
+		delete(d.deleting, repo.Name)
 	}
-	wg.Wait()
-	return loopErr
+	return deletions, nil
 }
 
-func (d *driver) StartCommit(parent *pfs.Commit, commit *pfs.Commit, started *google_protobuf.Timestamp, shards map[uint64]bool) error {
+func (d *driver) StartCommit(parent *pfs.Commit, commit *pfs.Commit, started *google_protobuf.Timestamp, transaction string, expectedParent *pfs.Commit, shards map[uint64]bool) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
+	if d.hidden(commit.Repo.Name) {
+		return fmt.Errorf("repo %s not found", commit.Repo.Name)
+	}
+	if t, ok := d.transactions[transaction]; ok && t.prepared {
+		return fmt.Errorf("transaction %s is being finished", transaction)
+	}
+	if expectedParent != nil {
+		if err := d.checkNoFinishedChild(d.resolveCommit(expectedParent), shards); err != nil {
+			return err
+		}
+	}
+	parent = d.resolveCommit(parent)
 	for shard := range shards {
 		diffInfo := &drive.DiffInfo{
 			Diff: &drive.Diff{
@@ -145,20 +335,37 @@ func (d *driver) StartCommit(parent *pfs.Commit, commit *pfs.Commit, started *go
 			Started:      started,
 			ParentCommit: parent,
 			Appends:      make(map[string]*drive.Append),
+			Transaction:  transaction,
 		}
 		if err := d.started.insert(diffInfo); err != nil {
 			return err
 		}
 	}
+	if transaction != "" {
+		d.addTransactionCommit(transaction, commit)
+	}
 	return nil
 }
 
-func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, shards map[uint64]bool) error {
+func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, force bool, expectedParent *pfs.Commit, shards map[uint64]bool) error {
+	d.lock.RLock()
+	transaction := d.commitTransaction(commit)
+	d.lock.RUnlock()
+	if transaction != "" {
+		return fmt.Errorf("commit %s/%s is part of transaction %s, finish the transaction instead", commit.Repo.Name, commit.Id, transaction)
+	}
+	d.fences.fence(commit, shards, !force)
+	defer d.fences.unfence(commit, shards)
 	// closure so we can defer Unlock
 	var diffInfos []*drive.DiffInfo
 	if err := func() error {
 		d.lock.Lock()
 		defer d.lock.Unlock()
+		if expectedParent != nil {
+			if err := d.checkExpectedParent(commit, d.resolveCommit(expectedParent), shards); err != nil {
+				return err
+			}
+		}
 		for shard := range shards {
 			diffInfo := d.started.pop(&drive.Diff{
 				Commit: commit,
@@ -168,6 +375,7 @@ func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Time
 				return fmt.Errorf("commit %s/%s not found", commit.Repo.Name, commit.Id)
 			}
 			diffInfo.Finished = finished
+			setDiffStats(diffInfo)
 			diffInfos = append(diffInfos, diffInfo)
 			if err := d.finished.insert(diffInfo); err != nil {
 				return err
@@ -196,113 +404,1035 @@ func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Time
 	return loopErr
 }
 
-func (d *driver) InspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs.CommitInfo, error) {
+func (d *driver) PrepareTransaction(transactionID string, finished *google_protobuf.Timestamp, shards map[uint64]bool) error {
 	d.lock.RLock()
-	defer d.lock.RUnlock()
-	return d.inspectCommit(commit, shards)
-}
-
-func (d *driver) ListCommit(repos []*pfs.Repo, fromCommit []*pfs.Commit, shards map[uint64]bool) ([]*pfs.CommitInfo, error) {
-	repoSet := make(map[string]bool)
-	for _, repo := range repos {
-		repoSet[repo.Name] = true
+	t, ok := d.transactions[transactionID]
+	released := !ok && d.transactionReleased(transactionID, shards)
+	d.lock.RUnlock()
+	if released {
+		return nil
 	}
-	breakCommitIds := make(map[string]bool)
-	for _, commit := range fromCommit {
-		if !repoSet[commit.Repo.Name] {
-			return nil, fmt.Errorf("Commit %s/%s is from a repo that isn't being listed.", commit.Repo.Name, commit.Id)
-		}
-		breakCommitIds[commit.Id] = true
+	if !ok {
+		return fmt.Errorf("transaction %s not found", transactionID)
 	}
-	d.lock.RLock()
-	defer d.lock.RUnlock()
-	var result []*pfs.CommitInfo
-	for _, repo := range repos {
-		for shard := range shards {
-			_, ok := d.finished[repo.Name]
-			if !ok {
-				return nil, fmt.Errorf("repo %s not found", repo.Name)
-			}
-			for commitID := range d.leaves[repo.Name][shard] {
-				commit := &pfs.Commit{
-					Repo: repo,
-					Id:   commitID,
-				}
-				for commit != nil && !breakCommitIds[commit.Id] {
-					// we add this commit to breakCommitIds so we won't see it twice
-					breakCommitIds[commit.Id] = true
-					commitInfo, err := d.inspectCommit(commit, shards)
-					if err != nil {
-						return nil, err
-					}
-					result = append(result, commitInfo)
-					commit = commitInfo.ParentCommit
+	// the commits stay fenced until the transaction is released or aborted
+	for _, commit := range t.commits {
+		d.fences.fence(commit, shards, true)
+	}
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if t.prepared {
+			return nil
+		}
+		// every diff is checked before any is prepared so that a missing
+		// one leaves all of the commits open
+		for _, commit := range t.commits {
+			for shard := range shards {
+				if _, ok := d.started.get(&drive.Diff{
+					Commit: commit,
+					Shard:  shard,
+				}); !ok {
+					return fmt.Errorf("commit %s/%s not found", commit.Repo.Name, commit.Id)
 				}
 			}
 		}
+		// the diffs stay in started so they aren't readable until the
+		// transaction is released
+		diffInfos = d.transactionDiffInfos(t, shards)
+		for _, diffInfo := range diffInfos {
+			diffInfo.Finished = finished
+			diffInfo.TransactionPrepared = true
+			setDiffStats(diffInfo)
+		}
+		t.prepared = true
+		return nil
+	}(); err != nil {
+		d.unfenceTransaction(t, shards)
+		return err
+	}
+	if err := d.createDiffs(diffInfos); err != nil {
+		if abortErr := d.AbortTransaction(transactionID, shards); abortErr != nil {
+			return fmt.Errorf("%s, and it couldn't be aborted: %s", err.Error(), abortErr.Error())
+		}
+		return err
 	}
-	return result, nil
+	return nil
 }
 
-func (d *driver) DeleteCommit(commit *pfs.Commit, shards map[uint64]bool) error {
-	return nil
+func (d *driver) CommitTransaction(transactionID string, shards map[uint64]bool) error {
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		t, ok := d.transactions[transactionID]
+		if !ok {
+			if d.transactionReleased(transactionID, shards) {
+				return nil
+			}
+			return fmt.Errorf("transaction %s not found", transactionID)
+		}
+		if !t.prepared {
+			return fmt.Errorf("transaction %s hasn't been prepared", transactionID)
+		}
+		diffInfos = d.transactionDiffInfos(t, shards)
+		for _, diffInfo := range diffInfos {
+			diffInfo.TransactionCommitted = true
+		}
+		t.committed = true
+		return nil
+	}(); err != nil {
+		return err
+	}
+	return d.createDiffs(diffInfos)
 }
 
-func (d *driver) PutFile(file *pfs.File, shard uint64, offset int64, reader io.Reader) (retErr error) {
-	d.lock.RLock()
-	diffInfo, ok := d.started.get(&drive.Diff{
-		Commit: file.Commit,
-		Shard:  shard,
-	})
-	d.lock.RUnlock()
-	if !ok {
-		return fmt.Errorf("commit %s/%s not found", file.Commit.Repo.Name, file.Commit.Id)
+func (d *driver) ReleaseTransaction(transactionID string, shards map[uint64]bool) ([]*pfs.Commit, error) {
+	var t *transaction
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		var ok bool
+		t, ok = d.transactions[transactionID]
+		if !ok {
+			if d.transactionReleased(transactionID, shards) {
+				return nil
+			}
+			return fmt.Errorf("transaction %s not found", transactionID)
+		}
+		if !t.committed {
+			return fmt.Errorf("transaction %s hasn't been committed", transactionID)
+		}
+		diffInfos = d.transactionDiffInfos(t, shards)
+		for _, diffInfo := range diffInfos {
+			d.started.pop(diffInfo.Diff)
+			diffInfo.TransactionPrepared = false
+			if err := d.finished.insert(diffInfo); err != nil {
+				return err
+			}
+			if err := d.insertLeaf(diffInfo); err != nil {
+				return err
+			}
+		}
+		delete(d.transactions, transactionID)
+		return nil
+	}(); err != nil {
+		return nil, err
 	}
-	blockRefs, err := pfsutil.PutBlock(d.driveClient, reader)
-	if err != nil {
-		return err
+	if t == nil {
+		return nil, nil
 	}
-	d.lock.Lock()
-	defer d.lock.Unlock()
-	diffInfo, ok = d.started.get(&drive.Diff{
-		Commit: file.Commit,
-		Shard:  shard,
-	})
-	if !ok {
-		// This is a weird case since the commit existed above, it means someone
-		// deleted the commit while the above code was running
-		return fmt.Errorf("commit %s/%s not found", file.Commit.Repo.Name, file.Commit.Id)
+	d.unfenceTransaction(t, shards)
+	// a restart before this is persisted makes the diffs readable anyway
+	// since they're committed
+	if err := d.createDiffs(diffInfos); err != nil {
+		return nil, err
 	}
-	addDirs(diffInfo, file)
-	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
-	if !ok {
-		_append = &drive.Append{}
-		if diffInfo.ParentCommit != nil {
-			_append.LastRef = d.lastRef(
-				pfsutil.NewFile(
-					diffInfo.ParentCommit.Repo.Name,
-					diffInfo.ParentCommit.Id,
-					file.Path,
-				),
-				shard,
-			)
+	return t.commits, nil
+}
+
+func (d *driver) AbortTransaction(transactionID string, shards map[uint64]bool) error {
+	var t *transaction
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		var ok bool
+		t, ok = d.transactions[transactionID]
+		if !ok {
+			return fmt.Errorf("transaction %s not found", transactionID)
 		}
-		diffInfo.Appends[path.Clean(file.Path)] = _append
-	}
-	_append.BlockRefs = append(_append.BlockRefs, blockRefs.BlockRef...)
-	for _, blockRef := range blockRefs.BlockRef {
-		diffInfo.SizeBytes += blockRef.Range.Upper - blockRef.Range.Lower
+		if t.committed {
+			return fmt.Errorf("transaction %s has been committed, it can't be aborted", transactionID)
+		}
+		if !t.prepared {
+			return nil
+		}
+		diffInfos = d.transactionDiffInfos(t, shards)
+		for _, diffInfo := range diffInfos {
+			diffInfo.Finished = nil
+			diffInfo.TransactionPrepared = false
+		}
+		t.prepared = false
+		return nil
+	}(); err != nil {
+		return err
 	}
-	return nil
+	d.unfenceTransaction(t, shards)
+	return d.createDiffs(diffInfos)
 }
 
-func (d *driver) MakeDirectory(file *pfs.File, shards map[uint64]bool) error {
-	return nil
+func (d *driver) MergeCommits(ours *pfs.Commit, theirs *pfs.Commit, commit *pfs.Commit, strategy pfs.MergeStrategy, finished *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if d.hidden(ours.Repo.Name) {
+			return fmt.Errorf("repo %s not found", ours.Repo.Name)
+		}
+		ours = d.resolveCommit(ours)
+		theirs = d.resolveCommit(theirs)
+		if ours.Repo.Name != theirs.Repo.Name {
+			return fmt.Errorf("can't merge %s/%s into %s/%s, they're in different repos", theirs.Repo.Name, theirs.Id, ours.Repo.Name, ours.Id)
+		}
+		// every shard is merged before any is finished so that a conflict
+		// in one leaves the commit unmade in all of them
+		for shard := range shards {
+			diffInfo, err := d.mergeShard(ours, theirs, commit, strategy, finished, shard)
+			if err != nil {
+				return err
+			}
+			diffInfos = append(diffInfos, diffInfo)
+		}
+		if commit == nil {
+			return nil
+		}
+		for _, diffInfo := range diffInfos {
+			setDiffStats(diffInfo)
+			if err := d.finished.insert(diffInfo); err != nil {
+				return err
+			}
+			if err := d.insertLeaf(diffInfo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	if commit == nil {
+		return nil
+	}
+	return d.createDiffs(diffInfos)
+}
+
+// mergeShard returns the diff of the merge commit in shard, d.lock must be
+// held.
+func (d *driver) mergeShard(ours *pfs.Commit, theirs *pfs.Commit, commit *pfs.Commit, strategy pfs.MergeStrategy, finished *google_protobuf.Timestamp, shard uint64) (*drive.DiffInfo, error) {
+	for _, c := range []*pfs.Commit{ours, theirs} {
+		if _, ok := d.finished.get(&drive.Diff{
+			Commit: c,
+			Shard:  shard,
+		}); !ok {
+			return nil, fmt.Errorf("commit %s/%s not found", c.Repo.Name, c.Id)
+		}
+	}
+	diffInfo := &drive.DiffInfo{
+		Diff: &drive.Diff{
+			Commit: commit,
+			Shard:  shard,
+		},
+		Started:      finished,
+		Finished:     finished,
+		ParentCommit: ours,
+		MergedCommit: theirs,
+		Appends:      make(map[string]*drive.Append),
+	}
+	base := d.mergeBase(ours, theirs, shard)
+	oursChanged := d.changedFiles(ours, base, shard)
+	var conflicts []string
+	for _, filePath := range d.changedFiles(theirs, base, shard) {
+		file := &pfs.File{
+			Commit: commit,
+			Path:   filePath,
+		}
+		theirBlockRefs, err := d.mergeBlockRefs(theirs, filePath, shard)
+		if err != nil {
+			return nil, err
+		}
+		ourBlockRefs, err := d.mergeBlockRefs(ours, filePath, shard)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case hasBlockRefsPrefix(theirBlockRefs, ourBlockRefs):
+			// theirs only appended to ours, which covers files ours didn't change
+			if len(theirBlockRefs) > len(ourBlockRefs) {
+				d.appendBlockRefs(diffInfo, file, shard, theirBlockRefs[len(ourBlockRefs):])
+			}
+		case hasBlockRefsPrefix(ourBlockRefs, theirBlockRefs):
+			// ours already has all of theirs
+		case !containsString(oursChanged, filePath):
+			// theirs replaced the file in an earlier merge
+			d.replaceBlockRefs(diffInfo, file, shard, theirBlockRefs)
+		case strategy == pfs.MergeStrategy_MERGE_STRATEGY_OURS:
+		case strategy == pfs.MergeStrategy_MERGE_STRATEGY_THEIRS:
+			d.replaceBlockRefs(diffInfo, file, shard, theirBlockRefs)
+		case strategy == pfs.MergeStrategy_MERGE_STRATEGY_UNION:
+			var baseBlockRefs []*drive.BlockRef
+			if base != nil {
+				if baseBlockRefs, err = d.mergeBlockRefs(base, filePath, shard); err != nil {
+					return nil, err
+				}
+			}
+			if !hasBlockRefsPrefix(theirBlockRefs, baseBlockRefs) {
+				conflicts = append(conflicts, filePath)
+				continue
+			}
+			d.appendBlockRefs(diffInfo, file, shard, theirBlockRefs[len(baseBlockRefs):])
+		default:
+			conflicts = append(conflicts, filePath)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("conflict: %s changed in both %s/%s and %s/%s", strings.Join(conflicts, ", "), ours.Repo.Name, ours.Id, theirs.Repo.Name, theirs.Id)
+	}
+	return diffInfo, nil
+}
+
+// mergeBase returns the nearest commit which both ours and theirs descend
+// from, or nil if they have none, d.lock must be held.
+func (d *driver) mergeBase(ours *pfs.Commit, theirs *pfs.Commit, shard uint64) *pfs.Commit {
+	ourAncestors := make(map[string]bool)
+	d.walkAncestors(ours, shard, func(commit *pfs.Commit) bool {
+		ourAncestors[commit.Id] = true
+		return true
+	})
+	var result *pfs.Commit
+	d.walkAncestors(theirs, shard, func(commit *pfs.Commit) bool {
+		if result == nil && ourAncestors[commit.Id] {
+			result = commit
+		}
+		return result == nil
+	})
+	return result
+}
+
+// changedFiles returns the paths of the files appended to by tip and its
+// ancestors which aren't ancestors of base, sorted, d.lock must be held.
+func (d *driver) changedFiles(tip *pfs.Commit, base *pfs.Commit, shard uint64) []string {
+	baseAncestors := make(map[string]bool)
+	if base != nil {
+		d.walkAncestors(base, shard, func(commit *pfs.Commit) bool {
+			baseAncestors[commit.Id] = true
+			return true
+		})
+	}
+	changed := make(map[string]bool)
+	d.walkAncestors(tip, shard, func(commit *pfs.Commit) bool {
+		if baseAncestors[commit.Id] {
+			return false
+		}
+		diffInfo, _ := d.finished.get(&drive.Diff{
+			Commit: commit,
+			Shard:  shard,
+		})
+		for filePath, _append := range diffInfo.Appends {
+			if len(_append.BlockRefs) > 0 {
+				changed[filePath] = true
+			}
+		}
+		return true
+	})
+	var result []string
+	for filePath := range changed {
+		result = append(result, filePath)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// walkAncestors calls f on commit and its finished ancestors, nearest first,
+// following the commits merged into merge commits as well as parents. The
+// ancestors of a commit f returns false for aren't walked. d.lock must be
+// held.
+func (d *driver) walkAncestors(commit *pfs.Commit, shard uint64, f func(*pfs.Commit) bool) {
+	seen := make(map[string]bool)
+	queue := []*pfs.Commit{commit}
+	for len(queue) > 0 {
+		commit := queue[0]
+		queue = queue[1:]
+		if seen[commit.Id] {
+			continue
+		}
+		seen[commit.Id] = true
+		diffInfo, ok := d.finished.get(&drive.Diff{
+			Commit: commit,
+			Shard:  shard,
+		})
+		if !ok || !f(commit) {
+			continue
+		}
+		for _, parent := range []*pfs.Commit{diffInfo.ParentCommit, diffInfo.MergedCommit} {
+			if parent != nil {
+				queue = append(queue, parent)
+			}
+		}
+	}
+}
+
+// mergeBlockRefs returns the blocks of the file at filePath in commit, nil if
+// there's no such file, d.lock must be held.
+func (d *driver) mergeBlockRefs(commit *pfs.Commit, filePath string, shard uint64) ([]*drive.BlockRef, error) {
+	_, blockRefs, err := d.inspectFile(pfsutil.NewFile(commit.Repo.Name, commit.Id, filePath), nil, shard)
+	if err == pfs.ErrFileNotFound {
+		return nil, nil
+	}
+	return blockRefs, err
+}
+
+// replaceBlockRefs makes blockRefs the whole of dst in diffInfo rather than
+// appending them to what dst has in diffInfo's parent, d.lock must be held.
+func (d *driver) replaceBlockRefs(diffInfo *drive.DiffInfo, dst *pfs.File, dstShard uint64, blockRefs []*drive.BlockRef) {
+	d.appendBlockRefs(diffInfo, dst, dstShard, blockRefs)
+	diffInfo.Appends[path.Clean(dst.Path)].LastRef = nil
+}
+
+// hasBlockRefsPrefix returns true if blockRefs starts with prefix.
+func hasBlockRefsPrefix(blockRefs []*drive.BlockRef, prefix []*drive.BlockRef) bool {
+	if len(prefix) > len(blockRefs) {
+		return false
+	}
+	for i, blockRef := range prefix {
+		if blockRef.Block.Hash != blockRefs[i].Block.Hash ||
+			blockRef.Range.Lower != blockRefs[i].Range.Lower ||
+			blockRef.Range.Upper != blockRefs[i].Range.Upper {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, candidate := range values {
+		if candidate == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExpectedParent returns a conflict error unless expectedParent is the
+// parent of the started commit and has no finished children, d.lock must be
+// held.
+func (d *driver) checkExpectedParent(commit *pfs.Commit, expectedParent *pfs.Commit, shards map[uint64]bool) error {
+	for shard := range shards {
+		diffInfo, ok := d.started.get(&drive.Diff{
+			Commit: commit,
+			Shard:  shard,
+		})
+		if !ok {
+			return fmt.Errorf("commit %s/%s not found", commit.Repo.Name, commit.Id)
+		}
+		if diffInfo.ParentCommit == nil || diffInfo.ParentCommit.Repo.Name != expectedParent.Repo.Name ||
+			diffInfo.ParentCommit.Id != expectedParent.Id {
+			return fmt.Errorf("conflict: the parent of commit %s/%s isn't %s/%s", commit.Repo.Name, commit.Id, expectedParent.Repo.Name, expectedParent.Id)
+		}
+	}
+	return d.checkNoFinishedChild(expectedParent, shards)
+}
+
+// checkNoFinishedChild returns a conflict error if commit has a finished
+// child, d.lock must be held.
+func (d *driver) checkNoFinishedChild(commit *pfs.Commit, shards map[uint64]bool) error {
+	for shard := range shards {
+		// a commit becomes internal once one of its children finishes
+		if _, ok := d.internals.get(&drive.Diff{
+			Commit: commit,
+			Shard:  shard,
+		}); ok {
+			return fmt.Errorf("conflict: commit %s/%s already has a finished child", commit.Repo.Name, commit.Id)
+		}
+	}
+	return nil
+}
+
+// transaction is the commits started in a transaction, it's prepared once
+// its diffs are finished and persisted and committed once every server has
+// prepared it.
+type transaction struct {
+	commits   []*pfs.Commit
+	prepared  bool
+	committed bool
+}
+
+// addTransactionCommit adds commit to transactionID and returns the
+// transaction, d.lock must be held.
+func (d *driver) addTransactionCommit(transactionID string, commit *pfs.Commit) *transaction {
+	t, ok := d.transactions[transactionID]
+	if !ok {
+		t = &transaction{}
+		d.transactions[transactionID] = t
+	}
+	for _, transactionCommit := range t.commits {
+		if transactionCommit.Repo.Name == commit.Repo.Name && transactionCommit.Id == commit.Id {
+			return t
+		}
+	}
+	t.commits = append(t.commits, commit)
+	return t
+}
+
+// transactionDiffInfos returns the started diffs of t's commits in shards,
+// d.lock must be held.
+func (d *driver) transactionDiffInfos(t *transaction, shards map[uint64]bool) []*drive.DiffInfo {
+	var result []*drive.DiffInfo
+	for _, commit := range t.commits {
+		for shard := range shards {
+			if diffInfo, ok := d.started.get(&drive.Diff{
+				Commit: commit,
+				Shard:  shard,
+			}); ok {
+				result = append(result, diffInfo)
+			}
+		}
+	}
+	return result
+}
+
+func (d *driver) unfenceTransaction(t *transaction, shards map[uint64]bool) {
+	for _, commit := range t.commits {
+		d.fences.unfence(commit, shards)
+	}
+}
+
+// transactionReleased returns true if transactionID has been released in
+// shards, which makes finishing it again a no-op. It's only called for
+// transactions we don't know of, which are rare, so it scans rather than
+// keeping every transaction ever released. d.lock must be held.
+func (d *driver) transactionReleased(transactionID string, shards map[uint64]bool) bool {
+	for _, shardMap := range d.finished {
+		for shard := range shards {
+			for _, diffInfo := range shardMap[shard] {
+				if diffInfo.Transaction == transactionID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// commitTransaction returns the transaction commit was started in, "" if it
+// wasn't, d.lock must be held.
+func (d *driver) commitTransaction(commit *pfs.Commit) string {
+	for transactionID, t := range d.transactions {
+		for _, transactionCommit := range t.commits {
+			if transactionCommit.Repo.Name == commit.Repo.Name && transactionCommit.Id == commit.Id {
+				return transactionID
+			}
+		}
+	}
+	return ""
+}
+
+func (d *driver) InspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs.CommitInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.inspectCommit(d.resolveCommit(commit), shards)
+}
+
+func (d *driver) ListCommit(repos []*pfs.Repo, fromCommit []*pfs.Commit, shards map[uint64]bool) ([]*pfs.CommitInfo, error) {
+	repoSet := make(map[string]bool)
+	for _, repo := range repos {
+		repoSet[repo.Name] = true
+	}
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	breakCommitIds := make(map[string]bool)
+	for _, commit := range fromCommit {
+		if !repoSet[commit.Repo.Name] {
+			return nil, fmt.Errorf("Commit %s/%s is from a repo that isn't being listed.", commit.Repo.Name, commit.Id)
+		}
+		breakCommitIds[d.resolveCommit(commit).Id] = true
+	}
+	var result []*pfs.CommitInfo
+	for _, repo := range repos {
+		for shard := range shards {
+			_, ok := d.finished[repo.Name]
+			if !ok || d.hidden(repo.Name) {
+				return nil, fmt.Errorf("repo %s not found", repo.Name)
+			}
+			for commitID := range d.leaves[repo.Name][shard] {
+				commit := &pfs.Commit{
+					Repo: repo,
+					Id:   commitID,
+				}
+				for commit != nil && !breakCommitIds[commit.Id] {
+					// we add this commit to breakCommitIds so we won't see it twice
+					breakCommitIds[commit.Id] = true
+					commitInfo, err := d.inspectCommit(commit, shards)
+					if err != nil {
+						return nil, err
+					}
+					result = append(result, commitInfo)
+					commit = commitInfo.ParentCommit
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *driver) DeleteCommit(commit *pfs.Commit, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	if commit.Id == "" {
+		return fmt.Errorf("commit id must be set")
+	}
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		if d.hidden(commit.Repo.Name) {
+			return fmt.Errorf("repo %s not found", commit.Repo.Name)
+		}
+		commit = d.resolveCommit(commit)
+		for shard := range shards {
+			diff := &drive.Diff{
+				Commit: commit,
+				Shard:  shard,
+			}
+			if _, ok := d.trash.get(diff); ok {
+				// a retry of a delete which failed on another shard
+				continue
+			}
+			if _, ok := d.started.get(diff); ok {
+				return fmt.Errorf("commit %s/%s isn't finished", commit.Repo.Name, commit.Id)
+			}
+			diffInfo, ok := d.finished.get(diff)
+			if !ok {
+				return fmt.Errorf("commit %s/%s not found", commit.Repo.Name, commit.Id)
+			}
+			if _, ok := d.leaves.get(diff); !ok {
+				return fmt.Errorf("commit %s/%s has children, only commits without children can be deleted", commit.Repo.Name, commit.Id)
+			}
+			trashedDiffInfo := *diffInfo
+			trashedDiffInfo.Trashed = deleted
+			diffInfos = append(diffInfos, &trashedDiffInfo)
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	if err := d.createDiffs(diffInfos); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, diffInfo := range diffInfos {
+		d.finished.pop(diffInfo.Diff)
+		if err := d.removeLeaf(diffInfo); err != nil {
+			return err
+		}
+		if err := d.trash.insert(diffInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *driver) RestoreCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		if d.hidden(commit.Repo.Name) {
+			return fmt.Errorf("repo %s not found", commit.Repo.Name)
+		}
+		commit = d.resolveCommit(commit)
+		for shard := range shards {
+			diff := &drive.Diff{
+				Commit: commit,
+				Shard:  shard,
+			}
+			diffInfo, ok := d.trash.get(diff)
+			if !ok {
+				if _, ok := d.finished.get(diff); ok {
+					// a retry of a restore which failed on another shard
+					continue
+				}
+				return fmt.Errorf("commit %s/%s isn't in the trash", commit.Repo.Name, commit.Id)
+			}
+			if diffInfo.ParentCommit != nil {
+				if _, ok := d.trash.get(&drive.Diff{
+					Commit: diffInfo.ParentCommit,
+					Shard:  shard,
+				}); ok {
+					return fmt.Errorf("commit %s/%s's parent %s is in the trash, restore it first", commit.Repo.Name, commit.Id, diffInfo.ParentCommit.Id)
+				}
+			}
+			restoredDiffInfo := *diffInfo
+			restoredDiffInfo.Trashed = nil
+			diffInfos = append(diffInfos, &restoredDiffInfo)
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	if err := d.createDiffs(diffInfos); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, diffInfo := range diffInfos {
+		d.trash.pop(diffInfo.Diff)
+		if err := d.finished.insert(diffInfo); err != nil {
+			return err
+		}
+		if err := d.insertLeaf(diffInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *driver) TagCommit(commit *pfs.Commit, tag string, shards map[uint64]bool) error {
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		if d.hidden(commit.Repo.Name) {
+			return fmt.Errorf("repo %s not found", commit.Repo.Name)
+		}
+		commit = d.resolveCommit(commit)
+		if tagged, ok := d.tags[commit.Repo.Name][tag]; ok && tagged.Id != commit.Id {
+			return fmt.Errorf("tag %s already refers to commit %s/%s", tag, tagged.Repo.Name, tagged.Id)
+		}
+		for shard := range shards {
+			diff := &drive.Diff{
+				Commit: commit,
+				Shard:  shard,
+			}
+			if _, ok := d.started.get(diff); ok {
+				return fmt.Errorf("commit %s/%s isn't finished", commit.Repo.Name, commit.Id)
+			}
+			diffInfo, ok := d.finished.get(diff)
+			if !ok {
+				return fmt.Errorf("commit %s/%s not found", commit.Repo.Name, commit.Id)
+			}
+			if _, ok := d.finished.get(&drive.Diff{
+				Commit: &pfs.Commit{Repo: commit.Repo, Id: tag},
+				Shard:  shard,
+			}); ok {
+				return fmt.Errorf("tag %s is the id of a commit", tag)
+			}
+			if hasTag(diffInfo, tag) {
+				// a retry of a tag which failed on another shard
+				continue
+			}
+			taggedDiffInfo := *diffInfo
+			taggedDiffInfo.Tags = append(append([]string(nil), diffInfo.Tags...), tag)
+			diffInfos = append(diffInfos, &taggedDiffInfo)
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	if err := d.createDiffs(diffInfos); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, diffInfo := range diffInfos {
+		// the original is replaced rather than modified because it may
+		// still be being written to the drive
+		for _, diffMap := range []diffMap{d.finished, d.leaves, d.internals} {
+			if _, ok := diffMap.get(diffInfo.Diff); ok {
+				diffMap.pop(diffInfo.Diff)
+				if err := diffMap.insert(diffInfo); err != nil {
+					return err
+				}
+			}
+		}
+		d.addTags(diffInfo)
+	}
+	return nil
+}
+
+func (d *driver) ListTag(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.TagInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if _, ok := d.finished[repo.Name]; !ok || d.hidden(repo.Name) {
+		return nil, fmt.Errorf("repo %s not found", repo.Name)
+	}
+	var result []*pfs.TagInfo
+	for tag, commit := range d.tags[repo.Name] {
+		// tags of commits in the trash are kept so they can't be reused, but
+		// they aren't listed
+		for shard := range shards {
+			if _, ok := d.finished.get(&drive.Diff{
+				Commit: commit,
+				Shard:  shard,
+			}); ok {
+				result = append(result, &pfs.TagInfo{
+					Tag:    tag,
+					Commit: commit,
+				})
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *driver) TrashRepo(repo *pfs.Repo, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if _, ok := d.finished[repo.Name]; !ok || d.deleting[repo.Name] {
+			return fmt.Errorf("repo %s not found", repo.Name)
+		}
+		if trashed, ok := d.trashedRepos[repo.Name]; ok {
+			// a retry of a delete which failed on another server, we keep
+			// the original time so the repo doesn't linger in the trash
+			deleted = trashed
+		}
+		// the repo is hidden straight away, the mark on the diffs that
+		// create it keeps it hidden across restarts
+		d.trashedRepos[repo.Name] = deleted
+		for shard := range shards {
+			if diffInfo, ok := d.finished.get(&drive.Diff{
+				Commit: &pfs.Commit{Repo: repo},
+				Shard:  shard,
+			}); ok {
+				trashedDiffInfo := *diffInfo
+				trashedDiffInfo.Trashed = deleted
+				diffInfos = append(diffInfos, &trashedDiffInfo)
+			}
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	return d.createDiffs(diffInfos)
+}
+
+func (d *driver) RestoreRepo(repo *pfs.Repo, shards map[uint64]bool) error {
+	var diffInfos []*drive.DiffInfo
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		if _, ok := d.finished[repo.Name]; !ok || d.deleting[repo.Name] {
+			return fmt.Errorf("repo %s isn't in the trash", repo.Name)
+		}
+		for shard := range shards {
+			if diffInfo, ok := d.finished.get(&drive.Diff{
+				Commit: &pfs.Commit{Repo: repo},
+				Shard:  shard,
+			}); ok {
+				restoredDiffInfo := *diffInfo
+				restoredDiffInfo.Trashed = nil
+				diffInfos = append(diffInfos, &restoredDiffInfo)
+			}
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	// a repo which isn't in the trash is rewritten anyway, it may be a
+	// retry of a restore which failed after its diffs were written
+	if err := d.createDiffs(diffInfos); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.trashedRepos, repo.Name)
+	return nil
+}
+
+func (d *driver) ListTrash(shards map[uint64]bool) ([]*pfs.TrashInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	var result []*pfs.TrashInfo
+	for repoName, deleted := range d.trashedRepos {
+		if d.deleting[repoName] {
+			continue
+		}
+		result = append(result, &pfs.TrashInfo{
+			Repo:    &pfs.Repo{Name: repoName},
+			Deleted: deleted,
+		})
+	}
+	// every commit has a diff in every shard so we only list each one once
+	commits := make(map[string]bool)
+	for repoName, shardMap := range d.trash {
+		if d.hidden(repoName) {
+			continue
+		}
+		for shard, commitMap := range shardMap {
+			if !shards[shard] {
+				continue
+			}
+			for commitID, diffInfo := range commitMap {
+				if commits[path.Join(repoName, commitID)] {
+					continue
+				}
+				commits[path.Join(repoName, commitID)] = true
+				result = append(result, &pfs.TrashInfo{
+					Repo:    diffInfo.Diff.Commit.Repo,
+					Commit:  diffInfo.Diff.Commit,
+					Deleted: diffInfo.Trashed,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *driver) EmptyTrash(before *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	var repos []*pfs.Repo
+	var diffInfos []*drive.DiffInfo
+	d.lock.RLock()
+	for repoName, deleted := range d.trashedRepos {
+		if trashedBefore(deleted, before) {
+			repos = append(repos, &pfs.Repo{Name: repoName})
+		}
+	}
+	for repoName, shardMap := range d.trash {
+		if d.trashedRepos[repoName] != nil {
+			// the whole repo goes at once
+			continue
+		}
+		for shard, commitMap := range shardMap {
+			if !shards[shard] {
+				continue
+			}
+			for _, diffInfo := range commitMap {
+				if trashedBefore(diffInfo.Trashed, before) {
+					diffInfos = append(diffInfos, diffInfo)
+				}
+			}
+		}
+	}
+	d.lock.RUnlock()
+	for _, repo := range repos {
+		shardDeletions, err := d.DeleteRepo(repo, shards)
+		if err != nil {
+			return err
+		}
+		for _, shardDeletion := range shardDeletions {
+			if shardDeletion.Error != "" {
+				return fmt.Errorf("error deleting repo %s from shard %d: %s", repo.Name, shardDeletion.Shard, shardDeletion.Error)
+			}
+		}
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var loopErr error
+	for _, diffInfo := range diffInfos {
+		diffInfo := diffInfo
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.driveClient.DeleteDiff(
+				context.Background(),
+				&drive.DeleteDiffRequest{Diff: diffInfo.Diff},
+			); err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				if loopErr == nil {
+					loopErr = err
+				}
+				return
+			}
+			d.lock.Lock()
+			defer d.lock.Unlock()
+			d.trash.pop(diffInfo.Diff)
+			for _, tag := range diffInfo.Tags {
+				delete(d.tags[diffInfo.Diff.Commit.Repo.Name], tag)
+			}
+		}()
+	}
+	wg.Wait()
+	return loopErr
+}
+
+func (d *driver) PutFile(file *pfs.File, shard uint64, offset int64, reader io.Reader) (retErr error) {
+	if err := d.fences.enter(file.Commit, shard); err != nil {
+		return err
+	}
+	defer d.fences.exit(file.Commit, shard)
+	d.lock.RLock()
+	diffInfo, ok := d.started.get(&drive.Diff{
+		Commit: file.Commit,
+		Shard:  shard,
+	})
+	d.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("commit %s/%s not found", file.Commit.Repo.Name, file.Commit.Id)
+	}
+	blockRefs, err := pfsutil.PutBlock(d.driveClient, reader)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	diffInfo, ok = d.started.get(&drive.Diff{
+		Commit: file.Commit,
+		Shard:  shard,
+	})
+	if !ok {
+		// This is a weird case since the commit existed above, it means someone
+		// deleted the commit while the above code was running
+		return fmt.Errorf("commit %s/%s not found", file.Commit.Repo.Name, file.Commit.Id)
+	}
+	addDirs(diffInfo, file)
+	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
+	if !ok {
+		_append = &drive.Append{}
+		if diffInfo.ParentCommit != nil {
+			_append.LastRef = d.lastRef(
+				pfsutil.NewFile(
+					diffInfo.ParentCommit.Repo.Name,
+					diffInfo.ParentCommit.Id,
+					file.Path,
+				),
+				shard,
+			)
+		}
+		diffInfo.Appends[path.Clean(file.Path)] = _append
+	}
+	_append.BlockRefs = append(_append.BlockRefs, blockRefs.BlockRef...)
+	for _, blockRef := range blockRefs.BlockRef {
+		diffInfo.SizeBytes += blockRef.Range.Upper - blockRef.Range.Lower
+	}
+	return nil
+}
+
+func (d *driver) MakeDirectory(file *pfs.File, shards map[uint64]bool) error {
+	return nil
+}
+
+func (d *driver) GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64, size int64, shard uint64) (io.ReadCloser, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	file = d.resolveFile(file)
+	fileInfo, blockRefs, err := d.inspectFile(file, filterShard, shard)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		return nil, fmt.Errorf("file %s/%s/%s is directory", file.Commit.Repo.Name, file.Commit.Id, file.Path)
+	}
+	if _, ok := d.started.get(&drive.Diff{
+		Commit: file.Commit,
+		Shard:  shard,
+	}); !ok {
+		// finished commits can't change underneath us
+		return newFileReader(d.driveClient, blockRefs, offset, size), nil
+	}
+	if err := d.fences.enter(file.Commit, shard); err != nil {
+		return nil, err
+	}
+	return &fencedReader{
+		newFileReader(d.driveClient, blockRefs, offset, size),
+		func() { d.fences.exit(file.Commit, shard) },
+	}, nil
+}
+
+func (d *driver) InspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint64) (*pfs.FileInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	fileInfo, _, err := d.inspectFile(d.resolveFile(file), filterShard, shard)
+	return fileInfo, err
 }
 
-func (d *driver) GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64, size int64, shard uint64) (io.ReadCloser, error) {
+func (d *driver) InspectFileBlocks(file *pfs.File, filterShard *pfs.Shard, shard uint64) ([]*drive.BlockRef, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	file = d.resolveFile(file)
 	fileInfo, blockRefs, err := d.inspectFile(file, filterShard, shard)
 	if err != nil {
 		return nil, err
@@ -310,19 +1440,13 @@ func (d *driver) GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64, s
 	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
 		return nil, fmt.Errorf("file %s/%s/%s is directory", file.Commit.Repo.Name, file.Commit.Id, file.Path)
 	}
-	return newFileReader(d.driveClient, blockRefs, offset, size), nil
-}
-
-func (d *driver) InspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint64) (*pfs.FileInfo, error) {
-	d.lock.RLock()
-	defer d.lock.RUnlock()
-	fileInfo, _, err := d.inspectFile(file, filterShard, shard)
-	return fileInfo, err
+	return blockRefs, nil
 }
 
 func (d *driver) ListFile(file *pfs.File, filterShard *pfs.Shard, shard uint64) ([]*pfs.FileInfo, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	file = d.resolveFile(file)
 	fileInfo, _, err := d.inspectFile(file, filterShard, shard)
 	if err != nil {
 		return nil, err
@@ -345,11 +1469,152 @@ func (d *driver) DeleteFile(file *pfs.File, shard uint64) error {
 	return nil
 }
 
-func (d *driver) AddShard(shard uint64) error {
-	listDiffClient, err := d.driveClient.ListDiff(context.Background(), &drive.ListDiffRequest{Shard: shard})
+func (d *driver) CopyFile(src *pfs.File, srcShard uint64, dst *pfs.File, dstShard uint64) error {
+	if err := d.fences.enter(dst.Commit, dstShard); err != nil {
+		return err
+	}
+	defer d.fences.exit(dst.Commit, dstShard)
+	blockRefs, err := d.copyBlockRefs(src, srcShard)
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	diffInfo, ok := d.started.get(&drive.Diff{
+		Commit: dst.Commit,
+		Shard:  dstShard,
+	})
+	if !ok {
+		return fmt.Errorf("commit %s/%s not found", dst.Commit.Repo.Name, dst.Commit.Id)
+	}
+	d.appendBlockRefs(diffInfo, dst, dstShard, blockRefs)
+	return nil
+}
+
+func (d *driver) ConcatFiles(srcs []*pfs.File, srcShards []uint64, dst *pfs.File, dstShard uint64) error {
+	if err := d.fences.enter(dst.Commit, dstShard); err != nil {
+		return err
+	}
+	defer d.fences.exit(dst.Commit, dstShard)
+	var blockRefs []*drive.BlockRef
+	for i, src := range srcs {
+		srcBlockRefs, err := d.copyBlockRefs(src, srcShards[i])
+		if err != nil {
+			return err
+		}
+		blockRefs = append(blockRefs, srcBlockRefs...)
+	}
+	return d.createFile(dst, dstShard, blockRefs)
+}
+
+func (d *driver) PutBlocks(reader io.Reader) ([]*drive.BlockRef, error) {
+	blockRefs, err := pfsutil.PutBlock(d.driveClient, reader)
+	if err != nil {
+		return nil, err
+	}
+	return blockRefs.BlockRef, nil
+}
+
+func (d *driver) CreateFile(file *pfs.File, shard uint64, blockRefs []*drive.BlockRef) error {
+	if err := d.fences.enter(file.Commit, shard); err != nil {
+		return err
+	}
+	defer d.fences.exit(file.Commit, shard)
+	return d.createFile(file, shard, blockRefs)
+}
+
+// createFile creates file from blockRefs, the caller must be in file's
+// commit's fence.
+func (d *driver) createFile(file *pfs.File, shard uint64, blockRefs []*drive.BlockRef) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	diffInfo, ok := d.started.get(&drive.Diff{
+		Commit: file.Commit,
+		Shard:  shard,
+	})
+	if !ok {
+		return fmt.Errorf("commit %s/%s not found", file.Commit.Repo.Name, file.Commit.Id)
+	}
+	// file is checked for under the same lock its blocks are added with so
+	// concurrent creations of it can't both succeed
+	_, appended := diffInfo.Appends[path.Clean(file.Path)]
+	if appended || (diffInfo.ParentCommit != nil && d.lastRef(pfsutil.NewFile(
+		diffInfo.ParentCommit.Repo.Name,
+		diffInfo.ParentCommit.Id,
+		file.Path,
+	), shard) != nil) {
+		return fmt.Errorf("file %s/%s/%s already exists", file.Commit.Repo.Name, file.Commit.Id, file.Path)
+	}
+	d.appendBlockRefs(diffInfo, file, shard, blockRefs)
+	return nil
+}
+
+// copyBlockRefs returns the blocks of src, which must be a regular file in a
+// finished commit. srcShard need not be held locally.
+func (d *driver) copyBlockRefs(src *pfs.File, srcShard uint64) ([]*drive.BlockRef, error) {
+	d.lock.RLock()
+	src = d.resolveFile(src)
+	_, local := d.finished.get(&drive.Diff{
+		Commit: src.Commit,
+		Shard:  srcShard,
+	})
+	getDiffInfo := d.getDiffInfo
+	if !local {
+		// src lives on another shard, read its finished diffs from storage
+		getDiffInfo = d.getPersistedDiffInfo
+		d.lock.RUnlock()
+	}
+	fileInfo, blockRefs, err := d.inspectFileWith(src, nil, srcShard, getDiffInfo)
+	if local {
+		d.lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		return nil, fmt.Errorf("file %s/%s/%s is directory", src.Commit.Repo.Name, src.Commit.Id, src.Path)
+	}
+	return blockRefs, nil
+}
+
+// appendBlockRefs appends blockRefs to dst in diffInfo, d.lock must be held.
+func (d *driver) appendBlockRefs(diffInfo *drive.DiffInfo, dst *pfs.File, dstShard uint64, blockRefs []*drive.BlockRef) {
+	addDirs(diffInfo, dst)
+	_append, ok := diffInfo.Appends[path.Clean(dst.Path)]
+	if !ok {
+		_append = &drive.Append{}
+		if diffInfo.ParentCommit != nil {
+			_append.LastRef = d.lastRef(
+				pfsutil.NewFile(
+					diffInfo.ParentCommit.Repo.Name,
+					diffInfo.ParentCommit.Id,
+					dst.Path,
+				),
+				dstShard,
+			)
+		}
+		diffInfo.Appends[path.Clean(dst.Path)] = _append
+	}
+	_append.BlockRefs = append(_append.BlockRefs, blockRefs...)
+	for _, blockRef := range blockRefs {
+		diffInfo.SizeBytes += blockRef.Range.Upper - blockRef.Range.Lower
+	}
+}
+
+func (d *driver) AddShard(shard uint64, transfer pkgshard.Transfer) error {
+	// we only list the headers, the diffs themselves can be large so they're
+	// pulled one at a time in resumable chunks
+	listDiffClient, err := d.driveClient.ListDiff(
+		context.Background(),
+		&drive.ListDiffRequest{
+			Shard:       shard,
+			HeadersOnly: true,
+		},
+	)
 	if err != nil {
 		return err
 	}
+	var diffs []*drive.Diff
 	for {
 		diffInfo, err := listDiffClient.Recv()
 		if err != nil && err != io.EOF {
@@ -358,42 +1623,163 @@ func (d *driver) AddShard(shard uint64) error {
 		if err == io.EOF {
 			break
 		}
-		func() error {
+		diffs = append(diffs, diffInfo.Diff)
+	}
+	transfer.Progress(0, uint64(len(diffs)))
+	for i, diff := range diffs {
+		select {
+		case <-transfer.Cancel():
+			return pkgshard.ErrTransferCancelled
+		default:
+		}
+		diffInfo, err := d.pullDiff(diff)
+		if err != nil {
+			return err
+		}
+		if err := func() error {
 			d.lock.Lock()
-			defer d.lock.Lock()
+			defer d.lock.Unlock()
 			if _, ok := d.finished[diffInfo.Diff.Commit.Repo.Name]; !ok {
 				d.finished[diffInfo.Diff.Commit.Repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
 				d.started[diffInfo.Diff.Commit.Repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
+				d.leaves[diffInfo.Diff.Commit.Repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
+				d.trash[diffInfo.Diff.Commit.Repo.Name] = make(map[uint64]map[string]*drive.DiffInfo)
+			}
+			if diffInfo.RepoDeleting {
+				// we crashed part way through deleting this repo
+				d.deleting[diffInfo.Diff.Commit.Repo.Name] = true
+			}
+			if diffInfo.TransactionPrepared && diffInfo.TransactionCommitted {
+				// we crashed after every server committed the transaction
+				// but before it was released, it's as good as released
+				diffInfo.TransactionPrepared = false
+			}
+			if diffInfo.Finished == nil || diffInfo.TransactionPrepared {
+				// this diff was flushed before it was finished, or its
+				// transaction hasn't been committed yet
+				if diffInfo.Transaction != "" {
+					t := d.addTransactionCommit(diffInfo.Transaction, diffInfo.Diff.Commit)
+					if diffInfo.TransactionPrepared {
+						t.prepared = true
+						d.fences.fence(diffInfo.Diff.Commit, map[uint64]bool{shard: true}, false)
+					}
+				}
+				return d.started.insert(diffInfo)
+			}
+			d.addTags(diffInfo)
+			if diffInfo.Trashed != nil {
+				if diffInfo.Diff.Commit.Id != "" {
+					return d.trash.insert(diffInfo)
+				}
+				d.trashedRepos[diffInfo.Diff.Commit.Repo.Name] = diffInfo.Trashed
 			}
 			if err := d.finished.insert(diffInfo); err != nil {
 				return err
 			}
 			return d.insertLeaf(diffInfo)
-		}()
+		}(); err != nil {
+			return err
+		}
+		transfer.Progress(uint64(i+1), uint64(len(diffs)))
 	}
 	return nil
 }
 
+// pullDiff fetches a diff in chunks, an interrupted transfer is resumed
+// from where it left off rather than restarted.
+func (d *driver) pullDiff(diff *drive.Diff) (*drive.DiffInfo, error) {
+	var data []byte
+	var chunk *drive.DiffChunk
+	var err error
+	for attempt := 0; attempt < pullDiffAttempts; attempt++ {
+		if chunk, err = d.pullDiffChunks(diff, &data); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha512.Sum512(data)
+	if base64.URLEncoding.EncodeToString(checksum[:]) != chunk.Checksum {
+		return nil, fmt.Errorf("checksum mismatch for diff %s/%s/%d", diff.Commit.Repo.Name, diff.Commit.Id, diff.Shard)
+	}
+	diffInfo := &drive.DiffInfo{}
+	if err := proto.Unmarshal(data, diffInfo); err != nil {
+		return nil, err
+	}
+	return diffInfo, nil
+}
+
+// pullDiffChunks appends the chunks of diff past len(*data) to data, it
+// returns the last chunk received.
+func (d *driver) pullDiffChunks(diff *drive.Diff, data *[]byte) (*drive.DiffChunk, error) {
+	pullDiffClient, err := d.driveClient.PullDiff(
+		context.Background(),
+		&drive.PullDiffRequest{
+			Diff:        diff,
+			OffsetBytes: uint64(len(*data)),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	var last *drive.DiffChunk
+	for {
+		chunk, err := pullDiffClient.Recv()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF {
+			break
+		}
+		d.replicationLimiter.Wait(len(chunk.Value))
+		*data = append(*data, chunk.Value...)
+		last = chunk
+	}
+	if last == nil || uint64(len(*data)) != last.SizeBytes {
+		return nil, fmt.Errorf("transfer of diff %s/%s/%d ended early", diff.Commit.Repo.Name, diff.Commit.Id, diff.Shard)
+	}
+	return last, nil
+}
+
 func (d *driver) DeleteShard(shard uint64) error {
 	d.lock.Lock()
-	defer d.lock.Lock()
+	defer d.lock.Unlock()
 	for _, shardMap := range d.finished {
 		delete(shardMap, shard)
 	}
 	for _, shardMap := range d.started {
 		delete(shardMap, shard)
 	}
+	for _, shardMap := range d.trash {
+		delete(shardMap, shard)
+	}
 	return nil
 }
 
+func (d *driver) Flush() error {
+	var diffInfos []*drive.DiffInfo
+	d.lock.RLock()
+	for _, shardMap := range d.started {
+		for _, commitMap := range shardMap {
+			for _, diffInfo := range commitMap {
+				diffInfos = append(diffInfos, diffInfo)
+			}
+		}
+	}
+	d.lock.RUnlock()
+	return d.createDiffs(diffInfos)
+}
+
 func (d *driver) inspectRepo(repo *pfs.Repo, shards map[uint64]bool) (*pfs.RepoInfo, error) {
 	result := &pfs.RepoInfo{
 		Repo: repo,
 	}
 	_, ok := d.finished[repo.Name]
-	if !ok {
+	if !ok || d.hidden(repo.Name) {
 		return nil, fmt.Errorf("repo %s not found", repo.Name)
 	}
+	commits := make(map[string]bool)
 	for shard := range shards {
 		diffInfos, ok := d.finished[repo.Name][shard]
 		if !ok {
@@ -403,14 +1789,36 @@ func (d *driver) inspectRepo(repo *pfs.Repo, shards map[uint64]bool) (*pfs.RepoI
 			diffInfo := diffInfo
 			if diffInfo.Diff.Commit.Id == "" {
 				result.Created = diffInfo.Finished
+				result.Ttl = diffInfo.RepoTtl
+			} else {
+				// every commit has a diff in every shard so we count them by id
+				commits[diffInfo.Diff.Commit.Id] = true
+				if result.LastCommitFinished == nil ||
+					prototime.TimestampToTime(diffInfo.Finished).After(prototime.TimestampToTime(result.LastCommitFinished)) {
+					result.LastCommit = diffInfo.Diff.Commit
+					result.LastCommitFinished = diffInfo.Finished
+				}
 			}
 			result.SizeBytes += diffInfo.SizeBytes
 		}
 	}
+	result.CommitCount = uint64(len(commits))
+	if result.Ttl != nil {
+		lastActive := result.Created
+		if result.LastCommitFinished != nil {
+			lastActive = result.LastCommitFinished
+		}
+		result.Expires = prototime.TimeToTimestamp(
+			prototime.TimestampToTime(lastActive).Add(prototime.DurationFromProto(result.Ttl)),
+		)
+	}
 	return result, nil
 }
 
 func (d *driver) getDiffInfo(diff *drive.Diff) (_ *drive.DiffInfo, read bool, ok bool) {
+	if d.hidden(diff.Commit.Repo.Name) {
+		return nil, false, false
+	}
 	if diffInfo, ok := d.finished.get(diff); ok {
 		return diffInfo, true, true
 	}
@@ -420,7 +1828,18 @@ func (d *driver) getDiffInfo(diff *drive.Diff) (_ *drive.DiffInfo, read bool, ok
 	return nil, false, false
 }
 
+func (d *driver) getPersistedDiffInfo(diff *drive.Diff) (_ *drive.DiffInfo, read bool, ok bool) {
+	diffInfo, err := d.driveClient.InspectDiff(context.Background(), &drive.InspectDiffRequest{Diff: diff})
+	if err != nil || diffInfo.Finished == nil || diffInfo.TransactionPrepared {
+		return nil, false, false
+	}
+	return diffInfo, true, true
+}
+
 func (d *driver) inspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs.CommitInfo, error) {
+	if d.hidden(commit.Repo.Name) {
+		return nil, fmt.Errorf("commit %s/%s not found", commit.Repo.Name, commit.Id)
+	}
 	var commitInfos []*pfs.CommitInfo
 	for shard := range shards {
 		if diffInfo, ok := d.finished.get(&drive.Diff{
@@ -435,6 +1854,10 @@ func (d *driver) inspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs
 					Started:      diffInfo.Started,
 					Finished:     diffInfo.Finished,
 					SizeBytes:    diffInfo.SizeBytes,
+					FilesAdded:   diffInfo.FilesAdded,
+					SizeDelta:    diffInfo.SizeDelta,
+					Tags:         diffInfo.Tags,
+					MergedCommit: diffInfo.MergedCommit,
 				})
 		}
 		if diffInfo, ok := d.started.get(&drive.Diff{
@@ -470,12 +1893,21 @@ func filterBlockRefs(filterShard *pfs.Shard, blockRefs []*drive.BlockRef) []*dri
 }
 
 func (d *driver) inspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint64) (*pfs.FileInfo, []*drive.BlockRef, error) {
+	return d.inspectFileWith(file, filterShard, shard, d.getDiffInfo)
+}
+
+func (d *driver) inspectFileWith(
+	file *pfs.File,
+	filterShard *pfs.Shard,
+	shard uint64,
+	getDiffInfo func(*drive.Diff) (*drive.DiffInfo, bool, bool),
+) (*pfs.FileInfo, []*drive.BlockRef, error) {
 	fileInfo := &pfs.FileInfo{File: file}
 	var blockRefs []*drive.BlockRef
 	children := make(map[string]bool)
 	commit := file.Commit
 	for commit != nil {
-		diffInfo, _, ok := d.getDiffInfo(&drive.Diff{
+		diffInfo, _, ok := getDiffInfo(&drive.Diff{
 			Commit: commit,
 			Shard:  shard,
 		})
@@ -543,6 +1975,18 @@ func (d *driver) lastRef(file *pfs.File, shard uint64) *pfs.Commit {
 	return nil
 }
 
+// setDiffStats computes diffInfo's stats relative to its parent.
+func setDiffStats(diffInfo *drive.DiffInfo) {
+	for _, _append := range diffInfo.Appends {
+		if len(_append.BlockRefs) > 0 && _append.LastRef == nil {
+			diffInfo.FilesAdded++
+		}
+	}
+	// appends are the only change a diff can make, DeleteFile is a no-op,
+	// so the delta is just what was appended
+	diffInfo.SizeDelta = int64(diffInfo.SizeBytes)
+}
+
 func addDirs(diffInfo *drive.DiffInfo, child *pfs.File) {
 	childPath := child.Path
 	dirPath := path.Dir(childPath)
@@ -621,6 +2065,94 @@ func (r *fileReader) Close() error {
 	return nil
 }
 
+// fences tracks the reads and writes in flight against open commits so
+// that FinishCommit doesn't race them.
+type fences struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	active map[fenceKey]int
+	fenced map[fenceKey]bool
+}
+
+type fenceKey struct {
+	repo     string
+	commitID string
+	shard    uint64
+}
+
+func newFences() *fences {
+	f := &fences{
+		active: make(map[fenceKey]int),
+		fenced: make(map[fenceKey]bool),
+	}
+	f.cond = sync.NewCond(&f.lock)
+	return f
+}
+
+func newFenceKey(commit *pfs.Commit, shard uint64) fenceKey {
+	return fenceKey{commit.Repo.Name, commit.Id, shard}
+}
+
+// enter registers a read or write against commit, it fails if commit is
+// being finished.
+func (f *fences) enter(commit *pfs.Commit, shard uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	key := newFenceKey(commit, shard)
+	if f.fenced[key] {
+		return fmt.Errorf("commit %s/%s is being finished", commit.Repo.Name, commit.Id)
+	}
+	f.active[key]++
+	return nil
+}
+
+func (f *fences) exit(commit *pfs.Commit, shard uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	key := newFenceKey(commit, shard)
+	f.active[key]--
+	if f.active[key] <= 0 {
+		delete(f.active, key)
+		f.cond.Broadcast()
+	}
+}
+
+// fence rejects new reads and writes against commit, if wait is set it
+// blocks until the ones in flight have exited.
+func (f *fences) fence(commit *pfs.Commit, shards map[uint64]bool, wait bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for shard := range shards {
+		f.fenced[newFenceKey(commit, shard)] = true
+	}
+	if !wait {
+		return
+	}
+	for shard := range shards {
+		for f.active[newFenceKey(commit, shard)] > 0 {
+			f.cond.Wait()
+		}
+	}
+}
+
+func (f *fences) unfence(commit *pfs.Commit, shards map[uint64]bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for shard := range shards {
+		delete(f.fenced, newFenceKey(commit, shard))
+	}
+}
+
+type fencedReader struct {
+	io.ReadCloser
+	exit func()
+}
+
+func (r *fencedReader) Close() error {
+	r.exit()
+	return r.ReadCloser.Close()
+}
+
 type diffMap map[string]map[uint64]map[string]*drive.DiffInfo
 
 func (d diffMap) get(diff *drive.Diff) (_ *drive.DiffInfo, ok bool) {
@@ -668,6 +2200,167 @@ func (d diffMap) pop(diff *drive.Diff) *drive.DiffInfo {
 	return diffInfo
 }
 
+// eachShardDeletion calls f for every deletion in parallel, f's error is
+// recorded in its deletion.
+func eachShardDeletion(deletions []*pfs.ShardDeletion, f func(*pfs.ShardDeletion) error) {
+	var wg sync.WaitGroup
+	for _, deletion := range deletions {
+		deletion := deletion
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(deletion); err != nil {
+				deletion.Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func shardDeletionsOK(deletions []*pfs.ShardDeletion) bool {
+	for _, deletion := range deletions {
+		if deletion.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// hidden returns true if repoName is being deleted or is in the trash.
+func (d *driver) hidden(repoName string) bool {
+	return d.deleting[repoName] || d.trashedRepos[repoName] != nil
+}
+
+// createDiffs writes diffInfos to the drive in parallel.
+func (d *driver) createDiffs(diffInfos []*drive.DiffInfo) error {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var loopErr error
+	for _, diffInfo := range diffInfos {
+		diffInfo := diffInfo
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.driveClient.CreateDiff(context.Background(), diffInfo); err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				if loopErr == nil {
+					loopErr = err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return loopErr
+}
+
+// trashedBefore returns true if trashed is before before, a nil before is
+// after everything.
+func trashedBefore(trashed *google_protobuf.Timestamp, before *google_protobuf.Timestamp) bool {
+	return before == nil || prototime.TimestampToTime(trashed).Before(prototime.TimestampToTime(before))
+}
+
+// resolveCommit returns the commit which commit's id refers to, which is
+// commit itself unless its id is a tag or a time. d.lock must be held.
+func (d *driver) resolveCommit(commit *pfs.Commit) *pfs.Commit {
+	if commit == nil {
+		return nil
+	}
+	if tagged, ok := d.tags[commit.Repo.Name][commit.Id]; ok {
+		return tagged
+	}
+	if asOf, ok := pfs.ParseAsOfCommitID(commit.Id); ok {
+		if result := d.commitAsOf(commit.Repo, asOf); result != nil {
+			return result
+		}
+	}
+	return commit
+}
+
+// commitAsOf returns the last commit in repo finished at or before asOf, or
+// nil if there isn't one. Commits are finished at the same time in every
+// shard so every server agrees on the result.
+func (d *driver) commitAsOf(repo *pfs.Repo, asOf time.Time) *pfs.Commit {
+	var result *drive.DiffInfo
+	var resultFinished time.Time
+	for _, commitMap := range d.finished[repo.Name] {
+		for _, diffInfo := range commitMap {
+			if diffInfo.Diff.Commit.Id == "" {
+				// the diff that creates the repo
+				continue
+			}
+			finished := prototime.TimestampToTime(diffInfo.Finished)
+			if finished.After(asOf) {
+				continue
+			}
+			if result == nil || finished.After(resultFinished) ||
+				(finished.Equal(resultFinished) && diffInfo.Diff.Commit.Id > result.Diff.Commit.Id) {
+				result = diffInfo
+				resultFinished = finished
+			}
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	return result.Diff.Commit
+}
+
+// resolveFile is resolveCommit for a file's commit.
+func (d *driver) resolveFile(file *pfs.File) *pfs.File {
+	commit := d.resolveCommit(file.Commit)
+	if commit == file.Commit {
+		return file
+	}
+	return &pfs.File{
+		Commit: commit,
+		Path:   file.Path,
+	}
+}
+
+// addTags indexes diffInfo's tags so that they resolve to its commit.
+func (d *driver) addTags(diffInfo *drive.DiffInfo) {
+	commit := diffInfo.Diff.Commit
+	for _, tag := range diffInfo.Tags {
+		if _, ok := d.tags[commit.Repo.Name]; !ok {
+			d.tags[commit.Repo.Name] = make(map[string]*pfs.Commit)
+		}
+		d.tags[commit.Repo.Name][tag] = commit
+	}
+}
+
+func hasTag(diffInfo *drive.DiffInfo, tag string) bool {
+	for _, diffTag := range diffInfo.Tags {
+		if diffTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLeaf undoes insertLeaf for a leaf which has been removed from
+// finished, its parent becomes a leaf again if it has no other children.
+func (d *driver) removeLeaf(leaf *drive.DiffInfo) error {
+	d.leaves.pop(leaf.Diff)
+	if leaf.ParentCommit == nil {
+		return nil
+	}
+	for _, diffInfo := range d.finished[leaf.Diff.Commit.Repo.Name][leaf.Diff.Shard] {
+		if diffInfo.ParentCommit != nil && diffInfo.ParentCommit.Id == leaf.ParentCommit.Id {
+			return nil
+		}
+	}
+	parentDiff := &drive.Diff{
+		Commit: leaf.ParentCommit,
+		Shard:  leaf.Diff.Shard,
+	}
+	d.internals.pop(parentDiff)
+	if parentDiffInfo, ok := d.finished.get(parentDiff); ok {
+		return d.leaves.insert(parentDiffInfo)
+	}
+	return nil
+}
+
 func (d *driver) insertLeaf(leaf *drive.DiffInfo) error {
 	if _, ok := d.internals.get(leaf.Diff); ok {
 		// Not an actual leaf, we already know it's a leaf node
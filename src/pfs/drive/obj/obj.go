@@ -2,9 +2,20 @@ package obj
 
 import (
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
 )
 
-// NewDriver constructs a new Driver for obj.
-func NewDriver(driveClient drive.APIClient) (drive.Driver, error) {
-	return newDriver(driveClient)
+const (
+	// ReplicationRateKey is the runtime config key for the bytes per second a
+	// server may pull diffs at when it takes on shards, 0 is unlimited.
+	ReplicationRateKey = "replication_bytes_per_second"
+	// pullDiffAttempts is how many times a diff transfer is resumed before
+	// AddShard gives up.
+	pullDiffAttempts = 5
+)
+
+// NewDriver constructs a new Driver for obj, diffs pulled when shards are
+// added are limited by replicationLimiter.
+func NewDriver(driveClient drive.APIClient, replicationLimiter ratelimit.Limiter) (drive.Driver, error) {
+	return newDriver(driveClient, replicationLimiter)
 }
@@ -15,22 +15,31 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
 	"go.pedge.io/google-protobuf"
 	"go.pedge.io/proto/rpclog"
 	"go.pedge.io/proto/stream"
 	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
 	"golang.org/x/net/context"
 )
 
 type localAPIServer struct {
 	protorpclog.Logger
-	dir string
+	dir             string
+	pullDiffLimiter ratelimit.Limiter
+	// the drive server corrupt blocks are fetched from again, nil if there's
+	// none
+	replicaClient drive.APIClient
 }
 
-func newLocalAPIServer(dir string) (*localAPIServer, error) {
+func newLocalAPIServer(dir string, pullDiffLimiter ratelimit.Limiter, scrubPercent uint64, replicaClient drive.APIClient) (*localAPIServer, error) {
 	server := &localAPIServer{
-		Logger: protorpclog.NewLogger("pachyderm.pfs.drive.localAPIServer"),
-		dir:    dir,
+		Logger:          protorpclog.NewLogger("pachyderm.pfs.drive.localAPIServer"),
+		dir:             dir,
+		pullDiffLimiter: pullDiffLimiter,
+		replicaClient:   replicaClient,
 	}
 	if err := os.MkdirAll(server.tmpDir(), 0777); err != nil {
 		return nil, err
@@ -41,14 +50,24 @@ func newLocalAPIServer(dir string) (*localAPIServer, error) {
 	if err := os.MkdirAll(server.blockDir(), 0777); err != nil {
 		return nil, err
 	}
+	report, err := server.recoverState()
+	if err != nil {
+		return nil, err
+	}
+	protolog.Info(report)
+	if scrubPercent > 0 {
+		go server.scrub(scrubPercent)
+	}
 	return server, nil
 }
 
-func (s *localAPIServer) putOneBlock(scanner *bufio.Scanner) (result *drive.BlockRef, retErr error) {
-	hash := newHash()
+// putOneBlock writes lines from reader to a block until it's larger than
+// blockSize, eof is true if reader is exhausted.
+func (s *localAPIServer) putOneBlock(reader *bufio.Reader) (result *drive.BlockRef, eof bool, retErr error) {
+	hash := drive.NewHash()
 	tmp, err := ioutil.TempFile(s.tmpDir(), "block")
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer func() {
 		if err := tmp.Close(); err != nil && retErr == nil {
@@ -56,6 +75,10 @@ func (s *localAPIServer) putOneBlock(scanner *bufio.Scanner) (result *drive.Bloc
 			return
 		}
 		if result == nil {
+			// we failed partway through, the block is incomplete
+			if err := os.Remove(tmp.Name()); err != nil && retErr == nil {
+				retErr = err
+			}
 			return
 		}
 		// check if it's a new block
@@ -73,44 +96,32 @@ func (s *localAPIServer) putOneBlock(scanner *bufio.Scanner) (result *drive.Bloc
 			return
 		}
 	}()
-	var bytesWritten int
-	for scanner.Scan() {
-		// they take out the newline, put it back
-		bytes := append(scanner.Bytes(), '\n')
-		if _, err := hash.Write(bytes); err != nil {
-			return nil, err
-		}
-		if _, err := tmp.Write(bytes); err != nil {
-			return nil, err
-		}
-		bytesWritten += len(bytes)
-		if bytesWritten > blockSize {
-			break
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	bytesWritten, eof, err := drive.CopyBlock(io.MultiWriter(hash, tmp), reader, blockSize)
+	if err != nil {
+		return nil, false, err
 	}
 	return &drive.BlockRef{
-		Block: getBlock(hash),
+		Block: drive.NewBlock(hash),
 		Range: &drive.ByteRange{
 			Lower: 0,
 			Upper: uint64(bytesWritten),
 		},
-	}, nil
+	}, eof, nil
 }
 
 func (s *localAPIServer) PutBlock(putBlockServer drive.API_PutBlockServer) (retErr error) {
 	result := &drive.BlockRefs{}
 	defer func(start time.Time) { s.Log(nil, result, retErr, time.Since(start)) }(time.Now())
-	scanner := bufio.NewScanner(protostream.NewStreamingBytesReader(putBlockServer))
+	_, done := grpcutil.StartOp(putBlockServer.Context(), nil)
+	defer done()
+	reader := bufio.NewReaderSize(protostream.NewStreamingBytesReader(putBlockServer), grpcutil.StreamingChunkSize)
 	for {
-		blockRef, err := s.putOneBlock(scanner)
+		blockRef, eof, err := s.putOneBlock(reader)
 		if err != nil {
 			return err
 		}
 		result.BlockRef = append(result.BlockRef, blockRef)
-		if (blockRef.Range.Upper - blockRef.Range.Lower) < uint64(blockSize) {
+		if eof {
 			break
 		}
 	}
@@ -119,6 +130,8 @@ func (s *localAPIServer) PutBlock(putBlockServer drive.API_PutBlockServer) (retE
 
 func (s *localAPIServer) GetBlock(request *drive.GetBlockRequest, getBlockServer drive.API_GetBlockServer) (retErr error) {
 	defer func(start time.Time) { s.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	_, done := grpcutil.StartOp(getBlockServer.Context(), request)
+	defer done()
 	file, err := os.Open(s.blockPath(request.Block))
 	if err != nil {
 		return err
@@ -129,7 +142,7 @@ func (s *localAPIServer) GetBlock(request *drive.GetBlockRequest, getBlockServer
 		}
 	}()
 	reader := io.NewSectionReader(file, int64(request.OffsetBytes), int64(request.SizeBytes))
-	return protostream.WriteToStreamingBytesServer(reader, getBlockServer)
+	return grpcutil.WriteToStreamingBytesServer(reader, getBlockServer)
 }
 
 func (s *localAPIServer) InspectBlock(ctx context.Context, request *drive.InspectBlockRequest) (response *drive.BlockInfo, retErr error) {
@@ -154,14 +167,7 @@ func (s *localAPIServer) ListBlock(ctx context.Context, request *drive.ListBlock
 
 func (s *localAPIServer) CreateDiff(ctx context.Context, request *drive.DiffInfo) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
-	data, err := proto.Marshal(request)
-	if err != nil {
-		return nil, err
-	}
-	if err := os.MkdirAll(path.Dir(s.diffPath(request.Diff)), 0777); err != nil {
-		return nil, err
-	}
-	if err := ioutil.WriteFile(s.diffPath(request.Diff), data, 0666); err != nil {
+	if err := s.writeDiff(request); err != nil {
 		return nil, err
 	}
 	return google_protobuf.EmptyInstance, nil
@@ -174,7 +180,12 @@ func (s *localAPIServer) InspectDiff(ctx context.Context, request *drive.Inspect
 
 func (s *localAPIServer) ListDiff(request *drive.ListDiffRequest, listDiffServer drive.API_ListDiffServer) (retErr error) {
 	defer func(start time.Time) { s.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(listDiffServer.Context(), request)
+	defer done()
 	if err := filepath.Walk(s.diffDir(), func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		diff := s.pathToDiff(path)
 		if diff == nil {
 			// likely a directory
@@ -185,6 +196,9 @@ func (s *localAPIServer) ListDiff(request *drive.ListDiffRequest, listDiffServer
 			if err != nil {
 				return err
 			}
+			if request.HeadersOnly {
+				diffInfo.Appends = nil
+			}
 			if err := listDiffServer.Send(diffInfo); err != nil {
 				return err
 			}
@@ -196,9 +210,81 @@ func (s *localAPIServer) ListDiff(request *drive.ListDiffRequest, listDiffServer
 	return nil
 }
 
+func (s *localAPIServer) PullDiff(request *drive.PullDiffRequest, pullDiffServer drive.API_PullDiffServer) (retErr error) {
+	defer func(start time.Time) { s.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(pullDiffServer.Context(), request)
+	defer done()
+	data, err := ioutil.ReadFile(s.diffPath(request.Diff))
+	if err != nil {
+		return err
+	}
+	if request.OffsetBytes > uint64(len(data)) {
+		return fmt.Errorf("offset %d is past the end of diff %s/%s/%d", request.OffsetBytes,
+			request.Diff.Commit.Repo.Name, request.Diff.Commit.Id, request.Diff.Shard)
+	}
+	hash := drive.NewHash()
+	if _, err := hash.Write(data); err != nil {
+		return err
+	}
+	checksum := drive.NewBlock(hash).Hash
+	// always send at least one chunk so that empty diffs still get a checksum
+	for offset := request.OffsetBytes; offset == request.OffsetBytes || offset < uint64(len(data)); offset += diffChunkSize {
+		end := offset + diffChunkSize
+		if end > uint64(len(data)) {
+			end = uint64(len(data))
+		}
+		s.pullDiffLimiter.Wait(int(end - offset))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := pullDiffServer.Send(&drive.DiffChunk{
+			Value:     data[offset:end],
+			SizeBytes: uint64(len(data)),
+			Checksum:  checksum,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *localAPIServer) DeleteDiff(ctx context.Context, request *drive.DeleteDiffRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
-	return google_protobuf.EmptyInstance, os.Remove(s.diffPath(request.Diff))
+	// the replicas of a shard delete the same diffs so a diff that's already
+	// gone isn't an error
+	if err := os.Remove(s.diffPath(request.Diff)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+// writeDiff writes diffInfo to a temporary file and renames it into place so
+// that a crash never leaves a partial diff.
+func (s *localAPIServer) writeDiff(diffInfo *drive.DiffInfo) (retErr error) {
+	data, err := proto.Marshal(diffInfo)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(s.diffPath(diffInfo.Diff)), 0777); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.tmpDir(), "diff")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.diffPath(diffInfo.Diff))
 }
 
 func (s *localAPIServer) tmpDir() string {
@@ -213,6 +299,10 @@ func (s *localAPIServer) blockPath(block *drive.Block) string {
 	return filepath.Join(s.blockDir(), block.Hash)
 }
 
+func (s *localAPIServer) quarantineDir() string {
+	return filepath.Join(s.dir, "quarantine")
+}
+
 func (s *localAPIServer) diffDir() string {
 	return filepath.Join(s.dir, "diff")
 }
@@ -223,8 +313,12 @@ func (s *localAPIServer) diffPath(diff *drive.Diff) string {
 
 // pathToDiff parses a path as a diff, it returns nil when parse fails
 func (s *localAPIServer) pathToDiff(path string) *drive.Diff {
-	repoCommitShard := strings.Split(strings.TrimPrefix(path, s.diffDir()), "/")
-	if len(repoCommitShard) < 3 {
+	relPath, err := filepath.Rel(s.diffDir(), path)
+	if err != nil {
+		return nil
+	}
+	repoCommitShard := strings.Split(relPath, "/")
+	if len(repoCommitShard) != 3 {
 		return nil
 	}
 	shard, err := strconv.ParseUint(repoCommitShard[2], 10, 64)
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+const (
+	testLineSize = 1536 * 1024
+	testNumLines = 7
+)
+
+func TestPutBlockBig(t *testing.T) {
+	// files are much larger than the max message size and the blocks, and
+	// their lines are longer than any buffer, so nothing may hold a whole
+	// file or line in a single message
+	grpcutil.SetMaxMsgSize(2 * 1024 * 1024)
+	defer grpcutil.SetMaxMsgSize(grpcutil.DefaultMaxMsgSize)
+	defer func(size int) { blockSize = size }(blockSize)
+	blockSize = 4 * 1024 * 1024
+	apiClient, dir := getDriveClient(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	var value []byte
+	for i := 0; i < testNumLines; i++ {
+		value = append(value, bytes.Repeat([]byte{byte('a' + i)}, testLineSize)...)
+		if i != testNumLines-1 {
+			// the last line has no newline, it must not gain one
+			value = append(value, '\n')
+		}
+	}
+	blockRefs, err := pfsutil.PutBlock(apiClient, bytes.NewReader(value))
+	require.NoError(t, err)
+	require.True(t, len(blockRefs.BlockRef) > 1)
+
+	var result bytes.Buffer
+	for i, blockRef := range blockRefs.BlockRef {
+		size := blockRef.Range.Upper - blockRef.Range.Lower
+		if i != len(blockRefs.BlockRef)-1 {
+			require.True(t, size > uint64(blockSize))
+		}
+		reader, err := pfsutil.GetBlock(apiClient, blockRef.Block.Hash, blockRef.Range.Lower, size)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		if i != len(blockRefs.BlockRef)-1 {
+			// blocks are only broken after a whole line
+			require.Equal(t, byte('\n'), data[len(data)-1])
+		}
+		result.Write(data)
+	}
+	require.True(t, bytes.Equal(value, result.Bytes()))
+}
+
+func TestMaxMsgSize(t *testing.T) {
+	grpcutil.SetMaxMsgSize(1024)
+	defer grpcutil.SetMaxMsgSize(grpcutil.DefaultMaxMsgSize)
+	apiClient, dir := getDriveClient(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	_, err := pfsutil.PutBlock(apiClient, bytes.NewReader(bytes.Repeat([]byte{'a'}, 2048)))
+	require.True(t, err != nil && strings.Contains(err.Error(), "larger than the max"))
+}
+
+func TestHashBlocks(t *testing.T) {
+	apiClient, dir := getDriveClient(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	for _, value := range []string{"", "foo\n", "foo\nbar"} {
+		blockRefs, err := pfsutil.PutBlock(apiClient, strings.NewReader(value))
+		require.NoError(t, err)
+		hashedBlockRefs, err := drive.HashBlocks(strings.NewReader(value))
+		require.NoError(t, err)
+		require.Equal(t, blockRefs.BlockRef, hashedBlockRefs)
+	}
+}
+
+func getDriveClient(t *testing.T) (drive.APIClient, string) {
+	dir, err := ioutil.TempDir("", "pachyderm-drive")
+	require.NoError(t, err)
+	apiServer, err := NewLocalAPIServer(dir, ratelimit.NewLimiter(0), 0, nil)
+	require.NoError(t, err)
+	return getLocalDriveClient(t, apiServer), dir
+}
+
+func getLocalDriveClient(t *testing.T, apiServer drive.APIServer) drive.APIClient {
+	localServer := grpcutil.NewLocalServer()
+	drive.RegisterAPIServer(localServer.Server(), apiServer)
+	go func() {
+		_ = localServer.Serve()
+	}()
+	clientConn, err := localServer.Dial()
+	require.NoError(t, err)
+	return drive.NewAPIClient(clientConn)
+}
+
+func TestRecoverState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pachyderm-drive")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	server, err := newLocalAPIServer(dir, ratelimit.NewLimiter(0), 0, nil)
+	require.NoError(t, err)
+
+	blockRefs, err := pfsutil.PutBlock(getLocalDriveClient(t, server), strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	// a block that was being written when we crashed
+	require.NoError(t, ioutil.WriteFile(filepath.Join(server.tmpDir(), "block123"), []byte("fo"), 0666))
+	// a good diff, a diff whose header disagrees with its path, a diff that
+	// refers to a missing block and a diff which is garbage
+	goodDiff := testDiff("repo", "good", blockRefs.BlockRef[0])
+	require.NoError(t, server.writeDiff(goodDiff))
+	movedDiff := testDiff("repo", "moved", blockRefs.BlockRef[0])
+	require.NoError(t, server.writeDiff(movedDiff))
+	repairedDiff := &drive.Diff{Commit: goodDiff.Diff.Commit, Shard: 1}
+	require.NoError(t, os.Rename(server.diffPath(movedDiff.Diff), server.diffPath(repairedDiff)))
+	missingDiff := testDiff("repo", "missing", &drive.BlockRef{
+		Block: &drive.Block{Hash: "missing"},
+		Range: &drive.ByteRange{Upper: 4},
+	})
+	require.NoError(t, server.writeDiff(missingDiff))
+	garbageDiff := testDiff("repo", "garbage", blockRefs.BlockRef[0])
+	require.NoError(t, server.writeDiff(garbageDiff))
+	require.NoError(t, ioutil.WriteFile(server.diffPath(garbageDiff.Diff), []byte("garbage"), 0666))
+
+	report, err := server.recoverState()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), report.OrphanedBlocks)
+	require.Equal(t, uint64(2), report.OrphanedBytes)
+	require.Equal(t, uint64(4), report.DiffsChecked)
+	require.Equal(t, uint64(1), report.DiffsRepaired)
+	require.Equal(t, 2, len(report.Quarantined))
+	diffInfo, err := server.readDiff(repairedDiff)
+	require.NoError(t, err)
+	require.Equal(t, repairedDiff, diffInfo.Diff)
+	_, err = os.Stat(server.diffPath(missingDiff.Diff))
+	require.True(t, os.IsNotExist(err))
+
+	// everything is fixed so a second pass has nothing to do
+	report, err = server.recoverState()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), report.DiffsChecked)
+	require.Equal(t, uint64(0), report.DiffsRepaired)
+	require.Equal(t, 0, len(report.Quarantined))
+}
+
+func TestScrub(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pachyderm-drive")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	server, err := newLocalAPIServer(dir, ratelimit.NewLimiter(0), 0, nil)
+	require.NoError(t, err)
+	replicaDir, err := ioutil.TempDir("", "pachyderm-drive")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(replicaDir)
+	}()
+	replica, err := newLocalAPIServer(replicaDir, ratelimit.NewLimiter(0), 0, nil)
+	require.NoError(t, err)
+
+	var blocks []*drive.Block
+	for _, value := range []string{"foo\n", "bar\n", "baz\n"} {
+		blockRefs, err := pfsutil.PutBlock(getLocalDriveClient(t, server), strings.NewReader(value))
+		require.NoError(t, err)
+		_, err = pfsutil.PutBlock(getLocalDriveClient(t, replica), strings.NewReader(value))
+		require.NoError(t, err)
+		blocks = append(blocks, blockRefs.BlockRef[0].Block)
+	}
+	require.NoError(t, ioutil.WriteFile(server.blockPath(blocks[0]), []byte("rot\n"), 0666))
+
+	// without a replica the corrupt block is quarantined
+	report, cursor, err := server.scrubBatch("", 100*24)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), report.BlocksChecked)
+	require.Equal(t, 1, len(report.Corrupt))
+	require.Equal(t, 0, len(report.Repaired))
+	_, err = os.Stat(server.blockPath(blocks[0]))
+	require.True(t, os.IsNotExist(err))
+
+	// with one it's fetched again
+	server.replicaClient = getLocalDriveClient(t, replica)
+	require.NoError(t, ioutil.WriteFile(server.blockPath(blocks[1]), []byte("rot\n"), 0666))
+	report, _, err = server.scrubBatch(cursor, 100*24)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), report.BlocksChecked)
+	require.Equal(t, []string{blocks[1].Hash}, report.Repaired)
+	data, err := ioutil.ReadFile(server.blockPath(blocks[1]))
+	require.NoError(t, err)
+	require.Equal(t, "bar\n", string(data))
+}
+
+func TestScrubBatchSize(t *testing.T) {
+	require.Equal(t, 0, scrubBatchSize(0, 10))
+	// a batch is never empty when there are blocks to check
+	require.Equal(t, 1, scrubBatchSize(1, 1))
+	require.Equal(t, 10, scrubBatchSize(2400, 10))
+	require.Equal(t, 5, scrubBatchSize(5, 100*24))
+}
+
+func testDiff(repoName string, commitID string, blockRef *drive.BlockRef) *drive.DiffInfo {
+	return &drive.DiffInfo{
+		Diff: &drive.Diff{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{Name: repoName},
+				Id:   commitID,
+			},
+		},
+		Appends: map[string]*drive.Append{
+			"file": {
+				BlockRefs: []*drive.BlockRef{blockRef},
+			},
+		},
+	}
+}
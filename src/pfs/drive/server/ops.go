@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/time"
+	"golang.org/x/net/context"
+)
+
+// maxRequestSummary is how much of a request's text is kept with an op.
+const maxRequestSummary = 256
+
+func (s *localAPIServer) ListOp(ctx context.Context, request *google_protobuf.Empty) (response *pfs.Ops, retErr error) {
+	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	response = &pfs.Ops{}
+	for _, op := range grpcutil.ListOps() {
+		protoOp := &pfs.Op{
+			Id:      op.ID,
+			Method:  op.Method,
+			Peer:    audit.Principal(op.Context),
+			Started: prototime.TimeToTimestamp(op.Started),
+		}
+		if op.Request != nil {
+			protoOp.Request = op.Request.String()
+			if len(protoOp.Request) > maxRequestSummary {
+				protoOp.Request = protoOp.Request[:maxRequestSummary] + "..."
+			}
+		}
+		if shard, ok := requestShard(op.Request); ok {
+			protoOp.Shard = &google_protobuf.UInt64Value{Value: shard}
+		}
+		response.Op = append(response.Op, protoOp)
+	}
+	return response, nil
+}
+
+func (s *localAPIServer) CancelOp(ctx context.Context, request *pfs.CancelOpRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if !grpcutil.CancelOp(request.Id) {
+		return nil, fmt.Errorf("op %d not found", request.Id)
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+// requestShard returns the shard of the diff request is about.
+func requestShard(request proto.Message) (uint64, bool) {
+	switch request := request.(type) {
+	case *drive.ListDiffRequest:
+		return request.Shard, true
+	case interface {
+		GetDiff() *drive.Diff
+	}:
+		if diff := request.GetDiff(); diff != nil {
+			return diff.Shard, true
+		}
+	}
+	return 0, false
+}
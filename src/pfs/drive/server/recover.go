@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs/drive"
+)
+
+// recoverState cleans up what a crash left behind. It runs before the
+// server accepts requests so nothing in tmpDir can still be in use.
+func (s *localAPIServer) recoverState() (*drive.RecoveryReport, error) {
+	report := &drive.RecoveryReport{}
+	if err := s.recoverTmp(report); err != nil {
+		return nil, err
+	}
+	if err := s.recoverDiffs(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// recoverTmp removes blocks and diffs which were being written when we
+// crashed, they were never renamed into place so nothing refers to them.
+func (s *localAPIServer) recoverTmp(report *drive.RecoveryReport) error {
+	fileInfos, err := ioutil.ReadDir(s.tmpDir())
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		if err := os.RemoveAll(filepath.Join(s.tmpDir(), fileInfo.Name())); err != nil {
+			return err
+		}
+		report.OrphanedBlocks++
+		report.OrphanedBytes += uint64(fileInfo.Size())
+	}
+	return nil
+}
+
+// recoverDiffs checks that every diff can be read, is stored under the path
+// for its header and only refers to blocks we have. Headers are rewritten to
+// match their path, anything else that's wrong gets the diff quarantined.
+func (s *localAPIServer) recoverDiffs(report *drive.RecoveryReport) error {
+	return filepath.Walk(s.diffDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		report.DiffsChecked++
+		diff := s.pathToDiff(path)
+		if diff == nil {
+			return s.quarantine(report, path, "not a diff path")
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		diffInfo := &drive.DiffInfo{}
+		if err := proto.Unmarshal(data, diffInfo); err != nil {
+			return s.quarantine(report, path, err.Error())
+		}
+		for filePath, fileAppend := range diffInfo.Appends {
+			for _, blockRef := range fileAppend.BlockRefs {
+				if err := s.checkBlockRef(blockRef); err != nil {
+					return s.quarantine(report, path, fmt.Sprintf("file %s: %s", filePath, err.Error()))
+				}
+			}
+		}
+		if !proto.Equal(diffInfo.Diff, diff) {
+			diffInfo.Diff = diff
+			if err := s.writeDiff(diffInfo); err != nil {
+				return err
+			}
+			report.DiffsRepaired++
+		}
+		return nil
+	})
+}
+
+func (s *localAPIServer) checkBlockRef(blockRef *drive.BlockRef) error {
+	if blockRef.Block == nil || blockRef.Range == nil {
+		return fmt.Errorf("malformed block ref")
+	}
+	fileInfo, err := os.Stat(s.blockPath(blockRef.Block))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("block %s is missing", blockRef.Block.Hash)
+		}
+		return err
+	}
+	if uint64(fileInfo.Size()) < blockRef.Range.Upper {
+		return fmt.Errorf("block %s is %d bytes but %d are referenced", blockRef.Block.Hash, fileInfo.Size(), blockRef.Range.Upper)
+	}
+	return nil
+}
+
+// quarantine moves path into the quarantine directory, keeping its path
+// relative to s.dir, so that it's out of the way but can still be inspected.
+func (s *localAPIServer) quarantine(report *drive.RecoveryReport, path string, reason string) error {
+	relPath, err := s.moveToQuarantine(path)
+	if err != nil {
+		return err
+	}
+	report.Quarantined = append(report.Quarantined, fmt.Sprintf("%s: %s", relPath, reason))
+	return nil
+}
+
+// moveToQuarantine does the move for quarantine and returns path relative
+// to s.dir.
+func (s *localAPIServer) moveToQuarantine(path string) (string, error) {
+	relPath, err := filepath.Rel(s.dir, path)
+	if err != nil {
+		return "", err
+	}
+	quarantinePath := filepath.Join(s.quarantineDir(), relPath)
+	if err := os.MkdirAll(filepath.Dir(quarantinePath), 0777); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path, quarantinePath); err != nil {
+		return "", err
+	}
+	return relPath, nil
+}
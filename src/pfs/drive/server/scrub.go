@@ -0,0 +1,236 @@
+package server
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// scrubInterval is how often the scrubber checks a batch of blocks, each
+	// batch is its share of the day's blocks.
+	scrubInterval = time.Hour
+	// readDirBatchSize is how many names are read from the block directory
+	// at a time, so that it's never held in memory all at once.
+	readDirBatchSize = 1024
+)
+
+// scrub checks scrubPercent of the blocks against their hash each day. It
+// goes through them in order of hash, picking up where it left off, so every
+// block is checked once every 100/scrubPercent days.
+func (s *localAPIServer) scrub(scrubPercent uint64) {
+	var cursor string
+	for range time.Tick(scrubInterval) {
+		report, next, err := s.scrubBatch(cursor, scrubPercent)
+		if err != nil {
+			protolog.Printf("Error scrubbing blocks: %s", err.Error())
+			continue
+		}
+		cursor = next
+		protolog.Info(report)
+	}
+}
+
+// scrubBatch checks the batch of blocks after cursor, wrapping around to the
+// first block, and returns the hash of the last one it checked. Corrupt
+// blocks are quarantined and fetched again from the replica.
+func (s *localAPIServer) scrubBatch(cursor string, scrubPercent uint64) (*drive.ScrubReport, string, error) {
+	report := &drive.ScrubReport{}
+	blocks := 0
+	if err := s.walkBlockNames(func(string) { blocks++ }); err != nil {
+		return nil, cursor, err
+	}
+	batchSize := scrubBatchSize(blocks, scrubPercent)
+	if batchSize == 0 {
+		return report, cursor, nil
+	}
+	// the batch is the first batchSize names after cursor, followed by the
+	// first names before it if we wrap around
+	after := &maxNames{}
+	before := &maxNames{}
+	if err := s.walkBlockNames(func(name string) {
+		if name > cursor {
+			after.pushBounded(name, batchSize)
+		} else {
+			before.pushBounded(name, batchSize)
+		}
+	}); err != nil {
+		return nil, cursor, err
+	}
+	batch := append(after.sorted(), before.sorted()...)
+	if len(batch) > batchSize {
+		batch = batch[:batchSize]
+	}
+	for _, name := range batch {
+		cursor = name
+		block := &drive.Block{Hash: name}
+		hash, size, err := s.hashBlock(block)
+		if err != nil && os.IsNotExist(err) {
+			continue
+		}
+		report.BlocksChecked++
+		report.BytesChecked += size
+		// a read error is as much a sign of rot as a bad hash
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else if hash != block.Hash {
+			reason = fmt.Sprintf("content hashes to %s", hash)
+		}
+		if reason == "" {
+			continue
+		}
+		if err := s.repairBlock(report, block, reason); err != nil {
+			return nil, cursor, err
+		}
+	}
+	return report, cursor, nil
+}
+
+// scrubBatchSize returns how many of blocks are checked every scrubInterval
+// to check scrubPercent of them a day.
+func scrubBatchSize(blocks int, scrubPercent uint64) int {
+	batchesPerDay := uint64(24 * time.Hour / scrubInterval)
+	result := int((uint64(blocks)*scrubPercent + 100*batchesPerDay - 1) / (100 * batchesPerDay))
+	if result > blocks {
+		return blocks
+	}
+	return result
+}
+
+// walkBlockNames calls f with the name of each block, in no particular order,
+// reading the block directory readDirBatchSize names at a time.
+func (s *localAPIServer) walkBlockNames(f func(name string)) (retErr error) {
+	dir, err := os.Open(s.blockDir())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := dir.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	for {
+		names, err := dir.Readdirnames(readDirBatchSize)
+		for _, name := range names {
+			f(name)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// maxNames is a max heap of names, it keeps the smallest names pushed to it.
+type maxNames []string
+
+func (h maxNames) Len() int            { return len(h) }
+func (h maxNames) Less(i, j int) bool  { return h[i] > h[j] }
+func (h maxNames) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxNames) Push(x interface{}) { *h = append(*h, x.(string)) }
+func (h *maxNames) Pop() interface{} {
+	old := *h
+	result := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return result
+}
+
+// pushBounded pushes name, dropping the largest name if there are more than
+// size.
+func (h *maxNames) pushBounded(name string, size int) {
+	heap.Push(h, name)
+	if h.Len() > size {
+		heap.Pop(h)
+	}
+}
+
+// sorted returns the names in ascending order.
+func (h maxNames) sorted() []string {
+	result := append([]string(nil), h...)
+	sort.Strings(result)
+	return result
+}
+
+// hashBlock returns the hash of block's content and its size.
+func (s *localAPIServer) hashBlock(block *drive.Block) (_ string, _ uint64, retErr error) {
+	file, err := os.Open(s.blockPath(block))
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	hash := drive.NewHash()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return drive.NewBlock(hash).Hash, uint64(size), nil
+}
+
+// repairBlock quarantines a corrupt block and fetches it again from the
+// replica. A block which can't be fetched stays missing, reads of it fail
+// rather than return corrupt data.
+func (s *localAPIServer) repairBlock(report *drive.ScrubReport, block *drive.Block, reason string) error {
+	if _, err := s.moveToQuarantine(s.blockPath(block)); err != nil {
+		return err
+	}
+	report.Corrupt = append(report.Corrupt, fmt.Sprintf("%s: %s", block.Hash, reason))
+	if s.replicaClient == nil {
+		return nil
+	}
+	if err := s.fetchBlock(block); err != nil {
+		protolog.Printf("Error fetching block %s from the replica: %s", block.Hash, err.Error())
+		return nil
+	}
+	report.Repaired = append(report.Repaired, block.Hash)
+	return nil
+}
+
+// fetchBlock copies block from the replica, checking its hash before it's
+// renamed into place.
+func (s *localAPIServer) fetchBlock(block *drive.Block) (retErr error) {
+	blockInfo, err := s.replicaClient.InspectBlock(context.Background(), &drive.InspectBlockRequest{Block: block})
+	if err != nil {
+		return err
+	}
+	reader, err := pfsutil.GetBlock(s.replicaClient, block.Hash, 0, blockInfo.SizeBytes)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.tmpDir(), "block")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	hash := drive.NewHash()
+	if _, err := io.Copy(io.MultiWriter(hash, tmp), reader); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if replicaHash := drive.NewBlock(hash).Hash; replicaHash != block.Hash {
+		return fmt.Errorf("the replica's copy is corrupt too, it hashes to %s", replicaHash)
+	}
+	return os.Rename(tmp.Name(), s.blockPath(block))
+}
@@ -2,12 +2,18 @@ package server
 
 import (
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pkg/ratelimit"
 )
 
 var (
-	blockSize = 128 * 1024 * 1024 // 128 Megabytes
+	blockSize     = drive.BlockSize
+	diffChunkSize = uint64(1024 * 1024) // 1 Megabyte
 )
 
-func NewLocalAPIServer(dir string) (drive.APIServer, error) {
-	return newLocalAPIServer(dir)
+// NewLocalAPIServer returns a drive.APIServer which stores data in dir,
+// PullDiff streams are limited by pullDiffLimiter. scrubPercent of its
+// blocks are checked against their hash each day, corrupt ones are fetched
+// again from replicaClient unless it's nil.
+func NewLocalAPIServer(dir string, pullDiffLimiter ratelimit.Limiter, scrubPercent uint64, replicaClient drive.APIClient) (drive.APIServer, error) {
+	return newLocalAPIServer(dir, pullDiffLimiter, scrubPercent, replicaClient)
 }
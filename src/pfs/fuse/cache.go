@@ -0,0 +1,342 @@
+package fuse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"go.pedge.io/protolog"
+)
+
+const (
+	// cacheBlockSize is the size of the blocks files are cached in, reads are
+	// rounded out to whole blocks.
+	cacheBlockSize = 1 << 20
+	// evictInterval is how many blocks are added to the cache between checks
+	// of its size.
+	evictInterval = 64
+	tempPrefix    = ".tmp-"
+	// doneFile is created in a request directory once no more blocks will
+	// be requested in it.
+	doneFile = ".done"
+	// fillInterval is how often FillCache looks for new requests.
+	fillInterval = time.Second
+)
+
+// cache is a directory of file blocks which can be shared by every mount on
+// a node. Blocks are only cached from finished commits, which can't change, so
+// a block's address (its commit, path, shard and index) identifies its content
+// and entries never need to be invalidated. If requestDir is set the cache is
+// only read, the blocks which are missing are requested there for FillCache
+// to add.
+type cache struct {
+	dir        string
+	maxBytes   uint64
+	requestDir string
+	apiClient  pfs.APIClient
+	stats      *Stats
+	puts       uint64
+}
+
+func newCache(dir string, maxBytes uint64, requestDir string, apiClient pfs.APIClient, stats *Stats) *cache {
+	return &cache{
+		dir,
+		maxBytes,
+		requestDir,
+		apiClient,
+		stats,
+		0,
+	}
+}
+
+// read reads size bytes from file at offset, blocks are served from the
+// cache when possible and added to it otherwise. file's commit must be
+// finished.
+func (c *cache) read(file *pfs.File, shard *pfs.Shard, offset int64, size int64) ([]byte, error) {
+	var result []byte
+	for index := offset / cacheBlockSize; index*cacheBlockSize < offset+size; index++ {
+		block, err := c.block(file, shard, index)
+		if err != nil {
+			return nil, err
+		}
+		blockOffset := index * cacheBlockSize
+		start := int64(0)
+		if offset > blockOffset {
+			start = offset - blockOffset
+		}
+		end := int64(len(block))
+		if offset+size-blockOffset < end {
+			end = offset + size - blockOffset
+		}
+		if start >= end {
+			break
+		}
+		result = append(result, block[start:end]...)
+		if len(block) < cacheBlockSize {
+			// we've hit the end of the file
+			break
+		}
+	}
+	return result, nil
+}
+
+// block returns the index'th block of file.
+func (c *cache) block(file *pfs.File, shard *pfs.Shard, index int64) ([]byte, error) {
+	blockPath := filepath.Join(c.dir, blockKey(file, shard, index))
+	if data, err := ioutil.ReadFile(blockPath); err == nil {
+		// the modification time is used to evict the least recently used
+		// blocks
+		now := time.Now()
+		_ = os.Chtimes(blockPath, now, now)
+		atomic.AddUint64(&c.stats.BytesCached, uint64(len(data)))
+		return data, nil
+	}
+	data, err := getBlock(c.apiClient, file, shard, index)
+	if err != nil {
+		return nil, err
+	}
+	if c.requestDir != "" {
+		if err := request(c.requestDir, file, shard, index); err != nil {
+			protolog.Printf("fuse: error requesting %s: %s", blockPath, err.Error())
+		}
+		return data, nil
+	}
+	if err := c.put(blockPath, data); err != nil {
+		// the cache is an optimization, failing to fill it shouldn't fail
+		// the read
+		protolog.Printf("fuse: error caching %s: %s", blockPath, err.Error())
+	}
+	return data, nil
+}
+
+func getBlock(apiClient pfs.APIClient, file *pfs.File, shard *pfs.Shard, index int64) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := pfsutil.GetFile(
+		apiClient,
+		file.Commit.Repo.Name,
+		file.Commit.Id,
+		file.Path,
+		index*cacheBlockSize,
+		cacheBlockSize,
+		shard,
+		&buffer,
+	); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// request asks for the index'th block of file to be added to the cache, the
+// request is named after the block so asking twice is harmless.
+func request(requestDir string, file *pfs.File, shard *pfs.Shard, index int64) error {
+	// nothing is reading the requests once they're finished
+	if _, err := os.Stat(filepath.Join(requestDir, doneFile)); err == nil {
+		return nil
+	}
+	data, err := proto.Marshal(&pfs.GetFileRequest{
+		File:        file,
+		Shard:       shard,
+		OffsetBytes: index * cacheBlockSize,
+		SizeBytes:   cacheBlockSize,
+	})
+	if err != nil {
+		return err
+	}
+	return writeAtomic(requestDir, filepath.Join(requestDir, blockKey(file, shard, index)), data)
+}
+
+// FillCache adds the blocks requested in options.CacheRequestDir by mounts
+// with the same option to the cache in options.CacheDir, evicting blocks once
+// it's larger than options.CacheBytes. Only blocks of finished commits are
+// added and each is read from pfs, or from options.BlockDir if it's set,
+// rather than trusted from the requester, so the cache can be shared by mounts
+// which can't write to it. It returns once FinishCacheRequests has been called
+// on the request directory.
+func FillCache(apiClient pfs.APIClient, options MounterOptions) error {
+	requestDir := options.CacheRequestDir
+	stats := &Stats{}
+	c := newCache(options.CacheDir, options.CacheBytes, "", apiClient, stats)
+	var direct *directReader
+	if options.BlockDir != "" {
+		direct = newDirectReader(options.BlockDir, apiClient, stats)
+	}
+	for {
+		fileInfos, err := ioutil.ReadDir(requestDir)
+		if err != nil {
+			return err
+		}
+		done := false
+		for _, fileInfo := range fileInfos {
+			switch {
+			case fileInfo.Name() == doneFile:
+				done = true
+			case strings.HasPrefix(fileInfo.Name(), tempPrefix):
+			default:
+				if err := c.fill(filepath.Join(requestDir, fileInfo.Name()), direct); err != nil {
+					protolog.Printf("fuse: error filling %s: %s", fileInfo.Name(), err.Error())
+				}
+			}
+		}
+		// the requests made before the done file was created have all been
+		// seen
+		if done {
+			return nil
+		}
+		time.Sleep(fillInterval)
+	}
+}
+
+// FinishCacheRequests tells the FillCache reading requestDir that no more
+// blocks will be requested.
+func FinishCacheRequests(requestDir string) error {
+	return writeAtomic(requestDir, filepath.Join(requestDir, doneFile), nil)
+}
+
+// fill adds the block requested in requestPath to the cache and removes the
+// request, the block is read from direct if it's there.
+func (c *cache) fill(requestPath string, direct *directReader) (retErr error) {
+	defer func() {
+		if err := os.Remove(requestPath); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	data, err := ioutil.ReadFile(requestPath)
+	if err != nil {
+		return err
+	}
+	getFileRequest := &pfs.GetFileRequest{}
+	if err := proto.Unmarshal(data, getFileRequest); err != nil {
+		return err
+	}
+	file := getFileRequest.File
+	if file == nil || file.Commit == nil || file.Commit.Repo == nil || getFileRequest.OffsetBytes%cacheBlockSize != 0 {
+		return fmt.Errorf("invalid request")
+	}
+	index := getFileRequest.OffsetBytes / cacheBlockSize
+	blockPath := filepath.Join(c.dir, blockKey(file, getFileRequest.Shard, index))
+	if _, err := os.Stat(blockPath); err == nil {
+		return nil
+	}
+	commitInfo, err := pfsutil.InspectCommit(c.apiClient, file.Commit.Repo.Name, file.Commit.Id)
+	if err != nil {
+		return err
+	}
+	if commitInfo.CommitType != pfs.CommitType_COMMIT_TYPE_READ {
+		return fmt.Errorf("commit %s/%s isn't finished", file.Commit.Repo.Name, file.Commit.Id)
+	}
+	if direct != nil {
+		data, ok, err := direct.read(file, getFileRequest.Shard, getFileRequest.OffsetBytes, cacheBlockSize)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return c.put(blockPath, data)
+		}
+	}
+	data, err = getBlock(c.apiClient, file, getFileRequest.Shard, index)
+	if err != nil {
+		return err
+	}
+	return c.put(blockPath, data)
+}
+
+// put writes a block to the cache so that other mounts never see a partial
+// block.
+func (c *cache) put(blockPath string, data []byte) error {
+	if err := writeAtomic(c.dir, blockPath, data); err != nil {
+		return err
+	}
+	if atomic.AddUint64(&c.puts, 1)%evictInterval == 0 {
+		return c.evict()
+	}
+	return nil
+}
+
+// writeAtomic writes data to a temporary file in dir and then renames it to
+// path, readers of path never see a partial file.
+func writeAtomic(dir string, path string, data []byte) (retErr error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	tempFile, err := ioutil.TempFile(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.Remove(tempFile.Name())
+		}
+	}()
+	if _, err := tempFile.Write(data); err != nil {
+		_ = tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
+
+// evict removes the least recently used blocks until the cache is under
+// maxBytes. Other mounts may be evicting at the same time so blocks which
+// have already been removed are skipped.
+func (c *cache) evict() error {
+	if c.maxBytes == 0 {
+		return nil
+	}
+	fileInfos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var blocks []os.FileInfo
+	var size uint64
+	for _, fileInfo := range fileInfos {
+		// temporary files are blocks which are still being written
+		if strings.HasPrefix(fileInfo.Name(), tempPrefix) {
+			continue
+		}
+		blocks = append(blocks, fileInfo)
+		size += uint64(fileInfo.Size())
+	}
+	if size <= c.maxBytes {
+		return nil
+	}
+	sort.Sort(sortFileInfosByModTime(blocks))
+	for _, fileInfo := range blocks {
+		if size <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, fileInfo.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= uint64(fileInfo.Size())
+	}
+	return nil
+}
+
+// blockKey is the name of a block in the cache.
+func blockKey(file *pfs.File, shard *pfs.Shard, index int64) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n%d\n", file.Commit.Repo.Name, file.Commit.Id, file.Path, index)
+	if shard != nil {
+		fmt.Fprintf(hash, "%d/%d/%d/%d/%t\n", shard.FileNumber, shard.FileModulus, shard.BlockNumber, shard.BlockModulus, shard.TopDir)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type sortFileInfosByModTime []os.FileInfo
+
+func (s sortFileInfosByModTime) Len() int           { return len(s) }
+func (s sortFileInfosByModTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortFileInfosByModTime) Less(i, j int) bool { return s[i].ModTime().Before(s[j].ModTime()) }
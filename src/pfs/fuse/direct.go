@@ -0,0 +1,112 @@
+package fuse
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+)
+
+// directReader reads files straight out of the drive's block directory,
+// which is only available when we're running on the same host as the drive,
+// bypassing the grpc stream from pfs.
+type directReader struct {
+	blockDir  string
+	apiClient pfs.APIClient
+	stats     *Stats
+	// fileBlocks holds the blocks of each file we've read, files are only
+	// read directly from finished commits so these never change
+	fileBlocks map[string][]*pfs.FileBlock
+	lock       sync.Mutex
+}
+
+func newDirectReader(blockDir string, apiClient pfs.APIClient, stats *Stats) *directReader {
+	return &directReader{
+		blockDir,
+		apiClient,
+		stats,
+		make(map[string][]*pfs.FileBlock),
+		sync.Mutex{},
+	}
+}
+
+// read reads size bytes from file at offset, it returns false if a block
+// that's needed isn't in the block directory. file's commit must be finished.
+func (r *directReader) read(file *pfs.File, shard *pfs.Shard, offset int64, size int64) ([]byte, bool, error) {
+	fileBlocks, err := r.getFileBlocks(file, shard)
+	if err != nil {
+		return nil, false, err
+	}
+	var result []byte
+	var position int64
+	for _, fileBlock := range fileBlocks {
+		if position >= offset+size {
+			break
+		}
+		blockSize := int64(fileBlock.Upper - fileBlock.Lower)
+		if position+blockSize <= offset {
+			position += blockSize
+			continue
+		}
+		start := int64(0)
+		if offset > position {
+			start = offset - position
+		}
+		end := blockSize
+		if offset+size-position < end {
+			end = offset + size - position
+		}
+		data, ok, err := r.readBlock(fileBlock.Hash, int64(fileBlock.Lower)+start, end-start)
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+		result = append(result, data...)
+		position += blockSize
+	}
+	atomic.AddUint64(&r.stats.BytesDirect, uint64(len(result)))
+	return result, true, nil
+}
+
+func (r *directReader) readBlock(hash string, offset int64, size int64) (_ []byte, _ bool, retErr error) {
+	file, err := os.Open(filepath.Join(r.blockDir, hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, offset); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (r *directReader) getFileBlocks(file *pfs.File, shard *pfs.Shard) ([]*pfs.FileBlock, error) {
+	fileKey := key(file)
+	if shard != nil {
+		fileKey = fileKey + "/" + shard.String()
+	}
+	r.lock.Lock()
+	fileBlocks, ok := r.fileBlocks[fileKey]
+	r.lock.Unlock()
+	if ok {
+		return fileBlocks, nil
+	}
+	fileBlocks, err := pfsutil.InspectFileBlocks(r.apiClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, shard)
+	if err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	r.fileBlocks[fileKey] = fileBlocks
+	r.lock.Unlock()
+	return fileBlocks, nil
+}
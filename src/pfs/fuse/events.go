@@ -0,0 +1,147 @@
+package fuse
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/golang/protobuf/proto"
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
+)
+
+// metricsInterval is how often a filesystem which samples its debug events
+// logs the FilesystemMetrics of the ops it served.
+const metricsInterval = time.Minute
+
+// events logs the debug event of each op a filesystem serves. Logging one
+// per op makes a busy mount crawl, so if sampleRate isn't 0 only one in
+// sampleRate events is logged and every op is instead counted toward the
+// FilesystemMetrics logged each metricsInterval.
+type events struct {
+	sampleRate uint64
+	ops        uint64 // the ops recorded, for sampling
+	lock       sync.Mutex
+	metrics    map[string]*OpMetrics
+	start      time.Time
+	done       chan bool
+	wg         sync.WaitGroup
+}
+
+func newEvents(sampleRate uint64) *events {
+	e := &events{
+		sampleRate: sampleRate,
+		metrics:    make(map[string]*OpMetrics),
+		start:      time.Now(),
+		done:       make(chan bool),
+	}
+	if sampleRate != 0 {
+		e.wg.Add(1)
+		go e.logMetrics()
+	}
+	return e
+}
+
+// record records an op which started at start and read or wrote bytes,
+// newEvent returns its debug event and is only called if it's logged.
+func (e *events) record(
+	op string,
+	start time.Time,
+	bytes uint64,
+	err error,
+	newEvent func(duration *google_protobuf.Duration, errno int32) proto.Message,
+) {
+	duration := time.Since(start)
+	if e.sampleRate == 0 {
+		protolog.Debug(newEvent(prototime.DurationToProto(duration), errorToErrno(err)))
+		return
+	}
+	e.lock.Lock()
+	metrics, ok := e.metrics[op]
+	if !ok {
+		metrics = &OpMetrics{
+			Op:            op,
+			TotalDuration: &google_protobuf.Duration{},
+			MaxDuration:   &google_protobuf.Duration{},
+		}
+		e.metrics[op] = metrics
+	}
+	metrics.Count++
+	if err != nil {
+		metrics.Errors++
+	}
+	metrics.Bytes += bytes
+	metrics.TotalDuration = prototime.DurationToProto(prototime.DurationFromProto(metrics.TotalDuration) + duration)
+	if duration > prototime.DurationFromProto(metrics.MaxDuration) {
+		metrics.MaxDuration = prototime.DurationToProto(duration)
+	}
+	e.lock.Unlock()
+	if atomic.AddUint64(&e.ops, 1)%e.sampleRate == 0 {
+		protolog.Debug(newEvent(prototime.DurationToProto(duration), errorToErrno(err)))
+	}
+}
+
+// close stops e, logging the metrics recorded since they were last logged.
+func (e *events) close() {
+	if e.sampleRate == 0 {
+		return
+	}
+	close(e.done)
+	e.wg.Wait()
+}
+
+func (e *events) logMetrics() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.done:
+			e.flush()
+			return
+		}
+	}
+}
+
+// flush logs the metrics recorded since the last flush and resets them.
+func (e *events) flush() {
+	e.lock.Lock()
+	now := time.Now()
+	filesystemMetrics := &FilesystemMetrics{
+		Interval: prototime.DurationToProto(now.Sub(e.start)),
+	}
+	for _, metrics := range e.metrics {
+		filesystemMetrics.OpMetrics = append(filesystemMetrics.OpMetrics, metrics)
+	}
+	e.metrics = make(map[string]*OpMetrics)
+	e.start = now
+	e.lock.Unlock()
+	if len(filesystemMetrics.OpMetrics) == 0 {
+		return
+	}
+	sort.Sort(sortOpMetrics(filesystemMetrics.OpMetrics))
+	protolog.Info(filesystemMetrics)
+}
+
+// errorToErrno returns the errno the kernel is given for err, fuse sends EIO
+// for errors which don't carry their own.
+func errorToErrno(err error) int32 {
+	if err == nil {
+		return 0
+	}
+	if errorNumber, ok := err.(fuse.ErrorNumber); ok {
+		return int32(errorNumber.Errno())
+	}
+	return int32(fuse.EIO)
+}
+
+type sortOpMetrics []*OpMetrics
+
+func (s sortOpMetrics) Len() int           { return len(s) }
+func (s sortOpMetrics) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortOpMetrics) Less(i, j int) bool { return s[i].Op < s[j].Op }
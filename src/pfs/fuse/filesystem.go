@@ -3,6 +3,7 @@ package fuse
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path"
 	"strings"
@@ -12,22 +13,49 @@ import (
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"github.com/golang/protobuf/proto"
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
-	"go.pedge.io/protolog"
+	"go.pedge.io/google-protobuf"
 	"golang.org/x/net/context"
 )
 
+const (
+	// rootInode is the inode fuse reserves for the root of the filesystem,
+	// inodes at or below it are never handed out.
+	rootInode uint64 = 1
+	// writeAttempts is how many times a write is sent to pfs before we give
+	// up on the part of it that hasn't been written.
+	writeAttempts = 3
+)
+
 type filesystem struct {
 	apiClient pfs.APIClient
 	Filesystem
 	inodes map[string]uint64
-	lock   sync.RWMutex
+	// usedInodes maps each inode we've handed out back to its key so that
+	// no inode is ever reused for a different file within a mount.
+	usedInodes map[uint64]string
+	// openFiles holds the write state shared by every handle for a file,
+	// a file's entry is removed when its last handle is released
+	openFiles map[string]*openFile
+	lock      sync.RWMutex
+	stats     *Stats
+	cache     *cache        // nil if reads aren't cached
+	direct    *directReader // nil if blocks can't be read directly
+	// finishedCommits holds the commits we've seen finished, only their
+	// files are cached or read directly since they can't change
+	finishedCommits map[string]bool
+	events          *events
 }
 
 func newFilesystem(
 	apiClient pfs.APIClient,
 	commitMounts []*CommitMount,
+	stats *Stats,
+	cache *cache,
+	direct *directReader,
+	events *events,
 ) *filesystem {
 	return &filesystem{
 		apiClient,
@@ -35,14 +63,23 @@ func newFilesystem(
 			commitMounts,
 		},
 		make(map[string]uint64),
+		make(map[uint64]string),
+		make(map[string]*openFile),
 		sync.RWMutex{},
+		stats,
+		cache,
+		direct,
+		make(map[string]bool),
+		events,
 	}
 }
 
 func (f *filesystem) Root() (result fs.Node, retErr error) {
-	defer func() {
-		protolog.Debug(&Root{&f.Filesystem, getNode(result), errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		f.events.record("Root", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &Root{&f.Filesystem, getNode(result), errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	return &directory{
 		f,
 		Node{
@@ -62,9 +99,11 @@ type directory struct {
 }
 
 func (d *directory) Attr(ctx context.Context, a *fuse.Attr) (retErr error) {
-	defer func() {
-		protolog.Debug(&DirectoryAttr{&d.Node, &Attr{uint32(a.Mode)}, errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		d.fs.events.record("DirectoryAttr", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &DirectoryAttr{&d.Node, getAttr(a), errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	a.Valid = time.Nanosecond
 	if d.Write {
 		a.Mode = os.ModeDir | 0775
@@ -76,9 +115,11 @@ func (d *directory) Attr(ctx context.Context, a *fuse.Attr) (retErr error) {
 }
 
 func (d *directory) Lookup(ctx context.Context, name string) (result fs.Node, retErr error) {
-	defer func() {
-		protolog.Debug(&DirectoryLookup{&d.Node, name, getNode(result), errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		d.fs.events.record("DirectoryLookup", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &DirectoryLookup{&d.Node, name, getNode(result), errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	if d.File.Commit.Repo.Name == "" {
 		return d.lookUpRepo(ctx, name)
 	}
@@ -89,13 +130,15 @@ func (d *directory) Lookup(ctx context.Context, name string) (result fs.Node, re
 }
 
 func (d *directory) ReadDirAll(ctx context.Context) (result []fuse.Dirent, retErr error) {
-	defer func() {
-		var dirents []*Dirent
-		for _, dirent := range result {
-			dirents = append(dirents, &Dirent{dirent.Inode, dirent.Name})
-		}
-		protolog.Debug(&DirectoryReadDirAll{&d.Node, dirents, errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		d.fs.events.record("DirectoryReadDirAll", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			var dirents []*Dirent
+			for _, dirent := range result {
+				dirents = append(dirents, &Dirent{dirent.Inode, dirent.Name, uint32(dirent.Type)})
+			}
+			return &DirectoryReadDirAll{&d.Node, dirents, errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	if d.File.Commit.Repo.Name == "" {
 		return d.readRepos(ctx)
 	}
@@ -112,9 +155,11 @@ func (d *directory) ReadDirAll(ctx context.Context) (result []fuse.Dirent, retEr
 }
 
 func (d *directory) Create(ctx context.Context, request *fuse.CreateRequest, response *fuse.CreateResponse) (result fs.Node, _ fs.Handle, retErr error) {
-	defer func() {
-		protolog.Debug(&DirectoryCreate{&d.Node, getNode(result), errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		d.fs.events.record("DirectoryCreate", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &DirectoryCreate{&d.Node, getNode(result), errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	if d.File.Commit.Id == "" {
 		return nil, 0, fuse.EPERM
 	}
@@ -134,9 +179,11 @@ func (d *directory) Create(ctx context.Context, request *fuse.CreateRequest, res
 }
 
 func (d *directory) Mkdir(ctx context.Context, request *fuse.MkdirRequest) (result fs.Node, retErr error) {
-	defer func() {
-		protolog.Debug(&DirectoryMkdir{&d.Node, getNode(result), errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		d.fs.events.record("DirectoryMkdir", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &DirectoryMkdir{&d.Node, getNode(result), errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	if d.File.Commit.Id == "" {
 		return nil, fuse.EPERM
 	}
@@ -156,9 +203,11 @@ type file struct {
 }
 
 func (f *file) Attr(ctx context.Context, a *fuse.Attr) (retErr error) {
-	defer func() {
-		protolog.Debug(&FileAttr{&f.Node, &Attr{uint32(a.Mode)}, errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		f.fs.events.record("FileAttr", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &FileAttr{&f.Node, getAttr(a), errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	fileInfo, err := pfsutil.InspectFile(
 		f.fs.apiClient,
 		f.File.Commit.Repo.Name,
@@ -172,72 +221,296 @@ func (f *file) Attr(ctx context.Context, a *fuse.Attr) (retErr error) {
 	if fileInfo != nil {
 		a.Size = fileInfo.SizeBytes
 	}
+	if openFile := f.fs.getOpenFile(f.File); openFile != nil {
+		if size := openFile.size(); a.Size < size {
+			// writes we've made may not be visible through InspectFile yet
+			a.Size = size
+		}
+	}
 	a.Mode = 0666
 	a.Inode = f.fs.inode(f.File)
 	return nil
 }
 
 func (f *file) Read(ctx context.Context, request *fuse.ReadRequest, response *fuse.ReadResponse) (retErr error) {
-	defer func() {
-		protolog.Debug(&FileRead{&f.Node, errorToString(retErr)})
-	}()
-	var buffer bytes.Buffer
-	if err := pfsutil.GetFile(
-		f.fs.apiClient,
-		f.File.Commit.Repo.Name,
-		f.File.Commit.Id,
-		f.File.Path,
-		request.Offset,
-		int64(request.Size),
-		f.Shard,
-		&buffer,
-	); err != nil {
+	defer func(start time.Time) {
+		size := uint64(len(response.Data))
+		f.fs.events.record("FileRead", start, size, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &FileRead{&f.Node, errorToString(retErr), duration, errno, request.Offset, size}
+		})
+	}(time.Now())
+	data, err := f.fs.read(f.File, f.Shard, request.Offset, int64(request.Size))
+	if err != nil {
 		return err
 	}
-	response.Data = buffer.Bytes()
+	response.Data = data
+	atomic.AddUint64(&f.fs.stats.BytesRead, uint64(len(response.Data)))
 	return nil
 }
 
 func (f *file) Open(ctx context.Context, request *fuse.OpenRequest, response *fuse.OpenResponse) (_ fs.Handle, retErr error) {
-	defer func() {
-		protolog.Debug(&FileRead{&f.Node, errorToString(retErr)})
-	}()
+	defer func(start time.Time) {
+		f.fs.events.record("FileOpen", start, 0, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &FileOpen{&f.Node, errorToString(retErr), duration, errno}
+		})
+	}(time.Now())
 	atomic.AddInt32(&f.handles, 1)
+	f.fs.acquireOpenFile(f.File)
 	return f, nil
 }
 
 func (f *file) Write(ctx context.Context, request *fuse.WriteRequest, response *fuse.WriteResponse) (retErr error) {
-	defer func() {
-		protolog.Debug(&FileWrite{&f.Node, errorToString(retErr)})
-	}()
-	written, err := pfsutil.PutFile(f.fs.apiClient, f.File.Commit.Repo.Name, f.File.Commit.Id, f.File.Path, request.Offset, bytes.NewReader(request.Data))
-	if err != nil {
-		return err
+	defer func(start time.Time) {
+		size := uint64(response.Size)
+		f.fs.events.record("FileWrite", start, size, retErr, func(duration *google_protobuf.Duration, errno int32) proto.Message {
+			return &FileWrite{&f.Node, errorToString(retErr), duration, errno, request.Offset, size}
+		})
+	}(time.Now())
+	// Writes to the same file are serialized, writes to different files
+	// proceed in parallel.
+	openFile := f.fs.getOpenFile(f.File)
+	if openFile == nil {
+		// fuse only writes to files it has opened
+		return fuse.EIO
+	}
+	openFile.lock.Lock()
+	defer openFile.lock.Unlock()
+	var written int
+	var err error
+	for attempt := 0; attempt < writeAttempts && written < len(request.Data); attempt++ {
+		var n int
+		n, err = pfsutil.PutFile(
+			f.fs.apiClient,
+			f.File.Commit.Repo.Name,
+			f.File.Commit.Id,
+			f.File.Path,
+			request.Offset+int64(written),
+			bytes.NewReader(request.Data[written:]),
+		)
+		if err != nil {
+			// we can't know how much of a failed write made it in, so
+			// only short writes are retried
+			break
+		}
+		if n > 0 {
+			openFile.markDirty(request.Offset+int64(written), int64(n))
+		}
+		written += n
 	}
 	response.Size = written
+	atomic.AddUint64(&f.fs.stats.BytesWritten, uint64(written))
 	if f.size < request.Offset+int64(written) {
 		f.size = request.Offset + int64(written)
 	}
+	if written < len(request.Data) {
+		if err != nil {
+			return err
+		}
+		return fuse.EIO
+	}
+	return nil
+}
+
+func (f *file) Release(ctx context.Context, request *fuse.ReleaseRequest) error {
+	atomic.AddInt32(&f.handles, -1)
+	f.fs.releaseOpenFile(f.File)
 	return nil
 }
 
+// openFile is the write state for a file, it's shared between all of the
+// handles for that file.
+type openFile struct {
+	// handles is how many handles have the file open, it's guarded by the
+	// filesystem's lock
+	handles int
+	lock    sync.Mutex
+	// dirty is the sorted, non overlapping ranges that have been written
+	// through this mount
+	dirty     []byteRange
+	dirtyLock sync.Mutex
+}
+
+type byteRange struct {
+	lower int64
+	upper int64
+}
+
+// read reads size bytes from file at offset. Files of finished commits are
+// read directly from the drive's block directory or from the cache when
+// possible, everything else is streamed from pfs.
+func (f *filesystem) read(file *pfs.File, shard *pfs.Shard, offset int64, size int64) ([]byte, error) {
+	if f.direct != nil || f.cache != nil {
+		finished, err := f.commitFinished(file.Commit)
+		if err != nil {
+			return nil, err
+		}
+		if finished && f.direct != nil {
+			data, ok, err := f.direct.read(file, shard, offset, size)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return data, nil
+			}
+		}
+		if finished && f.cache != nil {
+			return f.cache.read(file, shard, offset, size)
+		}
+	}
+	var buffer bytes.Buffer
+	if err := pfsutil.GetFile(
+		f.apiClient,
+		file.Commit.Repo.Name,
+		file.Commit.Id,
+		file.Path,
+		offset,
+		size,
+		shard,
+		&buffer,
+	); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (f *filesystem) commitFinished(commit *pfs.Commit) (bool, error) {
+	commitKey := fmt.Sprintf("%s/%s", commit.Repo.Name, commit.Id)
+	f.lock.RLock()
+	finished := f.finishedCommits[commitKey]
+	f.lock.RUnlock()
+	if finished {
+		return true, nil
+	}
+	commitInfo, err := pfsutil.InspectCommit(f.apiClient, commit.Repo.Name, commit.Id)
+	if err != nil {
+		return false, err
+	}
+	finished = commitInfo != nil && commitInfo.CommitType == pfs.CommitType_COMMIT_TYPE_READ
+	// a commit which is still being written may finish later, so only
+	// finished commits are remembered
+	if finished {
+		f.lock.Lock()
+		f.finishedCommits[commitKey] = true
+		f.lock.Unlock()
+	}
+	return finished, nil
+}
+
+// acquireOpenFile returns the openFile for file, creating it if this is the
+// file's first handle.
+func (f *filesystem) acquireOpenFile(file *pfs.File) *openFile {
+	fileKey := key(file)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	result, ok := f.openFiles[fileKey]
+	if !ok {
+		result = &openFile{}
+		f.openFiles[fileKey] = result
+	}
+	result.handles++
+	return result
+}
+
+// releaseOpenFile drops a handle to file, the file's openFile is removed
+// along with its last handle.
+func (f *filesystem) releaseOpenFile(file *pfs.File) {
+	fileKey := key(file)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	result, ok := f.openFiles[fileKey]
+	if !ok {
+		return
+	}
+	result.handles--
+	if result.handles <= 0 {
+		delete(f.openFiles, fileKey)
+	}
+}
+
+// getOpenFile returns the openFile for file, nil if it has no open handles.
+func (f *filesystem) getOpenFile(file *pfs.File) *openFile {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.openFiles[key(file)]
+}
+
+func (o *openFile) markDirty(offset int64, size int64) {
+	o.dirtyLock.Lock()
+	defer o.dirtyLock.Unlock()
+	newRange := byteRange{offset, offset + size}
+	var dirty []byteRange
+	inserted := false
+	for _, r := range o.dirty {
+		switch {
+		case r.upper < newRange.lower:
+			dirty = append(dirty, r)
+		case newRange.upper < r.lower:
+			if !inserted {
+				dirty = append(dirty, newRange)
+				inserted = true
+			}
+			dirty = append(dirty, r)
+		default:
+			// overlapping or adjacent, merge them
+			if r.lower < newRange.lower {
+				newRange.lower = r.lower
+			}
+			if r.upper > newRange.upper {
+				newRange.upper = r.upper
+			}
+		}
+	}
+	if !inserted {
+		dirty = append(dirty, newRange)
+	}
+	o.dirty = dirty
+}
+
+// size is the end of the last dirty range
+func (o *openFile) size() uint64 {
+	o.dirtyLock.Lock()
+	defer o.dirtyLock.Unlock()
+	if len(o.dirty) == 0 {
+		return 0
+	}
+	return uint64(o.dirty[len(o.dirty)-1].upper)
+}
+
+// inode returns the inode for file, inodes are a hash of the file's
+// repo/commit/path so they're stable across remounts. On the rare
+// collision we probe for the next free inode, so the colliding file's inode
+// depends on the order in which files were first seen.
 func (f *filesystem) inode(file *pfs.File) uint64 {
+	fileKey := key(file)
 	f.lock.RLock()
-	inode, ok := f.inodes[key(file)]
+	inode, ok := f.inodes[fileKey]
 	f.lock.RUnlock()
 	if ok {
 		return inode
 	}
 	f.lock.Lock()
-	if inode, ok := f.inodes[key(file)]; ok {
+	defer f.lock.Unlock()
+	if inode, ok := f.inodes[fileKey]; ok {
 		return inode
 	}
-	newInode := uint64(len(f.inodes))
-	f.inodes[key(file)] = newInode
-	f.lock.Unlock()
+	newInode := hashInode(fileKey)
+	for {
+		if _, ok := f.usedInodes[newInode]; !ok && newInode > rootInode {
+			break
+		}
+		newInode++
+	}
+	f.inodes[fileKey] = newInode
+	f.usedInodes[newInode] = fileKey
 	return newInode
 }
 
+func hashInode(fileKey string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(fileKey))
+	return hash.Sum64()
+}
+
 func (d *directory) copy() *directory {
 	return &directory{
 		fs: d.fs,
@@ -301,7 +574,8 @@ func (d *directory) lookUpCommit(ctx context.Context, name string) (fs.Node, err
 		return nil, fuse.ENOENT
 	}
 	result := d.copy()
-	result.File.Commit.Id = name
+	// name may be a tag, the commit is always known by its id
+	result.File.Commit.Id = commitInfo.Commit.Id
 	if commitInfo.CommitType == pfs.CommitType_COMMIT_TYPE_READ {
 		result.Write = false
 	} else {
@@ -409,6 +683,10 @@ func getNode(node fs.Node) *Node {
 	}
 }
 
+func getAttr(a *fuse.Attr) *Attr {
+	return &Attr{uint32(a.Mode), a.Size, a.Inode}
+}
+
 func key(file *pfs.File) string {
 	return fmt.Sprintf("%s/%s/%s", file.Commit.Repo.Name, file.Commit.Id, file.Path)
 }
@@ -1,7 +1,12 @@
 package fuse
 
 import (
+	"path"
+	"strings"
+
+	"bazil.org/fuse/fs"
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
 )
 
 type Mounter interface {
@@ -13,12 +18,119 @@ type Mounter interface {
 		ready chan bool,
 	) error
 	// Unmount unmounts a mounted filesystem (duh).
-	// There's nothing special about this unmount, it's just doing a syscall under the hood.
+	// If the regular unmount fails it falls back to a lazy unmount.
 	Unmount(mountPoint string) error
+	// Stats returns the number of bytes read and written through all of the
+	// filesystems mounted by this Mounter.
+	Stats() *Stats
+}
+
+// Stats counts the bytes that have passed through a Mounter.
+type Stats struct {
+	BytesRead    uint64
+	BytesWritten uint64
+	BytesCached  uint64 // the bytes read which were served from the cache
+	BytesDirect  uint64 // the bytes read directly from the drive's block directory
+}
+
+// MounterOptions configures how a Mounter reads files.
+type MounterOptions struct {
+	// CacheDir caches blocks of finished commits, it can be shared by
+	// Mounters in other processes. "" disables caching.
+	CacheDir string
+	// CacheBytes is how large CacheDir can get before blocks are evicted, 0
+	// means no limit.
+	CacheBytes uint64
+	// CacheRequestDir, if set, means CacheDir is only read. Blocks missing
+	// from it are requested in CacheRequestDir for a FillCache, in a process
+	// which can write CacheDir, to add.
+	CacheRequestDir string
+	// BlockDir is the drive's block directory, if we're on the same host as
+	// the drive files of finished commits are read from it directly. ""
+	// disables direct reads.
+	BlockDir string
+	// ReadOnly mounts the filesystem read-only, even commits which aren't
+	// finished can't be written to.
+	ReadOnly bool
+	// SampleEvents logs the debug event of only one in SampleEvents ops,
+	// the ops are summed up in FilesystemMetrics logged each minute instead.
+	// 0 logs the debug event of every op.
+	SampleEvents uint64
+	// MountOptions are passed through to fuse when a filesystem is mounted,
+	// nil means DefaultMountOptions.
+	MountOptions *MountOptions
+}
+
+// MountOptions are the fuse options a filesystem is mounted with.
+type MountOptions struct {
+	// AllowOther lets users other than the one who mounted the filesystem
+	// access it, users other than root need user_allow_other set in
+	// /etc/fuse.conf to use it.
+	AllowOther bool
+	// MaxReadahead is the most the kernel reads ahead of what's been read, 0
+	// means the kernel's default.
+	MaxReadahead uint32
+	// AsyncRead lets the kernel have several reads of a file outstanding at
+	// once, rather than waiting for each to return before sending the next.
+	AsyncRead bool
+	// VolumeName is the name the filesystem is shown with on OS X, "" means
+	// pfs://address.
+	VolumeName string
+}
+
+// DefaultMountOptions are the MountOptions of Mounters which aren't given
+// any, they favor throughput and let every user on the host read the mount.
+var DefaultMountOptions = MountOptions{
+	AllowOther:   true,
+	MaxReadahead: 1<<32 - 1,
+	AsyncRead:    true,
+}
+
+// ListMounts returns the filesystems mounted by Mounters on this machine,
+// mounts whose process has exited are marked stale.
+func ListMounts() ([]*MountInfo, error) {
+	return listMounts()
+}
+
+// Unmount unmounts mountPoint, falling back to a lazy unmount if it's busy
+// or was left behind by a process which crashed.
+func Unmount(mountPoint string) error {
+	return unmount(mountPoint)
 }
 
 // NewMounter creates a new Mounter.
 // Address can be left blank, it's used only for aesthetic purposes.
 func NewMounter(address string, apiClient pfs.APIClient) Mounter {
-	return newMounter(address, apiClient)
+	return newMounter(address, apiClient, MounterOptions{})
+}
+
+// NewMounterWithOptions creates a new Mounter configured by options.
+func NewMounterWithOptions(address string, apiClient pfs.APIClient, options MounterOptions) Mounter {
+	return newMounter(address, apiClient, options)
+}
+
+// ParseCommitMounts parses commit mounts given as repo/commit:alias, the
+// commit and alias are optional.
+func ParseCommitMounts(args []string) []*CommitMount {
+	var result []*CommitMount
+	for _, arg := range args {
+		commitMount := &CommitMount{Commit: pfsutil.NewCommit("", "")}
+		repo, commitAlias := path.Split(arg)
+		commitMount.Commit.Repo.Name = path.Clean(repo)
+		split := strings.Split(commitAlias, ":")
+		if len(split) > 0 {
+			commitMount.Commit.Id = split[0]
+		}
+		if len(split) > 1 {
+			commitMount.Alias = split[1]
+		}
+		result = append(result, commitMount)
+	}
+	return result
+}
+
+// NewFilesystem returns the filesystem a Mounter mounts, for serving pfs
+// other than through fuse.
+func NewFilesystem(apiClient pfs.APIClient, commitMounts []*CommitMount) fs.FS {
+	return newFilesystem(apiClient, commitMounts, &Stats{}, nil, nil, newEvents(0))
 }
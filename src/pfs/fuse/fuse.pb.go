@@ -6,11 +6,14 @@
 Package fuse is a generated protocol buffer package.
 
 It is generated from these files:
+
 	pfs/fuse/fuse.proto
 
 It has these top-level messages:
+
 	CommitMount
 	Filesystem
+	MountInfo
 	Node
 	Attr
 	Dirent
@@ -24,12 +27,15 @@ It has these top-level messages:
 	FileRead
 	FileOpen
 	FileWrite
+	OpMetrics
+	FilesystemMetrics
 */
 package fuse
 
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "go.pedge.io/google-protobuf"
 import pfs "github.com/pachyderm/pachyderm/src/pfs"
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -76,6 +82,26 @@ func (m *Filesystem) GetCommitMounts() []*CommitMount {
 	return nil
 }
 
+type MountInfo struct {
+	MountPoint   string         `protobuf:"bytes,1,opt,name=mount_point" json:"mount_point,omitempty"`
+	Address      string         `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	Pid          int64          `protobuf:"varint,3,opt,name=pid" json:"pid,omitempty"`
+	CommitMounts []*CommitMount `protobuf:"bytes,4,rep,name=commit_mounts" json:"commit_mounts,omitempty"`
+	// stale is set when listing mounts whose process is gone
+	Stale bool `protobuf:"varint,5,opt,name=stale" json:"stale,omitempty"`
+}
+
+func (m *MountInfo) Reset()         { *m = MountInfo{} }
+func (m *MountInfo) String() string { return proto.CompactTextString(m) }
+func (*MountInfo) ProtoMessage()    {}
+
+func (m *MountInfo) GetCommitMounts() []*CommitMount {
+	if m != nil {
+		return m.CommitMounts
+	}
+	return nil
+}
+
 type Node struct {
 	File      *pfs.File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
 	RepoAlias string     `protobuf:"bytes,2,opt,name=repo_alias" json:"repo_alias,omitempty"`
@@ -102,7 +128,9 @@ func (m *Node) GetShard() *pfs.Shard {
 }
 
 type Attr struct {
-	Mode uint32 `protobuf:"varint,1,opt,name=Mode" json:"Mode,omitempty"`
+	Mode  uint32 `protobuf:"varint,1,opt,name=Mode" json:"Mode,omitempty"`
+	Size  uint64 `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	Inode uint64 `protobuf:"varint,3,opt,name=inode" json:"inode,omitempty"`
 }
 
 func (m *Attr) Reset()         { *m = Attr{} }
@@ -112,6 +140,7 @@ func (*Attr) ProtoMessage()    {}
 type Dirent struct {
 	Inode uint64 `protobuf:"varint,1,opt,name=inode" json:"inode,omitempty"`
 	Name  string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Type  uint32 `protobuf:"varint,3,opt,name=type" json:"type,omitempty"`
 }
 
 func (m *Dirent) Reset()         { *m = Dirent{} }
@@ -119,9 +148,11 @@ func (m *Dirent) String() string { return proto.CompactTextString(m) }
 func (*Dirent) ProtoMessage()    {}
 
 type Root struct {
-	Filesystem *Filesystem `protobuf:"bytes,1,opt,name=filesystem" json:"filesystem,omitempty"`
-	Result     *Node       `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
-	Error      string      `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Filesystem *Filesystem               `protobuf:"bytes,1,opt,name=filesystem" json:"filesystem,omitempty"`
+	Result     *Node                     `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
+	Error      string                    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Duration   *google_protobuf.Duration `protobuf:"bytes,4,opt,name=duration" json:"duration,omitempty"`
+	Errno      int32                     `protobuf:"varint,5,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *Root) Reset()         { *m = Root{} }
@@ -142,10 +173,19 @@ func (m *Root) GetResult() *Node {
 	return nil
 }
 
+func (m *Root) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type DirectoryAttr struct {
-	Directory *Node  `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
-	Result    *Attr  `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
-	Error     string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Directory *Node                     `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
+	Result    *Attr                     `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
+	Error     string                    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Duration  *google_protobuf.Duration `protobuf:"bytes,4,opt,name=duration" json:"duration,omitempty"`
+	Errno     int32                     `protobuf:"varint,5,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *DirectoryAttr) Reset()         { *m = DirectoryAttr{} }
@@ -166,11 +206,20 @@ func (m *DirectoryAttr) GetResult() *Attr {
 	return nil
 }
 
+func (m *DirectoryAttr) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type DirectoryLookup struct {
-	Directory *Node  `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
-	Name      string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
-	Result    *Node  `protobuf:"bytes,3,opt,name=result" json:"result,omitempty"`
-	Err       string `protobuf:"bytes,4,opt,name=err" json:"err,omitempty"`
+	Directory *Node                     `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
+	Name      string                    `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Result    *Node                     `protobuf:"bytes,3,opt,name=result" json:"result,omitempty"`
+	Err       string                    `protobuf:"bytes,4,opt,name=err" json:"err,omitempty"`
+	Duration  *google_protobuf.Duration `protobuf:"bytes,5,opt,name=duration" json:"duration,omitempty"`
+	Errno     int32                     `protobuf:"varint,6,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *DirectoryLookup) Reset()         { *m = DirectoryLookup{} }
@@ -191,10 +240,19 @@ func (m *DirectoryLookup) GetResult() *Node {
 	return nil
 }
 
+func (m *DirectoryLookup) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type DirectoryReadDirAll struct {
-	Directory *Node     `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
-	Result    []*Dirent `protobuf:"bytes,2,rep,name=result" json:"result,omitempty"`
-	Error     string    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Directory *Node                     `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
+	Result    []*Dirent                 `protobuf:"bytes,2,rep,name=result" json:"result,omitempty"`
+	Error     string                    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Duration  *google_protobuf.Duration `protobuf:"bytes,4,opt,name=duration" json:"duration,omitempty"`
+	Errno     int32                     `protobuf:"varint,5,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *DirectoryReadDirAll) Reset()         { *m = DirectoryReadDirAll{} }
@@ -215,10 +273,19 @@ func (m *DirectoryReadDirAll) GetResult() []*Dirent {
 	return nil
 }
 
+func (m *DirectoryReadDirAll) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type DirectoryCreate struct {
-	Directory *Node  `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
-	Result    *Node  `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
-	Error     string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Directory *Node                     `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
+	Result    *Node                     `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
+	Error     string                    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Duration  *google_protobuf.Duration `protobuf:"bytes,4,opt,name=duration" json:"duration,omitempty"`
+	Errno     int32                     `protobuf:"varint,5,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *DirectoryCreate) Reset()         { *m = DirectoryCreate{} }
@@ -239,10 +306,19 @@ func (m *DirectoryCreate) GetResult() *Node {
 	return nil
 }
 
+func (m *DirectoryCreate) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type DirectoryMkdir struct {
-	Directory *Node  `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
-	Result    *Node  `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
-	Error     string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Directory *Node                     `protobuf:"bytes,1,opt,name=directory" json:"directory,omitempty"`
+	Result    *Node                     `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
+	Error     string                    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Duration  *google_protobuf.Duration `protobuf:"bytes,4,opt,name=duration" json:"duration,omitempty"`
+	Errno     int32                     `protobuf:"varint,5,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *DirectoryMkdir) Reset()         { *m = DirectoryMkdir{} }
@@ -263,10 +339,19 @@ func (m *DirectoryMkdir) GetResult() *Node {
 	return nil
 }
 
+func (m *DirectoryMkdir) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type FileAttr struct {
-	File   *Node  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Result *Attr  `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
-	Error  string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	File     *Node                     `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Result   *Attr                     `protobuf:"bytes,2,opt,name=result" json:"result,omitempty"`
+	Error    string                    `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Duration *google_protobuf.Duration `protobuf:"bytes,4,opt,name=duration" json:"duration,omitempty"`
+	Errno    int32                     `protobuf:"varint,5,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *FileAttr) Reset()         { *m = FileAttr{} }
@@ -287,9 +372,20 @@ func (m *FileAttr) GetResult() *Attr {
 	return nil
 }
 
+func (m *FileAttr) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type FileRead struct {
-	File  *Node  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	File     *Node                     `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Error    string                    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Duration *google_protobuf.Duration `protobuf:"bytes,3,opt,name=duration" json:"duration,omitempty"`
+	Errno    int32                     `protobuf:"varint,4,opt,name=errno" json:"errno,omitempty"`
+	Offset   int64                     `protobuf:"varint,5,opt,name=offset" json:"offset,omitempty"`
+	Bytes    uint64                    `protobuf:"varint,6,opt,name=bytes" json:"bytes,omitempty"`
 }
 
 func (m *FileRead) Reset()         { *m = FileRead{} }
@@ -303,9 +399,18 @@ func (m *FileRead) GetFile() *Node {
 	return nil
 }
 
+func (m *FileRead) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type FileOpen struct {
-	File  *Node  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	File     *Node                     `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Error    string                    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Duration *google_protobuf.Duration `protobuf:"bytes,3,opt,name=duration" json:"duration,omitempty"`
+	Errno    int32                     `protobuf:"varint,4,opt,name=errno" json:"errno,omitempty"`
 }
 
 func (m *FileOpen) Reset()         { *m = FileOpen{} }
@@ -319,9 +424,20 @@ func (m *FileOpen) GetFile() *Node {
 	return nil
 }
 
+func (m *FileOpen) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
 type FileWrite struct {
-	File  *Node  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	File     *Node                     `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Error    string                    `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Duration *google_protobuf.Duration `protobuf:"bytes,3,opt,name=duration" json:"duration,omitempty"`
+	Errno    int32                     `protobuf:"varint,4,opt,name=errno" json:"errno,omitempty"`
+	Offset   int64                     `protobuf:"varint,5,opt,name=offset" json:"offset,omitempty"`
+	Bytes    uint64                    `protobuf:"varint,6,opt,name=bytes" json:"bytes,omitempty"`
 }
 
 func (m *FileWrite) Reset()         { *m = FileWrite{} }
@@ -335,9 +451,72 @@ func (m *FileWrite) GetFile() *Node {
 	return nil
 }
 
+func (m *FileWrite) GetDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
+
+// OpMetrics aggregates the ops of one kind a filesystem served over a
+// FilesystemMetrics interval.
+type OpMetrics struct {
+	// op is the name of the op's debug event, ie FileRead.
+	Op            string                    `protobuf:"bytes,1,opt,name=op" json:"op,omitempty"`
+	Count         uint64                    `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+	Errors        uint64                    `protobuf:"varint,3,opt,name=errors" json:"errors,omitempty"`
+	Bytes         uint64                    `protobuf:"varint,4,opt,name=bytes" json:"bytes,omitempty"`
+	TotalDuration *google_protobuf.Duration `protobuf:"bytes,5,opt,name=total_duration" json:"total_duration,omitempty"`
+	MaxDuration   *google_protobuf.Duration `protobuf:"bytes,6,opt,name=max_duration" json:"max_duration,omitempty"`
+}
+
+func (m *OpMetrics) Reset()         { *m = OpMetrics{} }
+func (m *OpMetrics) String() string { return proto.CompactTextString(m) }
+func (*OpMetrics) ProtoMessage()    {}
+
+func (m *OpMetrics) GetTotalDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.TotalDuration
+	}
+	return nil
+}
+
+func (m *OpMetrics) GetMaxDuration() *google_protobuf.Duration {
+	if m != nil {
+		return m.MaxDuration
+	}
+	return nil
+}
+
+// FilesystemMetrics is logged periodically by a filesystem which samples its
+// debug events rather than logging one per op.
+type FilesystemMetrics struct {
+	Interval  *google_protobuf.Duration `protobuf:"bytes,1,opt,name=interval" json:"interval,omitempty"`
+	OpMetrics []*OpMetrics              `protobuf:"bytes,2,rep,name=op_metrics" json:"op_metrics,omitempty"`
+}
+
+func (m *FilesystemMetrics) Reset()         { *m = FilesystemMetrics{} }
+func (m *FilesystemMetrics) String() string { return proto.CompactTextString(m) }
+func (*FilesystemMetrics) ProtoMessage()    {}
+
+func (m *FilesystemMetrics) GetInterval() *google_protobuf.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+func (m *FilesystemMetrics) GetOpMetrics() []*OpMetrics {
+	if m != nil {
+		return m.OpMetrics
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*CommitMount)(nil), "fuse.CommitMount")
 	proto.RegisterType((*Filesystem)(nil), "fuse.Filesystem")
+	proto.RegisterType((*MountInfo)(nil), "fuse.MountInfo")
 	proto.RegisterType((*Node)(nil), "fuse.Node")
 	proto.RegisterType((*Attr)(nil), "fuse.Attr")
 	proto.RegisterType((*Dirent)(nil), "fuse.Dirent")
@@ -351,4 +530,6 @@ func init() {
 	proto.RegisterType((*FileRead)(nil), "fuse.FileRead")
 	proto.RegisterType((*FileOpen)(nil), "fuse.FileOpen")
 	proto.RegisterType((*FileWrite)(nil), "fuse.FileWrite")
+	proto.RegisterType((*OpMetrics)(nil), "fuse.OpMetrics")
+	proto.RegisterType((*FilesystemMetrics)(nil), "fuse.FilesystemMetrics")
 }
@@ -2,11 +2,14 @@ package fuse
 
 import (
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
 )
 
 const (
@@ -17,12 +20,38 @@ const (
 type mounter struct {
 	address   string
 	apiClient pfs.APIClient
+	stats     *Stats
+	cache     *cache        // nil if reads aren't cached
+	direct    *directReader // nil if blocks can't be read directly
+	readOnly  bool
+	// sampleEvents is MounterOptions.SampleEvents
+	sampleEvents uint64
+	mountOptions MountOptions
 }
 
-func newMounter(address string, apiClient pfs.APIClient) Mounter {
+func newMounter(address string, apiClient pfs.APIClient, options MounterOptions) Mounter {
+	stats := &Stats{}
+	var c *cache
+	if options.CacheDir != "" {
+		c = newCache(options.CacheDir, options.CacheBytes, options.CacheRequestDir, apiClient, stats)
+	}
+	var direct *directReader
+	if options.BlockDir != "" {
+		direct = newDirectReader(options.BlockDir, apiClient, stats)
+	}
+	mountOptions := DefaultMountOptions
+	if options.MountOptions != nil {
+		mountOptions = *options.MountOptions
+	}
 	return &mounter{
 		address,
 		apiClient,
+		stats,
+		c,
+		direct,
+		options.ReadOnly,
+		options.SampleEvents,
+		mountOptions,
 	}
 }
 
@@ -41,16 +70,45 @@ func (m *mounter) Mount(
 	if err := os.MkdirAll(mountPoint, 0777); err != nil {
 		return err
 	}
+	if absMountPoint, err := filepath.Abs(mountPoint); err == nil {
+		mountPoint = absMountPoint
+	}
+	// commits given as of a time are resolved once so that a commit which
+	// finishes while we're mounted can't change what we serve
+	for _, commitMount := range commitMounts {
+		if _, ok := pfs.ParseAsOfCommitID(commitMount.Commit.Id); !ok {
+			continue
+		}
+		commitInfo, err := pfsutil.InspectCommit(m.apiClient, commitMount.Commit.Repo.Name, commitMount.Commit.Id)
+		if err != nil {
+			return err
+		}
+		commitMount.Commit = commitInfo.Commit
+	}
 	name := namePrefix + m.address
-	conn, err := fuse.Mount(
-		mountPoint,
+	volumeName := m.mountOptions.VolumeName
+	if volumeName == "" {
+		volumeName = name
+	}
+	mountOptions := []fuse.MountOption{
 		fuse.FSName(name),
-		fuse.VolumeName(name),
+		fuse.VolumeName(volumeName),
 		fuse.Subtype(subtype),
-		fuse.AllowOther(),
 		fuse.WritebackCache(),
-		fuse.MaxReadahead(1<<32-1),
-	)
+	}
+	if m.mountOptions.AllowOther {
+		mountOptions = append(mountOptions, fuse.AllowOther())
+	}
+	if m.mountOptions.MaxReadahead != 0 {
+		mountOptions = append(mountOptions, fuse.MaxReadahead(m.mountOptions.MaxReadahead))
+	}
+	if m.mountOptions.AsyncRead {
+		mountOptions = append(mountOptions, fuse.AsyncRead())
+	}
+	if m.readOnly {
+		mountOptions = append(mountOptions, fuse.ReadOnly())
+	}
+	conn, err := fuse.Mount(mountPoint, mountOptions...)
 	if err != nil {
 		return err
 	}
@@ -59,12 +117,27 @@ func (m *mounter) Mount(
 			retErr = err
 		}
 	}()
+	if err := recordMount(&MountInfo{
+		MountPoint:   mountPoint,
+		Address:      m.address,
+		Pid:          int64(os.Getpid()),
+		CommitMounts: commitMounts,
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		if err := forgetMount(mountPoint); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
 	once.Do(func() {
 		if ready != nil {
 			close(ready)
 		}
 	})
-	if err := fs.Serve(conn, newFilesystem(m.apiClient, commitMounts)); err != nil {
+	events := newEvents(m.sampleEvents)
+	defer events.close()
+	if err := fs.Serve(conn, newFilesystem(m.apiClient, commitMounts, m.stats, m.cache, m.direct, events)); err != nil {
 		return err
 	}
 	<-conn.Ready
@@ -72,5 +145,14 @@ func (m *mounter) Mount(
 }
 
 func (m *mounter) Unmount(mountPoint string) error {
-	return fuse.Unmount(mountPoint)
+	return unmount(mountPoint)
+}
+
+func (m *mounter) Stats() *Stats {
+	return &Stats{
+		BytesRead:    atomic.LoadUint64(&m.stats.BytesRead),
+		BytesWritten: atomic.LoadUint64(&m.stats.BytesWritten),
+		BytesCached:  atomic.LoadUint64(&m.stats.BytesCached),
+		BytesDirect:  atomic.LoadUint64(&m.stats.BytesDirect),
+	}
 }
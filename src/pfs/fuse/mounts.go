@@ -0,0 +1,107 @@
+package fuse
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"bazil.org/fuse"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+var (
+	marshaler = &jsonpb.Marshaler{}
+)
+
+// mountStateDir is where we record active mounts, one file per mount point.
+func mountStateDir() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.TempDir()
+	}
+	return filepath.Join(home, ".pachyderm", "mounts")
+}
+
+func mountStateFile(mountPoint string) string {
+	return filepath.Join(mountStateDir(), url.QueryEscape(mountPoint))
+}
+
+func recordMount(mountInfo *MountInfo) error {
+	if err := os.MkdirAll(mountStateDir(), 0755); err != nil {
+		return err
+	}
+	encodedMountInfo, err := marshaler.MarshalToString(mountInfo)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(mountStateFile(mountInfo.MountPoint), []byte(encodedMountInfo), 0644)
+}
+
+func forgetMount(mountPoint string) error {
+	if err := os.Remove(mountStateFile(mountPoint)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func listMounts() ([]*MountInfo, error) {
+	fileInfos, err := ioutil.ReadDir(mountStateDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var result []*MountInfo
+	for _, fileInfo := range fileInfos {
+		encodedMountInfo, err := ioutil.ReadFile(filepath.Join(mountStateDir(), fileInfo.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var mountInfo MountInfo
+		if err := jsonpb.UnmarshalString(string(encodedMountInfo), &mountInfo); err != nil {
+			return nil, err
+		}
+		mountInfo.Stale = !processExists(mountInfo.Pid)
+		result = append(result, &mountInfo)
+	}
+	return result, nil
+}
+
+func unmount(mountPoint string) error {
+	if absMountPoint, err := filepath.Abs(mountPoint); err == nil {
+		mountPoint = absMountPoint
+	}
+	if err := fuse.Unmount(mountPoint); err != nil {
+		// the mount is likely busy or its process is gone, fall back to a
+		// lazy unmount which detaches it now and cleans up once it's unused
+		if lazyErr := lazyUnmount(mountPoint); lazyErr != nil {
+			return err
+		}
+	}
+	return forgetMount(mountPoint)
+}
+
+func lazyUnmount(mountPoint string) error {
+	var err error
+	for _, args := range [][]string{
+		{"fusermount", "-u", "-z", mountPoint},
+		{"umount", "-l", mountPoint},
+	} {
+		if err = exec.Command(args[0], args[1:]...).Run(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func processExists(pid int64) bool {
+	process, err := os.FindProcess(int(pid))
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,56 @@
+package nfs
+
+// The MOUNT program (RFC 1813 appendix I) hands out the root's file handle.
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	mountProcNull    = 0
+	mountProcMnt     = 1
+	mountProcDump    = 2
+	mountProcUmnt    = 3
+	mountProcUmntAll = 4
+	mountProcExport  = 5
+
+	mountOK    = 0
+	mountNoEnt = 2
+
+	// exportPath is the only path we export
+	exportPath = "/"
+)
+
+func (s *server) handleMount(call *call, reply *xdrWriter) uint32 {
+	switch call.proc {
+	case mountProcNull, mountProcUmntAll:
+	case mountProcMnt:
+		if dirPath := call.args.string(); dirPath != exportPath {
+			reply.uint32(mountNoEnt)
+			break
+		}
+		root, err := s.root()
+		if err != nil {
+			reply.uint32(mountNoEnt)
+			break
+		}
+		reply.uint32(mountOK)
+		reply.opaque(handle(root))
+		// the auth flavors we accept
+		reply.uint32(1)
+		reply.uint32(authUnix)
+	case mountProcDump:
+		// we don't keep track of who has us mounted
+		reply.bool(false)
+	case mountProcUmnt:
+		call.args.string()
+	case mountProcExport:
+		reply.bool(true)
+		reply.string(exportPath)
+		// no groups, the clients which can connect are limited by address
+		// rather than by export
+		reply.bool(false)
+		reply.bool(false)
+	default:
+		return acceptProcUnavail
+	}
+	return acceptSuccess
+}
@@ -0,0 +1,54 @@
+/*
+Package nfs exports a pfs filesystem over NFSv3, for machines which can't
+mount it with fuse. It serves the nodes of the same filesystem that fuse
+mounts, so the two behave alike.
+
+The MOUNT and NFS programs are both served on one TCP port and there's no
+portmapper or lock manager, clients have to be told the port and not to lock:
+
+	mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock host:/ /mnt
+
+NFSv4 isn't supported.
+*/
+package nfs
+
+import (
+	"net"
+
+	"bazil.org/fuse/fs"
+)
+
+// DefaultMaxNodes is the MaxNodes of servers created without options.
+const DefaultMaxNodes = 1 << 16
+
+// Server serves a filesystem over NFS.
+type Server interface {
+	// Serve accepts connections on listener until it's closed.
+	Serve(listener net.Listener) error
+}
+
+// ServerOptions configures who can use a Server and what they can do.
+type ServerOptions struct {
+	// Writable lets clients create and write files, otherwise the export is
+	// read-only.
+	Writable bool
+	// AllowedClients are the networks clients may connect from besides
+	// loopback, connections from anywhere else are closed. NFS clients
+	// aren't authenticated so this is all that keeps others out.
+	AllowedClients []*net.IPNet
+	// MaxNodes bounds how many file handles are remembered, the least
+	// recently used ones become stale past it and clients look them up
+	// again. 0 means DefaultMaxNodes.
+	MaxNodes int
+}
+
+// NewServer creates a new Server which exports filesystem at "/" read-only to
+// loopback clients.
+func NewServer(filesystem fs.FS) Server {
+	return NewServerWithOptions(filesystem, ServerOptions{})
+}
+
+// NewServerWithOptions creates a new Server which exports filesystem at "/".
+func NewServerWithOptions(filesystem fs.FS, options ServerOptions) Server {
+	return newServer(filesystem, options)
+}
@@ -0,0 +1,243 @@
+package nfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"go.pedge.io/protolog"
+)
+
+// ONC RPC (RFC 5531) over TCP, each message is sent as a record made of
+// fragments which are prefixed with their length, the high bit of which
+// marks the record's last fragment.
+const (
+	rpcVersion = 2
+
+	rpcCall  = 0
+	rpcReply = 1
+
+	msgAccepted = 0
+	msgDenied   = 1
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+
+	rejectRPCMismatch = 0
+
+	authNone = 0
+	authUnix = 1
+
+	lastFragment = 1 << 31
+	// maxRecordSize bounds the records we'll read, it leaves room for a
+	// write of maxTransferSize and its arguments
+	maxRecordSize = maxTransferSize + 1<<16
+)
+
+var errGarbageArgs = errors.New("nfs: garbage arguments")
+
+// call is an RPC call, its arguments are read from args.
+type call struct {
+	xid     uint32
+	program uint32
+	version uint32
+	proc    uint32
+	args    *xdrReader
+}
+
+// handler handles calls, it writes the results of call to reply and returns
+// their accept stat.
+type handler func(call *call, reply *xdrWriter) uint32
+
+func serveConn(conn net.Conn, handler handler) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	for {
+		record, err := readRecord(conn)
+		if err != nil {
+			if err != io.EOF {
+				protolog.Printf("nfs: error reading from %s: %s", conn.RemoteAddr(), err.Error())
+			}
+			return
+		}
+		reply, err := handleRecord(record, handler)
+		if err != nil {
+			protolog.Printf("nfs: bad call from %s: %s", conn.RemoteAddr(), err.Error())
+			return
+		}
+		if err := writeRecord(conn, reply); err != nil {
+			protolog.Printf("nfs: error writing to %s: %s", conn.RemoteAddr(), err.Error())
+			return
+		}
+	}
+}
+
+// handleRecord returns the reply to the call in record.
+func handleRecord(record []byte, handler handler) ([]byte, error) {
+	r := &xdrReader{buf: record}
+	xid := r.uint32()
+	if msgType := r.uint32(); msgType != rpcCall {
+		return nil, fmt.Errorf("message type %d isn't a call", msgType)
+	}
+	version := r.uint32()
+	c := &call{xid: xid, program: r.uint32(), version: r.uint32(), proc: r.uint32(), args: r}
+	// credentials and verifier, we don't authenticate callers
+	for i := 0; i < 2; i++ {
+		r.uint32()
+		r.opaque()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	reply := &xdrWriter{}
+	reply.uint32(xid)
+	reply.uint32(rpcReply)
+	if version != rpcVersion {
+		reply.uint32(msgDenied)
+		reply.uint32(rejectRPCMismatch)
+		reply.uint32(rpcVersion)
+		reply.uint32(rpcVersion)
+		return reply.Bytes(), nil
+	}
+	reply.uint32(msgAccepted)
+	reply.uint32(authNone)
+	reply.opaque(nil)
+	results := &xdrWriter{}
+	stat := handler(c, results)
+	if stat == acceptSuccess && r.err != nil {
+		stat = acceptGarbageArgs
+		results.Reset()
+	}
+	reply.uint32(stat)
+	reply.Write(results.Bytes())
+	return reply.Bytes(), nil
+}
+
+func readRecord(reader io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var header uint32
+		if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+			return nil, err
+		}
+		size := header &^ lastFragment
+		if uint64(len(record))+uint64(size) > maxRecordSize {
+			return nil, fmt.Errorf("record is larger than %d bytes", maxRecordSize)
+		}
+		fragment := make([]byte, size)
+		if _, err := io.ReadFull(reader, fragment); err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if header&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+func writeRecord(writer io.Writer, record []byte) error {
+	var buffer bytes.Buffer
+	if err := binary.Write(&buffer, binary.BigEndian, uint32(len(record))|lastFragment); err != nil {
+		return err
+	}
+	buffer.Write(record)
+	_, err := writer.Write(buffer.Bytes())
+	return err
+}
+
+// xdrReader decodes XDR (RFC 4506), once it runs out of data err is set and
+// every read returns zero values.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+func (r *xdrReader) fixed(n int) []byte {
+	padded := (n + 3) &^ 3
+	if r.err != nil || n < 0 || len(r.buf) < padded {
+		r.err = errGarbageArgs
+		return nil
+	}
+	result := r.buf[:n]
+	r.buf = r.buf[padded:]
+	return result
+}
+
+func (r *xdrReader) uint32() uint32 {
+	if b := r.fixed(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *xdrReader) uint64() uint64 {
+	if b := r.fixed(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+func (r *xdrReader) opaque() []byte {
+	size := r.uint32()
+	if uint64(size) > uint64(len(r.buf)) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	return r.fixed(int(size))
+}
+
+func (r *xdrReader) string() string {
+	return string(r.opaque())
+}
+
+// xdrWriter encodes XDR.
+type xdrWriter struct {
+	bytes.Buffer
+}
+
+func (w *xdrWriter) fixed(b []byte) {
+	w.Write(b)
+	if padding := (4 - len(b)%4) % 4; padding != 0 {
+		w.Write(make([]byte, padding))
+	}
+}
+
+func (w *xdrWriter) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *xdrWriter) uint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *xdrWriter) bool(v bool) {
+	if v {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+func (w *xdrWriter) opaque(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.fixed(b)
+}
+
+func (w *xdrWriter) string(s string) {
+	w.opaque([]byte(s))
+}
@@ -0,0 +1,946 @@
+package nfs
+
+import (
+	"container/list"
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+)
+
+// The NFS program (RFC 1813).
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	nfsProcNull        = 0
+	nfsProcGetattr     = 1
+	nfsProcSetattr     = 2
+	nfsProcLookup      = 3
+	nfsProcAccess      = 4
+	nfsProcReadlink    = 5
+	nfsProcRead        = 6
+	nfsProcWrite       = 7
+	nfsProcCreate      = 8
+	nfsProcMkdir       = 9
+	nfsProcSymlink     = 10
+	nfsProcMknod       = 11
+	nfsProcRemove      = 12
+	nfsProcRmdir       = 13
+	nfsProcRename      = 14
+	nfsProcLink        = 15
+	nfsProcReaddir     = 16
+	nfsProcReaddirplus = 17
+	nfsProcFsstat      = 18
+	nfsProcFsinfo      = 19
+	nfsProcPathconf    = 20
+	nfsProcCommit      = 21
+
+	nfsOK             = 0
+	nfsErrPerm        = 1
+	nfsErrNoEnt       = 2
+	nfsErrIO          = 5
+	nfsErrAccess      = 13
+	nfsErrExist       = 17
+	nfsErrNotDir      = 20
+	nfsErrIsDir       = 21
+	nfsErrInval       = 22
+	nfsErrROFS        = 30
+	nfsErrNameTooLong = 63
+	nfsErrStale       = 70
+	nfsErrBadHandle   = 10001
+	nfsErrBadCookie   = 10003
+	nfsErrNotSupp     = 10004
+	nfsErrTooSmall    = 10005
+
+	typeRegular   = 1
+	typeDirectory = 2
+
+	accessRead    = 0x01
+	accessLookup  = 0x02
+	accessModify  = 0x04
+	accessExtend  = 0x08
+	accessDelete  = 0x10
+	accessExecute = 0x20
+
+	createUnchecked = 0
+	createExclusive = 2
+
+	setToClientTime = 2
+
+	// writes go to pfs before they return
+	fileSync = 2
+
+	fsfHomogeneous = 0x08
+
+	// maxTransferSize is the most we read or write in one call
+	maxTransferSize = 1 << 20
+	// readDirOverhead is the size of a READDIR reply without its entries
+	readDirOverhead = 4 + 4 + attrSize + 8 + 4 + 4
+	attrSize        = 84
+	handleSize      = 8
+)
+
+// server serves the nodes of a filesystem. Handles are the inodes of nodes.
+// NFS clients don't tell us when they're done with a handle so the nodes
+// they've been given handles to are kept until there are options.MaxNodes of
+// them, then the least recently used are dropped and their handles go stale.
+// Handles from before a restart are stale too.
+type server struct {
+	filesystem fs.FS
+	options    ServerOptions
+	lock       sync.Mutex
+	nodes      map[uint64]*node
+	order      *list.List // the inodes of nodes, most recently used first
+	rootInode  uint64     // 0 until the root has been looked up
+	// verifier changes when the server restarts, so that clients know to
+	// resend writes they haven't seen committed
+	verifier []byte
+}
+
+type node struct {
+	fs.Node
+	inode   uint64
+	parent  uint64
+	element *list.Element
+}
+
+func newServer(filesystem fs.FS, options ServerOptions) *server {
+	if options.MaxNodes == 0 {
+		options.MaxNodes = DefaultMaxNodes
+	}
+	verifier := make([]byte, 8)
+	binary.BigEndian.PutUint64(verifier, uint64(time.Now().UnixNano()))
+	return &server{
+		filesystem: filesystem,
+		options:    options,
+		nodes:      make(map[uint64]*node),
+		order:      list.New(),
+		verifier:   verifier,
+	}
+}
+
+func (s *server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		if !s.allowed(conn.RemoteAddr()) {
+			protolog.Printf("nfs: refused connection from %s", conn.RemoteAddr())
+			_ = conn.Close()
+			continue
+		}
+		go serveConn(conn, s.handle)
+	}
+}
+
+// allowed returns true if a client at addr may connect.
+func (s *server) allowed(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	if tcpAddr.IP.IsLoopback() {
+		return true
+	}
+	for _, allowedClients := range s.options.AllowedClients {
+		if allowedClients.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) handle(call *call, reply *xdrWriter) uint32 {
+	var version uint32
+	switch call.program {
+	case mountProgram:
+		if call.version == mountVersion {
+			return s.handleMount(call, reply)
+		}
+		version = mountVersion
+	case nfsProgram:
+		if call.version == nfsVersion {
+			return s.handleNFS(call, reply)
+		}
+		version = nfsVersion
+	default:
+		return acceptProgUnavail
+	}
+	reply.uint32(version)
+	reply.uint32(version)
+	return acceptProgMismatch
+}
+
+func (s *server) handleNFS(call *call, reply *xdrWriter) uint32 {
+	args := call.args
+	if !s.options.Writable {
+		switch call.proc {
+		case nfsProcSetattr, nfsProcWrite, nfsProcCreate, nfsProcMkdir:
+			reply.uint32(nfsErrROFS)
+			writeEmptyWcc(reply)
+			return acceptSuccess
+		}
+	}
+	switch call.proc {
+	case nfsProcNull:
+	case nfsProcGetattr:
+		s.getattr(args, reply)
+	case nfsProcSetattr:
+		s.setattr(args, reply)
+	case nfsProcLookup:
+		s.lookup(args, reply)
+	case nfsProcAccess:
+		s.access(args, reply)
+	case nfsProcRead:
+		s.read(args, reply)
+	case nfsProcWrite:
+		s.write(args, reply)
+	case nfsProcCreate:
+		s.create(args, reply)
+	case nfsProcMkdir:
+		s.mkdir(args, reply)
+	case nfsProcReaddir:
+		s.readDir(args, reply, false)
+	case nfsProcReaddirplus:
+		s.readDir(args, reply, true)
+	case nfsProcFsstat:
+		s.fsstat(args, reply)
+	case nfsProcFsinfo:
+		s.fsinfo(args, reply)
+	case nfsProcPathconf:
+		s.pathconf(args, reply)
+	case nfsProcCommit:
+		s.commit(args, reply)
+	// pfs doesn't have links and the fuse filesystem can't remove or
+	// rename, the replies are the failures of each proc
+	case nfsProcReadlink:
+		reply.uint32(nfsErrNotSupp)
+		reply.bool(false)
+	case nfsProcSymlink, nfsProcMknod, nfsProcRemove, nfsProcRmdir:
+		reply.uint32(nfsErrNotSupp)
+		writeEmptyWcc(reply)
+	case nfsProcRename:
+		reply.uint32(nfsErrNotSupp)
+		writeEmptyWcc(reply)
+		writeEmptyWcc(reply)
+	case nfsProcLink:
+		reply.uint32(nfsErrNotSupp)
+		reply.bool(false)
+		writeEmptyWcc(reply)
+	default:
+		return acceptProcUnavail
+	}
+	return acceptSuccess
+}
+
+func (s *server) getattr(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		return
+	}
+	a, err := getAttr(n)
+	if err != nil {
+		reply.uint32(errorToStatus(err))
+		return
+	}
+	reply.uint32(nfsOK)
+	writeAttr(reply, a)
+}
+
+func (s *server) setattr(args *xdrReader, reply *xdrWriter) {
+	h := args.opaque()
+	set := readSattr(args)
+	if args.bool() {
+		// the guard's ctime, ours is always now so it's ignored
+		args.uint64()
+	}
+	if args.err != nil {
+		return
+	}
+	n, status := s.node(h)
+	if status != nfsOK {
+		reply.uint32(status)
+		writeEmptyWcc(reply)
+		return
+	}
+	a, err := getAttr(n)
+	if err != nil {
+		reply.uint32(errorToStatus(err))
+		writeEmptyWcc(reply)
+		return
+	}
+	// files can't be truncated, setting a file's size to what it already
+	// is happens when it's created with O_TRUNC
+	if set.sizeSet && set.size != a.Size {
+		reply.uint32(nfsErrNotSupp)
+		writeWcc(reply, n)
+		return
+	}
+	reply.uint32(nfsOK)
+	writeWcc(reply, n)
+}
+
+func (s *server) lookup(args *xdrReader, reply *xdrWriter) {
+	h := args.opaque()
+	name := args.string()
+	if args.err != nil {
+		return
+	}
+	dir, status := s.node(h)
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	child, a, status := s.lookupChild(dir, name)
+	if status != nfsOK {
+		reply.uint32(status)
+		writePostOpAttr(reply, dir)
+		return
+	}
+	reply.uint32(nfsOK)
+	reply.opaque(handle(child.inode))
+	reply.bool(true)
+	writeAttr(reply, a)
+	writePostOpAttr(reply, dir)
+}
+
+func (s *server) access(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	requested := args.uint32()
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	a, err := getAttr(n)
+	if err != nil {
+		reply.uint32(errorToStatus(err))
+		reply.bool(false)
+		return
+	}
+	// the caller's uid isn't looked at, everyone gets the owner's access
+	var granted uint32
+	perm := a.Mode.Perm()
+	if perm&0400 != 0 {
+		granted |= accessRead
+	}
+	if perm&0200 != 0 && s.options.Writable {
+		granted |= accessModify | accessExtend | accessDelete
+	}
+	if perm&0100 != 0 {
+		if a.Mode.IsDir() {
+			granted |= accessLookup
+		} else {
+			granted |= accessExecute
+		}
+	}
+	reply.uint32(nfsOK)
+	reply.bool(true)
+	writeAttr(reply, a)
+	reply.uint32(requested & granted)
+}
+
+func (s *server) read(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	offset := args.uint64()
+	count := args.uint32()
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	reader, ok := n.Node.(fs.HandleReader)
+	if !ok {
+		reply.uint32(nfsErrIsDir)
+		writePostOpAttr(reply, n)
+		return
+	}
+	if count > maxTransferSize {
+		count = maxTransferSize
+	}
+	response := &fuse.ReadResponse{}
+	if err := reader.Read(
+		context.Background(),
+		&fuse.ReadRequest{Offset: int64(offset), Size: int(count)},
+		response,
+	); err != nil {
+		reply.uint32(errorToStatus(err))
+		writePostOpAttr(reply, n)
+		return
+	}
+	a, err := getAttr(n)
+	reply.uint32(nfsOK)
+	reply.bool(err == nil)
+	if err == nil {
+		writeAttr(reply, a)
+	}
+	reply.uint32(uint32(len(response.Data)))
+	// without the file's size we can only tell we're at the end when
+	// nothing was read
+	eof := len(response.Data) == 0 || (err == nil && offset+uint64(len(response.Data)) >= a.Size)
+	reply.bool(eof)
+	reply.opaque(response.Data)
+}
+
+func (s *server) write(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	offset := args.uint64()
+	count := args.uint32()
+	// we always write synchronously, however stable the client needs it
+	args.uint32()
+	data := args.opaque()
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		writeEmptyWcc(reply)
+		return
+	}
+	writer, ok := n.Node.(fs.HandleWriter)
+	if !ok {
+		reply.uint32(nfsErrIsDir)
+		writeWcc(reply, n)
+		return
+	}
+	if uint64(count) < uint64(len(data)) {
+		data = data[:count]
+	}
+	response := &fuse.WriteResponse{}
+	if err := writer.Write(
+		context.Background(),
+		&fuse.WriteRequest{Offset: int64(offset), Data: data},
+		response,
+	); err != nil {
+		reply.uint32(errorToStatus(err))
+		writeWcc(reply, n)
+		return
+	}
+	reply.uint32(nfsOK)
+	writeWcc(reply, n)
+	reply.uint32(uint32(response.Size))
+	reply.uint32(fileSync)
+	reply.fixed(s.verifier)
+}
+
+func (s *server) create(args *xdrReader, reply *xdrWriter) {
+	h := args.opaque()
+	name := args.string()
+	how := args.uint32()
+	if how == createExclusive {
+		// the create verifier, exclusive creates are treated as guarded
+		// ones since we've nowhere to keep it
+		args.fixed(8)
+	} else {
+		readSattr(args)
+	}
+	if args.err != nil {
+		return
+	}
+	dir, status := s.node(h)
+	if status != nfsOK {
+		reply.uint32(status)
+		writeEmptyWcc(reply)
+		return
+	}
+	if how != createUnchecked {
+		if _, _, status := s.lookupChild(dir, name); status == nfsOK {
+			reply.uint32(nfsErrExist)
+			writeWcc(reply, dir)
+			return
+		}
+	}
+	creater, ok := dir.Node.(fs.NodeCreater)
+	if !ok {
+		reply.uint32(nfsErrNotDir)
+		writeWcc(reply, dir)
+		return
+	}
+	child, childHandle, err := creater.Create(
+		context.Background(),
+		&fuse.CreateRequest{Name: name, Mode: 0666},
+		&fuse.CreateResponse{},
+	)
+	if err != nil {
+		reply.uint32(errorToStatus(err))
+		writeWcc(reply, dir)
+		return
+	}
+	if releaser, ok := childHandle.(fs.HandleReleaser); ok {
+		_ = releaser.Release(context.Background(), &fuse.ReleaseRequest{})
+	}
+	s.writeNewNode(reply, dir, child)
+}
+
+func (s *server) mkdir(args *xdrReader, reply *xdrWriter) {
+	h := args.opaque()
+	name := args.string()
+	readSattr(args)
+	if args.err != nil {
+		return
+	}
+	dir, status := s.node(h)
+	if status != nfsOK {
+		reply.uint32(status)
+		writeEmptyWcc(reply)
+		return
+	}
+	mkdirer, ok := dir.Node.(fs.NodeMkdirer)
+	if !ok {
+		reply.uint32(nfsErrNotDir)
+		writeWcc(reply, dir)
+		return
+	}
+	child, err := mkdirer.Mkdir(context.Background(), &fuse.MkdirRequest{Name: name, Mode: os.ModeDir | 0775})
+	if err != nil {
+		reply.uint32(errorToStatus(err))
+		writeWcc(reply, dir)
+		return
+	}
+	s.writeNewNode(reply, dir, child)
+}
+
+// writeNewNode writes the reply to a CREATE or MKDIR of child in dir. If we
+// can't get child's attributes the client is left to look it up.
+func (s *server) writeNewNode(reply *xdrWriter, dir *node, child fs.Node) {
+	reply.uint32(nfsOK)
+	if registered, a, err := s.register(child, dir.inode); err == nil {
+		reply.bool(true)
+		reply.opaque(handle(registered.inode))
+		reply.bool(true)
+		writeAttr(reply, a)
+	} else {
+		reply.bool(false)
+		reply.bool(false)
+	}
+	writeWcc(reply, dir)
+}
+
+type dirEntry struct {
+	name  string
+	inode uint64
+}
+
+func (s *server) readDir(args *xdrReader, reply *xdrWriter, plus bool) {
+	h := args.opaque()
+	cookie := args.uint64()
+	// the cookie verifier, our listings aren't versioned
+	args.fixed(8)
+	if plus {
+		// dircount, maxcount bounds the reply on its own
+		args.uint32()
+	}
+	count := args.uint32()
+	if args.err != nil {
+		return
+	}
+	dir, status := s.node(h)
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	readDirAller, ok := dir.Node.(fs.HandleReadDirAller)
+	if !ok {
+		reply.uint32(nfsErrNotDir)
+		writePostOpAttr(reply, dir)
+		return
+	}
+	dirents, err := readDirAller.ReadDirAll(context.Background())
+	if err != nil {
+		reply.uint32(errorToStatus(err))
+		writePostOpAttr(reply, dir)
+		return
+	}
+	entries := []dirEntry{{".", dir.inode}, {"..", dir.parent}}
+	for _, dirent := range dirents {
+		entries = append(entries, dirEntry{dirent.Name, dirent.Inode})
+	}
+	// cookies are the index of the next entry
+	if cookie > uint64(len(entries)) {
+		reply.uint32(nfsErrBadCookie)
+		writePostOpAttr(reply, dir)
+		return
+	}
+	body := &xdrWriter{}
+	size := uint32(readDirOverhead)
+	eof := true
+	for i := cookie; i < uint64(len(entries)); i++ {
+		entry := entries[i]
+		entrySize := uint32(4 + 8 + 4 + (len(entry.name)+3)&^3 + 8)
+		if plus {
+			entrySize += 4 + 4
+		}
+		if size+entrySize > count {
+			eof = false
+			break
+		}
+		body.bool(true)
+		body.uint64(entry.inode)
+		body.string(entry.name)
+		body.uint64(i + 1)
+		if plus {
+			// entries don't come with attributes or handles, clients look
+			// up the ones they need rather than us looking up every one
+			body.bool(false)
+			body.bool(false)
+		}
+		size += entrySize
+	}
+	if body.Len() == 0 && !eof {
+		reply.uint32(nfsErrTooSmall)
+		writePostOpAttr(reply, dir)
+		return
+	}
+	reply.uint32(nfsOK)
+	writePostOpAttr(reply, dir)
+	reply.fixed(make([]byte, 8))
+	reply.Write(body.Bytes())
+	reply.bool(false)
+	reply.bool(eof)
+}
+
+func (s *server) fsstat(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	reply.uint32(nfsOK)
+	writePostOpAttr(reply, n)
+	// pfs doesn't know how much space it has, like the fuse filesystem we
+	// report none
+	for i := 0; i < 6; i++ {
+		reply.uint64(0)
+	}
+	reply.uint32(0)
+}
+
+func (s *server) fsinfo(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	reply.uint32(nfsOK)
+	writePostOpAttr(reply, n)
+	// rtmax, rtpref, rtmult then the same for writes
+	for i := 0; i < 2; i++ {
+		reply.uint32(maxTransferSize)
+		reply.uint32(maxTransferSize)
+		reply.uint32(4096)
+	}
+	// dtpref
+	reply.uint32(1 << 16)
+	// maxfilesize
+	reply.uint64(1<<63 - 1)
+	// time_delta
+	reply.uint32(0)
+	reply.uint32(1)
+	reply.uint32(fsfHomogeneous)
+}
+
+func (s *server) pathconf(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		reply.bool(false)
+		return
+	}
+	reply.uint32(nfsOK)
+	writePostOpAttr(reply, n)
+	// linkmax, name_max
+	reply.uint32(1)
+	reply.uint32(255)
+	// no_trunc, chown_restricted, case_insensitive, case_preserving
+	reply.bool(true)
+	reply.bool(true)
+	reply.bool(false)
+	reply.bool(true)
+}
+
+func (s *server) commit(args *xdrReader, reply *xdrWriter) {
+	n, status := s.node(args.opaque())
+	// offset and count, writes are synchronous so there's nothing to commit
+	args.uint64()
+	args.uint32()
+	if args.err != nil {
+		return
+	}
+	if status != nfsOK {
+		reply.uint32(status)
+		writeEmptyWcc(reply)
+		return
+	}
+	reply.uint32(nfsOK)
+	writeWcc(reply, n)
+	reply.fixed(s.verifier)
+}
+
+// root returns the inode of the filesystem's root, looking it up the first
+// time.
+func (s *server) root() (uint64, error) {
+	s.lock.Lock()
+	rootInode := s.rootInode
+	s.lock.Unlock()
+	if rootInode != 0 {
+		return rootInode, nil
+	}
+	root, err := s.filesystem.Root()
+	if err != nil {
+		return 0, err
+	}
+	a, err := getAttr(root)
+	if err != nil {
+		return 0, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// the root is its own parent
+	s.rootInode = a.Inode
+	s.add(&node{Node: root, inode: a.Inode, parent: a.Inode})
+	return s.rootInode, nil
+}
+
+// register gives n a handle, parent is the inode of its directory.
+func (s *server) register(n fs.Node, parent uint64) (*node, *fuse.Attr, error) {
+	a, err := getAttr(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	registered := &node{Node: n, inode: a.Inode, parent: parent}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.add(registered)
+	return registered, a, nil
+}
+
+// add adds n to nodes, replacing any node with the same inode, and drops the
+// least recently used nodes past MaxNodes. The root is never dropped. s.lock
+// must be held.
+func (s *server) add(n *node) {
+	if existing, ok := s.nodes[n.inode]; ok {
+		s.order.Remove(existing.element)
+	}
+	n.element = s.order.PushFront(n.inode)
+	s.nodes[n.inode] = n
+	for s.order.Len() > s.options.MaxNodes {
+		element := s.order.Back()
+		inode := element.Value.(uint64)
+		if inode == s.rootInode {
+			if s.order.Len() == 1 {
+				break
+			}
+			s.order.MoveToFront(element)
+			continue
+		}
+		s.order.Remove(element)
+		delete(s.nodes, inode)
+	}
+}
+
+// node returns the node of h.
+func (s *server) node(h []byte) (*node, uint32) {
+	if len(h) != handleSize {
+		return nil, nfsErrBadHandle
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	n, ok := s.nodes[binary.BigEndian.Uint64(h)]
+	if !ok {
+		return nil, nfsErrStale
+	}
+	s.order.MoveToFront(n.element)
+	return n, nfsOK
+}
+
+func (s *server) lookupChild(dir *node, name string) (*node, *fuse.Attr, uint32) {
+	var result *node
+	switch name {
+	case ".":
+		result = dir
+	case "..":
+		s.lock.Lock()
+		result = s.nodes[dir.parent]
+		s.lock.Unlock()
+		if result == nil {
+			return nil, nil, nfsErrStale
+		}
+	default:
+		lookuper, ok := dir.Node.(fs.NodeStringLookuper)
+		if !ok {
+			return nil, nil, nfsErrNotDir
+		}
+		child, err := lookuper.Lookup(context.Background(), name)
+		if err != nil {
+			return nil, nil, errorToStatus(err)
+		}
+		registered, a, err := s.register(child, dir.inode)
+		if err != nil {
+			return nil, nil, errorToStatus(err)
+		}
+		return registered, a, nfsOK
+	}
+	a, err := getAttr(result)
+	if err != nil {
+		return nil, nil, errorToStatus(err)
+	}
+	return result, a, nfsOK
+}
+
+func handle(inode uint64) []byte {
+	result := make([]byte, handleSize)
+	binary.BigEndian.PutUint64(result, inode)
+	return result
+}
+
+func getAttr(n fs.Node) (*fuse.Attr, error) {
+	var a fuse.Attr
+	if err := n.Attr(context.Background(), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func writeAttr(w *xdrWriter, a *fuse.Attr) {
+	nlink := a.Nlink
+	if nlink == 0 {
+		nlink = 1
+	}
+	if a.Mode.IsDir() {
+		w.uint32(typeDirectory)
+	} else {
+		w.uint32(typeRegular)
+	}
+	w.uint32(uint32(a.Mode.Perm()))
+	w.uint32(nlink)
+	w.uint32(a.Uid)
+	w.uint32(a.Gid)
+	w.uint64(a.Size)
+	// used
+	w.uint64(a.Size)
+	// rdev
+	w.uint64(0)
+	// fsid
+	w.uint64(0)
+	w.uint64(a.Inode)
+	// the fuse filesystem doesn't keep times, they're now so that clients
+	// don't cache what may have changed, the way fuse is told not to
+	now := time.Now()
+	for _, t := range []time.Time{a.Atime, a.Mtime, a.Ctime} {
+		if t.IsZero() {
+			t = now
+		}
+		w.uint32(uint32(t.Unix()))
+		w.uint32(uint32(t.Nanosecond()))
+	}
+}
+
+func writePostOpAttr(w *xdrWriter, n *node) {
+	a, err := getAttr(n)
+	w.bool(err == nil)
+	if err == nil {
+		writeAttr(w, a)
+	}
+}
+
+// writeWcc writes n's weak cache consistency data, we don't have its
+// attributes from before the op.
+func writeWcc(w *xdrWriter, n *node) {
+	w.bool(false)
+	writePostOpAttr(w, n)
+}
+
+func writeEmptyWcc(w *xdrWriter) {
+	w.bool(false)
+	w.bool(false)
+}
+
+type sattr struct {
+	sizeSet bool
+	size    uint64
+}
+
+// readSattr reads a sattr3, only its size means anything to pfs.
+func readSattr(r *xdrReader) sattr {
+	var result sattr
+	// mode, uid and gid
+	for i := 0; i < 3; i++ {
+		if r.bool() {
+			r.uint32()
+		}
+	}
+	if result.sizeSet = r.bool(); result.sizeSet {
+		result.size = r.uint64()
+	}
+	// atime and mtime
+	for i := 0; i < 2; i++ {
+		if r.uint32() == setToClientTime {
+			r.uint64()
+		}
+	}
+	return result
+}
+
+// errorToStatus returns the NFS status for an error from the filesystem,
+// errors which don't carry an errno are IO errors as they are with fuse.
+func errorToStatus(err error) uint32 {
+	errorNumber, ok := err.(fuse.ErrorNumber)
+	if !ok {
+		return nfsErrIO
+	}
+	switch syscall.Errno(errorNumber.Errno()) {
+	case syscall.EPERM:
+		return nfsErrPerm
+	case syscall.ENOENT:
+		return nfsErrNoEnt
+	case syscall.EACCES:
+		return nfsErrAccess
+	case syscall.EEXIST:
+		return nfsErrExist
+	case syscall.ENOTDIR:
+		return nfsErrNotDir
+	case syscall.EISDIR:
+		return nfsErrIsDir
+	case syscall.EINVAL:
+		return nfsErrInval
+	case syscall.EROFS:
+		return nfsErrROFS
+	case syscall.ENAMETOOLONG:
+		return nfsErrNameTooLong
+	case syscall.ENOSYS:
+		return nfsErrNotSupp
+	}
+	return nfsErrIO
+}
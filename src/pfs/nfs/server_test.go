@@ -0,0 +1,283 @@
+package nfs
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"golang.org/x/net/context"
+)
+
+func TestReadWrite(t *testing.T) {
+	client := newTestClient(t, map[string]*testFile{"file": {inode: 3, data: []byte("hello")}}, ServerOptions{Writable: true})
+	root := client.mount()
+	file := client.lookup(root, "file")
+
+	data, eof := client.read(file, 0, 100)
+	require.Equal(t, "hello", string(data))
+	require.True(t, eof)
+
+	reply := client.call(nfsProgram, nfsVersion, nfsProcWrite, func(w *xdrWriter) {
+		w.opaque(file)
+		w.uint64(5)
+		w.uint32(6)
+		w.uint32(0)
+		w.opaque([]byte(" world"))
+	})
+	require.Equal(t, uint32(nfsOK), reply.uint32())
+	skipWcc(reply)
+	require.Equal(t, uint32(6), reply.uint32())
+	require.Equal(t, uint32(fileSync), reply.uint32())
+
+	data, eof = client.read(file, 0, 100)
+	require.Equal(t, "hello world", string(data))
+	require.True(t, eof)
+	data, eof = client.read(file, 0, 5)
+	require.Equal(t, "hello", string(data))
+	require.False(t, eof)
+}
+
+func TestReadDir(t *testing.T) {
+	client := newTestClient(t, map[string]*testFile{"file": {inode: 3}}, ServerOptions{})
+	root := client.mount()
+
+	names, eof := client.readDir(root, 0, 1<<16)
+	require.Equal(t, []string{".", "..", "file"}, names)
+	require.True(t, eof)
+
+	// room for just the first entry
+	names, eof = client.readDir(root, 0, readDirOverhead+4+8+4+4+8)
+	require.Equal(t, []string{"."}, names)
+	require.False(t, eof)
+	names, eof = client.readDir(root, 1, 1<<16)
+	require.Equal(t, []string{"..", "file"}, names)
+	require.True(t, eof)
+}
+
+func TestStaleHandle(t *testing.T) {
+	client := newTestClient(t, nil, ServerOptions{})
+	client.mount()
+	reply := client.call(nfsProgram, nfsVersion, nfsProcGetattr, func(w *xdrWriter) {
+		w.opaque(handle(100))
+	})
+	require.Equal(t, uint32(nfsErrStale), reply.uint32())
+	reply = client.call(nfsProgram, nfsVersion, nfsProcGetattr, func(w *xdrWriter) {
+		w.opaque([]byte("bad"))
+	})
+	require.Equal(t, uint32(nfsErrBadHandle), reply.uint32())
+}
+
+func TestReadOnly(t *testing.T) {
+	client := newTestClient(t, map[string]*testFile{"file": {inode: 3, data: []byte("hello")}}, ServerOptions{})
+	root := client.mount()
+	file := client.lookup(root, "file")
+	reply := client.call(nfsProgram, nfsVersion, nfsProcWrite, func(w *xdrWriter) {
+		w.opaque(file)
+		w.uint64(0)
+		w.uint32(1)
+		w.uint32(0)
+		w.opaque([]byte("j"))
+	})
+	require.Equal(t, uint32(nfsErrROFS), reply.uint32())
+	data, _ := client.read(file, 0, 100)
+	require.Equal(t, "hello", string(data))
+}
+
+func TestMaxNodes(t *testing.T) {
+	client := newTestClient(t, map[string]*testFile{"a": {inode: 3}, "b": {inode: 4}}, ServerOptions{MaxNodes: 2})
+	root := client.mount()
+	a := client.lookup(root, "a")
+	client.lookup(root, "b")
+	// a was the least recently used and the root is never dropped
+	reply := client.call(nfsProgram, nfsVersion, nfsProcGetattr, func(w *xdrWriter) {
+		w.opaque(a)
+	})
+	require.Equal(t, uint32(nfsErrStale), reply.uint32())
+	client.lookup(root, "a")
+}
+
+func TestAllowed(t *testing.T) {
+	_, allowedClients, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	s := newServer(&testFS{&testDir{}}, ServerOptions{AllowedClients: []*net.IPNet{allowedClients}})
+	require.True(t, s.allowed(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}))
+	require.True(t, s.allowed(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}))
+	require.False(t, s.allowed(&net.TCPAddr{IP: net.ParseIP("192.168.1.1")}))
+}
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	xid  uint32
+}
+
+func newTestClient(t *testing.T, files map[string]*testFile, options ServerOptions) *testClient {
+	clientConn, serverConn := net.Pipe()
+	go serveConn(serverConn, newServer(&testFS{&testDir{files}}, options).handle)
+	return &testClient{t: t, conn: clientConn}
+}
+
+// call makes a call and returns its results.
+func (c *testClient) call(program uint32, version uint32, proc uint32, args func(*xdrWriter)) *xdrReader {
+	c.xid++
+	w := &xdrWriter{}
+	w.uint32(c.xid)
+	w.uint32(rpcCall)
+	w.uint32(rpcVersion)
+	w.uint32(program)
+	w.uint32(version)
+	w.uint32(proc)
+	for i := 0; i < 2; i++ {
+		w.uint32(authNone)
+		w.opaque(nil)
+	}
+	if args != nil {
+		args(w)
+	}
+	require.NoError(c.t, writeRecord(c.conn, w.Bytes()))
+	record, err := readRecord(c.conn)
+	require.NoError(c.t, err)
+	r := &xdrReader{buf: record}
+	require.Equal(c.t, c.xid, r.uint32())
+	require.Equal(c.t, uint32(rpcReply), r.uint32())
+	require.Equal(c.t, uint32(msgAccepted), r.uint32())
+	r.uint32()
+	r.opaque()
+	require.Equal(c.t, uint32(acceptSuccess), r.uint32())
+	return r
+}
+
+func (c *testClient) mount() []byte {
+	reply := c.call(mountProgram, mountVersion, mountProcMnt, func(w *xdrWriter) {
+		w.string("/")
+	})
+	require.Equal(c.t, uint32(mountOK), reply.uint32())
+	return reply.opaque()
+}
+
+func (c *testClient) lookup(dir []byte, name string) []byte {
+	reply := c.call(nfsProgram, nfsVersion, nfsProcLookup, func(w *xdrWriter) {
+		w.opaque(dir)
+		w.string(name)
+	})
+	require.Equal(c.t, uint32(nfsOK), reply.uint32())
+	return reply.opaque()
+}
+
+func (c *testClient) read(file []byte, offset uint64, count uint32) ([]byte, bool) {
+	reply := c.call(nfsProgram, nfsVersion, nfsProcRead, func(w *xdrWriter) {
+		w.opaque(file)
+		w.uint64(offset)
+		w.uint32(count)
+	})
+	require.Equal(c.t, uint32(nfsOK), reply.uint32())
+	skipPostOpAttr(reply)
+	reply.uint32()
+	eof := reply.bool()
+	return reply.opaque(), eof
+}
+
+func (c *testClient) readDir(dir []byte, cookie uint64, count uint32) ([]string, bool) {
+	reply := c.call(nfsProgram, nfsVersion, nfsProcReaddir, func(w *xdrWriter) {
+		w.opaque(dir)
+		w.uint64(cookie)
+		w.fixed(make([]byte, 8))
+		w.uint32(count)
+	})
+	require.Equal(c.t, uint32(nfsOK), reply.uint32())
+	skipPostOpAttr(reply)
+	reply.fixed(8)
+	var names []string
+	for reply.bool() {
+		reply.uint64()
+		names = append(names, reply.string())
+		reply.uint64()
+	}
+	return names, reply.bool()
+}
+
+func skipPostOpAttr(r *xdrReader) {
+	if r.bool() {
+		r.fixed(attrSize)
+	}
+}
+
+func skipWcc(r *xdrReader) {
+	if r.bool() {
+		r.fixed(24)
+	}
+	skipPostOpAttr(r)
+}
+
+type testFS struct {
+	root *testDir
+}
+
+func (f *testFS) Root() (fs.Node, error) {
+	return f.root, nil
+}
+
+type testDir struct {
+	files map[string]*testFile
+}
+
+func (d *testDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = 1
+	a.Mode = os.ModeDir | 0775
+	return nil
+}
+
+func (d *testDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	file, ok := d.files[name]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+	return file, nil
+}
+
+func (d *testDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var result []fuse.Dirent
+	for name, file := range d.files {
+		result = append(result, fuse.Dirent{Inode: file.inode, Name: name, Type: fuse.DT_File})
+	}
+	return result, nil
+}
+
+type testFile struct {
+	inode uint64
+	data  []byte
+}
+
+func (f *testFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = f.inode
+	a.Mode = 0666
+	a.Size = uint64(len(f.data))
+	return nil
+}
+
+func (f *testFile) Read(ctx context.Context, request *fuse.ReadRequest, response *fuse.ReadResponse) error {
+	if request.Offset >= int64(len(f.data)) {
+		return nil
+	}
+	end := request.Offset + int64(request.Size)
+	if end > int64(len(f.data)) {
+		end = int64(len(f.data))
+	}
+	response.Data = f.data[request.Offset:end]
+	return nil
+}
+
+func (f *testFile) Write(ctx context.Context, request *fuse.WriteRequest, response *fuse.WriteResponse) error {
+	end := request.Offset + int64(len(request.Data))
+	if end > int64(len(f.data)) {
+		data := make([]byte, end)
+		copy(data, f.data)
+		f.data = data
+	}
+	copy(f.data[request.Offset:], request.Data)
+	response.Size = len(request.Data)
+	return nil
+}
@@ -2,6 +2,34 @@ package pfs
 
 import (
 	"errors"
+	"strings"
+	"time"
 )
 
 var ErrFileNotFound error = errors.New("file not found")
+
+// FailedServersTrailer is the trailer a partial ListFile sets to the addresses
+// of the servers which failed, the files on them are missing from the
+// listing. It isn't set when every server answered.
+const FailedServersTrailer = "failed-servers"
+
+// asOfPrefix starts a commit id which refers to the last commit in a repo
+// finished at or before a time rather than to a commit itself, ie
+// "@2015-11-03T15:04:05Z" or "@2015-11-03".
+const asOfPrefix = "@"
+
+var asOfLayouts = []string{time.RFC3339Nano, "2006-01-02"}
+
+// ParseAsOfCommitID returns the time an as of commit id refers to, ok is
+// false if id isn't an as of commit id.
+func ParseAsOfCommitID(id string) (_ time.Time, ok bool) {
+	if !strings.HasPrefix(id, asOfPrefix) {
+		return time.Time{}, false
+	}
+	for _, layout := range asOfLayouts {
+		if t, err := time.Parse(layout, strings.TrimPrefix(id, asOfPrefix)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -15,28 +15,80 @@ It has these top-level messages:
 	Server
 	RepoInfo
 	RepoInfos
+	RepoDedupStats
+	RepoDedupStats
+	RepoDedupStatsList
+	ShardDeletion
+	ShardDeletions
+	TrashInfo
+	TrashInfos
 	CommitInfo
 	CommitInfos
+	TagInfo
+	TagInfos
 	FileInfo
 	FileInfos
+	FileBlock
+	FileBlocks
+	FileSample
+	ValueCount
+	FieldStats
+	FileStats
+	RepoStats
+	ServerStats
 	ServerInfo
 	ServerInfos
+	SlowOp
+	SlowOps
+	Op
+	Ops
+	CancelOpRequest
+	ShardInfo
+	ShardInfos
+	ReplicaInfo
+	ReplicaLag
 	Shard
+	ConsistencyToken
+	PinVersionRequest
+	VersionPin
 	CreateRepoRequest
 	InspectRepoRequest
 	ListRepoRequest
+	DedupStatsRequest
+	DedupStatsRequest
 	DeleteRepoRequest
+	RestoreRepoRequest
 	StartCommitRequest
 	FinishCommitRequest
+	Transaction
+	FinishTransactionRequest
+	MergeCommitsRequest
+	MergeCommitsRequest
 	InspectCommitRequest
 	ListCommitRequest
 	DeleteCommitRequest
+	RestoreCommitRequest
+	TagCommitRequest
+	ListTagRequest
+	ListTrashRequest
+	EmptyTrashRequest
 	GetFileRequest
+	GetTarRequest
+	SampleFileRequest
+	StatsFileRequest
+	StatsRepoRequest
 	PutFileRequest
 	InspectFileRequest
 	MakeDirectoryRequest
 	ListFileRequest
 	DeleteFileRequest
+	CopyFileRequest
+	StartMultipartPutRequest
+	MultipartPut
+	PutPartRequest
+	ConcatFilesRequest
+	InspectShardRequest
+	InspectLocalShardRequest
 */
 package pfs
 
@@ -47,6 +99,7 @@ import _ "github.com/gengo/grpc-gateway/third_party/googleapis/google/api"
 import google_protobuf1 "go.pedge.io/google-protobuf"
 import google_protobuf2 "go.pedge.io/google-protobuf"
 import google_protobuf3 "go.pedge.io/google-protobuf"
+import google_protobuf4 "go.pedge.io/google-protobuf"
 import shard "github.com/pachyderm/pachyderm/src/pkg/shard"
 
 import (
@@ -107,6 +160,103 @@ func (x FileType) String() string {
 	return proto.EnumName(FileType_name, int32(x))
 }
 
+// RecordFormat is how SampleFile splits a file into records.
+type RecordFormat int32
+
+const (
+	// RECORD_FORMAT_LINES is a record per line, ie JSON lines.
+	RecordFormat_RECORD_FORMAT_LINES RecordFormat = 0
+	// RECORD_FORMAT_CSV is a record per CSV row, quoted fields may contain
+	// newlines. The first row is the header.
+	RecordFormat_RECORD_FORMAT_CSV RecordFormat = 1
+)
+
+var RecordFormat_name = map[int32]string{
+	0: "RECORD_FORMAT_LINES",
+	1: "RECORD_FORMAT_CSV",
+}
+var RecordFormat_value = map[string]int32{
+	"RECORD_FORMAT_LINES": 0,
+	"RECORD_FORMAT_CSV":   1,
+}
+
+func (x RecordFormat) String() string {
+	return proto.EnumName(RecordFormat_name, int32(x))
+}
+
+// FieldType is the type StatsFile infers for a field from its values.
+type FieldType int32
+
+const (
+	// FIELD_TYPE_NULL is a field whose sampled values were all null or empty.
+	FieldType_FIELD_TYPE_NULL FieldType = 0
+	FieldType_FIELD_TYPE_BOOL FieldType = 1
+	FieldType_FIELD_TYPE_INT  FieldType = 2
+	// FIELD_TYPE_FLOAT is also a field with both int and float values.
+	FieldType_FIELD_TYPE_FLOAT  FieldType = 3
+	FieldType_FIELD_TYPE_STRING FieldType = 4
+	FieldType_FIELD_TYPE_OBJECT FieldType = 5
+	FieldType_FIELD_TYPE_ARRAY  FieldType = 6
+	// FIELD_TYPE_MIXED is a field with values of several other types.
+	FieldType_FIELD_TYPE_MIXED FieldType = 7
+)
+
+var FieldType_name = map[int32]string{
+	0: "FIELD_TYPE_NULL",
+	1: "FIELD_TYPE_BOOL",
+	2: "FIELD_TYPE_INT",
+	3: "FIELD_TYPE_FLOAT",
+	4: "FIELD_TYPE_STRING",
+	5: "FIELD_TYPE_OBJECT",
+	6: "FIELD_TYPE_ARRAY",
+	7: "FIELD_TYPE_MIXED",
+}
+var FieldType_value = map[string]int32{
+	"FIELD_TYPE_NULL":   0,
+	"FIELD_TYPE_BOOL":   1,
+	"FIELD_TYPE_INT":    2,
+	"FIELD_TYPE_FLOAT":  3,
+	"FIELD_TYPE_STRING": 4,
+	"FIELD_TYPE_OBJECT": 5,
+	"FIELD_TYPE_ARRAY":  6,
+	"FIELD_TYPE_MIXED":  7,
+}
+
+func (x FieldType) String() string {
+	return proto.EnumName(FieldType_name, int32(x))
+}
+
+// MergeStrategy is how MergeCommits merges a file changed on both sides.
+type MergeStrategy int32
+
+const (
+	// MERGE_STRATEGY_ERROR fails the merge.
+	MergeStrategy_MERGE_STRATEGY_ERROR MergeStrategy = 0
+	// MERGE_STRATEGY_OURS keeps the file as it is in ours.
+	MergeStrategy_MERGE_STRATEGY_OURS MergeStrategy = 1
+	// MERGE_STRATEGY_THEIRS replaces the file with the one in theirs.
+	MergeStrategy_MERGE_STRATEGY_THEIRS MergeStrategy = 2
+	// MERGE_STRATEGY_UNION appends what theirs appended to the file to ours.
+	MergeStrategy_MERGE_STRATEGY_UNION MergeStrategy = 3
+)
+
+var MergeStrategy_name = map[int32]string{
+	0: "MERGE_STRATEGY_ERROR",
+	1: "MERGE_STRATEGY_OURS",
+	2: "MERGE_STRATEGY_THEIRS",
+	3: "MERGE_STRATEGY_UNION",
+}
+var MergeStrategy_value = map[string]int32{
+	"MERGE_STRATEGY_ERROR":  0,
+	"MERGE_STRATEGY_OURS":   1,
+	"MERGE_STRATEGY_THEIRS": 2,
+	"MERGE_STRATEGY_UNION":  3,
+}
+
+func (x MergeStrategy) String() string {
+	return proto.EnumName(MergeStrategy_name, int32(x))
+}
+
 // Repo represents a repo.
 type Repo struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
@@ -161,9 +311,15 @@ func (*Server) ProtoMessage()    {}
 
 // RepoInfo represent information about a repo.
 type RepoInfo struct {
-	Repo      *Repo                       `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
-	Created   *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
-	SizeBytes uint64                      `protobuf:"varint,3,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	Repo               *Repo                       `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	Created            *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
+	SizeBytes          uint64                      `protobuf:"varint,3,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	CommitCount        uint64                      `protobuf:"varint,4,opt,name=commit_count" json:"commit_count,omitempty"`
+	LastCommit         *Commit                     `protobuf:"bytes,5,opt,name=last_commit" json:"last_commit,omitempty"`
+	LastCommitFinished *google_protobuf2.Timestamp `protobuf:"bytes,6,opt,name=last_commit_finished" json:"last_commit_finished,omitempty"`
+	// ttl and expires are only set for scratch repos.
+	Ttl     *google_protobuf4.Duration  `protobuf:"bytes,7,opt,name=ttl" json:"ttl,omitempty"`
+	Expires *google_protobuf2.Timestamp `protobuf:"bytes,8,opt,name=expires" json:"expires,omitempty"`
 }
 
 func (m *RepoInfo) Reset()         { *m = RepoInfo{} }
@@ -184,6 +340,34 @@ func (m *RepoInfo) GetCreated() *google_protobuf2.Timestamp {
 	return nil
 }
 
+func (m *RepoInfo) GetLastCommit() *Commit {
+	if m != nil {
+		return m.LastCommit
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetLastCommitFinished() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.LastCommitFinished
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetTtl() *google_protobuf4.Duration {
+	if m != nil {
+		return m.Ttl
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetExpires() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Expires
+	}
+	return nil
+}
+
 type RepoInfos struct {
 	RepoInfo []*RepoInfo `protobuf:"bytes,1,rep,name=repo_info" json:"repo_info,omitempty"`
 }
@@ -199,6 +383,146 @@ func (m *RepoInfos) GetRepoInfo() []*RepoInfo {
 	return nil
 }
 
+// RepoDedupStats is how much of a repo's storage is shared between its
+// commits.
+type RepoDedupStats struct {
+	Repo        *Repo  `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	CommitCount uint64 `protobuf:"varint,2,opt,name=commit_count" json:"commit_count,omitempty"`
+	// logical_bytes is the sum of the sizes of the repo's commits, each
+	// counting the data it has from its ancestors.
+	LogicalBytes uint64 `protobuf:"varint,3,opt,name=logical_bytes" json:"logical_bytes,omitempty"`
+	// referenced_bytes is the sum of the sizes of the repo's diffs, data copied
+	// from another file is counted again.
+	ReferencedBytes uint64 `protobuf:"varint,4,opt,name=referenced_bytes" json:"referenced_bytes,omitempty"`
+	// physical_bytes is the size of the distinct block ranges the repo's diffs
+	// reference, a range referenced from several shards is counted once per
+	// server.
+	PhysicalBytes   uint64       `protobuf:"varint,5,opt,name=physical_bytes" json:"physical_bytes,omitempty"`
+	BlockRefs       uint64       `protobuf:"varint,6,opt,name=block_refs" json:"block_refs,omitempty"`
+	UniqueBlockRefs uint64       `protobuf:"varint,7,opt,name=unique_block_refs" json:"unique_block_refs,omitempty"`
+	UniqueBlocks    []*FileBlock `protobuf:"bytes,8,rep,name=unique_blocks" json:"unique_blocks,omitempty"`
+}
+
+func (m *RepoDedupStats) Reset()         { *m = RepoDedupStats{} }
+func (m *RepoDedupStats) String() string { return proto.CompactTextString(m) }
+func (*RepoDedupStats) ProtoMessage()    {}
+
+func (m *RepoDedupStats) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+func (m *RepoDedupStats) GetUniqueBlocks() []*FileBlock {
+	if m != nil {
+		return m.UniqueBlocks
+	}
+	return nil
+}
+
+type RepoDedupStatsList struct {
+	RepoDedupStats []*RepoDedupStats `protobuf:"bytes,1,rep,name=repo_dedup_stats" json:"repo_dedup_stats,omitempty"`
+}
+
+func (m *RepoDedupStatsList) Reset()         { *m = RepoDedupStatsList{} }
+func (m *RepoDedupStatsList) String() string { return proto.CompactTextString(m) }
+func (*RepoDedupStatsList) ProtoMessage()    {}
+
+func (m *RepoDedupStatsList) GetRepoDedupStats() []*RepoDedupStats {
+	if m != nil {
+		return m.RepoDedupStats
+	}
+	return nil
+}
+
+// ShardDeletion is the progress of deleting a repo from a shard.
+type ShardDeletion struct {
+	Shard uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	// diffs_deleted is the number of the repo's diffs removed from the shard.
+	DiffsDeleted uint64 `protobuf:"varint,2,opt,name=diffs_deleted" json:"diffs_deleted,omitempty"`
+	// error is set if the shard wasn't completely deleted, deleting the repo
+	// again picks up where it left off.
+	Error string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ShardDeletion) Reset()         { *m = ShardDeletion{} }
+func (m *ShardDeletion) String() string { return proto.CompactTextString(m) }
+func (*ShardDeletion) ProtoMessage()    {}
+
+type ShardDeletions struct {
+	ShardDeletion []*ShardDeletion `protobuf:"bytes,1,rep,name=shard_deletion" json:"shard_deletion,omitempty"`
+}
+
+func (m *ShardDeletions) Reset()         { *m = ShardDeletions{} }
+func (m *ShardDeletions) String() string { return proto.CompactTextString(m) }
+func (*ShardDeletions) ProtoMessage()    {}
+
+func (m *ShardDeletions) GetShardDeletion() []*ShardDeletion {
+	if m != nil {
+		return m.ShardDeletion
+	}
+	return nil
+}
+
+// TrashInfo represents a deleted repo or commit which can still be restored.
+type TrashInfo struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	// commit is unset if the whole repo was deleted.
+	Commit  *Commit                     `protobuf:"bytes,2,opt,name=commit" json:"commit,omitempty"`
+	Deleted *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=deleted" json:"deleted,omitempty"`
+	// expires is when the trash is emptied, after which the repo or commit
+	// can't be restored.
+	Expires *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=expires" json:"expires,omitempty"`
+}
+
+func (m *TrashInfo) Reset()         { *m = TrashInfo{} }
+func (m *TrashInfo) String() string { return proto.CompactTextString(m) }
+func (*TrashInfo) ProtoMessage()    {}
+
+func (m *TrashInfo) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+func (m *TrashInfo) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *TrashInfo) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
+func (m *TrashInfo) GetExpires() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Expires
+	}
+	return nil
+}
+
+type TrashInfos struct {
+	TrashInfo []*TrashInfo `protobuf:"bytes,1,rep,name=trash_info" json:"trash_info,omitempty"`
+}
+
+func (m *TrashInfos) Reset()         { *m = TrashInfos{} }
+func (m *TrashInfos) String() string { return proto.CompactTextString(m) }
+func (*TrashInfos) ProtoMessage()    {}
+
+func (m *TrashInfos) GetTrashInfo() []*TrashInfo {
+	if m != nil {
+		return m.TrashInfo
+	}
+	return nil
+}
+
 // CommitInfo represents information about a commit.
 type CommitInfo struct {
 	Commit       *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
@@ -207,6 +531,15 @@ type CommitInfo struct {
 	Started      *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=started" json:"started,omitempty"`
 	Finished     *google_protobuf2.Timestamp `protobuf:"bytes,5,opt,name=finished" json:"finished,omitempty"`
 	SizeBytes    uint64                      `protobuf:"varint,6,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	// files_added and size_delta are relative to the parent commit, they're
+	// only set once the commit is finished. Deleted files aren't counted since
+	// DeleteFile doesn't remove anything yet, so size_delta is never negative.
+	FilesAdded uint64   `protobuf:"varint,7,opt,name=files_added" json:"files_added,omitempty"`
+	SizeDelta  int64    `protobuf:"varint,9,opt,name=size_delta" json:"size_delta,omitempty"`
+	Tags       []string `protobuf:"bytes,10,rep,name=tags" json:"tags,omitempty"`
+	// merged_commit is the commit which was merged into parent_commit to make
+	// a merge commit.
+	MergedCommit *Commit `protobuf:"bytes,11,opt,name=merged_commit" json:"merged_commit,omitempty"`
 }
 
 func (m *CommitInfo) Reset()         { *m = CommitInfo{} }
@@ -241,6 +574,13 @@ func (m *CommitInfo) GetFinished() *google_protobuf2.Timestamp {
 	return nil
 }
 
+func (m *CommitInfo) GetMergedCommit() *Commit {
+	if m != nil {
+		return m.MergedCommit
+	}
+	return nil
+}
+
 type CommitInfos struct {
 	CommitInfo []*CommitInfo `protobuf:"bytes,1,rep,name=commit_info" json:"commit_info,omitempty"`
 }
@@ -256,6 +596,38 @@ func (m *CommitInfos) GetCommitInfo() []*CommitInfo {
 	return nil
 }
 
+// TagInfo represents a name given to a commit.
+type TagInfo struct {
+	Tag    string  `protobuf:"bytes,1,opt,name=tag" json:"tag,omitempty"`
+	Commit *Commit `protobuf:"bytes,2,opt,name=commit" json:"commit,omitempty"`
+}
+
+func (m *TagInfo) Reset()         { *m = TagInfo{} }
+func (m *TagInfo) String() string { return proto.CompactTextString(m) }
+func (*TagInfo) ProtoMessage()    {}
+
+func (m *TagInfo) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+type TagInfos struct {
+	TagInfo []*TagInfo `protobuf:"bytes,1,rep,name=tag_info" json:"tag_info,omitempty"`
+}
+
+func (m *TagInfos) Reset()         { *m = TagInfos{} }
+func (m *TagInfos) String() string { return proto.CompactTextString(m) }
+func (*TagInfos) ProtoMessage()    {}
+
+func (m *TagInfos) GetTagInfo() []*TagInfo {
+	if m != nil {
+		return m.TagInfo
+	}
+	return nil
+}
+
 // FileInfo represents information about a file.
 type FileInfo struct {
 	File           *File                       `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
@@ -314,11 +686,174 @@ func (m *FileInfos) GetFileInfo() []*FileInfo {
 	return nil
 }
 
+// FileBlock is the range of a block which holds part of a file.
+type FileBlock struct {
+	Hash  string `protobuf:"bytes,1,opt,name=hash" json:"hash,omitempty"`
+	Lower uint64 `protobuf:"varint,2,opt,name=lower" json:"lower,omitempty"`
+	Upper uint64 `protobuf:"varint,3,opt,name=upper" json:"upper,omitempty"`
+}
+
+func (m *FileBlock) Reset()         { *m = FileBlock{} }
+func (m *FileBlock) String() string { return proto.CompactTextString(m) }
+func (*FileBlock) ProtoMessage()    {}
+
+type FileBlocks struct {
+	FileBlock []*FileBlock `protobuf:"bytes,1,rep,name=file_block" json:"file_block,omitempty"`
+}
+
+func (m *FileBlocks) Reset()         { *m = FileBlocks{} }
+func (m *FileBlocks) String() string { return proto.CompactTextString(m) }
+func (*FileBlocks) ProtoMessage()    {}
+
+func (m *FileBlocks) GetFileBlock() []*FileBlock {
+	if m != nil {
+		return m.FileBlock
+	}
+	return nil
+}
+
+// FileSample is some of the records in a file.
+type FileSample struct {
+	// header is the header row of a CSV file, it isn't counted as a record.
+	Header []byte   `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Record [][]byte `protobuf:"bytes,2,rep,name=record,proto3" json:"record,omitempty"`
+	// records_scanned is how many records were read to take the sample.
+	RecordsScanned uint64 `protobuf:"varint,3,opt,name=records_scanned" json:"records_scanned,omitempty"`
+}
+
+func (m *FileSample) Reset()         { *m = FileSample{} }
+func (m *FileSample) String() string { return proto.CompactTextString(m) }
+func (*FileSample) ProtoMessage()    {}
+
+// ValueCount is how many times a value was sampled.
+type ValueCount struct {
+	Value string `protobuf:"bytes,1,opt,name=value" json:"value,omitempty"`
+	Count uint64 `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *ValueCount) Reset()         { *m = ValueCount{} }
+func (m *ValueCount) String() string { return proto.CompactTextString(m) }
+func (*ValueCount) ProtoMessage()    {}
+
+// FieldStats describes a field of the records in a sample. The fields of a
+// JSON lines file are the top level keys of its objects, a CSV file's are its
+// columns.
+type FieldStats struct {
+	Name string    `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Type FieldType `protobuf:"varint,2,opt,name=type,enum=pfs.FieldType" json:"type,omitempty"`
+	// null_rate is the fraction of the sampled records where the field was
+	// missing, null or empty.
+	NullRate float64 `protobuf:"fixed64,3,opt,name=null_rate" json:"null_rate,omitempty"`
+	// distinct is how many distinct values were sampled.
+	Distinct uint64 `protobuf:"varint,4,opt,name=distinct" json:"distinct,omitempty"`
+	// min, max and mean are over the numeric values, they're only set for int
+	// and float fields.
+	Min  float64 `protobuf:"fixed64,5,opt,name=min" json:"min,omitempty"`
+	Max  float64 `protobuf:"fixed64,6,opt,name=max" json:"max,omitempty"`
+	Mean float64 `protobuf:"fixed64,7,opt,name=mean" json:"mean,omitempty"`
+	// top_value is the most common values, most common first, it's empty for
+	// object and array fields.
+	TopValue []*ValueCount `protobuf:"bytes,8,rep,name=top_value" json:"top_value,omitempty"`
+}
+
+func (m *FieldStats) Reset()         { *m = FieldStats{} }
+func (m *FieldStats) String() string { return proto.CompactTextString(m) }
+func (*FieldStats) ProtoMessage()    {}
+
+func (m *FieldStats) GetTopValue() []*ValueCount {
+	if m != nil {
+		return m.TopValue
+	}
+	return nil
+}
+
+// FileStats is what StatsFile infers about a file from a sample of its
+// records.
+type FileStats struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// records_sampled is how many records the stats are computed from,
+	// records_scanned is how many were read to take the sample.
+	RecordsSampled uint64        `protobuf:"varint,2,opt,name=records_sampled" json:"records_sampled,omitempty"`
+	RecordsScanned uint64        `protobuf:"varint,3,opt,name=records_scanned" json:"records_scanned,omitempty"`
+	FieldStats     []*FieldStats `protobuf:"bytes,4,rep,name=field_stats" json:"field_stats,omitempty"`
+}
+
+func (m *FileStats) Reset()         { *m = FileStats{} }
+func (m *FileStats) String() string { return proto.CompactTextString(m) }
+func (*FileStats) ProtoMessage()    {}
+
+func (m *FileStats) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *FileStats) GetFieldStats() []*FieldStats {
+	if m != nil {
+		return m.FieldStats
+	}
+	return nil
+}
+
+// RepoStats is the FileStats of every file in a commit.
+type RepoStats struct {
+	FileStats []*FileStats `protobuf:"bytes,1,rep,name=file_stats" json:"file_stats,omitempty"`
+}
+
+func (m *RepoStats) Reset()         { *m = RepoStats{} }
+func (m *RepoStats) String() string { return proto.CompactTextString(m) }
+func (*RepoStats) ProtoMessage()    {}
+
+func (m *RepoStats) GetFileStats() []*FileStats {
+	if m != nil {
+		return m.FileStats
+	}
+	return nil
+}
+
+// ServerStats is the load on a server. The counts are since the server
+// started, rates come from the difference between two ServerStats.
+type ServerStats struct {
+	// size_bytes is the size of the repos in the server's master shards.
+	SizeBytes uint64 `protobuf:"varint,1,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	// the grpc messages the server has received and sent, and their bytes
+	MessagesReceived uint64                      `protobuf:"varint,2,opt,name=messages_received" json:"messages_received,omitempty"`
+	BytesReceived    uint64                      `protobuf:"varint,3,opt,name=bytes_received" json:"bytes_received,omitempty"`
+	MessagesSent     uint64                      `protobuf:"varint,4,opt,name=messages_sent" json:"messages_sent,omitempty"`
+	BytesSent        uint64                      `protobuf:"varint,5,opt,name=bytes_sent" json:"bytes_sent,omitempty"`
+	Time             *google_protobuf2.Timestamp `protobuf:"bytes,6,opt,name=time" json:"time,omitempty"`
+	// addresses_cache_stats are the stats of the server's cache of the
+	// sharder's addresses.
+	AddressesCacheStats *shard.AddressesCacheStats `protobuf:"bytes,7,opt,name=addresses_cache_stats" json:"addresses_cache_stats,omitempty"`
+}
+
+func (m *ServerStats) Reset()         { *m = ServerStats{} }
+func (m *ServerStats) String() string { return proto.CompactTextString(m) }
+func (*ServerStats) ProtoMessage()    {}
+
+func (m *ServerStats) GetTime() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *ServerStats) GetAddressesCacheStats() *shard.AddressesCacheStats {
+	if m != nil {
+		return m.AddressesCacheStats
+	}
+	return nil
+}
+
 // ServerInfo represents information about a server.
 type ServerInfo struct {
 	Server      *Server                     `protobuf:"bytes,1,opt,name=server" json:"server,omitempty"`
 	ServerState *shard.ServerState          `protobuf:"bytes,2,opt,name=server_state" json:"server_state,omitempty"`
 	ServerRole  map[int64]*shard.ServerRole `protobuf:"bytes,3,rep,name=server_role" json:"server_role,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ServerStats *ServerStats                `protobuf:"bytes,4,opt,name=server_stats" json:"server_stats,omitempty"`
+	// error is set if the server couldn't be inspected.
+	Error string `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
 }
 
 func (m *ServerInfo) Reset()         { *m = ServerInfo{} }
@@ -346,6 +881,13 @@ func (m *ServerInfo) GetServerRole() map[int64]*shard.ServerRole {
 	return nil
 }
 
+func (m *ServerInfo) GetServerStats() *ServerStats {
+	if m != nil {
+		return m.ServerStats
+	}
+	return nil
+}
+
 type ServerInfos struct {
 	ServerInfo []*ServerInfo `protobuf:"bytes,1,rep,name=server_info" json:"server_info,omitempty"`
 }
@@ -361,494 +903,3042 @@ func (m *ServerInfos) GetServerInfo() []*ServerInfo {
 	return nil
 }
 
-// Shard represents a dynamic shard within pfs.
-// number must always be less than modulo.
-type Shard struct {
-	FileNumber   uint64 `protobuf:"varint,1,opt,name=file_number" json:"file_number,omitempty"`
-	FileModulus  uint64 `protobuf:"varint,2,opt,name=file_modulus" json:"file_modulus,omitempty"`
-	BlockNumber  uint64 `protobuf:"varint,3,opt,name=block_number" json:"block_number,omitempty"`
-	BlockModulus uint64 `protobuf:"varint,4,opt,name=block_modulus" json:"block_modulus,omitempty"`
+// SlowOp is an rpc to a pfs server which took longer than the slow op
+// threshold.
+type SlowOp struct {
+	// address is the server which served the rpc.
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Method  string `protobuf:"bytes,2,opt,name=method" json:"method,omitempty"`
+	// request is the start of the request's text form.
+	Request string `protobuf:"bytes,3,opt,name=request" json:"request,omitempty"`
+	// shard is the shard the request's file is in, it's unset for requests
+	// which aren't about a file.
+	Shard    *google_protobuf3.UInt64Value `protobuf:"bytes,4,opt,name=shard" json:"shard,omitempty"`
+	Duration *google_protobuf4.Duration    `protobuf:"bytes,5,opt,name=duration" json:"duration,omitempty"`
+	// bytes is the size of the request and response, for streams it's the
+	// bytes streamed.
+	Bytes uint64                      `protobuf:"varint,6,opt,name=bytes" json:"bytes,omitempty"`
+	Error string                      `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+	Time  *google_protobuf2.Timestamp `protobuf:"bytes,8,opt,name=time" json:"time,omitempty"`
+}
+
+func (m *SlowOp) Reset()         { *m = SlowOp{} }
+func (m *SlowOp) String() string { return proto.CompactTextString(m) }
+func (*SlowOp) ProtoMessage()    {}
+
+func (m *SlowOp) GetShard() *google_protobuf3.UInt64Value {
+	if m != nil {
+		return m.Shard
+	}
+	return nil
 }
 
-func (m *Shard) Reset()         { *m = Shard{} }
-func (m *Shard) String() string { return proto.CompactTextString(m) }
-func (*Shard) ProtoMessage()    {}
+func (m *SlowOp) GetDuration() *google_protobuf4.Duration {
+	if m != nil {
+		return m.Duration
+	}
+	return nil
+}
 
-type CreateRepoRequest struct {
-	Repo    *Repo                       `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
-	Created *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
+func (m *SlowOp) GetTime() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
 }
 
-func (m *CreateRepoRequest) Reset()         { *m = CreateRepoRequest{} }
-func (m *CreateRepoRequest) String() string { return proto.CompactTextString(m) }
-func (*CreateRepoRequest) ProtoMessage()    {}
+type SlowOps struct {
+	SlowOp []*SlowOp `protobuf:"bytes,1,rep,name=slow_op" json:"slow_op,omitempty"`
+}
 
-func (m *CreateRepoRequest) GetRepo() *Repo {
+func (m *SlowOps) Reset()         { *m = SlowOps{} }
+func (m *SlowOps) String() string { return proto.CompactTextString(m) }
+func (*SlowOps) ProtoMessage()    {}
+
+func (m *SlowOps) GetSlowOp() []*SlowOp {
 	if m != nil {
-		return m.Repo
+		return m.SlowOp
 	}
 	return nil
 }
 
-func (m *CreateRepoRequest) GetCreated() *google_protobuf2.Timestamp {
+// Op is an rpc which a server is serving.
+type Op struct {
+	// id identifies the op on its server.
+	Id uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	// address is the server which is serving the rpc.
+	Address string `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	Method  string `protobuf:"bytes,3,opt,name=method" json:"method,omitempty"`
+	// peer is the principal which made the rpc, see audit.Principal.
+	Peer string `protobuf:"bytes,4,opt,name=peer" json:"peer,omitempty"`
+	// shard is the shard the request's file is in, it's unset for requests
+	// which aren't about a file.
+	Shard *google_protobuf3.UInt64Value `protobuf:"bytes,5,opt,name=shard" json:"shard,omitempty"`
+	// request is the start of the request's text form, it's empty for rpcs
+	// which stream their requests.
+	Request string                      `protobuf:"bytes,6,opt,name=request" json:"request,omitempty"`
+	Started *google_protobuf2.Timestamp `protobuf:"bytes,7,opt,name=started" json:"started,omitempty"`
+}
+
+func (m *Op) Reset()         { *m = Op{} }
+func (m *Op) String() string { return proto.CompactTextString(m) }
+func (*Op) ProtoMessage()    {}
+
+func (m *Op) GetShard() *google_protobuf3.UInt64Value {
 	if m != nil {
-		return m.Created
+		return m.Shard
 	}
 	return nil
 }
 
-type InspectRepoRequest struct {
-	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+func (m *Op) GetStarted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Started
+	}
+	return nil
 }
 
-func (m *InspectRepoRequest) Reset()         { *m = InspectRepoRequest{} }
-func (m *InspectRepoRequest) String() string { return proto.CompactTextString(m) }
-func (*InspectRepoRequest) ProtoMessage()    {}
+type Ops struct {
+	Op []*Op `protobuf:"bytes,1,rep,name=op" json:"op,omitempty"`
+}
 
-func (m *InspectRepoRequest) GetRepo() *Repo {
+func (m *Ops) Reset()         { *m = Ops{} }
+func (m *Ops) String() string { return proto.CompactTextString(m) }
+func (*Ops) ProtoMessage()    {}
+
+func (m *Ops) GetOp() []*Op {
 	if m != nil {
-		return m.Repo
+		return m.Op
 	}
 	return nil
 }
 
-type ListRepoRequest struct {
+type CancelOpRequest struct {
+	// address is the server serving the op, it's ignored by InternalAPI.
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Id      uint64 `protobuf:"varint,2,opt,name=id" json:"id,omitempty"`
 }
 
-func (m *ListRepoRequest) Reset()         { *m = ListRepoRequest{} }
-func (m *ListRepoRequest) String() string { return proto.CompactTextString(m) }
-func (*ListRepoRequest) ProtoMessage()    {}
+func (m *CancelOpRequest) Reset()         { *m = CancelOpRequest{} }
+func (m *CancelOpRequest) String() string { return proto.CompactTextString(m) }
+func (*CancelOpRequest) ProtoMessage()    {}
 
-type DeleteRepoRequest struct {
-	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+// ShardInfo represents the replication state of a shard.
+type ShardInfo struct {
+	Shard         uint64         `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	MasterAddress string         `protobuf:"bytes,2,opt,name=master_address" json:"master_address,omitempty"`
+	ReplicaInfo   []*ReplicaInfo `protobuf:"bytes,3,rep,name=replica_info" json:"replica_info,omitempty"`
 }
 
-func (m *DeleteRepoRequest) Reset()         { *m = DeleteRepoRequest{} }
-func (m *DeleteRepoRequest) String() string { return proto.CompactTextString(m) }
-func (*DeleteRepoRequest) ProtoMessage()    {}
+func (m *ShardInfo) Reset()         { *m = ShardInfo{} }
+func (m *ShardInfo) String() string { return proto.CompactTextString(m) }
+func (*ShardInfo) ProtoMessage()    {}
 
-func (m *DeleteRepoRequest) GetRepo() *Repo {
+func (m *ShardInfo) GetReplicaInfo() []*ReplicaInfo {
 	if m != nil {
-		return m.Repo
+		return m.ReplicaInfo
 	}
 	return nil
 }
 
-type StartCommitRequest struct {
-	Parent  *Commit                     `protobuf:"bytes,1,opt,name=parent" json:"parent,omitempty"`
-	Commit  *Commit                     `protobuf:"bytes,2,opt,name=commit" json:"commit,omitempty"`
-	Started *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=started" json:"started,omitempty"`
+type ShardInfos struct {
+	ShardInfo []*ShardInfo `protobuf:"bytes,1,rep,name=shard_info" json:"shard_info,omitempty"`
 }
 
-func (m *StartCommitRequest) Reset()         { *m = StartCommitRequest{} }
-func (m *StartCommitRequest) String() string { return proto.CompactTextString(m) }
-func (*StartCommitRequest) ProtoMessage()    {}
+func (m *ShardInfos) Reset()         { *m = ShardInfos{} }
+func (m *ShardInfos) String() string { return proto.CompactTextString(m) }
+func (*ShardInfos) ProtoMessage()    {}
 
-func (m *StartCommitRequest) GetParent() *Commit {
+func (m *ShardInfos) GetShardInfo() []*ShardInfo {
 	if m != nil {
-		return m.Parent
+		return m.ShardInfo
 	}
 	return nil
 }
 
-func (m *StartCommitRequest) GetCommit() *Commit {
+// ReplicaInfo represents how far a replica of a shard is behind its master.
+type ReplicaInfo struct {
+	Address    string        `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	ReplicaLag []*ReplicaLag `protobuf:"bytes,2,rep,name=replica_lag" json:"replica_lag,omitempty"`
+	// error is set if the replica couldn't be inspected.
+	Error string `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ReplicaInfo) Reset()         { *m = ReplicaInfo{} }
+func (m *ReplicaInfo) String() string { return proto.CompactTextString(m) }
+func (*ReplicaInfo) ProtoMessage()    {}
+
+func (m *ReplicaInfo) GetReplicaLag() []*ReplicaLag {
 	if m != nil {
-		return m.Commit
+		return m.ReplicaLag
 	}
 	return nil
 }
 
-func (m *StartCommitRequest) GetStarted() *google_protobuf2.Timestamp {
+type ReplicaLag struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	// last_commit is the replica's most recently finished commit.
+	LastCommit         *Commit                     `protobuf:"bytes,2,opt,name=last_commit" json:"last_commit,omitempty"`
+	LastCommitFinished *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=last_commit_finished" json:"last_commit_finished,omitempty"`
+	CommitsBehind      uint64                      `protobuf:"varint,4,opt,name=commits_behind" json:"commits_behind,omitempty"`
+	// lag is how much older the replica's last commit is than the master's.
+	Lag *google_protobuf4.Duration `protobuf:"bytes,5,opt,name=lag" json:"lag,omitempty"`
+}
+
+func (m *ReplicaLag) Reset()         { *m = ReplicaLag{} }
+func (m *ReplicaLag) String() string { return proto.CompactTextString(m) }
+func (*ReplicaLag) ProtoMessage()    {}
+
+func (m *ReplicaLag) GetRepo() *Repo {
 	if m != nil {
-		return m.Started
+		return m.Repo
 	}
 	return nil
 }
 
-type FinishCommitRequest struct {
-	Commit   *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
-	Finished *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=finished" json:"finished,omitempty"`
+func (m *ReplicaLag) GetLastCommit() *Commit {
+	if m != nil {
+		return m.LastCommit
+	}
+	return nil
 }
 
-func (m *FinishCommitRequest) Reset()         { *m = FinishCommitRequest{} }
-func (m *FinishCommitRequest) String() string { return proto.CompactTextString(m) }
-func (*FinishCommitRequest) ProtoMessage()    {}
-
-func (m *FinishCommitRequest) GetCommit() *Commit {
+func (m *ReplicaLag) GetLastCommitFinished() *google_protobuf2.Timestamp {
 	if m != nil {
-		return m.Commit
+		return m.LastCommitFinished
 	}
 	return nil
 }
 
-func (m *FinishCommitRequest) GetFinished() *google_protobuf2.Timestamp {
+func (m *ReplicaLag) GetLag() *google_protobuf4.Duration {
 	if m != nil {
-		return m.Finished
+		return m.Lag
 	}
 	return nil
 }
 
-type InspectCommitRequest struct {
+// Shard represents a dynamic shard within pfs.
+// number must always be less than modulo.
+type Shard struct {
+	FileNumber   uint64 `protobuf:"varint,1,opt,name=file_number" json:"file_number,omitempty"`
+	FileModulus  uint64 `protobuf:"varint,2,opt,name=file_modulus" json:"file_modulus,omitempty"`
+	BlockNumber  uint64 `protobuf:"varint,3,opt,name=block_number" json:"block_number,omitempty"`
+	BlockModulus uint64 `protobuf:"varint,4,opt,name=block_modulus" json:"block_modulus,omitempty"`
+	TopDir       bool   `protobuf:"varint,5,opt,name=top_dir" json:"top_dir,omitempty"`
+}
+
+func (m *Shard) Reset()         { *m = Shard{} }
+func (m *Shard) String() string { return proto.CompactTextString(m) }
+func (*Shard) ProtoMessage()    {}
+
+// ConsistencyToken is returned by FinishCommit, reads which present it see
+// the commit even if they go through a different frontend.
+type ConsistencyToken struct {
 	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	// version is the shard assignment version the commit was finished at.
+	Version int64 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
 }
 
-func (m *InspectCommitRequest) Reset()         { *m = InspectCommitRequest{} }
-func (m *InspectCommitRequest) String() string { return proto.CompactTextString(m) }
-func (*InspectCommitRequest) ProtoMessage()    {}
+func (m *ConsistencyToken) Reset()         { *m = ConsistencyToken{} }
+func (m *ConsistencyToken) String() string { return proto.CompactTextString(m) }
+func (*ConsistencyToken) ProtoMessage()    {}
 
-func (m *InspectCommitRequest) GetCommit() *Commit {
+func (m *ConsistencyToken) GetCommit() *Commit {
 	if m != nil {
 		return m.Commit
 	}
 	return nil
 }
 
-type ListCommitRequest struct {
-	Repo       []*Repo    `protobuf:"bytes,1,rep,name=repo" json:"repo,omitempty"`
-	CommitType CommitType `protobuf:"varint,2,opt,name=commit_type,enum=pfs.CommitType" json:"commit_type,omitempty"`
-	FromCommit []*Commit  `protobuf:"bytes,3,rep,name=from_commit" json:"from_commit,omitempty"`
-	Block      bool       `protobuf:"varint,4,opt,name=block" json:"block,omitempty"`
+type PinVersionRequest struct {
+	// the pin expires once ttl passes without an rpc presenting it, the
+	// default is 5 minutes and it can be at most 30 minutes
+	Ttl *google_protobuf4.Duration `protobuf:"bytes,1,opt,name=ttl" json:"ttl,omitempty"`
 }
 
-func (m *ListCommitRequest) Reset()         { *m = ListCommitRequest{} }
-func (m *ListCommitRequest) String() string { return proto.CompactTextString(m) }
-func (*ListCommitRequest) ProtoMessage()    {}
+func (m *PinVersionRequest) Reset()         { *m = PinVersionRequest{} }
+func (m *PinVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*PinVersionRequest) ProtoMessage()    {}
 
-func (m *ListCommitRequest) GetRepo() []*Repo {
+func (m *PinVersionRequest) GetTtl() *google_protobuf4.Duration {
+	if m != nil {
+		return m.Ttl
+	}
+	return nil
+}
+
+// VersionPin pins the shard assignment version a frontend routes with for an
+// operation made of several rpcs, such as a recursive put, so that a version
+// change part way through doesn't send its writes to two different sets of
+// masters. The token is presented in the version-pin rpc header.
+type VersionPin struct {
+	Token   string `protobuf:"bytes,1,opt,name=token" json:"token,omitempty"`
+	Version int64  `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *VersionPin) Reset()         { *m = VersionPin{} }
+func (m *VersionPin) String() string { return proto.CompactTextString(m) }
+func (*VersionPin) ProtoMessage()    {}
+
+type CreateRepoRequest struct {
+	Repo    *Repo                       `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	Created *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
+	// ttl makes this a scratch repo, it's deleted once ttl has passed since
+	// its last commit finished.
+	Ttl *google_protobuf4.Duration `protobuf:"bytes,3,opt,name=ttl" json:"ttl,omitempty"`
+}
+
+func (m *CreateRepoRequest) Reset()         { *m = CreateRepoRequest{} }
+func (m *CreateRepoRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateRepoRequest) ProtoMessage()    {}
+
+func (m *CreateRepoRequest) GetRepo() *Repo {
 	if m != nil {
 		return m.Repo
 	}
 	return nil
 }
 
-func (m *ListCommitRequest) GetFromCommit() []*Commit {
+func (m *CreateRepoRequest) GetCreated() *google_protobuf2.Timestamp {
 	if m != nil {
-		return m.FromCommit
+		return m.Created
 	}
 	return nil
 }
 
-type DeleteCommitRequest struct {
-	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+func (m *CreateRepoRequest) GetTtl() *google_protobuf4.Duration {
+	if m != nil {
+		return m.Ttl
+	}
+	return nil
 }
 
-func (m *DeleteCommitRequest) Reset()         { *m = DeleteCommitRequest{} }
-func (m *DeleteCommitRequest) String() string { return proto.CompactTextString(m) }
-func (*DeleteCommitRequest) ProtoMessage()    {}
+type InspectRepoRequest struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+}
 
-func (m *DeleteCommitRequest) GetCommit() *Commit {
+func (m *InspectRepoRequest) Reset()         { *m = InspectRepoRequest{} }
+func (m *InspectRepoRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectRepoRequest) ProtoMessage()    {}
+
+func (m *InspectRepoRequest) GetRepo() *Repo {
 	if m != nil {
-		return m.Commit
+		return m.Repo
 	}
 	return nil
 }
 
-type GetFileRequest struct {
-	File        *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	OffsetBytes int64  `protobuf:"varint,2,opt,name=offset_bytes" json:"offset_bytes,omitempty"`
-	SizeBytes   int64  `protobuf:"varint,3,opt,name=size_bytes" json:"size_bytes,omitempty"`
-	Shard       *Shard `protobuf:"bytes,4,opt,name=shard" json:"shard,omitempty"`
+type ListRepoRequest struct {
 }
 
-func (m *GetFileRequest) Reset()         { *m = GetFileRequest{} }
-func (m *GetFileRequest) String() string { return proto.CompactTextString(m) }
-func (*GetFileRequest) ProtoMessage()    {}
+func (m *ListRepoRequest) Reset()         { *m = ListRepoRequest{} }
+func (m *ListRepoRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRepoRequest) ProtoMessage()    {}
 
-func (m *GetFileRequest) GetFile() *File {
+type DedupStatsRequest struct {
+	// repo is the repos to report on, all of them if it's empty.
+	Repo []*Repo `protobuf:"bytes,1,rep,name=repo" json:"repo,omitempty"`
+}
+
+func (m *DedupStatsRequest) Reset()         { *m = DedupStatsRequest{} }
+func (m *DedupStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*DedupStatsRequest) ProtoMessage()    {}
+
+func (m *DedupStatsRequest) GetRepo() []*Repo {
 	if m != nil {
-		return m.File
+		return m.Repo
 	}
 	return nil
 }
 
-func (m *GetFileRequest) GetShard() *Shard {
+type DeleteRepoRequest struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	// force deletes the repo even if pipelines read from or write to it.
+	Force bool `protobuf:"varint,2,opt,name=force" json:"force,omitempty"`
+	// purge deletes the repo immediately rather than moving it to the trash,
+	// it can't be restored.
+	Purge   bool                        `protobuf:"varint,3,opt,name=purge" json:"purge,omitempty"`
+	Deleted *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=deleted" json:"deleted,omitempty"`
+}
+
+func (m *DeleteRepoRequest) Reset()         { *m = DeleteRepoRequest{} }
+func (m *DeleteRepoRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRepoRequest) ProtoMessage()    {}
+
+func (m *DeleteRepoRequest) GetRepo() *Repo {
 	if m != nil {
-		return m.Shard
+		return m.Repo
 	}
 	return nil
 }
 
-type PutFileRequest struct {
-	File        *File    `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	FileType    FileType `protobuf:"varint,2,opt,name=file_type,enum=pfs.FileType" json:"file_type,omitempty"`
-	OffsetBytes int64    `protobuf:"varint,3,opt,name=offset_bytes" json:"offset_bytes,omitempty"`
-	Value       []byte   `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+func (m *DeleteRepoRequest) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
 }
 
-func (m *PutFileRequest) Reset()         { *m = PutFileRequest{} }
-func (m *PutFileRequest) String() string { return proto.CompactTextString(m) }
-func (*PutFileRequest) ProtoMessage()    {}
+type RestoreRepoRequest struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+}
 
-func (m *PutFileRequest) GetFile() *File {
+func (m *RestoreRepoRequest) Reset()         { *m = RestoreRepoRequest{} }
+func (m *RestoreRepoRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreRepoRequest) ProtoMessage()    {}
+
+func (m *RestoreRepoRequest) GetRepo() *Repo {
 	if m != nil {
-		return m.File
+		return m.Repo
 	}
 	return nil
 }
 
-type InspectFileRequest struct {
-	File  *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Shard *Shard `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
+type StartCommitRequest struct {
+	Parent  *Commit                     `protobuf:"bytes,1,opt,name=parent" json:"parent,omitempty"`
+	Commit  *Commit                     `protobuf:"bytes,2,opt,name=commit" json:"commit,omitempty"`
+	Started *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=started" json:"started,omitempty"`
+	// transaction makes the commit part of a transaction, it's finished by
+	// FinishTransaction rather than FinishCommit
+	Transaction *Transaction `protobuf:"bytes,4,opt,name=transaction" json:"transaction,omitempty"`
+	// expected_parent makes StartCommit fail with a conflict if it already
+	// has a finished child
+	ExpectedParent *Commit `protobuf:"bytes,5,opt,name=expected_parent" json:"expected_parent,omitempty"`
 }
 
-func (m *InspectFileRequest) Reset()         { *m = InspectFileRequest{} }
-func (m *InspectFileRequest) String() string { return proto.CompactTextString(m) }
-func (*InspectFileRequest) ProtoMessage()    {}
+func (m *StartCommitRequest) Reset()         { *m = StartCommitRequest{} }
+func (m *StartCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*StartCommitRequest) ProtoMessage()    {}
 
-func (m *InspectFileRequest) GetFile() *File {
+func (m *StartCommitRequest) GetParent() *Commit {
 	if m != nil {
-		return m.File
+		return m.Parent
 	}
 	return nil
 }
 
-func (m *InspectFileRequest) GetShard() *Shard {
+func (m *StartCommitRequest) GetCommit() *Commit {
 	if m != nil {
-		return m.Shard
+		return m.Commit
 	}
 	return nil
 }
 
-type MakeDirectoryRequest struct {
-	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+func (m *StartCommitRequest) GetStarted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Started
+	}
+	return nil
 }
 
-func (m *MakeDirectoryRequest) Reset()         { *m = MakeDirectoryRequest{} }
-func (m *MakeDirectoryRequest) String() string { return proto.CompactTextString(m) }
-func (*MakeDirectoryRequest) ProtoMessage()    {}
+func (m *StartCommitRequest) GetTransaction() *Transaction {
+	if m != nil {
+		return m.Transaction
+	}
+	return nil
+}
 
-func (m *MakeDirectoryRequest) GetFile() *File {
+func (m *StartCommitRequest) GetExpectedParent() *Commit {
 	if m != nil {
-		return m.File
+		return m.ExpectedParent
 	}
 	return nil
 }
 
-type ListFileRequest struct {
-	File  *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Shard *Shard `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
+type FinishCommitRequest struct {
+	Commit   *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Finished *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=finished" json:"finished,omitempty"`
+	// force finishes the commit without waiting for in-flight reads and
+	// writes, the writes will fail.
+	Force bool `protobuf:"varint,4,opt,name=force" json:"force,omitempty"`
+	// expected_parent makes FinishCommit fail with a conflict, leaving the
+	// commit started, if it isn't the commit's parent or another of its
+	// children has finished first.
+	ExpectedParent *Commit `protobuf:"bytes,5,opt,name=expected_parent" json:"expected_parent,omitempty"`
 }
 
-func (m *ListFileRequest) Reset()         { *m = ListFileRequest{} }
-func (m *ListFileRequest) String() string { return proto.CompactTextString(m) }
-func (*ListFileRequest) ProtoMessage()    {}
+func (m *FinishCommitRequest) Reset()         { *m = FinishCommitRequest{} }
+func (m *FinishCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*FinishCommitRequest) ProtoMessage()    {}
 
-func (m *ListFileRequest) GetFile() *File {
+func (m *FinishCommitRequest) GetCommit() *Commit {
 	if m != nil {
-		return m.File
+		return m.Commit
 	}
 	return nil
 }
 
-func (m *ListFileRequest) GetShard() *Shard {
+func (m *FinishCommitRequest) GetFinished() *google_protobuf2.Timestamp {
 	if m != nil {
-		return m.Shard
+		return m.Finished
 	}
 	return nil
 }
 
-type DeleteFileRequest struct {
-	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+func (m *FinishCommitRequest) GetExpectedParent() *Commit {
+	if m != nil {
+		return m.ExpectedParent
+	}
+	return nil
 }
 
-func (m *DeleteFileRequest) Reset()         { *m = DeleteFileRequest{} }
-func (m *DeleteFileRequest) String() string { return proto.CompactTextString(m) }
-func (*DeleteFileRequest) ProtoMessage()    {}
+// Transaction groups commits to several repos which are finished together,
+// readers see all of them finished or none of them.
+type Transaction struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
 
-func (m *DeleteFileRequest) GetFile() *File {
+func (m *Transaction) Reset()         { *m = Transaction{} }
+func (m *Transaction) String() string { return proto.CompactTextString(m) }
+func (*Transaction) ProtoMessage()    {}
+
+type FinishTransactionRequest struct {
+	Transaction *Transaction                `protobuf:"bytes,1,opt,name=transaction" json:"transaction,omitempty"`
+	Finished    *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=finished" json:"finished,omitempty"`
+}
+
+func (m *FinishTransactionRequest) Reset()         { *m = FinishTransactionRequest{} }
+func (m *FinishTransactionRequest) String() string { return proto.CompactTextString(m) }
+func (*FinishTransactionRequest) ProtoMessage()    {}
+
+func (m *FinishTransactionRequest) GetTransaction() *Transaction {
 	if m != nil {
-		return m.File
+		return m.Transaction
 	}
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*Repo)(nil), "pfs.Repo")
-	proto.RegisterType((*Commit)(nil), "pfs.Commit")
-	proto.RegisterType((*File)(nil), "pfs.File")
-	proto.RegisterType((*Server)(nil), "pfs.Server")
-	proto.RegisterType((*RepoInfo)(nil), "pfs.RepoInfo")
-	proto.RegisterType((*RepoInfos)(nil), "pfs.RepoInfos")
-	proto.RegisterType((*CommitInfo)(nil), "pfs.CommitInfo")
-	proto.RegisterType((*CommitInfos)(nil), "pfs.CommitInfos")
-	proto.RegisterType((*FileInfo)(nil), "pfs.FileInfo")
-	proto.RegisterType((*FileInfos)(nil), "pfs.FileInfos")
-	proto.RegisterType((*ServerInfo)(nil), "pfs.ServerInfo")
-	proto.RegisterType((*ServerInfos)(nil), "pfs.ServerInfos")
-	proto.RegisterType((*Shard)(nil), "pfs.Shard")
-	proto.RegisterType((*CreateRepoRequest)(nil), "pfs.CreateRepoRequest")
-	proto.RegisterType((*InspectRepoRequest)(nil), "pfs.InspectRepoRequest")
-	proto.RegisterType((*ListRepoRequest)(nil), "pfs.ListRepoRequest")
-	proto.RegisterType((*DeleteRepoRequest)(nil), "pfs.DeleteRepoRequest")
-	proto.RegisterType((*StartCommitRequest)(nil), "pfs.StartCommitRequest")
-	proto.RegisterType((*FinishCommitRequest)(nil), "pfs.FinishCommitRequest")
-	proto.RegisterType((*InspectCommitRequest)(nil), "pfs.InspectCommitRequest")
-	proto.RegisterType((*ListCommitRequest)(nil), "pfs.ListCommitRequest")
-	proto.RegisterType((*DeleteCommitRequest)(nil), "pfs.DeleteCommitRequest")
-	proto.RegisterType((*GetFileRequest)(nil), "pfs.GetFileRequest")
-	proto.RegisterType((*PutFileRequest)(nil), "pfs.PutFileRequest")
-	proto.RegisterType((*InspectFileRequest)(nil), "pfs.InspectFileRequest")
-	proto.RegisterType((*MakeDirectoryRequest)(nil), "pfs.MakeDirectoryRequest")
-	proto.RegisterType((*ListFileRequest)(nil), "pfs.ListFileRequest")
-	proto.RegisterType((*DeleteFileRequest)(nil), "pfs.DeleteFileRequest")
-	proto.RegisterEnum("pfs.CommitType", CommitType_name, CommitType_value)
-	proto.RegisterEnum("pfs.FileType", FileType_name, FileType_value)
+func (m *FinishTransactionRequest) GetFinished() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Finished
+	}
+	return nil
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+type MergeCommitsRequest struct {
+	// ours is the parent of the merge commit, the files changed in theirs since
+	// the two diverged are merged into it.
+	Ours     *Commit       `protobuf:"bytes,1,opt,name=ours" json:"ours,omitempty"`
+	Theirs   *Commit       `protobuf:"bytes,2,opt,name=theirs" json:"theirs,omitempty"`
+	Strategy MergeStrategy `protobuf:"varint,3,opt,name=strategy,enum=pfs.MergeStrategy" json:"strategy,omitempty"`
+	// commit and finished are set by the server, an internal request without
+	// a commit only checks that the merge can be made.
+	Commit   *Commit                     `protobuf:"bytes,4,opt,name=commit" json:"commit,omitempty"`
+	Finished *google_protobuf2.Timestamp `protobuf:"bytes,5,opt,name=finished" json:"finished,omitempty"`
+}
 
-// Client API for API service
+func (m *MergeCommitsRequest) Reset()         { *m = MergeCommitsRequest{} }
+func (m *MergeCommitsRequest) String() string { return proto.CompactTextString(m) }
+func (*MergeCommitsRequest) ProtoMessage()    {}
 
-type APIClient interface {
-	// Repo rpcs
-	// CreateRepo creates a new repo.
-	// An error is returned if the repo already exists.
-	CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// InspectRepo returns info about a repo.
-	InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error)
-	// ListRepo returns info about all repos.
+func (m *MergeCommitsRequest) GetOurs() *Commit {
+	if m != nil {
+		return m.Ours
+	}
+	return nil
+}
+
+func (m *MergeCommitsRequest) GetTheirs() *Commit {
+	if m != nil {
+		return m.Theirs
+	}
+	return nil
+}
+
+func (m *MergeCommitsRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *MergeCommitsRequest) GetFinished() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Finished
+	}
+	return nil
+}
+
+type InspectCommitRequest struct {
+	Commit           *Commit           `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,2,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *InspectCommitRequest) Reset()         { *m = InspectCommitRequest{} }
+func (m *InspectCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectCommitRequest) ProtoMessage()    {}
+
+func (m *InspectCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *InspectCommitRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type ListCommitRequest struct {
+	Repo       []*Repo    `protobuf:"bytes,1,rep,name=repo" json:"repo,omitempty"`
+	CommitType CommitType `protobuf:"varint,2,opt,name=commit_type,enum=pfs.CommitType" json:"commit_type,omitempty"`
+	FromCommit []*Commit  `protobuf:"bytes,3,rep,name=from_commit" json:"from_commit,omitempty"`
+	Block      bool       `protobuf:"varint,4,opt,name=block" json:"block,omitempty"`
+}
+
+func (m *ListCommitRequest) Reset()         { *m = ListCommitRequest{} }
+func (m *ListCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*ListCommitRequest) ProtoMessage()    {}
+
+func (m *ListCommitRequest) GetRepo() []*Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+func (m *ListCommitRequest) GetFromCommit() []*Commit {
+	if m != nil {
+		return m.FromCommit
+	}
+	return nil
+}
+
+type DeleteCommitRequest struct {
+	Commit  *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Deleted *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=deleted" json:"deleted,omitempty"`
+}
+
+func (m *DeleteCommitRequest) Reset()         { *m = DeleteCommitRequest{} }
+func (m *DeleteCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteCommitRequest) ProtoMessage()    {}
+
+func (m *DeleteCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *DeleteCommitRequest) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
+type RestoreCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+}
+
+func (m *RestoreCommitRequest) Reset()         { *m = RestoreCommitRequest{} }
+func (m *RestoreCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*RestoreCommitRequest) ProtoMessage()    {}
+
+func (m *RestoreCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+type TagCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Tag    string  `protobuf:"bytes,2,opt,name=tag" json:"tag,omitempty"`
+}
+
+func (m *TagCommitRequest) Reset()         { *m = TagCommitRequest{} }
+func (m *TagCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*TagCommitRequest) ProtoMessage()    {}
+
+func (m *TagCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+type ListTagRequest struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+}
+
+func (m *ListTagRequest) Reset()         { *m = ListTagRequest{} }
+func (m *ListTagRequest) String() string { return proto.CompactTextString(m) }
+func (*ListTagRequest) ProtoMessage()    {}
+
+func (m *ListTagRequest) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+type ListTrashRequest struct {
+}
+
+func (m *ListTrashRequest) Reset()         { *m = ListTrashRequest{} }
+func (m *ListTrashRequest) String() string { return proto.CompactTextString(m) }
+func (*ListTrashRequest) ProtoMessage()    {}
+
+type EmptyTrashRequest struct {
+	// all empties the whole trash rather than just what's been in it longer
+	// than the trash window.
+	All bool `protobuf:"varint,1,opt,name=all" json:"all,omitempty"`
+	// before is set by the frontend, trash deleted before it is emptied.
+	Before *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=before" json:"before,omitempty"`
+}
+
+func (m *EmptyTrashRequest) Reset()         { *m = EmptyTrashRequest{} }
+func (m *EmptyTrashRequest) String() string { return proto.CompactTextString(m) }
+func (*EmptyTrashRequest) ProtoMessage()    {}
+
+func (m *EmptyTrashRequest) GetBefore() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Before
+	}
+	return nil
+}
+
+type GetFileRequest struct {
+	File             *File             `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	OffsetBytes      int64             `protobuf:"varint,2,opt,name=offset_bytes" json:"offset_bytes,omitempty"`
+	SizeBytes        int64             `protobuf:"varint,3,opt,name=size_bytes" json:"size_bytes,omitempty"`
+	Shard            *Shard            `protobuf:"bytes,4,opt,name=shard" json:"shard,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,5,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *GetFileRequest) Reset()         { *m = GetFileRequest{} }
+func (m *GetFileRequest) String() string { return proto.CompactTextString(m) }
+func (*GetFileRequest) ProtoMessage()    {}
+
+func (m *GetFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *GetFileRequest) GetShard() *Shard {
+	if m != nil {
+		return m.Shard
+	}
+	return nil
+}
+
+func (m *GetFileRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type GetTarRequest struct {
+	// file is the directory to tar, the whole commit if its path is empty.
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// gzip compresses the tar.
+	Gzip             bool              `protobuf:"varint,2,opt,name=gzip" json:"gzip,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,3,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *GetTarRequest) Reset()         { *m = GetTarRequest{} }
+func (m *GetTarRequest) String() string { return proto.CompactTextString(m) }
+func (*GetTarRequest) ProtoMessage()    {}
+
+func (m *GetTarRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *GetTarRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type SampleFileRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// records is the most records to return.
+	Records uint64 `protobuf:"varint,2,opt,name=records" json:"records,omitempty"`
+	// random samples records from the whole file rather than returning the
+	// first ones.
+	Random           bool              `protobuf:"varint,3,opt,name=random" json:"random,omitempty"`
+	Format           RecordFormat      `protobuf:"varint,4,opt,name=format,enum=pfs.RecordFormat" json:"format,omitempty"`
+	Shard            *Shard            `protobuf:"bytes,5,opt,name=shard" json:"shard,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,6,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *SampleFileRequest) Reset()         { *m = SampleFileRequest{} }
+func (m *SampleFileRequest) String() string { return proto.CompactTextString(m) }
+func (*SampleFileRequest) ProtoMessage()    {}
+
+func (m *SampleFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *SampleFileRequest) GetShard() *Shard {
+	if m != nil {
+		return m.Shard
+	}
+	return nil
+}
+
+func (m *SampleFileRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type StatsFileRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// records is how many records to sample, 1000 if it's 0.
+	Records          uint64            `protobuf:"varint,2,opt,name=records" json:"records,omitempty"`
+	Format           RecordFormat      `protobuf:"varint,3,opt,name=format,enum=pfs.RecordFormat" json:"format,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,4,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *StatsFileRequest) Reset()         { *m = StatsFileRequest{} }
+func (m *StatsFileRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsFileRequest) ProtoMessage()    {}
+
+func (m *StatsFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *StatsFileRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type StatsRepoRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	// records is how many records to sample from each file, 1000 if it's 0.
+	Records          uint64            `protobuf:"varint,2,opt,name=records" json:"records,omitempty"`
+	Format           RecordFormat      `protobuf:"varint,3,opt,name=format,enum=pfs.RecordFormat" json:"format,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,4,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *StatsRepoRequest) Reset()         { *m = StatsRepoRequest{} }
+func (m *StatsRepoRequest) String() string { return proto.CompactTextString(m) }
+func (*StatsRepoRequest) ProtoMessage()    {}
+
+func (m *StatsRepoRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *StatsRepoRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type PutFileRequest struct {
+	File        *File    `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	FileType    FileType `protobuf:"varint,2,opt,name=file_type,enum=pfs.FileType" json:"file_type,omitempty"`
+	OffsetBytes int64    `protobuf:"varint,3,opt,name=offset_bytes" json:"offset_bytes,omitempty"`
+	Value       []byte   `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *PutFileRequest) Reset()         { *m = PutFileRequest{} }
+func (m *PutFileRequest) String() string { return proto.CompactTextString(m) }
+func (*PutFileRequest) ProtoMessage()    {}
+
+func (m *PutFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type InspectFileRequest struct {
+	File             *File             `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Shard            *Shard            `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,3,opt,name=consistency_token" json:"consistency_token,omitempty"`
+}
+
+func (m *InspectFileRequest) Reset()         { *m = InspectFileRequest{} }
+func (m *InspectFileRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectFileRequest) ProtoMessage()    {}
+
+func (m *InspectFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *InspectFileRequest) GetShard() *Shard {
+	if m != nil {
+		return m.Shard
+	}
+	return nil
+}
+
+func (m *InspectFileRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type MakeDirectoryRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *MakeDirectoryRequest) Reset()         { *m = MakeDirectoryRequest{} }
+func (m *MakeDirectoryRequest) String() string { return proto.CompactTextString(m) }
+func (*MakeDirectoryRequest) ProtoMessage()    {}
+
+func (m *MakeDirectoryRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type ListFileRequest struct {
+	File             *File             `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Shard            *Shard            `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
+	ConsistencyToken *ConsistencyToken `protobuf:"bytes,3,opt,name=consistency_token" json:"consistency_token,omitempty"`
+	Partial          bool              `protobuf:"varint,4,opt,name=partial" json:"partial,omitempty"`
+}
+
+func (m *ListFileRequest) Reset()         { *m = ListFileRequest{} }
+func (m *ListFileRequest) String() string { return proto.CompactTextString(m) }
+func (*ListFileRequest) ProtoMessage()    {}
+
+func (m *ListFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *ListFileRequest) GetShard() *Shard {
+	if m != nil {
+		return m.Shard
+	}
+	return nil
+}
+
+func (m *ListFileRequest) GetConsistencyToken() *ConsistencyToken {
+	if m != nil {
+		return m.ConsistencyToken
+	}
+	return nil
+}
+
+type DeleteFileRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *DeleteFileRequest) Reset()         { *m = DeleteFileRequest{} }
+func (m *DeleteFileRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteFileRequest) ProtoMessage()    {}
+
+func (m *DeleteFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type CopyFileRequest struct {
+	Src       *File `protobuf:"bytes,1,opt,name=src" json:"src,omitempty"`
+	Dst       *File `protobuf:"bytes,2,opt,name=dst" json:"dst,omitempty"`
+	Recursive bool  `protobuf:"varint,3,opt,name=recursive" json:"recursive,omitempty"`
+}
+
+func (m *CopyFileRequest) Reset()         { *m = CopyFileRequest{} }
+func (m *CopyFileRequest) String() string { return proto.CompactTextString(m) }
+func (*CopyFileRequest) ProtoMessage()    {}
+
+func (m *CopyFileRequest) GetSrc() *File {
+	if m != nil {
+		return m.Src
+	}
+	return nil
+}
+
+func (m *CopyFileRequest) GetDst() *File {
+	if m != nil {
+		return m.Dst
+	}
+	return nil
+}
+
+type StartMultipartPutRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *StartMultipartPutRequest) Reset()         { *m = StartMultipartPutRequest{} }
+func (m *StartMultipartPutRequest) String() string { return proto.CompactTextString(m) }
+func (*StartMultipartPutRequest) ProtoMessage()    {}
+
+func (m *StartMultipartPutRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+// MultipartPut is a file being put in parts which can be uploaded
+// concurrently, it's created once all of them have been.
+type MultipartPut struct {
+	Id   string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	File *File  `protobuf:"bytes,2,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *MultipartPut) Reset()         { *m = MultipartPut{} }
+func (m *MultipartPut) String() string { return proto.CompactTextString(m) }
+func (*MultipartPut) ProtoMessage()    {}
+
+func (m *MultipartPut) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type PutPartRequest struct {
+	// multipart_put and number only need to be set in the first request of
+	// the stream. Putting a part again replaces it.
+	MultipartPut *MultipartPut `protobuf:"bytes,1,opt,name=multipart_put" json:"multipart_put,omitempty"`
+	Number       uint64        `protobuf:"varint,2,opt,name=number" json:"number,omitempty"`
+	Value        []byte        `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *PutPartRequest) Reset()         { *m = PutPartRequest{} }
+func (m *PutPartRequest) String() string { return proto.CompactTextString(m) }
+func (*PutPartRequest) ProtoMessage()    {}
+
+func (m *PutPartRequest) GetMultipartPut() *MultipartPut {
+	if m != nil {
+		return m.MultipartPut
+	}
+	return nil
+}
+
+type ConcatFilesRequest struct {
+	Src []*File `protobuf:"bytes,1,rep,name=src" json:"src,omitempty"`
+	Dst *File   `protobuf:"bytes,2,opt,name=dst" json:"dst,omitempty"`
+}
+
+func (m *ConcatFilesRequest) Reset()         { *m = ConcatFilesRequest{} }
+func (m *ConcatFilesRequest) String() string { return proto.CompactTextString(m) }
+func (*ConcatFilesRequest) ProtoMessage()    {}
+
+func (m *ConcatFilesRequest) GetSrc() []*File {
+	if m != nil {
+		return m.Src
+	}
+	return nil
+}
+
+func (m *ConcatFilesRequest) GetDst() *File {
+	if m != nil {
+		return m.Dst
+	}
+	return nil
+}
+
+type InspectShardRequest struct {
+	// shard is the shards to inspect, all shards are inspected if it's empty.
+	Shard []uint64 `protobuf:"varint,1,rep,name=shard" json:"shard,omitempty"`
+}
+
+func (m *InspectShardRequest) Reset()         { *m = InspectShardRequest{} }
+func (m *InspectShardRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectShardRequest) ProtoMessage()    {}
+
+type InspectLocalShardRequest struct {
+	Shard uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+}
+
+func (m *InspectLocalShardRequest) Reset()         { *m = InspectLocalShardRequest{} }
+func (m *InspectLocalShardRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectLocalShardRequest) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*Repo)(nil), "pfs.Repo")
+	proto.RegisterType((*Commit)(nil), "pfs.Commit")
+	proto.RegisterType((*File)(nil), "pfs.File")
+	proto.RegisterType((*Server)(nil), "pfs.Server")
+	proto.RegisterType((*RepoInfo)(nil), "pfs.RepoInfo")
+	proto.RegisterType((*RepoInfos)(nil), "pfs.RepoInfos")
+	proto.RegisterType((*RepoDedupStats)(nil), "pfs.RepoDedupStats")
+	proto.RegisterType((*ShardDeletion)(nil), "pfs.ShardDeletion")
+	proto.RegisterType((*ShardDeletions)(nil), "pfs.ShardDeletions")
+	proto.RegisterType((*TrashInfo)(nil), "pfs.TrashInfo")
+	proto.RegisterType((*TrashInfos)(nil), "pfs.TrashInfos")
+	proto.RegisterType((*CommitInfo)(nil), "pfs.CommitInfo")
+	proto.RegisterType((*CommitInfos)(nil), "pfs.CommitInfos")
+	proto.RegisterType((*TagInfo)(nil), "pfs.TagInfo")
+	proto.RegisterType((*TagInfos)(nil), "pfs.TagInfos")
+	proto.RegisterType((*FileInfo)(nil), "pfs.FileInfo")
+	proto.RegisterType((*FileInfos)(nil), "pfs.FileInfos")
+	proto.RegisterType((*FileBlock)(nil), "pfs.FileBlock")
+	proto.RegisterType((*FileBlocks)(nil), "pfs.FileBlocks")
+	proto.RegisterType((*FileSample)(nil), "pfs.FileSample")
+	proto.RegisterType((*ValueCount)(nil), "pfs.ValueCount")
+	proto.RegisterType((*FieldStats)(nil), "pfs.FieldStats")
+	proto.RegisterType((*FileStats)(nil), "pfs.FileStats")
+	proto.RegisterType((*RepoStats)(nil), "pfs.RepoStats")
+	proto.RegisterType((*ServerStats)(nil), "pfs.ServerStats")
+	proto.RegisterType((*ServerInfo)(nil), "pfs.ServerInfo")
+	proto.RegisterType((*ServerInfos)(nil), "pfs.ServerInfos")
+	proto.RegisterType((*SlowOp)(nil), "pfs.SlowOp")
+	proto.RegisterType((*SlowOps)(nil), "pfs.SlowOps")
+	proto.RegisterType((*Op)(nil), "pfs.Op")
+	proto.RegisterType((*Ops)(nil), "pfs.Ops")
+	proto.RegisterType((*CancelOpRequest)(nil), "pfs.CancelOpRequest")
+	proto.RegisterType((*ShardInfo)(nil), "pfs.ShardInfo")
+	proto.RegisterType((*ShardInfos)(nil), "pfs.ShardInfos")
+	proto.RegisterType((*ReplicaInfo)(nil), "pfs.ReplicaInfo")
+	proto.RegisterType((*ReplicaLag)(nil), "pfs.ReplicaLag")
+	proto.RegisterType((*Shard)(nil), "pfs.Shard")
+	proto.RegisterType((*ConsistencyToken)(nil), "pfs.ConsistencyToken")
+	proto.RegisterType((*PinVersionRequest)(nil), "pfs.PinVersionRequest")
+	proto.RegisterType((*VersionPin)(nil), "pfs.VersionPin")
+	proto.RegisterType((*CreateRepoRequest)(nil), "pfs.CreateRepoRequest")
+	proto.RegisterType((*InspectRepoRequest)(nil), "pfs.InspectRepoRequest")
+	proto.RegisterType((*ListRepoRequest)(nil), "pfs.ListRepoRequest")
+	proto.RegisterType((*DedupStatsRequest)(nil), "pfs.DedupStatsRequest")
+	proto.RegisterType((*DeleteRepoRequest)(nil), "pfs.DeleteRepoRequest")
+	proto.RegisterType((*RestoreRepoRequest)(nil), "pfs.RestoreRepoRequest")
+	proto.RegisterType((*StartCommitRequest)(nil), "pfs.StartCommitRequest")
+	proto.RegisterType((*FinishCommitRequest)(nil), "pfs.FinishCommitRequest")
+	proto.RegisterType((*Transaction)(nil), "pfs.Transaction")
+	proto.RegisterType((*FinishTransactionRequest)(nil), "pfs.FinishTransactionRequest")
+	proto.RegisterType((*MergeCommitsRequest)(nil), "pfs.MergeCommitsRequest")
+	proto.RegisterType((*InspectCommitRequest)(nil), "pfs.InspectCommitRequest")
+	proto.RegisterType((*ListCommitRequest)(nil), "pfs.ListCommitRequest")
+	proto.RegisterType((*DeleteCommitRequest)(nil), "pfs.DeleteCommitRequest")
+	proto.RegisterType((*RestoreCommitRequest)(nil), "pfs.RestoreCommitRequest")
+	proto.RegisterType((*TagCommitRequest)(nil), "pfs.TagCommitRequest")
+	proto.RegisterType((*ListTagRequest)(nil), "pfs.ListTagRequest")
+	proto.RegisterType((*ListTrashRequest)(nil), "pfs.ListTrashRequest")
+	proto.RegisterType((*EmptyTrashRequest)(nil), "pfs.EmptyTrashRequest")
+	proto.RegisterType((*GetFileRequest)(nil), "pfs.GetFileRequest")
+	proto.RegisterType((*GetTarRequest)(nil), "pfs.GetTarRequest")
+	proto.RegisterType((*SampleFileRequest)(nil), "pfs.SampleFileRequest")
+	proto.RegisterType((*StatsFileRequest)(nil), "pfs.StatsFileRequest")
+	proto.RegisterType((*StatsRepoRequest)(nil), "pfs.StatsRepoRequest")
+	proto.RegisterType((*PutFileRequest)(nil), "pfs.PutFileRequest")
+	proto.RegisterType((*InspectFileRequest)(nil), "pfs.InspectFileRequest")
+	proto.RegisterType((*MakeDirectoryRequest)(nil), "pfs.MakeDirectoryRequest")
+	proto.RegisterType((*ListFileRequest)(nil), "pfs.ListFileRequest")
+	proto.RegisterType((*DeleteFileRequest)(nil), "pfs.DeleteFileRequest")
+	proto.RegisterType((*CopyFileRequest)(nil), "pfs.CopyFileRequest")
+	proto.RegisterType((*StartMultipartPutRequest)(nil), "pfs.StartMultipartPutRequest")
+	proto.RegisterType((*MultipartPut)(nil), "pfs.MultipartPut")
+	proto.RegisterType((*PutPartRequest)(nil), "pfs.PutPartRequest")
+	proto.RegisterType((*ConcatFilesRequest)(nil), "pfs.ConcatFilesRequest")
+	proto.RegisterType((*InspectShardRequest)(nil), "pfs.InspectShardRequest")
+	proto.RegisterType((*InspectLocalShardRequest)(nil), "pfs.InspectLocalShardRequest")
+	proto.RegisterEnum("pfs.CommitType", CommitType_name, CommitType_value)
+	proto.RegisterEnum("pfs.FileType", FileType_name, FileType_value)
+	proto.RegisterEnum("pfs.RecordFormat", RecordFormat_name, RecordFormat_value)
+	proto.RegisterEnum("pfs.FieldType", FieldType_name, FieldType_value)
+	proto.RegisterEnum("pfs.MergeStrategy", MergeStrategy_name, MergeStrategy_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for API service
+
+type APIClient interface {
+	// Repo rpcs
+	// CreateRepo creates a new repo.
+	// An error is returned if the repo already exists.
+	CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectRepo returns info about a repo.
+	InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error)
+	// ListRepo returns info about all repos.
+	ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error)
+	// DeleteRepo moves a repo to the trash, or deletes it and all of its
+	// commits from every shard if purge is set.
+	// Repos which pipelines read from or write to are only deleted if force is set.
+	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*ShardDeletions, error)
+	// RestoreRepo restores a repo from the trash.
+	RestoreRepo(ctx context.Context, in *RestoreRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// Commit rpcs
+	// StartCommit creates a new write commit from a parent commit.
+	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error)
+	// FinishCommit turns a write commit into a read commit.
+	// The returned token can be presented to reads so they see the commit.
+	FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*ConsistencyToken, error)
+	// InspectCommit returns the info about a commit.
+	InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error)
+	// ListCommit returns info about all commits.
+	ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// TagCommit gives a finished commit a name which can be used anywhere its
+	// id can, tags can't be moved to another commit.
+	TagCommit(ctx context.Context, in *TagCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ListTag returns the tags in a repo.
+	ListTag(ctx context.Context, in *ListTagRequest, opts ...grpc.CallOption) (*TagInfos, error)
+	// DeleteCommit moves a commit to the trash, only commits without
+	// children can be deleted.
+	DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a commit from the trash.
+	RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ListTrash returns the repos and commits which can be restored.
+	ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*TrashInfos, error)
+	// EmptyTrash permanently deletes what's in the trash.
+	EmptyTrash(ctx context.Context, in *EmptyTrashRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// File rpcs
+	// PutFile writes the specified file to pfs.
+	PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error)
+	// GetFile returns a byte stream of the contents of the file.
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error)
+	// SampleFile returns the first records of a file, or a random sample of
+	// them, without transferring the whole file.
+	SampleFile(ctx context.Context, in *SampleFileRequest, opts ...grpc.CallOption) (*FileSample, error)
+	// InspectFile returns info about a file.
+	InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	// ListFile streams info about the files in a directory as they're found.
+	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (API_ListFileClient, error)
+	// DeleteFile deletes a file.
+	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// CopyFile copies a file, the copy shares the original's blocks so no data is moved.
+	CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectShard returns the replication state of shards.
+	InspectShard(ctx context.Context, in *InspectShardRequest, opts ...grpc.CallOption) (*ShardInfos, error)
+	// InspectFileBlocks returns the blocks which hold a file, in order. Clients
+	// with access to the drive's block directory can read them directly.
+	InspectFileBlocks(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileBlocks, error)
+	// PinVersion pins the frontend's current version, rpcs which present the
+	// pin are routed with it until it's unpinned or expires.
+	PinVersion(ctx context.Context, in *PinVersionRequest, opts ...grpc.CallOption) (*VersionPin, error)
+	// UnpinVersion releases a pin.
+	UnpinVersion(ctx context.Context, in *VersionPin, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ConcatFiles creates a file from the concatenation of regular files, it
+	// shares their blocks so no data is moved.
+	ConcatFiles(ctx context.Context, in *ConcatFilesRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StartMultipartPut starts putting a file in parts.
+	StartMultipartPut(ctx context.Context, in *StartMultipartPutRequest, opts ...grpc.CallOption) (*MultipartPut, error)
+	// PutPart puts one part of a multipart put, parts can be put concurrently.
+	PutPart(ctx context.Context, opts ...grpc.CallOption) (API_PutPartClient, error)
+	// CompleteMultipartPut creates the file from its parts in number order, a
+	// multipart put which is never completed leaves no file behind.
+	CompleteMultipartPut(ctx context.Context, in *MultipartPut, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StartTransaction returns a transaction to start commits in.
+	StartTransaction(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Transaction, error)
+	// FinishTransaction finishes the commits started in a transaction, none
+	// of them are readable anywhere until all of them have been finished
+	// everywhere.
+	FinishTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// MergeCommits makes a finished commit, a child of ours, which has the
+	// changes theirs made since the two diverged.
+	MergeCommits(ctx context.Context, in *MergeCommitsRequest, opts ...grpc.CallOption) (*Commit, error)
+	// DedupStats reports how much storage repos' commits share.
+	DedupStats(ctx context.Context, in *DedupStatsRequest, opts ...grpc.CallOption) (*RepoDedupStatsList, error)
+	// ListServer returns the roles and load of every server.
+	ListServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerInfos, error)
+	// ListSlowOp returns every server's recent slow ops, slowest first.
+	ListSlowOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*SlowOps, error)
+	// ListOp returns the rpcs every server is serving, oldest first.
+	ListOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Ops, error)
+	// CancelOp cancels an rpc a server is serving.
+	CancelOp(ctx context.Context, in *CancelOpRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StatsFile infers the schema of a file, and how its fields' values are
+	// distributed, from a random sample of its records. The stats of files in
+	// finished commits are cached.
+	StatsFile(ctx context.Context, in *StatsFileRequest, opts ...grpc.CallOption) (*FileStats, error)
+	// StatsRepo returns the StatsFile of every file in a commit.
+	StatsRepo(ctx context.Context, in *StatsRepoRequest, opts ...grpc.CallOption) (*RepoStats, error)
+	// GetTar returns a byte stream of a tar of every file beneath a
+	// directory, files with the same blocks as one already in the tar are hard
+	// links to it.
+	GetTar(ctx context.Context, in *GetTarRequest, opts ...grpc.CallOption) (API_GetTarClient, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/CreateRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
+	out := new(RepoInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
+	out := new(RepoInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*ShardDeletions, error) {
+	out := new(ShardDeletions)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) RestoreRepo(ctx context.Context, in *RestoreRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/RestoreRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error) {
+	out := new(Commit)
+	err := grpc.Invoke(ctx, "/pfs.API/StartCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*ConsistencyToken, error) {
+	out := new(ConsistencyToken)
+	err := grpc.Invoke(ctx, "/pfs.API/FinishCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
+	out := new(CommitInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) TagCommit(ctx context.Context, in *TagCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/TagCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListTag(ctx context.Context, in *ListTagRequest, opts ...grpc.CallOption) (*TagInfos, error) {
+	out := new(TagInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListTag", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/RestoreCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*TrashInfos, error) {
+	out := new(TrashInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListTrash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) EmptyTrash(ctx context.Context, in *EmptyTrashRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/EmptyTrash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/pfs.API/PutFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIPutFileClient{stream}
+	return x, nil
+}
+
+type API_PutFileClient interface {
+	Send(*PutFileRequest) error
+	CloseAndRecv() (*google_protobuf1.Empty, error)
+	grpc.ClientStream
+}
+
+type aPIPutFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIPutFileClient) Send(m *PutFileRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *aPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(google_protobuf1.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[1], c.cc, "/pfs.API/GetFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIGetFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_GetFileClient interface {
+	Recv() (*google_protobuf3.BytesValue, error)
+	grpc.ClientStream
+}
+
+type aPIGetFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
+	m := new(google_protobuf3.BytesValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) SampleFile(ctx context.Context, in *SampleFileRequest, opts ...grpc.CallOption) (*FileSample, error) {
+	out := new(FileSample)
+	err := grpc.Invoke(ctx, "/pfs.API/SampleFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	out := new(FileInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (API_ListFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[2], c.cc, "/pfs.API/ListFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIListFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_ListFileClient interface {
+	Recv() (*FileInfo, error)
+	grpc.ClientStream
+}
+
+type aPIListFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIListFileClient) Recv() (*FileInfo, error) {
+	m := new(FileInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/CopyFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectShard(ctx context.Context, in *InspectShardRequest, opts ...grpc.CallOption) (*ShardInfos, error) {
+	out := new(ShardInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectShard", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectFileBlocks(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileBlocks, error) {
+	out := new(FileBlocks)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectFileBlocks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) PinVersion(ctx context.Context, in *PinVersionRequest, opts ...grpc.CallOption) (*VersionPin, error) {
+	out := new(VersionPin)
+	err := grpc.Invoke(ctx, "/pfs.API/PinVersion", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) UnpinVersion(ctx context.Context, in *VersionPin, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/UnpinVersion", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ConcatFiles(ctx context.Context, in *ConcatFilesRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/ConcatFiles", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StartMultipartPut(ctx context.Context, in *StartMultipartPutRequest, opts ...grpc.CallOption) (*MultipartPut, error) {
+	out := new(MultipartPut)
+	err := grpc.Invoke(ctx, "/pfs.API/StartMultipartPut", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) PutPart(ctx context.Context, opts ...grpc.CallOption) (API_PutPartClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[3], c.cc, "/pfs.API/PutPart", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIPutPartClient{stream}
+	return x, nil
+}
+
+type API_PutPartClient interface {
+	Send(*PutPartRequest) error
+	CloseAndRecv() (*google_protobuf1.Empty, error)
+	grpc.ClientStream
+}
+
+type aPIPutPartClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIPutPartClient) Send(m *PutPartRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *aPIPutPartClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(google_protobuf1.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) CompleteMultipartPut(ctx context.Context, in *MultipartPut, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/CompleteMultipartPut", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StartTransaction(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := grpc.Invoke(ctx, "/pfs.API/StartTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FinishTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/FinishTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) MergeCommits(ctx context.Context, in *MergeCommitsRequest, opts ...grpc.CallOption) (*Commit, error) {
+	out := new(Commit)
+	err := grpc.Invoke(ctx, "/pfs.API/MergeCommits", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DedupStats(ctx context.Context, in *DedupStatsRequest, opts ...grpc.CallOption) (*RepoDedupStatsList, error) {
+	out := new(RepoDedupStatsList)
+	err := grpc.Invoke(ctx, "/pfs.API/DedupStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerInfos, error) {
+	out := new(ServerInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListServer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListSlowOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*SlowOps, error) {
+	out := new(SlowOps)
+	err := grpc.Invoke(ctx, "/pfs.API/ListSlowOp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Ops, error) {
+	out := new(Ops)
+	err := grpc.Invoke(ctx, "/pfs.API/ListOp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) CancelOp(ctx context.Context, in *CancelOpRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/CancelOp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StatsFile(ctx context.Context, in *StatsFileRequest, opts ...grpc.CallOption) (*FileStats, error) {
+	out := new(FileStats)
+	err := grpc.Invoke(ctx, "/pfs.API/StatsFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StatsRepo(ctx context.Context, in *StatsRepoRequest, opts ...grpc.CallOption) (*RepoStats, error) {
+	out := new(RepoStats)
+	err := grpc.Invoke(ctx, "/pfs.API/StatsRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) GetTar(ctx context.Context, in *GetTarRequest, opts ...grpc.CallOption) (API_GetTarClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[4], c.cc, "/pfs.API/GetTar", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIGetTarClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_GetTarClient interface {
+	Recv() (*google_protobuf3.BytesValue, error)
+	grpc.ClientStream
+}
+
+type aPIGetTarClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIGetTarClient) Recv() (*google_protobuf3.BytesValue, error) {
+	m := new(google_protobuf3.BytesValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for API service
+
+type APIServer interface {
+	// Repo rpcs
+	// CreateRepo creates a new repo.
+	// An error is returned if the repo already exists.
+	CreateRepo(context.Context, *CreateRepoRequest) (*google_protobuf1.Empty, error)
+	// InspectRepo returns info about a repo.
+	InspectRepo(context.Context, *InspectRepoRequest) (*RepoInfo, error)
+	// ListRepo returns info about all repos.
+	ListRepo(context.Context, *ListRepoRequest) (*RepoInfos, error)
+	// DeleteRepo moves a repo to the trash, or deletes it and all of its
+	// commits from every shard if purge is set.
+	// Repos which pipelines read from or write to are only deleted if force is set.
+	DeleteRepo(context.Context, *DeleteRepoRequest) (*ShardDeletions, error)
+	// RestoreRepo restores a repo from the trash.
+	RestoreRepo(context.Context, *RestoreRepoRequest) (*google_protobuf1.Empty, error)
+	// Commit rpcs
+	// StartCommit creates a new write commit from a parent commit.
+	StartCommit(context.Context, *StartCommitRequest) (*Commit, error)
+	// FinishCommit turns a write commit into a read commit.
+	// The returned token can be presented to reads so they see the commit.
+	FinishCommit(context.Context, *FinishCommitRequest) (*ConsistencyToken, error)
+	// InspectCommit returns the info about a commit.
+	InspectCommit(context.Context, *InspectCommitRequest) (*CommitInfo, error)
+	// ListCommit returns info about all commits.
+	ListCommit(context.Context, *ListCommitRequest) (*CommitInfos, error)
+	// TagCommit gives a finished commit a name which can be used anywhere its
+	// id can, tags can't be moved to another commit.
+	TagCommit(context.Context, *TagCommitRequest) (*google_protobuf1.Empty, error)
+	// ListTag returns the tags in a repo.
+	ListTag(context.Context, *ListTagRequest) (*TagInfos, error)
+	// DeleteCommit moves a commit to the trash, only commits without
+	// children can be deleted.
+	DeleteCommit(context.Context, *DeleteCommitRequest) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a commit from the trash.
+	RestoreCommit(context.Context, *RestoreCommitRequest) (*google_protobuf1.Empty, error)
+	// ListTrash returns the repos and commits which can be restored.
+	ListTrash(context.Context, *ListTrashRequest) (*TrashInfos, error)
+	// EmptyTrash permanently deletes what's in the trash.
+	EmptyTrash(context.Context, *EmptyTrashRequest) (*google_protobuf1.Empty, error)
+	// File rpcs
+	// PutFile writes the specified file to pfs.
+	PutFile(API_PutFileServer) error
+	// GetFile returns a byte stream of the contents of the file.
+	GetFile(*GetFileRequest, API_GetFileServer) error
+	// SampleFile returns the first records of a file, or a random sample of
+	// them, without transferring the whole file.
+	SampleFile(context.Context, *SampleFileRequest) (*FileSample, error)
+	// InspectFile returns info about a file.
+	InspectFile(context.Context, *InspectFileRequest) (*FileInfo, error)
+	// ListFile streams info about the files in a directory as they're found.
+	ListFile(*ListFileRequest, API_ListFileServer) error
+	// DeleteFile deletes a file.
+	DeleteFile(context.Context, *DeleteFileRequest) (*google_protobuf1.Empty, error)
+	// CopyFile copies a file, the copy shares the original's blocks so no data is moved.
+	CopyFile(context.Context, *CopyFileRequest) (*google_protobuf1.Empty, error)
+	// InspectShard returns the replication state of shards.
+	InspectShard(context.Context, *InspectShardRequest) (*ShardInfos, error)
+	// InspectFileBlocks returns the blocks which hold a file, in order. Clients
+	// with access to the drive's block directory can read them directly.
+	InspectFileBlocks(context.Context, *InspectFileRequest) (*FileBlocks, error)
+	// PinVersion pins the frontend's current version, rpcs which present the
+	// pin are routed with it until it's unpinned or expires.
+	PinVersion(context.Context, *PinVersionRequest) (*VersionPin, error)
+	// UnpinVersion releases a pin.
+	UnpinVersion(context.Context, *VersionPin) (*google_protobuf1.Empty, error)
+	// ConcatFiles creates a file from the concatenation of regular files, it
+	// shares their blocks so no data is moved.
+	ConcatFiles(context.Context, *ConcatFilesRequest) (*google_protobuf1.Empty, error)
+	// StartMultipartPut starts putting a file in parts.
+	StartMultipartPut(context.Context, *StartMultipartPutRequest) (*MultipartPut, error)
+	// PutPart puts one part of a multipart put, parts can be put concurrently.
+	PutPart(API_PutPartServer) error
+	// CompleteMultipartPut creates the file from its parts in number order, a
+	// multipart put which is never completed leaves no file behind.
+	CompleteMultipartPut(context.Context, *MultipartPut) (*google_protobuf1.Empty, error)
+	// StartTransaction returns a transaction to start commits in.
+	StartTransaction(context.Context, *google_protobuf1.Empty) (*Transaction, error)
+	// FinishTransaction finishes the commits started in a transaction, none
+	// of them are readable anywhere until all of them have been finished
+	// everywhere.
+	FinishTransaction(context.Context, *FinishTransactionRequest) (*google_protobuf1.Empty, error)
+	// MergeCommits makes a finished commit, a child of ours, which has the
+	// changes theirs made since the two diverged.
+	MergeCommits(context.Context, *MergeCommitsRequest) (*Commit, error)
+	// DedupStats reports how much storage repos' commits share.
+	DedupStats(context.Context, *DedupStatsRequest) (*RepoDedupStatsList, error)
+	// ListServer returns the roles and load of every server.
+	ListServer(context.Context, *google_protobuf1.Empty) (*ServerInfos, error)
+	// ListSlowOp returns every server's recent slow ops, slowest first.
+	ListSlowOp(context.Context, *google_protobuf1.Empty) (*SlowOps, error)
+	// ListOp returns the rpcs every server is serving, oldest first.
+	ListOp(context.Context, *google_protobuf1.Empty) (*Ops, error)
+	// CancelOp cancels an rpc a server is serving.
+	CancelOp(context.Context, *CancelOpRequest) (*google_protobuf1.Empty, error)
+	// StatsFile infers the schema of a file, and how its fields' values are
+	// distributed, from a random sample of its records. The stats of files in
+	// finished commits are cached.
+	StatsFile(context.Context, *StatsFileRequest) (*FileStats, error)
+	// StatsRepo returns the StatsFile of every file in a commit.
+	StatsRepo(context.Context, *StatsRepoRequest) (*RepoStats, error)
+	// GetTar returns a byte stream of a tar of every file beneath a
+	// directory, files with the same blocks as one already in the tar are hard
+	// links to it.
+	GetTar(*GetTarRequest, API_GetTarServer) error
+}
+
+func RegisterAPIServer(s *grpc.Server, srv APIServer) {
+	s.RegisterService(&_API_serviceDesc, srv)
+}
+
+func _API_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CreateRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CreateRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).InspectRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeleteRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).DeleteRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_RestoreRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RestoreRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).RestoreRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StartCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).StartCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).FinishCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).InspectCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_TagCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TagCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).TagCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListTagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListTag(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeleteCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).DeleteCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_RestoreCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RestoreCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).RestoreCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListTrash(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_EmptyTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(EmptyTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).EmptyTrash(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(APIServer).PutFile(&aPIPutFileServer{stream})
+}
+
+type API_PutFileServer interface {
+	SendAndClose(*google_protobuf1.Empty) error
+	Recv() (*PutFileRequest, error)
+	grpc.ServerStream
+}
+
+type aPIPutFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *aPIPutFileServer) Recv() (*PutFileRequest, error) {
+	m := new(PutFileRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _API_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).GetFile(m, &aPIGetFileServer{stream})
+}
+
+type API_GetFileServer interface {
+	Send(*google_protobuf3.BytesValue) error
+	grpc.ServerStream
+}
+
+type aPIGetFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_SampleFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(SampleFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).SampleFile(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).InspectFile(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).ListFile(m, &aPIListFileServer{stream})
+}
+
+type API_ListFileServer interface {
+	Send(*FileInfo) error
+	grpc.ServerStream
+}
+
+type aPIListFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIListFileServer) Send(m *FileInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeleteFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).DeleteFile(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_CopyFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CopyFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CopyFile(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_InspectShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).InspectShard(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_InspectFileBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).InspectFileBlocks(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_PinVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(PinVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).PinVersion(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_UnpinVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(VersionPin)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).UnpinVersion(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ConcatFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ConcatFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ConcatFiles(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_StartMultipartPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StartMultipartPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).StartMultipartPut(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_PutPart_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(APIServer).PutPart(&aPIPutPartServer{stream})
+}
+
+type API_PutPartServer interface {
+	SendAndClose(*google_protobuf1.Empty) error
+	Recv() (*PutPartRequest, error)
+	grpc.ServerStream
+}
+
+type aPIPutPartServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIPutPartServer) SendAndClose(m *google_protobuf1.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *aPIPutPartServer) Recv() (*PutPartRequest, error) {
+	m := new(PutPartRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _API_CompleteMultipartPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(MultipartPut)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CompleteMultipartPut(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_StartTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).StartTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_FinishTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).FinishTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_MergeCommits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(MergeCommitsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).MergeCommits(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_DedupStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DedupStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).DedupStats(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListServer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListSlowOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListSlowOp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListOp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_CancelOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CancelOpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CancelOp(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_StatsFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StatsFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).StatsFile(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_StatsRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StatsRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).StatsRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_GetTar_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetTarRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).GetTar(m, &aPIGetTarServer{stream})
+}
+
+type API_GetTarServer interface {
+	Send(*google_protobuf3.BytesValue) error
+	grpc.ServerStream
+}
+
+type aPIGetTarServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIGetTarServer) Send(m *google_protobuf3.BytesValue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _API_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pfs.API",
+	HandlerType: (*APIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateRepo",
+			Handler:    _API_CreateRepo_Handler,
+		},
+		{
+			MethodName: "InspectRepo",
+			Handler:    _API_InspectRepo_Handler,
+		},
+		{
+			MethodName: "ListRepo",
+			Handler:    _API_ListRepo_Handler,
+		},
+		{
+			MethodName: "DeleteRepo",
+			Handler:    _API_DeleteRepo_Handler,
+		},
+		{
+			MethodName: "RestoreRepo",
+			Handler:    _API_RestoreRepo_Handler,
+		},
+		{
+			MethodName: "StartCommit",
+			Handler:    _API_StartCommit_Handler,
+		},
+		{
+			MethodName: "FinishCommit",
+			Handler:    _API_FinishCommit_Handler,
+		},
+		{
+			MethodName: "InspectCommit",
+			Handler:    _API_InspectCommit_Handler,
+		},
+		{
+			MethodName: "ListCommit",
+			Handler:    _API_ListCommit_Handler,
+		},
+		{
+			MethodName: "TagCommit",
+			Handler:    _API_TagCommit_Handler,
+		},
+		{
+			MethodName: "ListTag",
+			Handler:    _API_ListTag_Handler,
+		},
+		{
+			MethodName: "DeleteCommit",
+			Handler:    _API_DeleteCommit_Handler,
+		},
+		{
+			MethodName: "RestoreCommit",
+			Handler:    _API_RestoreCommit_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _API_ListTrash_Handler,
+		},
+		{
+			MethodName: "EmptyTrash",
+			Handler:    _API_EmptyTrash_Handler,
+		},
+		{
+			MethodName: "SampleFile",
+			Handler:    _API_SampleFile_Handler,
+		},
+		{
+			MethodName: "InspectFile",
+			Handler:    _API_InspectFile_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _API_DeleteFile_Handler,
+		},
+		{
+			MethodName: "CopyFile",
+			Handler:    _API_CopyFile_Handler,
+		},
+		{
+			MethodName: "InspectShard",
+			Handler:    _API_InspectShard_Handler,
+		},
+		{
+			MethodName: "InspectFileBlocks",
+			Handler:    _API_InspectFileBlocks_Handler,
+		},
+		{
+			MethodName: "PinVersion",
+			Handler:    _API_PinVersion_Handler,
+		},
+		{
+			MethodName: "UnpinVersion",
+			Handler:    _API_UnpinVersion_Handler,
+		},
+		{
+			MethodName: "ConcatFiles",
+			Handler:    _API_ConcatFiles_Handler,
+		},
+		{
+			MethodName: "StartMultipartPut",
+			Handler:    _API_StartMultipartPut_Handler,
+		},
+		{
+			MethodName: "CompleteMultipartPut",
+			Handler:    _API_CompleteMultipartPut_Handler,
+		},
+		{
+			MethodName: "StartTransaction",
+			Handler:    _API_StartTransaction_Handler,
+		},
+		{
+			MethodName: "FinishTransaction",
+			Handler:    _API_FinishTransaction_Handler,
+		},
+		{
+			MethodName: "MergeCommits",
+			Handler:    _API_MergeCommits_Handler,
+		},
+		{
+			MethodName: "DedupStats",
+			Handler:    _API_DedupStats_Handler,
+		},
+		{
+			MethodName: "ListServer",
+			Handler:    _API_ListServer_Handler,
+		},
+		{
+			MethodName: "ListSlowOp",
+			Handler:    _API_ListSlowOp_Handler,
+		},
+		{
+			MethodName: "ListOp",
+			Handler:    _API_ListOp_Handler,
+		},
+		{
+			MethodName: "CancelOp",
+			Handler:    _API_CancelOp_Handler,
+		},
+		{
+			MethodName: "StatsFile",
+			Handler:    _API_StatsFile_Handler,
+		},
+		{
+			MethodName: "StatsRepo",
+			Handler:    _API_StatsRepo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PutFile",
+			Handler:       _API_PutFile_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetFile",
+			Handler:       _API_GetFile_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListFile",
+			Handler:       _API_ListFile_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutPart",
+			Handler:       _API_PutPart_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetTar",
+			Handler:       _API_GetTar_Handler,
+			ServerStreams: true,
+		},
+	},
+}
+
+// Client API for InternalAPI service
+
+type InternalAPIClient interface {
+	// Repo rpcs
+	// CreateRepo creates a new repo.
+	// An error is returned if the repo already exists.
+	CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectRepo returns info about a repo.
+	InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error)
+	// ListRepo returns info about all repos.
 	ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error)
-	// DeleteRepo deletes a repo.
-	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// DeleteRepo deletes a repo from the shards this server hosts.
+	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*ShardDeletions, error)
+	// RestoreRepo restores a repo from the trash.
+	RestoreRepo(ctx context.Context, in *RestoreRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// Commit rpcs
 	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error)
+	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// FinishCommit turns a write commit into a read commit.
 	FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// InspectCommit returns the info about a commit.
 	InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error)
 	// ListCommit returns info about all commits.
 	ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
-	// DeleteCommit deletes a commit.
+	// TagCommit gives a finished commit a name which can be used anywhere its
+	// id can, tags can't be moved to another commit.
+	TagCommit(ctx context.Context, in *TagCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ListTag returns the tags in a repo.
+	ListTag(ctx context.Context, in *ListTagRequest, opts ...grpc.CallOption) (*TagInfos, error)
+	// DeleteCommit moves a commit to the trash, only commits without
+	// children can be deleted.
 	DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a commit from the trash.
+	RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ListTrash returns the repos and commits which can be restored.
+	ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*TrashInfos, error)
+	// EmptyTrash permanently deletes what's in the trash.
+	EmptyTrash(ctx context.Context, in *EmptyTrashRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// File rpcs
 	// PutFile writes the specified file to pfs.
-	PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error)
+	PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error)
 	// GetFile returns a byte stream of the contents of the file.
-	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error)
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error)
+	// SampleFile returns the first records of a file, or a random sample of
+	// them, without transferring the whole file.
+	SampleFile(ctx context.Context, in *SampleFileRequest, opts ...grpc.CallOption) (*FileSample, error)
 	// InspectFile returns info about a file.
 	InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
-	// ListFile returns info about all files.
-	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error)
+	// ListFile streams info about the files in a directory as they're found.
+	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (InternalAPI_ListFileClient, error)
 	// DeleteFile deletes a file.
 	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// CopyFile copies a regular file, the copy shares the original's blocks so no data is moved.
+	CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectLocalShard returns the repos this server has stored for a shard
+	// regardless of whether it's the shard's master or a replica.
+	InspectLocalShard(ctx context.Context, in *InspectLocalShardRequest, opts ...grpc.CallOption) (*RepoInfos, error)
+	// InspectFileBlocks returns the blocks which hold a file, in order.
+	InspectFileBlocks(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileBlocks, error)
+	// ConcatFiles creates a file from the concatenation of regular files.
+	ConcatFiles(ctx context.Context, in *ConcatFilesRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StartMultipartPut starts putting a file in parts.
+	StartMultipartPut(ctx context.Context, in *StartMultipartPutRequest, opts ...grpc.CallOption) (*MultipartPut, error)
+	// PutPart puts one part of a multipart put.
+	PutPart(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutPartClient, error)
+	// CompleteMultipartPut creates the file from its parts in number order.
+	CompleteMultipartPut(ctx context.Context, in *MultipartPut, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// PrepareTransaction finishes the commits started in a transaction without
+	// making them readable.
+	PrepareTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// MergeCommits makes the merge commit.
+	MergeCommits(ctx context.Context, in *MergeCommitsRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// DedupStats reports on the shards this server hosts.
+	DedupStats(ctx context.Context, in *DedupStatsRequest, opts ...grpc.CallOption) (*RepoDedupStatsList, error)
+	// InspectServer returns this server's load.
+	InspectServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStats, error)
+	// ListSlowOp returns this server's recent slow ops.
+	ListSlowOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*SlowOps, error)
+	// ListOp returns the rpcs this server's process is serving.
+	ListOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Ops, error)
+	// CancelOp cancels an rpc this server's process is serving.
+	CancelOp(ctx context.Context, in *CancelOpRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StatsFile infers the schema of a file, and how its fields' values are
+	// distributed, from a random sample of its records. The stats of files in
+	// finished commits are cached.
+	StatsFile(ctx context.Context, in *StatsFileRequest, opts ...grpc.CallOption) (*FileStats, error)
+	// CommitTransaction records that a transaction every server has prepared
+	// will be released.
+	CommitTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ReleaseTransaction makes the commits of a committed transaction readable
+	// and returns them.
+	ReleaseTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// AbortTransaction undoes PrepareTransaction.
+	AbortTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+}
+
+type internalAPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewInternalAPIClient(cc *grpc.ClientConn) InternalAPIClient {
+	return &internalAPIClient{cc}
+}
+
+func (c *internalAPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CreateRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
+	out := new(RepoInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
+	out := new(RepoInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*ShardDeletions, error) {
+	out := new(ShardDeletions)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) RestoreRepo(ctx context.Context, in *RestoreRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/RestoreRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/StartCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FinishCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
+	out := new(CommitInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) TagCommit(ctx context.Context, in *TagCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/TagCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListTag(ctx context.Context, in *ListTagRequest, opts ...grpc.CallOption) (*TagInfos, error) {
+	out := new(TagInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListTag", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/RestoreCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*TrashInfos, error) {
+	out := new(TrashInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListTrash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) EmptyTrash(ctx context.Context, in *EmptyTrashRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/EmptyTrash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[0], c.cc, "/pfs.InternalAPI/PutFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIPutFileClient{stream}
+	return x, nil
+}
+
+type InternalAPI_PutFileClient interface {
+	Send(*PutFileRequest) error
+	CloseAndRecv() (*google_protobuf1.Empty, error)
+	grpc.ClientStream
+}
+
+type internalAPIPutFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *internalAPIPutFileClient) Send(m *PutFileRequest) error {
+	return x.ClientStream.SendMsg(m)
 }
 
-type aPIClient struct {
-	cc *grpc.ClientConn
+func (x *internalAPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(google_protobuf1.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func NewAPIClient(cc *grpc.ClientConn) APIClient {
-	return &aPIClient{cc}
+func (c *internalAPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[1], c.cc, "/pfs.InternalAPI/GetFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIGetFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
 
-func (c *aPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/CreateRepo", in, out, c.cc, opts...)
+type InternalAPI_GetFileClient interface {
+	Recv() (*google_protobuf3.BytesValue, error)
+	grpc.ClientStream
+}
+
+type internalAPIGetFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *internalAPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
+	m := new(google_protobuf3.BytesValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *internalAPIClient) SampleFile(ctx context.Context, in *SampleFileRequest, opts ...grpc.CallOption) (*FileSample, error) {
+	out := new(FileSample)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/SampleFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
-	out := new(RepoInfo)
-	err := grpc.Invoke(ctx, "/pfs.API/InspectRepo", in, out, c.cc, opts...)
+func (c *internalAPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	out := new(FileInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
-	out := new(RepoInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListRepo", in, out, c.cc, opts...)
+func (c *internalAPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (InternalAPI_ListFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[2], c.cc, "/pfs.InternalAPI/ListFile", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &internalAPIListFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InternalAPI_ListFileClient interface {
+	Recv() (*FileInfo, error)
+	grpc.ClientStream
+}
+
+type internalAPIListFileClient struct {
+	grpc.ClientStream
 }
 
-func (c *aPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+func (x *internalAPIListFileClient) Recv() (*FileInfo, error) {
+	m := new(FileInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *internalAPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
 	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/DeleteRepo", in, out, c.cc, opts...)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error) {
-	out := new(Commit)
-	err := grpc.Invoke(ctx, "/pfs.API/StartCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) CopyFile(ctx context.Context, in *CopyFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CopyFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/FinishCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) InspectLocalShard(ctx context.Context, in *InspectLocalShardRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
+	out := new(RepoInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectLocalShard", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
-	out := new(CommitInfo)
-	err := grpc.Invoke(ctx, "/pfs.API/InspectCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) InspectFileBlocks(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileBlocks, error) {
+	out := new(FileBlocks)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectFileBlocks", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) ConcatFiles(ctx context.Context, in *ConcatFilesRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ConcatFiles", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/DeleteCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) StartMultipartPut(ctx context.Context, in *StartMultipartPutRequest, opts ...grpc.CallOption) (*MultipartPut, error) {
+	out := new(MultipartPut)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/StartMultipartPut", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/pfs.API/PutFile", opts...)
+func (c *internalAPIClient) PutPart(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutPartClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[3], c.cc, "/pfs.InternalAPI/PutPart", opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &aPIPutFileClient{stream}
+	x := &internalAPIPutPartClient{stream}
 	return x, nil
 }
 
-type API_PutFileClient interface {
-	Send(*PutFileRequest) error
+type InternalAPI_PutPartClient interface {
+	Send(*PutPartRequest) error
 	CloseAndRecv() (*google_protobuf1.Empty, error)
 	grpc.ClientStream
 }
 
-type aPIPutFileClient struct {
+type internalAPIPutPartClient struct {
 	grpc.ClientStream
 }
 
-func (x *aPIPutFileClient) Send(m *PutFileRequest) error {
+func (x *internalAPIPutPartClient) Send(m *PutPartRequest) error {
 	return x.ClientStream.SendMsg(m)
 }
 
-func (x *aPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
+func (x *internalAPIPutPartClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
 	if err := x.ClientStream.CloseSend(); err != nil {
 		return nil, err
 	}
@@ -859,68 +3949,117 @@ func (x *aPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
 	return m, nil
 }
 
-func (c *aPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[1], c.cc, "/pfs.API/GetFile", opts...)
+func (c *internalAPIClient) CompleteMultipartPut(ctx context.Context, in *MultipartPut, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CompleteMultipartPut", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &aPIGetFileClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
+	return out, nil
+}
+
+func (c *internalAPIClient) PrepareTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/PrepareTransaction", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
+	return out, nil
+}
+
+func (c *internalAPIClient) MergeCommits(ctx context.Context, in *MergeCommitsRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/MergeCommits", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	return x, nil
+	return out, nil
 }
 
-type API_GetFileClient interface {
-	Recv() (*google_protobuf3.BytesValue, error)
-	grpc.ClientStream
+func (c *internalAPIClient) DedupStats(ctx context.Context, in *DedupStatsRequest, opts ...grpc.CallOption) (*RepoDedupStatsList, error) {
+	out := new(RepoDedupStatsList)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DedupStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-type aPIGetFileClient struct {
-	grpc.ClientStream
+func (c *internalAPIClient) InspectServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStats, error) {
+	out := new(ServerStats)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectServer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (x *aPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
-	m := new(google_protobuf3.BytesValue)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func (c *internalAPIClient) ListSlowOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*SlowOps, error) {
+	out := new(SlowOps)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListSlowOp", in, out, c.cc, opts...)
+	if err != nil {
 		return nil, err
 	}
-	return m, nil
+	return out, nil
 }
 
-func (c *aPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
-	out := new(FileInfo)
-	err := grpc.Invoke(ctx, "/pfs.API/InspectFile", in, out, c.cc, opts...)
+func (c *internalAPIClient) ListOp(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Ops, error) {
+	out := new(Ops)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListOp", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error) {
-	out := new(FileInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListFile", in, out, c.cc, opts...)
+func (c *internalAPIClient) CancelOp(ctx context.Context, in *CancelOpRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CancelOp", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+func (c *internalAPIClient) StatsFile(ctx context.Context, in *StatsFileRequest, opts ...grpc.CallOption) (*FileStats, error) {
+	out := new(FileStats)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/StatsFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) CommitTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
 	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/DeleteFile", in, out, c.cc, opts...)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CommitTransaction", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for API service
+func (c *internalAPIClient) ReleaseTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ReleaseTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-type APIServer interface {
+func (c *internalAPIClient) AbortTransaction(ctx context.Context, in *FinishTransactionRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/AbortTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for InternalAPI service
+
+type InternalAPIServer interface {
 	// Repo rpcs
 	// CreateRepo creates a new repo.
 	// An error is returned if the repo already exists.
@@ -929,163 +4068,294 @@ type APIServer interface {
 	InspectRepo(context.Context, *InspectRepoRequest) (*RepoInfo, error)
 	// ListRepo returns info about all repos.
 	ListRepo(context.Context, *ListRepoRequest) (*RepoInfos, error)
-	// DeleteRepo deletes a repo.
-	DeleteRepo(context.Context, *DeleteRepoRequest) (*google_protobuf1.Empty, error)
+	// DeleteRepo deletes a repo from the shards this server hosts.
+	DeleteRepo(context.Context, *DeleteRepoRequest) (*ShardDeletions, error)
+	// RestoreRepo restores a repo from the trash.
+	RestoreRepo(context.Context, *RestoreRepoRequest) (*google_protobuf1.Empty, error)
 	// Commit rpcs
 	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(context.Context, *StartCommitRequest) (*Commit, error)
+	StartCommit(context.Context, *StartCommitRequest) (*google_protobuf1.Empty, error)
 	// FinishCommit turns a write commit into a read commit.
 	FinishCommit(context.Context, *FinishCommitRequest) (*google_protobuf1.Empty, error)
 	// InspectCommit returns the info about a commit.
 	InspectCommit(context.Context, *InspectCommitRequest) (*CommitInfo, error)
 	// ListCommit returns info about all commits.
 	ListCommit(context.Context, *ListCommitRequest) (*CommitInfos, error)
-	// DeleteCommit deletes a commit.
+	// TagCommit gives a finished commit a name which can be used anywhere its
+	// id can, tags can't be moved to another commit.
+	TagCommit(context.Context, *TagCommitRequest) (*google_protobuf1.Empty, error)
+	// ListTag returns the tags in a repo.
+	ListTag(context.Context, *ListTagRequest) (*TagInfos, error)
+	// DeleteCommit moves a commit to the trash, only commits without
+	// children can be deleted.
 	DeleteCommit(context.Context, *DeleteCommitRequest) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a commit from the trash.
+	RestoreCommit(context.Context, *RestoreCommitRequest) (*google_protobuf1.Empty, error)
+	// ListTrash returns the repos and commits which can be restored.
+	ListTrash(context.Context, *ListTrashRequest) (*TrashInfos, error)
+	// EmptyTrash permanently deletes what's in the trash.
+	EmptyTrash(context.Context, *EmptyTrashRequest) (*google_protobuf1.Empty, error)
 	// File rpcs
 	// PutFile writes the specified file to pfs.
-	PutFile(API_PutFileServer) error
+	PutFile(InternalAPI_PutFileServer) error
 	// GetFile returns a byte stream of the contents of the file.
-	GetFile(*GetFileRequest, API_GetFileServer) error
+	GetFile(*GetFileRequest, InternalAPI_GetFileServer) error
+	// SampleFile returns the first records of a file, or a random sample of
+	// them, without transferring the whole file.
+	SampleFile(context.Context, *SampleFileRequest) (*FileSample, error)
 	// InspectFile returns info about a file.
 	InspectFile(context.Context, *InspectFileRequest) (*FileInfo, error)
-	// ListFile returns info about all files.
-	ListFile(context.Context, *ListFileRequest) (*FileInfos, error)
+	// ListFile streams info about the files in a directory as they're found.
+	ListFile(*ListFileRequest, InternalAPI_ListFileServer) error
 	// DeleteFile deletes a file.
 	DeleteFile(context.Context, *DeleteFileRequest) (*google_protobuf1.Empty, error)
+	// CopyFile copies a regular file, the copy shares the original's blocks so no data is moved.
+	CopyFile(context.Context, *CopyFileRequest) (*google_protobuf1.Empty, error)
+	// InspectLocalShard returns the repos this server has stored for a shard
+	// regardless of whether it's the shard's master or a replica.
+	InspectLocalShard(context.Context, *InspectLocalShardRequest) (*RepoInfos, error)
+	// InspectFileBlocks returns the blocks which hold a file, in order.
+	InspectFileBlocks(context.Context, *InspectFileRequest) (*FileBlocks, error)
+	// ConcatFiles creates a file from the concatenation of regular files.
+	ConcatFiles(context.Context, *ConcatFilesRequest) (*google_protobuf1.Empty, error)
+	// StartMultipartPut starts putting a file in parts.
+	StartMultipartPut(context.Context, *StartMultipartPutRequest) (*MultipartPut, error)
+	// PutPart puts one part of a multipart put.
+	PutPart(InternalAPI_PutPartServer) error
+	// CompleteMultipartPut creates the file from its parts in number order.
+	CompleteMultipartPut(context.Context, *MultipartPut) (*google_protobuf1.Empty, error)
+	// PrepareTransaction finishes the commits started in a transaction without
+	// making them readable.
+	PrepareTransaction(context.Context, *FinishTransactionRequest) (*google_protobuf1.Empty, error)
+	// MergeCommits makes the merge commit.
+	MergeCommits(context.Context, *MergeCommitsRequest) (*google_protobuf1.Empty, error)
+	// DedupStats reports on the shards this server hosts.
+	DedupStats(context.Context, *DedupStatsRequest) (*RepoDedupStatsList, error)
+	// InspectServer returns this server's load.
+	InspectServer(context.Context, *google_protobuf1.Empty) (*ServerStats, error)
+	// ListSlowOp returns this server's recent slow ops.
+	ListSlowOp(context.Context, *google_protobuf1.Empty) (*SlowOps, error)
+	// ListOp returns the rpcs this server's process is serving.
+	ListOp(context.Context, *google_protobuf1.Empty) (*Ops, error)
+	// CancelOp cancels an rpc this server's process is serving.
+	CancelOp(context.Context, *CancelOpRequest) (*google_protobuf1.Empty, error)
+	// StatsFile infers the schema of a file, and how its fields' values are
+	// distributed, from a random sample of its records. The stats of files in
+	// finished commits are cached.
+	StatsFile(context.Context, *StatsFileRequest) (*FileStats, error)
+	// CommitTransaction records that a transaction every server has prepared
+	// will be released.
+	CommitTransaction(context.Context, *FinishTransactionRequest) (*google_protobuf1.Empty, error)
+	// ReleaseTransaction makes the commits of a committed transaction readable
+	// and returns them.
+	ReleaseTransaction(context.Context, *FinishTransactionRequest) (*CommitInfos, error)
+	// AbortTransaction undoes PrepareTransaction.
+	AbortTransaction(context.Context, *FinishTransactionRequest) (*google_protobuf1.Empty, error)
 }
 
-func RegisterAPIServer(s *grpc.Server, srv APIServer) {
-	s.RegisterService(&_API_serviceDesc, srv)
+func RegisterInternalAPIServer(s *grpc.Server, srv InternalAPIServer) {
+	s.RegisterService(&_InternalAPI_serviceDesc, srv)
 }
 
-func _API_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _InternalAPI_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(CreateRepoRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).CreateRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).CreateRepo(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+func _InternalAPI_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(InspectRepoRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).InspectRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).InspectRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _InternalAPI_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).ListRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _InternalAPI_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeleteRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).DeleteRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _InternalAPI_RestoreRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RestoreRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).RestoreRepo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _InternalAPI_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StartCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).StartCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _InternalAPI_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).FinishCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _InternalAPI_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).InspectCommit(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListRepoRequest)
+func _InternalAPI_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).ListRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).ListCommit(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(DeleteRepoRequest)
+func _InternalAPI_TagCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TagCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).DeleteRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).TagCommit(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(StartCommitRequest)
+func _InternalAPI_ListTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListTagRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).StartCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).ListTag(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(FinishCommitRequest)
+func _InternalAPI_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeleteCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).FinishCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).DeleteCommit(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(InspectCommitRequest)
+func _InternalAPI_RestoreCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RestoreCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).InspectCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).RestoreCommit(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListCommitRequest)
+func _InternalAPI_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListTrashRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).ListCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).ListTrash(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(DeleteCommitRequest)
+func _InternalAPI_EmptyTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(EmptyTrashRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).DeleteCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).EmptyTrash(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(APIServer).PutFile(&aPIPutFileServer{stream})
+func _InternalAPI_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InternalAPIServer).PutFile(&internalAPIPutFileServer{stream})
 }
 
-type API_PutFileServer interface {
+type InternalAPI_PutFileServer interface {
 	SendAndClose(*google_protobuf1.Empty) error
 	Recv() (*PutFileRequest, error)
 	grpc.ServerStream
 }
 
-type aPIPutFileServer struct {
+type internalAPIPutFileServer struct {
 	grpc.ServerStream
 }
 
-func (x *aPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
+func (x *internalAPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *aPIPutFileServer) Recv() (*PutFileRequest, error) {
+func (x *internalAPIPutFileServer) Recv() (*PutFileRequest, error) {
 	m := new(PutFileRequest)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
@@ -1093,575 +4363,308 @@ func (x *aPIPutFileServer) Recv() (*PutFileRequest, error) {
 	return m, nil
 }
 
-func _API_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+func _InternalAPI_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(GetFileRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(APIServer).GetFile(m, &aPIGetFileServer{stream})
+	return srv.(InternalAPIServer).GetFile(m, &internalAPIGetFileServer{stream})
 }
 
-type API_GetFileServer interface {
+type InternalAPI_GetFileServer interface {
 	Send(*google_protobuf3.BytesValue) error
 	grpc.ServerStream
 }
 
-type aPIGetFileServer struct {
+type internalAPIGetFileServer struct {
 	grpc.ServerStream
 }
 
-func (x *aPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
+func (x *internalAPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _API_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(InspectFileRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	out, err := srv.(APIServer).InspectFile(ctx, in)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func _API_ListFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListFileRequest)
+func _InternalAPI_SampleFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(SampleFileRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(APIServer).ListFile(ctx, in)
+	out, err := srv.(InternalAPIServer).SampleFile(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _API_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(DeleteFileRequest)
+func _InternalAPI_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectFileRequest)
 	if err := dec(in); err != nil {
 		return nil, err
-	}
-	out, err := srv.(APIServer).DeleteFile(ctx, in)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-var _API_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "pfs.API",
-	HandlerType: (*APIServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "CreateRepo",
-			Handler:    _API_CreateRepo_Handler,
-		},
-		{
-			MethodName: "InspectRepo",
-			Handler:    _API_InspectRepo_Handler,
-		},
-		{
-			MethodName: "ListRepo",
-			Handler:    _API_ListRepo_Handler,
-		},
-		{
-			MethodName: "DeleteRepo",
-			Handler:    _API_DeleteRepo_Handler,
-		},
-		{
-			MethodName: "StartCommit",
-			Handler:    _API_StartCommit_Handler,
-		},
-		{
-			MethodName: "FinishCommit",
-			Handler:    _API_FinishCommit_Handler,
-		},
-		{
-			MethodName: "InspectCommit",
-			Handler:    _API_InspectCommit_Handler,
-		},
-		{
-			MethodName: "ListCommit",
-			Handler:    _API_ListCommit_Handler,
-		},
-		{
-			MethodName: "DeleteCommit",
-			Handler:    _API_DeleteCommit_Handler,
-		},
-		{
-			MethodName: "InspectFile",
-			Handler:    _API_InspectFile_Handler,
-		},
-		{
-			MethodName: "ListFile",
-			Handler:    _API_ListFile_Handler,
-		},
-		{
-			MethodName: "DeleteFile",
-			Handler:    _API_DeleteFile_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "PutFile",
-			Handler:       _API_PutFile_Handler,
-			ClientStreams: true,
-		},
-		{
-			StreamName:    "GetFile",
-			Handler:       _API_GetFile_Handler,
-			ServerStreams: true,
-		},
-	},
-}
-
-// Client API for InternalAPI service
-
-type InternalAPIClient interface {
-	// Repo rpcs
-	// CreateRepo creates a new repo.
-	// An error is returned if the repo already exists.
-	CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// InspectRepo returns info about a repo.
-	InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error)
-	// ListRepo returns info about all repos.
-	ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error)
-	// DeleteRepo deletes a repo.
-	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// Commit rpcs
-	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// FinishCommit turns a write commit into a read commit.
-	FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// InspectCommit returns the info about a commit.
-	InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error)
-	// ListCommit returns info about all commits.
-	ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
-	// DeleteCommit deletes a commit.
-	DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// File rpcs
-	// PutFile writes the specified file to pfs.
-	PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error)
-	// GetFile returns a byte stream of the contents of the file.
-	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error)
-	// InspectFile returns info about a file.
-	InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
-	// ListFile returns info about all files.
-	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error)
-	// DeleteFile deletes a file.
-	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-}
-
-type internalAPIClient struct {
-	cc *grpc.ClientConn
-}
-
-func NewInternalAPIClient(cc *grpc.ClientConn) InternalAPIClient {
-	return &internalAPIClient{cc}
-}
-
-func (c *internalAPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CreateRepo", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
-	out := new(RepoInfo)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectRepo", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
-	out := new(RepoInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListRepo", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteRepo", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/StartCommit", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FinishCommit", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
-	out := new(CommitInfo)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectCommit", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListCommit", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *internalAPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteCommit", in, out, c.cc, opts...)
+	}
+	out, err := srv.(InternalAPIServer).InspectFile(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *internalAPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[0], c.cc, "/pfs.InternalAPI/PutFile", opts...)
-	if err != nil {
-		return nil, err
+func _InternalAPI_ListFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	x := &internalAPIPutFileClient{stream}
-	return x, nil
+	return srv.(InternalAPIServer).ListFile(m, &internalAPIListFileServer{stream})
 }
 
-type InternalAPI_PutFileClient interface {
-	Send(*PutFileRequest) error
-	CloseAndRecv() (*google_protobuf1.Empty, error)
-	grpc.ClientStream
+type InternalAPI_ListFileServer interface {
+	Send(*FileInfo) error
+	grpc.ServerStream
 }
 
-type internalAPIPutFileClient struct {
-	grpc.ClientStream
+type internalAPIListFileServer struct {
+	grpc.ServerStream
 }
 
-func (x *internalAPIPutFileClient) Send(m *PutFileRequest) error {
-	return x.ClientStream.SendMsg(m)
+func (x *internalAPIListFileServer) Send(m *FileInfo) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-func (x *internalAPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
+func _InternalAPI_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeleteFileRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	m := new(google_protobuf1.Empty)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+	out, err := srv.(InternalAPIServer).DeleteFile(ctx, in)
+	if err != nil {
 		return nil, err
 	}
-	return m, nil
+	return out, nil
 }
 
-func (c *internalAPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[1], c.cc, "/pfs.InternalAPI/GetFile", opts...)
+func _InternalAPI_CopyFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CopyFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).CopyFile(ctx, in)
 	if err != nil {
 		return nil, err
 	}
-	x := &internalAPIGetFileClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
+	return out, nil
+}
+
+func _InternalAPI_InspectLocalShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectLocalShardRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
+	out, err := srv.(InternalAPIServer).InspectLocalShard(ctx, in)
+	if err != nil {
 		return nil, err
 	}
-	return x, nil
-}
-
-type InternalAPI_GetFileClient interface {
-	Recv() (*google_protobuf3.BytesValue, error)
-	grpc.ClientStream
-}
-
-type internalAPIGetFileClient struct {
-	grpc.ClientStream
+	return out, nil
 }
 
-func (x *internalAPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
-	m := new(google_protobuf3.BytesValue)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func _InternalAPI_InspectFileBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectFileRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return m, nil
-}
-
-func (c *internalAPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
-	out := new(FileInfo)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectFile", in, out, c.cc, opts...)
+	out, err := srv.(InternalAPIServer).InspectFileBlocks(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *internalAPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error) {
-	out := new(FileInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListFile", in, out, c.cc, opts...)
+func _InternalAPI_ConcatFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ConcatFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).ConcatFiles(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *internalAPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteFile", in, out, c.cc, opts...)
+func _InternalAPI_StartMultipartPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StartMultipartPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(InternalAPIServer).StartMultipartPut(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for InternalAPI service
+func _InternalAPI_PutPart_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InternalAPIServer).PutPart(&internalAPIPutPartServer{stream})
+}
 
-type InternalAPIServer interface {
-	// Repo rpcs
-	// CreateRepo creates a new repo.
-	// An error is returned if the repo already exists.
-	CreateRepo(context.Context, *CreateRepoRequest) (*google_protobuf1.Empty, error)
-	// InspectRepo returns info about a repo.
-	InspectRepo(context.Context, *InspectRepoRequest) (*RepoInfo, error)
-	// ListRepo returns info about all repos.
-	ListRepo(context.Context, *ListRepoRequest) (*RepoInfos, error)
-	// DeleteRepo deletes a repo.
-	DeleteRepo(context.Context, *DeleteRepoRequest) (*google_protobuf1.Empty, error)
-	// Commit rpcs
-	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(context.Context, *StartCommitRequest) (*google_protobuf1.Empty, error)
-	// FinishCommit turns a write commit into a read commit.
-	FinishCommit(context.Context, *FinishCommitRequest) (*google_protobuf1.Empty, error)
-	// InspectCommit returns the info about a commit.
-	InspectCommit(context.Context, *InspectCommitRequest) (*CommitInfo, error)
-	// ListCommit returns info about all commits.
-	ListCommit(context.Context, *ListCommitRequest) (*CommitInfos, error)
-	// DeleteCommit deletes a commit.
-	DeleteCommit(context.Context, *DeleteCommitRequest) (*google_protobuf1.Empty, error)
-	// File rpcs
-	// PutFile writes the specified file to pfs.
-	PutFile(InternalAPI_PutFileServer) error
-	// GetFile returns a byte stream of the contents of the file.
-	GetFile(*GetFileRequest, InternalAPI_GetFileServer) error
-	// InspectFile returns info about a file.
-	InspectFile(context.Context, *InspectFileRequest) (*FileInfo, error)
-	// ListFile returns info about all files.
-	ListFile(context.Context, *ListFileRequest) (*FileInfos, error)
-	// DeleteFile deletes a file.
-	DeleteFile(context.Context, *DeleteFileRequest) (*google_protobuf1.Empty, error)
+type InternalAPI_PutPartServer interface {
+	SendAndClose(*google_protobuf1.Empty) error
+	Recv() (*PutPartRequest, error)
+	grpc.ServerStream
 }
 
-func RegisterInternalAPIServer(s *grpc.Server, srv InternalAPIServer) {
-	s.RegisterService(&_InternalAPI_serviceDesc, srv)
+type internalAPIPutPartServer struct {
+	grpc.ServerStream
 }
 
-func _InternalAPI_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(CreateRepoRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	out, err := srv.(InternalAPIServer).CreateRepo(ctx, in)
-	if err != nil {
+func (x *internalAPIPutPartServer) SendAndClose(m *google_protobuf1.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *internalAPIPutPartServer) Recv() (*PutPartRequest, error) {
+	m := new(PutPartRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	return out, nil
+	return m, nil
 }
 
-func _InternalAPI_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(InspectRepoRequest)
+func _InternalAPI_CompleteMultipartPut_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(MultipartPut)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).InspectRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).CompleteMultipartPut(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListRepoRequest)
+func _InternalAPI_PrepareTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishTransactionRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).ListRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).PrepareTransaction(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(DeleteRepoRequest)
+func _InternalAPI_MergeCommits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(MergeCommitsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).DeleteRepo(ctx, in)
+	out, err := srv.(InternalAPIServer).MergeCommits(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(StartCommitRequest)
+func _InternalAPI_DedupStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DedupStatsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).StartCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).DedupStats(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(FinishCommitRequest)
+func _InternalAPI_InspectServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).FinishCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).InspectServer(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(InspectCommitRequest)
+func _InternalAPI_ListSlowOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).InspectCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).ListSlowOp(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListCommitRequest)
+func _InternalAPI_ListOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).ListCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).ListOp(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(DeleteCommitRequest)
+func _InternalAPI_CancelOp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CancelOpRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).DeleteCommit(ctx, in)
+	out, err := srv.(InternalAPIServer).CancelOp(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(InternalAPIServer).PutFile(&internalAPIPutFileServer{stream})
-}
-
-type InternalAPI_PutFileServer interface {
-	SendAndClose(*google_protobuf1.Empty) error
-	Recv() (*PutFileRequest, error)
-	grpc.ServerStream
-}
-
-type internalAPIPutFileServer struct {
-	grpc.ServerStream
-}
-
-func (x *internalAPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
-	return x.ServerStream.SendMsg(m)
-}
-
-func (x *internalAPIPutFileServer) Recv() (*PutFileRequest, error) {
-	m := new(PutFileRequest)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
+func _InternalAPI_StatsFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StatsFileRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return m, nil
-}
-
-func _InternalAPI_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(GetFileRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
+	out, err := srv.(InternalAPIServer).StatsFile(ctx, in)
+	if err != nil {
+		return nil, err
 	}
-	return srv.(InternalAPIServer).GetFile(m, &internalAPIGetFileServer{stream})
-}
-
-type InternalAPI_GetFileServer interface {
-	Send(*google_protobuf3.BytesValue) error
-	grpc.ServerStream
-}
-
-type internalAPIGetFileServer struct {
-	grpc.ServerStream
-}
-
-func (x *internalAPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
-	return x.ServerStream.SendMsg(m)
+	return out, nil
 }
 
-func _InternalAPI_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(InspectFileRequest)
+func _InternalAPI_CommitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishTransactionRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).InspectFile(ctx, in)
+	out, err := srv.(InternalAPIServer).CommitTransaction(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_ListFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(ListFileRequest)
+func _InternalAPI_ReleaseTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishTransactionRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).ListFile(ctx, in)
+	out, err := srv.(InternalAPIServer).ReleaseTransaction(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func _InternalAPI_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
-	in := new(DeleteFileRequest)
+func _InternalAPI_AbortTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FinishTransactionRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
-	out, err := srv.(InternalAPIServer).DeleteFile(ctx, in)
+	out, err := srv.(InternalAPIServer).AbortTransaction(ctx, in)
 	if err != nil {
 		return nil, err
 	}
@@ -1688,6 +4691,10 @@ var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteRepo",
 			Handler:    _InternalAPI_DeleteRepo_Handler,
 		},
+		{
+			MethodName: "RestoreRepo",
+			Handler:    _InternalAPI_RestoreRepo_Handler,
+		},
 		{
 			MethodName: "StartCommit",
 			Handler:    _InternalAPI_StartCommit_Handler,
@@ -1704,22 +4711,110 @@ var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ListCommit",
 			Handler:    _InternalAPI_ListCommit_Handler,
 		},
+		{
+			MethodName: "TagCommit",
+			Handler:    _InternalAPI_TagCommit_Handler,
+		},
+		{
+			MethodName: "ListTag",
+			Handler:    _InternalAPI_ListTag_Handler,
+		},
 		{
 			MethodName: "DeleteCommit",
 			Handler:    _InternalAPI_DeleteCommit_Handler,
 		},
 		{
-			MethodName: "InspectFile",
-			Handler:    _InternalAPI_InspectFile_Handler,
+			MethodName: "RestoreCommit",
+			Handler:    _InternalAPI_RestoreCommit_Handler,
 		},
 		{
-			MethodName: "ListFile",
-			Handler:    _InternalAPI_ListFile_Handler,
+			MethodName: "ListTrash",
+			Handler:    _InternalAPI_ListTrash_Handler,
+		},
+		{
+			MethodName: "EmptyTrash",
+			Handler:    _InternalAPI_EmptyTrash_Handler,
+		},
+		{
+			MethodName: "SampleFile",
+			Handler:    _InternalAPI_SampleFile_Handler,
+		},
+		{
+			MethodName: "InspectFile",
+			Handler:    _InternalAPI_InspectFile_Handler,
 		},
 		{
 			MethodName: "DeleteFile",
 			Handler:    _InternalAPI_DeleteFile_Handler,
 		},
+		{
+			MethodName: "CopyFile",
+			Handler:    _InternalAPI_CopyFile_Handler,
+		},
+		{
+			MethodName: "InspectLocalShard",
+			Handler:    _InternalAPI_InspectLocalShard_Handler,
+		},
+		{
+			MethodName: "InspectFileBlocks",
+			Handler:    _InternalAPI_InspectFileBlocks_Handler,
+		},
+		{
+			MethodName: "ConcatFiles",
+			Handler:    _InternalAPI_ConcatFiles_Handler,
+		},
+		{
+			MethodName: "StartMultipartPut",
+			Handler:    _InternalAPI_StartMultipartPut_Handler,
+		},
+		{
+			MethodName: "CompleteMultipartPut",
+			Handler:    _InternalAPI_CompleteMultipartPut_Handler,
+		},
+		{
+			MethodName: "PrepareTransaction",
+			Handler:    _InternalAPI_PrepareTransaction_Handler,
+		},
+		{
+			MethodName: "MergeCommits",
+			Handler:    _InternalAPI_MergeCommits_Handler,
+		},
+		{
+			MethodName: "DedupStats",
+			Handler:    _InternalAPI_DedupStats_Handler,
+		},
+		{
+			MethodName: "InspectServer",
+			Handler:    _InternalAPI_InspectServer_Handler,
+		},
+		{
+			MethodName: "ListSlowOp",
+			Handler:    _InternalAPI_ListSlowOp_Handler,
+		},
+		{
+			MethodName: "ListOp",
+			Handler:    _InternalAPI_ListOp_Handler,
+		},
+		{
+			MethodName: "CancelOp",
+			Handler:    _InternalAPI_CancelOp_Handler,
+		},
+		{
+			MethodName: "StatsFile",
+			Handler:    _InternalAPI_StatsFile_Handler,
+		},
+		{
+			MethodName: "CommitTransaction",
+			Handler:    _InternalAPI_CommitTransaction_Handler,
+		},
+		{
+			MethodName: "ReleaseTransaction",
+			Handler:    _InternalAPI_ReleaseTransaction_Handler,
+		},
+		{
+			MethodName: "AbortTransaction",
+			Handler:    _InternalAPI_AbortTransaction_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -1732,5 +4827,15 @@ var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 			Handler:       _InternalAPI_GetFile_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ListFile",
+			Handler:       _InternalAPI_ListFile_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutPart",
+			Handler:       _InternalAPI_PutPart_Handler,
+			ClientStreams: true,
+		},
 	},
 }
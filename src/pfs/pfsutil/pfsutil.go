@@ -7,15 +7,18 @@ package pfsutil
 import (
 	"io"
 	"math"
+	"time"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"go.pedge.io/google-protobuf"
 	"go.pedge.io/proto/stream"
+	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 )
 
-const chunkSize = 4096
-
 func NewRepo(repoName string) *pfs.Repo {
 	return &pfs.Repo{Name: repoName}
 }
@@ -53,6 +56,21 @@ func CreateRepo(apiClient pfs.APIClient, repoName string) error {
 	return err
 }
 
+// CreateScratchRepo creates a repo which is deleted once ttl has passed
+// since its last commit finished.
+func CreateScratchRepo(apiClient pfs.APIClient, repoName string, ttl time.Duration) error {
+	_, err := apiClient.CreateRepo(
+		context.Background(),
+		&pfs.CreateRepoRequest{
+			Repo: &pfs.Repo{
+				Name: repoName,
+			},
+			Ttl: prototime.DurationToProto(ttl),
+		},
+	)
+	return err
+}
+
 func InspectRepo(apiClient pfs.APIClient, repoName string) (*pfs.RepoInfo, error) {
 	repoInfo, err := apiClient.InspectRepo(
 		context.Background(),
@@ -79,8 +97,25 @@ func ListRepo(apiClient pfs.APIClient) ([]*pfs.RepoInfo, error) {
 	return repoInfos.RepoInfo, nil
 }
 
-func DeleteRepo(apiClient pfs.APIClient, repoName string) error {
-	_, err := apiClient.DeleteRepo(
+// DedupStats returns how much storage the commits of the repos named
+// repoNames share, every repo if there are none.
+func DedupStats(apiClient pfs.APIClient, repoNames ...string) ([]*pfs.RepoDedupStats, error) {
+	request := &pfs.DedupStatsRequest{}
+	for _, repoName := range repoNames {
+		request.Repo = append(request.Repo, &pfs.Repo{Name: repoName})
+	}
+	repoDedupStats, err := apiClient.DedupStats(
+		context.Background(),
+		request,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return repoDedupStats.RepoDedupStats, nil
+}
+
+func DeleteRepo(apiClient pfs.APIClient, repoName string) ([]*pfs.ShardDeletion, error) {
+	shardDeletions, err := apiClient.DeleteRepo(
 		context.Background(),
 		&pfs.DeleteRepoRequest{
 			Repo: &pfs.Repo{
@@ -88,6 +123,56 @@ func DeleteRepo(apiClient pfs.APIClient, repoName string) error {
 			},
 		},
 	)
+	if err != nil {
+		return nil, err
+	}
+	return shardDeletions.ShardDeletion, nil
+}
+
+// ForceDeleteRepo deletes a repo even if pipelines read from or write to it.
+func ForceDeleteRepo(apiClient pfs.APIClient, repoName string) ([]*pfs.ShardDeletion, error) {
+	shardDeletions, err := apiClient.DeleteRepo(
+		context.Background(),
+		&pfs.DeleteRepoRequest{
+			Repo: &pfs.Repo{
+				Name: repoName,
+			},
+			Force: true,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return shardDeletions.ShardDeletion, nil
+}
+
+// PurgeRepo deletes a repo immediately rather than moving it to the trash.
+func PurgeRepo(apiClient pfs.APIClient, repoName string, force bool) ([]*pfs.ShardDeletion, error) {
+	shardDeletions, err := apiClient.DeleteRepo(
+		context.Background(),
+		&pfs.DeleteRepoRequest{
+			Repo: &pfs.Repo{
+				Name: repoName,
+			},
+			Force: force,
+			Purge: true,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return shardDeletions.ShardDeletion, nil
+}
+
+func RestoreRepo(apiClient pfs.APIClient, repoName string) error {
+	_, err := apiClient.RestoreRepo(
+		context.Background(),
+		&pfs.RestoreRepoRequest{
+			Repo: &pfs.Repo{
+				Name: repoName,
+			},
+		},
+	)
 	return err
 }
 
@@ -109,7 +194,120 @@ func StartCommit(apiClient pfs.APIClient, repoName string, parentCommit string)
 	return commit, nil
 }
 
-func FinishCommit(apiClient pfs.APIClient, repoName string, commitID string) error {
+// StartCommitExpectingParent starts a commit which fails with a conflict if
+// parentCommit already has a finished child.
+func StartCommitExpectingParent(apiClient pfs.APIClient, repoName string, parentCommit string) (*pfs.Commit, error) {
+	parent := &pfs.Commit{
+		Repo: &pfs.Repo{
+			Name: repoName,
+		},
+		Id: parentCommit,
+	}
+	return apiClient.StartCommit(
+		context.Background(),
+		&pfs.StartCommitRequest{
+			Parent:         parent,
+			ExpectedParent: parent,
+		},
+	)
+}
+
+// StartCommitInTransaction starts a commit which is finished by
+// FinishTransaction along with the transaction's other commits.
+func StartCommitInTransaction(apiClient pfs.APIClient, repoName string, parentCommit string, transactionID string) (*pfs.Commit, error) {
+	return apiClient.StartCommit(
+		context.Background(),
+		&pfs.StartCommitRequest{
+			Parent: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: parentCommit,
+			},
+			Transaction: &pfs.Transaction{
+				Id: transactionID,
+			},
+		},
+	)
+}
+
+func StartTransaction(apiClient pfs.APIClient) (*pfs.Transaction, error) {
+	return apiClient.StartTransaction(context.Background(), google_protobuf.EmptyInstance)
+}
+
+func FinishTransaction(apiClient pfs.APIClient, transactionID string) error {
+	_, err := apiClient.FinishTransaction(
+		context.Background(),
+		&pfs.FinishTransactionRequest{
+			Transaction: &pfs.Transaction{
+				Id: transactionID,
+			},
+		},
+	)
+	return err
+}
+
+// MergeCommits makes a finished child of ourCommit with the changes
+// theirCommit made since the two diverged and returns it.
+func MergeCommits(apiClient pfs.APIClient, repoName string, ourCommit string, theirCommit string, strategy pfs.MergeStrategy) (*pfs.Commit, error) {
+	return apiClient.MergeCommits(
+		context.Background(),
+		&pfs.MergeCommitsRequest{
+			Ours: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: ourCommit,
+			},
+			Theirs: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: theirCommit,
+			},
+			Strategy: strategy,
+		},
+	)
+}
+
+// ForceFinishCommit finishes a commit without waiting for in-flight reads
+// and writes, the writes will fail.
+func ForceFinishCommit(apiClient pfs.APIClient, repoName string, commitID string) error {
+	_, err := apiClient.FinishCommit(
+		context.Background(),
+		&pfs.FinishCommitRequest{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: commitID,
+			},
+			Force: true,
+		},
+	)
+	return err
+}
+
+// FinishCommit finishes a commit and returns the token which makes reads
+// through any frontend see it finished.
+func FinishCommit(apiClient pfs.APIClient, repoName string, commitID string) (*pfs.ConsistencyToken, error) {
+	return apiClient.FinishCommit(
+		context.Background(),
+		&pfs.FinishCommitRequest{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: commitID,
+			},
+		},
+	)
+}
+
+// FinishCommitExpectingParent finishes a commit unless its parent isn't
+// parentCommit or another child of parentCommit has finished first, which
+// are conflicts.
+func FinishCommitExpectingParent(apiClient pfs.APIClient, repoName string, commitID string, parentCommit string) error {
 	_, err := apiClient.FinishCommit(
 		context.Background(),
 		&pfs.FinishCommitRequest{
@@ -119,6 +317,12 @@ func FinishCommit(apiClient pfs.APIClient, repoName string, commitID string) err
 				},
 				Id: commitID,
 			},
+			ExpectedParent: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: parentCommit,
+			},
 		},
 	)
 	return err
@@ -174,12 +378,81 @@ func DeleteCommit(apiClient pfs.APIClient, repoName string, commitID string) err
 	return err
 }
 
+func TagCommit(apiClient pfs.APIClient, repoName string, commitID string, tag string) error {
+	_, err := apiClient.TagCommit(
+		context.Background(),
+		&pfs.TagCommitRequest{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: commitID,
+			},
+			Tag: tag,
+		},
+	)
+	return err
+}
+
+func ListTag(apiClient pfs.APIClient, repoName string) ([]*pfs.TagInfo, error) {
+	tagInfos, err := apiClient.ListTag(
+		context.Background(),
+		&pfs.ListTagRequest{
+			Repo: &pfs.Repo{
+				Name: repoName,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tagInfos.TagInfo, nil
+}
+
+func RestoreCommit(apiClient pfs.APIClient, repoName string, commitID string) error {
+	_, err := apiClient.RestoreCommit(
+		context.Background(),
+		&pfs.RestoreCommitRequest{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: commitID,
+			},
+		},
+	)
+	return err
+}
+
+func ListTrash(apiClient pfs.APIClient) ([]*pfs.TrashInfo, error) {
+	trashInfos, err := apiClient.ListTrash(
+		context.Background(),
+		&pfs.ListTrashRequest{},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return trashInfos.TrashInfo, nil
+}
+
+// EmptyTrash permanently deletes what's been in the trash longer than the
+// trash window, or everything in it if all is set.
+func EmptyTrash(apiClient pfs.APIClient, all bool) error {
+	_, err := apiClient.EmptyTrash(
+		context.Background(),
+		&pfs.EmptyTrashRequest{
+			All: all,
+		},
+	)
+	return err
+}
+
 func PutBlock(apiClient drive.APIClient, reader io.Reader) (*drive.BlockRefs, error) {
 	putBlockClient, err := apiClient.PutBlock(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	if _, err := io.Copy(protostream.NewStreamingBytesWriter(putBlockClient), reader); err != nil {
+	if err := grpcutil.WriteToStreamingBytesServer(reader, putBlockClient); err != nil {
 		return nil, err
 	}
 	return putBlockClient.CloseAndRecv()
@@ -202,6 +475,73 @@ func GetBlock(apiClient drive.APIClient, hash string, offsetBytes uint64, sizeBy
 	return protostream.NewStreamingBytesReader(apiGetBlockClient), nil
 }
 
+func InspectShard(apiClient pfs.APIClient, shards ...uint64) ([]*pfs.ShardInfo, error) {
+	shardInfos, err := apiClient.InspectShard(
+		context.Background(),
+		&pfs.InspectShardRequest{
+			Shard: shards,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return shardInfos.ShardInfo, nil
+}
+
+func ListSlowOp(apiClient pfs.APIClient) ([]*pfs.SlowOp, error) {
+	slowOps, err := apiClient.ListSlowOp(
+		context.Background(),
+		google_protobuf.EmptyInstance,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return slowOps.SlowOp, nil
+}
+
+func ListOp(apiClient pfs.APIClient) ([]*pfs.Op, error) {
+	ops, err := apiClient.ListOp(
+		context.Background(),
+		google_protobuf.EmptyInstance,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ops.Op, nil
+}
+
+func CancelOp(apiClient pfs.APIClient, address string, id uint64) error {
+	_, err := apiClient.CancelOp(
+		context.Background(),
+		&pfs.CancelOpRequest{
+			Address: address,
+			Id:      id,
+		},
+	)
+	return err
+}
+
+func ListDriveOp(apiClient drive.APIClient) ([]*pfs.Op, error) {
+	ops, err := apiClient.ListOp(
+		context.Background(),
+		google_protobuf.EmptyInstance,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ops.Op, nil
+}
+
+func CancelDriveOp(apiClient drive.APIClient, id uint64) error {
+	_, err := apiClient.CancelOp(
+		context.Background(),
+		&pfs.CancelOpRequest{
+			Id: id,
+		},
+	)
+	return err
+}
+
 func InspectBlock(apiClient drive.APIClient, hash string) (*drive.BlockInfo, error) {
 	blockInfo, err := apiClient.InspectBlock(
 		context.Background(),
@@ -252,25 +592,76 @@ func PutFile(apiClient pfs.APIClient, repoName string, commitID string, path str
 		OffsetBytes: offset,
 	}
 	var size int
+	// Send marshals the request before returning so value can be reused
+	value := make([]byte, grpcutil.StreamingChunkSize)
 	for {
-		value := make([]byte, chunkSize)
 		iSize, err := reader.Read(value)
-		if err != nil {
-			if err == io.EOF {
-				break
+		if iSize > 0 {
+			request.Value = value[0:iSize]
+			size += iSize
+			if err := putFileClient.Send(&request); err != nil {
+				return 0, err
 			}
-			return 0, err
 		}
-		request.Value = value[0:iSize]
-		size += iSize
-		if err := putFileClient.Send(&request); err != nil {
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
 			return 0, err
 		}
 	}
-	if err != nil && err != io.EOF {
-		return 0, err
+	return size, nil
+}
+
+func StartMultipartPut(apiClient pfs.APIClient, repoName string, commitID string, path string) (*pfs.MultipartPut, error) {
+	return apiClient.StartMultipartPut(
+		context.Background(),
+		&pfs.StartMultipartPutRequest{
+			File: NewFile(repoName, commitID, path),
+		},
+	)
+}
+
+// PutPart puts the contents of reader as part number of multipartPut.
+func PutPart(apiClient pfs.APIClient, multipartPut *pfs.MultipartPut, number uint64, reader io.Reader) (retErr error) {
+	putPartClient, err := apiClient.PutPart(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := putPartClient.CloseAndRecv(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	request := pfs.PutPartRequest{
+		MultipartPut: multipartPut,
+		Number:       number,
 	}
-	return size, err
+	// Send marshals the request before returning so value can be reused
+	value := make([]byte, grpcutil.StreamingChunkSize)
+	sent := false
+	for {
+		size, err := reader.Read(value)
+		// an empty part still has to be sent for it to be part of the file
+		if size > 0 || (err == io.EOF && !sent) {
+			request.Value = value[0:size]
+			if err := putPartClient.Send(&request); err != nil {
+				return err
+			}
+			sent = true
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func CompleteMultipartPut(apiClient pfs.APIClient, multipartPut *pfs.MultipartPut) error {
+	_, err := apiClient.CompleteMultipartPut(context.Background(), multipartPut)
+	return err
 }
 
 func GetFile(apiClient pfs.APIClient, repoName string, commitID string, path string, offset int64, size int64, shard *pfs.Shard, writer io.Writer) error {
@@ -303,6 +694,30 @@ func GetFile(apiClient pfs.APIClient, repoName string, commitID string, path str
 	return nil
 }
 
+// GetTar writes a tar of every file beneath path, or of the whole commit if
+// path is empty, to writer.
+func GetTar(apiClient pfs.APIClient, repoName string, commitID string, path string, gzip bool, writer io.Writer) error {
+	apiGetTarClient, err := apiClient.GetTar(
+		context.Background(),
+		&pfs.GetTarRequest{
+			File: &pfs.File{
+				Commit: &pfs.Commit{
+					Repo: &pfs.Repo{
+						Name: repoName,
+					},
+					Id: commitID,
+				},
+				Path: path,
+			},
+			Gzip: gzip,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return protostream.WriteFromStreamingBytesClient(apiGetTarClient, writer)
+}
+
 func InspectFile(apiClient pfs.APIClient, repoName string, commitID string, path string, shard *pfs.Shard) (*pfs.FileInfo, error) {
 	fileInfo, err := apiClient.InspectFile(
 		context.Background(),
@@ -325,10 +740,74 @@ func InspectFile(apiClient pfs.APIClient, repoName string, commitID string, path
 	return fileInfo, nil
 }
 
-func ListFile(apiClient pfs.APIClient, repoName string, commitID string, path string, shard *pfs.Shard) ([]*pfs.FileInfo, error) {
-	fileInfos, err := apiClient.ListFile(
+func SampleFile(apiClient pfs.APIClient, repoName string, commitID string, path string, records uint64, random bool, format pfs.RecordFormat, shard *pfs.Shard) (*pfs.FileSample, error) {
+	fileSample, err := apiClient.SampleFile(
 		context.Background(),
-		&pfs.ListFileRequest{
+		&pfs.SampleFileRequest{
+			File: &pfs.File{
+				Commit: &pfs.Commit{
+					Repo: &pfs.Repo{
+						Name: repoName,
+					},
+					Id: commitID,
+				},
+				Path: path,
+			},
+			Records: records,
+			Random:  random,
+			Format:  format,
+			Shard:   shard,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return fileSample, nil
+}
+
+func StatsFile(apiClient pfs.APIClient, repoName string, commitID string, path string, records uint64, format pfs.RecordFormat) (*pfs.FileStats, error) {
+	return apiClient.StatsFile(
+		context.Background(),
+		&pfs.StatsFileRequest{
+			File: &pfs.File{
+				Commit: &pfs.Commit{
+					Repo: &pfs.Repo{
+						Name: repoName,
+					},
+					Id: commitID,
+				},
+				Path: path,
+			},
+			Records: records,
+			Format:  format,
+		},
+	)
+}
+
+func StatsRepo(apiClient pfs.APIClient, repoName string, commitID string, records uint64, format pfs.RecordFormat) ([]*pfs.FileStats, error) {
+	repoStats, err := apiClient.StatsRepo(
+		context.Background(),
+		&pfs.StatsRepoRequest{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{
+					Name: repoName,
+				},
+				Id: commitID,
+			},
+			Records: records,
+			Format:  format,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return repoStats.FileStats, nil
+}
+
+func InspectFileBlocks(apiClient pfs.APIClient, repoName string, commitID string, path string, shard *pfs.Shard) ([]*pfs.FileBlock, error) {
+	fileBlocks, err := apiClient.InspectFileBlocks(
+		context.Background(),
+		&pfs.InspectFileRequest{
 			File: &pfs.File{
 				Commit: &pfs.Commit{
 					Repo: &pfs.Repo{
@@ -344,7 +823,91 @@ func ListFile(apiClient pfs.APIClient, repoName string, commitID string, path st
 	if err != nil {
 		return nil, err
 	}
-	return fileInfos.FileInfo, nil
+	return fileBlocks.FileBlock, nil
+}
+
+// FilePrefix returns the size of the file at path in the commit and whether
+// its content is a prefix of reader, which is read up to the file's size. It
+// compares the hashes of the file's blocks so the file isn't read. A file
+// which doesn't exist is an empty prefix.
+func FilePrefix(apiClient pfs.APIClient, repoName string, commitID string, path string, reader io.Reader) (int64, bool, error) {
+	fileBlocks, err := InspectFileBlocks(apiClient, repoName, commitID, path, nil)
+	if err != nil {
+		if grpc.ErrorDesc(err) == pfs.ErrFileNotFound.Error() {
+			return 0, true, nil
+		}
+		return 0, false, err
+	}
+	var size int64
+	prefix := true
+	for _, fileBlock := range fileBlocks {
+		blockSize := int64(fileBlock.Upper - fileBlock.Lower)
+		size += blockSize
+		if !prefix {
+			continue
+		}
+		// only a whole block can be checked against its hash
+		if fileBlock.Lower != 0 {
+			prefix = false
+			continue
+		}
+		hash := drive.NewHash()
+		if _, err := io.CopyN(hash, reader, blockSize); err != nil {
+			if err != io.EOF {
+				return 0, false, err
+			}
+			prefix = false
+			continue
+		}
+		prefix = drive.NewBlock(hash).Hash == fileBlock.Hash
+	}
+	return size, prefix, nil
+}
+
+func ListFile(apiClient pfs.APIClient, repoName string, commitID string, path string, shard *pfs.Shard) ([]*pfs.FileInfo, error) {
+	fileInfos, _, err := listFile(apiClient, repoName, commitID, path, shard, false)
+	return fileInfos, err
+}
+
+// PartialListFile lists the files in path on the servers which answer, it
+// only fails if the servers which answer aren't the masters of a majority of
+// the shards. It also returns the servers which failed, whose files are
+// missing from the listing.
+func PartialListFile(apiClient pfs.APIClient, repoName string, commitID string, path string, shard *pfs.Shard) ([]*pfs.FileInfo, []string, error) {
+	return listFile(apiClient, repoName, commitID, path, shard, true)
+}
+
+func listFile(apiClient pfs.APIClient, repoName string, commitID string, path string, shard *pfs.Shard, partial bool) ([]*pfs.FileInfo, []string, error) {
+	listFileClient, err := apiClient.ListFile(
+		context.Background(),
+		&pfs.ListFileRequest{
+			File: &pfs.File{
+				Commit: &pfs.Commit{
+					Repo: &pfs.Repo{
+						Name: repoName,
+					},
+					Id: commitID,
+				},
+				Path: path,
+			},
+			Shard:   shard,
+			Partial: partial,
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fileInfos []*pfs.FileInfo
+	for {
+		fileInfo, err := listFileClient.Recv()
+		if err == io.EOF {
+			return fileInfos, listFileClient.Trailer()[pfs.FailedServersTrailer], nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		fileInfos = append(fileInfos, fileInfo)
+	}
 }
 
 func DeleteFile(apiClient pfs.APIClient, repoName string, commitID string, path string) error {
@@ -365,6 +928,31 @@ func DeleteFile(apiClient pfs.APIClient, repoName string, commitID string, path
 	return err
 }
 
+func CopyFile(apiClient pfs.APIClient, srcRepoName string, srcCommitID string, srcPath string,
+	dstRepoName string, dstCommitID string, dstPath string, recursive bool) error {
+	_, err := apiClient.CopyFile(
+		context.Background(),
+		&pfs.CopyFileRequest{
+			Src:       NewFile(srcRepoName, srcCommitID, srcPath),
+			Dst:       NewFile(dstRepoName, dstCommitID, dstPath),
+			Recursive: recursive,
+		},
+	)
+	return err
+}
+
+// ConcatFiles creates dst from the concatenation of srcs, in order.
+func ConcatFiles(apiClient pfs.APIClient, srcs []*pfs.File, dst *pfs.File) error {
+	_, err := apiClient.ConcatFiles(
+		context.Background(),
+		&pfs.ConcatFilesRequest{
+			Src: srcs,
+			Dst: dst,
+		},
+	)
+	return err
+}
+
 func MakeDirectory(apiClient pfs.APIClient, repoName string, commitID string, path string) (retErr error) {
 	putFileClient, err := apiClient.PutFile(context.Background())
 	if err != nil {
@@ -390,3 +978,20 @@ func MakeDirectory(apiClient pfs.APIClient, repoName string, commitID string, pa
 		},
 	)
 }
+
+// PinVersion pins the frontend's current version, rpcs made with
+// grpc.WithPerRPCCredentials(pfs.NewVersionPinCredentials(pin)) are routed
+// with it until UnpinVersion is called or ttl passes without one being made.
+// A ttl of 0 uses the frontend's default.
+func PinVersion(apiClient pfs.APIClient, ttl time.Duration) (*pfs.VersionPin, error) {
+	request := &pfs.PinVersionRequest{}
+	if ttl != 0 {
+		request.Ttl = prototime.DurationToProto(ttl)
+	}
+	return apiClient.PinVersion(context.Background(), request)
+}
+
+func UnpinVersion(apiClient pfs.APIClient, pin *pfs.VersionPin) error {
+	_, err := apiClient.UnpinVersion(context.Background(), pin)
+	return err
+}
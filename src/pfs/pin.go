@@ -0,0 +1,37 @@
+package pfs
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// versionPinHeader is the rpc header a VersionPin's token is presented in.
+const versionPinHeader = "version-pin"
+
+// NewVersionPinCredentials returns credentials which present pin with every
+// rpc, use them with grpc.WithPerRPCCredentials.
+func NewVersionPinCredentials(pin *VersionPin) credentials.Credentials {
+	return versionPinCredentials(pin.Token)
+}
+
+// VersionPinToken returns the token of the VersionPin the rpc made with ctx
+// presented, ok is false if it didn't present one.
+func VersionPinToken(ctx context.Context) (token string, ok bool) {
+	if md, ok := metadata.FromContext(ctx); ok {
+		if values, ok := md[versionPinHeader]; ok && len(values) > 0 {
+			return values[0], true
+		}
+	}
+	return "", false
+}
+
+type versionPinCredentials string
+
+func (v versionPinCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{versionPinHeader: string(v)}, nil
+}
+
+func (v versionPinCredentials) RequireTransportSecurity() bool {
+	return false
+}
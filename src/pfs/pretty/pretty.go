@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"time"
 
 	"go.pedge.io/proto/time"
@@ -11,10 +12,11 @@ import (
 	"github.com/docker/docker/pkg/units"
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pfs/fuse"
 )
 
 func PrintRepoHeader(w io.Writer) {
-	fmt.Fprint(w, "NAME\tCREATED\tSIZE\t\n")
+	fmt.Fprint(w, "NAME\tCREATED\tSIZE\tCOMMITS\tLAST COMMIT\tLAST COMMIT FINISHED\tEXPIRES\t\n")
 }
 
 func PrintRepoInfo(w io.Writer, repoInfo *pfs.RepoInfo) {
@@ -29,16 +31,64 @@ func PrintRepoInfo(w io.Writer, repoInfo *pfs.RepoInfo) {
 			),
 		),
 	)
-	fmt.Fprintf(w, "%s\t\n", units.BytesSize(float64(repoInfo.SizeBytes)))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(repoInfo.SizeBytes)))
+	fmt.Fprintf(w, "%d\t", repoInfo.CommitCount)
+	if repoInfo.LastCommit != nil {
+		fmt.Fprintf(w, "%s\t", repoInfo.LastCommit.Id)
+		fmt.Fprintf(
+			w,
+			"%s ago\t", units.HumanDuration(
+				time.Since(
+					prototime.TimestampToTime(
+						repoInfo.LastCommitFinished,
+					),
+				),
+			),
+		)
+	} else {
+		fmt.Fprint(w, "<none>\t<none>\t")
+	}
+	if repoInfo.Expires != nil {
+		fmt.Fprintf(
+			w,
+			"in %s\t\n", units.HumanDuration(
+				prototime.TimestampToTime(
+					repoInfo.Expires,
+				).Sub(time.Now()),
+			),
+		)
+	} else {
+		fmt.Fprint(w, "never\t\n")
+	}
+}
+
+func PrintRepoDedupStatsHeader(w io.Writer) {
+	fmt.Fprint(w, "NAME\tCOMMITS\tLOGICAL\tREFERENCED\tPHYSICAL\tDEDUP RATIO\tBLOCK REUSE\t\n")
+}
+
+func PrintRepoDedupStats(w io.Writer, stats *pfs.RepoDedupStats) {
+	fmt.Fprintf(w, "%s\t", stats.Repo.Name)
+	fmt.Fprintf(w, "%d\t", stats.CommitCount)
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(stats.LogicalBytes)))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(stats.ReferencedBytes)))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(stats.PhysicalBytes)))
+	if stats.PhysicalBytes == 0 {
+		fmt.Fprint(w, "-\t-\t\n")
+		return
+	}
+	fmt.Fprintf(w, "%.2fx\t", float64(stats.LogicalBytes)/float64(stats.PhysicalBytes))
+	fmt.Fprintf(w, "%.2fx\t\n", float64(stats.BlockRefs)/float64(stats.UniqueBlockRefs))
 }
 
 func PrintCommitInfoHeader(w io.Writer) {
-	fmt.Fprint(w, "ID\tPARENT\tSTATUS\tSTARTED\tFINISHED\tSIZE\t\n")
+	fmt.Fprint(w, "ID\tPARENT\tSTATUS\tSTARTED\tFINISHED\tSIZE\tADDED\tDELTA\t\n")
 }
 
 func PrintCommitInfo(w io.Writer, commitInfo *pfs.CommitInfo) {
 	fmt.Fprintf(w, "%s\t", commitInfo.Commit.Id)
-	if commitInfo.ParentCommit != nil {
+	if commitInfo.ParentCommit != nil && commitInfo.MergedCommit != nil {
+		fmt.Fprintf(w, "%s+%s\t", commitInfo.ParentCommit.Id, commitInfo.MergedCommit.Id)
+	} else if commitInfo.ParentCommit != nil {
 		fmt.Fprintf(w, "%s\t", commitInfo.ParentCommit.Id)
 	} else {
 		fmt.Fprint(w, "<none>\t")
@@ -69,7 +119,17 @@ func PrintCommitInfo(w io.Writer, commitInfo *pfs.CommitInfo) {
 		))
 	}
 	fmt.Fprintf(w, finished)
-	fmt.Fprintf(w, "%s\t\n", units.BytesSize(float64(commitInfo.SizeBytes)))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(commitInfo.SizeBytes)))
+	if commitInfo.Finished == nil {
+		fmt.Fprint(w, "\t\t\n")
+		return
+	}
+	fmt.Fprintf(w, "%d\t", commitInfo.FilesAdded)
+	if commitInfo.SizeDelta < 0 {
+		fmt.Fprintf(w, "-%s\t\n", units.BytesSize(float64(-commitInfo.SizeDelta)))
+	} else {
+		fmt.Fprintf(w, "+%s\t\n", units.BytesSize(float64(commitInfo.SizeDelta)))
+	}
 }
 
 func PrintFileInfoHeader(w io.Writer) {
@@ -131,6 +191,155 @@ func PrintServerInfo(w io.Writer, serverInfo *pfs.ServerInfo) {
 	fmt.Fprint(w, "\t\n")
 }
 
+func PrintShardInfoHeader(w io.Writer) {
+	fmt.Fprint(w, "SHARD\tMASTER\tREPLICA\tREPO\tLAST COMMIT\tBEHIND\tLAG\t\n")
+}
+
+// PrintShardInfo prints a line for each repo of each replica of the shard.
+func PrintShardInfo(w io.Writer, shardInfo *pfs.ShardInfo) {
+	if len(shardInfo.ReplicaInfo) == 0 {
+		fmt.Fprintf(w, "%d\t%s\t<none>\t\t\t\t\t\n", shardInfo.Shard, shardInfo.MasterAddress)
+		return
+	}
+	for _, replicaInfo := range shardInfo.ReplicaInfo {
+		if replicaInfo.Error != "" {
+			fmt.Fprintf(w, "%d\t%s\t%s\terror: %s\t\t\t\t\n", shardInfo.Shard, shardInfo.MasterAddress, replicaInfo.Address, replicaInfo.Error)
+			continue
+		}
+		for _, replicaLag := range replicaInfo.ReplicaLag {
+			fmt.Fprintf(w, "%d\t", shardInfo.Shard)
+			fmt.Fprintf(w, "%s\t", shardInfo.MasterAddress)
+			fmt.Fprintf(w, "%s\t", replicaInfo.Address)
+			fmt.Fprintf(w, "%s\t", replicaLag.Repo.Name)
+			if replicaLag.LastCommit != nil {
+				fmt.Fprintf(w, "%s\t", replicaLag.LastCommit.Id)
+			} else {
+				fmt.Fprint(w, "<none>\t")
+			}
+			fmt.Fprintf(w, "%d\t", replicaLag.CommitsBehind)
+			fmt.Fprintf(w, "%s\t\n", prototime.DurationFromProto(replicaLag.Lag))
+		}
+	}
+}
+
+func PrintSlowOpHeader(w io.Writer) {
+	fmt.Fprint(w, "SERVER\tMETHOD\tSHARD\tDURATION\tBYTES\tTIME\tERROR\tREQUEST\t\n")
+}
+
+func PrintSlowOp(w io.Writer, slowOp *pfs.SlowOp) {
+	fmt.Fprintf(w, "%s\t", slowOp.Address)
+	fmt.Fprintf(w, "%s\t", slowOp.Method)
+	if slowOp.Shard != nil {
+		fmt.Fprintf(w, "%d\t", slowOp.Shard.Value)
+	} else {
+		fmt.Fprint(w, "-\t")
+	}
+	fmt.Fprintf(w, "%s\t", prototime.DurationFromProto(slowOp.Duration))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(slowOp.Bytes)))
+	fmt.Fprintf(
+		w,
+		"%s ago\t", units.HumanDuration(
+			time.Since(
+				prototime.TimestampToTime(
+					slowOp.Time,
+				),
+			),
+		),
+	)
+	fmt.Fprintf(w, "%s\t", slowOp.Error)
+	fmt.Fprintf(w, "%s\t\n", slowOp.Request)
+}
+
+func PrintFileStatsHeader(w io.Writer) {
+	fmt.Fprint(w, "FILE\tFIELD\tTYPE\tNULLS\tDISTINCT\tMIN\tMAX\tMEAN\tTOP VALUES\t\n")
+}
+
+// PrintFileStats prints a row for each field of fileStats.
+func PrintFileStats(w io.Writer, fileStats *pfs.FileStats) {
+	for _, fieldStats := range fileStats.FieldStats {
+		fmt.Fprintf(w, "%s\t", fileStats.File.Path)
+		fmt.Fprintf(w, "%s\t", fieldStats.Name)
+		fmt.Fprintf(w, "%s\t", strings.ToLower(strings.TrimPrefix(fieldStats.Type.String(), "FIELD_TYPE_")))
+		fmt.Fprintf(w, "%.1f%%\t", 100*fieldStats.NullRate)
+		fmt.Fprintf(w, "%d\t", fieldStats.Distinct)
+		if fieldStats.Type == pfs.FieldType_FIELD_TYPE_INT || fieldStats.Type == pfs.FieldType_FIELD_TYPE_FLOAT {
+			fmt.Fprintf(w, "%g\t%g\t%g\t", fieldStats.Min, fieldStats.Max, fieldStats.Mean)
+		} else {
+			fmt.Fprint(w, "-\t-\t-\t")
+		}
+		var topValues []string
+		for _, valueCount := range fieldStats.TopValue {
+			topValues = append(topValues, fmt.Sprintf("%s (%d)", valueCount.Value, valueCount.Count))
+		}
+		fmt.Fprintf(w, "%s\t\n", strings.Join(topValues, ", "))
+	}
+}
+
+func PrintOpHeader(w io.Writer) {
+	fmt.Fprint(w, "SERVER\tID\tMETHOD\tPEER\tSHARD\tAGE\tREQUEST\t\n")
+}
+
+func PrintOp(w io.Writer, op *pfs.Op) {
+	fmt.Fprintf(w, "%s\t", op.Address)
+	fmt.Fprintf(w, "%d\t", op.Id)
+	fmt.Fprintf(w, "%s\t", op.Method)
+	fmt.Fprintf(w, "%s\t", op.Peer)
+	if op.Shard != nil {
+		fmt.Fprintf(w, "%d\t", op.Shard.Value)
+	} else {
+		fmt.Fprint(w, "-\t")
+	}
+	fmt.Fprintf(w, "%s\t", time.Since(prototime.TimestampToTime(op.Started)))
+	fmt.Fprintf(w, "%s\t\n", op.Request)
+}
+
+func PrintShardDeletionHeader(w io.Writer) {
+	fmt.Fprint(w, "SHARD\tDIFFS DELETED\t\n")
+}
+
+func PrintShardDeletion(w io.Writer, shardDeletion *pfs.ShardDeletion) {
+	fmt.Fprintf(w, "%d\t%d\t\n", shardDeletion.Shard, shardDeletion.DiffsDeleted)
+}
+
+func PrintTagInfoHeader(w io.Writer) {
+	fmt.Fprint(w, "TAG\tCOMMIT\t\n")
+}
+
+func PrintTagInfo(w io.Writer, tagInfo *pfs.TagInfo) {
+	fmt.Fprintf(w, "%s\t%s\t\n", tagInfo.Tag, tagInfo.Commit.Id)
+}
+
+func PrintTrashInfoHeader(w io.Writer) {
+	fmt.Fprint(w, "REPO\tCOMMIT\tDELETED\tEXPIRES\t\n")
+}
+
+func PrintTrashInfo(w io.Writer, trashInfo *pfs.TrashInfo) {
+	fmt.Fprintf(w, "%s\t", trashInfo.Repo.Name)
+	if trashInfo.Commit != nil {
+		fmt.Fprintf(w, "%s\t", trashInfo.Commit.Id)
+	} else {
+		fmt.Fprint(w, "<all>\t")
+	}
+	fmt.Fprintf(
+		w,
+		"%s ago\t", units.HumanDuration(
+			time.Since(
+				prototime.TimestampToTime(
+					trashInfo.Deleted,
+				),
+			),
+		),
+	)
+	fmt.Fprintf(
+		w,
+		"in %s\t\n", units.HumanDuration(
+			prototime.TimestampToTime(
+				trashInfo.Expires,
+			).Sub(time.Now()),
+		),
+	)
+}
+
 func PrintBlockInfoHeader(w io.Writer) {
 	fmt.Fprintf(w, "HASH\tCREATED\tSIZE\t\n")
 }
@@ -150,6 +359,35 @@ func PrintBlockInfo(w io.Writer, blockInfo *drive.BlockInfo) {
 	fmt.Fprintf(w, "%s\t\n", units.BytesSize(float64(blockInfo.SizeBytes)))
 }
 
+func PrintMountInfoHeader(w io.Writer) {
+	fmt.Fprint(w, "MOUNT POINT\tADDRESS\tPID\tSTATUS\tREPOS\t\n")
+}
+
+func PrintMountInfo(w io.Writer, mountInfo *fuse.MountInfo) {
+	fmt.Fprintf(w, "%s\t", mountInfo.MountPoint)
+	fmt.Fprintf(w, "%s\t", mountInfo.Address)
+	fmt.Fprintf(w, "%d\t", mountInfo.Pid)
+	if mountInfo.Stale {
+		fmt.Fprint(w, "stale\t")
+	} else {
+		fmt.Fprint(w, "running\t")
+	}
+	if len(mountInfo.CommitMounts) == 0 {
+		fmt.Fprint(w, "<all>\t\n")
+		return
+	}
+	for i, commitMount := range mountInfo.CommitMounts {
+		fmt.Fprint(w, commitMount.Commit.Repo.Name)
+		if commitMount.Commit.Id != "" {
+			fmt.Fprintf(w, "/%s", commitMount.Commit.Id)
+		}
+		if i != len(mountInfo.CommitMounts)-1 {
+			fmt.Fprint(w, ", ")
+		}
+	}
+	fmt.Fprint(w, "\t\n")
+}
+
 type uint64Slice []uint64
 
 func (s uint64Slice) Len() int           { return len(s) }
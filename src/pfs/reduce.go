@@ -15,6 +15,23 @@ func ReduceRepoInfos(repoInfos []*RepoInfo) []*RepoInfo {
 			continue
 		}
 		reducedRepoInfo.SizeBytes += repoInfo.SizeBytes
+		// commits span all shards so each shard sees all of them
+		if repoInfo.CommitCount > reducedRepoInfo.CommitCount {
+			reducedRepoInfo.CommitCount = repoInfo.CommitCount
+		}
+		if repoInfo.LastCommitFinished != nil && (reducedRepoInfo.LastCommitFinished == nil ||
+			prototime.TimestampToTime(repoInfo.LastCommitFinished).After(prototime.TimestampToTime(reducedRepoInfo.LastCommitFinished))) {
+			reducedRepoInfo.LastCommit = repoInfo.LastCommit
+			reducedRepoInfo.LastCommitFinished = repoInfo.LastCommitFinished
+		}
+		if reducedRepoInfo.Created == nil {
+			reducedRepoInfo.Created = repoInfo.Created
+		}
+		if repoInfo.Expires != nil && (reducedRepoInfo.Expires == nil ||
+			prototime.TimestampToTime(repoInfo.Expires).After(prototime.TimestampToTime(reducedRepoInfo.Expires))) {
+			reducedRepoInfo.Ttl = repoInfo.Ttl
+			reducedRepoInfo.Expires = repoInfo.Expires
+		}
 	}
 	var result []*RepoInfo
 	for _, repoInfo := range reducedRepoInfos {
@@ -24,6 +41,42 @@ func ReduceRepoInfos(repoInfos []*RepoInfo) []*RepoInfo {
 	return result
 }
 
+// ReduceRepoDedupStats merges the dedup stats servers report for their
+// shards of each repo. Block ranges are counted once across all of the
+// servers, the merged stats don't have UniqueBlocks.
+func ReduceRepoDedupStats(repoDedupStats []*RepoDedupStats) []*RepoDedupStats {
+	reducedRepoDedupStats := make(map[string]*RepoDedupStats)
+	uniqueBlocks := make(map[string]map[FileBlock]bool)
+	for _, stats := range repoDedupStats {
+		reducedStats, ok := reducedRepoDedupStats[stats.Repo.Name]
+		if !ok {
+			reducedStats = &RepoDedupStats{Repo: stats.Repo}
+			reducedRepoDedupStats[stats.Repo.Name] = reducedStats
+			uniqueBlocks[stats.Repo.Name] = make(map[FileBlock]bool)
+		}
+		// commits span all shards so each shard sees all of them
+		if stats.CommitCount > reducedStats.CommitCount {
+			reducedStats.CommitCount = stats.CommitCount
+		}
+		reducedStats.LogicalBytes += stats.LogicalBytes
+		reducedStats.ReferencedBytes += stats.ReferencedBytes
+		reducedStats.BlockRefs += stats.BlockRefs
+		for _, fileBlock := range stats.UniqueBlocks {
+			if !uniqueBlocks[stats.Repo.Name][*fileBlock] {
+				uniqueBlocks[stats.Repo.Name][*fileBlock] = true
+				reducedStats.UniqueBlockRefs++
+				reducedStats.PhysicalBytes += fileBlock.Upper - fileBlock.Lower
+			}
+		}
+	}
+	var result []*RepoDedupStats
+	for _, stats := range reducedRepoDedupStats {
+		result = append(result, stats)
+	}
+	sort.Sort(sortRepoDedupStats(result))
+	return result
+}
+
 func ReduceCommitInfos(commitInfos []*CommitInfo) []*CommitInfo {
 	reducedCommitInfos := make(map[string]*CommitInfo)
 	for _, commitInfo := range commitInfos {
@@ -36,6 +89,8 @@ func ReduceCommitInfos(commitInfos []*CommitInfo) []*CommitInfo {
 			reducedCommitInfo.CommitType = CommitType_COMMIT_TYPE_WRITE
 		}
 		reducedCommitInfo.SizeBytes += commitInfo.SizeBytes
+		reducedCommitInfo.FilesAdded += commitInfo.FilesAdded
+		reducedCommitInfo.SizeDelta += commitInfo.SizeDelta
 	}
 	var result []*CommitInfo
 	for _, commitInfo := range reducedCommitInfos {
@@ -82,6 +137,21 @@ func (a sortRepoInfos) Swap(i, j int) {
 	a[j] = tmp
 }
 
+type sortRepoDedupStats []*RepoDedupStats
+
+func (a sortRepoDedupStats) Len() int {
+	return len(a)
+}
+
+func (a sortRepoDedupStats) Less(i, j int) bool {
+	return a[i].Repo.Name < a[j].Repo.Name
+}
+func (a sortRepoDedupStats) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
 type sortCommitInfos []*CommitInfo
 
 func (a sortCommitInfos) Len() int {
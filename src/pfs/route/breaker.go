@@ -0,0 +1,112 @@
+package route
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.pedge.io/protolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// breakerThreshold is how many rpcs in a row have to fail to reach a
+	// server for its breaker to trip.
+	breakerThreshold = 5
+	// breakerProbeInterval is how often a tripped breaker lets an rpc
+	// through to see if the master is back.
+	breakerProbeInterval = 10 * time.Second
+)
+
+// ErrBreakerOpen is returned instead of a connection to a shard's master
+// while the master's breaker is tripped.
+var ErrBreakerOpen = errors.New("route: shard master is unreachable, breaker is open")
+
+type breaker struct {
+	failures int
+	// open is when the breaker tripped or was last probed, it's zero while
+	// the breaker is closed
+	open time.Time
+}
+
+// breakers are the circuit breakers of the servers routed to, by address. A
+// server which is unreachable is unreachable for every shard it's master of,
+// so the shards share its breaker.
+type breakers struct {
+	breakers map[string]*breaker
+	lock     sync.Mutex
+}
+
+func newBreakers() *breakers {
+	return &breakers{breakers: make(map[string]*breaker)}
+}
+
+func (r *router) ReportResult(shard uint64, version int64, err error) {
+	address, ok, lookupErr := r.sharder.GetMasterAddress(shard, version)
+	if lookupErr != nil || !ok {
+		return
+	}
+	r.report(address, err)
+}
+
+func (r *router) report(address string, err error) {
+	r.breakers.lock.Lock()
+	defer r.breakers.lock.Unlock()
+	b, ok := r.breakers.breakers[address]
+	if !ok {
+		b = &breaker{}
+		r.breakers.breakers[address] = b
+	}
+	if !unreachable(err) {
+		if (b.open != time.Time{}) {
+			protolog.Printf("route: %s is reachable again, closing its breaker", address)
+		}
+		delete(r.breakers.breakers, address)
+		return
+	}
+	b.failures++
+	if b.failures == breakerThreshold {
+		protolog.Printf("route: %d rpcs in a row failed to reach %s, opening its breaker: %s", b.failures, address, err.Error())
+		b.open = time.Now()
+	}
+}
+
+// isOpen returns true if address's breaker is open.
+func (r *router) isOpen(address string) bool {
+	r.breakers.lock.Lock()
+	defer r.breakers.lock.Unlock()
+	b, ok := r.breakers.breakers[address]
+	return ok && (b.open != time.Time{})
+}
+
+// allow returns false if address's breaker is open and it isn't time to
+// probe it.
+func (r *router) allow(address string) bool {
+	r.breakers.lock.Lock()
+	defer r.breakers.lock.Unlock()
+	b, ok := r.breakers.breakers[address]
+	if !ok || (b.open == time.Time{}) {
+		return true
+	}
+	if time.Since(b.open) < breakerProbeInterval {
+		return false
+	}
+	// one rpc at a time gets through to probe the server
+	b.open = time.Now()
+	return true
+}
+
+// unreachable returns true if err means an rpc didn't reach the server or
+// the server didn't answer in time, as opposed to the server returning an
+// error.
+func unreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch grpc.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
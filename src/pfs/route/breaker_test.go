@@ -0,0 +1,55 @@
+package route
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// testSharder puts the masters of shards 1 and 2 on the same server.
+type testSharder struct {
+	shard.Sharder
+}
+
+func (s *testSharder) GetMasterAddress(shard uint64, version int64) (string, bool, error) {
+	if shard == 3 {
+		return "other:650", true, nil
+	}
+	return "master:650", true, nil
+}
+
+func (s *testSharder) GetReplicaAddresses(shard uint64, version int64) (map[string]bool, error) {
+	return map[string]bool{"replica:650": true}, nil
+}
+
+func TestBreaker(t *testing.T) {
+	router := newRouter(&testSharder{}, grpcutil.NewDialer(grpc.WithInsecure()), "")
+	unavailable := grpc.Errorf(codes.Unavailable, "connection refused")
+	for i := 0; i < breakerThreshold-1; i++ {
+		router.ReportResult(1, 0, unavailable)
+	}
+	// errors from the server itself don't count
+	router.ReportResult(1, 0, errors.New("file not found"))
+	require.True(t, router.allow("master:650"))
+	// failures to reach any shard count toward the server's breaker
+	for i := 0; i < breakerThreshold; i++ {
+		router.ReportResult(uint64(i%2+1), 0, unavailable)
+	}
+	require.False(t, router.allow("master:650"))
+	require.True(t, router.allow("other:650"))
+	_, err := router.GetMasterClientConn(2, 0)
+	require.Equal(t, ErrBreakerOpen, err)
+	_, master, err := router.GetReadClientConn(2, 0)
+	require.NoError(t, err)
+	require.False(t, master)
+	_, master, err = router.GetReadClientConn(3, 0)
+	require.NoError(t, err)
+	require.True(t, master)
+	router.ReportResult(2, 0, nil)
+	require.True(t, router.allow("master:650"))
+}
@@ -0,0 +1,98 @@
+package route
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/uuid"
+)
+
+// how often WaitForPins checks for pins which have expired
+const pinPollInterval = time.Second
+
+type pin struct {
+	version int64
+	ttl     time.Duration
+	expires time.Time
+}
+
+// pins are the versions pinned through a router, by token.
+type pins struct {
+	pins map[string]*pin
+	lock sync.Mutex
+}
+
+func newPins() *pins {
+	return &pins{pins: make(map[string]*pin)}
+}
+
+func (r *router) PinVersion(version int64, ttl time.Duration) string {
+	token := uuid.NewWithoutDashes()
+	r.pins.lock.Lock()
+	defer r.pins.lock.Unlock()
+	r.pins.pins[token] = &pin{version, ttl, time.Now().Add(ttl)}
+	return token
+}
+
+func (r *router) UnpinVersion(token string) {
+	r.pins.lock.Lock()
+	defer r.pins.lock.Unlock()
+	delete(r.pins.pins, token)
+}
+
+func (r *router) PinnedVersion(token string) (int64, bool) {
+	r.pins.lock.Lock()
+	defer r.pins.lock.Unlock()
+	pin, ok := r.pins.pins[token]
+	if !ok || time.Now().After(pin.expires) {
+		delete(r.pins.pins, token)
+		return 0, false
+	}
+	pin.expires = time.Now().Add(pin.ttl)
+	return pin.version, true
+}
+
+func (r *router) WaitForPins(version int64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for r.pinnedBefore(version) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("pachyderm: unpinned %d pins of versions before %d which were still pinned after %s", r.unpinBefore(version), version, timeout)
+		}
+		time.Sleep(pinPollInterval)
+	}
+	return nil
+}
+
+// unpinBefore drops the pins of versions before version and returns how many
+// there were.
+func (r *router) unpinBefore(version int64) int {
+	r.pins.lock.Lock()
+	defer r.pins.lock.Unlock()
+	result := 0
+	for token, pin := range r.pins.pins {
+		if pin.version < version {
+			delete(r.pins.pins, token)
+			result++
+		}
+	}
+	return result
+}
+
+// pinnedBefore returns true if a version before version is pinned, expired
+// pins are dropped.
+func (r *router) pinnedBefore(version int64) bool {
+	r.pins.lock.Lock()
+	defer r.pins.lock.Unlock()
+	result := false
+	for token, pin := range r.pins.pins {
+		if time.Now().After(pin.expires) {
+			delete(r.pins.pins, token)
+			continue
+		}
+		if pin.version < version {
+			result = true
+		}
+	}
+	return result
+}
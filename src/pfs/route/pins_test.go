@@ -0,0 +1,31 @@
+package route
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestVersionPins(t *testing.T) {
+	router := newRouter(nil, nil, "")
+	token := router.PinVersion(3, time.Minute)
+	version, ok := router.PinnedVersion(token)
+	require.True(t, ok)
+	require.Equal(t, int64(3), version)
+	require.True(t, router.pinnedBefore(4))
+	require.False(t, router.pinnedBefore(3))
+	router.UnpinVersion(token)
+	_, ok = router.PinnedVersion(token)
+	require.False(t, ok)
+	require.False(t, router.pinnedBefore(4))
+	token = router.PinVersion(3, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	_, ok = router.PinnedVersion(token)
+	require.False(t, ok)
+	require.NoError(t, router.WaitForPins(4, time.Minute))
+	token = router.PinVersion(3, time.Minute)
+	require.True(t, router.WaitForPins(4, time.Millisecond) != nil)
+	_, ok = router.PinnedVersion(token)
+	require.False(t, ok)
+}
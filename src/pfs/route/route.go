@@ -1,6 +1,8 @@
 package route
 
 import (
+	"time"
+
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
@@ -8,11 +10,45 @@ import (
 	"google.golang.org/grpc"
 )
 
+const (
+	// ShardKeysKey is the runtime config key for the repos whose files are
+	// sharded by something other than their path, its value is a comma
+	// separated list of repo=function pairs where function is the name of a
+	// ShardKeyFunc, ie "customers=top,events=dir". Files already in a repo
+	// can't be found if its function changes, so set it before writing to
+	// the repo.
+	ShardKeysKey = "repo_shard_keys"
+	// PathShardKey shards files by their path, it's the default.
+	PathShardKey = "path"
+	// TopShardKey shards files by the top level directory they're in, so
+	// "customer1/orders" and "customer1/invoices/2015" are on the same
+	// shard.
+	TopShardKey = "top"
+	// DirShardKey shards files by the directory they're in, so the files in
+	// a directory are on the same shard.
+	DirShardKey = "dir"
+)
+
+// ShardKeyFunc returns the key a file is sharded by from its cleaned path,
+// files with the same key are on the same shard.
+type ShardKeyFunc func(filePath string) string
+
+// RegisterShardKeyFunc makes shardKeyFunc available to ShardKeysKey as name,
+// it has to be registered in every pfsd before the config key refers to it.
+func RegisterShardKeyFunc(name string, shardKeyFunc ShardKeyFunc) {
+	registerShardKeyFunc(name, shardKeyFunc)
+}
+
 type Sharder interface {
 	FileModulus() uint64
 	BlockModulus() uint64
 	GetShard(file *pfs.File) uint64
+	// KeyShard returns the shard of the files whose ShardKeyFunc returns
+	// key.
+	KeyShard(key string) uint64
 	GetBlockShard(block *drive.Block) uint64
+	// SetShardKeys is a config Setter for ShardKeysKey.
+	SetShardKeys(value string) error
 }
 
 func NewSharder(fileModulus uint64, blockModulus uint64) Sharder {
@@ -23,10 +59,42 @@ type Router interface {
 	GetMasterShards(version int64) (map[uint64]bool, error)
 	GetReplicaShards(version int64) (map[uint64]bool, error)
 	GetAllShards(version int64) (map[uint64]bool, error)
+	// GetMasterClientConn returns ErrBreakerOpen while the breaker of
+	// shard's master is open, see ReportResult.
 	GetMasterClientConn(shard uint64, version int64) (*grpc.ClientConn, error)
+	// GetReadClientConn returns a connection to shard's master, or to one of
+	// its replicas whose breaker isn't open while the master's is, master is
+	// false in the second case. Replicas only serve reads of commits they
+	// hold finished.
+	GetReadClientConn(shard uint64, version int64) (clientConn *grpc.ClientConn, master bool, err error)
 	GetMasterOrReplicaClientConn(shard uint64, version int64) (*grpc.ClientConn, error)
 	GetReplicaClientConns(shard uint64, version int64) ([]*grpc.ClientConn, error)
 	GetAllClientConns(version int64) ([]*grpc.ClientConn, error)
+	GetAllAddresses(version int64) (map[string]bool, error)
+	GetShardToMasterAddress(version int64) (map[uint64]string, error)
+	GetShardToReplicaAddresses(version int64) (map[uint64]map[string]bool, error)
+	GetClientConn(address string) (*grpc.ClientConn, error)
+	// AddressesCacheStats returns stats for the sharder's cache of addresses.
+	AddressesCacheStats() *shard.AddressesCacheStats
+	// ReportResult records the result of an rpc made to the master of shard
+	// at version. Breakers are kept by address, after breakerThreshold rpcs
+	// in a row fail to reach a server its breaker opens, rpcs to it fail fast
+	// except for a probe every breakerProbeInterval, and the breaker closes
+	// once one succeeds.
+	ReportResult(shard uint64, version int64, err error)
+	// PinVersion pins version for an operation made of several rpcs and
+	// returns the pin's token. The pin expires once ttl passes without
+	// PinnedVersion being called for it.
+	PinVersion(version int64, ttl time.Duration) string
+	UnpinVersion(token string)
+	// PinnedVersion returns the version token pins, ok is false if it's been
+	// unpinned or has expired.
+	PinnedVersion(token string) (version int64, ok bool)
+	// WaitForPins blocks until no version before version is pinned. If
+	// timeout passes first the pins of earlier versions are unpinned, so the
+	// operations using them fail rather than block the new version, and an
+	// error saying how many there were is returned.
+	WaitForPins(version int64, timeout time.Duration) error
 }
 
 func NewRouter(
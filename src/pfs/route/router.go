@@ -2,6 +2,7 @@ package route
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/pkg/shard"
@@ -12,6 +13,8 @@ type router struct {
 	sharder      shard.Sharder
 	dialer       grpcutil.Dialer
 	localAddress string
+	pins         *pins
+	breakers     *breakers
 }
 
 func newRouter(
@@ -23,6 +26,8 @@ func newRouter(
 		sharder,
 		dialer,
 		localAddress,
+		newPins(),
+		newBreakers(),
 	}
 }
 
@@ -82,16 +87,54 @@ func (r *router) GetAllShards(version int64) (map[uint64]bool, error) {
 }
 
 func (r *router) GetMasterClientConn(shard uint64, version int64) (*grpc.ClientConn, error) {
-	address, ok, err := r.sharder.GetMasterAddress(shard, version)
+	address, err := r.getMasterAddress(shard, version)
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("no master found for %d", shard)
+	if !r.allow(address) {
+		return nil, ErrBreakerOpen
 	}
 	return r.dialer.Dial(address)
 }
 
+func (r *router) GetReadClientConn(shard uint64, version int64) (*grpc.ClientConn, bool, error) {
+	address, err := r.getMasterAddress(shard, version)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.allow(address) {
+		clientConn, err := r.dialer.Dial(address)
+		return clientConn, true, err
+	}
+	addresses, err := r.sharder.GetReplicaAddresses(shard, version)
+	if err != nil {
+		return nil, false, err
+	}
+	var replicas []string
+	for replica := range addresses {
+		if !r.isOpen(replica) {
+			replicas = append(replicas, replica)
+		}
+	}
+	if len(replicas) == 0 {
+		return nil, false, ErrBreakerOpen
+	}
+	sort.Strings(replicas)
+	clientConn, err := r.dialer.Dial(replicas[0])
+	return clientConn, false, err
+}
+
+func (r *router) getMasterAddress(shard uint64, version int64) (string, error) {
+	address, ok, err := r.sharder.GetMasterAddress(shard, version)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("no master found for %d", shard)
+	}
+	return address, nil
+}
+
 func (r *router) GetMasterOrReplicaClientConn(shard uint64, version int64) (*grpc.ClientConn, error) {
 	addresses, err := r.sharder.GetReplicaAddresses(shard, version)
 	if err != nil {
@@ -124,8 +167,15 @@ func (r *router) GetAllClientConns(version int64) ([]*grpc.ClientConn, error) {
 	if err != nil {
 		return nil, err
 	}
-	var clientConns []*grpc.ClientConn
+	// the conns are returned in the same order every time so that writes
+	// which go to each server in turn meet in the same place first
+	var sortedAddresses []string
 	for address := range addresses {
+		sortedAddresses = append(sortedAddresses, address)
+	}
+	sort.Strings(sortedAddresses)
+	var clientConns []*grpc.ClientConn
+	for _, address := range sortedAddresses {
 		// TODO: huge race, this whole thing is bad
 		clientConn, err := r.dialer.Dial(address)
 		if err != nil {
@@ -136,6 +186,26 @@ func (r *router) GetAllClientConns(version int64) ([]*grpc.ClientConn, error) {
 	return clientConns, nil
 }
 
+func (r *router) GetShardToMasterAddress(version int64) (map[uint64]string, error) {
+	return r.sharder.GetShardToMasterAddress(version)
+}
+
+func (r *router) GetShardToReplicaAddresses(version int64) (map[uint64]map[string]bool, error) {
+	return r.sharder.GetShardToReplicaAddresses(version)
+}
+
+func (r *router) GetAllAddresses(version int64) (map[string]bool, error) {
+	return r.getAllAddresses(version)
+}
+
+func (r *router) GetClientConn(address string) (*grpc.ClientConn, error) {
+	return r.dialer.Dial(address)
+}
+
+func (r *router) AddressesCacheStats() *shard.AddressesCacheStats {
+	return r.sharder.AddressesCacheStats()
+}
+
 func (r *router) getAllAddresses(version int64) (map[string]bool, error) {
 	result := make(map[string]bool)
 	shardToMasterAddress, err := r.sharder.GetShardToMasterAddress(version)
@@ -1,22 +1,39 @@
 package route
 
 import (
+	"fmt"
 	"hash/adler32"
 	"path"
+	"strings"
+	"sync"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 )
 
+var (
+	shardKeyFuncs = map[string]ShardKeyFunc{
+		PathShardKey: func(filePath string) string { return filePath },
+		TopShardKey:  topDir,
+		DirShardKey:  path.Dir,
+	}
+	shardKeyFuncsLock sync.RWMutex
+)
+
 type sharder struct {
 	fileModulus  uint64
 	blockModulus uint64
+	// repoShardKeys is the ShardKeyFunc of each repo which doesn't shard by
+	// path, set by SetShardKeys.
+	repoShardKeys map[string]ShardKeyFunc
+	lock          sync.RWMutex
 }
 
 func newSharder(fileModulus uint64, blockModulus uint64) *sharder {
 	return &sharder{
-		fileModulus:  fileModulus,
-		blockModulus: blockModulus,
+		fileModulus:   fileModulus,
+		blockModulus:  blockModulus,
+		repoShardKeys: make(map[string]ShardKeyFunc),
 	}
 }
 
@@ -29,19 +46,70 @@ func (s *sharder) BlockModulus() uint64 {
 }
 
 func (s *sharder) GetShard(file *pfs.File) uint64 {
-	return uint64(adler32.Checksum([]byte(path.Clean(file.Path)))) % s.fileModulus
+	filePath := path.Clean(file.Path)
+	if file.Commit != nil && file.Commit.Repo != nil {
+		s.lock.RLock()
+		shardKeyFunc, ok := s.repoShardKeys[file.Commit.Repo.Name]
+		s.lock.RUnlock()
+		if ok {
+			return s.KeyShard(shardKeyFunc(filePath))
+		}
+	}
+	return s.KeyShard(filePath)
+}
+
+func (s *sharder) KeyShard(key string) uint64 {
+	return uint64(adler32.Checksum([]byte(key))) % s.fileModulus
 }
 
 func (s *sharder) GetBlockShard(block *drive.Block) uint64 {
 	return uint64(adler32.Checksum([]byte(block.Hash))) % s.blockModulus
 }
 
+func (s *sharder) SetShardKeys(value string) error {
+	repoShardKeys := make(map[string]ShardKeyFunc)
+	for _, repoShardKey := range strings.Split(value, ",") {
+		if repoShardKey == "" {
+			continue
+		}
+		split := strings.SplitN(repoShardKey, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("route: invalid %s entry %q, expected repo=function", ShardKeysKey, repoShardKey)
+		}
+		shardKeyFuncsLock.RLock()
+		shardKeyFunc, ok := shardKeyFuncs[split[1]]
+		shardKeyFuncsLock.RUnlock()
+		if !ok {
+			return fmt.Errorf("route: unknown shard key function %s for repo %s", split[1], split[0])
+		}
+		repoShardKeys[split[0]] = shardKeyFunc
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.repoShardKeys = repoShardKeys
+	return nil
+}
+
+// topDir returns the top level directory filePath is in.
+func topDir(filePath string) string {
+	return strings.SplitN(filePath, "/", 2)[0]
+}
+
+func registerShardKeyFunc(name string, shardKeyFunc ShardKeyFunc) {
+	shardKeyFuncsLock.Lock()
+	defer shardKeyFuncsLock.Unlock()
+	shardKeyFuncs[name] = shardKeyFunc
+}
+
 func FileInShard(shard *pfs.Shard, file *pfs.File) bool {
 	if shard == nil {
 		// this lets us default to no filtering
 		return true
 	}
 	sharder := &sharder{fileModulus: shard.FileModulus}
+	if shard.TopDir {
+		return sharder.KeyShard(topDir(path.Clean(file.Path))) == shard.FileNumber
+	}
 	return sharder.GetShard(file) == shard.FileNumber
 }
 
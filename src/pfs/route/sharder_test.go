@@ -0,0 +1,35 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestShardKeys(t *testing.T) {
+	sharder := newSharder(64, 1)
+	file := func(repo string, filePath string) *pfs.File {
+		return &pfs.File{Commit: &pfs.Commit{Repo: &pfs.Repo{Name: repo}, Id: "commit"}, Path: filePath}
+	}
+	require.NoError(t, sharder.SetShardKeys("customers=top,events=dir"))
+	require.Equal(t, sharder.KeyShard("customer1"), sharder.GetShard(file("customers", "customer1/orders")))
+	require.Equal(t, sharder.KeyShard("customer1"), sharder.GetShard(file("customers", "customer1/invoices/2015")))
+	require.Equal(t, sharder.KeyShard("2015/11"), sharder.GetShard(file("events", "2015/11/03")))
+	require.Equal(t, sharder.KeyShard("customer1/orders"), sharder.GetShard(file("other", "customer1/orders")))
+	require.NotNil(t, sharder.SetShardKeys("customers=unknown"))
+	require.NotNil(t, sharder.SetShardKeys("customers"))
+	RegisterShardKeyFunc("first-letter", func(filePath string) string { return filePath[:1] })
+	require.NoError(t, sharder.SetShardKeys("customers=first-letter"))
+	require.Equal(t, sharder.KeyShard("c"), sharder.GetShard(file("customers", "customer1/orders")))
+}
+
+func TestFileInTopDirShard(t *testing.T) {
+	sharder := newSharder(8, 1)
+	file := &pfs.File{Path: "customer1/invoices/2015"}
+	shard := &pfs.Shard{FileNumber: sharder.KeyShard("customer1"), FileModulus: 8, BlockModulus: 1, TopDir: true}
+	require.True(t, FileInShard(shard, file))
+	require.True(t, FileInShard(shard, &pfs.File{Path: "customer1/orders"}))
+	shard.FileNumber = (shard.FileNumber + 1) % 8
+	require.False(t, FileInShard(shard, file))
+}
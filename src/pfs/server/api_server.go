@@ -4,74 +4,137 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/events"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/pkg/shard"
 	"github.com/pachyderm/pachyderm/src/pkg/uuid"
+	"github.com/pachyderm/pachyderm/src/pps"
 	"go.pedge.io/google-protobuf"
 	"go.pedge.io/proto/rpclog"
-	"go.pedge.io/proto/stream"
 	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 )
 
+const (
+	// consistencyTimeout bounds how long a read presenting a
+	// ConsistencyToken waits for this frontend to catch up.
+	consistencyTimeout      = 30 * time.Second
+	consistencyPollInterval = 100 * time.Millisecond
+	// defaultPinTTL is how long a version pin lasts without being used when
+	// PinVersion isn't given a ttl, longer ttls are capped at maxPinTTL.
+	defaultPinTTL = 5 * time.Minute
+	maxPinTTL     = 30 * time.Minute
+	// pinWaitTimeout bounds how long a new version waits for the operations
+	// which pinned older ones, after that their pins are dropped.
+	pinWaitTimeout = 10 * time.Minute
+)
+
 type apiServer struct {
 	protorpclog.Logger
-	sharder route.Sharder
-	router  route.Router
-	version int64
+	sharder       route.Sharder
+	router        route.Router
+	auditRecorder audit.Recorder
+	eventRecorder events.Recorder
+	// pipelineAPIClient is used to refuse to delete repos which pipelines
+	// use, it may be nil.
+	pipelineAPIClient pps.PipelineAPIClient
+	// driveAPIClient is used to read blocks directly for tars, it's nil
+	// unless the servers use the obj driver.
+	driveAPIClient drive.APIClient
+	// trashWindow is how long deleted repos and commits stay in the trash.
+	trashWindow time.Duration
+	version     int64
 	// versionLock protects the version field.
 	// versionLock must be held BEFORE reading from version and UNTIL all
 	// requests using version have returned
 	versionLock sync.RWMutex
+	// shutdown is set by Shutdown, writes tracks in-flight writes so that
+	// Shutdown can wait for them, both are protected by shutdownLock.
+	shutdown     bool
+	writes       sync.WaitGroup
+	shutdownLock sync.RWMutex
 }
 
 func newAPIServer(
 	sharder route.Sharder,
 	router route.Router,
+	auditRecorder audit.Recorder,
+	eventRecorder events.Recorder,
+	pipelineAPIClient pps.PipelineAPIClient,
+	driveAPIClient drive.APIClient,
+	trashWindow time.Duration,
 ) *apiServer {
 	return &apiServer{
 		protorpclog.NewLogger("pachyderm.pfs.API"),
 		sharder,
 		router,
+		auditRecorder,
+		eventRecorder,
+		pipelineAPIClient,
+		driveAPIClient,
+		trashWindow,
 		shard.InvalidVersion,
 		sync.RWMutex{},
+		false,
+		sync.WaitGroup{},
+		sync.RWMutex{},
 	}
 }
 
 func (a *apiServer) CreateRepo(ctx context.Context, request *pfs.CreateRepoRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.CreateRepo", repoName(request.Repo), request, retErr)
+	}(ctx)
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	if strings.Contains(request.Repo.Name, "/") {
-		return nil, fmt.Errorf("repo names cannot contain /")
-	}
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if strings.Contains(request.Repo.Name, "/") {
+		return nil, fmt.Errorf("repo names cannot contain /")
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
 	request.Created = prototime.TimeToTimestamp(time.Now())
-	for _, clientConn := range clientConns {
-		if _, err := pfs.NewInternalAPIClient(clientConn).CreateRepo(ctx, request); err != nil {
-			return nil, err
-		}
+	if err := a.fanOut(version, func(apiClient pfs.InternalAPIClient) error {
+		_, err := apiClient.CreateRepo(ctx, request)
+		return err
+	}); err != nil {
+		return nil, err
 	}
 	return google_protobuf.EmptyInstance, nil
 }
 
 func (a *apiServer) InspectRepo(ctx context.Context, request *pfs.InspectRepoRequest) (response *pfs.RepoInfo, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConn, err := a.getClientConn(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, err := a.getClientConn(version)
 	if err != nil {
 		return nil, err
 	}
@@ -80,43 +143,210 @@ func (a *apiServer) InspectRepo(ctx context.Context, request *pfs.InspectRepoReq
 
 func (a *apiServer) ListRepo(ctx context.Context, request *pfs.ListRepoRequest) (response *pfs.RepoInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var repoInfos []*pfs.RepoInfo
+	var loopErr error
+	for _, clientConn := range clientConns {
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subRepoInfos, err := pfs.NewInternalAPIClient(clientConn).ListRepo(ctx, request)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if loopErr == nil {
+					loopErr = err
+				}
+				return
+			}
+			repoInfos = append(repoInfos, subRepoInfos.RepoInfo...)
+		}(clientConn)
+	}
+	wg.Wait()
+	if loopErr != nil {
+		return nil, loopErr
+	}
+	return &pfs.RepoInfos{
+		RepoInfo: pfs.ReduceRepoInfos(repoInfos),
+	}, nil
+}
+
+func (a *apiServer) DedupStats(ctx context.Context, request *pfs.DedupStatsRequest) (response *pfs.RepoDedupStatsList, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	var lock sync.Mutex
+	var repoDedupStats []*pfs.RepoDedupStats
+	if err := a.fanOut(version, func(apiClient pfs.InternalAPIClient) error {
+		subRepoDedupStats, err := apiClient.DedupStats(ctx, request)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		repoDedupStats = append(repoDedupStats, subRepoDedupStats.RepoDedupStats...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &pfs.RepoDedupStatsList{
+		RepoDedupStats: pfs.ReduceRepoDedupStats(repoDedupStats),
+	}, nil
+}
+
+func (a *apiServer) DeleteRepo(ctx context.Context, request *pfs.DeleteRepoRequest) (response *pfs.ShardDeletions, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.DeleteRepo", repoName(request.Repo), request, retErr)
+	}(ctx)
+	if !request.Force {
+		pipelines, err := a.repoPipelines(request.Repo)
+		if err != nil {
+			return nil, fmt.Errorf("pachyderm: couldn't check whether pipelines use repo %s, use force to delete it anyway: %s", request.Repo.Name, err.Error())
+		}
+		if len(pipelines) > 0 {
+			return nil, fmt.Errorf("pachyderm: repo %s is used by pipelines %s, use force to delete it anyway", request.Repo.Name, strings.Join(pipelines, ", "))
+		}
+	}
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConn, err := a.getClientConn(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
 		return nil, err
 	}
-	return pfs.NewInternalAPIClient(clientConn).ListRepo(ctx, request)
+	if !request.Purge {
+		request.Deleted = prototime.TimeToTimestamp(time.Now())
+		for _, clientConn := range clientConns {
+			if _, err := pfs.NewInternalAPIClient(clientConn).DeleteRepo(ctx, request); err != nil {
+				return nil, err
+			}
+		}
+		return &pfs.ShardDeletions{}, nil
+	}
+	// every server deletes at once so one that fails doesn't stop the others
+	// from getting as far as they can, the master and replicas of a shard
+	// delete the same diffs so their progress is merged
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	shardDeletions := make(map[uint64]*pfs.ShardDeletion)
+	var errs []string
+	for _, clientConn := range clientConns {
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subShardDeletions, err := pfs.NewInternalAPIClient(clientConn).DeleteRepo(ctx, request)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs = append(errs, err.Error())
+				return
+			}
+			for _, shardDeletion := range subShardDeletions.ShardDeletion {
+				mergeShardDeletion(shardDeletions, shardDeletion)
+			}
+		}(clientConn)
+	}
+	wg.Wait()
+	var shards []uint64
+	for shard := range shardDeletions {
+		shards = append(shards, shard)
+	}
+	sort.Sort(uint64Slice(shards))
+	response = &pfs.ShardDeletions{}
+	for _, shard := range shards {
+		shardDeletion := shardDeletions[shard]
+		response.ShardDeletion = append(response.ShardDeletion, shardDeletion)
+		if shardDeletion.Error != "" {
+			errs = append(errs, fmt.Sprintf("shard %d: %s", shard, shardDeletion.Error))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("pachyderm: repo %s was only partially deleted, delete it again to finish: %s", request.Repo.Name, strings.Join(errs, "; "))
+	}
+	return response, nil
 }
 
-func (a *apiServer) DeleteRepo(ctx context.Context, request *pfs.DeleteRepoRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *apiServer) RestoreRepo(ctx context.Context, request *pfs.RestoreRepoRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.RestoreRepo", repoName(request.Repo), request, retErr)
+	}(ctx)
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
 		return nil, err
 	}
 	for _, clientConn := range clientConns {
-		if _, err := pfs.NewInternalAPIClient(clientConn).DeleteRepo(ctx, request); err != nil {
+		if _, err := pfs.NewInternalAPIClient(clientConn).RestoreRepo(ctx, request); err != nil {
 			return nil, err
 		}
 	}
 	return google_protobuf.EmptyInstance, nil
-
 }
 
 func (a *apiServer) StartCommit(ctx context.Context, request *pfs.StartCommitRequest) (response *pfs.Commit, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		repo := commitRepoName(request.Commit)
+		if repo == "" {
+			repo = commitRepoName(request.Parent)
+		}
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.StartCommit", repo, request, retErr)
+	}(ctx)
+	if err := a.startWrite(); err != nil {
+		return nil, err
+	}
+	defer a.writes.Done()
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
 	if request.Commit == nil {
 		if request.Parent == nil {
 			return nil, fmt.Errorf("one of Parent or Commit must be non nil")
@@ -130,38 +360,185 @@ func (a *apiServer) StartCommit(ctx context.Context, request *pfs.StartCommitReq
 		}
 	}
 	request.Started = prototime.TimeToTimestamp(time.Now())
+	if err := a.fanOut(version, func(apiClient pfs.InternalAPIClient) error {
+		_, err := apiClient.StartCommit(ctx, request)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return request.Commit, nil
+}
+
+func (a *apiServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitRequest) (response *pfs.ConsistencyToken, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.FinishCommit", commitRepoName(request.Commit), request, retErr)
+	}(ctx)
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
+	if err != nil {
+		return nil, err
+	}
+	request.Finished = prototime.TimeToTimestamp(time.Now())
+	// the servers are finished in the same order by every call, so of two
+	// commits racing to finish with the same expected parent the one which
+	// loses on the first server never finishes anywhere
 	for _, clientConn := range clientConns {
-		if _, err := pfs.NewInternalAPIClient(clientConn).StartCommit(ctx, request); err != nil {
+		if _, err := pfs.NewInternalAPIClient(clientConn).FinishCommit(ctx, request); err != nil {
 			return nil, err
 		}
 	}
-	return request.Commit, nil
+	a.eventRecorder.CommitFinished(request.Commit, prototime.TimestampToTime(request.Finished))
+	return &pfs.ConsistencyToken{
+		Commit:  request.Commit,
+		Version: version,
+	}, nil
+}
+
+func (a *apiServer) StartTransaction(ctx context.Context, request *google_protobuf.Empty) (response *pfs.Transaction, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	return &pfs.Transaction{Id: uuid.NewWithoutDashes()}, nil
 }
 
-func (a *apiServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *apiServer) FinishTransaction(ctx context.Context, request *pfs.FinishTransactionRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.FinishTransaction", "", request, retErr)
+	}(ctx)
+	if request.Transaction == nil {
+		return nil, fmt.Errorf("request.Transaction cannot be nil")
+	}
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
 		return nil, err
 	}
 	request.Finished = prototime.TimeToTimestamp(time.Now())
+	// the commits are finished in every server's shards but stay unreadable
+	// until every server has prepared them, any server failing to aborts
+	// them everywhere and leaves them open so the transaction can be retried
 	for _, clientConn := range clientConns {
-		if _, err := pfs.NewInternalAPIClient(clientConn).FinishCommit(ctx, request); err != nil {
+		if _, err := pfs.NewInternalAPIClient(clientConn).PrepareTransaction(ctx, request); err != nil {
+			for _, clientConn := range clientConns {
+				if _, abortErr := pfs.NewInternalAPIClient(clientConn).AbortTransaction(ctx, request); abortErr != nil {
+					protolog.Printf("error aborting transaction %s: %s", request.Transaction.Id, abortErr.Error())
+				}
+			}
 			return nil, err
 		}
 	}
+	// once a server has committed the transaction it can't be aborted, if
+	// we fail from here on the commits stay unreadable until a retry of
+	// FinishTransaction, which skips what's been done, finishes it
+	for _, clientConn := range clientConns {
+		if _, err := pfs.NewInternalAPIClient(clientConn).CommitTransaction(ctx, request); err != nil {
+			return nil, fmt.Errorf("pachyderm: transaction %s is committed but not readable, retry finishing it: %s", request.Transaction.Id, err.Error())
+		}
+	}
+	// nothing is readable until every server has committed, every server
+	// has a shard of each commit but a retry only hears of the commits from
+	// servers which hadn't released them yet
+	commits := make(map[string]*pfs.Commit)
+	for _, clientConn := range clientConns {
+		commitInfos, err := pfs.NewInternalAPIClient(clientConn).ReleaseTransaction(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("pachyderm: transaction %s is committed but not readable everywhere, retry finishing it: %s", request.Transaction.Id, err.Error())
+		}
+		for _, commitInfo := range commitInfos.CommitInfo {
+			commits[path.Join(commitInfo.Commit.Repo.Name, commitInfo.Commit.Id)] = commitInfo.Commit
+		}
+	}
+	for _, commit := range commits {
+		a.eventRecorder.CommitFinished(commit, prototime.TimestampToTime(request.Finished))
+	}
 	return google_protobuf.EmptyInstance, nil
 }
 
+func (a *apiServer) MergeCommits(ctx context.Context, request *pfs.MergeCommitsRequest) (response *pfs.Commit, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.MergeCommits", commitRepoName(request.Ours), request, retErr)
+	}(ctx)
+	if request.Ours == nil || request.Theirs == nil {
+		return nil, fmt.Errorf("request.Ours and request.Theirs cannot be nil")
+	}
+	if err := a.startWrite(); err != nil {
+		return nil, err
+	}
+	defer a.writes.Done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	// every server checks for conflicts before any makes the commit, so a
+	// conflict in one shard doesn't leave the commit made in the others
+	checkRequest := &pfs.MergeCommitsRequest{
+		Ours:     request.Ours,
+		Theirs:   request.Theirs,
+		Strategy: request.Strategy,
+	}
+	if err := a.fanOut(version, func(apiClient pfs.InternalAPIClient) error {
+		_, err := apiClient.MergeCommits(ctx, checkRequest)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	request.Commit = &pfs.Commit{
+		Repo: request.Ours.Repo,
+		Id:   uuid.NewWithoutDashes(),
+	}
+	request.Finished = prototime.TimeToTimestamp(time.Now())
+	if err := a.fanOut(version, func(apiClient pfs.InternalAPIClient) error {
+		_, err := apiClient.MergeCommits(ctx, request)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return request.Commit, nil
+}
+
 func (a *apiServer) InspectCommit(ctx context.Context, request *pfs.InspectCommitRequest) (response *pfs.CommitInfo, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.allClientConns); err != nil {
+		return nil, err
+	}
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConn, err := a.getClientConn(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, err := a.getClientConn(version)
 	if err != nil {
 		return nil, err
 	}
@@ -170,10 +547,25 @@ func (a *apiServer) InspectCommit(ctx context.Context, request *pfs.InspectCommi
 
 func (a *apiServer) ListCommit(ctx context.Context, request *pfs.ListCommitRequest) (response *pfs.CommitInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = versionToContext(version, ctx)
+	// blocking calls wait for a commit for as long as the caller wants so they
+	// don't get the rpc timeout, they're cancelled if any of them fail though
+	if !request.Block {
+		var cancel context.CancelFunc
+		ctx, cancel = grpcutil.WithTimeout(ctx)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
 		return nil, err
 	}
@@ -186,14 +578,15 @@ func (a *apiServer) ListCommit(ctx context.Context, request *pfs.ListCommitReque
 		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
 			subCommitInfos, err := pfs.NewInternalAPIClient(clientConn).ListCommit(ctx, request)
+			lock.Lock()
+			defer lock.Unlock()
 			if err != nil {
 				if loopErr == nil {
 					loopErr = err
+					cancel()
 				}
 				return
 			}
-			lock.Lock()
-			defer lock.Unlock()
 			commitInfos = append(commitInfos, subCommitInfos.CommitInfo...)
 		}(clientConn)
 	}
@@ -204,146 +597,140 @@ func (a *apiServer) ListCommit(ctx context.Context, request *pfs.ListCommitReque
 	return &pfs.CommitInfos{CommitInfo: pfs.ReduceCommitInfos(commitInfos)}, nil
 }
 
-func (a *apiServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *apiServer) TagCommit(ctx context.Context, request *pfs.TagCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.TagCommit", commitRepoName(request.Commit), request, retErr)
+	}(ctx)
+	if request.Tag == "" || strings.Contains(request.Tag, "/") || strings.HasPrefix(request.Tag, "@") {
+		return nil, fmt.Errorf("tags must be non-empty, cannot contain / and cannot start with @")
+	}
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
 		return nil, err
 	}
 	for _, clientConn := range clientConns {
-		if _, err := pfs.NewInternalAPIClient(clientConn).DeleteCommit(ctx, request); err != nil {
+		if _, err := pfs.NewInternalAPIClient(clientConn).TagCommit(ctx, request); err != nil {
 			return nil, err
 		}
 	}
 	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *apiServer) PutFile(putFileServer pfs.API_PutFileServer) (retErr error) {
-	var request *pfs.PutFileRequest
-	var err error
-	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
-	ctx := versionToContext(a.version, putFileServer.Context())
-	defer func() {
-		if err := putFileServer.SendAndClose(google_protobuf.EmptyInstance); err != nil && retErr == nil {
-			retErr = err
-		}
-	}()
-	request, err = putFileServer.Recv()
-	if err != nil && err != io.EOF {
-		return err
-	}
-	if err == io.EOF {
-		// tolerate people calling and immediately hanging up
-		return nil
+func (a *apiServer) ListTag(ctx context.Context, request *pfs.ListTagRequest) (response *pfs.TagInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if strings.HasPrefix(request.File.Path, "/") {
-		// This is a subtle error case, the paths foo and /foo will hash to
-		// different shards but will produce the same change once they get to
-		// those shards due to how path.Join. This can go wrong in a number of
-		// ways so we forbid leading slashes.
-		return fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, err := a.getClientConn(version)
+	if err != nil {
+		return nil, err
 	}
-	if request.FileType == pfs.FileType_FILE_TYPE_DIR {
-		if len(request.Value) > 0 {
-			return fmt.Errorf("PutFileRequest shouldn't have type dir and a value")
-		}
-		clientConns, err := a.router.GetAllClientConns(a.version)
-		if err != nil {
-			return err
-		}
-		for _, clientConn := range clientConns {
-			putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
-			if err != nil {
-				return err
-			}
-			if err := putFileClient.Send(request); err != nil {
-				return err
-			}
-			if _, err := putFileClient.CloseAndRecv(); err != nil {
-				return err
-			}
-		}
-		return nil
+	response, err = pfs.NewInternalAPIClient(clientConn).ListTag(ctx, request)
+	if err != nil {
+		return nil, err
 	}
-	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	sort.Sort(sortTagInfos(response.TagInfo))
+	return response, nil
+}
+
+func (a *apiServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.DeleteCommit", commitRepoName(request.Commit), request, retErr)
+	}(ctx)
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() {
-		if _, err := putFileClient.CloseAndRecv(); err != nil && retErr == nil {
-			retErr = err
+	request.Deleted = prototime.TimeToTimestamp(time.Now())
+	for _, clientConn := range clientConns {
+		if _, err := pfs.NewInternalAPIClient(clientConn).DeleteCommit(ctx, request); err != nil {
+			return nil, err
 		}
-	}()
-	if err := putFileClient.Send(request); err != nil {
-		return err
 	}
-	for {
-		request, err := putFileServer.Recv()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		if err := putFileClient.Send(request); err != nil {
-			return err
-		}
-	}
-	return nil
+	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *apiServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.API_GetFileServer) (retErr error) {
-	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
-	ctx := versionToContext(a.version, apiGetFileServer.Context())
-	clientConn, err := a.getClientConnForFile(request.File, a.version)
+func (a *apiServer) RestoreCommit(ctx context.Context, request *pfs.RestoreCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.RestoreCommit", commitRepoName(request.Commit), request, retErr)
+	}(ctx)
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fileGetClient, err := pfs.NewInternalAPIClient(clientConn).GetFile(ctx, request)
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, clientConn := range clientConns {
+		if _, err := pfs.NewInternalAPIClient(clientConn).RestoreCommit(ctx, request); err != nil {
+			return nil, err
+		}
 	}
-	return protostream.RelayFromStreamingBytesClient(fileGetClient, apiGetFileServer)
+	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *apiServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
+func (a *apiServer) ListTrash(ctx context.Context, request *pfs.ListTrashRequest) (response *pfs.TrashInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return pfs.NewInternalAPIClient(clientConn).InspectFile(ctx, request)
-}
-
-func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (response *pfs.FileInfos, retErr error) {
-	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
-	a.versionLock.RLock()
-	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConns, err := a.router.GetAllClientConns(a.version)
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
 	if err != nil {
 		return nil, err
 	}
 	var wg sync.WaitGroup
 	var lock sync.Mutex
-	var fileInfos []*pfs.FileInfo
-	seenDirectories := make(map[string]bool)
+	trashInfos := make(map[string]*pfs.TrashInfo)
 	var loopErr error
 	for _, clientConn := range clientConns {
 		wg.Add(1)
 		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
-			subFileInfos, err := pfs.NewInternalAPIClient(clientConn).ListFile(ctx, request)
+			subTrashInfos, err := pfs.NewInternalAPIClient(clientConn).ListTrash(ctx, request)
 			lock.Lock()
 			defer lock.Unlock()
 			if err != nil {
@@ -352,14 +739,8 @@ func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest)
 				}
 				return
 			}
-			for _, fileInfo := range subFileInfos.FileInfo {
-				if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
-					if seenDirectories[fileInfo.File.Path] {
-						continue
-					}
-					seenDirectories[fileInfo.File.Path] = true
-				}
-				fileInfos = append(fileInfos, fileInfo)
+			for _, trashInfo := range subTrashInfos.TrashInfo {
+				trashInfos[trashKey(trashInfo)] = trashInfo
 			}
 		}(clientConn)
 	}
@@ -367,48 +748,1388 @@ func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest)
 	if loopErr != nil {
 		return nil, loopErr
 	}
-	return &pfs.FileInfos{
-		FileInfo: fileInfos,
-	}, nil
+	response = &pfs.TrashInfos{}
+	for _, trashInfo := range trashInfos {
+		trashInfo.Expires = prototime.TimeToTimestamp(prototime.TimestampToTime(trashInfo.Deleted).Add(a.trashWindow))
+		response.TrashInfo = append(response.TrashInfo, trashInfo)
+	}
+	sort.Sort(sortTrashInfosByDeleted(response.TrashInfo))
+	return response, nil
 }
 
-func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *apiServer) EmptyTrash(ctx context.Context, request *pfs.EmptyTrashRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.EmptyTrash", "", request, retErr)
+	}(ctx)
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
-	ctx = versionToContext(a.version, ctx)
-	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return pfs.NewInternalAPIClient(clientConn).DeleteFile(ctx, request)
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := a.router.GetAllClientConns(version)
+	if err != nil {
+		return nil, err
+	}
+	request.Before = nil
+	if !request.All {
+		request.Before = prototime.TimeToTimestamp(time.Now().Add(-a.trashWindow))
+	}
+	for _, clientConn := range clientConns {
+		if _, err := pfs.NewInternalAPIClient(clientConn).EmptyTrash(ctx, request); err != nil {
+			return nil, err
+		}
+	}
+	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *apiServer) Version(version int64) error {
-	a.versionLock.Lock()
-	defer a.versionLock.Unlock()
-	a.version = version
+func (a *apiServer) PutFile(putFileServer pfs.API_PutFileServer) (retErr error) {
+	var request *pfs.PutFileRequest
+	var err error
+	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(putFileServer.Context(), nil)
+	defer done()
+	defer func() {
+		if request != nil {
+			// the file's contents are left out of the audit log
+			a.auditRecorder.Record(
+				putFileServer.Context(),
+				"pachyderm.pfs.API.PutFile",
+				fileRepoName(request.File),
+				&pfs.PutFileRequest{File: request.File, FileType: request.FileType, OffsetBytes: request.OffsetBytes},
+				retErr,
+			)
+		}
+	}()
+	if err := a.startWrite(); err != nil {
+		return err
+	}
+	defer a.writes.Done()
+	version, err := a.getVersion(putFileServer.Context())
+	if err != nil {
+		return err
+	}
+	ctx = versionToContext(version, ctx)
+	defer func() {
+		if err := putFileServer.SendAndClose(google_protobuf.EmptyInstance); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	request, err = putFileServer.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == io.EOF {
+		// tolerate people calling and immediately hanging up
+		return nil
+	}
+	if strings.HasPrefix(request.File.Path, "/") {
+		// This is a subtle error case, the paths foo and /foo will hash to
+		// different shards but will produce the same change once they get to
+		// those shards due to how path.Join. This can go wrong in a number of
+		// ways so we forbid leading slashes.
+		return fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	}
+	if request.FileType == pfs.FileType_FILE_TYPE_DIR {
+		if len(request.Value) > 0 {
+			return fmt.Errorf("PutFileRequest shouldn't have type dir and a value")
+		}
+		clientConns, err := a.router.GetAllClientConns(version)
+		if err != nil {
+			return err
+		}
+		for _, clientConn := range clientConns {
+			putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
+			if err != nil {
+				return err
+			}
+			if err := putFileClient.Send(request); err != nil {
+				return err
+			}
+			if _, err := putFileClient.CloseAndRecv(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	clientConn, report, err := a.getClientConnForFile(request.File, version)
+	if err != nil {
+		return err
+	}
+	defer func() { report(retErr) }()
+	putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := putFileClient.CloseAndRecv(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	if err := putFileClient.Send(request); err != nil {
+		return err
+	}
+	for {
+		request, err := putFileServer.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if err := putFileClient.Send(request); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (a *apiServer) getClientConn(version int64) (*grpc.ClientConn, error) {
-	shards, err := a.router.GetMasterShards(a.version)
+func (a *apiServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.API_GetFileServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(apiGetFileServer.Context(), request)
+	defer done()
+	defer func() {
+		if repo := fileRepoName(request.File); a.auditRecorder.Sensitive(repo) {
+			a.auditRecorder.Record(apiGetFileServer.Context(), "pachyderm.pfs.API.GetFile", repo, request, retErr)
+		}
+	}()
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.readClientConns(request.File)); err != nil {
+		return err
+	}
+	version, err := a.getVersion(apiGetFileServer.Context())
+	if err != nil {
+		return err
+	}
+	ctx = versionToContext(version, ctx)
+	clientConn, report, err := a.getReadClientConnForFile(request.File, version)
+	if err != nil {
+		return err
+	}
+	defer func() { report(retErr) }()
+	fileGetClient, err := pfs.NewInternalAPIClient(clientConn).GetFile(ctx, request)
+	if err != nil {
+		return err
+	}
+	return grpcutil.RelayFromStreamingBytesClient(fileGetClient, apiGetFileServer)
+}
+
+func (a *apiServer) GetTar(request *pfs.GetTarRequest, apiGetTarServer pfs.API_GetTarServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(apiGetTarServer.Context(), request)
+	defer done()
+	defer func() {
+		if repo := fileRepoName(request.File); a.auditRecorder.Sensitive(repo) {
+			a.auditRecorder.Record(apiGetTarServer.Context(), "pachyderm.pfs.API.GetTar", repo, request, retErr)
+		}
+	}()
+	fileInfos, err := a.listRegularFiles(ctx, request.File, request.ConsistencyToken)
+	if err != nil {
+		return err
+	}
+	// each file is read at the version current when it's reached, holding
+	// one version for the whole tar would hold up rebalancing
+	blocks := func(file *pfs.File) (fileBlocks *pfs.FileBlocks, retErr error) {
+		retErr = a.withReadClientConn(ctx, file, request.ConsistencyToken, func(ctx context.Context, clientConn *grpc.ClientConn) error {
+			var err error
+			fileBlocks, err = pfs.NewInternalAPIClient(clientConn).InspectFileBlocks(ctx, &pfs.InspectFileRequest{File: file})
+			return err
+		})
+		return fileBlocks, retErr
+	}
+	// with the obj driver the blocks are read straight from objd rather
+	// than through the server holding the file
+	read := func(file *pfs.File, fileBlocks *pfs.FileBlocks, writer io.Writer) error {
+		if a.driveAPIClient != nil {
+			for _, fileBlock := range fileBlocks.FileBlock {
+				getBlockClient, err := a.driveAPIClient.GetBlock(ctx, &drive.GetBlockRequest{
+					Block:     &drive.Block{Hash: fileBlock.Hash},
+					SizeBytes: fileBlock.Upper - fileBlock.Lower,
+				})
+				if err != nil {
+					return err
+				}
+				if err := relayBytes(getBlockClient, writer); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return a.withReadClientConn(ctx, file, request.ConsistencyToken, func(ctx context.Context, clientConn *grpc.ClientConn) error {
+			getFileClient, err := pfs.NewInternalAPIClient(clientConn).GetFile(ctx, &pfs.GetFileRequest{
+				File:      file,
+				SizeBytes: int64(blocksSize(fileBlocks)),
+			})
+			if err != nil {
+				return err
+			}
+			return relayBytes(getFileClient, writer)
+		})
+	}
+	return writeTar(grpcutil.NewStreamingBytesWriter(apiGetTarServer), request.Gzip, request.File.Path, fileInfos, blocks, read)
+}
+
+// relayBytes writes the values received from client to writer until it ends.
+func relayBytes(client interface {
+	Recv() (*google_protobuf.BytesValue, error)
+}, writer io.Writer) error {
+	for {
+		bytesValue, err := client.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(bytesValue.Value); err != nil {
+			return err
+		}
+	}
+}
+
+func (a *apiServer) SampleFile(ctx context.Context, request *pfs.SampleFileRequest) (response *pfs.FileSample, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func() {
+		if repo := fileRepoName(request.File); a.auditRecorder.Sensitive(repo) {
+			a.auditRecorder.Record(ctx, "pachyderm.pfs.API.SampleFile", repo, request, retErr)
+		}
+	}()
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.readClientConns(request.File)); err != nil {
+		return nil, err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	for shard := range shards {
-		return a.router.GetMasterClientConn(shard, version)
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, report, err := a.getReadClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
 	}
-	return a.router.GetMasterClientConn(uint64(rand.Int())%a.sharder.FileModulus(), version)
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).SampleFile(ctx, request)
 }
 
-func (a *apiServer) getClientConnForFile(file *pfs.File, version int64) (*grpc.ClientConn, error) {
-	return a.router.GetMasterClientConn(a.sharder.GetShard(file), version)
+func (a *apiServer) StatsFile(ctx context.Context, request *pfs.StatsFileRequest) (response *pfs.FileStats, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func() {
+		if repo := fileRepoName(request.File); a.auditRecorder.Sensitive(repo) {
+			a.auditRecorder.Record(ctx, "pachyderm.pfs.API.StatsFile", repo, request, retErr)
+		}
+	}()
+	return a.statsFile(ctx, request)
 }
 
-func versionToContext(version int64, ctx context.Context) context.Context {
-	return metadata.NewContext(
-		ctx,
-		metadata.Pairs("version", fmt.Sprint(version)),
-	)
+func (a *apiServer) StatsRepo(ctx context.Context, request *pfs.StatsRepoRequest) (response *pfs.RepoStats, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func() {
+		if request.Commit != nil && request.Commit.Repo != nil && a.auditRecorder.Sensitive(request.Commit.Repo.Name) {
+			a.auditRecorder.Record(ctx, "pachyderm.pfs.API.StatsRepo", request.Commit.Repo.Name, request, retErr)
+		}
+	}()
+	fileInfos, err := a.listRegularFiles(ctx, &pfs.File{Commit: request.Commit}, request.ConsistencyToken)
+	if err != nil {
+		return nil, err
+	}
+	result := &pfs.RepoStats{}
+	for _, fileInfo := range fileInfos {
+		fileStats, err := a.statsFile(ctx, &pfs.StatsFileRequest{
+			File:             fileInfo.File,
+			Records:          request.Records,
+			Format:           request.Format,
+			ConsistencyToken: request.ConsistencyToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.FileStats = append(result.FileStats, fileStats)
+	}
+	return result, nil
+}
+
+func (a *apiServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.readClientConns(request.File)); err != nil {
+		return nil, err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, report, err := a.getReadClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).InspectFile(ctx, request)
+}
+
+func (a *apiServer) InspectFileBlocks(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileBlocks, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.readClientConns(request.File)); err != nil {
+		return nil, err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, report, err := a.getReadClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).InspectFileBlocks(ctx, request)
 }
+
+func (a *apiServer) ListFile(request *pfs.ListFileRequest, listFileServer pfs.API_ListFileServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(listFileServer.Context(), request)
+	defer done()
+	failedServers, err := a.listFile(ctx, request, listFileServer.Send)
+	if err != nil {
+		return err
+	}
+	if len(failedServers) > 0 {
+		listFileServer.SetTrailer(metadata.MD{pfs.FailedServersTrailer: failedServers})
+	}
+	return nil
+}
+
+func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.DeleteFile", fileRepoName(request.File), request, retErr)
+	}(ctx)
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, report, err := a.getClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).DeleteFile(ctx, request)
+}
+
+func (a *apiServer) CopyFile(ctx context.Context, request *pfs.CopyFileRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.CopyFile", fileRepoName(request.Dst), request, retErr)
+	}(ctx)
+	if err := a.startWrite(); err != nil {
+		return nil, err
+	}
+	defer a.writes.Done()
+	if strings.HasPrefix(request.Src.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.Src.Path)
+	}
+	if strings.HasPrefix(request.Dst.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.Dst.Path)
+	}
+	// the files are resolved up front so the copies below only go to the
+	// shards which hold the destination paths
+	copies, err := a.resolveCopies(ctx, request.Src, request.Dst, request.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	for _, copyRequest := range copies {
+		clientConn, report, err := a.getClientConnForFile(copyRequest.Dst, version)
+		if err != nil {
+			return nil, err
+		}
+		_, err = pfs.NewInternalAPIClient(clientConn).CopyFile(ctx, copyRequest)
+		report(err)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *apiServer) ConcatFiles(ctx context.Context, request *pfs.ConcatFilesRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.ConcatFiles", fileRepoName(request.Dst), request, retErr)
+	}(ctx)
+	if err := a.startWrite(); err != nil {
+		return nil, err
+	}
+	defer a.writes.Done()
+	if len(request.Src) == 0 {
+		return nil, fmt.Errorf("pachyderm: no files to concatenate")
+	}
+	for _, src := range request.Src {
+		if strings.HasPrefix(src.Path, "/") {
+			return nil, fmt.Errorf("pachyderm: leading slash in path: %s", src.Path)
+		}
+	}
+	if strings.HasPrefix(request.Dst.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.Dst.Path)
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	// the whole file is created by dst's shard, which reads the blocks of
+	// srcs on other shards from storage
+	clientConn, report, err := a.getClientConnForFile(request.Dst, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).ConcatFiles(ctx, request)
+}
+
+func (a *apiServer) StartMultipartPut(ctx context.Context, request *pfs.StartMultipartPutRequest) (response *pfs.MultipartPut, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	if err := a.startWrite(); err != nil {
+		return nil, err
+	}
+	defer a.writes.Done()
+	if strings.HasPrefix(request.File.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	// the put lives on the master of the file's shard, which every part and
+	// the completion go to
+	clientConn, report, err := a.getClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).StartMultipartPut(ctx, request)
+}
+
+func (a *apiServer) PutPart(putPartServer pfs.API_PutPartServer) (retErr error) {
+	var request *pfs.PutPartRequest
+	var err error
+	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(putPartServer.Context(), nil)
+	defer done()
+	if err := a.startWrite(); err != nil {
+		return err
+	}
+	defer a.writes.Done()
+	version, err := a.getVersion(putPartServer.Context())
+	if err != nil {
+		return err
+	}
+	ctx = versionToContext(version, ctx)
+	defer func() {
+		if err := putPartServer.SendAndClose(google_protobuf.EmptyInstance); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	request, err = putPartServer.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == io.EOF {
+		return nil
+	}
+	if request.MultipartPut == nil {
+		return fmt.Errorf("pachyderm: PutPartRequest must have a multipart put")
+	}
+	clientConn, report, err := a.getClientConnForFile(request.MultipartPut.File, version)
+	if err != nil {
+		return err
+	}
+	defer func() { report(retErr) }()
+	putPartClient, err := pfs.NewInternalAPIClient(clientConn).PutPart(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := putPartClient.CloseAndRecv(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	if err := putPartClient.Send(request); err != nil {
+		return err
+	}
+	for {
+		request, err := putPartServer.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if err := putPartClient.Send(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *apiServer) CompleteMultipartPut(ctx context.Context, request *pfs.MultipartPut) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.CompleteMultipartPut", fileRepoName(request.File), request, retErr)
+	}(ctx)
+	if err := a.startWrite(); err != nil {
+		return nil, err
+	}
+	defer a.writes.Done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, report, err := a.getClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).CompleteMultipartPut(ctx, request)
+}
+
+func (a *apiServer) InspectShard(ctx context.Context, request *pfs.InspectShardRequest) (response *pfs.ShardInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	shardToMasterAddress, err := a.router.GetShardToMasterAddress(version)
+	if err != nil {
+		return nil, err
+	}
+	shardToReplicaAddresses, err := a.router.GetShardToReplicaAddresses(version)
+	if err != nil {
+		return nil, err
+	}
+	shards := request.Shard
+	if len(shards) == 0 {
+		for shard := range shardToMasterAddress {
+			shards = append(shards, shard)
+		}
+		sort.Sort(uint64Slice(shards))
+	}
+	response = &pfs.ShardInfos{}
+	for _, shard := range shards {
+		masterAddress, ok := shardToMasterAddress[shard]
+		if !ok {
+			return nil, fmt.Errorf("pachyderm: no master found for shard %d", shard)
+		}
+		masterRepoInfos, err := a.inspectLocalShard(ctx, masterAddress, shard)
+		if err != nil {
+			return nil, err
+		}
+		shardInfo := &pfs.ShardInfo{
+			Shard:         shard,
+			MasterAddress: masterAddress,
+		}
+		var replicaAddresses []string
+		for address := range shardToReplicaAddresses[shard] {
+			replicaAddresses = append(replicaAddresses, address)
+		}
+		sort.Strings(replicaAddresses)
+		for _, address := range replicaAddresses {
+			replicaInfo := &pfs.ReplicaInfo{Address: address}
+			replicaRepoInfos, err := a.inspectLocalShard(ctx, address, shard)
+			if err != nil {
+				// one unreachable replica shouldn't hide the state of the others
+				replicaInfo.Error = err.Error()
+			} else {
+				replicaInfo.ReplicaLag = replicaLags(masterRepoInfos, replicaRepoInfos)
+			}
+			shardInfo.ReplicaInfo = append(shardInfo.ReplicaInfo, replicaInfo)
+		}
+		response.ShardInfo = append(response.ShardInfo, shardInfo)
+	}
+	return response, nil
+}
+
+func (a *apiServer) ListServer(ctx context.Context, request *google_protobuf.Empty) (response *pfs.ServerInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	addresses, err := a.router.GetAllAddresses(version)
+	if err != nil {
+		return nil, err
+	}
+	shardToMasterAddress, err := a.router.GetShardToMasterAddress(version)
+	if err != nil {
+		return nil, err
+	}
+	shardToReplicaAddresses, err := a.router.GetShardToReplicaAddresses(version)
+	if err != nil {
+		return nil, err
+	}
+	var sortedAddresses []string
+	for address := range addresses {
+		sortedAddresses = append(sortedAddresses, address)
+	}
+	sort.Strings(sortedAddresses)
+	response = &pfs.ServerInfos{}
+	for _, address := range sortedAddresses {
+		serverRole := &shard.ServerRole{
+			Address:  address,
+			Version:  version,
+			Masters:  make(map[uint64]bool),
+			Replicas: make(map[uint64]bool),
+		}
+		for shard, masterAddress := range shardToMasterAddress {
+			if masterAddress == address {
+				serverRole.Masters[shard] = true
+			}
+		}
+		for shard, replicaAddresses := range shardToReplicaAddresses {
+			if replicaAddresses[address] {
+				serverRole.Replicas[shard] = true
+			}
+		}
+		serverInfo := &pfs.ServerInfo{
+			Server:      &pfs.Server{Id: address},
+			ServerState: &shard.ServerState{Address: address, Version: version},
+			ServerRole:  map[int64]*shard.ServerRole{version: serverRole},
+		}
+		serverStats, err := a.inspectServer(ctx, address)
+		if err != nil {
+			// one unreachable server shouldn't hide the load on the others
+			serverInfo.Error = err.Error()
+		} else {
+			serverInfo.ServerStats = serverStats
+		}
+		response.ServerInfo = append(response.ServerInfo, serverInfo)
+	}
+	return response, nil
+}
+
+func (a *apiServer) ListSlowOp(ctx context.Context, request *google_protobuf.Empty) (response *pfs.SlowOps, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	addresses, err := a.router.GetAllAddresses(version)
+	if err != nil {
+		return nil, err
+	}
+	response = &pfs.SlowOps{}
+	for address := range addresses {
+		clientConn, err := a.router.GetClientConn(address)
+		if err != nil {
+			return nil, err
+		}
+		slowOps, err := pfs.NewInternalAPIClient(clientConn).ListSlowOp(ctx, google_protobuf.EmptyInstance)
+		if err != nil {
+			return nil, err
+		}
+		for _, slowOp := range slowOps.SlowOp {
+			slowOp.Address = address
+			response.SlowOp = append(response.SlowOp, slowOp)
+		}
+	}
+	sort.Sort(slowOpsByDuration(response.SlowOp))
+	return response, nil
+}
+
+func (a *apiServer) ListOp(ctx context.Context, request *google_protobuf.Empty) (response *pfs.Ops, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	addresses, err := a.router.GetAllAddresses(version)
+	if err != nil {
+		return nil, err
+	}
+	var sortedAddresses []string
+	for address := range addresses {
+		sortedAddresses = append(sortedAddresses, address)
+	}
+	sort.Strings(sortedAddresses)
+	response = &pfs.Ops{}
+	for _, address := range sortedAddresses {
+		clientConn, err := a.router.GetClientConn(address)
+		if err != nil {
+			return nil, err
+		}
+		ops, err := pfs.NewInternalAPIClient(clientConn).ListOp(ctx, google_protobuf.EmptyInstance)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range ops.Op {
+			op.Address = address
+			response.Op = append(response.Op, op)
+		}
+	}
+	return response, nil
+}
+
+func (a *apiServer) CancelOp(ctx context.Context, request *pfs.CancelOpRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	defer func(ctx context.Context) {
+		a.auditRecorder.Record(ctx, "pachyderm.pfs.API.CancelOp", "", request, retErr)
+	}(ctx)
+	clientConn, err := a.router.GetClientConn(request.Address)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewInternalAPIClient(clientConn).CancelOp(ctx, request)
+}
+
+func (a *apiServer) Shutdown() {
+	a.shutdownLock.Lock()
+	a.shutdown = true
+	a.shutdownLock.Unlock()
+	a.writes.Wait()
+}
+
+// startWrite registers an in-flight write, a.writes.Done must be called once
+// the write is finished. It returns ErrShutdown if Shutdown has been called.
+func (a *apiServer) startWrite() error {
+	a.shutdownLock.RLock()
+	defer a.shutdownLock.RUnlock()
+	if a.shutdown {
+		return ErrShutdown
+	}
+	a.writes.Add(1)
+	return nil
+}
+
+func (a *apiServer) Version(version int64) error {
+	a.versionLock.Lock()
+	a.version = version
+	a.versionLock.Unlock()
+	// operations which pinned an older version are still using it
+	if err := a.router.WaitForPins(version, pinWaitTimeout); err != nil {
+		protolog.Printf("%s", err.Error())
+	}
+	return nil
+}
+
+func (a *apiServer) PinVersion(ctx context.Context, request *pfs.PinVersionRequest) (response *pfs.VersionPin, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	ttl := defaultPinTTL
+	if request.Ttl != nil {
+		ttl = prototime.DurationFromProto(request.Ttl)
+	}
+	if ttl <= 0 {
+		ttl = defaultPinTTL
+	}
+	// a client can't hold back new versions indefinitely
+	if ttl > maxPinTTL {
+		ttl = maxPinTTL
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	if a.version == shard.InvalidVersion {
+		return nil, fmt.Errorf("pachyderm: frontend has no version to pin yet")
+	}
+	return &pfs.VersionPin{
+		Token:   a.router.PinVersion(a.version, ttl),
+		Version: a.version,
+	}, nil
+}
+
+func (a *apiServer) UnpinVersion(ctx context.Context, request *pfs.VersionPin) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	a.router.UnpinVersion(request.Token)
+	return google_protobuf.EmptyInstance, nil
+}
+
+// getVersion returns the version to route the rpc made with ctx with, the
+// version it pinned if it presents a pin. versionLock must be held.
+func (a *apiServer) getVersion(ctx context.Context) (int64, error) {
+	token, ok := pfs.VersionPinToken(ctx)
+	if !ok {
+		return a.version, nil
+	}
+	version, ok := a.router.PinnedVersion(token)
+	if !ok {
+		return 0, fmt.Errorf("pachyderm: version pin %s has expired or was unpinned", token)
+	}
+	return version, nil
+}
+
+func (a *apiServer) getClientConn(version int64) (*grpc.ClientConn, error) {
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	for shard := range shards {
+		return a.router.GetMasterClientConn(shard, version)
+	}
+	return a.router.GetMasterClientConn(uint64(rand.Int())%a.sharder.FileModulus(), version)
+}
+
+// getClientConnForFile returns a connection to the master of file's shard,
+// report has to be called with the result of the rpcs made with it so that
+// the master's breaker sees it becoming unreachable.
+func (a *apiServer) getClientConnForFile(file *pfs.File, version int64) (_ *grpc.ClientConn, report func(error), _ error) {
+	shard := a.sharder.GetShard(file)
+	clientConn, err := a.router.GetMasterClientConn(shard, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientConn, func(err error) { a.router.ReportResult(shard, version, err) }, nil
+}
+
+// getReadClientConnForFile is getClientConnForFile for reads, which go to a
+// replica of file's shard while the master's breaker is open. The replica
+// refuses the read unless it holds file's commit finished.
+func (a *apiServer) getReadClientConnForFile(file *pfs.File, version int64) (_ *grpc.ClientConn, report func(error), _ error) {
+	shard := a.sharder.GetShard(file)
+	clientConn, master, err := a.router.GetReadClientConn(shard, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !master {
+		// the master's breaker only hears about rpcs to the master
+		return clientConn, func(error) {}, nil
+	}
+	return clientConn, func(err error) { a.router.ReportResult(shard, version, err) }, nil
+}
+
+// waitForVersion waits until this frontend has caught up to the version in
+// token, it must be called without holding versionLock since Version needs
+// it to make progress.
+func (a *apiServer) waitForVersion(token *pfs.ConsistencyToken) error {
+	if token == nil {
+		return nil
+	}
+	deadline := time.Now().Add(consistencyTimeout)
+	for {
+		a.versionLock.RLock()
+		version := a.version
+		a.versionLock.RUnlock()
+		if version >= token.Version {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("pachyderm: timed out waiting for version %d, frontend is at %d", token.Version, version)
+		}
+		time.Sleep(consistencyPollInterval)
+	}
+}
+
+// waitForToken waits until this frontend has caught up to the version in
+// token and the internal servers getClientConns returns have finished the
+// commit in token. versionLock is only held while checking, not between
+// checks, so waiting doesn't hold up rebalancing; it must be called without
+// holding it.
+func (a *apiServer) waitForToken(ctx context.Context, token *pfs.ConsistencyToken, getClientConns func(version int64) ([]*grpc.ClientConn, error)) error {
+	if token == nil {
+		return nil
+	}
+	if err := a.waitForVersion(token); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(consistencyTimeout)
+	for {
+		finished, err := a.commitFinished(ctx, token.Commit, getClientConns)
+		if err == nil && finished {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("pachyderm: timed out waiting for commit %s/%s to be finished", token.Commit.Repo.Name, token.Commit.Id)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(consistencyPollInterval):
+		}
+	}
+}
+
+// commitFinished returns true if the internal servers getClientConns returns
+// have all finished commit.
+func (a *apiServer) commitFinished(ctx context.Context, commit *pfs.Commit, getClientConns func(version int64) ([]*grpc.ClientConn, error)) (bool, error) {
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConns, err := getClientConns(version)
+	if err != nil {
+		return false, err
+	}
+	for _, clientConn := range clientConns {
+		commitInfo, err := pfs.NewInternalAPIClient(clientConn).InspectCommit(ctx, &pfs.InspectCommitRequest{Commit: commit})
+		if err != nil {
+			return false, err
+		}
+		if commitInfo.CommitType != pfs.CommitType_COMMIT_TYPE_READ {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// allClientConns is a getClientConns for waitForToken which returns every
+// internal server.
+func (a *apiServer) allClientConns(version int64) ([]*grpc.ClientConn, error) {
+	return a.router.GetAllClientConns(version)
+}
+
+// readClientConns returns a getClientConns for waitForToken which returns the
+// internal server reads of file go to.
+func (a *apiServer) readClientConns(file *pfs.File) func(version int64) ([]*grpc.ClientConn, error) {
+	return func(version int64) ([]*grpc.ClientConn, error) {
+		clientConn, _, err := a.router.GetReadClientConn(a.sharder.GetShard(file), version)
+		if err != nil {
+			return nil, err
+		}
+		return []*grpc.ClientConn{clientConn}, nil
+	}
+}
+
+func (a *apiServer) inspectLocalShard(ctx context.Context, address string, shard uint64) (map[string]*pfs.RepoInfo, error) {
+	clientConn, err := a.router.GetClientConn(address)
+	if err != nil {
+		return nil, err
+	}
+	repoInfos, err := pfs.NewInternalAPIClient(clientConn).InspectLocalShard(ctx, &pfs.InspectLocalShardRequest{Shard: shard})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*pfs.RepoInfo)
+	for _, repoInfo := range repoInfos.RepoInfo {
+		result[repoInfo.Repo.Name] = repoInfo
+	}
+	return result, nil
+}
+
+func (a *apiServer) inspectServer(ctx context.Context, address string) (*pfs.ServerStats, error) {
+	clientConn, err := a.router.GetClientConn(address)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewInternalAPIClient(clientConn).InspectServer(ctx, google_protobuf.EmptyInstance)
+}
+
+type slowOpsByDuration []*pfs.SlowOp
+
+func (s slowOpsByDuration) Len() int      { return len(s) }
+func (s slowOpsByDuration) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s slowOpsByDuration) Less(i, j int) bool {
+	return prototime.DurationFromProto(s[i].Duration) > prototime.DurationFromProto(s[j].Duration)
+}
+
+type fileInfosByPath []*pfs.FileInfo
+
+func (f fileInfosByPath) Len() int           { return len(f) }
+func (f fileInfosByPath) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f fileInfosByPath) Less(i, j int) bool { return f[i].File.Path < f[j].File.Path }
+
+// replicaLags compares a replica's repos to its master's, repos the replica
+// is missing entirely are reported with no last commit.
+func replicaLags(master map[string]*pfs.RepoInfo, replica map[string]*pfs.RepoInfo) []*pfs.ReplicaLag {
+	var repoNames []string
+	for repoName := range master {
+		repoNames = append(repoNames, repoName)
+	}
+	sort.Strings(repoNames)
+	var result []*pfs.ReplicaLag
+	for _, repoName := range repoNames {
+		masterRepoInfo := master[repoName]
+		replicaLag := &pfs.ReplicaLag{
+			Repo:          masterRepoInfo.Repo,
+			CommitsBehind: masterRepoInfo.CommitCount,
+		}
+		if replicaRepoInfo, ok := replica[repoName]; ok {
+			replicaLag.LastCommit = replicaRepoInfo.LastCommit
+			replicaLag.LastCommitFinished = replicaRepoInfo.LastCommitFinished
+			replicaLag.CommitsBehind = 0
+			if masterRepoInfo.CommitCount > replicaRepoInfo.CommitCount {
+				replicaLag.CommitsBehind = masterRepoInfo.CommitCount - replicaRepoInfo.CommitCount
+			}
+		}
+		if masterRepoInfo.LastCommitFinished != nil {
+			var lag time.Duration
+			if replicaLag.LastCommitFinished == nil {
+				lag = prototime.TimestampToTime(masterRepoInfo.LastCommitFinished).Sub(prototime.TimestampToTime(masterRepoInfo.Created))
+			} else {
+				lag = prototime.TimestampToTime(masterRepoInfo.LastCommitFinished).Sub(prototime.TimestampToTime(replicaLag.LastCommitFinished))
+			}
+			replicaLag.Lag = prototime.DurationToProto(lag)
+		}
+		result = append(result, replicaLag)
+	}
+	return result
+}
+
+// listFile calls send with each of the files listed by request as the
+// internal servers return them. Regular files are only on one server so
+// they're sent straight away, directories are on every server which has
+// files beneath them so they're merged and sent last. If request.Partial is
+// set the servers which failed are returned, their files are missing.
+func (a *apiServer) listFile(ctx context.Context, request *pfs.ListFileRequest, send func(*pfs.FileInfo) error) ([]string, error) {
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.allClientConns); err != nil {
+		return nil, err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	var lock sync.Mutex
+	var directories []*pfs.FileInfo
+	var failedServers []string
+	listFile := func(apiClient pfs.InternalAPIClient) error {
+		listFileClient, err := apiClient.ListFile(ctx, request)
+		if err != nil {
+			return err
+		}
+		for {
+			fileInfo, err := listFileClient.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				directories = append(directories, fileInfo)
+			} else {
+				err = send(fileInfo)
+			}
+			lock.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if request.Partial {
+		failedServers, err = a.fanOutPartial(version, listFile)
+	} else {
+		err = a.fanOut(version, listFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInfo := range pfs.ReduceFileInfos(directories) {
+		if err := send(fileInfo); err != nil {
+			return nil, err
+		}
+	}
+	return failedServers, nil
+}
+
+// statsFile returns the stats of request.File from the server with its shard.
+func (a *apiServer) statsFile(ctx context.Context, request *pfs.StatsFileRequest) (response *pfs.FileStats, retErr error) {
+	if err := a.waitForToken(ctx, request.ConsistencyToken, a.readClientConns(request.File)); err != nil {
+		return nil, err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := grpcutil.WithTimeout(versionToContext(version, ctx))
+	defer cancel()
+	clientConn, report, err := a.getReadClientConnForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { report(retErr) }()
+	return pfs.NewInternalAPIClient(clientConn).StatsFile(ctx, request)
+}
+
+// withReadClientConn calls f with a client conn to a server which can read
+// file, the version the conn is for is held until f returns.
+func (a *apiServer) withReadClientConn(ctx context.Context, file *pfs.File, consistencyToken *pfs.ConsistencyToken, f func(context.Context, *grpc.ClientConn) error) (retErr error) {
+	if err := a.waitForToken(ctx, consistencyToken, a.readClientConns(file)); err != nil {
+		return err
+	}
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = versionToContext(version, ctx)
+	clientConn, report, err := a.getReadClientConnForFile(file, version)
+	if err != nil {
+		return err
+	}
+	defer func() { report(retErr) }()
+	return f(ctx, clientConn)
+}
+
+// listRegularFiles returns every regular file beneath dir, sorted by path.
+// The files are in dir's commit rather than the commits that last modified
+// them.
+func (a *apiServer) listRegularFiles(ctx context.Context, dir *pfs.File, consistencyToken *pfs.ConsistencyToken) ([]*pfs.FileInfo, error) {
+	var fileInfos []*pfs.FileInfo
+	if _, err := a.listFile(ctx, &pfs.ListFileRequest{File: dir, ConsistencyToken: consistencyToken}, func(fileInfo *pfs.FileInfo) error {
+		fileInfos = append(fileInfos, fileInfo)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var result []*pfs.FileInfo
+	for _, fileInfo := range fileInfos {
+		// children can come back attributed to the commit that added them,
+		// we want their contents as of dir's commit
+		fileInfo.File = &pfs.File{
+			Commit: dir.Commit,
+			Path:   fileInfo.File.Path,
+		}
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			children, err := a.listRegularFiles(ctx, fileInfo.File, consistencyToken)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, children...)
+			continue
+		}
+		result = append(result, fileInfo)
+	}
+	sort.Sort(fileInfosByPath(result))
+	return result, nil
+}
+
+// resolveCopies returns a copy request for each regular file beneath src.
+func (a *apiServer) resolveCopies(ctx context.Context, src *pfs.File, dst *pfs.File, recursive bool) ([]*pfs.CopyFileRequest, error) {
+	var fileInfos []*pfs.FileInfo
+	if _, err := a.listFile(ctx, &pfs.ListFileRequest{File: src}, func(fileInfo *pfs.FileInfo) error {
+		fileInfos = append(fileInfos, fileInfo)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(fileInfos) == 0 {
+		return nil, pfs.ErrFileNotFound
+	}
+	if len(fileInfos) == 1 &&
+		fileInfos[0].FileType == pfs.FileType_FILE_TYPE_REGULAR &&
+		path.Clean(fileInfos[0].File.Path) == path.Clean(src.Path) {
+		return []*pfs.CopyFileRequest{{Src: src, Dst: dst}}, nil
+	}
+	if !recursive {
+		return nil, fmt.Errorf("pachyderm: %s/%s/%s is a directory, copying it must be recursive", src.Commit.Repo.Name, src.Commit.Id, src.Path)
+	}
+	var result []*pfs.CopyFileRequest
+	for _, fileInfo := range fileInfos {
+		// children can come back attributed to the commit that added them,
+		// we want their contents as of src's commit
+		srcChild := &pfs.File{
+			Commit: src.Commit,
+			Path:   fileInfo.File.Path,
+		}
+		child := &pfs.File{
+			Commit: dst.Commit,
+			Path:   path.Join(dst.Path, relativePath(src.Path, fileInfo.File.Path)),
+		}
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			copies, err := a.resolveCopies(ctx, srcChild, child, recursive)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, copies...)
+			continue
+		}
+		result = append(result, &pfs.CopyFileRequest{Src: srcChild, Dst: child})
+	}
+	return result, nil
+}
+
+// repoPipelines returns the names of the pipelines which read from or write
+// to repo, it returns nothing if pfs wasn't given a pipeline client.
+func (a *apiServer) repoPipelines(repo *pfs.Repo) ([]string, error) {
+	if a.pipelineAPIClient == nil {
+		return nil, nil
+	}
+	pipelineInfos, err := a.pipelineAPIClient.ListPipeline(context.Background(), &pps.ListPipelineRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, pipelineInfo := range pipelineInfos.PipelineInfo {
+		uses := pipelineInfo.OutputRepo != nil && pipelineInfo.OutputRepo.Name == repo.Name
+		for _, input := range pipelineInfo.Inputs {
+			if input.Repo != nil && input.Repo.Name == repo.Name {
+				uses = true
+			}
+		}
+		if uses {
+			result = append(result, pipelineInfo.Pipeline.Name)
+		}
+	}
+	return result, nil
+}
+
+// mergeShardDeletion adds shardDeletion to shardDeletions, a shard which is
+// reported more than once keeps the most diffs deleted and any error.
+func mergeShardDeletion(shardDeletions map[uint64]*pfs.ShardDeletion, shardDeletion *pfs.ShardDeletion) {
+	existing, ok := shardDeletions[shardDeletion.Shard]
+	if !ok {
+		shardDeletions[shardDeletion.Shard] = shardDeletion
+		return
+	}
+	if shardDeletion.DiffsDeleted > existing.DiffsDeleted {
+		existing.DiffsDeleted = shardDeletion.DiffsDeleted
+	}
+	if existing.Error == "" {
+		existing.Error = shardDeletion.Error
+	}
+}
+
+// trashKey identifies a repo or commit in the trash.
+func trashKey(trashInfo *pfs.TrashInfo) string {
+	if trashInfo.Commit == nil {
+		return trashInfo.Repo.Name
+	}
+	return path.Join(trashInfo.Repo.Name, trashInfo.Commit.Id)
+}
+
+// relativePath returns the path of child relative to dir.
+func relativePath(dir string, child string) string {
+	dir = path.Clean(dir)
+	if dir == "." {
+		return child
+	}
+	return strings.TrimPrefix(path.Clean(child), dir+"/")
+}
+
+func repoName(repo *pfs.Repo) string {
+	if repo == nil {
+		return ""
+	}
+	return repo.Name
+}
+
+func commitRepoName(commit *pfs.Commit) string {
+	if commit == nil {
+		return ""
+	}
+	return repoName(commit.Repo)
+}
+
+func fileRepoName(file *pfs.File) string {
+	if file == nil {
+		return ""
+	}
+	return commitRepoName(file.Commit)
+}
+
+func versionToContext(version int64, ctx context.Context) context.Context {
+	return metadata.NewContext(
+		ctx,
+		metadata.Pairs("version", fmt.Sprint(version)),
+	)
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+
+type sortTrashInfosByDeleted []*pfs.TrashInfo
+
+func (s sortTrashInfosByDeleted) Len() int      { return len(s) }
+func (s sortTrashInfosByDeleted) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortTrashInfosByDeleted) Less(i, j int) bool {
+	return prototime.TimestampToTime(s[i].Deleted).Before(prototime.TimestampToTime(s[j].Deleted))
+}
+
+type sortTagInfos []*pfs.TagInfo
+
+func (s sortTagInfos) Len() int           { return len(s) }
+func (s sortTagInfos) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortTagInfos) Less(i, j int) bool { return s[i].Tag < s[j].Tag }
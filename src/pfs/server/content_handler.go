@@ -0,0 +1,255 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"go.pedge.io/proto/time"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	// ContentTokenHeader is the header requests to the content handler
+	// carry its token in, as "Bearer " followed by the token.
+	ContentTokenHeader = "Authorization"
+	// ContentPrincipalHeader is the header requests to the content handler
+	// report who's making them in, reads of sensitive repos are audited as
+	// that principal, or as the request's address if it's not set.
+	ContentPrincipalHeader = "Pachyderm-Principal"
+)
+
+// contentHandler serves the contents of files over plain HTTP at
+// /repos/{repo}/commits/{commit}/files/{path} so that browsers and notebooks
+// can read pfs directly.
+type contentHandler struct {
+	apiClient     pfs.APIClient
+	auditRecorder audit.Recorder
+	token         string
+	// the origins which may make cross origin requests, "*" allows any
+	corsOrigins map[string]bool
+}
+
+func newContentHandler(apiClient pfs.APIClient, auditRecorder audit.Recorder, token string, corsOrigins []string) *contentHandler {
+	handler := &contentHandler{
+		apiClient,
+		auditRecorder,
+		token,
+		make(map[string]bool),
+	}
+	for _, origin := range corsOrigins {
+		handler.corsOrigins[origin] = true
+	}
+	return handler
+}
+
+func (h *contentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w, r)
+	switch r.Method {
+	case "GET", "HEAD":
+	case "OPTIONS":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	// an empty token would let in requests with "Bearer "
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(ContentTokenHeader)), []byte("Bearer "+h.token)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	file, err := parseContentPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	// the reads below are made by pfsd's own client, so they're audited as
+	// pfsd, this records who they're really for
+	if repo := file.Commit.Repo.Name; h.auditRecorder.Sensitive(repo) {
+		defer func() {
+			h.auditRecorder.Record(audit.NewContext(context.Background(), contentPrincipal(r)), "pachyderm.pfs.Content.GetFile", repo, &pfs.GetFileRequest{File: file}, err)
+		}()
+	}
+	fileInfo, err := pfsutil.InspectFile(h.apiClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, nil)
+	if err != nil {
+		http.Error(w, grpc.ErrorDesc(err), contentErrorStatus(err))
+		return
+	}
+	if fileInfo == nil {
+		err = pfs.ErrFileNotFound
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		err = fmt.Errorf("%s is a directory", file.Path)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fileBlocks, err := pfsutil.InspectFileBlocks(h.apiClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, nil)
+	if err != nil {
+		http.Error(w, grpc.ErrorDesc(err), contentErrorStatus(err))
+		return
+	}
+	w.Header().Set("ETag", etag(fileBlocks))
+	// commit ids can be tags or times which resolve to different commits
+	// over time, so clients have to revalidate, the ETag makes that cheap
+	w.Header().Set("Cache-Control", "no-cache")
+	if mime.TypeByExtension(path.Ext(file.Path)) == "" {
+		// stops ServeContent reading the start of the file to sniff a type
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	var modified time.Time
+	if fileInfo.Modified != nil {
+		modified = prototime.TimestampToTime(fileInfo.Modified)
+	}
+	reader := &contentReader{
+		apiClient: h.apiClient,
+		file:      file,
+		size:      int64(fileInfo.SizeBytes),
+	}
+	defer reader.Close()
+	http.ServeContent(w, r, path.Base(file.Path), modified, reader)
+}
+
+func (h *contentHandler) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+	if !h.corsOrigins["*"] && !h.corsOrigins[origin] {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Expose-Headers", "Accept-Ranges, Content-Length, Content-Range, ETag")
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Pachyderm-Principal, Range, If-Match, If-None-Match, If-Modified-Since, If-Range")
+		w.Header().Set("Access-Control-Max-Age", "600")
+	}
+}
+
+// contentPrincipal returns who r says it's from, or its address if it doesn't
+// say.
+func contentPrincipal(r *http.Request) string {
+	if principal := r.Header.Get(ContentPrincipalHeader); principal != "" {
+		return principal
+	}
+	return r.RemoteAddr
+}
+
+// parseContentPath parses a path of the form
+// /repos/{repo}/commits/{commit}/files/{path}.
+func parseContentPath(urlPath string) (*pfs.File, error) {
+	parts := strings.SplitN(strings.TrimPrefix(urlPath, "/"), "/", 6)
+	if len(parts) != 6 || parts[0] != "repos" || parts[2] != "commits" || parts[4] != "files" ||
+		parts[1] == "" || parts[3] == "" || parts[5] == "" {
+		return nil, fmt.Errorf("%s isn't of the form /repos/{repo}/commits/{commit}/files/{path}", urlPath)
+	}
+	return &pfs.File{
+		Commit: &pfs.Commit{
+			Repo: &pfs.Repo{Name: parts[1]},
+			Id:   parts[3],
+		},
+		Path: parts[5],
+	}, nil
+}
+
+func contentErrorStatus(err error) int {
+	if grpc.ErrorDesc(err) == pfs.ErrFileNotFound.Error() {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// etag identifies the content of a file by the blocks it's made of, blocks
+// are content addressed so files with the same blocks have the same content.
+func etag(fileBlocks []*pfs.FileBlock) string {
+	hash := sha256.New()
+	for _, fileBlock := range fileBlocks {
+		fmt.Fprintf(hash, "%s %d %d\n", fileBlock.Hash, fileBlock.Lower, fileBlock.Upper)
+	}
+	return fmt.Sprintf("%q", hex.EncodeToString(hash.Sum(nil)))
+}
+
+// contentReader is an io.ReadSeeker over a file in pfs, which is what
+// http.ServeContent needs to serve ranges. Reads after a Seek which moved the
+// offset start a new GetFile from there.
+type contentReader struct {
+	apiClient pfs.APIClient
+	file      *pfs.File
+	size      int64
+	offset    int64
+	reader    *io.PipeReader
+}
+
+func (r *contentReader) Read(data []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.reader == nil {
+		reader, writer := io.Pipe()
+		offset := r.offset
+		go func() {
+			writer.CloseWithError(pfsutil.GetFile(
+				r.apiClient,
+				r.file.Commit.Repo.Name,
+				r.file.Commit.Id,
+				r.file.Path,
+				offset,
+				r.size-offset,
+				nil,
+				writer,
+			))
+		}()
+		r.reader = reader
+	}
+	n, err := r.reader.Read(data)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *contentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset += r.offset
+	case os.SEEK_END:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+	if offset != r.offset {
+		if err := r.Close(); err != nil {
+			return 0, err
+		}
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *contentReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	err := r.reader.Close()
+	r.reader = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"go.pedge.io/protolog"
+	"google.golang.org/grpc"
+)
+
+// maxFanOutParallelism is the most internal servers a frontend rpc calls at
+// once.
+const maxFanOutParallelism = 16
+
+// fanOutError is returned when some of the servers a request was fanned out
+// to failed, it has the error from each of them.
+type fanOutError struct {
+	servers int
+	errs    map[string]error // by address
+}
+
+func (e *fanOutError) Error() string {
+	var addresses []string
+	for address := range e.errs {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	var errs []string
+	for _, address := range addresses {
+		errs = append(errs, fmt.Sprintf("%s: %s", address, grpc.ErrorDesc(e.errs[address])))
+	}
+	return fmt.Sprintf("pachyderm: %d of %d servers failed: %s", len(e.errs), e.servers, strings.Join(errs, "; "))
+}
+
+// fanOut calls f with a client for each of the internal servers at version,
+// at most maxFanOutParallelism at a time, and waits for all of them so that
+// every failure is reported rather than just the first.
+func (a *apiServer) fanOut(version int64, f func(apiClient pfs.InternalAPIClient) error) error {
+	addresses, err := a.router.GetAllAddresses(version)
+	if err != nil {
+		return err
+	}
+	errs := a.callAll(addresses, f)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &fanOutError{len(addresses), errs}
+}
+
+// fanOutPartial is fanOut for reads which can make do with some of the
+// servers, it succeeds as long as the servers which answered are the masters
+// of a majority of the shards. The servers which failed are returned so the
+// caller can say its results are partial.
+func (a *apiServer) fanOutPartial(version int64, f func(apiClient pfs.InternalAPIClient) error) ([]string, error) {
+	addresses, err := a.router.GetAllAddresses(version)
+	if err != nil {
+		return nil, err
+	}
+	errs := a.callAll(addresses, f)
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	err = &fanOutError{len(addresses), errs}
+	shardToMasterAddress, shardErr := a.router.GetShardToMasterAddress(version)
+	if shardErr != nil {
+		return nil, err
+	}
+	covered := 0
+	for _, address := range shardToMasterAddress {
+		if _, ok := errs[address]; !ok {
+			covered++
+		}
+	}
+	if 2*covered <= len(shardToMasterAddress) {
+		return nil, err
+	}
+	protolog.Printf("returning partial results for %d of %d shards, %s", covered, len(shardToMasterAddress), err.Error())
+	var failed []string
+	for address := range errs {
+		failed = append(failed, address)
+	}
+	sort.Strings(failed)
+	return failed, nil
+}
+
+// callAll calls f with a client for each of addresses, at most
+// maxFanOutParallelism at a time, and returns the errors by address.
+func (a *apiServer) callAll(addresses map[string]bool, f func(apiClient pfs.InternalAPIClient) error) map[string]error {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	errs := make(map[string]error)
+	semaphore := make(chan bool, maxFanOutParallelism)
+	for address := range addresses {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			semaphore <- true
+			defer func() { <-semaphore }()
+			clientConn, err := a.router.GetClientConn(address)
+			if err == nil {
+				err = f(pfs.NewInternalAPIClient(clientConn))
+			}
+			if err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				errs[address] = err
+			}
+		}(address)
+	}
+	wg.Wait()
+	return errs
+}
@@ -3,27 +3,33 @@ package server
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"go.pedge.io/google-protobuf"
-	"go.pedge.io/proto/rpclog"
-	"go.pedge.io/proto/stream"
+	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/metadata"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	pkgshard "github.com/pachyderm/pachyderm/src/pkg/shard"
 )
 
 type internalAPIServer struct {
-	protorpclog.Logger
+	*slowOpLogger
 	sharder           route.Sharder
 	router            route.Router
 	driver            drive.Driver
+	localShards       *localShards
+	multipartPuts     *multipartPuts
+	statsCache        *statsCache
 	commitWaiters     []*commitWait
 	commitWaitersLock sync.Mutex
 }
@@ -32,12 +38,16 @@ func newInternalAPIServer(
 	sharder route.Sharder,
 	router route.Router,
 	driver drive.Driver,
+	localShardsPath string,
 ) *internalAPIServer {
 	return &internalAPIServer{
-		Logger:            protorpclog.NewLogger("pachyderm.pfs.InternalAPI"),
+		slowOpLogger:      newSlowOpLogger("pachyderm.pfs.InternalAPI", sharder),
 		sharder:           sharder,
 		router:            router,
 		driver:            driver,
+		localShards:       newLocalShards(localShardsPath),
+		multipartPuts:     newMultipartPuts(),
+		statsCache:        newStatsCache(statsCacheSize),
 		commitWaiters:     nil,
 		commitWaitersLock: sync.Mutex{},
 	}
@@ -45,6 +55,8 @@ func newInternalAPIServer(
 
 func (a *internalAPIServer) CreateRepo(ctx context.Context, request *pfs.CreateRepoRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -53,7 +65,7 @@ func (a *internalAPIServer) CreateRepo(ctx context.Context, request *pfs.CreateR
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.CreateRepo(request.Repo, request.Created, shards); err != nil {
+	if err := a.driver.CreateRepo(request.Repo, request.Created, request.Ttl, shards); err != nil {
 		return nil, err
 	}
 	return google_protobuf.EmptyInstance, nil
@@ -61,6 +73,8 @@ func (a *internalAPIServer) CreateRepo(ctx context.Context, request *pfs.CreateR
 
 func (a *internalAPIServer) InspectRepo(ctx context.Context, request *pfs.InspectRepoRequest) (response *pfs.RepoInfo, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -74,11 +88,15 @@ func (a *internalAPIServer) InspectRepo(ctx context.Context, request *pfs.Inspec
 
 func (a *internalAPIServer) ListRepo(ctx context.Context, request *pfs.ListRepoRequest) (response *pfs.RepoInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	shards, err := a.router.GetAllShards(version)
+	// only master shards so the frontend can merge results without double
+	// counting replicas
+	shards, err := a.router.GetMasterShards(version)
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +104,30 @@ func (a *internalAPIServer) ListRepo(ctx context.Context, request *pfs.ListRepoR
 	return &pfs.RepoInfos{RepoInfo: repoInfos}, err
 }
 
-func (a *internalAPIServer) DeleteRepo(ctx context.Context, request *pfs.DeleteRepoRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *internalAPIServer) DedupStats(ctx context.Context, request *pfs.DedupStatsRequest) (response *pfs.RepoDedupStatsList, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// only master shards, like ListRepo
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	repoDedupStats, err := a.driver.DedupStats(request.Repo, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.RepoDedupStatsList{RepoDedupStats: repoDedupStats}, nil
+}
+
+func (a *internalAPIServer) DeleteRepo(ctx context.Context, request *pfs.DeleteRepoRequest) (response *pfs.ShardDeletions, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -96,15 +136,41 @@ func (a *internalAPIServer) DeleteRepo(ctx context.Context, request *pfs.DeleteR
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.DeleteRepo(request.Repo, shards); err != nil {
+	if !request.Purge {
+		if err := a.driver.TrashRepo(request.Repo, request.Deleted, shards); err != nil {
+			return nil, err
+		}
+		return &pfs.ShardDeletions{}, nil
+	}
+	shardDeletions, err := a.driver.DeleteRepo(request.Repo, shards)
+	if err != nil {
 		return nil, err
 	}
-	return google_protobuf.EmptyInstance, nil
+	return &pfs.ShardDeletions{ShardDeletion: shardDeletions}, nil
+}
 
+func (a *internalAPIServer) RestoreRepo(ctx context.Context, request *pfs.RestoreRepoRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetAllShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.RestoreRepo(request.Repo, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
 }
 
 func (a *internalAPIServer) StartCommit(ctx context.Context, request *pfs.StartCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -113,7 +179,11 @@ func (a *internalAPIServer) StartCommit(ctx context.Context, request *pfs.StartC
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.StartCommit(request.Parent, request.Commit, request.Started, shards); err != nil {
+	var transaction string
+	if request.Transaction != nil {
+		transaction = request.Transaction.Id
+	}
+	if err := a.driver.StartCommit(request.Parent, request.Commit, request.Started, transaction, request.ExpectedParent, shards); err != nil {
 		return nil, err
 	}
 	if err := a.pulseCommitWaiters(request.Commit, pfs.CommitType_COMMIT_TYPE_WRITE, shards); err != nil {
@@ -124,6 +194,8 @@ func (a *internalAPIServer) StartCommit(ctx context.Context, request *pfs.StartC
 
 func (a *internalAPIServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -132,7 +204,7 @@ func (a *internalAPIServer) FinishCommit(ctx context.Context, request *pfs.Finis
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.FinishCommit(request.Commit, request.Finished, shards); err != nil {
+	if err := a.driver.FinishCommit(request.Commit, request.Finished, request.Force, request.ExpectedParent, shards); err != nil {
 		return nil, err
 	}
 	if err := a.pulseCommitWaiters(request.Commit, pfs.CommitType_COMMIT_TYPE_READ, shards); err != nil {
@@ -141,8 +213,116 @@ func (a *internalAPIServer) FinishCommit(ctx context.Context, request *pfs.Finis
 	return google_protobuf.EmptyInstance, nil
 }
 
+func (a *internalAPIServer) PrepareTransaction(ctx context.Context, request *pfs.FinishTransactionRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.PrepareTransaction(request.Transaction.Id, request.Finished, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) CommitTransaction(ctx context.Context, request *pfs.FinishTransactionRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.CommitTransaction(request.Transaction.Id, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) ReleaseTransaction(ctx context.Context, request *pfs.FinishTransactionRequest) (response *pfs.CommitInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := a.driver.ReleaseTransaction(request.Transaction.Id, shards)
+	if err != nil {
+		return nil, err
+	}
+	result := &pfs.CommitInfos{}
+	for _, commit := range commits {
+		if err := a.pulseCommitWaiters(commit, pfs.CommitType_COMMIT_TYPE_READ, shards); err != nil {
+			return nil, err
+		}
+		result.CommitInfo = append(result.CommitInfo, &pfs.CommitInfo{
+			Commit:     commit,
+			CommitType: pfs.CommitType_COMMIT_TYPE_READ,
+		})
+	}
+	return result, nil
+}
+
+func (a *internalAPIServer) AbortTransaction(ctx context.Context, request *pfs.FinishTransactionRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.AbortTransaction(request.Transaction.Id, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) MergeCommits(ctx context.Context, request *pfs.MergeCommitsRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.MergeCommits(request.Ours, request.Theirs, request.Commit, request.Strategy, request.Finished, shards); err != nil {
+		return nil, err
+	}
+	if request.Commit != nil {
+		if err := a.pulseCommitWaiters(request.Commit, pfs.CommitType_COMMIT_TYPE_READ, shards); err != nil {
+			return nil, err
+		}
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
 func (a *internalAPIServer) InspectCommit(ctx context.Context, request *pfs.InspectCommitRequest) (response *pfs.CommitInfo, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -156,6 +336,8 @@ func (a *internalAPIServer) InspectCommit(ctx context.Context, request *pfs.Insp
 
 func (a *internalAPIServer) ListCommit(ctx context.Context, request *pfs.ListCommitRequest) (response *pfs.CommitInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -169,12 +351,19 @@ func (a *internalAPIServer) ListCommit(ctx context.Context, request *pfs.ListCom
 		return nil, err
 	}
 	if len(commitInfos) == 0 && request.Block {
-		commitChan := make(chan *pfs.CommitInfo)
-		if err := a.registerCommitWaiter(request, shards, commitChan); err != nil {
+		var waiter *commitWait
+		commitInfos, waiter, err = a.registerCommitWaiter(request, shards)
+		if err != nil {
 			return nil, err
 		}
-		for commitInfo := range commitChan {
-			commitInfos = append(commitInfos, commitInfo)
+		if waiter != nil {
+			select {
+			case commitInfo := <-waiter.commitInfoChan:
+				commitInfos = append(commitInfos, commitInfo)
+			case <-ctx.Done():
+				a.unregisterCommitWaiter(waiter)
+				return nil, ctx.Err()
+			}
 		}
 	}
 	return &pfs.CommitInfos{
@@ -182,8 +371,83 @@ func (a *internalAPIServer) ListCommit(ctx context.Context, request *pfs.ListCom
 	}, nil
 }
 
+func (a *internalAPIServer) TagCommit(ctx context.Context, request *pfs.TagCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetAllShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.TagCommit(request.Commit, request.Tag, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) ListTag(ctx context.Context, request *pfs.ListTagRequest) (response *pfs.TagInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	tagInfos, err := a.driver.ListTag(request.Repo, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.TagInfos{TagInfo: tagInfos}, nil
+}
+
 func (a *internalAPIServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetAllShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.DeleteCommit(request.Commit, request.Deleted, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) RestoreCommit(ctx context.Context, request *pfs.RestoreCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetAllShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.RestoreCommit(request.Commit, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) ListTrash(ctx context.Context, request *pfs.ListTrashRequest) (response *pfs.TrashInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -192,16 +456,34 @@ func (a *internalAPIServer) DeleteCommit(ctx context.Context, request *pfs.Delet
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.DeleteCommit(request.Commit, shards); err != nil {
+	trashInfos, err := a.driver.ListTrash(shards)
+	return &pfs.TrashInfos{TrashInfo: trashInfos}, err
+}
+
+func (a *internalAPIServer) EmptyTrash(ctx context.Context, request *pfs.EmptyTrashRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetAllShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.EmptyTrash(request.Before, shards); err != nil {
 		return nil, err
 	}
-	// TODO push delete to replicas
 	return google_protobuf.EmptyInstance, nil
 }
 
 func (a *internalAPIServer) PutFile(putFileServer pfs.InternalAPI_PutFileServer) (retErr error) {
 	var request *pfs.PutFileRequest
-	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	reader := &countingReader{}
+	defer func(start time.Time) { a.LogStream(request, retErr, time.Since(start), reader.bytes) }(time.Now())
+	ctx, done := grpcutil.StartOp(putFileServer.Context(), nil)
+	defer done()
 	version, err := a.getVersion(putFileServer.Context())
 	if err != nil {
 		return err
@@ -239,21 +521,26 @@ func (a *internalAPIServer) PutFile(putFileServer pfs.InternalAPI_PutFileServer)
 	if err != nil {
 		return err
 	}
-	reader := putFileReader{
+	putFileReader := &putFileReader{
+		ctx:    ctx,
 		server: putFileServer,
 	}
-	_, err = reader.buffer.Write(request.Value)
+	_, err = putFileReader.buffer.Write(request.Value)
 	if err != nil {
 		return err
 	}
-	if err := a.driver.PutFile(request.File, shard, request.OffsetBytes, &reader); err != nil {
+	reader.reader = putFileReader
+	if err := a.driver.PutFile(request.File, shard, request.OffsetBytes, reader); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (a *internalAPIServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.InternalAPI_GetFileServer) (retErr error) {
-	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	reader := &countingReader{}
+	defer func(start time.Time) { a.LogStream(request, retErr, time.Since(start), reader.bytes) }(time.Now())
+	_, done := grpcutil.StartOp(apiGetFileServer.Context(), request)
+	defer done()
 	version, err := a.getVersion(apiGetFileServer.Context())
 	if err != nil {
 		return err
@@ -271,11 +558,90 @@ func (a *internalAPIServer) GetFile(request *pfs.GetFileRequest, apiGetFileServe
 			retErr = err
 		}
 	}()
-	return protostream.WriteToStreamingBytesServer(file, apiGetFileServer)
+	reader.reader = file
+	return grpcutil.WriteToStreamingBytesServer(reader, apiGetFileServer)
+}
+
+func (a *internalAPIServer) SampleFile(ctx context.Context, request *pfs.SampleFileRequest) (response *pfs.FileSample, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	file, err := a.driver.GetFile(request.File, request.Shard, 0, math.MaxInt64, shard)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	return sampleFile(file, request.Records, request.Random, request.Format)
+}
+
+func (a *internalAPIServer) StatsFile(ctx context.Context, request *pfs.StatsFileRequest) (response *pfs.FileStats, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	records := request.Records
+	if records == 0 {
+		records = defaultStatsRecords
+	}
+	commitInfo, err := a.driver.InspectCommit(request.File.Commit, map[uint64]bool{shard: true})
+	if err != nil {
+		return nil, err
+	}
+	// a finished commit's files can't change, so neither can their stats
+	key := statsKey{commitInfo.Commit.Id, path.Clean(request.File.Path), request.Format, records}
+	if commitInfo.Finished != nil {
+		if fileStats, ok := a.statsCache.get(key); ok {
+			result := *fileStats
+			result.File = request.File
+			return &result, nil
+		}
+	}
+	file, err := a.driver.GetFile(request.File, nil, 0, math.MaxInt64, shard)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	sample, err := sampleFile(file, records, true, request.Format)
+	if err != nil {
+		return nil, err
+	}
+	result, err := fileStats(request.File, sample, request.Format)
+	if err != nil {
+		return nil, err
+	}
+	if commitInfo.Finished != nil {
+		a.statsCache.put(key, result)
+	}
+	return result, nil
 }
 
 func (a *internalAPIServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -287,19 +653,50 @@ func (a *internalAPIServer) InspectFile(ctx context.Context, request *pfs.Inspec
 	return a.driver.InspectFile(request.File, request.Shard, shard)
 }
 
-func (a *internalAPIServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (response *pfs.FileInfos, retErr error) {
+func (a *internalAPIServer) InspectFileBlocks(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileBlocks, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	shards, err := a.router.GetMasterShards(version)
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	blockRefs, err := a.driver.InspectFileBlocks(request.File, request.Shard, shard)
 	if err != nil {
 		return nil, err
 	}
+	fileBlocks := &pfs.FileBlocks{}
+	for _, blockRef := range blockRefs {
+		fileBlocks.FileBlock = append(fileBlocks.FileBlock, &pfs.FileBlock{
+			Hash:  blockRef.Block.Hash,
+			Lower: blockRef.Range.Lower,
+			Upper: blockRef.Range.Upper,
+		})
+	}
+	return fileBlocks, nil
+}
+
+func (a *internalAPIServer) ListFile(request *pfs.ListFileRequest, listFileServer pfs.InternalAPI_ListFileServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	_, done := grpcutil.StartOp(listFileServer.Context(), request)
+	defer done()
+	version, err := a.getVersion(listFileServer.Context())
+	if err != nil {
+		return err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return err
+	}
+	// regular files are in a single shard and are sent as each shard is
+	// listed, directories can be in many so they're merged and sent last
 	var wg sync.WaitGroup
 	var lock sync.Mutex
-	var fileInfos []*pfs.FileInfo
+	var directories []*pfs.FileInfo
 	var loopErr error
 	for shard := range shards {
 		shard := shard
@@ -307,28 +704,42 @@ func (a *internalAPIServer) ListFile(ctx context.Context, request *pfs.ListFileR
 		go func() {
 			defer wg.Done()
 			subFileInfos, err := a.driver.ListFile(request.File, request.Shard, shard)
+			lock.Lock()
+			defer lock.Unlock()
 			if err != nil && err != pfs.ErrFileNotFound {
 				if loopErr == nil {
 					loopErr = err
 				}
 				return
 			}
-			lock.Lock()
-			defer lock.Unlock()
-			fileInfos = append(fileInfos, subFileInfos...)
+			for _, fileInfo := range subFileInfos {
+				if loopErr != nil {
+					return
+				}
+				if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+					directories = append(directories, fileInfo)
+					continue
+				}
+				loopErr = listFileServer.Send(fileInfo)
+			}
 		}()
 	}
 	wg.Wait()
 	if loopErr != nil {
-		return nil, loopErr
+		return loopErr
 	}
-	return &pfs.FileInfos{
-		FileInfo: pfs.ReduceFileInfos(fileInfos),
-	}, nil
+	for _, fileInfo := range pfs.ReduceFileInfos(directories) {
+		if err := listFileServer.Send(fileInfo); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (a *internalAPIServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -350,16 +761,235 @@ func (a *internalAPIServer) DeleteFile(ctx context.Context, request *pfs.DeleteF
 	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *internalAPIServer) AddShard(shard uint64, version int64) error {
-	return a.driver.AddShard(shard)
+func (a *internalAPIServer) CopyFile(ctx context.Context, request *pfs.CopyFileRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(request.Src.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.Src.Path)
+	}
+	if strings.HasPrefix(request.Dst.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.Dst.Path)
+	}
+	dstShard, err := a.getMasterShardForFile(request.Dst, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.CopyFile(request.Src, a.sharder.GetShard(request.Src), request.Dst, dstShard); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) ConcatFiles(ctx context.Context, request *pfs.ConcatFilesRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var srcShards []uint64
+	for _, src := range request.Src {
+		if strings.HasPrefix(src.Path, "/") {
+			return nil, fmt.Errorf("pachyderm: leading slash in path: %s", src.Path)
+		}
+		srcShards = append(srcShards, a.sharder.GetShard(src))
+	}
+	if strings.HasPrefix(request.Dst.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.Dst.Path)
+	}
+	dstShard, err := a.getMasterShardForFile(request.Dst, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.ConcatFiles(request.Src, srcShards, request.Dst, dstShard); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) StartMultipartPut(ctx context.Context, request *pfs.StartMultipartPutRequest) (response *pfs.MultipartPut, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(request.File.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	}
+	if _, err := a.getMasterShardForFile(request.File, version); err != nil {
+		return nil, err
+	}
+	return a.multipartPuts.start(request.File), nil
 }
 
-func (a *internalAPIServer) RemoveShard(shard uint64, version int64) error {
-	return a.driver.DeleteShard(shard)
+func (a *internalAPIServer) PutPart(putPartServer pfs.InternalAPI_PutPartServer) (retErr error) {
+	var request *pfs.PutPartRequest
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(putPartServer.Context(), nil)
+	defer done()
+	version, err := a.getVersion(putPartServer.Context())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := putPartServer.SendAndClose(google_protobuf.EmptyInstance); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	request, err = putPartServer.Recv()
+	if err != nil {
+		return err
+	}
+	if _, err := a.getMasterShardForFile(request.MultipartPut.File, version); err != nil {
+		return err
+	}
+	reader := putPartReader{
+		ctx:    ctx,
+		server: putPartServer,
+	}
+	if _, err := reader.buffer.Write(request.Value); err != nil {
+		return err
+	}
+	// parts are written to the block store without holding anything so
+	// they're uploaded concurrently, only recording them is serialized
+	blockRefs, err := a.driver.PutBlocks(&reader)
+	if err != nil {
+		return err
+	}
+	return a.multipartPuts.putPart(request.MultipartPut, request.Number, blockRefs)
+}
+
+func (a *internalAPIServer) CompleteMultipartPut(ctx context.Context, request *pfs.MultipartPut) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	blockRefs, err := a.multipartPuts.blockRefs(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.CreateFile(request.File, shard, blockRefs); err != nil {
+		return nil, err
+	}
+	a.multipartPuts.finish(request)
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) InspectLocalShard(ctx context.Context, request *pfs.InspectLocalShardRequest) (response *pfs.RepoInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetAllShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if !shards[request.Shard] {
+		return nil, fmt.Errorf("pachyderm: shard %d not found locally", request.Shard)
+	}
+	repoInfos, err := a.driver.ListRepo(map[uint64]bool{request.Shard: true})
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.RepoInfos{RepoInfo: repoInfos}, nil
+}
+
+func (a *internalAPIServer) InspectServer(ctx context.Context, request *google_protobuf.Empty) (response *pfs.ServerStats, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetMasterShards(version)
+	if err != nil {
+		return nil, err
+	}
+	repoInfos, err := a.driver.ListRepo(shards)
+	if err != nil {
+		return nil, err
+	}
+	grpcStats := grpcutil.GetServerStats()
+	response = &pfs.ServerStats{
+		MessagesReceived:    grpcStats.MessagesReceived,
+		BytesReceived:       grpcStats.BytesReceived,
+		MessagesSent:        grpcStats.MessagesSent,
+		BytesSent:           grpcStats.BytesSent,
+		Time:                prototime.TimeToTimestamp(time.Now()),
+		AddressesCacheStats: a.router.AddressesCacheStats(),
+	}
+	for _, repoInfo := range repoInfos {
+		response.SizeBytes += repoInfo.SizeBytes
+	}
+	return response, nil
+}
+
+func (a *internalAPIServer) ListSlowOp(ctx context.Context, request *google_protobuf.Empty) (response *pfs.SlowOps, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, done := grpcutil.StartOp(ctx, request)
+	defer done()
+	return &pfs.SlowOps{SlowOp: a.slowOps()}, nil
+}
+
+func (a *internalAPIServer) ListOp(ctx context.Context, request *google_protobuf.Empty) (response *pfs.Ops, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	return opsToProto(a.sharder, grpcutil.ListOps()), nil
+}
+
+func (a *internalAPIServer) CancelOp(ctx context.Context, request *pfs.CancelOpRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if !grpcutil.CancelOp(request.Id) {
+		return nil, fmt.Errorf("pachyderm: op %d not found", request.Id)
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) AddShard(shard uint64, version int64, transfer pkgshard.Transfer) error {
+	if err := a.driver.AddShard(shard, transfer); err != nil {
+		if err == pkgshard.ErrTransferCancelled {
+			// drop the diffs which were pulled so the shard can be added
+			// from scratch later
+			if err := a.driver.DeleteShard(shard); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return a.localShards.add(shard, version)
+}
+
+func (a *internalAPIServer) RemoveShard(shard uint64, version int64, transfer pkgshard.Transfer) error {
+	if err := a.driver.DeleteShard(shard); err != nil {
+		return err
+	}
+	return a.localShards.remove(shard)
 }
 
 func (a *internalAPIServer) LocalShards() (map[uint64]bool, error) {
-	return nil, nil
+	return a.localShards.get(), nil
+}
+
+func (a *internalAPIServer) Flush() error {
+	return a.driver.Flush()
 }
 
 func (a *internalAPIServer) getMasterShardForFile(file *pfs.File, version int64) (uint64, error) {
@@ -375,16 +1005,32 @@ func (a *internalAPIServer) getMasterShardForFile(file *pfs.File, version int64)
 	return shard, nil
 }
 
+// getShardForFile is getMasterShardForFile for reads, which replicas serve
+// while the master's unreachable. A replica only serves reads of commits it
+// holds finished, anything else could be missing writes the master has.
 func (a *internalAPIServer) getShardForFile(file *pfs.File, version int64) (uint64, error) {
 	shard := a.sharder.GetShard(file)
-	shards, err := a.router.GetMasterShards(version)
+	master, err := a.isLocalMasterShard(shard, version)
 	if err != nil {
 		return 0, err
 	}
-	_, ok := shards[shard]
-	if !ok {
+	if master {
+		return shard, nil
+	}
+	replica, err := a.isLocalReplicaShard(shard, version)
+	if err != nil {
+		return 0, err
+	}
+	if !replica {
 		return 0, fmt.Errorf("pachyderm: shard %d not found locally", shard)
 	}
+	commitInfo, err := a.driver.InspectCommit(file.Commit, map[uint64]bool{shard: true})
+	if err != nil {
+		return 0, err
+	}
+	if commitInfo == nil || commitInfo.CommitType != pfs.CommitType_COMMIT_TYPE_READ {
+		return 0, fmt.Errorf("pachyderm: commit %s/%s isn't finished on the replica of shard %d", file.Commit.Repo.Name, file.Commit.Id, shard)
+	}
 	return shard, nil
 }
 
@@ -416,11 +1062,17 @@ func (a *internalAPIServer) isLocalShard(shard uint64, version int64) (bool, err
 }
 
 type putFileReader struct {
+	ctx    context.Context
 	server pfs.InternalAPI_PutFileServer
 	buffer bytes.Buffer
 }
 
 func (r *putFileReader) Read(p []byte) (int, error) {
+	// a cancelled write returns an error before the driver sees EOF so the
+	// partial file is never added to the diff
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
 	if r.buffer.Len() == 0 {
 		request, err := r.server.Recv()
 		if err != nil {
@@ -434,6 +1086,28 @@ func (r *putFileReader) Read(p []byte) (int, error) {
 	return r.buffer.Read(p)
 }
 
+type putPartReader struct {
+	ctx    context.Context
+	server pfs.InternalAPI_PutPartServer
+	buffer bytes.Buffer
+}
+
+func (r *putPartReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if r.buffer.Len() == 0 {
+		request, err := r.server.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := r.buffer.Write(request.Value); err != nil {
+			return 0, err
+		}
+	}
+	return r.buffer.Read(p)
+}
+
 func (a *internalAPIServer) getVersion(ctx context.Context) (int64, error) {
 	md, ok := metadata.FromContext(ctx)
 	if !ok {
@@ -465,7 +1139,9 @@ func newCommitWait(repos []*pfs.Repo, commitType pfs.CommitType, commitInfoChan
 	}
 }
 
-func (a *internalAPIServer) registerCommitWaiter(request *pfs.ListCommitRequest, shards map[uint64]bool, outChan chan *pfs.CommitInfo) error {
+// registerCommitWaiter returns the commits request is waiting for if there
+// are any now, otherwise it registers a commitWait which is sent the next one.
+func (a *internalAPIServer) registerCommitWaiter(request *pfs.ListCommitRequest, shards map[uint64]bool) ([]*pfs.CommitInfo, *commitWait, error) {
 	// This is a blocking request, which means we need to block until we
 	// have at least one response.
 	a.commitWaitersLock.Lock()
@@ -474,18 +1150,30 @@ func (a *internalAPIServer) registerCommitWaiter(request *pfs.ListCommitRequest,
 	// created between then and now.
 	commitInfos, err := a.filteredListCommits(request.Repo, request.FromCommit, request.CommitType, shards)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	if len(commitInfos) != 0 {
-		go func() {
-			for _, commitInfo := range commitInfos {
-				outChan <- commitInfo
-			}
-			close(outChan)
-		}()
+		return commitInfos, nil, nil
 	}
-	a.commitWaiters = append(a.commitWaiters, newCommitWait(request.Repo, request.CommitType, outChan))
-	return nil
+	// the channel is buffered so that pulseCommitWaiters never blocks on a
+	// waiter whose caller has gone away
+	waiter := newCommitWait(request.Repo, request.CommitType, make(chan *pfs.CommitInfo, 1))
+	a.commitWaiters = append(a.commitWaiters, waiter)
+	return nil, waiter, nil
+}
+
+// unregisterCommitWaiter removes waiter, for callers which stop waiting
+// before it's pulsed.
+func (a *internalAPIServer) unregisterCommitWaiter(waiter *commitWait) {
+	a.commitWaitersLock.Lock()
+	defer a.commitWaitersLock.Unlock()
+	var commitWaiters []*commitWait
+	for _, commitWaiter := range a.commitWaiters {
+		if commitWaiter != waiter {
+			commitWaiters = append(commitWaiters, commitWaiter)
+		}
+	}
+	a.commitWaiters = commitWaiters
 }
 
 func (a *internalAPIServer) pulseCommitWaiters(commit *pfs.Commit, commitType pfs.CommitType, shards map[uint64]bool) error {
@@ -502,7 +1190,6 @@ WaitersLoop:
 			for _, repo := range commitWaiter.repos {
 				if repo.Name == commit.Repo.Name {
 					commitWaiter.commitInfoChan <- commitInfo
-					close(commitWaiter.commitInfoChan)
 					continue WaitersLoop
 				}
 			}
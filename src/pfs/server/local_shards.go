@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"go.pedge.io/protolog"
+)
+
+// localShards is the set of shards a server hosts and the versions they were
+// added at. It's kept in a file so that after a restart the server can report
+// its shards in its ServerState before it's been assigned any roles, which
+// lets AssignRoles give them back to it rather than moving them elsewhere.
+type localShards struct {
+	path   string
+	lock   sync.Mutex
+	shards map[uint64]int64
+	// restored is true until the first shard is added after a restart, at
+	// that point the sharder has taken over and shards from the file which
+	// it doesn't add again are stale
+	restored bool
+}
+
+// newLocalShards returns the localShards stored at path, an empty path keeps
+// them in memory only. The file is only a hint so errors reading it are
+// logged rather than returned.
+func newLocalShards(path string) *localShards {
+	shards := make(map[uint64]int64)
+	if path != "" {
+		var err error
+		if shards, err = readLocalShards(path); err != nil {
+			protolog.Printf("Error reading local shards from %s: %s", path, err.Error())
+			shards = make(map[uint64]int64)
+		}
+	}
+	return &localShards{
+		path:     path,
+		shards:   shards,
+		restored: len(shards) > 0,
+	}
+}
+
+func (l *localShards) add(shard uint64, version int64) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.restored {
+		l.shards = make(map[uint64]int64)
+		l.restored = false
+	}
+	l.shards[shard] = version
+	return l.write()
+}
+
+func (l *localShards) remove(shard uint64) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.shards, shard)
+	return l.write()
+}
+
+func (l *localShards) get() map[uint64]bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	result := make(map[uint64]bool)
+	for shard := range l.shards {
+		result[shard] = true
+	}
+	return result
+}
+
+// write replaces the file with the current shards, it's written to a
+// temporary file and renamed so a crash never leaves a partial file.
+func (l *localShards) write() error {
+	if l.path == "" {
+		return nil
+	}
+	var shards uint64Slice
+	for shard := range l.shards {
+		shards = append(shards, shard)
+	}
+	sort.Sort(shards)
+	var buffer bytes.Buffer
+	for _, shard := range shards {
+		fmt.Fprintf(&buffer, "%d %d\n", shard, l.shards[shard])
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0777); err != nil {
+		return err
+	}
+	tmpPath := l.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buffer.Bytes(), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, l.path)
+}
+
+// readLocalShards reads a file written by write, each line is a shard and the
+// version it was added at.
+func readLocalShards(path string) (map[uint64]int64, error) {
+	result := make(map[uint64]int64)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var shard uint64
+		var version int64
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d", &shard, &version); err != nil {
+			return nil, fmt.Errorf("malformed line %q: %s", scanner.Text(), err.Error())
+		}
+		result[shard] = version
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/pkg/uuid"
+)
+
+// multipartPutTTL is how long a multipart put which isn't touched is kept
+// before it's dropped, its parts' blocks are left for block gc.
+const multipartPutTTL = 24 * time.Hour
+
+// multipartPuts is the multipart puts of the files whose shards a server
+// is the master of, by id. They're kept in memory so a put whose shard
+// moves has to be started again.
+type multipartPuts struct {
+	lock sync.Mutex
+	puts map[string]*multipartPut
+}
+
+type multipartPut struct {
+	file    *pfs.File
+	parts   map[uint64][]*drive.BlockRef
+	touched time.Time
+}
+
+func newMultipartPuts() *multipartPuts {
+	return &multipartPuts{puts: make(map[string]*multipartPut)}
+}
+
+// start starts a multipart put of file, dropping the puts which expired.
+func (m *multipartPuts) start(file *pfs.File) *pfs.MultipartPut {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	for id, put := range m.puts {
+		if now.Sub(put.touched) > multipartPutTTL {
+			delete(m.puts, id)
+		}
+	}
+	id := uuid.NewWithoutDashes()
+	m.puts[id] = &multipartPut{
+		file:    file,
+		parts:   make(map[uint64][]*drive.BlockRef),
+		touched: now,
+	}
+	return &pfs.MultipartPut{Id: id, File: file}
+}
+
+// get returns the put for multipartPut, the caller must hold m.lock.
+func (m *multipartPuts) get(multipartPut *pfs.MultipartPut) (*multipartPut, error) {
+	put, ok := m.puts[multipartPut.Id]
+	if !ok || !sameFile(put.file, multipartPut.File) {
+		return nil, fmt.Errorf("pachyderm: multipart put %s not found", multipartPut.Id)
+	}
+	put.touched = time.Now()
+	return put, nil
+}
+
+func (m *multipartPuts) putPart(multipartPut *pfs.MultipartPut, number uint64, blockRefs []*drive.BlockRef) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	put, err := m.get(multipartPut)
+	if err != nil {
+		return err
+	}
+	put.parts[number] = blockRefs
+	return nil
+}
+
+// blockRefs returns the blocks of multipartPut's parts in number order.
+func (m *multipartPuts) blockRefs(multipartPut *pfs.MultipartPut) ([]*drive.BlockRef, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	put, err := m.get(multipartPut)
+	if err != nil {
+		return nil, err
+	}
+	if len(put.parts) == 0 {
+		return nil, fmt.Errorf("pachyderm: multipart put %s has no parts", multipartPut.Id)
+	}
+	var numbers []uint64
+	for number := range put.parts {
+		numbers = append(numbers, number)
+	}
+	sort.Sort(uint64Slice(numbers))
+	var result []*drive.BlockRef
+	for _, number := range numbers {
+		result = append(result, put.parts[number]...)
+	}
+	return result, nil
+}
+
+func (m *multipartPuts) finish(multipartPut *pfs.MultipartPut) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.puts, multipartPut.Id)
+}
+
+func sameFile(a *pfs.File, b *pfs.File) bool {
+	return a.Commit.Repo.Name == b.Commit.Repo.Name &&
+		a.Commit.Id == b.Commit.Id &&
+		path.Clean(a.Path) == path.Clean(b.Path)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/time"
+)
+
+// maxRequestSummary is how much of a request's text is kept with an op.
+const maxRequestSummary = 256
+
+func opsToProto(sharder route.Sharder, ops []*grpcutil.Op) *pfs.Ops {
+	result := &pfs.Ops{}
+	for _, op := range ops {
+		result.Op = append(result.Op, &pfs.Op{
+			Id:      op.ID,
+			Method:  op.Method,
+			Peer:    audit.Principal(op.Context),
+			Shard:   requestShard(sharder, op.Request),
+			Request: requestSummary(op.Request),
+			Started: prototime.TimeToTimestamp(op.Started),
+		})
+	}
+	return result
+}
+
+// requestShard returns the shard of the file request is about, or nil if it
+// isn't about a file.
+func requestShard(sharder route.Sharder, request proto.Message) *google_protobuf.UInt64Value {
+	if fileRequest, ok := request.(interface {
+		GetFile() *pfs.File
+	}); ok && fileRequest.GetFile() != nil {
+		return &google_protobuf.UInt64Value{Value: sharder.GetShard(fileRequest.GetFile())}
+	}
+	return nil
+}
+
+// requestSummary returns the start of request's text form.
+func requestSummary(request proto.Message) string {
+	if request == nil {
+		return ""
+	}
+	result := request.String()
+	if len(result) > maxRequestSummary {
+		return result[:maxRequestSummary] + "..."
+	}
+	return result
+}
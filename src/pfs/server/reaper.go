@@ -0,0 +1,46 @@
+package server
+
+import (
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+)
+
+func reap(apiClient pfs.APIClient, interval time.Duration, cancel chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cancel:
+			return
+		case <-ticker.C:
+		}
+		if err := reapExpiredRepos(apiClient, time.Now()); err != nil {
+			protolog.Printf("Error reaping scratch repos %s", err.Error())
+		}
+		if _, err := apiClient.EmptyTrash(context.Background(), &pfs.EmptyTrashRequest{}); err != nil {
+			protolog.Printf("Error emptying trash %s", err.Error())
+		}
+	}
+}
+
+func reapExpiredRepos(apiClient pfs.APIClient, now time.Time) error {
+	repoInfos, err := apiClient.ListRepo(context.Background(), &pfs.ListRepoRequest{})
+	if err != nil {
+		return err
+	}
+	for _, repoInfo := range repoInfos.RepoInfo {
+		if repoInfo.Expires == nil || prototime.TimestampToTime(repoInfo.Expires).After(now) {
+			continue
+		}
+		// every pfsd runs a reaper so someone else may have beaten us to it,
+		// we log and carry on rather than give up on the other repos
+		if _, err := apiClient.DeleteRepo(context.Background(), &pfs.DeleteRepoRequest{Repo: repoInfo.Repo, Purge: true}); err != nil {
+			protolog.Printf("Error deleting expired scratch repo %s %s", repoInfo.Repo.Name, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"math/rand"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+)
+
+// defaultSampleRecords is how many records SampleFile returns when the
+// request doesn't say.
+const defaultSampleRecords = 10
+
+// recordReader reads a file a record at a time.
+type recordReader interface {
+	// Read returns the next record or io.EOF.
+	Read() ([]byte, error)
+}
+
+func newRecordReader(reader io.Reader, format pfs.RecordFormat) recordReader {
+	if format == pfs.RecordFormat_RECORD_FORMAT_CSV {
+		return newCSVRecordReader(reader)
+	}
+	return &lineRecordReader{bufio.NewReader(reader)}
+}
+
+// lineRecordReader reads newline delimited records, ie JSON lines.
+type lineRecordReader struct {
+	reader *bufio.Reader
+}
+
+func (r *lineRecordReader) Read() ([]byte, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if record := bytes.TrimRight(line, "\r\n"); len(record) > 0 {
+			return record, nil
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		// blank lines aren't records, keep going
+	}
+}
+
+// csvRecordReader reads CSV rows. A quoted field can span lines so rows are
+// re-encoded rather than returned as they appear in the file.
+type csvRecordReader struct {
+	reader *csv.Reader
+}
+
+func newCSVRecordReader(reader io.Reader) *csvRecordReader {
+	csvReader := csv.NewReader(reader)
+	// a ragged row is still a record worth showing
+	csvReader.FieldsPerRecord = -1
+	return &csvRecordReader{csvReader}
+}
+
+func (r *csvRecordReader) Read() ([]byte, error) {
+	fields, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write(fields); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// sampleFile returns the first records read from reader, or if random is set
+// a uniform random sample of all of them.
+func sampleFile(reader io.Reader, records uint64, random bool, format pfs.RecordFormat) (*pfs.FileSample, error) {
+	if records == 0 {
+		records = defaultSampleRecords
+	}
+	recordReader := newRecordReader(reader, format)
+	result := &pfs.FileSample{}
+	if format == pfs.RecordFormat_RECORD_FORMAT_CSV {
+		header, err := recordReader.Read()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		result.Header = header
+	}
+	for random || uint64(len(result.Record)) < records {
+		record, err := recordReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.RecordsScanned++
+		if uint64(len(result.Record)) < records {
+			result.Record = append(result.Record, record)
+		} else if i := uint64(rand.Int63n(int64(result.RecordsScanned))); i < records {
+			// reservoir sampling, every record scanned is equally likely to
+			// end up in the sample
+			result.Record[i] = record
+		}
+	}
+	return result, nil
+}
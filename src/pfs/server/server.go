@@ -1,42 +1,99 @@
 package server
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/drive"
 	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/events"
 	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"github.com/pachyderm/pachyderm/src/pps"
+)
+
+var (
+	// ErrShutdown is returned for writes made after Shutdown has been called.
+	ErrShutdown = errors.New("pachyderm.pfs.server: server is shutting down")
 )
 
 type APIServer interface {
 	pfs.APIServer
 	shard.Frontend
+	// Shutdown stops the server from accepting new StartCommit and PutFile
+	// requests and waits for in-flight ones to finish.
+	Shutdown()
 }
 
 type InternalAPIServer interface {
 	pfs.InternalAPIServer
 	shard.Server
+	// Flush persists commits which haven't been finished so that they aren't
+	// lost when the server exits.
+	Flush() error
+	// SetSlowOpThreshold is a config Setter for SlowOpThresholdKey, rpcs
+	// which take at least the threshold are returned by ListSlowOp.
+	SetSlowOpThreshold(value string) error
 }
 
-// NewAPIServer returns a new APIServer.
+// NewAPIServer returns a new APIServer, mutating rpcs and reads of sensitive
+// repos are recorded with auditRecorder and finished commits with
+// eventRecorder. Repos which pipelines read from or
+// write to aren't deleted without force unless pipelineAPIClient is nil.
+// Tars read their blocks through driveAPIClient if it isn't nil. Deleted repos and commits can be restored until they've been in the trash
+// for trashWindow.
 func NewAPIServer(
 	sharder route.Sharder,
 	router route.Router,
+	auditRecorder audit.Recorder,
+	eventRecorder events.Recorder,
+	pipelineAPIClient pps.PipelineAPIClient,
+	driveAPIClient drive.APIClient,
+	trashWindow time.Duration,
 ) APIServer {
 	return newAPIServer(
 		sharder,
 		router,
+		auditRecorder,
+		eventRecorder,
+		pipelineAPIClient,
+		driveAPIClient,
+		trashWindow,
 	)
 }
 
-// NewInternalAPIServer returns a new InternalAPIServer.
+// Reap deletes expired scratch repos and empties the trash of anything older
+// than the trash window through apiClient every interval until cancel is
+// closed.
+func Reap(apiClient pfs.APIClient, interval time.Duration, cancel chan bool) {
+	reap(apiClient, interval, cancel)
+}
+
+// NewContentHandler returns an http.Handler which serves the contents of files
+// read through apiClient at /repos/{repo}/commits/{commit}/files/{path}, with
+// support for ranges and ETags, to requests whose ContentTokenHeader is
+// "Bearer " followed by token. Reads of sensitive repos are recorded with
+// auditRecorder as ContentPrincipalHeader. Cross origin requests are allowed
+// from corsOrigins, which may include "*" to allow any origin.
+func NewContentHandler(apiClient pfs.APIClient, auditRecorder audit.Recorder, token string, corsOrigins []string) http.Handler {
+	return newContentHandler(apiClient, auditRecorder, token, corsOrigins)
+}
+
+// NewInternalAPIServer returns a new InternalAPIServer. The shards it hosts
+// are recorded in the file at localShardsPath, if it's set, so that they can
+// be reported to the sharder as soon as the server restarts.
 func NewInternalAPIServer(
 	sharder route.Sharder,
 	router route.Router,
 	driver drive.Driver,
+	localShardsPath string,
 ) InternalAPIServer {
 	return newInternalAPIServer(
 		sharder,
 		router,
 		driver,
+		localShardsPath,
 	)
 }
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"go.pedge.io/proto/rpclog"
+	"go.pedge.io/proto/time"
+)
+
+const (
+	// SlowOpThresholdKey is the runtime config key for how long an rpc to a
+	// pfs server has to take to be kept as a slow op, its value is a
+	// duration such as "500ms", 0 keeps none.
+	SlowOpThresholdKey = "slow_op_threshold"
+	// DefaultSlowOpThreshold is the threshold used when SlowOpThresholdKey
+	// isn't set.
+	DefaultSlowOpThreshold = time.Second
+	// maxSlowOps is how many slow ops a server keeps, older ones are dropped.
+	maxSlowOps = 100
+)
+
+// slowOpLogger is a protorpclog.Logger which also keeps the most recent rpcs
+// which took longer than the threshold.
+type slowOpLogger struct {
+	serviceName string
+	sharder     route.Sharder
+	threshold   int64
+	lock        sync.Mutex
+	// ops is a ring of the slow ops, next is where the next one goes
+	ops  []*pfs.SlowOp
+	next int
+}
+
+func newSlowOpLogger(serviceName string, sharder route.Sharder) *slowOpLogger {
+	return &slowOpLogger{
+		serviceName: serviceName,
+		sharder:     sharder,
+		threshold:   int64(DefaultSlowOpThreshold),
+	}
+}
+
+func (l *slowOpLogger) Log(request proto.Message, response proto.Message, err error, duration time.Duration) {
+	methodName := getMethodName()
+	protorpclog.Log(l.serviceName, methodName, request, response, err, duration)
+	l.record(methodName, request, err, duration, uint64(messageSize(request)+messageSize(response)))
+}
+
+// LogStream is Log for rpcs which stream bytes, bytes is how many were
+// streamed.
+func (l *slowOpLogger) LogStream(request proto.Message, err error, duration time.Duration, bytes uint64) {
+	methodName := getMethodName()
+	protorpclog.Log(l.serviceName, methodName, request, nil, err, duration)
+	l.record(methodName, request, err, duration, bytes)
+}
+
+// SetSlowOpThreshold is a config Setter for SlowOpThresholdKey.
+func (l *slowOpLogger) SetSlowOpThreshold(value string) error {
+	threshold := DefaultSlowOpThreshold
+	if value != "" {
+		var err error
+		if threshold, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("pachyderm: invalid %s %q: %s", SlowOpThresholdKey, value, err.Error())
+		}
+		if threshold < 0 {
+			return fmt.Errorf("pachyderm: %s can't be negative", SlowOpThresholdKey)
+		}
+	}
+	atomic.StoreInt64(&l.threshold, int64(threshold))
+	return nil
+}
+
+// slowOps returns the slow ops, most recent first.
+func (l *slowOpLogger) slowOps() []*pfs.SlowOp {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var result []*pfs.SlowOp
+	for i := 1; i <= len(l.ops); i++ {
+		result = append(result, l.ops[(l.next-i+len(l.ops))%len(l.ops)])
+	}
+	return result
+}
+
+func (l *slowOpLogger) record(methodName string, request proto.Message, err error, duration time.Duration, bytes uint64) {
+	threshold := time.Duration(atomic.LoadInt64(&l.threshold))
+	if threshold == 0 || duration < threshold {
+		return
+	}
+	slowOp := &pfs.SlowOp{
+		Method:   methodName,
+		Request:  requestSummary(request),
+		Shard:    requestShard(l.sharder, request),
+		Duration: prototime.DurationToProto(duration),
+		Bytes:    bytes,
+		Time:     prototime.TimeToTimestamp(time.Now()),
+	}
+	if err != nil {
+		slowOp.Error = err.Error()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.ops) < maxSlowOps {
+		l.ops = append(l.ops, slowOp)
+	} else {
+		l.ops[l.next] = slowOp
+	}
+	l.next = (l.next + 1) % maxSlowOps
+}
+
+func messageSize(message proto.Message) int {
+	if message == nil {
+		return 0
+	}
+	return proto.Size(message)
+}
+
+// getMethodName returns the name of the rpc handler which called Log, Log is
+// called from a deferred func in the handler.
+func getMethodName() string {
+	pc := make([]uintptr, 1)
+	runtime.Callers(4, pc)
+	split := strings.Split(runtime.FuncForPC(pc[0]).Name(), ".")
+	return split[len(split)-1]
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	bytes  uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.bytes += uint64(n)
+	return n, err
+}
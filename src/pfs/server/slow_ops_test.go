@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/route"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestSlowOps(t *testing.T) {
+	sharder := route.NewSharder(16, 1)
+	logger := newSlowOpLogger("test", sharder)
+	file := &pfs.File{Commit: &pfs.Commit{Repo: &pfs.Repo{Name: "repo"}, Id: "commit"}, Path: "file"}
+
+	testHandler(logger, &pfs.InspectFileRequest{File: file}, time.Millisecond)
+	require.Equal(t, 0, len(logger.slowOps()))
+
+	testHandler(logger, &pfs.InspectFileRequest{File: file}, 2*time.Second)
+	testHandler(logger, &pfs.ListRepoRequest{}, 3*time.Second)
+	slowOps := logger.slowOps()
+	require.Equal(t, 2, len(slowOps))
+	require.Equal(t, "testHandler", slowOps[1].Method)
+	require.Equal(t, sharder.GetShard(file), slowOps[1].Shard.Value)
+	require.Equal(t, uint64(proto.Size(&pfs.InspectFileRequest{File: file})), slowOps[1].Bytes)
+	require.True(t, slowOps[0].Shard == nil)
+
+	require.NoError(t, logger.SetSlowOpThreshold("0"))
+	testHandler(logger, &pfs.ListRepoRequest{}, time.Hour)
+	require.Equal(t, 2, len(logger.slowOps()))
+	require.True(t, logger.SetSlowOpThreshold("-1s") != nil)
+
+	require.NoError(t, logger.SetSlowOpThreshold("1ms"))
+	for i := 0; i < maxSlowOps+10; i++ {
+		testHandler(logger, &pfs.ListRepoRequest{}, time.Duration(i+1)*time.Second)
+	}
+	slowOps = logger.slowOps()
+	require.Equal(t, maxSlowOps, len(slowOps))
+	require.Equal(t, int64(maxSlowOps+10), slowOps[0].Duration.Seconds)
+	require.Equal(t, int64(11), slowOps[maxSlowOps-1].Duration.Seconds)
+}
+
+// testHandler logs like an rpc handler which took duration.
+func testHandler(logger *slowOpLogger, request proto.Message, duration time.Duration) (retErr error) {
+	defer func() { logger.Log(request, nil, retErr, duration) }()
+	return nil
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+)
+
+const (
+	// defaultStatsRecords is how many records StatsFile samples when the
+	// request doesn't say.
+	defaultStatsRecords = 1000
+	// topValues is how many of a field's most common values are returned.
+	topValues = 5
+	// statsCacheSize is how many FileStats a server keeps for files in
+	// finished commits.
+	statsCacheSize = 1024
+	// valueField is the field a JSON lines record which isn't an object is
+	// counted as.
+	valueField = "value"
+)
+
+// fieldValues is what's been sampled of a field.
+type fieldValues struct {
+	types  map[pfs.FieldType]bool
+	counts map[string]uint64
+	// numbers is the numeric values, for min, max and mean
+	numbers []float64
+}
+
+func (f *fieldValues) add(fieldType pfs.FieldType, value string, number float64) {
+	// nulls are counted from the records the field wasn't present in
+	if fieldType == pfs.FieldType_FIELD_TYPE_NULL {
+		return
+	}
+	f.types[fieldType] = true
+	f.counts[value]++
+	if fieldType == pfs.FieldType_FIELD_TYPE_INT || fieldType == pfs.FieldType_FIELD_TYPE_FLOAT {
+		f.numbers = append(f.numbers, number)
+	}
+}
+
+// fileStats returns the stats of file inferred from sample, which must have
+// been taken in format.
+func fileStats(file *pfs.File, sample *pfs.FileSample, format pfs.RecordFormat) (*pfs.FileStats, error) {
+	fields := make(map[string]*fieldValues)
+	field := func(name string) *fieldValues {
+		if _, ok := fields[name]; !ok {
+			fields[name] = &fieldValues{
+				types:  make(map[pfs.FieldType]bool),
+				counts: make(map[string]uint64),
+			}
+		}
+		return fields[name]
+	}
+	var header []string
+	if format == pfs.RecordFormat_RECORD_FORMAT_CSV && len(sample.Header) > 0 {
+		var err error
+		if header, err = parseCSVRecord(sample.Header); err != nil {
+			return nil, err
+		}
+		for _, name := range header {
+			field(name)
+		}
+	}
+	for _, record := range sample.Record {
+		if format == pfs.RecordFormat_RECORD_FORMAT_CSV {
+			values, err := parseCSVRecord(record)
+			if err != nil {
+				return nil, err
+			}
+			for i, value := range values {
+				name := strconv.Itoa(i)
+				if i < len(header) {
+					name = header[i]
+				}
+				fieldType, number := csvValueType(value)
+				field(name).add(fieldType, value, number)
+			}
+			continue
+		}
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(record))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("pachyderm: record isn't json: %s", err.Error())
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			object = map[string]interface{}{valueField: value}
+		}
+		for name, value := range object {
+			fieldType, number := jsonValueType(value)
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := value.(string); ok {
+				encoded = []byte(s)
+			}
+			field(name).add(fieldType, string(encoded), number)
+		}
+	}
+	result := &pfs.FileStats{
+		File:           file,
+		RecordsSampled: uint64(len(sample.Record)),
+		RecordsScanned: sample.RecordsScanned,
+	}
+	for name, values := range fields {
+		result.FieldStats = append(result.FieldStats, values.stats(name, result.RecordsSampled))
+	}
+	sort.Sort(fieldStatsByName(result.FieldStats))
+	return result, nil
+}
+
+func (f *fieldValues) stats(name string, records uint64) *pfs.FieldStats {
+	result := &pfs.FieldStats{
+		Name:     name,
+		Type:     fieldType(f.types),
+		Distinct: uint64(len(f.counts)),
+	}
+	if records > 0 {
+		var present uint64
+		for _, count := range f.counts {
+			present += count
+		}
+		// records without the field at all count as nulls
+		result.NullRate = float64(records-present) / float64(records)
+	}
+	if len(f.numbers) > 0 && (result.Type == pfs.FieldType_FIELD_TYPE_INT || result.Type == pfs.FieldType_FIELD_TYPE_FLOAT) {
+		result.Min, result.Max = math.Inf(1), math.Inf(-1)
+		var sum float64
+		for _, number := range f.numbers {
+			result.Min = math.Min(result.Min, number)
+			result.Max = math.Max(result.Max, number)
+			sum += number
+		}
+		result.Mean = sum / float64(len(f.numbers))
+	}
+	if result.Type == pfs.FieldType_FIELD_TYPE_OBJECT || result.Type == pfs.FieldType_FIELD_TYPE_ARRAY {
+		return result
+	}
+	for value, count := range f.counts {
+		result.TopValue = append(result.TopValue, &pfs.ValueCount{Value: value, Count: count})
+	}
+	sort.Sort(valueCountsByCount(result.TopValue))
+	if len(result.TopValue) > topValues {
+		result.TopValue = result.TopValue[:topValues]
+	}
+	return result
+}
+
+// fieldType returns the type of a field whose values had types.
+func fieldType(types map[pfs.FieldType]bool) pfs.FieldType {
+	if types[pfs.FieldType_FIELD_TYPE_INT] && types[pfs.FieldType_FIELD_TYPE_FLOAT] {
+		delete(types, pfs.FieldType_FIELD_TYPE_INT)
+	}
+	switch len(types) {
+	case 0:
+		return pfs.FieldType_FIELD_TYPE_NULL
+	case 1:
+		for fieldType := range types {
+			return fieldType
+		}
+	}
+	return pfs.FieldType_FIELD_TYPE_MIXED
+}
+
+// jsonValueType returns the type of a value decoded with UseNumber, and the
+// value if it's a number.
+func jsonValueType(value interface{}) (pfs.FieldType, float64) {
+	switch value := value.(type) {
+	case nil:
+		return pfs.FieldType_FIELD_TYPE_NULL, 0
+	case bool:
+		return pfs.FieldType_FIELD_TYPE_BOOL, 0
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return pfs.FieldType_FIELD_TYPE_INT, float64(i)
+		}
+		f, _ := value.Float64()
+		return pfs.FieldType_FIELD_TYPE_FLOAT, f
+	case string:
+		return pfs.FieldType_FIELD_TYPE_STRING, 0
+	case map[string]interface{}:
+		return pfs.FieldType_FIELD_TYPE_OBJECT, 0
+	}
+	return pfs.FieldType_FIELD_TYPE_ARRAY, 0
+}
+
+// csvValueType returns the type of a CSV value, and the value if it's a
+// number. CSV values are all strings so the type is whatever they parse as.
+func csvValueType(value string) (pfs.FieldType, float64) {
+	if value == "" {
+		return pfs.FieldType_FIELD_TYPE_NULL, 0
+	}
+	// ParseBool would take 1 and 0 as bools too
+	if strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+		return pfs.FieldType_FIELD_TYPE_BOOL, 0
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return pfs.FieldType_FIELD_TYPE_INT, float64(i)
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return pfs.FieldType_FIELD_TYPE_FLOAT, f
+	}
+	return pfs.FieldType_FIELD_TYPE_STRING, 0
+}
+
+// parseCSVRecord returns the fields of a record returned by csvRecordReader.
+func parseCSVRecord(record []byte) ([]string, error) {
+	reader := csv.NewReader(bytes.NewReader(record))
+	reader.FieldsPerRecord = -1
+	return reader.Read()
+}
+
+type fieldStatsByName []*pfs.FieldStats
+
+func (f fieldStatsByName) Len() int           { return len(f) }
+func (f fieldStatsByName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f fieldStatsByName) Less(i, j int) bool { return f[i].Name < f[j].Name }
+
+type valueCountsByCount []*pfs.ValueCount
+
+func (v valueCountsByCount) Len() int      { return len(v) }
+func (v valueCountsByCount) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v valueCountsByCount) Less(i, j int) bool {
+	if v[i].Count != v[j].Count {
+		return v[i].Count > v[j].Count
+	}
+	return v[i].Value < v[j].Value
+}
+
+// statsKey identifies the stats of a file in a finished commit, which can't
+// change.
+type statsKey struct {
+	commitID string
+	path     string
+	format   pfs.RecordFormat
+	records  uint64
+}
+
+type statsEntry struct {
+	key       statsKey
+	fileStats *pfs.FileStats
+}
+
+// statsCache is an lru cache of FileStats.
+type statsCache struct {
+	size    int
+	entries map[statsKey]*list.Element
+	order   *list.List
+	lock    sync.Mutex
+}
+
+func newStatsCache(size int) *statsCache {
+	return &statsCache{
+		size:    size,
+		entries: make(map[statsKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *statsCache) get(key statsKey) (*pfs.FileStats, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*statsEntry).fileStats, true
+}
+
+func (c *statsCache) put(key statsKey, fileStats *pfs.FileStats) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*statsEntry).fileStats = fileStats
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&statsEntry{key, fileStats})
+	for c.order.Len() > c.size {
+		element := c.order.Back()
+		delete(c.entries, element.Value.(*statsEntry).key)
+		c.order.Remove(element)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestFileStatsJSON(t *testing.T) {
+	data := strings.Join([]string{
+		`{"id": 1, "name": "alice", "score": 1.5, "tags": ["a"]}`,
+		`{"id": 2, "name": "bob", "score": 2, "tags": []}`,
+		`{"id": 3, "name": null, "score": "high"}`,
+		`{"id": 4, "name": "bob", "score": 4.5}`,
+	}, "\n")
+	sample, err := sampleFile(strings.NewReader(data), 10, false, pfs.RecordFormat_RECORD_FORMAT_LINES)
+	require.NoError(t, err)
+	fileStats, err := fileStats(&pfs.File{Path: "file"}, sample, pfs.RecordFormat_RECORD_FORMAT_LINES)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), fileStats.RecordsSampled)
+	require.Equal(t, 4, len(fileStats.FieldStats))
+
+	id := fileStats.FieldStats[0]
+	require.Equal(t, "id", id.Name)
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_INT, id.Type)
+	require.Equal(t, uint64(4), id.Distinct)
+	require.Equal(t, float64(1), id.Min)
+	require.Equal(t, float64(4), id.Max)
+	require.Equal(t, 2.5, id.Mean)
+
+	name := fileStats.FieldStats[1]
+	require.Equal(t, "name", name.Name)
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_STRING, name.Type)
+	require.Equal(t, 0.25, name.NullRate)
+	require.Equal(t, uint64(2), name.Distinct)
+	require.Equal(t, &pfs.ValueCount{Value: "bob", Count: 2}, name.TopValue[0])
+
+	score := fileStats.FieldStats[2]
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_MIXED, score.Type)
+
+	tags := fileStats.FieldStats[3]
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_ARRAY, tags.Type)
+	require.Equal(t, 0.5, tags.NullRate)
+	require.Equal(t, 0, len(tags.TopValue))
+}
+
+func TestFileStatsCSV(t *testing.T) {
+	data := "id,price,note\n1,1.5,\n2,2,\"a, b\"\n"
+	sample, err := sampleFile(strings.NewReader(data), 10, false, pfs.RecordFormat_RECORD_FORMAT_CSV)
+	require.NoError(t, err)
+	fileStats, err := fileStats(&pfs.File{Path: "file"}, sample, pfs.RecordFormat_RECORD_FORMAT_CSV)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(fileStats.FieldStats))
+	require.Equal(t, "id", fileStats.FieldStats[0].Name)
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_INT, fileStats.FieldStats[0].Type)
+	require.Equal(t, "note", fileStats.FieldStats[1].Name)
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_STRING, fileStats.FieldStats[1].Type)
+	require.Equal(t, 0.5, fileStats.FieldStats[1].NullRate)
+	require.Equal(t, "a, b", fileStats.FieldStats[1].TopValue[0].Value)
+	// ints and floats together are floats
+	require.Equal(t, "price", fileStats.FieldStats[2].Name)
+	require.Equal(t, pfs.FieldType_FIELD_TYPE_FLOAT, fileStats.FieldStats[2].Type)
+	require.Equal(t, 1.75, fileStats.FieldStats[2].Mean)
+}
+
+func TestStatsCache(t *testing.T) {
+	cache := newStatsCache(1)
+	first := statsKey{"commit", "first", pfs.RecordFormat_RECORD_FORMAT_LINES, 1000}
+	second := statsKey{"commit", "second", pfs.RecordFormat_RECORD_FORMAT_LINES, 1000}
+	cache.put(first, &pfs.FileStats{RecordsSampled: 1})
+	fileStats, ok := cache.get(first)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), fileStats.RecordsSampled)
+	cache.put(second, &pfs.FileStats{RecordsSampled: 2})
+	_, ok = cache.get(first)
+	require.False(t, ok)
+	_, ok = cache.get(second)
+	require.True(t, ok)
+}
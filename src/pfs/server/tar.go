@@ -0,0 +1,118 @@
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.pedge.io/proto/time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+)
+
+// defaultTarMode is the mode of files in a tar whose permissions weren't set.
+const defaultTarMode = 0644
+
+// writeTar writes a tar of the files in fileInfos, named relative to dir, to
+// writer. blocks returns the blocks a file is made of and read writes the
+// contents of those blocks, each file's size in the tar is the size of its
+// blocks so a file written to after it was listed still fits its header. A
+// file made of the same blocks as one already in the tar is written as a hard
+// link to it rather than read again.
+func writeTar(
+	writer io.Writer,
+	gzipped bool,
+	dir string,
+	fileInfos []*pfs.FileInfo,
+	blocks func(*pfs.File) (*pfs.FileBlocks, error),
+	read func(*pfs.File, *pfs.FileBlocks, io.Writer) error,
+) (retErr error) {
+	// the tar writes headers and padding in tiny pieces, we don't want a
+	// message for each of them
+	bufferedWriter := bufio.NewWriterSize(writer, grpcutil.StreamingChunkSize)
+	defer func() {
+		if err := bufferedWriter.Flush(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	writer = bufferedWriter
+	if gzipped {
+		gzipWriter := gzip.NewWriter(writer)
+		defer func() {
+			if err := gzipWriter.Close(); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+		writer = gzipWriter
+	}
+	tarWriter := tar.NewWriter(writer)
+	defer func() {
+		if err := tarWriter.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	// written maps the blocks of each file in the tar to its name
+	written := make(map[string]string)
+	for _, fileInfo := range fileInfos {
+		fileBlocks, err := blocks(fileInfo.File)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:     relativePath(dir, fileInfo.File.Path),
+			Mode:     defaultTarMode,
+			Typeflag: tar.TypeReg,
+		}
+		if fileInfo.Perm != 0 {
+			header.Mode = int64(os.FileMode(fileInfo.Perm).Perm())
+		}
+		if fileInfo.Modified != nil {
+			header.ModTime = prototime.TimestampToTime(fileInfo.Modified)
+		}
+		key := blocksKey(fileBlocks)
+		if linkname, ok := written[key]; ok && key != "" {
+			header.Typeflag = tar.TypeLink
+			header.Linkname = linkname
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+		written[key] = header.Name
+		header.Size = int64(blocksSize(fileBlocks))
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Size == 0 {
+			continue
+		}
+		if err := read(fileInfo.File, fileBlocks, tarWriter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blocksSize returns the number of bytes in fileBlocks.
+func blocksSize(fileBlocks *pfs.FileBlocks) uint64 {
+	var result uint64
+	for _, fileBlock := range fileBlocks.FileBlock {
+		result += fileBlock.Upper - fileBlock.Lower
+	}
+	return result
+}
+
+// blocksKey returns a string which is the same for files made of the same
+// ranges of the same blocks, it's empty for empty files.
+func blocksKey(fileBlocks *pfs.FileBlocks) string {
+	var parts []string
+	for _, fileBlock := range fileBlocks.FileBlock {
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", fileBlock.Hash, fileBlock.Lower, fileBlock.Upper))
+	}
+	return strings.Join(parts, ",")
+}
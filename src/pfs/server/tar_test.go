@@ -0,0 +1,77 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestWriteTar(t *testing.T) {
+	contents := map[string]string{
+		"dir/a":     "foo",
+		"dir/b":     "bar",
+		"dir/sub/c": "foo",
+		"dir/empty": "",
+		"dir/none":  "",
+	}
+	var fileInfos []*pfs.FileInfo
+	for _, path := range []string{"dir/a", "dir/b", "dir/empty", "dir/none", "dir/sub/c"} {
+		fileInfos = append(fileInfos, &pfs.FileInfo{
+			File:      &pfs.File{Path: path},
+			SizeBytes: uint64(len(contents[path])),
+		})
+	}
+	blocks := func(file *pfs.File) (*pfs.FileBlocks, error) {
+		if contents[file.Path] == "" {
+			return &pfs.FileBlocks{}, nil
+		}
+		// files with the same contents are made of the same block
+		return &pfs.FileBlocks{
+			FileBlock: []*pfs.FileBlock{{Hash: contents[file.Path], Upper: uint64(len(contents[file.Path]))}},
+		}, nil
+	}
+	var reads []string
+	read := func(file *pfs.File, fileBlocks *pfs.FileBlocks, writer io.Writer) error {
+		reads = append(reads, file.Path)
+		for _, fileBlock := range fileBlocks.FileBlock {
+			if _, err := writer.Write([]byte(fileBlock.Hash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// "a" was written to after it was listed, its size comes from its blocks
+	fileInfos[0].SizeBytes = 1
+	var buffer bytes.Buffer
+	require.NoError(t, writeTar(&buffer, true, "dir", fileInfos, blocks, read))
+	require.Equal(t, []string{"dir/a", "dir/b"}, reads)
+
+	gzipReader, err := gzip.NewReader(&buffer)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+		if header.Name == "sub/c" {
+			require.Equal(t, byte(tar.TypeLink), header.Typeflag)
+			require.Equal(t, "a", header.Linkname)
+			continue
+		}
+		require.Equal(t, byte(tar.TypeReg), header.Typeflag)
+		data, err := ioutil.ReadAll(tarReader)
+		require.NoError(t, err)
+		require.Equal(t, contents["dir/"+header.Name], string(data))
+	}
+	require.Equal(t, []string{"a", "b", "empty", "none", "sub/c"}, names)
+}
@@ -0,0 +1,177 @@
+/*
+Package smb exports pfs to Windows clients through samba. pfs is mounted with
+fuse and each of the mount's top level directories, its repos or commit mount
+aliases, is shared. A repo's commits are directories in its share.
+*/
+package smb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"go.pedge.io/protolog"
+)
+
+// refreshInterval is how often the shares are checked for repos which have
+// been created or deleted.
+const refreshInterval = 30 * time.Second
+
+// Config configures the samba which serves a pfs mount.
+type Config struct {
+	// MountPoint is where pfs is mounted.
+	MountPoint string
+	// Workgroup is the Windows workgroup the shares are in.
+	Workgroup string
+	// User is the only user who can connect, samba has to know their
+	// password, see AddUser.
+	User string
+	// ReadOnly makes every share read-only.
+	ReadOnly bool
+}
+
+var configTemplate = template.Must(template.New("smb.conf").Parse(`[global]
+	workgroup = {{.Workgroup}}
+	server string = pachyderm
+	security = user
+	map to guest = never
+	valid users = {{.User}}
+	# pfs checks no permissions, fuse lets root do anything
+	force user = root
+	# pfs has no locks, extended attributes or DOS attributes to keep
+	kernel oplocks = no
+	posix locking = no
+	ea support = no
+	store dos attributes = no
+	unix extensions = no
+	load printers = no
+	printing = bsd
+	printcap name = /dev/null
+	disable spoolss = yes
+{{range .Shares}}
+[{{.}}]
+	path = {{$.MountPoint}}/{{.}}
+	read only = {{if $.ReadOnly}}yes{{else}}no{{end}}
+	browseable = yes
+{{end}}`))
+
+// WriteConfig writes the smb.conf which shares shares.
+func WriteConfig(writer io.Writer, config Config, shares []string) error {
+	return configTemplate.Execute(writer, struct {
+		Config
+		Shares []string
+	}{config, shares})
+}
+
+// Shares returns the shares of the pfs mount at mountPoint, directories
+// whose names samba can't share are left out.
+func Shares(mountPoint string) ([]string, error) {
+	fileInfos, err := ioutil.ReadDir(mountPoint)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() && validShareName(fileInfo.Name()) {
+			result = append(result, fileInfo.Name())
+		}
+	}
+	return result, nil
+}
+
+// Serve runs smbd with config until it exits. The config is written to
+// configPath and rewritten, and smbd reloaded, when the shares change.
+func Serve(config Config, configPath string) error {
+	shares, err := Shares(config.MountPoint)
+	if err != nil {
+		return err
+	}
+	if err := writeConfigFile(configPath, config, shares); err != nil {
+		return err
+	}
+	cmd := exec.Command("smbd", "--foreground", "--log-stdout", "--configfile="+configPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("smbd: %s", err.Error())
+			}
+			return nil
+		case <-ticker.C:
+			newShares, err := Shares(config.MountPoint)
+			if err != nil {
+				protolog.Printf("smb: error listing shares: %s", err.Error())
+				continue
+			}
+			if reflect.DeepEqual(newShares, shares) {
+				continue
+			}
+			if err := writeConfigFile(configPath, config, newShares); err != nil {
+				protolog.Printf("smb: error writing %s: %s", configPath, err.Error())
+				continue
+			}
+			shares = newShares
+			if err := cmd.Process.Signal(syscall.SIGHUP); err != nil {
+				protolog.Printf("smb: error reloading smbd: %s", err.Error())
+			}
+		}
+	}
+}
+
+// AddUser creates user and sets their samba password, if they already exist
+// only the password is set.
+func AddUser(user string, password string) error {
+	if err := exec.Command("id", user).Run(); err != nil {
+		if output, err := exec.Command("useradd", "--no-create-home", "--shell", "/usr/sbin/nologin", user).CombinedOutput(); err != nil {
+			return fmt.Errorf("useradd %s: %s: %s", user, err.Error(), output)
+		}
+	}
+	cmd := exec.Command("smbpasswd", "-s", "-a", user)
+	cmd.Stdin = strings.NewReader(password + "\n" + password + "\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("smbpasswd %s: %s: %s", user, err.Error(), output)
+	}
+	return nil
+}
+
+func writeConfigFile(configPath string, config Config, shares []string) error {
+	var buffer bytes.Buffer
+	if err := WriteConfig(&buffer, config, shares); err != nil {
+		return err
+	}
+	// smbd may reload at any time, so it mustn't see a partial config
+	tmpPath := configPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, buffer.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, configPath)
+}
+
+// validShareName returns true if name can be a share, share names are at
+// most 80 characters and some characters and sections are reserved.
+func validShareName(name string) bool {
+	switch strings.ToLower(name) {
+	case "global", "homes", "printers", "ipc$":
+		return false
+	}
+	return len(name) <= 80 && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `\/[]:|<>+=;,*?"%`)
+}
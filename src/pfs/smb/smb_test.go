@@ -0,0 +1,35 @@
+package smb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestShares(t *testing.T) {
+	mountPoint, err := ioutil.TempDir("", "pachyderm-smb")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(mountPoint)
+	}()
+	for _, name := range []string{"repo", "alias", "global", "a:b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(mountPoint, name), 0755))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(mountPoint, "file"), nil, 0644))
+	shares, err := Shares(mountPoint)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alias", "repo"}, shares)
+}
+
+func TestWriteConfig(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, WriteConfig(&buffer, Config{MountPoint: "/pfs", Workgroup: "WORKGROUP", User: "analyst", ReadOnly: true}, []string{"repo"}))
+	config := buffer.String()
+	require.True(t, strings.Contains(config, "valid users = analyst\n"))
+	require.True(t, strings.Contains(config, "[repo]\n\tpath = /pfs/repo\n\tread only = yes\n"))
+}
@@ -58,7 +58,7 @@ func TestSimple(t *testing.T) {
 
 	doWrites(t, apiClient, repoName, newCommitID)
 
-	err = pfsutil.FinishCommit(apiClient, repoName, newCommitID)
+	_, err = pfsutil.FinishCommit(apiClient, repoName, newCommitID)
 	require.NoError(t, err)
 
 	newCommitInfo, err = pfsutil.InspectCommit(apiClient, repoName, newCommitID)
@@ -107,7 +107,7 @@ func TestBlockListCommits(t *testing.T) {
 
 	baseCommit, err := pfsutil.StartCommit(apiClient, repoName, "")
 	require.NoError(t, err)
-	err = pfsutil.FinishCommit(apiClient, repoName, baseCommit.Id)
+	_, err = pfsutil.FinishCommit(apiClient, repoName, baseCommit.Id)
 	require.NoError(t, err)
 
 	repo := &pfs.Repo{
@@ -151,7 +151,7 @@ func TestBlockListCommits(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		time.Sleep(1)
-		err := pfsutil.FinishCommit(apiClient, repoName, newCommit.Id)
+		_, err := pfsutil.FinishCommit(apiClient, repoName, newCommit.Id)
 		require.NoError(t, err)
 	}()
 	listCommitRequest.Block = true
@@ -212,7 +212,7 @@ func TestMount(t *testing.T) {
 	_, err = pfsutil.PutFile(apiClient, repoName, newCommitID, "big2", 0, bytes.NewReader(bigValue))
 	require.NoError(t, err)
 
-	err = pfsutil.FinishCommit(apiClient, repoName, newCommitID)
+	_, err = pfsutil.FinishCommit(apiClient, repoName, newCommitID)
 	require.NoError(t, err)
 
 	fInfo, err := os.Stat(filepath.Join(directory, repoName, newCommitID, "foo"))
@@ -281,7 +281,7 @@ func TestMountBig(t *testing.T) {
 	}
 	wg.Wait()
 
-	err = pfsutil.FinishCommit(apiClient, repoName, newCommitID)
+	_, err = pfsutil.FinishCommit(apiClient, repoName, newCommitID)
 	require.NoError(t, err)
 
 	wg = sync.WaitGroup{}
@@ -349,7 +349,7 @@ func BenchmarkFuse(b *testing.B) {
 			}(j)
 		}
 		wg.Wait()
-		if err := pfsutil.FinishCommit(apiClient, repoName, newCommitID); err != nil {
+		if _, err := pfsutil.FinishCommit(apiClient, repoName, newCommitID); err != nil {
 			b.Error(err)
 		}
 	}
@@ -0,0 +1,255 @@
+/*
+Package admin serves a server's runtime profiles and recent logs on a port
+which requires a token, and bundles what they serve from a cluster's servers.
+
+The profiles are served with the same paths as net/http/pprof's, but
+net/http/pprof isn't used since it registers itself on http.DefaultServeMux,
+which the trace port serves to anyone.
+*/
+package admin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/logsink"
+)
+
+const (
+	// TokenHeader is the header requests carry the admin token in.
+	TokenHeader = "Authorization"
+	// mutexProfileFraction is the rate mutex contention is sampled at once
+	// the admin port is served, 1 in mutexProfileFraction events is recorded
+	mutexProfileFraction = 5
+	// defaultCPUProfileDuration is how long a cpu profile runs if the
+	// request doesn't say
+	defaultCPUProfileDuration = 30 * time.Second
+)
+
+// Profiles are the profiles, besides the cpu profile, which Bundle collects.
+var Profiles = []string{"heap", "goroutine", "mutex"}
+
+// NewHandler returns an http.Handler which serves profiles to requests whose
+// TokenHeader is "Bearer " followed by token. The cpu profile is served at
+// /debug/pprof/profile?seconds=N and the others at /debug/pprof/NAME. The
+// recent log events, see logsink.WriteRecentEvents, are served at /debug/logs.
+func NewHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/logs", serveLogs)
+	mux.HandleFunc("/debug/pprof/profile", serveCPUProfile)
+	mux.HandleFunc("/debug/pprof/", serveProfile)
+	return &authHandler{token, mux}
+}
+
+// Serve serves NewHandler(token) on port, token must not be empty. Mutex
+// contention is only sampled once Serve is called.
+func Serve(port uint16, token string) error {
+	if token == "" {
+		return fmt.Errorf("admin: can't serve without a token")
+	}
+	runtime.SetMutexProfileFraction(mutexProfileFraction)
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), NewHandler(token))
+}
+
+// Bundle collects a cpu profile lasting duration and Profiles from the admin
+// servers at addresses, which are host:port, and writes them to writer as a
+// gzipped tar with a directory per server. The cpu profiles are collected at
+// the same time. If a server fails its profiles are left out and an error is
+// returned once the others are written.
+func Bundle(writer io.Writer, addresses []string, token string, duration time.Duration) error {
+	type result struct {
+		profiles map[string][]byte
+		err      error
+	}
+	results := make([]result, len(addresses))
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			profiles, err := collect(address, token, duration)
+			results[i] = result{profiles, err}
+		}(i, address)
+	}
+	wg.Wait()
+	files := make(map[string][]byte)
+	var errs []string
+	for i, address := range addresses {
+		if results[i].err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", address, results[i].err.Error()))
+			continue
+		}
+		for name, data := range results[i].profiles {
+			files[fmt.Sprintf("%s/%s.pprof", ServerDir(address), name)] = data
+		}
+	}
+	if err := WriteTarball(writer, files); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("admin: couldn't profile %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Get returns the body of a GET of path, ie "/debug/logs", from the admin
+// server at address.
+func Get(address string, token string, path string) ([]byte, error) {
+	request, err := http.NewRequest("GET", fmt.Sprintf("http://%s%s", address, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(TokenHeader, "Bearer "+token)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// WriteTarball writes files, which are keyed by their path, to writer as a
+// gzipped tar.
+func WriteTarball(writer io.Writer, files map[string][]byte) error {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	now := time.Now()
+	for _, name := range names {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(files[name])),
+			ModTime: now,
+		}); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// ServerDir returns the directory a tarball keeps the files of the server at
+// address in.
+func ServerDir(address string) string {
+	return strings.Replace(address, ":", "_", -1)
+}
+
+type authHandler struct {
+	token   string
+	handler http.Handler
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// an empty token would let in requests with "Bearer "
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte("Bearer "+h.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+func serveLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := logsink.WriteRecentEvents(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func serveCPUProfile(w http.ResponseWriter, r *http.Request) {
+	duration := defaultCPUProfileDuration
+	if seconds := r.FormValue("seconds"); seconds != "" {
+		n, err := strconv.ParseUint(seconds, 10, 64)
+		if err != nil || n == 0 {
+			http.Error(w, fmt.Sprintf("invalid seconds %q", seconds), http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(n) * time.Second
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	// only one cpu profile can run at a time
+	if err := pprof.StartCPUProfile(w); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	time.Sleep(duration)
+	pprof.StopCPUProfile()
+}
+
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	if name == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, profile := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s\t%d\n", profile.Name(), profile.Count())
+		}
+		return
+	}
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		http.Error(w, fmt.Sprintf("unknown profile %s", name), http.StatusNotFound)
+		return
+	}
+	debug, _ := strconv.Atoi(r.FormValue("debug"))
+	if debug == 0 {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if err := profile.WriteTo(w, debug); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// collect returns the profiles of the admin server at address by name.
+func collect(address string, token string, duration time.Duration) (map[string][]byte, error) {
+	// the cpu profile is rounded up to a whole second
+	seconds := int64((duration + time.Second - 1) / time.Second)
+	if seconds == 0 {
+		seconds = 1
+	}
+	result := make(map[string][]byte)
+	data, err := Get(address, token, fmt.Sprintf("/debug/pprof/profile?seconds=%d", seconds))
+	if err != nil {
+		return nil, err
+	}
+	result["cpu"] = data
+	for _, name := range Profiles {
+		data, err := Get(address, token, "/debug/pprof/"+name)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = data
+	}
+	return result, nil
+}
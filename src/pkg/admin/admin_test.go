@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestUnauthorized(t *testing.T) {
+	server := httptest.NewServer(NewHandler("secret"))
+	defer server.Close()
+	response, err := http.Get(server.URL + "/debug/pprof/heap")
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	address := strings.TrimPrefix(server.URL, "http://")
+	_, err = Get(address, "wrong", "/debug/pprof/heap")
+	require.True(t, err != nil)
+	_, err = Get(address, "secret", "/debug/pprof/heap")
+	require.NoError(t, err)
+	_, err = Get(address, "secret", "/debug/logs")
+	require.NoError(t, err)
+	// a handler without a token lets nobody in
+	emptyServer := httptest.NewServer(NewHandler(""))
+	defer emptyServer.Close()
+	_, err = Get(strings.TrimPrefix(emptyServer.URL, "http://"), "", "/debug/pprof/heap")
+	require.True(t, err != nil)
+}
+
+func TestBundle(t *testing.T) {
+	server := httptest.NewServer(NewHandler("secret"))
+	defer server.Close()
+	closed := httptest.NewServer(NewHandler("secret"))
+	closed.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+	closedAddress := strings.TrimPrefix(closed.URL, "http://")
+	var buffer bytes.Buffer
+	err := Bundle(&buffer, []string{address, closedAddress}, "secret", time.Second)
+	require.True(t, err != nil)
+	require.True(t, strings.Contains(err.Error(), closedAddress))
+	gzipReader, err := gzip.NewReader(&buffer)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	dir := strings.Replace(address, ":", "_", -1)
+	require.Equal(t, []string{dir + "/cpu.pprof", dir + "/goroutine.pprof", dir + "/heap.pprof", dir + "/mutex.pprof"}, names)
+}
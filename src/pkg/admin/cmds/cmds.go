@@ -0,0 +1,266 @@
+package cmds
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pfs/pretty"
+	"github.com/pachyderm/pachyderm/src/pkg/admin"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"github.com/spf13/cobra"
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/pkg/cobra"
+	"go.pedge.io/proto/version"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func Cmds(pfsdAddress string, ppsdAddress string, etcdAddress string, namespace string, token string) ([]*cobra.Command, error) {
+	debug := &cobra.Command{
+		Use:   "debug",
+		Short: "Debug the servers in the cluster.",
+		Long:  "Debug the servers in the cluster.",
+	}
+
+	var duration time.Duration
+	var output string
+	var pfsPort int
+	var ppsPort int
+	var addresses []string
+	profile := &cobra.Command{
+		Use:   "profile",
+		Short: "Collect runtime profiles from every server.",
+		Long: `Collect runtime profiles from every server.
+
+A cpu profile lasting --duration, and heap, goroutine and mutex profiles, are
+collected from the admin port of every pfs server and of the pps server, and of
+the servers in --address, and bundled into a gzipped tar with a directory per
+server. The admin ports are only served if ADMIN_TOKEN is set on the servers,
+it has to be set to the same token for pachctl.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			if token == "" {
+				return fmt.Errorf("ADMIN_TOKEN must be set")
+			}
+			pfsAPIClient, err := getPfsAPIClient(pfsdAddress)
+			if err != nil {
+				return err
+			}
+			serverInfos, err := pfsAPIClient.ListServer(context.Background(), google_protobuf.EmptyInstance)
+			if err != nil {
+				return err
+			}
+			adminAddresses, err := getAdminAddresses(serverInfos, pfsPort, ppsdAddress, ppsPort, addresses)
+			if err != nil {
+				return err
+			}
+			file, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = file.Close()
+			}()
+			fmt.Fprintf(os.Stderr, "Profiling %d servers for %s.\n", len(adminAddresses), duration)
+			if err := admin.Bundle(file, adminAddresses, token, duration); err != nil {
+				return err
+			}
+			return file.Close()
+		}),
+	}
+	profile.Flags().DurationVar(&duration, "duration", 30*time.Second, "How long the cpu profile runs for.")
+	profile.Flags().StringVarP(&output, "output", "o", "profile.tar.gz", "The file to write the profiles to.")
+	profile.Flags().IntVar(&pfsPort, "pfs-port", 1150, "The admin port of the pfs servers.")
+	profile.Flags().IntVar(&ppsPort, "pps-port", 1151, "The admin port of the pps server.")
+	profile.Flags().StringSliceVar(&addresses, "address", nil, "Admin addresses of other servers to profile, ie a drive server's.")
+	debug.AddCommand(profile)
+
+	var dumpOutput string
+	var dumpPfsPort int
+	var dumpPpsPort int
+	var dumpAddresses []string
+	dump := &cobra.Command{
+		Use:   "dump",
+		Short: "Collect the state of every server for a bug report.",
+		Long: `Collect the state of every server for a bug report.
+
+The versions of pachctl, pfsd and ppsd, the pfs servers with their shards, the
+shard transfers and rebalances, and the recent slow rpcs are written to a
+gzipped tar along with the recent logs and a goroutine dump of every pfs
+server, the pps server and the servers in --address. The logs and goroutine
+dumps are read from the servers' admin ports, so ADMIN_TOKEN has to be set to
+their token. Whatever can't be collected is listed in errors.txt.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			if token == "" {
+				return fmt.Errorf("ADMIN_TOKEN must be set")
+			}
+			files := make(map[string][]byte)
+			var errs []string
+			addError := func(what string, err error) {
+				errs = append(errs, fmt.Sprintf("%s: %s", what, err.Error()))
+			}
+			versions := &bytes.Buffer{}
+			fmt.Fprintf(versions, "pachctl %s\n", formatVersion(pachyderm.Version))
+			for _, component := range []struct {
+				name    string
+				address string
+			}{{"pfsd", pfsdAddress}, {"ppsd", ppsdAddress}} {
+				version, err := getVersion(component.address)
+				if err != nil {
+					addError(component.name+" version", err)
+					continue
+				}
+				fmt.Fprintf(versions, "%s %s\n", component.name, formatVersion(version))
+			}
+			files["version.txt"] = versions.Bytes()
+			var serverInfos *pfs.ServerInfos
+			pfsAPIClient, err := getPfsAPIClient(pfsdAddress)
+			if err != nil {
+				return err
+			}
+			if serverInfos, err = pfsAPIClient.ListServer(context.Background(), google_protobuf.EmptyInstance); err != nil {
+				addError("servers", err)
+				serverInfos = &pfs.ServerInfos{}
+			} else {
+				files["servers.txt"] = []byte(proto.MarshalTextString(serverInfos))
+			}
+			sharder := shard.NewSharder(discovery.NewEtcdClient(etcdAddress), 0, 0, namespace)
+			if transfers, err := sharder.Transfers(); err != nil {
+				addError("transfers", err)
+			} else {
+				var buffer bytes.Buffer
+				for _, transfer := range transfers {
+					fmt.Fprintln(&buffer, proto.MarshalTextString(transfer))
+				}
+				files["transfers.txt"] = buffer.Bytes()
+			}
+			if reports, err := sharder.RebalanceReports(); err != nil {
+				addError("rebalances", err)
+			} else {
+				var buffer bytes.Buffer
+				for _, report := range reports {
+					fmt.Fprintln(&buffer, proto.MarshalTextString(report))
+				}
+				files["rebalances.txt"] = buffer.Bytes()
+			}
+			if slowOps, err := pfsutil.ListSlowOp(pfsAPIClient); err != nil {
+				addError("slow ops", err)
+			} else {
+				slowOpsBuffer := &bytes.Buffer{}
+				writer := tabwriter.NewWriter(slowOpsBuffer, 20, 1, 3, ' ', 0)
+				pretty.PrintSlowOpHeader(writer)
+				for _, slowOp := range slowOps {
+					pretty.PrintSlowOp(writer, slowOp)
+				}
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+				files["slow_ops.txt"] = slowOpsBuffer.Bytes()
+			}
+			adminAddresses, err := getAdminAddresses(serverInfos, dumpPfsPort, ppsdAddress, dumpPpsPort, dumpAddresses)
+			if err != nil {
+				return err
+			}
+			for _, adminAddress := range adminAddresses {
+				for name, path := range map[string]string{
+					"logs.json":     "/debug/logs",
+					"goroutine.txt": "/debug/pprof/goroutine?debug=2",
+				} {
+					data, err := admin.Get(adminAddress, token, path)
+					if err != nil {
+						addError(fmt.Sprintf("%s %s", adminAddress, name), err)
+						continue
+					}
+					files[admin.ServerDir(adminAddress)+"/"+name] = data
+				}
+			}
+			if len(errs) != 0 {
+				sort.Strings(errs)
+				files["errors.txt"] = []byte(strings.Join(errs, "\n") + "\n")
+				fmt.Fprintf(os.Stderr, "Couldn't collect everything, see errors.txt:\n%s\n", strings.Join(errs, "\n"))
+			}
+			file, err := os.Create(dumpOutput)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = file.Close()
+			}()
+			if err := admin.WriteTarball(file, files); err != nil {
+				return err
+			}
+			return file.Close()
+		}),
+	}
+	dump.Flags().StringVarP(&dumpOutput, "output", "o", "dump.tar.gz", "The file to write the dump to.")
+	dump.Flags().IntVar(&dumpPfsPort, "pfs-port", 1150, "The admin port of the pfs servers.")
+	dump.Flags().IntVar(&dumpPpsPort, "pps-port", 1151, "The admin port of the pps server.")
+	dump.Flags().StringSliceVar(&dumpAddresses, "address", nil, "Admin addresses of other servers to dump, ie a drive server's.")
+	debug.AddCommand(dump)
+
+	return []*cobra.Command{debug}, nil
+}
+
+func getPfsAPIClient(address string) (pfs.APIClient, error) {
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewAPIClient(clientConn), nil
+}
+
+func getVersion(address string) (*protoversion.Version, error) {
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
+	if err != nil {
+		return nil, err
+	}
+	return protoversion.NewAPIClient(clientConn).GetVersion(context.Background(), google_protobuf.EmptyInstance)
+}
+
+func formatVersion(version *protoversion.Version) string {
+	return fmt.Sprintf("%d.%d.%d(%s)", version.Major, version.Minor, version.Micro, version.Additional)
+}
+
+// getAdminAddresses returns the admin addresses of the pfs servers in
+// serverInfos and of the pps server, followed by addresses.
+func getAdminAddresses(serverInfos *pfs.ServerInfos, pfsPort int, ppsdAddress string, ppsPort int, addresses []string) ([]string, error) {
+	var result []string
+	for _, serverInfo := range serverInfos.ServerInfo {
+		if serverInfo.ServerState == nil {
+			continue
+		}
+		address, err := adminAddress(serverInfo.ServerState.Address, pfsPort)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, address)
+	}
+	address, err := adminAddress(ppsdAddress, ppsPort)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, address)
+	return append(result, addresses...), nil
+}
+
+// adminAddress returns the address of the admin port of the server serving
+// grpc at address.
+func adminAddress(address string, port int) (string, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
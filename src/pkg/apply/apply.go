@@ -0,0 +1,365 @@
+/*
+Package apply converges a cluster's repos and pipelines to a directory of
+manifests.
+
+A manifest is a json or yaml file with a single CreateRepoRequest or
+CreatePipelineRequest. A pipeline manifest is the json create-pipeline reads,
+so the output of inspect-pipeline --spec is one, and a repo manifest looks like
+{"repo": {"name": "data"}}.
+*/
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"github.com/pachyderm/pachyderm/src/pps/ppsutil"
+	"golang.org/x/net/context"
+)
+
+var (
+	manifestExts = map[string]bool{
+		".json": true,
+		".yaml": true,
+		".yml":  true,
+	}
+)
+
+// Manifests are the repos and pipelines a cluster should have.
+type Manifests struct {
+	Repos     []*pfs.CreateRepoRequest
+	Pipelines []*pps.CreatePipelineRequest
+}
+
+// ReadManifests reads every .json, .yaml and .yml file under dir as a manifest. A manifest
+// with a "pipeline" field is a pipeline, one with a "repo" field is a repo.
+func ReadManifests(dir string) (*Manifests, error) {
+	manifests := &Manifests{}
+	repoNames := make(map[string]string)
+	pipelineNames := make(map[string]string)
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !manifestExts[filepath.Ext(path)] {
+			return nil
+		}
+		spec, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		data, err := ppsutil.SpecToJSON(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err.Error())
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("%s: %s", path, err.Error())
+		}
+		_, isPipeline := fields["pipeline"]
+		_, isRepo := fields["repo"]
+		switch {
+		case isPipeline:
+			var request pps.CreatePipelineRequest
+			if err := jsonpb.UnmarshalString(string(data), &request); err != nil {
+				return fmt.Errorf("%s: %s", path, err.Error())
+			}
+			if request.Pipeline == nil || request.Pipeline.Name == "" {
+				return fmt.Errorf("%s: pipeline has no name", path)
+			}
+			if other, ok := pipelineNames[request.Pipeline.Name]; ok {
+				return fmt.Errorf("%s: pipeline %s is also in %s", path, request.Pipeline.Name, other)
+			}
+			pipelineNames[request.Pipeline.Name] = path
+			manifests.Pipelines = append(manifests.Pipelines, &request)
+		case isRepo:
+			var request pfs.CreateRepoRequest
+			if err := jsonpb.UnmarshalString(string(data), &request); err != nil {
+				return fmt.Errorf("%s: %s", path, err.Error())
+			}
+			if request.Repo == nil || request.Repo.Name == "" {
+				return fmt.Errorf("%s: repo has no name", path)
+			}
+			if other, ok := repoNames[request.Repo.Name]; ok {
+				return fmt.Errorf("%s: repo %s is also in %s", path, request.Repo.Name, other)
+			}
+			repoNames[request.Repo.Name] = path
+			manifests.Repos = append(manifests.Repos, &request)
+		default:
+			return fmt.Errorf("%s: neither a repo nor a pipeline manifest", path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// Action is a single change Apply makes to the cluster.
+type Action struct {
+	// Verb is "create", "update" or "delete".
+	Verb string
+	// Kind is "repo" or "pipeline".
+	Kind string
+	Name string
+	do   func(ctx context.Context) error
+}
+
+func (a *Action) String() string {
+	return fmt.Sprintf("%s %s %s", a.Verb, a.Kind, a.Name)
+}
+
+// Plan returns the actions which converge the cluster to manifests, in the
+// order they must be applied.
+//
+// Repos are only created and deleted, their data can't be kept across a
+// change to their ttl so an existing repo's ttl is left as is. Only repos an
+// earlier apply created, which are recorded in StateRepo, are deleted, so
+// repos made some other way are left alone, as are the repos pps creates for
+// pipelines and jobs. Pipelines are updated by deleting and recreating them,
+// which keeps their output repo.
+func Plan(
+	ctx context.Context,
+	pfsAPIClient pfs.APIClient,
+	pipelineAPIClient pps.PipelineAPIClient,
+	manifests *Manifests,
+) ([]*Action, error) {
+	repoInfos, err := pfsAPIClient.ListRepo(ctx, &pfs.ListRepoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	pipelineInfos, err := pipelineAPIClient.ListPipeline(ctx, &pps.ListPipelineRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var actions []*Action
+
+	repos := make(map[string]bool)
+	var stateRepoInfo *pfs.RepoInfo
+	for _, repoInfo := range repoInfos.RepoInfo {
+		repos[repoInfo.Repo.Name] = true
+		if repoInfo.Repo.Name == StateRepo {
+			stateRepoInfo = repoInfo
+		}
+	}
+	managed, err := managedRepos(pfsAPIClient, stateRepoInfo)
+	if err != nil {
+		return nil, err
+	}
+	wantRepos := make(map[string]bool)
+	for _, request := range sortedRepos(manifests.Repos) {
+		wantRepos[request.Repo.Name] = true
+		if !repos[request.Repo.Name] {
+			actions = append(actions, createRepo(pfsAPIClient, request))
+		}
+	}
+
+	pipelines := make(map[string]*pps.PipelineInfo)
+	for _, pipelineInfo := range pipelineInfos.PipelineInfo {
+		pipelines[pipelineInfo.Pipeline.Name] = pipelineInfo
+	}
+	wantPipelines := make(map[string]bool)
+	for _, request := range manifests.Pipelines {
+		wantPipelines[request.Pipeline.Name] = true
+	}
+	for _, pipelineInfo := range sortedPipelineInfos(pipelineInfos.PipelineInfo) {
+		if !wantPipelines[pipelineInfo.Pipeline.Name] {
+			actions = append(actions, deletePipeline(pipelineAPIClient, pipelineInfo.Pipeline))
+		}
+	}
+	for _, request := range pipelineOrder(manifests.Pipelines) {
+		pipelineInfo, ok := pipelines[request.Pipeline.Name]
+		switch {
+		case !ok:
+			actions = append(actions, createPipeline(pipelineAPIClient, request))
+		case !pipelineEqual(pipelineInfo, request):
+			actions = append(actions, updatePipeline(pipelineAPIClient, pipelineInfo, request))
+		}
+	}
+
+	var repoNames []string
+	for repoName := range repos {
+		repoNames = append(repoNames, repoName)
+	}
+	sort.Strings(repoNames)
+	for _, repoName := range repoNames {
+		repo := &pfs.Repo{Name: repoName}
+		if deletable(repo, wantRepos[repoName], managed[repoName]) {
+			actions = append(actions, deleteRepo(pfsAPIClient, repo))
+		}
+	}
+	return actions, nil
+}
+
+// deletable returns true if apply should delete repo, want is whether it has a
+// manifest and managed whether an earlier apply created it.
+func deletable(repo *pfs.Repo, want bool, managed bool) bool {
+	// a job's scratch repo has no ttl, it's deleted by the job when it
+	// finishes, so it has to be recognized by name like the rest of pps's repos
+	return !want && managed && !pps.OwnedRepo(repo)
+}
+
+// Apply applies actions in order, it stops at the first which fails.
+func Apply(ctx context.Context, actions []*Action) error {
+	for _, action := range actions {
+		if err := action.do(ctx); err != nil {
+			return fmt.Errorf("%s: %s", action.String(), err.Error())
+		}
+	}
+	return nil
+}
+
+func createRepo(pfsAPIClient pfs.APIClient, request *pfs.CreateRepoRequest) *Action {
+	return &Action{"create", "repo", request.Repo.Name, func(ctx context.Context) error {
+		if _, err := pfsAPIClient.CreateRepo(ctx, request); err != nil {
+			return err
+		}
+		return recordRepo(pfsAPIClient, request.Repo.Name, true)
+	}}
+}
+
+func deleteRepo(pfsAPIClient pfs.APIClient, repo *pfs.Repo) *Action {
+	return &Action{"delete", "repo", repo.Name, func(ctx context.Context) error {
+		// the repo goes to the trash, so a mistake can be undone with restore-repo
+		if _, err := pfsAPIClient.DeleteRepo(ctx, &pfs.DeleteRepoRequest{Repo: repo}); err != nil {
+			return err
+		}
+		return recordRepo(pfsAPIClient, repo.Name, false)
+	}}
+}
+
+func createPipeline(pipelineAPIClient pps.PipelineAPIClient, request *pps.CreatePipelineRequest) *Action {
+	return &Action{"create", "pipeline", request.Pipeline.Name, func(ctx context.Context) error {
+		_, err := pipelineAPIClient.CreatePipeline(ctx, request)
+		return err
+	}}
+}
+
+func updatePipeline(pipelineAPIClient pps.PipelineAPIClient, pipelineInfo *pps.PipelineInfo, request *pps.CreatePipelineRequest) *Action {
+	return &Action{"update", "pipeline", request.Pipeline.Name, func(ctx context.Context) error {
+		// the spec is rejected before anything changes if it isn't valid
+		if err := ppsutil.ValidatePipeline(request); err != nil {
+			return err
+		}
+		if _, err := pipelineAPIClient.DeletePipeline(ctx, &pps.DeletePipelineRequest{Pipeline: request.Pipeline}); err != nil {
+			return err
+		}
+		if _, err := pipelineAPIClient.CreatePipeline(ctx, request); err != nil {
+			// put the old pipeline back rather than leave none
+			if _, rollbackErr := pipelineAPIClient.CreatePipeline(ctx, ppsutil.PipelineSpec(pipelineInfo)); rollbackErr != nil {
+				return fmt.Errorf("%s, and restoring the old pipeline failed: %s", err.Error(), rollbackErr.Error())
+			}
+			return err
+		}
+		return nil
+	}}
+}
+
+func deletePipeline(pipelineAPIClient pps.PipelineAPIClient, pipeline *pps.Pipeline) *Action {
+	return &Action{"delete", "pipeline", pipeline.Name, func(ctx context.Context) error {
+		_, err := pipelineAPIClient.DeletePipeline(ctx, &pps.DeletePipelineRequest{Pipeline: pipeline})
+		return err
+	}}
+}
+
+// pipelineEqual returns true if pipelineInfo was created by request.
+func pipelineEqual(pipelineInfo *pps.PipelineInfo, request *pps.CreatePipelineRequest) bool {
+	spec := ppsutil.PipelineSpec(pipelineInfo)
+	// pps replaces the image of a transform with a build with the image it
+	// built, which a manifest doesn't have
+	if request.Transform != nil && request.Transform.Build != nil && spec.Transform != nil {
+		transform := *spec.Transform
+		transform.Image = request.Transform.Image
+		spec.Transform = &transform
+	}
+	return proto.Equal(spec, request)
+}
+
+// pipelineOrder returns requests sorted so that a pipeline comes after the
+// pipelines whose output it reads.
+func pipelineOrder(requests []*pps.CreatePipelineRequest) []*pps.CreatePipelineRequest {
+	pending := sortedPipelines(requests)
+	done := make(map[string]bool)
+	var result []*pps.CreatePipelineRequest
+	for len(pending) > 0 {
+		var next []*pps.CreatePipelineRequest
+		for _, request := range pending {
+			if dependsOn(request, pending, done) {
+				next = append(next, request)
+				continue
+			}
+			done[request.Pipeline.Name] = true
+			result = append(result, request)
+		}
+		if len(next) == len(pending) {
+			// a cycle, pps will reject it
+			return append(result, next...)
+		}
+		pending = next
+	}
+	return result
+}
+
+// dependsOn returns true if request reads the output of a pipeline in pending
+// which isn't done.
+func dependsOn(request *pps.CreatePipelineRequest, pending []*pps.CreatePipelineRequest, done map[string]bool) bool {
+	for _, input := range request.Inputs {
+		if input.Repo == nil {
+			continue
+		}
+		pipeline, ok := pps.RepoPipeline(input.Repo)
+		if !ok || done[pipeline.Name] || pipeline.Name == request.Pipeline.Name {
+			continue
+		}
+		for _, other := range pending {
+			if other.Pipeline.Name == pipeline.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sortedRepos(requests []*pfs.CreateRepoRequest) []*pfs.CreateRepoRequest {
+	result := append([]*pfs.CreateRepoRequest{}, requests...)
+	sort.Sort(reposByName(result))
+	return result
+}
+
+func sortedPipelines(requests []*pps.CreatePipelineRequest) []*pps.CreatePipelineRequest {
+	result := append([]*pps.CreatePipelineRequest{}, requests...)
+	sort.Sort(pipelinesByName(result))
+	return result
+}
+
+func sortedPipelineInfos(pipelineInfos []*pps.PipelineInfo) []*pps.PipelineInfo {
+	result := append([]*pps.PipelineInfo{}, pipelineInfos...)
+	sort.Sort(pipelineInfosByName(result))
+	return result
+}
+
+type reposByName []*pfs.CreateRepoRequest
+
+func (s reposByName) Len() int               { return len(s) }
+func (s reposByName) Swap(i int, j int)      { s[i], s[j] = s[j], s[i] }
+func (s reposByName) Less(i int, j int) bool { return s[i].Repo.Name < s[j].Repo.Name }
+
+type pipelinesByName []*pps.CreatePipelineRequest
+
+func (s pipelinesByName) Len() int               { return len(s) }
+func (s pipelinesByName) Swap(i int, j int)      { s[i], s[j] = s[j], s[i] }
+func (s pipelinesByName) Less(i int, j int) bool { return s[i].Pipeline.Name < s[j].Pipeline.Name }
+
+type pipelineInfosByName []*pps.PipelineInfo
+
+func (s pipelineInfosByName) Len() int               { return len(s) }
+func (s pipelineInfosByName) Swap(i int, j int)      { s[i], s[j] = s[j], s[i] }
+func (s pipelineInfosByName) Less(i int, j int) bool { return s[i].Pipeline.Name < s[j].Pipeline.Name }
@@ -0,0 +1,58 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"github.com/pachyderm/pachyderm/src/pps"
+)
+
+func TestPipelineOrder(t *testing.T) {
+	requests := []*pps.CreatePipelineRequest{
+		testPipeline("a", "pipeline-c"),
+		testPipeline("b", "data"),
+		testPipeline("c", "pipeline-b"),
+	}
+	var names []string
+	for _, request := range pipelineOrder(requests) {
+		names = append(names, request.Pipeline.Name)
+	}
+	require.Equal(t, []string{"b", "c", "a"}, names)
+}
+
+func TestPipelineEqual(t *testing.T) {
+	request := testPipeline("a", "data")
+	request.Transform = &pps.Transform{Build: &pfs.File{Path: "build"}}
+	pipelineInfo := &pps.PipelineInfo{
+		Pipeline:   request.Pipeline,
+		Transform:  &pps.Transform{Image: "built", Build: request.Transform.Build},
+		Inputs:     request.Inputs,
+		OutputRepo: pps.PipelineRepo(request.Pipeline),
+	}
+	require.True(t, pipelineEqual(pipelineInfo, request))
+	pipelineInfo.Shards = 2
+	require.False(t, pipelineEqual(pipelineInfo, request))
+}
+
+func TestOwnedRepos(t *testing.T) {
+	for _, name := range []string{"pipeline-a", "job-1", "manifest-1", "ingest-a", "scratch-1", "failed-1", "debug-1"} {
+		require.True(t, pps.OwnedRepo(&pfs.Repo{Name: name}))
+	}
+	require.False(t, pps.OwnedRepo(&pfs.Repo{Name: "data"}))
+}
+
+func TestDeletable(t *testing.T) {
+	require.True(t, deletable(&pfs.Repo{Name: "data"}, false, true))
+	require.False(t, deletable(&pfs.Repo{Name: "data"}, true, true))
+	require.False(t, deletable(&pfs.Repo{Name: "data"}, false, false))
+	require.False(t, deletable(pps.JobScratchRepo(&pps.Job{Id: "1"}), false, true))
+	require.False(t, deletable(pps.JobFailedOutputRepo(&pps.Job{Id: "1"}), false, true))
+}
+
+func testPipeline(name string, input string) *pps.CreatePipelineRequest {
+	return &pps.CreatePipelineRequest{
+		Pipeline: &pps.Pipeline{Name: name},
+		Inputs:   []*pps.PipelineInput{{Repo: &pfs.Repo{Name: input}}},
+	}
+}
@@ -0,0 +1,84 @@
+package cmds
+
+import (
+	"fmt"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/apply"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func Cmds(pfsdAddress string, ppsdAddress string) ([]*cobra.Command, error) {
+	var dir string
+	var dryRun bool
+	applyCmd := &cobra.Command{
+		Use:   "apply -f dir",
+		Short: "Converge the cluster's repos and pipelines to a directory of manifests.",
+		Long: `Converge the cluster's repos and pipelines to a directory of manifests.
+
+Every .json, .yaml and .yml file under the directory is a manifest, either a
+pipeline spec as create-pipeline reads it or a repo such as
+{"repo": {"name": "data"}}. Pipelines which aren't in the manifests are
+deleted, as are repos which aren't if an earlier apply created them, pipelines
+whose spec changed are recreated. Deleted repos go to the trash. With --dry-run
+the changes are printed but not made.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			manifests, err := apply.ReadManifests(dir)
+			if err != nil {
+				return err
+			}
+			pfsAPIClient, err := getPfsAPIClient(pfsdAddress)
+			if err != nil {
+				return err
+			}
+			ppsAPIClient, err := getPpsAPIClient(ppsdAddress)
+			if err != nil {
+				return err
+			}
+			actions, err := apply.Plan(context.Background(), pfsAPIClient, ppsAPIClient, manifests)
+			if err != nil {
+				return err
+			}
+			if len(actions) == 0 {
+				fmt.Println("Nothing to do.")
+				return nil
+			}
+			for _, action := range actions {
+				fmt.Println(action.String())
+			}
+			if dryRun {
+				return nil
+			}
+			return apply.Apply(context.Background(), actions)
+		}),
+	}
+	applyCmd.Flags().StringVarP(&dir, "file", "f", ".", "The directory containing the manifests.")
+	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the changes without making them.")
+
+	return []*cobra.Command{applyCmd}, nil
+}
+
+func getPfsAPIClient(address string) (pfs.APIClient, error) {
+	clientConn, err := dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewAPIClient(clientConn), nil
+}
+
+func getPpsAPIClient(address string) (pps.APIClient, error) {
+	clientConn, err := dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return pps.NewAPIClient(clientConn), nil
+}
+
+func dial(address string) (*grpc.ClientConn, error) {
+	return audit.Dial(address)
+}
@@ -0,0 +1,69 @@
+package apply
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+)
+
+const (
+	// StateRepo records the repos apply has created, they're the only repos
+	// it deletes. Each is a file in stateDir named after the repo.
+	StateRepo = "apply-state"
+	stateDir  = "repos"
+)
+
+// managedRepos returns the names of the repos apply has created, stateRepoInfo
+// is the RepoInfo of StateRepo or nil if it doesn't exist.
+func managedRepos(pfsAPIClient pfs.APIClient, stateRepoInfo *pfs.RepoInfo) (map[string]bool, error) {
+	result := make(map[string]bool)
+	if stateRepoInfo == nil || stateRepoInfo.LastCommit == nil {
+		return result, nil
+	}
+	fileInfos, err := pfsutil.ListFile(pfsAPIClient, StateRepo, stateRepoInfo.LastCommit.Id, stateDir, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInfo := range fileInfos {
+		result[path.Base(fileInfo.File.Path)] = true
+	}
+	return result, nil
+}
+
+// recordRepo records in StateRepo that apply created repoName, or if managed
+// is false that it deleted it.
+func recordRepo(pfsAPIClient pfs.APIClient, repoName string, managed bool) error {
+	repoInfo, err := pfsutil.InspectRepo(pfsAPIClient, StateRepo)
+	if err != nil {
+		if err := pfsutil.CreateRepo(pfsAPIClient, StateRepo); err != nil {
+			// another apply may have created it first
+			if repoInfo, err = pfsutil.InspectRepo(pfsAPIClient, StateRepo); err != nil {
+				return err
+			}
+		}
+	}
+	parentID := ""
+	if repoInfo != nil && repoInfo.LastCommit != nil {
+		parentID = repoInfo.LastCommit.Id
+	}
+	commit, err := pfsutil.StartCommit(pfsAPIClient, StateRepo, parentID)
+	if err != nil {
+		return err
+	}
+	filePath := path.Join(stateDir, repoName)
+	if managed {
+		_, err = pfsutil.PutFile(pfsAPIClient, StateRepo, commit.Id, filePath, 0, strings.NewReader(repoName+"\n"))
+	} else {
+		err = pfsutil.DeleteFile(pfsAPIClient, StateRepo, commit.Id, filePath)
+	}
+	if err != nil {
+		_, _ = pfsutil.FinishCommit(pfsAPIClient, StateRepo, commit.Id)
+		return err
+	}
+	if _, err := pfsutil.FinishCommit(pfsAPIClient, StateRepo, commit.Id); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Package audit records who made mutating rpcs, and reads of sensitive repos,
+so that they can be reviewed later. Callers aren't authenticated, they report
+their own identity with PrincipalCredentials, so the log shows who each
+client claimed to be rather than who it was.
+*/
+package audit
+
+import (
+	"os"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// SensitiveReposKey configures which repos have their reads recorded, its
+	// value is a comma separated list of repo names.
+	SensitiveReposKey = "audit_sensitive_repos"
+	// DefaultTTL is how long Events are kept, in seconds, if a Recorder is
+	// given a ttl of 0.
+	DefaultTTL = 30 * 24 * 60 * 60
+)
+
+// Recorder records Events.
+type Recorder interface {
+	// Record records that the principal in ctx called method with request.
+	// repo is the repo the rpc acted on and err is the error it returned.
+	Record(ctx context.Context, method string, repo string, request proto.Message, err error)
+	// Sensitive returns true if reads of repo should be recorded.
+	Sensitive(repo string) bool
+	// SetSensitiveRepos is a config.Setter for SensitiveReposKey.
+	SetSensitiveRepos(value string) error
+}
+
+// NewRecorder returns a Recorder which stores Events in namespace, Events are
+// deleted after ttl seconds, a ttl of 0 keeps them for DefaultTTL. Events are
+// written in the background so that recording doesn't slow rpcs down, they're
+// dropped if too many are waiting to be written.
+func NewRecorder(discoveryClient discovery.Client, namespace string, ttl uint64) Recorder {
+	return newRecorder(discoveryClient, namespace, ttl)
+}
+
+// NewNopRecorder returns a Recorder which doesn't record anything.
+func NewNopRecorder() Recorder {
+	return nopRecorder{}
+}
+
+// Reader reads the Events stored by a Recorder.
+type Reader interface {
+	// ListEvents returns a page of the Events matching request, oldest
+	// first.
+	ListEvents(request *ListEventsRequest) (*Events, error)
+}
+
+// NewReader returns a Reader for the Events stored in namespace by a
+// Recorder with ttl.
+func NewReader(discoveryClient discovery.Client, namespace string, ttl uint64) Reader {
+	return newReader(discoveryClient, namespace, ttl)
+}
+
+// Principal returns who made the rpc with ctx, the common name of the
+// client's TLS certificate. It returns "" if the client didn't present one,
+// which is always the case with servers started by grpcutil.Serve since they
+// don't serve TLS.
+func Principal(ctx context.Context) string {
+	return principal(ctx)
+}
+
+// ReportedPrincipal returns the principal the client that made the rpc with
+// ctx reported with PrincipalCredentials, "" if it didn't. It isn't verified,
+// a client can report anything.
+func ReportedPrincipal(ctx context.Context) string {
+	return reportedPrincipal(ctx)
+}
+
+// NewContext returns a context which Record attributes to principal, as if
+// the client had reported it, for requests which don't arrive over grpc. Don't
+// make rpcs with it, they'd report principal too.
+func NewContext(ctx context.Context, principal string) context.Context {
+	return metadata.NewContext(ctx, metadata.Pairs(principalKey, principal))
+}
+
+// NewPrincipalCredentials returns credentials which report principal with
+// every rpc, use them with grpc.WithPerRPCCredentials.
+func NewPrincipalCredentials(principal string) credentials.Credentials {
+	return principalCredentials(principal)
+}
+
+// Dial dials address the way command line clients do, with grpcutil's
+// DialOptions and opts, and reports the local user as the principal of
+// every rpc.
+func Dial(address string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return grpc.Dial(
+		address,
+		grpcutil.DialOptions(
+			append(
+				opts,
+				grpc.WithInsecure(),
+				grpc.WithPerRPCCredentials(NewPrincipalCredentials(os.Getenv("USER"))),
+			)...,
+		)...,
+	)
+}
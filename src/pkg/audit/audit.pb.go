@@ -0,0 +1,221 @@
+// Code generated by protoc-gen-go.
+// source: pkg/audit/audit.proto
+// DO NOT EDIT!
+
+/*
+Package audit is a generated protocol buffer package.
+
+It is generated from these files:
+	pkg/audit/audit.proto
+
+It has these top-level messages:
+	Event
+	Events
+	ListEventsRequest
+	ExportEventsRequest
+*/
+package audit
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import google_protobuf "go.pedge.io/google-protobuf"
+import pfs "github.com/pachyderm/pachyderm/src/pfs"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// Event records a single rpc.
+type Event struct {
+	Principal string                     `protobuf:"bytes,1,opt,name=principal" json:"principal,omitempty"`
+	Method    string                     `protobuf:"bytes,2,opt,name=method" json:"method,omitempty"`
+	Repo      string                     `protobuf:"bytes,3,opt,name=repo" json:"repo,omitempty"`
+	Request   string                     `protobuf:"bytes,4,opt,name=request" json:"request,omitempty"`
+	Error     string                     `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
+	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,6,opt,name=timestamp" json:"timestamp,omitempty"`
+	// who the client said it was, pachctl reports $USER and pfsd "pfsd".
+	// Callers aren't authenticated so this isn't verified, anyone can claim
+	// to be anyone.
+	ReportedPrincipal string `protobuf:"bytes,7,opt,name=reported_principal" json:"reported_principal,omitempty"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+
+func (m *Event) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+type Events struct {
+	Event         []*Event `protobuf:"bytes,1,rep,name=event" json:"event,omitempty"`
+	NextPageToken string   `protobuf:"bytes,2,opt,name=next_page_token" json:"next_page_token,omitempty"`
+}
+
+func (m *Events) Reset()         { *m = Events{} }
+func (m *Events) String() string { return proto.CompactTextString(m) }
+func (*Events) ProtoMessage()    {}
+
+func (m *Events) GetEvent() []*Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+type ListEventsRequest struct {
+	Principal string                     `protobuf:"bytes,1,opt,name=principal" json:"principal,omitempty"`
+	Repo      string                     `protobuf:"bytes,2,opt,name=repo" json:"repo,omitempty"`
+	From      *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=from" json:"from,omitempty"`
+	To        *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=to" json:"to,omitempty"`
+	PageSize  uint64                     `protobuf:"varint,5,opt,name=page_size" json:"page_size,omitempty"`
+	PageToken string                     `protobuf:"bytes,6,opt,name=page_token" json:"page_token,omitempty"`
+}
+
+func (m *ListEventsRequest) Reset()         { *m = ListEventsRequest{} }
+func (m *ListEventsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListEventsRequest) ProtoMessage()    {}
+
+func (m *ListEventsRequest) GetFrom() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *ListEventsRequest) GetTo() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+type ExportEventsRequest struct {
+	Filter *ListEventsRequest `protobuf:"bytes,1,opt,name=filter" json:"filter,omitempty"`
+	Repo   *pfs.Repo          `protobuf:"bytes,2,opt,name=repo" json:"repo,omitempty"`
+}
+
+func (m *ExportEventsRequest) Reset()         { *m = ExportEventsRequest{} }
+func (m *ExportEventsRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportEventsRequest) ProtoMessage()    {}
+
+func (m *ExportEventsRequest) GetFilter() *ListEventsRequest {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+func (m *ExportEventsRequest) GetRepo() *pfs.Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Event)(nil), "audit.Event")
+	proto.RegisterType((*Events)(nil), "audit.Events")
+	proto.RegisterType((*ListEventsRequest)(nil), "audit.ListEventsRequest")
+	proto.RegisterType((*ExportEventsRequest)(nil), "audit.ExportEventsRequest")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for API service
+
+type APIClient interface {
+	ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*Events, error)
+	// ExportEvents writes the events matching filter to a new commit in repo.
+	ExportEvents(ctx context.Context, in *ExportEventsRequest, opts ...grpc.CallOption) (*pfs.Commit, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) ListEvents(ctx context.Context, in *ListEventsRequest, opts ...grpc.CallOption) (*Events, error) {
+	out := new(Events)
+	err := grpc.Invoke(ctx, "/audit.API/ListEvents", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ExportEvents(ctx context.Context, in *ExportEventsRequest, opts ...grpc.CallOption) (*pfs.Commit, error) {
+	out := new(pfs.Commit)
+	err := grpc.Invoke(ctx, "/audit.API/ExportEvents", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for API service
+
+type APIServer interface {
+	ListEvents(context.Context, *ListEventsRequest) (*Events, error)
+	// ExportEvents writes the events matching filter to a new commit in repo.
+	ExportEvents(context.Context, *ExportEventsRequest) (*pfs.Commit, error)
+}
+
+func RegisterAPIServer(s *grpc.Server, srv APIServer) {
+	s.RegisterService(&_API_serviceDesc, srv)
+}
+
+func _API_ListEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListEvents(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ExportEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ExportEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ExportEvents(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _API_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "audit.API",
+	HandlerType: (*APIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListEvents",
+			Handler:    _API_ListEvents_Handler,
+		},
+		{
+			MethodName: "ExportEvents",
+			Handler:    _API_ExportEvents_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
@@ -0,0 +1,143 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+	"go.pedge.io/proto/time"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func Cmds(address string) ([]*cobra.Command, error) {
+	var principal string
+	var repo string
+	var from string
+	var to string
+	addFilterFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVarP(&principal, "principal", "u", "", "Limit to events made by principal, as verified by its TLS certificate or, without one, as the client reported itself.")
+		cmd.Flags().StringVarP(&repo, "repo", "r", "", "Limit to events which acted on repo.")
+		cmd.Flags().StringVar(&from, "from", "", "Limit to events at or after this RFC3339 time.")
+		cmd.Flags().StringVar(&to, "to", "", "Limit to events before this RFC3339 time.")
+	}
+
+	listAuditEvents := &cobra.Command{
+		Use:   "list-audit-events",
+		Short: "Return the audit events matching a filter.",
+		Long:  "Return the audit events matching a filter, oldest first.",
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			request, err := listEventsRequest(principal, repo, from, to)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprint(writer, "TIME\tPRINCIPAL\tREPORTED\tMETHOD\tREPO\tERROR\t\n")
+			for {
+				events, err := apiClient.ListEvents(context.Background(), request)
+				if err != nil {
+					return err
+				}
+				for _, event := range events.Event {
+					fmt.Fprintf(
+						writer,
+						"%s\t%s\t%s\t%s\t%s\t%s\t\n",
+						prototime.TimestampToTime(event.Timestamp).Format(time.RFC3339),
+						orDash(event.Principal),
+						orDash(event.ReportedPrincipal),
+						event.Method,
+						orDash(event.Repo),
+						orDash(event.Error),
+					)
+				}
+				if events.NextPageToken == "" {
+					break
+				}
+				request.PageToken = events.NextPageToken
+			}
+			return writer.Flush()
+		}),
+	}
+	addFilterFlags(listAuditEvents)
+
+	exportAuditEvents := &cobra.Command{
+		Use:   "export-audit-events repo-name",
+		Short: "Export the audit events matching a filter to a repo.",
+		Long:  "Export the audit events matching a filter to a new commit in a repo, the repo is created if it doesn't exist.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			request, err := listEventsRequest(principal, repo, from, to)
+			if err != nil {
+				return err
+			}
+			commit, err := apiClient.ExportEvents(
+				context.Background(),
+				&audit.ExportEventsRequest{
+					Filter: request,
+					Repo:   &pfs.Repo{Name: args[0]},
+				},
+			)
+			if err != nil {
+				return err
+			}
+			fmt.Println(commit.Id)
+			return nil
+		}),
+	}
+	addFilterFlags(exportAuditEvents)
+
+	var result []*cobra.Command
+	result = append(result, listAuditEvents)
+	result = append(result, exportAuditEvents)
+	return result, nil
+}
+
+func listEventsRequest(principal string, repo string, from string, to string) (*audit.ListEventsRequest, error) {
+	request := &audit.ListEventsRequest{
+		Principal: principal,
+		Repo:      repo,
+	}
+	if from != "" {
+		fromTime, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, err
+		}
+		request.From = prototime.TimeToTimestamp(fromTime)
+	}
+	if to != "" {
+		toTime, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, err
+		}
+		request.To = prototime.TimeToTimestamp(toTime)
+	}
+	return request, nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func getAPIClient(address string) (audit.APIClient, error) {
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewAPIClient(clientConn), nil
+}
@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	principalKey = "principal"
+)
+
+func principal(ctx context.Context) string {
+	if authInfo, ok := credentials.FromContext(ctx); ok {
+		if tlsInfo, ok := authInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+			return tlsInfo.State.PeerCertificates[0].Subject.CommonName
+		}
+	}
+	return ""
+}
+
+func reportedPrincipal(ctx context.Context) string {
+	if md, ok := metadata.FromContext(ctx); ok {
+		if values, ok := md[principalKey]; ok && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+type principalCredentials string
+
+func (p principalCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{principalKey: string(p)}, nil
+}
+
+func (p principalCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"go.pedge.io/proto/time"
+)
+
+const (
+	defaultPageSize = 1000
+	// maxBucketsPerPage bounds how many buckets a page reads, so that a
+	// page of a sparse range still returns quickly.
+	maxBucketsPerPage = 24
+)
+
+type reader struct {
+	discoveryClient discovery.Client
+	namespace       string
+	ttl             uint64
+}
+
+func newReader(discoveryClient discovery.Client, namespace string, ttl uint64) *reader {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	return &reader{
+		discoveryClient,
+		namespace,
+		ttl,
+	}
+}
+
+// ListEvents reads the buckets between request.From, or the oldest Events
+// which haven't expired, and request.To a bucket at a time. The page token is
+// the bucket the next page starts in and how many of the matching Events in it
+// were on earlier pages.
+func (r *reader) ListEvents(request *ListEventsRequest) (*Events, error) {
+	if request == nil {
+		request = &ListEventsRequest{}
+	}
+	pageSize := int(request.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	to := time.Now()
+	if request.To != nil && prototime.TimestampToTime(request.To).Before(to) {
+		to = prototime.TimestampToTime(request.To)
+	}
+	current := bucket(time.Now().Add(-time.Duration(r.ttl) * time.Second))
+	if request.From != nil && prototime.TimestampToTime(request.From).After(current) {
+		current = bucket(prototime.TimestampToTime(request.From))
+	}
+	offset := 0
+	if request.PageToken != "" {
+		var err error
+		current, offset, err = parsePageToken(request.PageToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := &Events{}
+	for i := 0; i < maxBucketsPerPage && current.Before(to); i++ {
+		events, err := r.bucketEvents(current, request)
+		if err != nil {
+			return nil, err
+		}
+		if offset > len(events) {
+			offset = len(events)
+		}
+		events = events[offset:]
+		if remaining := pageSize - len(result.Event); len(events) > remaining {
+			result.Event = append(result.Event, events[:remaining]...)
+			result.NextPageToken = pageToken(current, offset+remaining)
+			return result, nil
+		}
+		result.Event = append(result.Event, events...)
+		current = current.Add(bucketDuration)
+		offset = 0
+	}
+	if current.Before(to) {
+		result.NextPageToken = pageToken(current, 0)
+	}
+	return result, nil
+}
+
+// bucketEvents returns the Events in the bucket starting at start which match
+// request, oldest first.
+func (r *reader) bucketEvents(start time.Time, request *ListEventsRequest) ([]*Event, error) {
+	encodedEvents, err := r.discoveryClient.GetAll(eventDir(r.namespace, start))
+	if err != nil {
+		return nil, err
+	}
+	var events []*Event
+	for _, encodedEvent := range encodedEvents {
+		event := &Event{}
+		if err := jsonpb.UnmarshalString(encodedEvent, event); err != nil {
+			return nil, err
+		}
+		if matches(event, request) {
+			events = append(events, event)
+		}
+	}
+	sortEventsByTimestamp(events)
+	return events, nil
+}
+
+func pageToken(start time.Time, offset int) string {
+	return fmt.Sprintf("%s/%d", start.Format(bucketFormat), offset)
+}
+
+func parsePageToken(token string) (time.Time, int, error) {
+	parts := strings.Split(token, "/")
+	if len(parts) != 2 {
+		return time.Time{}, 0, fmt.Errorf("malformed page token %s", token)
+	}
+	start, err := time.Parse(bucketFormat, parts[0])
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("malformed page token %s: %s", token, err.Error())
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil || offset < 0 {
+		return time.Time{}, 0, fmt.Errorf("malformed page token %s", token)
+	}
+	return start, offset, nil
+}
+
+func matches(event *Event, request *ListEventsRequest) bool {
+	if request == nil {
+		return true
+	}
+	if request.Principal != "" && eventPrincipal(event) != request.Principal {
+		return false
+	}
+	if request.Repo != "" && event.Repo != request.Repo {
+		return false
+	}
+	timestamp := prototime.TimestampToTime(event.Timestamp)
+	if request.From != nil && timestamp.Before(prototime.TimestampToTime(request.From)) {
+		return false
+	}
+	if request.To != nil && !timestamp.Before(prototime.TimestampToTime(request.To)) {
+		return false
+	}
+	return true
+}
+
+// eventPrincipal returns the verified principal of event, or the one its
+// client reported if there isn't one.
+func eventPrincipal(event *Event) string {
+	if event.Principal != "" {
+		return event.Principal
+	}
+	return event.ReportedPrincipal
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// recordBufferSize is how many Events can wait to be written before
+	// Record starts dropping them.
+	recordBufferSize = 1024
+	// Events are stored in a directory per bucketDuration, by their
+	// timestamp, so that they can be read a bucket at a time.
+	bucketDuration = time.Hour
+	bucketFormat   = "2006010215"
+)
+
+var (
+	marshaler = &jsonpb.Marshaler{}
+)
+
+type recorder struct {
+	discoveryClient discovery.Client
+	namespace       string
+	ttl             uint64
+	sensitiveRepos  map[string]bool
+	lock            sync.RWMutex
+	events          chan *Event
+}
+
+func newRecorder(discoveryClient discovery.Client, namespace string, ttl uint64) *recorder {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	recorder := &recorder{
+		discoveryClient,
+		namespace,
+		ttl,
+		make(map[string]bool),
+		sync.RWMutex{},
+		make(chan *Event, recordBufferSize),
+	}
+	go recorder.writeEvents()
+	return recorder
+}
+
+func (r *recorder) Record(ctx context.Context, method string, repo string, request proto.Message, err error) {
+	event := &Event{
+		Principal:         principal(ctx),
+		ReportedPrincipal: reportedPrincipal(ctx),
+		Method:            method,
+		Repo:              repo,
+		Timestamp:         prototime.TimeToTimestamp(time.Now()),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if request != nil {
+		encodedRequest, err := marshaler.MarshalToString(request)
+		if err != nil {
+			protolog.Printf("Error encoding audit request for %s: %s", method, err.Error())
+		}
+		event.Request = encodedRequest
+	}
+	// Recording is best effort, a failure to record shouldn't fail or hold
+	// up the rpc.
+	select {
+	case r.events <- event:
+	default:
+		protolog.Printf("Error recording audit event for %s: %d events are waiting to be written", method, recordBufferSize)
+	}
+}
+
+func (r *recorder) writeEvents() {
+	for event := range r.events {
+		encodedEvent, err := marshaler.MarshalToString(event)
+		if err != nil {
+			protolog.Printf("Error encoding audit event for %s: %s", event.Method, err.Error())
+			continue
+		}
+		if err := r.discoveryClient.CreateInDir(eventDir(r.namespace, prototime.TimestampToTime(event.Timestamp)), encodedEvent, r.ttl); err != nil {
+			protolog.Printf("Error recording audit event %s: %s", encodedEvent, err.Error())
+		}
+	}
+}
+
+func (r *recorder) Sensitive(repo string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.sensitiveRepos[repo]
+}
+
+func (r *recorder) SetSensitiveRepos(value string) error {
+	sensitiveRepos := make(map[string]bool)
+	for _, repo := range strings.Split(value, ",") {
+		if repo != "" {
+			sensitiveRepos[repo] = true
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sensitiveRepos = sensitiveRepos
+	return nil
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Record(ctx context.Context, method string, repo string, request proto.Message, err error) {
+}
+
+func (nopRecorder) Sensitive(repo string) bool {
+	return false
+}
+
+func (nopRecorder) SetSensitiveRepos(value string) error {
+	return nil
+}
+
+// eventDir returns the directory of the bucket of Events at timestamp.
+func eventDir(namespace string, timestamp time.Time) string {
+	return path.Join(namespace, "audit", bucket(timestamp).Format(bucketFormat))
+}
+
+func bucket(timestamp time.Time) time.Time {
+	return timestamp.UTC().Truncate(bucketDuration)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"go.pedge.io/proto/rpclog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// exportPath is the file each export writes its events to, one JSON
+	// encoded Event per line.
+	exportPath = "events"
+)
+
+var (
+	marshaler = &jsonpb.Marshaler{}
+)
+
+type apiServer struct {
+	protorpclog.Logger
+	reader       audit.Reader
+	pfsAPIClient pfs.APIClient
+}
+
+func newAPIServer(reader audit.Reader, pfsAPIClient pfs.APIClient) *apiServer {
+	return &apiServer{
+		protorpclog.NewLogger("audit.API"),
+		reader,
+		pfsAPIClient,
+	}
+}
+
+func (a *apiServer) ListEvents(ctx context.Context, request *audit.ListEventsRequest) (response *audit.Events, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	return a.reader.ListEvents(request)
+}
+
+func (a *apiServer) ExportEvents(ctx context.Context, request *audit.ExportEventsRequest) (response *pfs.Commit, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.Repo == nil {
+		return nil, fmt.Errorf("audit.server: request.Repo cannot be nil")
+	}
+	filter := &audit.ListEventsRequest{}
+	if request.Filter != nil {
+		*filter = *request.Filter
+	}
+	var buffer bytes.Buffer
+	for {
+		events, err := a.reader.ListEvents(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events.Event {
+			encodedEvent, err := marshaler.MarshalToString(event)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buffer, "%s\n", encodedEvent)
+		}
+		if events.NextPageToken == "" {
+			break
+		}
+		filter.PageToken = events.NextPageToken
+	}
+	if _, err := pfsutil.InspectRepo(a.pfsAPIClient, request.Repo.Name); err != nil {
+		if err := pfsutil.CreateRepo(a.pfsAPIClient, request.Repo.Name); err != nil {
+			return nil, err
+		}
+	}
+	// Each export is its own commit, with no parent, so the events in a
+	// commit are exactly the ones that matched that export's filter.
+	commit, err := pfsutil.StartCommit(a.pfsAPIClient, request.Repo.Name, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pfsutil.PutFile(a.pfsAPIClient, commit.Repo.Name, commit.Id, exportPath, 0, &buffer); err != nil {
+		return nil, err
+	}
+	if _, err := pfsutil.FinishCommit(a.pfsAPIClient, commit.Repo.Name, commit.Id); err != nil {
+		return nil, err
+	}
+	return commit, nil
+}
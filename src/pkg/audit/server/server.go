@@ -0,0 +1,16 @@
+package server
+
+import (
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+)
+
+type APIServer interface {
+	audit.APIServer
+}
+
+// NewAPIServer returns a new APIServer which serves the Events read by
+// reader and exports them to pfs using pfsAPIClient.
+func NewAPIServer(reader audit.Reader, pfsAPIClient pfs.APIClient) APIServer {
+	return newAPIServer(reader, pfsAPIClient)
+}
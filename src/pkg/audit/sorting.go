@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"sort"
+
+	"go.pedge.io/proto/time"
+)
+
+func sortEventsByTimestamp(s []*Event) {
+	sort.Sort(eventsByTimestamp(s))
+}
+
+type eventsByTimestamp []*Event
+
+func (s eventsByTimestamp) Len() int          { return len(s) }
+func (s eventsByTimestamp) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s eventsByTimestamp) Less(i int, j int) bool {
+	return prototime.TimestampToTime(s[i].Timestamp).Before(prototime.TimestampToTime(s[j].Timestamp))
+}
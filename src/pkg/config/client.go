@@ -0,0 +1,48 @@
+package config
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+)
+
+type client struct {
+	discoveryClient discovery.Client
+	namespace       string
+}
+
+func newClient(discoveryClient discovery.Client, namespace string) *client {
+	return &client{
+		discoveryClient,
+		namespace,
+	}
+}
+
+func (c *client) Get(key string) (string, error) {
+	return c.discoveryClient.Get(c.configKey(key))
+}
+
+func (c *client) GetAll() (map[string]string, error) {
+	encodedConfig, err := c.discoveryClient.GetAll(configDir(c.namespace))
+	if err != nil {
+		return nil, err
+	}
+	config := make(map[string]string)
+	for key, value := range encodedConfig {
+		config[strings.TrimPrefix(key, configDir(c.namespace)+"/")] = value
+	}
+	return config, nil
+}
+
+func (c *client) Set(key string, value string) error {
+	return c.discoveryClient.Set(c.configKey(key), value, 0)
+}
+
+func (c *client) Delete(key string) error {
+	return c.discoveryClient.Delete(c.configKey(key))
+}
+
+func (c *client) configKey(key string) string {
+	return path.Join(configDir(c.namespace), key)
+}
@@ -0,0 +1,93 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pachyderm/pachyderm/src/pfs/drive/obj"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/config"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"github.com/pachyderm/pachyderm/src/pkg/feature"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+)
+
+func Cmds(etcdAddress string, namespace string) ([]*cobra.Command, error) {
+	getConfig := &cobra.Command{
+		Use:   "get-config key",
+		Short: "Return the value of a runtime config key.",
+		Long:  "Return the value of a runtime config key.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			value, err := getClient(etcdAddress, namespace).Get(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(value)
+			return nil
+		}),
+	}
+
+	setConfig := &cobra.Command{
+		Use:   "set-config key value",
+		Short: "Set a runtime config key, servers apply the change without restarting.",
+		Long: fmt.Sprintf(`Set a runtime config key, servers apply the change without restarting.
+
+Keys:
+  %s, one of debug, info, warn or error.
+  %s, a comma separated list of repos whose reads are audited.
+  %s, the bytes per second each pfsd may replicate at, 0 is unlimited.
+  %s, the longest an internal rpc may take such as 30s, 0 is unlimited.
+  %s, a comma separated list of feature=mode, mode is on, off or opt-in.`,
+			config.LogLevelKey, audit.SensitiveReposKey, obj.ReplicationRateKey, grpcutil.RPCTimeoutKey, feature.FeaturesKey),
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			return getClient(etcdAddress, namespace).Set(args[0], args[1])
+		}),
+	}
+
+	deleteConfig := &cobra.Command{
+		Use:   "delete-config key",
+		Short: "Delete a runtime config key, resetting it to its default.",
+		Long:  "Delete a runtime config key, resetting it to its default.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			return getClient(etcdAddress, namespace).Delete(args[0])
+		}),
+	}
+
+	listConfig := &cobra.Command{
+		Use:   "list-config",
+		Short: "Return all runtime config keys.",
+		Long:  "Return all runtime config keys.",
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			values, err := getClient(etcdAddress, namespace).GetAll()
+			if err != nil {
+				return err
+			}
+			var keys []string
+			for key := range values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprint(writer, "KEY\tVALUE\t\n")
+			for _, key := range keys {
+				fmt.Fprintf(writer, "%s\t%s\t\n", key, values[key])
+			}
+			return writer.Flush()
+		}),
+	}
+
+	var result []*cobra.Command
+	result = append(result, getConfig)
+	result = append(result, setConfig)
+	result = append(result, deleteConfig)
+	result = append(result, listConfig)
+	return result, nil
+}
+
+func getClient(etcdAddress string, namespace string) config.Client {
+	return config.NewClient(discovery.NewEtcdClient(etcdAddress), namespace)
+}
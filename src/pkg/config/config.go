@@ -0,0 +1,53 @@
+/*
+Package config stores runtime configuration in a discovery directory so that
+it can be changed without restarting servers.
+*/
+package config
+
+import (
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+)
+
+const (
+	// LogLevelKey configures the protolog level, its value is a level name
+	// such as "debug" or "info".
+	LogLevelKey = "log_level"
+)
+
+// Setter applies a new value for a config key.
+// Setters are called with "" when a key is deleted and should go back to
+// their default.
+type Setter func(value string) error
+
+// Watcher applies config changes as they're made.
+type Watcher interface {
+	// Register registers setter to be called with the value of key whenever
+	// it changes. Register must be called before Watch.
+	Register(key string, setter Setter)
+	// Watch calls the registered Setters with the current config and then
+	// with every change to it. Watch blocks until cancel is closed.
+	Watch(cancel chan bool) error
+}
+
+// NewWatcher returns a new Watcher which watches the config in namespace.
+func NewWatcher(discoveryClient discovery.Client, namespace string) Watcher {
+	return newWatcher(discoveryClient, namespace)
+}
+
+// Client reads and writes config keys.
+type Client interface {
+	Get(key string) (string, error)
+	GetAll() (map[string]string, error)
+	Set(key string, value string) error
+	Delete(key string) error
+}
+
+// NewClient returns a new Client for the config in namespace.
+func NewClient(discoveryClient discovery.Client, namespace string) Client {
+	return newClient(discoveryClient, namespace)
+}
+
+// SetLogLevel is a Setter for LogLevelKey.
+func SetLogLevel(value string) error {
+	return setLogLevel(value)
+}
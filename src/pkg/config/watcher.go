@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"go.pedge.io/protolog"
+)
+
+type watcher struct {
+	discoveryClient discovery.Client
+	namespace       string
+	setters         map[string]Setter
+}
+
+func newWatcher(discoveryClient discovery.Client, namespace string) *watcher {
+	return &watcher{
+		discoveryClient,
+		namespace,
+		make(map[string]Setter),
+	}
+}
+
+func (w *watcher) Register(key string, setter Setter) {
+	w.setters[key] = setter
+}
+
+func (w *watcher) Watch(cancel chan bool) error {
+	oldConfig := make(map[string]string)
+	err := w.discoveryClient.WatchAll(
+		configDir(w.namespace),
+		cancel,
+		func(encodedConfig map[string]string) error {
+			config := make(map[string]string)
+			for key, value := range encodedConfig {
+				config[path.Base(key)] = value
+			}
+			for key, setter := range w.setters {
+				value := config[key]
+				if oldValue, ok := oldConfig[key]; ok && oldValue == value {
+					continue
+				}
+				// A bad value shouldn't stop us from applying the rest of
+				// the config, it's logged and the old value is kept.
+				if err := setter(value); err != nil {
+					protolog.Printf("Error setting config %s to %s: %s", key, value, err.Error())
+					continue
+				}
+				oldConfig[key] = value
+			}
+			return nil
+		},
+	)
+	if err == discovery.ErrCancelled {
+		return nil
+	}
+	return err
+}
+
+func setLogLevel(value string) error {
+	if value == "" {
+		// protolog defaults to info
+		protolog.SetLevel(protolog.Level_LEVEL_INFO)
+		return nil
+	}
+	level, ok := protolog.Level_value["LEVEL_"+strings.ToUpper(value)]
+	if !ok {
+		return fmt.Errorf("unknown log level %s", value)
+	}
+	protolog.SetLevel(protolog.Level(level))
+	return nil
+}
+
+func configDir(namespace string) string {
+	return fmt.Sprintf("%s/config", namespace)
+}
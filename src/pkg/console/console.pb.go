@@ -0,0 +1,425 @@
+// Code generated by protoc-gen-go.
+// source: pkg/console/console.proto
+// DO NOT EDIT!
+
+/*
+Package console is a generated protocol buffer package.
+
+It is generated from these files:
+	pkg/console/console.proto
+
+It has these top-level messages:
+	ListReposRequest
+	RepoSummary
+	RepoSummaries
+	ListPipelinesRequest
+	JobCounts
+	PipelineSummary
+	PipelineSummaries
+	ListJobsRequest
+	JobSummaries
+	GetDAGRequest
+	DAGNode
+	DAGEdge
+	DAG
+*/
+package console
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import _ "github.com/gengo/grpc-gateway/third_party/googleapis/google/api"
+import pfs "github.com/pachyderm/pachyderm/src/pfs"
+import pachyderm_pps "github.com/pachyderm/pachyderm/src/pps"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type DAGNodeType int32
+
+const (
+	DAGNodeType_DAG_NODE_TYPE_REPO     DAGNodeType = 0
+	DAGNodeType_DAG_NODE_TYPE_PIPELINE DAGNodeType = 1
+)
+
+var DAGNodeType_name = map[int32]string{
+	0: "DAG_NODE_TYPE_REPO",
+	1: "DAG_NODE_TYPE_PIPELINE",
+}
+var DAGNodeType_value = map[string]int32{
+	"DAG_NODE_TYPE_REPO":     0,
+	"DAG_NODE_TYPE_PIPELINE": 1,
+}
+
+func (x DAGNodeType) String() string {
+	return proto.EnumName(DAGNodeType_name, int32(x))
+}
+
+type ListReposRequest struct {
+	Search    string `protobuf:"bytes,1,opt,name=search" json:"search,omitempty"`
+	PageSize  uint32 `protobuf:"varint,2,opt,name=page_size" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,3,opt,name=page_token" json:"page_token,omitempty"`
+}
+
+func (m *ListReposRequest) Reset()         { *m = ListReposRequest{} }
+func (m *ListReposRequest) String() string { return proto.CompactTextString(m) }
+func (*ListReposRequest) ProtoMessage()    {}
+
+type RepoSummary struct {
+	RepoInfo  *pfs.RepoInfo   `protobuf:"bytes,1,opt,name=repo_info" json:"repo_info,omitempty"`
+	Head      *pfs.CommitInfo `protobuf:"bytes,2,opt,name=head" json:"head,omitempty"`
+	Producer  string          `protobuf:"bytes,3,opt,name=producer" json:"producer,omitempty"`
+	Consumers []string        `protobuf:"bytes,4,rep,name=consumers" json:"consumers,omitempty"`
+}
+
+func (m *RepoSummary) Reset()         { *m = RepoSummary{} }
+func (m *RepoSummary) String() string { return proto.CompactTextString(m) }
+func (*RepoSummary) ProtoMessage()    {}
+
+func (m *RepoSummary) GetRepoInfo() *pfs.RepoInfo {
+	if m != nil {
+		return m.RepoInfo
+	}
+	return nil
+}
+
+func (m *RepoSummary) GetHead() *pfs.CommitInfo {
+	if m != nil {
+		return m.Head
+	}
+	return nil
+}
+
+type RepoSummaries struct {
+	RepoSummary   []*RepoSummary `protobuf:"bytes,1,rep,name=repo_summary" json:"repo_summary,omitempty"`
+	NextPageToken string         `protobuf:"bytes,2,opt,name=next_page_token" json:"next_page_token,omitempty"`
+	Total         uint64         `protobuf:"varint,3,opt,name=total" json:"total,omitempty"`
+}
+
+func (m *RepoSummaries) Reset()         { *m = RepoSummaries{} }
+func (m *RepoSummaries) String() string { return proto.CompactTextString(m) }
+func (*RepoSummaries) ProtoMessage()    {}
+
+func (m *RepoSummaries) GetRepoSummary() []*RepoSummary {
+	if m != nil {
+		return m.RepoSummary
+	}
+	return nil
+}
+
+type ListPipelinesRequest struct {
+	Search    string `protobuf:"bytes,1,opt,name=search" json:"search,omitempty"`
+	PageSize  uint32 `protobuf:"varint,2,opt,name=page_size" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,3,opt,name=page_token" json:"page_token,omitempty"`
+}
+
+func (m *ListPipelinesRequest) Reset()         { *m = ListPipelinesRequest{} }
+func (m *ListPipelinesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPipelinesRequest) ProtoMessage()    {}
+
+type JobCounts struct {
+	Running uint64 `protobuf:"varint,1,opt,name=running" json:"running,omitempty"`
+	Failure uint64 `protobuf:"varint,2,opt,name=failure" json:"failure,omitempty"`
+	Success uint64 `protobuf:"varint,3,opt,name=success" json:"success,omitempty"`
+	Queued  uint64 `protobuf:"varint,4,opt,name=queued" json:"queued,omitempty"`
+}
+
+func (m *JobCounts) Reset()         { *m = JobCounts{} }
+func (m *JobCounts) String() string { return proto.CompactTextString(m) }
+func (*JobCounts) ProtoMessage()    {}
+
+type PipelineSummary struct {
+	PipelineInfo *pachyderm_pps.PipelineInfo `protobuf:"bytes,1,opt,name=pipeline_info" json:"pipeline_info,omitempty"`
+	LastJob      *pachyderm_pps.JobInfo      `protobuf:"bytes,2,opt,name=last_job" json:"last_job,omitempty"`
+	JobCounts    *JobCounts                  `protobuf:"bytes,3,opt,name=job_counts" json:"job_counts,omitempty"`
+}
+
+func (m *PipelineSummary) Reset()         { *m = PipelineSummary{} }
+func (m *PipelineSummary) String() string { return proto.CompactTextString(m) }
+func (*PipelineSummary) ProtoMessage()    {}
+
+func (m *PipelineSummary) GetPipelineInfo() *pachyderm_pps.PipelineInfo {
+	if m != nil {
+		return m.PipelineInfo
+	}
+	return nil
+}
+
+func (m *PipelineSummary) GetLastJob() *pachyderm_pps.JobInfo {
+	if m != nil {
+		return m.LastJob
+	}
+	return nil
+}
+
+func (m *PipelineSummary) GetJobCounts() *JobCounts {
+	if m != nil {
+		return m.JobCounts
+	}
+	return nil
+}
+
+type PipelineSummaries struct {
+	PipelineSummary []*PipelineSummary `protobuf:"bytes,1,rep,name=pipeline_summary" json:"pipeline_summary,omitempty"`
+	NextPageToken   string             `protobuf:"bytes,2,opt,name=next_page_token" json:"next_page_token,omitempty"`
+	Total           uint64             `protobuf:"varint,3,opt,name=total" json:"total,omitempty"`
+}
+
+func (m *PipelineSummaries) Reset()         { *m = PipelineSummaries{} }
+func (m *PipelineSummaries) String() string { return proto.CompactTextString(m) }
+func (*PipelineSummaries) ProtoMessage()    {}
+
+func (m *PipelineSummaries) GetPipelineSummary() []*PipelineSummary {
+	if m != nil {
+		return m.PipelineSummary
+	}
+	return nil
+}
+
+type ListJobsRequest struct {
+	Pipeline  string `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
+	State     string `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	Search    string `protobuf:"bytes,3,opt,name=search" json:"search,omitempty"`
+	PageSize  uint32 `protobuf:"varint,4,opt,name=page_size" json:"page_size,omitempty"`
+	PageToken string `protobuf:"bytes,5,opt,name=page_token" json:"page_token,omitempty"`
+}
+
+func (m *ListJobsRequest) Reset()         { *m = ListJobsRequest{} }
+func (m *ListJobsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListJobsRequest) ProtoMessage()    {}
+
+type JobSummaries struct {
+	JobInfo       []*pachyderm_pps.JobInfo `protobuf:"bytes,1,rep,name=job_info" json:"job_info,omitempty"`
+	NextPageToken string                   `protobuf:"bytes,2,opt,name=next_page_token" json:"next_page_token,omitempty"`
+	Total         uint64                   `protobuf:"varint,3,opt,name=total" json:"total,omitempty"`
+}
+
+func (m *JobSummaries) Reset()         { *m = JobSummaries{} }
+func (m *JobSummaries) String() string { return proto.CompactTextString(m) }
+func (*JobSummaries) ProtoMessage()    {}
+
+func (m *JobSummaries) GetJobInfo() []*pachyderm_pps.JobInfo {
+	if m != nil {
+		return m.JobInfo
+	}
+	return nil
+}
+
+type GetDAGRequest struct {
+}
+
+func (m *GetDAGRequest) Reset()         { *m = GetDAGRequest{} }
+func (m *GetDAGRequest) String() string { return proto.CompactTextString(m) }
+func (*GetDAGRequest) ProtoMessage()    {}
+
+type DAGNode struct {
+	Id    string      `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Type  DAGNodeType `protobuf:"varint,2,opt,name=type,enum=console.DAGNodeType" json:"type,omitempty"`
+	Name  string      `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	State string      `protobuf:"bytes,4,opt,name=state" json:"state,omitempty"`
+}
+
+func (m *DAGNode) Reset()         { *m = DAGNode{} }
+func (m *DAGNode) String() string { return proto.CompactTextString(m) }
+func (*DAGNode) ProtoMessage()    {}
+
+// DAGEdge is data flowing from a repo to a pipeline or a pipeline to a repo.
+type DAGEdge struct {
+	From string `protobuf:"bytes,1,opt,name=from" json:"from,omitempty"`
+	To   string `protobuf:"bytes,2,opt,name=to" json:"to,omitempty"`
+}
+
+func (m *DAGEdge) Reset()         { *m = DAGEdge{} }
+func (m *DAGEdge) String() string { return proto.CompactTextString(m) }
+func (*DAGEdge) ProtoMessage()    {}
+
+type DAG struct {
+	Node []*DAGNode `protobuf:"bytes,1,rep,name=node" json:"node,omitempty"`
+	Edge []*DAGEdge `protobuf:"bytes,2,rep,name=edge" json:"edge,omitempty"`
+}
+
+func (m *DAG) Reset()         { *m = DAG{} }
+func (m *DAG) String() string { return proto.CompactTextString(m) }
+func (*DAG) ProtoMessage()    {}
+
+func (m *DAG) GetNode() []*DAGNode {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+func (m *DAG) GetEdge() []*DAGEdge {
+	if m != nil {
+		return m.Edge
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ListReposRequest)(nil), "console.ListReposRequest")
+	proto.RegisterType((*RepoSummary)(nil), "console.RepoSummary")
+	proto.RegisterType((*RepoSummaries)(nil), "console.RepoSummaries")
+	proto.RegisterType((*ListPipelinesRequest)(nil), "console.ListPipelinesRequest")
+	proto.RegisterType((*JobCounts)(nil), "console.JobCounts")
+	proto.RegisterType((*PipelineSummary)(nil), "console.PipelineSummary")
+	proto.RegisterType((*PipelineSummaries)(nil), "console.PipelineSummaries")
+	proto.RegisterType((*ListJobsRequest)(nil), "console.ListJobsRequest")
+	proto.RegisterType((*JobSummaries)(nil), "console.JobSummaries")
+	proto.RegisterType((*GetDAGRequest)(nil), "console.GetDAGRequest")
+	proto.RegisterType((*DAGNode)(nil), "console.DAGNode")
+	proto.RegisterType((*DAGEdge)(nil), "console.DAGEdge")
+	proto.RegisterType((*DAG)(nil), "console.DAG")
+	proto.RegisterEnum("console.DAGNodeType", DAGNodeType_name, DAGNodeType_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for API service
+
+type APIClient interface {
+	ListRepos(ctx context.Context, in *ListReposRequest, opts ...grpc.CallOption) (*RepoSummaries, error)
+	ListPipelines(ctx context.Context, in *ListPipelinesRequest, opts ...grpc.CallOption) (*PipelineSummaries, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*JobSummaries, error)
+	// GetDAG returns the graph of repos and the pipelines between them.
+	GetDAG(ctx context.Context, in *GetDAGRequest, opts ...grpc.CallOption) (*DAG, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) ListRepos(ctx context.Context, in *ListReposRequest, opts ...grpc.CallOption) (*RepoSummaries, error) {
+	out := new(RepoSummaries)
+	err := grpc.Invoke(ctx, "/console.API/ListRepos", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListPipelines(ctx context.Context, in *ListPipelinesRequest, opts ...grpc.CallOption) (*PipelineSummaries, error) {
+	out := new(PipelineSummaries)
+	err := grpc.Invoke(ctx, "/console.API/ListPipelines", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*JobSummaries, error) {
+	out := new(JobSummaries)
+	err := grpc.Invoke(ctx, "/console.API/ListJobs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) GetDAG(ctx context.Context, in *GetDAGRequest, opts ...grpc.CallOption) (*DAG, error) {
+	out := new(DAG)
+	err := grpc.Invoke(ctx, "/console.API/GetDAG", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for API service
+
+type APIServer interface {
+	ListRepos(context.Context, *ListReposRequest) (*RepoSummaries, error)
+	ListPipelines(context.Context, *ListPipelinesRequest) (*PipelineSummaries, error)
+	ListJobs(context.Context, *ListJobsRequest) (*JobSummaries, error)
+	// GetDAG returns the graph of repos and the pipelines between them.
+	GetDAG(context.Context, *GetDAGRequest) (*DAG, error)
+}
+
+func RegisterAPIServer(s *grpc.Server, srv APIServer) {
+	s.RegisterService(&_API_serviceDesc, srv)
+}
+
+func _API_ListRepos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListReposRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListRepos(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListPipelines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListPipelinesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListPipelines(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).ListJobs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_GetDAG_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(GetDAGRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).GetDAG(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _API_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "console.API",
+	HandlerType: (*APIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRepos",
+			Handler:    _API_ListRepos_Handler,
+		},
+		{
+			MethodName: "ListPipelines",
+			Handler:    _API_ListPipelines_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _API_ListJobs_Handler,
+		},
+		{
+			MethodName: "GetDAG",
+			Handler:    _API_GetDAG_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
@@ -0,0 +1,183 @@
+// Code generated by protoc-gen-grpc-gateway
+// source: pkg/console/console.proto
+// DO NOT EDIT!
+
+/*
+Package console is a reverse proxy.
+
+It translates gRPC into RESTful JSON APIs.
+*/
+package console
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gengo/grpc-gateway/runtime"
+	"github.com/gengo/grpc-gateway/utilities"
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var _ codes.Code
+var _ io.Reader
+var _ = runtime.String
+var _ = json.Marshal
+var _ = utilities.PascalFromSnake
+
+var (
+	filter_API_ListRepos_0 = &utilities.DoubleArray{Encoding: map[string]int{}, Base: []int(nil), Check: []int(nil)}
+)
+
+func request_API_ListRepos_0(ctx context.Context, client APIClient, req *http.Request, pathParams map[string]string) (proto.Message, error) {
+	var protoReq ListReposRequest
+
+	if err := runtime.PopulateQueryParameters(&protoReq, req.URL.Query(), filter_API_ListRepos_0); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	return client.ListRepos(ctx, &protoReq)
+}
+
+var (
+	filter_API_ListPipelines_0 = &utilities.DoubleArray{Encoding: map[string]int{}, Base: []int(nil), Check: []int(nil)}
+)
+
+func request_API_ListPipelines_0(ctx context.Context, client APIClient, req *http.Request, pathParams map[string]string) (proto.Message, error) {
+	var protoReq ListPipelinesRequest
+
+	if err := runtime.PopulateQueryParameters(&protoReq, req.URL.Query(), filter_API_ListPipelines_0); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	return client.ListPipelines(ctx, &protoReq)
+}
+
+var (
+	filter_API_ListJobs_0 = &utilities.DoubleArray{Encoding: map[string]int{}, Base: []int(nil), Check: []int(nil)}
+)
+
+func request_API_ListJobs_0(ctx context.Context, client APIClient, req *http.Request, pathParams map[string]string) (proto.Message, error) {
+	var protoReq ListJobsRequest
+
+	if err := runtime.PopulateQueryParameters(&protoReq, req.URL.Query(), filter_API_ListJobs_0); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	return client.ListJobs(ctx, &protoReq)
+}
+
+var (
+	filter_API_GetDAG_0 = &utilities.DoubleArray{Encoding: map[string]int{}, Base: []int(nil), Check: []int(nil)}
+)
+
+func request_API_GetDAG_0(ctx context.Context, client APIClient, req *http.Request, pathParams map[string]string) (proto.Message, error) {
+	var protoReq GetDAGRequest
+
+	if err := runtime.PopulateQueryParameters(&protoReq, req.URL.Query(), filter_API_GetDAG_0); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	return client.GetDAG(ctx, &protoReq)
+}
+
+// RegisterAPIHandlerFromEndpoint is same as RegisterAPIHandler but
+// automatically dials to "endpoint" and closes the connection when "ctx" gets done.
+func RegisterAPIHandlerFromEndpoint(ctx context.Context, mux *runtime.ServeMux, endpoint string) (err error) {
+	conn, err := grpc.Dial(endpoint, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if cerr := conn.Close(); cerr != nil {
+				glog.Errorf("Failed to close conn to %s: %v", endpoint, cerr)
+			}
+			return
+		}
+		go func() {
+			<-ctx.Done()
+			if cerr := conn.Close(); cerr != nil {
+				glog.Errorf("Failed to close conn to %s: %v", endpoint, cerr)
+			}
+		}()
+	}()
+
+	return RegisterAPIHandler(ctx, mux, conn)
+}
+
+// RegisterAPIHandler registers the http handlers for service API to "mux".
+// The handlers forward requests to the grpc endpoint over "conn".
+func RegisterAPIHandler(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error {
+	client := NewAPIClient(conn)
+
+	mux.Handle("GET", pattern_API_ListRepos_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		resp, err := request_API_ListRepos_0(runtime.AnnotateContext(ctx, req), client, req, pathParams)
+		if err != nil {
+			runtime.HTTPError(ctx, w, err)
+			return
+		}
+
+		forward_API_ListRepos_0(ctx, w, req, resp, mux.GetForwardResponseOptions()...)
+
+	})
+
+	mux.Handle("GET", pattern_API_ListPipelines_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		resp, err := request_API_ListPipelines_0(runtime.AnnotateContext(ctx, req), client, req, pathParams)
+		if err != nil {
+			runtime.HTTPError(ctx, w, err)
+			return
+		}
+
+		forward_API_ListPipelines_0(ctx, w, req, resp, mux.GetForwardResponseOptions()...)
+
+	})
+
+	mux.Handle("GET", pattern_API_ListJobs_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		resp, err := request_API_ListJobs_0(runtime.AnnotateContext(ctx, req), client, req, pathParams)
+		if err != nil {
+			runtime.HTTPError(ctx, w, err)
+			return
+		}
+
+		forward_API_ListJobs_0(ctx, w, req, resp, mux.GetForwardResponseOptions()...)
+
+	})
+
+	mux.Handle("GET", pattern_API_GetDAG_0, func(w http.ResponseWriter, req *http.Request, pathParams map[string]string) {
+		resp, err := request_API_GetDAG_0(runtime.AnnotateContext(ctx, req), client, req, pathParams)
+		if err != nil {
+			runtime.HTTPError(ctx, w, err)
+			return
+		}
+
+		forward_API_GetDAG_0(ctx, w, req, resp, mux.GetForwardResponseOptions()...)
+
+	})
+
+	return nil
+}
+
+var (
+	pattern_API_ListRepos_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"console", "repos"}, ""))
+
+	pattern_API_ListPipelines_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"console", "pipelines"}, ""))
+
+	pattern_API_ListJobs_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"console", "jobs"}, ""))
+
+	pattern_API_GetDAG_0 = runtime.MustPattern(runtime.NewPattern(1, []int{2, 0, 2, 1}, []string{"console", "dag"}, ""))
+)
+
+var (
+	forward_API_ListRepos_0 = runtime.ForwardResponseMessage
+
+	forward_API_ListPipelines_0 = runtime.ForwardResponseMessage
+
+	forward_API_ListJobs_0 = runtime.ForwardResponseMessage
+
+	forward_API_GetDAG_0 = runtime.ForwardResponseMessage
+)
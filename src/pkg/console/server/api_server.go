@@ -0,0 +1,338 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/console"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/proto/rpclog"
+	"go.pedge.io/proto/time"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+var (
+	jobStateNames = map[pps.JobState]string{
+		pps.JobState_JOB_STATE_RUNNING: "running",
+		pps.JobState_JOB_STATE_FAILURE: "failure",
+		pps.JobState_JOB_STATE_SUCCESS: "success",
+		pps.JobState_JOB_STATE_QUEUED:  "queued",
+	}
+)
+
+type apiServer struct {
+	protorpclog.Logger
+	pfsAPIClient      pfs.APIClient
+	jobAPIClient      pps.JobAPIClient
+	pipelineAPIClient pps.PipelineAPIClient
+}
+
+func newAPIServer(
+	pfsAPIClient pfs.APIClient,
+	jobAPIClient pps.JobAPIClient,
+	pipelineAPIClient pps.PipelineAPIClient,
+) *apiServer {
+	return &apiServer{
+		protorpclog.NewLogger("console.API"),
+		pfsAPIClient,
+		jobAPIClient,
+		pipelineAPIClient,
+	}
+}
+
+func (a *apiServer) ListRepos(ctx context.Context, request *console.ListReposRequest) (response *console.RepoSummaries, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	repoInfos, err := a.pfsAPIClient.ListRepo(ctx, &pfs.ListRepoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var matches []*pfs.RepoInfo
+	for _, repoInfo := range repoInfos.RepoInfo {
+		if contains(repoInfo.Repo.Name, request.Search) {
+			matches = append(matches, repoInfo)
+		}
+	}
+	sort.Sort(sortRepoInfosByName(matches))
+	start, end, nextPageToken, err := page(len(matches), request.PageSize, request.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	pipelineInfos, err := a.pipelineAPIClient.ListPipeline(ctx, &pps.ListPipelineRequest{})
+	if err != nil {
+		return nil, err
+	}
+	response = &console.RepoSummaries{
+		NextPageToken: nextPageToken,
+		Total:         uint64(len(matches)),
+	}
+	for _, repoInfo := range matches[start:end] {
+		repoSummary := &console.RepoSummary{
+			RepoInfo: repoInfo,
+		}
+		for _, pipelineInfo := range pipelineInfos.PipelineInfo {
+			if pipelineInfo.OutputRepo != nil && pipelineInfo.OutputRepo.Name == repoInfo.Repo.Name {
+				repoSummary.Producer = pipelineInfo.Pipeline.Name
+			}
+			for _, input := range pipelineInfo.Inputs {
+				if input.Repo.Name == repoInfo.Repo.Name {
+					repoSummary.Consumers = append(repoSummary.Consumers, pipelineInfo.Pipeline.Name)
+				}
+			}
+		}
+		// only the repos on this page are asked for their commits, which is
+		// what makes paging cheaper than listing everything
+		commitInfos, err := a.pfsAPIClient.ListCommit(
+			ctx,
+			&pfs.ListCommitRequest{
+				Repo:       []*pfs.Repo{repoInfo.Repo},
+				CommitType: pfs.CommitType_COMMIT_TYPE_READ,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, commitInfo := range commitInfos.CommitInfo {
+			if repoSummary.Head == nil || prototime.TimestampToTime(commitInfo.Finished).After(prototime.TimestampToTime(repoSummary.Head.Finished)) {
+				repoSummary.Head = commitInfo
+			}
+		}
+		response.RepoSummary = append(response.RepoSummary, repoSummary)
+	}
+	return response, nil
+}
+
+func (a *apiServer) ListPipelines(ctx context.Context, request *console.ListPipelinesRequest) (response *console.PipelineSummaries, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	pipelineInfos, err := a.pipelineAPIClient.ListPipeline(ctx, &pps.ListPipelineRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var matches []*pps.PipelineInfo
+	for _, pipelineInfo := range pipelineInfos.PipelineInfo {
+		if contains(pipelineInfo.Pipeline.Name, request.Search) {
+			matches = append(matches, pipelineInfo)
+		}
+	}
+	sort.Sort(sortPipelineInfosByName(matches))
+	start, end, nextPageToken, err := page(len(matches), request.PageSize, request.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	response = &console.PipelineSummaries{
+		NextPageToken: nextPageToken,
+		Total:         uint64(len(matches)),
+	}
+	for _, pipelineInfo := range matches[start:end] {
+		jobInfos, err := a.jobAPIClient.ListJob(ctx, &pps.ListJobRequest{Pipeline: pipelineInfo.Pipeline})
+		if err != nil {
+			return nil, err
+		}
+		pipelineSummary := &console.PipelineSummary{
+			PipelineInfo: pipelineInfo,
+			LastJob:      lastJob(jobInfos.JobInfo),
+			JobCounts:    &console.JobCounts{},
+		}
+		for _, jobInfo := range jobInfos.JobInfo {
+			switch jobInfo.State {
+			case pps.JobState_JOB_STATE_RUNNING:
+				pipelineSummary.JobCounts.Running++
+			case pps.JobState_JOB_STATE_FAILURE:
+				pipelineSummary.JobCounts.Failure++
+			case pps.JobState_JOB_STATE_SUCCESS:
+				pipelineSummary.JobCounts.Success++
+			case pps.JobState_JOB_STATE_QUEUED:
+				pipelineSummary.JobCounts.Queued++
+			}
+		}
+		response.PipelineSummary = append(response.PipelineSummary, pipelineSummary)
+	}
+	return response, nil
+}
+
+func (a *apiServer) ListJobs(ctx context.Context, request *console.ListJobsRequest) (response *console.JobSummaries, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.State != "" && !isJobStateName(request.State) {
+		return nil, fmt.Errorf("console.server: unknown job state %s, must be one of running, failure, success or queued", request.State)
+	}
+	listJobRequest := &pps.ListJobRequest{}
+	if request.Pipeline != "" {
+		listJobRequest.Pipeline = &pps.Pipeline{Name: request.Pipeline}
+	}
+	jobInfos, err := a.jobAPIClient.ListJob(ctx, listJobRequest)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*pps.JobInfo
+	for _, jobInfo := range jobInfos.JobInfo {
+		if request.State != "" && jobStateNames[jobInfo.State] != request.State {
+			continue
+		}
+		if !contains(jobInfo.Job.Id, request.Search) && (jobInfo.Pipeline == nil || !contains(jobInfo.Pipeline.Name, request.Search)) {
+			continue
+		}
+		matches = append(matches, jobInfo)
+	}
+	sort.Sort(sortJobInfosNewestFirst(matches))
+	start, end, nextPageToken, err := page(len(matches), request.PageSize, request.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &console.JobSummaries{
+		JobInfo:       matches[start:end],
+		NextPageToken: nextPageToken,
+		Total:         uint64(len(matches)),
+	}, nil
+}
+
+func (a *apiServer) GetDAG(ctx context.Context, request *console.GetDAGRequest) (response *console.DAG, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	repoInfos, err := a.pfsAPIClient.ListRepo(ctx, &pfs.ListRepoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	pipelineInfos, err := a.pipelineAPIClient.ListPipeline(ctx, &pps.ListPipelineRequest{})
+	if err != nil {
+		return nil, err
+	}
+	jobInfos, err := a.jobAPIClient.ListJob(ctx, &pps.ListJobRequest{})
+	if err != nil {
+		return nil, err
+	}
+	jobInfosByPipeline := make(map[string][]*pps.JobInfo)
+	for _, jobInfo := range jobInfos.JobInfo {
+		if jobInfo.Pipeline != nil {
+			jobInfosByPipeline[jobInfo.Pipeline.Name] = append(jobInfosByPipeline[jobInfo.Pipeline.Name], jobInfo)
+		}
+	}
+	response = &console.DAG{}
+	sort.Sort(sortRepoInfosByName(repoInfos.RepoInfo))
+	for _, repoInfo := range repoInfos.RepoInfo {
+		response.Node = append(response.Node, &console.DAGNode{
+			Id:   repoNodeID(repoInfo.Repo.Name),
+			Type: console.DAGNodeType_DAG_NODE_TYPE_REPO,
+			Name: repoInfo.Repo.Name,
+		})
+	}
+	sort.Sort(sortPipelineInfosByName(pipelineInfos.PipelineInfo))
+	for _, pipelineInfo := range pipelineInfos.PipelineInfo {
+		id := pipelineNodeID(pipelineInfo.Pipeline.Name)
+		node := &console.DAGNode{
+			Id:   id,
+			Type: console.DAGNodeType_DAG_NODE_TYPE_PIPELINE,
+			Name: pipelineInfo.Pipeline.Name,
+		}
+		if jobInfo := lastJob(jobInfosByPipeline[pipelineInfo.Pipeline.Name]); jobInfo != nil {
+			node.State = jobStateNames[jobInfo.State]
+		}
+		response.Node = append(response.Node, node)
+		for _, input := range pipelineInfo.Inputs {
+			response.Edge = append(response.Edge, &console.DAGEdge{
+				From: repoNodeID(input.Repo.Name),
+				To:   id,
+			})
+		}
+		if pipelineInfo.OutputRepo != nil {
+			response.Edge = append(response.Edge, &console.DAGEdge{
+				From: id,
+				To:   repoNodeID(pipelineInfo.OutputRepo.Name),
+			})
+		}
+	}
+	return response, nil
+}
+
+// page returns the slice [start:end] of a list of length total which
+// pageToken and pageSize select, and the token for the page after it.
+// Tokens are offsets into the list.
+func page(total int, pageSize uint32, pageToken string) (int, int, string, error) {
+	start := 0
+	if pageToken != "" {
+		offset, err := strconv.ParseUint(pageToken, 10, 64)
+		if err != nil || offset > uint64(total) {
+			return 0, 0, "", fmt.Errorf("console.server: invalid page token %s", pageToken)
+		}
+		start = int(offset)
+	}
+	size := int(pageSize)
+	if size == 0 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	end := start + size
+	if end >= total {
+		return start, total, "", nil
+	}
+	return start, end, strconv.Itoa(end), nil
+}
+
+// contains reports whether name contains search, ignoring case.
+func contains(name string, search string) bool {
+	return strings.Contains(strings.ToLower(name), strings.ToLower(search))
+}
+
+func isJobStateName(name string) bool {
+	for _, stateName := range jobStateNames {
+		if stateName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lastJob returns the most recently created of jobInfos, or nil.
+func lastJob(jobInfos []*pps.JobInfo) *pps.JobInfo {
+	var result *pps.JobInfo
+	for _, jobInfo := range jobInfos {
+		if result == nil || prototime.TimestampToTime(jobInfo.CreatedAt).After(prototime.TimestampToTime(result.CreatedAt)) {
+			result = jobInfo
+		}
+	}
+	return result
+}
+
+func repoNodeID(name string) string {
+	return "repo/" + name
+}
+
+func pipelineNodeID(name string) string {
+	return "pipeline/" + name
+}
+
+type sortRepoInfosByName []*pfs.RepoInfo
+
+func (s sortRepoInfosByName) Len() int           { return len(s) }
+func (s sortRepoInfosByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortRepoInfosByName) Less(i, j int) bool { return s[i].Repo.Name < s[j].Repo.Name }
+
+type sortPipelineInfosByName []*pps.PipelineInfo
+
+func (s sortPipelineInfosByName) Len() int      { return len(s) }
+func (s sortPipelineInfosByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortPipelineInfosByName) Less(i, j int) bool {
+	return s[i].Pipeline.Name < s[j].Pipeline.Name
+}
+
+// sortJobInfosNewestFirst breaks ties by id so that pages are stable.
+type sortJobInfosNewestFirst []*pps.JobInfo
+
+func (s sortJobInfosNewestFirst) Len() int      { return len(s) }
+func (s sortJobInfosNewestFirst) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortJobInfosNewestFirst) Less(i, j int) bool {
+	iCreated := prototime.TimestampToTime(s[i].CreatedAt)
+	jCreated := prototime.TimestampToTime(s[j].CreatedAt)
+	if !iCreated.Equal(jCreated) {
+		return iCreated.After(jCreated)
+	}
+	return s[i].Job.Id < s[j].Job.Id
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/console"
+	"github.com/pachyderm/pachyderm/src/pps"
+)
+
+type APIServer interface {
+	console.APIServer
+}
+
+// NewAPIServer returns a new APIServer which reads repos from pfsAPIClient
+// and jobs and pipelines from jobAPIClient and pipelineAPIClient.
+func NewAPIServer(
+	pfsAPIClient pfs.APIClient,
+	jobAPIClient pps.JobAPIClient,
+	pipelineAPIClient pps.PipelineAPIClient,
+) APIServer {
+	return newAPIServer(
+		pfsAPIClient,
+		jobAPIClient,
+		pipelineAPIClient,
+	)
+}
@@ -14,11 +14,17 @@ type BuildOptions struct {
 	OutputStream io.Writer
 }
 
+type InspectImageOptions struct{}
+
 type PullOptions struct {
 	NoPullIfLocal bool
 	OutputStream  io.Writer
 }
 
+type PushOptions struct {
+	OutputStream io.Writer
+}
+
 type CreateOptions struct {
 	Binds      []string
 	HasCommand bool
@@ -42,7 +48,12 @@ type RemoveOptions struct{}
 
 type Client interface {
 	Build(imageName string, contextDir string, options BuildOptions) error
+	// InspectImage returns the id of the image.
+	InspectImage(imageName string, options InspectImageOptions) (string, error)
 	Pull(imageName string, options PullOptions) error
+	// Push pushes the image to the registry in its name and returns the
+	// digest the registry stored it under.
+	Push(imageName string, options PushOptions) (string, error)
 	Create(imageName string, options CreateOptions) (string, error)
 	Start(containerID string, options StartOptions) error
 	Logs(containerID string, options LogsOptions) error
@@ -3,6 +3,8 @@ package container
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"regexp"
 
 	"github.com/fsouza/go-dockerclient"
 )
@@ -11,6 +13,8 @@ const (
 	defaultShell = "sh"
 )
 
+var pushDigestRegexp = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+
 type dockerClient struct {
 	// confusing
 	client *docker.Client
@@ -32,6 +36,14 @@ func (c *dockerClient) Build(imageName string, contextDir string, options BuildO
 	)
 }
 
+func (c *dockerClient) InspectImage(imageName string, options InspectImageOptions) (string, error) {
+	image, err := c.client.InspectImage(imageName)
+	if err != nil {
+		return "", err
+	}
+	return image.ID, nil
+}
+
 func (c *dockerClient) Pull(imageName string, options PullOptions) error {
 	repository, tag := docker.ParseRepositoryTag(imageName)
 	if tag == "" {
@@ -66,6 +78,38 @@ func (c *dockerClient) Pull(imageName string, options PullOptions) error {
 	)
 }
 
+func (c *dockerClient) Push(imageName string, options PushOptions) (string, error) {
+	repository, tag := docker.ParseRepositoryTag(imageName)
+	if tag == "" {
+		tag = "latest"
+	}
+	var output bytes.Buffer
+	var outputStream io.Writer = &output
+	if options.OutputStream != nil {
+		outputStream = io.MultiWriter(&output, options.OutputStream)
+	}
+	if err := c.client.PushImage(
+		docker.PushImageOptions{
+			Name:         repository,
+			Tag:          tag,
+			OutputStream: outputStream,
+		},
+		docker.AuthConfiguration{},
+	); err != nil {
+		return "", err
+	}
+	return pushDigest(output.String())
+}
+
+// pushDigest returns the digest the registry reported in the output of a push.
+func pushDigest(output string) (string, error) {
+	match := pushDigestRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return "", fmt.Errorf("container: the registry didn't report a digest for the push")
+	}
+	return match[1], nil
+}
+
 func (c *dockerClient) Create(imageName string, options CreateOptions) (_ string, retErr error) {
 	createContainerOptions, err := getDockerCreateContainerOptions(imageName, options)
 	if err != nil {
@@ -72,3 +72,11 @@ func newTestDockerClient() (*dockerClient, error) {
 	}
 	return newDockerClient(client), nil
 }
+
+func TestPushDigest(t *testing.T) {
+	digest, err := pushDigest("The push refers to a repository [registry:5000/image]\nabc123: Pushed\nlatest: digest: sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef size: 1234\n")
+	require.NoError(t, err)
+	require.Equal(t, "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", digest)
+	_, err = pushDigest("abc123: Pushed\n")
+	require.True(t, err != nil)
+}
@@ -99,6 +99,14 @@ func ObjdRc() *api.ReplicationController {
 					Volumes: []api.Volume{
 						{
 							Name: "obj-disk",
+							// objd's blocks are on the host so that the
+							// cache containers of jobs on the same host can
+							// read them directly
+							VolumeSource: api.VolumeSource{
+								HostPath: &api.HostPathVolumeSource{
+									Path: "/var/pachyderm/obj",
+								},
+							},
 						},
 					},
 				},
@@ -178,11 +186,28 @@ func PfsdRc(shards uint64) *api.ReplicationController {
 									ContainerPort: 750,
 									Name:          "api-http-port",
 								},
+								{
+									ContainerPort: 850,
+									Name:          "content-port",
+								},
 								{
 									ContainerPort: 1050,
 									Name:          "trace-port",
 								},
 							},
+							VolumeMounts: []api.VolumeMount{
+								{
+									Name:      "pfsd-state",
+									MountPath: "/pfs-state",
+								},
+							},
+						},
+					},
+					Volumes: []api.Volume{
+						{
+							// the shards pfsd hosts are recorded here so
+							// that they survive container restarts
+							Name: "pfsd-state",
 						},
 					},
 				},
@@ -217,6 +242,11 @@ func PfsdService() *api.Service {
 					Name:     "api-http-port",
 					NodePort: 30750,
 				},
+				{
+					Port:     850,
+					Name:     "content-port",
+					NodePort: 30850,
+				},
 			},
 		},
 	}
@@ -294,6 +324,10 @@ func PpsdRc() *api.ReplicationController {
 									HostIP:        "0.0.0.0",
 									Name:          "api-grpc-port",
 								},
+								{
+									ContainerPort: 751,
+									Name:          "api-http-port",
+								},
 								{
 									ContainerPort: 1051,
 									Name:          "trace-port",
@@ -0,0 +1,232 @@
+package discovery
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cachedClientRetryInterval = time.Second
+)
+
+// pendingCall is an in flight request to the underlying client, concurrent misses
+// for the same key wait on the same call.
+type pendingCall struct {
+	done   chan struct{}
+	value  string
+	values map[string]string
+	err    error
+}
+
+type cachedClient struct {
+	Client
+	watchKey string
+	cancel   chan bool
+	lock     sync.Mutex
+	// ready is true while the watch on watchKey is established, nothing
+	// is cached otherwise since we wouldn't hear about invalidations.
+	ready bool
+	// generation is bumped on every invalidation so that a response which
+	// raced with an invalidation doesn't get cached.
+	generation uint64
+	values     map[string]string
+	allValues  map[string]map[string]string
+	gets       map[string]*pendingCall
+	getAlls    map[string]*pendingCall
+}
+
+func newCachedClient(client Client, watchKey string) *cachedClient {
+	c := &cachedClient{
+		Client:    client,
+		watchKey:  strings.TrimPrefix(watchKey, "/"),
+		cancel:    make(chan bool),
+		values:    make(map[string]string),
+		allValues: make(map[string]map[string]string),
+		gets:      make(map[string]*pendingCall),
+		getAlls:   make(map[string]*pendingCall),
+	}
+	go c.watch()
+	return c
+}
+
+func (c *cachedClient) Close() error {
+	close(c.cancel)
+	return c.Client.Close()
+}
+
+func (c *cachedClient) Get(key string) (string, error) {
+	if !c.cacheable(key) {
+		return c.Client.Get(key)
+	}
+	c.lock.Lock()
+	if value, ok := c.values[key]; ok && c.ready {
+		c.lock.Unlock()
+		return value, nil
+	}
+	if call, ok := c.gets[key]; ok {
+		c.lock.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &pendingCall{done: make(chan struct{})}
+	c.gets[key] = call
+	generation := c.generation
+	c.lock.Unlock()
+
+	call.value, call.err = c.Client.Get(key)
+	c.lock.Lock()
+	delete(c.gets, key)
+	if call.err == nil && c.ready && c.generation == generation {
+		c.values[key] = call.value
+	}
+	c.lock.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+func (c *cachedClient) GetAll(key string) (map[string]string, error) {
+	if !c.cacheable(key) {
+		return c.Client.GetAll(key)
+	}
+	c.lock.Lock()
+	if values, ok := c.allValues[key]; ok && c.ready {
+		c.lock.Unlock()
+		return copyValues(values), nil
+	}
+	if call, ok := c.getAlls[key]; ok {
+		c.lock.Unlock()
+		<-call.done
+		return copyValues(call.values), call.err
+	}
+	call := &pendingCall{done: make(chan struct{})}
+	c.getAlls[key] = call
+	generation := c.generation
+	c.lock.Unlock()
+
+	call.values, call.err = c.Client.GetAll(key)
+	c.lock.Lock()
+	delete(c.getAlls, key)
+	if call.err == nil && c.ready && c.generation == generation {
+		c.allValues[key] = call.values
+	}
+	c.lock.Unlock()
+	close(call.done)
+	return copyValues(call.values), call.err
+}
+
+func (c *cachedClient) Set(key string, value string, ttl uint64) error {
+	defer c.invalidate(key)
+	return c.Client.Set(key, value, ttl)
+}
+
+func (c *cachedClient) Delete(key string) error {
+	defer c.invalidate(key)
+	return c.Client.Delete(key)
+}
+
+func (c *cachedClient) CheckAndDelete(key string, oldValue string) error {
+	defer c.invalidate(key)
+	return c.Client.CheckAndDelete(key, oldValue)
+}
+
+func (c *cachedClient) Create(key string, value string, ttl uint64) error {
+	defer c.invalidate(key)
+	return c.Client.Create(key, value, ttl)
+}
+
+func (c *cachedClient) CreateInDir(dir string, value string, ttl uint64) error {
+	defer c.invalidate(dir)
+	return c.Client.CreateInDir(dir, value, ttl)
+}
+
+func (c *cachedClient) CheckAndSet(key string, value string, ttl uint64, oldValue string) error {
+	defer c.invalidate(key)
+	return c.Client.CheckAndSet(key, value, ttl, oldValue)
+}
+
+func (c *cachedClient) SetLease(key string, value string, ttl uint64, leaseID uint64) (*Lease, error) {
+	defer c.invalidate(key)
+	return c.Client.SetLease(key, value, ttl, leaseID)
+}
+
+func (c *cachedClient) cacheable(key string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(key, "/"), c.watchKey)
+}
+
+// invalidate drops everything cached for key, including any GetAll that
+// key is a part of.
+func (c *cachedClient) invalidate(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.unsafeInvalidate(key)
+}
+
+func (c *cachedClient) unsafeInvalidate(key string) {
+	key = strings.TrimPrefix(key, "/")
+	c.generation++
+	for cachedKey := range c.values {
+		if strings.HasPrefix(cachedKey, key) {
+			delete(c.values, cachedKey)
+		}
+	}
+	for dir := range c.allValues {
+		if strings.HasPrefix(key, dir) || strings.HasPrefix(dir, key) {
+			delete(c.allValues, dir)
+		}
+	}
+}
+
+func (c *cachedClient) reset(ready bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.unsafeReset(ready)
+}
+
+func (c *cachedClient) unsafeReset(ready bool) {
+	c.ready = ready
+	c.generation++
+	c.values = make(map[string]string)
+	c.allValues = make(map[string]map[string]string)
+}
+
+func (c *cachedClient) watch() {
+	for {
+		err := c.Client.WatchAllEvents(
+			c.watchKey,
+			c.cancel,
+			func(events []*Event) error {
+				c.lock.Lock()
+				defer c.lock.Unlock()
+				if !c.ready {
+					// anything cached before the watch started may be stale
+					c.unsafeReset(true)
+				}
+				for _, event := range events {
+					c.unsafeInvalidate(event.Key)
+				}
+				return nil
+			},
+		)
+		c.reset(false)
+		if err == ErrCancelled {
+			return
+		}
+		select {
+		case <-c.cancel:
+			return
+		case <-time.After(cachedClientRetryInterval):
+		}
+	}
+}
+
+func copyValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,193 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestCachedClientHits(t *testing.T) {
+	t.Parallel()
+	client := newTestClient(map[string]string{"watch/a": "one", "watch/b": "two", "other": "three"})
+	cachedClient := newCachedClient(client, "/watch")
+	defer func() { require.NoError(t, cachedClient.Close()) }()
+
+	// nothing is cached until the watch is established
+	requireGet(t, cachedClient, "watch/a", "one")
+	requireGet(t, cachedClient, "watch/a", "one")
+	require.Equal(t, 2, client.getCount())
+	client.sendEvents(nil)
+
+	requireGet(t, cachedClient, "watch/a", "one")
+	requireGet(t, cachedClient, "watch/a", "one")
+	require.Equal(t, 3, client.getCount())
+	for i := 0; i < 2; i++ {
+		values, err := cachedClient.GetAll("watch")
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"watch/a": "one", "watch/b": "two"}, values)
+	}
+	require.Equal(t, 1, client.getAllCount())
+
+	// keys outside of the watch are never cached
+	requireGet(t, cachedClient, "other", "three")
+	requireGet(t, cachedClient, "other", "three")
+	require.Equal(t, 5, client.getCount())
+}
+
+func TestCachedClientInvalidation(t *testing.T) {
+	t.Parallel()
+	client := newTestClient(map[string]string{"watch/a": "one", "watch/b": "two"})
+	cachedClient := newCachedClient(client, "watch")
+	defer func() { require.NoError(t, cachedClient.Close()) }()
+	client.sendEvents(nil)
+
+	requireGet(t, cachedClient, "watch/a", "one")
+	requireGet(t, cachedClient, "watch/b", "two")
+	_, err := cachedClient.GetAll("watch")
+	require.NoError(t, err)
+
+	// a watch event for a key drops it and any directory containing it
+	client.set("watch/a", "1")
+	client.sendEvents([]*Event{{EventTypeModify, "/watch/a", "1"}})
+	requireGet(t, cachedClient, "watch/a", "1")
+	requireGet(t, cachedClient, "watch/b", "two")
+	require.Equal(t, 3, client.getCount())
+	values, err := cachedClient.GetAll("watch")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"watch/a": "1", "watch/b": "two"}, values)
+	require.Equal(t, 2, client.getAllCount())
+
+	// writes through the cache invalidate without waiting for the watch
+	require.NoError(t, cachedClient.Set("watch/b", "2", 0))
+	requireGet(t, cachedClient, "watch/b", "2")
+	require.Equal(t, 4, client.getCount())
+}
+
+func TestCachedClientErrors(t *testing.T) {
+	t.Parallel()
+	client := newTestClient(map[string]string{"watch/a": "one"})
+	cachedClient := newCachedClient(client, "watch")
+	defer func() { require.NoError(t, cachedClient.Close()) }()
+	client.sendEvents(nil)
+
+	client.setErr(fmt.Errorf("unavailable"))
+	_, err := cachedClient.Get("watch/a")
+	require.True(t, err != nil)
+	_, err = cachedClient.GetAll("watch")
+	require.True(t, err != nil)
+
+	// errors aren't cached
+	client.setErr(nil)
+	requireGet(t, cachedClient, "watch/a", "one")
+	values, err := cachedClient.GetAll("watch")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"watch/a": "one"}, values)
+	require.Equal(t, 2, client.getCount())
+	require.Equal(t, 2, client.getAllCount())
+}
+
+func requireGet(t *testing.T, client Client, key string, expected string) {
+	value, err := client.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, expected, value)
+}
+
+// testClient counts the reads that make it past the cache and delivers
+// watch events when the test sends them.
+type testClient struct {
+	Client
+	lock      sync.Mutex
+	values    map[string]string
+	err       error
+	gets      int
+	getAlls   int
+	events    chan []*Event
+	eventsErr chan error
+}
+
+func newTestClient(values map[string]string) *testClient {
+	return &testClient{
+		values:    values,
+		events:    make(chan []*Event),
+		eventsErr: make(chan error),
+	}
+}
+
+func (c *testClient) Close() error {
+	return nil
+}
+
+func (c *testClient) Get(key string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.gets++
+	if c.err != nil {
+		return "", c.err
+	}
+	return c.values[key], nil
+}
+
+func (c *testClient) GetAll(key string) (map[string]string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.getAlls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	result := make(map[string]string)
+	for valueKey, value := range c.values {
+		if strings.HasPrefix(valueKey, key) {
+			result[valueKey] = value
+		}
+	}
+	return result, nil
+}
+
+func (c *testClient) Set(key string, value string, ttl uint64) error {
+	c.set(key, value)
+	return nil
+}
+
+func (c *testClient) WatchAllEvents(key string, cancel chan bool, callBack func([]*Event) error) error {
+	for {
+		select {
+		case <-cancel:
+			return ErrCancelled
+		case events := <-c.events:
+			c.eventsErr <- callBack(events)
+		}
+	}
+}
+
+// sendEvents returns once the cached client has handled events.
+func (c *testClient) sendEvents(events []*Event) {
+	c.events <- events
+	<-c.eventsErr
+}
+
+func (c *testClient) set(key string, value string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[key] = value
+}
+
+func (c *testClient) setErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.err = err
+}
+
+func (c *testClient) getCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.gets
+}
+
+func (c *testClient) getAllCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.getAlls
+}
@@ -2,9 +2,41 @@ package discovery
 
 import (
 	"fmt"
+	"time"
 )
 
-var ErrCancelled = fmt.Errorf("pachyderm: cancelled by user")
+var (
+	ErrCancelled = fmt.Errorf("pachyderm: cancelled by user")
+	// ErrLeaseExpired is returned by SetLease when a lease has been lost,
+	// either because the key expired or because someone else wrote it.
+	ErrLeaseExpired = fmt.Errorf("pachyderm: lease expired")
+)
+
+type EventType int
+
+const (
+	EventTypeAdd EventType = iota
+	EventTypeModify
+	EventTypeDelete
+)
+
+// Lease is a hold on a key which is kept by renewing it before it expires.
+type Lease struct {
+	// ID is the backend's index for the write which took or renewed the
+	// lease, it only ever increases and doesn't depend on anyone's clock.
+	ID uint64
+	// TTL is how long the lease has left as measured by the backend.
+	TTL time.Duration
+	// Expiration is when the lease expires by the backend's clock.
+	Expiration time.Time
+}
+
+// Event is a change to a single key in a directory.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value string
+}
 
 type Client interface {
 	// Close closes the underlying connection.
@@ -18,9 +50,16 @@ type Client interface {
 	// the map will be empty if no keys are found.
 	GetAll(key string) (map[string]string, error)
 	// Watch calls callBack with changes to a value
+	// Watches survive outages of the backend, they pick up from the last
+	// change they saw once it's back, so the only errors they return are
+	// from callBack or ErrCancelled.
 	Watch(key string, cancel chan bool, callBack func(string) error) error
 	// WatchAll calls callBack with changes to a directory
 	WatchAll(key string, cancel chan bool, callBack func(map[string]string) error) error
+	// WatchAllEvents is like WatchAll but calls callBack with only the keys
+	// that changed, the first call has an EventTypeAdd for every key already
+	// in the directory (or is nil if the directory doesn't exist).
+	WatchAllEvents(key string, cancel chan bool, callBack func([]*Event) error) error
 	// Set sets the value for a key.
 	// ttl is in seconds.
 	Set(key string, value string, ttl uint64) error
@@ -36,12 +75,25 @@ type Client interface {
 	// CheckAndSet is like Set but only succeeds if the key is already set to oldValue.
 	// ttl is in seconds.
 	CheckAndSet(key string, value string, ttl uint64, oldValue string) error
+	// SetLease is like Set but returns a Lease on key. If leaseID isn't 0
+	// the write only succeeds if key hasn't been written or expired since
+	// the write with that ID, otherwise ErrLeaseExpired is returned.
+	// ttl is in seconds.
+	SetLease(key string, value string, ttl uint64, leaseID uint64) (*Lease, error)
 }
 
 func NewEtcdClient(addresses ...string) Client {
 	return newEtcdClient(addresses...)
 }
 
+// NewCachedClient returns a Client which caches Get and GetAll for keys
+// under watchKey and coalesces concurrent requests for the same key. The
+// cache is invalidated by a watch on watchKey, reads go straight to client
+// while that watch isn't established.
+func NewCachedClient(client Client, watchKey string) Client {
+	return newCachedClient(client, watchKey)
+}
+
 // Registry is an object that allows a value to be registered as
 // valid for the lifetime of a process, and allows all values
 // registered to be retrieved.
@@ -23,6 +23,21 @@ func TestEtcdWatch(t *testing.T) {
 	runWatchTest(t, client)
 }
 
+func TestDiffMaps(t *testing.T) {
+	t.Parallel()
+	events := diffMaps(
+		map[string]string{"a": "one", "b": "two", "c": "three"},
+		map[string]string{"a": "one", "b": "2", "d": "four"},
+	)
+	require.Equal(t, 3, len(events))
+	types := make(map[string]EventType)
+	for _, event := range events {
+		types[event.Key] = event.Type
+	}
+	require.Equal(t, map[string]EventType{"b": EventTypeModify, "c": EventTypeDelete, "d": EventTypeAdd}, types)
+	require.Equal(t, 0, len(diffMaps(nil, nil)))
+}
+
 func runTest(t *testing.T, client Client) {
 	err := client.Set("foo", "one", 0)
 	require.NoError(t, err)
@@ -74,6 +89,21 @@ func runWatchTest(t *testing.T, client Client) {
 		},
 	)
 	require.Equal(t, ErrCancelled, err)
+
+	cancel = make(chan bool)
+	err = client.WatchAllEvents(
+		"watchAllEvents/foo",
+		cancel,
+		func(events []*Event) error {
+			if events == nil {
+				return client.Set("watchAllEvents/foo/bar", "quux", 0)
+			}
+			require.Equal(t, []*Event{{Type: EventTypeAdd, Key: "watchAllEvents/foo/bar", Value: "quux"}}, events)
+			close(cancel)
+			return nil
+		},
+	)
+	require.Equal(t, ErrCancelled, err)
 }
 
 func getEtcdClient() (Client, error) {
@@ -2,10 +2,27 @@ package discovery
 
 import (
 	"strings"
+	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/coreos/go-etcd/etcd"
+	"go.pedge.io/protolog"
 )
 
+// watchMaxRetryInterval caps the time between attempts to re-establish a
+// watch while etcd is unavailable.
+const watchMaxRetryInterval = 30 * time.Second
+
+// callBackError wraps errors from watch callbacks so they can be told apart
+// from errors talking to etcd, which are retried.
+type callBackError struct {
+	err error
+}
+
+func (e *callBackError) Error() string {
+	return e.err.Error()
+}
+
 type etcdClient struct {
 	client *etcd.Client
 }
@@ -41,34 +58,34 @@ func (c *etcdClient) GetAll(key string) (map[string]string, error) {
 }
 
 func (c *etcdClient) Watch(key string, cancel chan bool, callBack func(string) error) error {
-	// This retry is needed for when the etcd cluster gets overloaded.
-	for {
-		if err := c.watchWithoutRetry(key, cancel, callBack); err != nil {
-			etcdErr, ok := err.(*etcd.EtcdError)
-			if ok && etcdErr.ErrorCode == 401 {
-				continue
-			}
-			if ok && etcdErr.ErrorCode == 501 {
-				continue
-			}
-			return err
-		}
-	}
+	// waitIndex is 0 until we've read the key, after that watches resume
+	// from it so that we don't miss or replay changes
+	var waitIndex uint64
+	var value *string
+	return c.retry(cancel, func(b backoff.BackOff) error {
+		return c.watchWithoutRetry(key, cancel, b, &waitIndex, &value, callBack)
+	})
 }
 
 func (c *etcdClient) WatchAll(key string, cancel chan bool, callBack func(map[string]string) error) error {
-	for {
-		if err := c.watchAllWithoutRetry(key, cancel, callBack); err != nil {
-			etcdErr, ok := err.(*etcd.EtcdError)
-			if ok && etcdErr.ErrorCode == 401 {
-				continue
-			}
-			if ok && etcdErr.ErrorCode == 501 {
-				continue
-			}
-			return err
-		}
-	}
+	return c.watchAll(key, cancel, func(value map[string]string, events []*Event) error {
+		return callBack(value)
+	})
+}
+
+func (c *etcdClient) WatchAllEvents(key string, cancel chan bool, callBack func([]*Event) error) error {
+	return c.watchAll(key, cancel, func(value map[string]string, events []*Event) error {
+		return callBack(events)
+	})
+}
+
+func (c *etcdClient) watchAll(key string, cancel chan bool, callBack func(map[string]string, []*Event) error) error {
+	var waitIndex uint64
+	// value is nil until we've read the directory
+	var value map[string]string
+	return c.retry(cancel, func(b backoff.BackOff) error {
+		return c.watchAllWithoutRetry(key, cancel, b, &waitIndex, &value, callBack)
+	})
 }
 
 func (c *etcdClient) Set(key string, value string, ttl uint64) error {
@@ -124,31 +141,66 @@ func (c *etcdClient) CheckAndSet(key string, value string, ttl uint64, oldValue
 	return nil
 }
 
+func (c *etcdClient) SetLease(key string, value string, ttl uint64, leaseID uint64) (*Lease, error) {
+	var response *etcd.Response
+	var err error
+	if leaseID == 0 {
+		response, err = c.client.Set(key, value, ttl)
+	} else {
+		response, err = c.client.CompareAndSwap(key, value, ttl, "", leaseID)
+	}
+	if err != nil {
+		// 100 is key not found and 101 is compare failed
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && (etcdErr.ErrorCode == 100 || etcdErr.ErrorCode == 101) {
+			return nil, ErrLeaseExpired
+		}
+		return nil, err
+	}
+	lease := &Lease{
+		ID:  response.Node.ModifiedIndex,
+		TTL: time.Duration(response.Node.TTL) * time.Second,
+	}
+	if response.Node.Expiration != nil {
+		lease.Expiration = *response.Node.Expiration
+	}
+	return lease, nil
+}
+
 // nodeToMap translates the contents of a node into a map
 // nodeToMap can be called on the same map with successive results from watch
 // to accumulate a value
 // nodeToMap returns true if out was modified
 func nodeToMap(node *etcd.Node, out map[string]string) bool {
+	return len(nodeToEvents(node, out)) > 0
+}
+
+// nodeToEvents is like nodeToMap but returns the changes it made to out
+func nodeToEvents(node *etcd.Node, out map[string]string) []*Event {
 	key := strings.TrimPrefix(node.Key, "/")
 	if !node.Dir {
+		value, ok := out[key]
 		if node.Value == "" {
-			if _, ok := out[key]; ok {
+			if ok {
 				delete(out, key)
-				return true
+				return []*Event{{Type: EventTypeDelete, Key: key, Value: value}}
 			}
-			return false
+			return nil
+		}
+		if !ok {
+			out[key] = node.Value
+			return []*Event{{Type: EventTypeAdd, Key: key, Value: node.Value}}
 		}
-		if value, ok := out[key]; !ok || value != node.Value {
+		if value != node.Value {
 			out[key] = node.Value
-			return true
+			return []*Event{{Type: EventTypeModify, Key: key, Value: node.Value}}
 		}
-		return false
+		return nil
 	}
-	changed := false
+	var events []*Event
 	for _, node := range node.Nodes {
-		changed = nodeToMap(node, out) || changed
+		events = append(events, nodeToEvents(node, out)...)
 	}
-	return changed
+	return events
 }
 
 func maxModifiedIndex(node *etcd.Node) uint64 {
@@ -161,80 +213,164 @@ func maxModifiedIndex(node *etcd.Node) uint64 {
 	return result
 }
 
-func (c *etcdClient) watchWithoutRetry(key string, cancel chan bool, callBack func(string) error) error {
-	var waitIndex uint64 = 1
-	// First get the starting value of the key
-	response, err := c.client.Get(key, false, false)
-	if err != nil {
-		if strings.HasPrefix(err.Error(), "100: Key not found") {
-			err = callBack("")
-			if err != nil {
+// retry calls f until it returns an error from a callback or cancel is
+// closed. Other errors come from etcd, which may be down for some time, so
+// they're retried with jittered exponential backoff; f resets b whenever it
+// hears from etcd.
+func (c *etcdClient) retry(cancel chan bool, f func(b backoff.BackOff) error) error {
+	b := backoff.NewExponentialBackOff()
+	b.MaxInterval = watchMaxRetryInterval
+	b.MaxElapsedTime = 0
+	for {
+		err := f(b)
+		if err == nil || err == ErrCancelled {
+			return err
+		}
+		if callBackErr, ok := err.(*callBackError); ok {
+			return callBackErr.err
+		}
+		if etcdErr, ok := err.(*etcd.EtcdError); ok && (etcdErr.ErrorCode == 401 || etcdErr.ErrorCode == 501) {
+			// the watch index was cleared or etcd restarted, this isn't an
+			// outage so retry right away
+			continue
+		}
+		protolog.Printf("discovery: error watching, retrying: %s", err.Error())
+		select {
+		case <-cancel:
+			return ErrCancelled
+		case <-time.After(b.NextBackOff()):
+		}
+	}
+}
+
+func (c *etcdClient) watchWithoutRetry(
+	key string,
+	cancel chan bool,
+	b backoff.BackOff,
+	waitIndex *uint64,
+	value **string,
+	callBack func(string) error,
+) error {
+	if *waitIndex == 0 {
+		// get the current value of the key, the first time through or after
+		// the index we were watching from was cleared
+		newValue := ""
+		response, err := c.client.Get(key, false, false)
+		if err != nil {
+			etcdErr, ok := err.(*etcd.EtcdError)
+			if !ok || etcdErr.ErrorCode != 100 {
 				return err
 			}
+			*waitIndex = etcdErr.Index + 1
 		} else {
-			return err
+			newValue = response.Node.Value
+			*waitIndex = response.EtcdIndex + 1
 		}
-	} else {
-		err = callBack(response.Node.Value)
-		if err != nil {
-			return err
+		b.Reset()
+		if *value == nil || **value != newValue {
+			*value = &newValue
+			if err := callBack(newValue); err != nil {
+				return &callBackError{err}
+			}
 		}
-		waitIndex = response.Node.ModifiedIndex + 1
 	}
 	for {
-		response, err := c.client.Watch(key, waitIndex, false, nil, cancel)
+		response, err := c.client.Watch(key, *waitIndex, false, nil, cancel)
 		if err != nil {
 			if err == etcd.ErrWatchStoppedByUser {
 				return ErrCancelled
 			}
+			if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == 401 {
+				*waitIndex = 0
+			}
 			return err
 		}
-		err = callBack(response.Node.Value)
-		if err != nil {
-			return err
+		b.Reset()
+		*waitIndex = response.Node.ModifiedIndex + 1
+		newValue := response.Node.Value
+		*value = &newValue
+		if err := callBack(newValue); err != nil {
+			return &callBackError{err}
 		}
-		waitIndex = response.Node.ModifiedIndex + 1
 	}
 }
 
-func (c *etcdClient) watchAllWithoutRetry(key string, cancel chan bool, callBack func(map[string]string) error) error {
-	var waitIndex uint64 = 1
-	value := make(map[string]string)
-	// First get the starting value of the key
-	response, err := c.client.Get(key, false, false)
-	if err != nil {
-		if strings.HasPrefix(err.Error(), "100: Key not found") {
-			err = callBack(nil)
-			if err != nil {
+func (c *etcdClient) watchAllWithoutRetry(
+	key string,
+	cancel chan bool,
+	b backoff.BackOff,
+	waitIndex *uint64,
+	value *map[string]string,
+	callBack func(map[string]string, []*Event) error,
+) error {
+	if *waitIndex == 0 {
+		// get the current contents of the directory, the first time through
+		// or after the index we were watching from was cleared
+		newValue := make(map[string]string)
+		response, err := c.client.Get(key, false, true)
+		if err != nil {
+			etcdErr, ok := err.(*etcd.EtcdError)
+			if !ok || etcdErr.ErrorCode != 100 {
 				return err
 			}
+			*waitIndex = etcdErr.Index + 1
 		} else {
-			return err
+			nodeToMap(response.Node, newValue)
+			*waitIndex = response.EtcdIndex + 1
 		}
-	} else {
-		waitIndex = maxModifiedIndex(response.Node) + 1
-		if nodeToMap(response.Node, value) {
-			err = callBack(value)
-			if err != nil {
-				return err
+		b.Reset()
+		if *value == nil && err != nil {
+			*value = newValue
+			if err := callBack(nil, nil); err != nil {
+				return &callBackError{err}
+			}
+		} else {
+			events := diffMaps(*value, newValue)
+			*value = newValue
+			if len(events) > 0 {
+				if err := callBack(newValue, events); err != nil {
+					return &callBackError{err}
+				}
 			}
 		}
 	}
 	for {
-		response, err := c.client.Watch(key, waitIndex, true, nil, cancel)
+		response, err := c.client.Watch(key, *waitIndex, true, nil, cancel)
 		if err != nil {
 			if err == etcd.ErrWatchStoppedByUser {
 				return ErrCancelled
 			}
+			if etcdErr, ok := err.(*etcd.EtcdError); ok && etcdErr.ErrorCode == 401 {
+				*waitIndex = 0
+			}
 			return err
 		}
-		responseModifiedIndex := maxModifiedIndex(response.Node)
-		waitIndex = responseModifiedIndex + 1
-		if nodeToMap(response.Node, value) {
-			err = callBack(value)
-			if err != nil {
-				return err
+		b.Reset()
+		*waitIndex = maxModifiedIndex(response.Node) + 1
+		if events := nodeToEvents(response.Node, *value); len(events) > 0 {
+			if err := callBack(*value, events); err != nil {
+				return &callBackError{err}
 			}
 		}
 	}
 }
+
+// diffMaps returns the events which turn oldValue into newValue, deletes are
+// needed when keys expired while we weren't watching.
+func diffMaps(oldValue map[string]string, newValue map[string]string) []*Event {
+	var events []*Event
+	for key, value := range newValue {
+		oldValue, ok := oldValue[key]
+		if !ok {
+			events = append(events, &Event{Type: EventTypeAdd, Key: key, Value: value})
+		} else if oldValue != value {
+			events = append(events, &Event{Type: EventTypeModify, Key: key, Value: value})
+		}
+	}
+	for key, value := range oldValue {
+		if _, ok := newValue[key]; !ok {
+			events = append(events, &Event{Type: EventTypeDelete, Key: key, Value: value})
+		}
+	}
+	return events
+}
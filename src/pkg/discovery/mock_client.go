@@ -76,6 +76,11 @@ func (c *mockClient) WatchAll(key string, cancel chan bool, callBack func(map[st
 	return nil
 }
 
+func (c *mockClient) WatchAllEvents(key string, cancel chan bool, callBack func([]*Event) error) error {
+	// TODO jdoliner
+	return nil
+}
+
 func (c *mockClient) Set(key string, value string, ttl uint64) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -0,0 +1,81 @@
+/*
+Package events appends cluster events, commits which finished and jobs which
+completed, to a repo so that pipelines can take them as input.
+
+Events are buffered and appended every few seconds in one commit, CommitsPath
+and JobsPath in the repo grow by a JSON object per event. Several servers can
+record to the same repo, each append is a commit whose parent is the repo's
+last commit, so the repo has no branches.
+
+Commits and jobs of pipelines which take the repo as input, directly or
+through other pipelines, aren't recorded since each append would trigger them
+again.
+
+Events are only buffered in memory, the ones a server hasn't appended when it
+restarts are lost, as are the oldest when more than a few thousand can't be
+appended. The repo is a record to build on, not a guarantee that every commit
+and job is in it.
+*/
+package events
+
+import (
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pps"
+)
+
+const (
+	// RepoKey configures the repo events are appended to, events aren't
+	// recorded if it's unset. The repo is created if it doesn't exist.
+	RepoKey = "events_repo"
+	// CommitsPath is the file in the repo CommitEvents are appended to.
+	CommitsPath = "commits"
+	// JobsPath is the file in the repo JobEvents are appended to.
+	JobsPath = "jobs"
+)
+
+// CommitEvent is a line of CommitsPath.
+type CommitEvent struct {
+	Repo     string    `json:"repo"`
+	Commit   string    `json:"commit"`
+	Finished time.Time `json:"finished"`
+}
+
+// JobEvent is a line of JobsPath.
+type JobEvent struct {
+	Job      string `json:"job"`
+	Pipeline string `json:"pipeline,omitempty"`
+	// State is "SUCCESS" or "FAILURE".
+	State        string    `json:"state"`
+	OutputRepo   string    `json:"output_repo,omitempty"`
+	OutputCommit string    `json:"output_commit,omitempty"`
+	Finished     time.Time `json:"finished"`
+}
+
+// Recorder records events to the repo configured by RepoKey.
+type Recorder interface {
+	// CommitFinished records that commit finished at finished. Commits to
+	// the events repo itself, and to repos downstream of it, aren't
+	// recorded.
+	CommitFinished(commit *pfs.Commit, finished time.Time)
+	// JobFinished records that job, which is pipeline's, or "" if it isn't
+	// a pipeline's, finished in state with its output in outputCommit.
+	JobFinished(job *pps.Job, pipeline string, state pps.JobState, outputCommit *pfs.Commit, finished time.Time)
+	// SetRepo is a config.Setter for RepoKey.
+	SetRepo(value string) error
+}
+
+// NewRecorder returns a Recorder which appends events to the repo through
+// pfsAPIClient in the background. pipelineAPIClient is used to find the
+// pipelines downstream of the repo, if it's nil their events are recorded.
+func NewRecorder(pfsAPIClient pfs.APIClient, pipelineAPIClient pps.PipelineAPIClient) Recorder {
+	recorder := newRecorder(pfsAPIClient, pipelineAPIClient)
+	go recorder.run()
+	return recorder
+}
+
+// NewNopRecorder returns a Recorder which doesn't record anything.
+func NewNopRecorder() Recorder {
+	return nopRecorder{}
+}
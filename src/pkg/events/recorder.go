@@ -0,0 +1,263 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// how often buffered events are appended to the repo
+	flushInterval = 10 * time.Second
+	// the most events of each kind which are buffered, the oldest are
+	// dropped when the repo can't be written to for a while
+	maxBufferedEvents = 10000
+)
+
+type recorder struct {
+	pfsAPIClient      pfs.APIClient
+	pipelineAPIClient pps.PipelineAPIClient
+	repo              string
+	// commits and jobs are the encoded events waiting to be appended
+	commits [][]byte
+	jobs    [][]byte
+	// downstreamRepos and downstreamPipelines are the pipelines which take
+	// the repo as input, directly or through other pipelines, and their
+	// output repos. Their commits and jobs aren't recorded, appending them
+	// would trigger the pipelines again.
+	downstreamRepos     map[string]bool
+	downstreamPipelines map[string]bool
+	lock                sync.Mutex
+}
+
+func newRecorder(pfsAPIClient pfs.APIClient, pipelineAPIClient pps.PipelineAPIClient) *recorder {
+	return &recorder{
+		pfsAPIClient:      pfsAPIClient,
+		pipelineAPIClient: pipelineAPIClient,
+	}
+}
+
+func (r *recorder) CommitFinished(commit *pfs.Commit, finished time.Time) {
+	if commit == nil || commit.Repo == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// the commits which append events would be events themselves
+	if r.repo == "" || commit.Repo.Name == r.repo || r.downstreamRepos[commit.Repo.Name] {
+		return
+	}
+	r.commits = r.add(r.commits, &CommitEvent{
+		Repo:     commit.Repo.Name,
+		Commit:   commit.Id,
+		Finished: finished,
+	})
+}
+
+func (r *recorder) JobFinished(job *pps.Job, pipeline string, state pps.JobState, outputCommit *pfs.Commit, finished time.Time) {
+	event := &JobEvent{
+		Job:      job.Id,
+		Pipeline: pipeline,
+		State:    strings.TrimPrefix(state.String(), "JOB_STATE_"),
+		Finished: finished,
+	}
+	if outputCommit != nil && outputCommit.Repo != nil {
+		event.OutputRepo = outputCommit.Repo.Name
+		event.OutputCommit = outputCommit.Id
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.repo == "" || r.downstreamPipelines[pipeline] || r.downstreamRepos[event.OutputRepo] {
+		return
+	}
+	r.jobs = r.add(r.jobs, event)
+}
+
+func (r *recorder) SetRepo(value string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if value != r.repo {
+		// the buffered events were meant for the old repo
+		r.commits = nil
+		r.jobs = nil
+		r.downstreamRepos = nil
+		r.downstreamPipelines = nil
+	}
+	r.repo = value
+	return nil
+}
+
+// add returns events with event appended, lock must be held.
+func (r *recorder) add(events [][]byte, event interface{}) [][]byte {
+	encodedEvent, err := json.Marshal(event)
+	if err != nil {
+		protolog.Printf("Error encoding event %+v: %s", event, err.Error())
+		return events
+	}
+	return bound(append(events, append(encodedEvent, '\n')))
+}
+
+func (r *recorder) run() {
+	for range time.Tick(flushInterval) {
+		if err := r.refreshDownstream(); err != nil {
+			protolog.Printf("Error listing the pipelines downstream of the events repo: %s", err.Error())
+		}
+		if err := r.flush(); err != nil {
+			protolog.Printf("Error appending events: %s", err.Error())
+		}
+	}
+}
+
+// refreshDownstream lists the pipelines downstream of the repo, it's done
+// before each flush so a pipeline created on the repo is known before the
+// commit which triggers it is appended.
+func (r *recorder) refreshDownstream() error {
+	r.lock.Lock()
+	repo := r.repo
+	r.lock.Unlock()
+	if repo == "" || r.pipelineAPIClient == nil {
+		return nil
+	}
+	pipelineInfos, err := r.pipelineAPIClient.ListPipeline(context.Background(), &pps.ListPipelineRequest{})
+	if err != nil {
+		return err
+	}
+	repos, pipelines := downstream(repo, pipelineInfos.PipelineInfo)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.repo == repo {
+		r.downstreamRepos, r.downstreamPipelines = repos, pipelines
+	}
+	return nil
+}
+
+// downstream returns the output repos and names of the pipelines in
+// pipelineInfos which take repo as input, directly or through other
+// pipelines.
+func downstream(repo string, pipelineInfos []*pps.PipelineInfo) (map[string]bool, map[string]bool) {
+	repos := map[string]bool{repo: true}
+	pipelines := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, pipelineInfo := range pipelineInfos {
+			if pipelines[pipelineInfo.Pipeline.Name] {
+				continue
+			}
+			for _, input := range pipelineInfo.Inputs {
+				if input.Repo == nil || !repos[input.Repo.Name] {
+					continue
+				}
+				pipelines[pipelineInfo.Pipeline.Name] = true
+				outputRepo := pipelineInfo.OutputRepo
+				if outputRepo == nil {
+					outputRepo = pps.PipelineRepo(pipelineInfo.Pipeline)
+				}
+				repos[outputRepo.Name] = true
+				changed = true
+				break
+			}
+		}
+	}
+	delete(repos, repo)
+	return repos, pipelines
+}
+
+// flush appends the buffered events to the repo, they're buffered again if
+// they can't be.
+func (r *recorder) flush() error {
+	r.lock.Lock()
+	repo, commits, jobs := r.repo, r.commits, r.jobs
+	r.commits, r.jobs = nil, nil
+	r.lock.Unlock()
+	if len(commits) == 0 && len(jobs) == 0 {
+		return nil
+	}
+	if err := r.write(repo, commits, jobs); err != nil {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if r.repo == repo {
+			r.commits = bound(append(commits, r.commits...))
+			r.jobs = bound(append(jobs, r.jobs...))
+		}
+		return err
+	}
+	return nil
+}
+
+// write appends commits and jobs to repo in a commit.
+func (r *recorder) write(repo string, commits [][]byte, jobs [][]byte) error {
+	repoInfo, err := pfsutil.InspectRepo(r.pfsAPIClient, repo)
+	if err != nil {
+		if err := pfsutil.CreateRepo(r.pfsAPIClient, repo); err != nil {
+			// another server may have created it first
+			if repoInfo, err = pfsutil.InspectRepo(r.pfsAPIClient, repo); err != nil {
+				return err
+			}
+		}
+	}
+	parentID := ""
+	if repoInfo != nil && repoInfo.LastCommit != nil {
+		parentID = repoInfo.LastCommit.Id
+	}
+	var commit *pfs.Commit
+	if parentID == "" {
+		commit, err = pfsutil.StartCommit(r.pfsAPIClient, repo, parentID)
+	} else {
+		// another server appending at the same time makes one of us
+		// conflict, its events are appended on the next flush
+		commit, err = pfsutil.StartCommitExpectingParent(r.pfsAPIClient, repo, parentID)
+	}
+	if err != nil {
+		return err
+	}
+	if err := func() error {
+		for path, events := range map[string][][]byte{CommitsPath: commits, JobsPath: jobs} {
+			if len(events) == 0 {
+				continue
+			}
+			if _, err := pfsutil.PutFile(r.pfsAPIClient, repo, commit.Id, path, 0, bytes.NewReader(bytes.Join(events, nil))); err != nil {
+				return err
+			}
+		}
+		if parentID == "" {
+			if _, err := pfsutil.FinishCommit(r.pfsAPIClient, repo, commit.Id); err != nil {
+				return err
+			}
+			return nil
+		}
+		return pfsutil.FinishCommitExpectingParent(r.pfsAPIClient, repo, commit.Id, parentID)
+	}(); err != nil {
+		_ = pfsutil.ForceFinishCommit(r.pfsAPIClient, repo, commit.Id)
+		_ = pfsutil.DeleteCommit(r.pfsAPIClient, repo, commit.Id)
+		return err
+	}
+	return nil
+}
+
+// bound drops the oldest of events if there are more than maxBufferedEvents.
+func bound(events [][]byte) [][]byte {
+	if len(events) > maxBufferedEvents {
+		protolog.Printf("Dropping %d events which couldn't be appended", len(events)-maxBufferedEvents)
+		return events[len(events)-maxBufferedEvents:]
+	}
+	return events
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) CommitFinished(commit *pfs.Commit, finished time.Time) {}
+
+func (nopRecorder) JobFinished(job *pps.Job, pipeline string, state pps.JobState, outputCommit *pfs.Commit, finished time.Time) {
+}
+
+func (nopRecorder) SetRepo(value string) error {
+	return nil
+}
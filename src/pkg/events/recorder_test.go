@@ -0,0 +1,82 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"github.com/pachyderm/pachyderm/src/pps"
+)
+
+func TestRecord(t *testing.T) {
+	recorder := newRecorder(nil, nil)
+	finished := time.Unix(1000, 0).UTC()
+	// nothing is recorded until there's a repo
+	recorder.CommitFinished(&pfs.Commit{Repo: &pfs.Repo{Name: "data"}, Id: "master/1"}, finished)
+	require.Equal(t, 0, len(recorder.commits))
+
+	require.NoError(t, recorder.SetRepo("events"))
+	recorder.CommitFinished(&pfs.Commit{Repo: &pfs.Repo{Name: "data"}, Id: "master/2"}, finished)
+	recorder.CommitFinished(&pfs.Commit{Repo: &pfs.Repo{Name: "events"}, Id: "master/0"}, finished)
+	recorder.JobFinished(
+		&pps.Job{Id: "job"},
+		"pipeline",
+		pps.JobState_JOB_STATE_SUCCESS,
+		&pfs.Commit{Repo: &pfs.Repo{Name: "pipeline"}, Id: "master/3"},
+		finished,
+	)
+	require.Equal(t, 1, len(recorder.commits))
+	var commitEvent CommitEvent
+	require.NoError(t, json.Unmarshal(recorder.commits[0], &commitEvent))
+	require.Equal(t, CommitEvent{Repo: "data", Commit: "master/2", Finished: finished}, commitEvent)
+	require.Equal(t, 1, len(recorder.jobs))
+	var jobEvent JobEvent
+	require.NoError(t, json.Unmarshal(recorder.jobs[0], &jobEvent))
+	require.Equal(t, JobEvent{Job: "job", Pipeline: "pipeline", State: "SUCCESS", OutputRepo: "pipeline", OutputCommit: "master/3", Finished: finished}, jobEvent)
+
+	// changing the repo drops what was meant for the old one
+	require.NoError(t, recorder.SetRepo("other"))
+	require.Equal(t, 0, len(recorder.commits))
+	require.Equal(t, 0, len(recorder.jobs))
+}
+
+func TestBound(t *testing.T) {
+	events := make([][]byte, maxBufferedEvents+1)
+	events[0] = []byte("oldest")
+	events[1] = []byte("kept")
+	bounded := bound(events)
+	require.Equal(t, maxBufferedEvents, len(bounded))
+	require.Equal(t, "kept", string(bounded[0]))
+}
+
+func TestDownstream(t *testing.T) {
+	pipelineInfos := []*pps.PipelineInfo{
+		{
+			Pipeline: &pps.Pipeline{Name: "second"},
+			Inputs:   []*pps.PipelineInput{{Repo: &pfs.Repo{Name: "first"}}},
+		},
+		{
+			Pipeline:   &pps.Pipeline{Name: "first"},
+			Inputs:     []*pps.PipelineInput{{Repo: &pfs.Repo{Name: "events"}}},
+			OutputRepo: &pfs.Repo{Name: "first"},
+		},
+		{
+			Pipeline: &pps.Pipeline{Name: "other"},
+			Inputs:   []*pps.PipelineInput{{Repo: &pfs.Repo{Name: "data"}}},
+		},
+	}
+	repos, pipelines := downstream("events", pipelineInfos)
+	require.Equal(t, map[string]bool{"first": true, pps.PipelineRepo(&pps.Pipeline{Name: "second"}).Name: true}, repos)
+	require.Equal(t, map[string]bool{"first": true, "second": true}, pipelines)
+
+	recorder := newRecorder(nil, nil)
+	require.NoError(t, recorder.SetRepo("events"))
+	recorder.downstreamRepos, recorder.downstreamPipelines = repos, pipelines
+	finished := time.Unix(1000, 0).UTC()
+	recorder.CommitFinished(&pfs.Commit{Repo: &pfs.Repo{Name: "first"}, Id: "master/1"}, finished)
+	recorder.JobFinished(&pps.Job{Id: "job"}, "second", pps.JobState_JOB_STATE_SUCCESS, nil, finished)
+	require.Equal(t, 0, len(recorder.commits))
+	require.Equal(t, 0, len(recorder.jobs))
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+	// insertAll takes at most 500 rows per request
+	bigQueryBatchSize = 500
+	// BigQuery only drops rows whose insertId it's seen for about a minute,
+	// so failed inserts are retried well within that
+	bigQueryInsertAttempts   = 3
+	bigQueryInsertRetryDelay = 5 * time.Second
+)
+
+// bigQuerySink streams rows into a table with insertAll. The vendored
+// bigquery client depends on api packages which aren't vendored, so this
+// speaks the REST api itself.
+type bigQuerySink struct {
+	client *http.Client
+	url    string
+}
+
+func newBigQuerySink(ctx context.Context, project string, dataset string, table string) (*bigQuerySink, error) {
+	client, err := google.DefaultClient(ctx, bigQueryScope)
+	if err != nil {
+		return nil, err
+	}
+	return &bigQuerySink{
+		client,
+		fmt.Sprintf("https://www.googleapis.com/bigquery/v2/projects/%s/datasets/%s/tables/%s/insertAll", project, dataset, table),
+	}, nil
+}
+
+type bigQueryInsertRow struct {
+	InsertID string                 `json:"insertId"`
+	JSON     map[string]interface{} `json:"json"`
+}
+
+type bigQueryInsertRequest struct {
+	Rows []*bigQueryInsertRow `json:"rows"`
+}
+
+type bigQueryInsertResponse struct {
+	InsertErrors []struct {
+		Index  int64 `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+func (s *bigQuerySink) Write(rows []*Row) error {
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > bigQueryBatchSize {
+			n = bigQueryBatchSize
+		}
+		if err := s.insertWithRetry(rows[:n]); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// insertWithRetry retries a failed insert while BigQuery would still drop
+// the rows of it which were inserted.
+func (s *bigQuerySink) insertWithRetry(rows []*Row) error {
+	var err error
+	for i := 0; i < bigQueryInsertAttempts; i++ {
+		if i > 0 {
+			time.Sleep(bigQueryInsertRetryDelay)
+		}
+		if err = s.insert(rows); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// insert inserts rows in one request.
+func (s *bigQuerySink) insert(rows []*Row) (retErr error) {
+	request := &bigQueryInsertRequest{}
+	for _, row := range rows {
+		request.Rows = append(request.Rows, &bigQueryInsertRow{row.ID, row.Value})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := httpResponse.Body.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	responseBody, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("bigquery insertAll: %s: %s", httpResponse.Status, strings.TrimSpace(string(responseBody)))
+	}
+	var response bigQueryInsertResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return err
+	}
+	if len(response.InsertErrors) > 0 {
+		insertError := response.InsertErrors[0]
+		var messages []string
+		for _, err := range insertError.Errors {
+			messages = append(messages, fmt.Sprintf("%s: %s", err.Reason, err.Message))
+		}
+		id := ""
+		if insertError.Index >= 0 && insertError.Index < int64(len(rows)) {
+			id = rows[insertError.Index].ID
+		}
+		return fmt.Errorf("bigquery rejected %d rows, row %s: %s", len(response.InsertErrors), id, strings.Join(messages, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Sink is a table in a warehouse which rows are written to.
+type Sink interface {
+	// Write writes rows. If Write fails some of the rows may have been
+	// written, callers should only rely on the sink dropping rows it
+	// already has while Write is retrying them itself.
+	Write(rows []*Row) error
+}
+
+// Row is a record to write to a Sink.
+type Row struct {
+	// ID is what identifies the row when it's written again, after a
+	// failed write say.
+	ID    string
+	Value map[string]interface{}
+	// End is the offset just after the row's line.
+	End int64
+}
+
+// NewSinkFromURL returns a sink for the table of url, which is
+// bigquery://project/dataset/table.
+func NewSinkFromURL(ctx context.Context, url string) (Sink, error) {
+	project, dataset, table, err := ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return newBigQuerySink(ctx, project, dataset, table)
+}
+
+// ParseURL returns the project, dataset and table of a bigquery:// url.
+func ParseURL(url string) (string, string, string, error) {
+	if strings.HasPrefix(url, "jdbc:") {
+		// TODO there are no database drivers vendored
+		return "", "", "", fmt.Errorf("%s: jdbc isn't supported yet, only bigquery://", url)
+	}
+	if !strings.HasPrefix(url, "bigquery://") {
+		return "", "", "", fmt.Errorf("%s isn't a bigquery:// url", url)
+	}
+	parts := strings.Split(strings.TrimPrefix(url, "bigquery://"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("%s isn't bigquery://project/dataset/table", url)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// ReadRows returns the rows in reader, which has a JSON object per line,
+// blank lines are skipped. Each row's id is idPrefix followed by the offset
+// of its line, offset is the offset of reader's first byte.
+func ReadRows(reader io.Reader, idPrefix string, offset int64) ([]*Row, error) {
+	var rows []*Row
+	bufferedReader := bufio.NewReader(reader)
+	for {
+		line, err := bufferedReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var value map[string]interface{}
+			if err := json.Unmarshal(trimmed, &value); err != nil {
+				return nil, fmt.Errorf("the line at offset %d isn't a JSON object: %s", offset, err.Error())
+			}
+			rows = append(rows, &Row{fmt.Sprintf("%s%d", idPrefix, offset), value, offset + int64(len(line))})
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return rows, nil
+		}
+	}
+}
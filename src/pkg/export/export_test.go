@@ -0,0 +1,33 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestParseURL(t *testing.T) {
+	project, dataset, table, err := ParseURL("bigquery://project/dataset/table")
+	require.NoError(t, err)
+	require.Equal(t, "project", project)
+	require.Equal(t, "dataset", dataset)
+	require.Equal(t, "table", table)
+	_, _, _, err = ParseURL("bigquery://project/dataset")
+	require.NotNil(t, err)
+	_, _, _, err = ParseURL("bigquery://project//table")
+	require.NotNil(t, err)
+	_, _, _, err = ParseURL("jdbc:postgresql://host/db")
+	require.NotNil(t, err)
+}
+
+func TestReadRows(t *testing.T) {
+	rows, err := ReadRows(strings.NewReader("{\"a\": 1}\n\n{\"b\": \"c\"}"), "commit/file@", 10)
+	require.NoError(t, err)
+	require.Equal(t, []*Row{
+		{"commit/file@10", map[string]interface{}{"a": float64(1)}, 19},
+		{"commit/file@20", map[string]interface{}{"b": "c"}, 30},
+	}, rows)
+	_, err = ReadRows(strings.NewReader("{\"a\": 1}\n[1]\n"), "", 0)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,40 @@
+/*
+Package feature gates experimental rpcs behind flags so that they can be
+rolled out gradually without recompiling.
+*/
+package feature
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// FeaturesKey configures which experimental features are on, its value
+	// is a comma separated list of feature=mode where mode is on, off or
+	// opt-in. Opt-in features are only on for requests which ask for them in
+	// their features header. Features which aren't listed keep their default.
+	FeaturesKey = "features"
+	// Query is the SQL query API served by pfsd.
+	Query = "query"
+)
+
+// Flags says which experimental features are on.
+type Flags interface {
+	// Check returns an error unless feature is on for the rpc made with ctx.
+	Check(ctx context.Context, feature string) error
+	// SetFeatures is a config.Setter for FeaturesKey.
+	SetFeatures(value string) error
+}
+
+// NewFlags returns Flags which have the features in on turned on by default,
+// every other feature is off by default.
+func NewFlags(on ...string) Flags {
+	return newFlags(on)
+}
+
+// NewFeatureCredentials returns credentials which ask for features with
+// every rpc, use them with grpc.WithPerRPCCredentials.
+func NewFeatureCredentials(features ...string) credentials.Credentials {
+	return featureCredentials(features)
+}
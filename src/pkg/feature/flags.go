@@ -0,0 +1,111 @@
+package feature
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	featuresHeader = "features"
+
+	modeOn    = "on"
+	modeOff   = "off"
+	modeOptIn = "opt-in"
+)
+
+type flags struct {
+	defaults map[string]string
+	modes    map[string]string
+	lock     sync.RWMutex
+}
+
+func newFlags(on []string) *flags {
+	defaults := make(map[string]string)
+	for _, feature := range on {
+		defaults[feature] = modeOn
+	}
+	return &flags{
+		defaults,
+		make(map[string]string),
+		sync.RWMutex{},
+	}
+}
+
+func (f *flags) Check(ctx context.Context, feature string) error {
+	switch f.mode(feature) {
+	case modeOn:
+		return nil
+	case modeOptIn:
+		if requested(ctx)[feature] {
+			return nil
+		}
+		return fmt.Errorf("pachyderm: %s is an opt-in feature, request it with the %s header", feature, featuresHeader)
+	default:
+		return fmt.Errorf("pachyderm: %s is an experimental feature which is off, turn it on with the %s config key", feature, FeaturesKey)
+	}
+}
+
+func (f *flags) SetFeatures(value string) error {
+	modes := make(map[string]string)
+	for _, featureMode := range strings.Split(value, ",") {
+		featureMode = strings.TrimSpace(featureMode)
+		if featureMode == "" {
+			continue
+		}
+		split := strings.SplitN(featureMode, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return fmt.Errorf("feature: %q isn't of the form feature=mode", featureMode)
+		}
+		switch split[1] {
+		case modeOn, modeOff, modeOptIn:
+		default:
+			return fmt.Errorf("feature: invalid mode %q for %s, must be %s, %s or %s", split[1], split[0], modeOn, modeOff, modeOptIn)
+		}
+		modes[split[0]] = split[1]
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.modes = modes
+	return nil
+}
+
+func (f *flags) mode(feature string) string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if mode, ok := f.modes[feature]; ok {
+		return mode
+	}
+	if mode, ok := f.defaults[feature]; ok {
+		return mode
+	}
+	return modeOff
+}
+
+// requested returns the features the rpc made with ctx asked for.
+func requested(ctx context.Context) map[string]bool {
+	result := make(map[string]bool)
+	if md, ok := metadata.FromContext(ctx); ok {
+		for _, value := range md[featuresHeader] {
+			for _, feature := range strings.Split(value, ",") {
+				if feature = strings.TrimSpace(feature); feature != "" {
+					result[feature] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+type featureCredentials []string
+
+func (f featureCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{featuresHeader: strings.Join(f, ",")}, nil
+}
+
+func (f featureCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,27 @@
+package feature
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFlags(t *testing.T) {
+	t.Parallel()
+	flags := NewFlags("on-by-default")
+	optedIn := metadata.NewContext(context.Background(), metadata.Pairs(featuresHeader, "new, other"))
+	require.NoError(t, flags.Check(context.Background(), "on-by-default"))
+	require.NotNil(t, flags.Check(optedIn, "new"))
+
+	require.NoError(t, flags.SetFeatures("new=opt-in,on-by-default=off"))
+	require.NotNil(t, flags.Check(context.Background(), "new"))
+	require.NoError(t, flags.Check(optedIn, "new"))
+	require.NotNil(t, flags.Check(optedIn, "on-by-default"))
+
+	require.NoError(t, flags.SetFeatures(""))
+	require.NoError(t, flags.Check(context.Background(), "on-by-default"))
+	require.NotNil(t, flags.SetFeatures("new=maybe"))
+	require.NotNil(t, flags.SetFeatures("new"))
+}
@@ -0,0 +1,71 @@
+package grpcutil
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// maxMsgSize is the limit enforced by codecs, it's read on every message so
+// that SetMaxMsgSize takes effect for connections which already exist.
+var maxMsgSize = int64(DefaultMaxMsgSize)
+
+// serverStats counts the messages servers created with ServerOptions have
+// received and sent, it's only accessed atomically.
+var serverStats Stats
+
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	data, err := proto.Marshal(v.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMsgSize(len(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if err := checkMsgSize(len(data)); err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (codec) String() string {
+	// the content type must match grpc's default codec so that we can talk
+	// to peers which don't use this one
+	return "proto"
+}
+
+// serverCodec is the codec servers use, it counts what passes through it in
+// serverStats.
+type serverCodec struct {
+	codec
+}
+
+func (c serverCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&serverStats.MessagesSent, 1)
+	atomic.AddUint64(&serverStats.BytesSent, uint64(len(data)))
+	return data, nil
+}
+
+func (c serverCodec) Unmarshal(data []byte, v interface{}) error {
+	atomic.AddUint64(&serverStats.MessagesReceived, 1)
+	atomic.AddUint64(&serverStats.BytesReceived, uint64(len(data)))
+	return c.codec.Unmarshal(data, v)
+}
+
+func checkMsgSize(size int) error {
+	if max := atomic.LoadInt64(&maxMsgSize); max > 0 && int64(size) > max {
+		return fmt.Errorf("grpcutil: message of %d bytes is larger than the max of %d bytes, the max is set with %s", size, max, MaxMsgSizeEnv)
+	}
+	return nil
+}
@@ -15,7 +15,7 @@ type dialer struct {
 }
 
 func newDialer(opts ...grpc.DialOption) *dialer {
-	return &dialer{opts, make(map[string]*grpc.ClientConn), &sync.RWMutex{}}
+	return &dialer{DialOptions(opts...), make(map[string]*grpc.ClientConn), &sync.RWMutex{}}
 }
 
 func (d *dialer) Dial(address string) (*grpc.ClientConn, error) {
@@ -1,12 +1,83 @@
 package grpcutil
 
 import (
+	"io"
 	"path/filepath"
+	"sync/atomic"
 
 	"github.com/pachyderm/pachyderm/src/pkg/uuid"
+	"go.pedge.io/proto/stream"
 	"google.golang.org/grpc"
 )
 
+const (
+	// DefaultMaxMsgSize is the largest message servers and clients accept
+	// unless it's changed with SetMaxMsgSize.
+	DefaultMaxMsgSize = 64 * 1024 * 1024
+	// MaxMsgSizeEnv is the environment variable binaries read the max message
+	// size from.
+	MaxMsgSizeEnv = "GRPC_MAX_MSG_SIZE"
+	// StreamingChunkSize is the most bytes sent in a single message of a
+	// byte stream, it's well under the max message size so that streams never
+	// hit it.
+	StreamingChunkSize = 1024 * 1024
+)
+
+// SetMaxMsgSize sets the largest message that servers and clients created
+// with ServerOptions and DialOptions will send or receive, 0 means no limit.
+func SetMaxMsgSize(size int) {
+	atomic.StoreInt64(&maxMsgSize, int64(size))
+}
+
+// Stats are the messages, and their bytes, that grpc servers in this process
+// have received and sent since it started.
+type Stats struct {
+	MessagesReceived uint64
+	BytesReceived    uint64
+	MessagesSent     uint64
+	BytesSent        uint64
+}
+
+// ServerOptions returns the options every grpc server should be created with.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.CustomCodec(serverCodec{})}
+}
+
+// GetServerStats returns the Stats of the servers created with ServerOptions.
+func GetServerStats() Stats {
+	return Stats{
+		MessagesReceived: atomic.LoadUint64(&serverStats.MessagesReceived),
+		BytesReceived:    atomic.LoadUint64(&serverStats.BytesReceived),
+		MessagesSent:     atomic.LoadUint64(&serverStats.MessagesSent),
+		BytesSent:        atomic.LoadUint64(&serverStats.BytesSent),
+	}
+}
+
+// DialOptions returns the options every grpc client should dial with, in
+// addition to opts.
+func DialOptions(opts ...grpc.DialOption) []grpc.DialOption {
+	return append([]grpc.DialOption{grpc.WithCodec(codec{})}, opts...)
+}
+
+// WriteToStreamingBytesServer writes the data from reader to
+// streamingBytesServer without ever holding more than StreamingChunkSize
+// bytes of it.
+func WriteToStreamingBytesServer(reader io.Reader, streamingBytesServer protostream.StreamingBytesServer) error {
+	return writeToStreamingBytesServer(reader, streamingBytesServer)
+}
+
+// NewStreamingBytesWriter returns an io.Writer which sends what's written to
+// it to streamingBytesServer, in messages of at most StreamingChunkSize bytes.
+func NewStreamingBytesWriter(streamingBytesServer protostream.StreamingBytesServer) io.Writer {
+	return &chunkWriter{streamingBytesServer}
+}
+
+// RelayFromStreamingBytesClient relays the data from streamingBytesClient to
+// streamingBytesServer, messages larger than StreamingChunkSize are split up.
+func RelayFromStreamingBytesClient(streamingBytesClient protostream.StreamingBytesClient, streamingBytesServer protostream.StreamingBytesServer) error {
+	return relayFromStreamingBytesClient(streamingBytesClient, streamingBytesServer)
+}
+
 type Dialer interface {
 	Dial(address string) (*grpc.ClientConn, error)
 	Clean() error
@@ -24,7 +95,7 @@ type LocalServer interface {
 
 func NewLocalServer() LocalServer {
 	return &localServer{
-		server: grpc.NewServer(),
+		server: grpc.NewServer(ServerOptions()...),
 		path:   filepath.Join("/tmp", uuid.NewWithoutDashes()),
 	}
 }
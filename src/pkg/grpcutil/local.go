@@ -35,5 +35,5 @@ func unixDialer(addr string, timeout time.Duration) (net.Conn, error) {
 }
 
 func (s *localServer) Dial() (*grpc.ClientConn, error) {
-	return grpc.Dial(s.path, grpc.WithDialer(unixDialer), grpc.WithInsecure())
+	return grpc.Dial(s.path, DialOptions(grpc.WithDialer(unixDialer), grpc.WithInsecure())...)
 }
@@ -0,0 +1,97 @@
+package grpcutil
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// Op is an rpc a server in this process is serving.
+type Op struct {
+	ID      uint64
+	Method  string
+	Request proto.Message
+	Started time.Time
+	// Context is the context StartOp returned, it's derived from the one the
+	// rpc was made with so it has the rpc's metadata, and CancelOp cancels it.
+	Context context.Context
+	cancel  context.CancelFunc
+}
+
+var (
+	ops     = make(map[uint64]*Op)
+	nextOp  uint64
+	opsLock sync.Mutex
+)
+
+// StartOp records that the rpc handler calling it is serving request, it
+// returns a context which CancelOp cancels, the handler should use it in
+// place of ctx. done must be called when the handler returns.
+func StartOp(ctx context.Context, request proto.Message) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	op := &Op{
+		Method:  callerName(),
+		Request: request,
+		Started: time.Now(),
+		Context: ctx,
+		cancel:  cancel,
+	}
+	opsLock.Lock()
+	nextOp++
+	op.ID = nextOp
+	ops[op.ID] = op
+	opsLock.Unlock()
+	return ctx, func() {
+		opsLock.Lock()
+		delete(ops, op.ID)
+		opsLock.Unlock()
+		cancel()
+	}
+}
+
+// ListOps returns the rpcs being served, oldest first.
+func ListOps() []*Op {
+	opsLock.Lock()
+	defer opsLock.Unlock()
+	var result []*Op
+	for _, op := range ops {
+		result = append(result, op)
+	}
+	sort.Sort(opsByID(result))
+	return result
+}
+
+// CancelOp cancels the context of the rpc with id, it returns false if there's
+// no such rpc. The rpc returns once it notices, handlers which are blocked on
+// something other than the context, such as the client, keep running.
+func CancelOp(id uint64) bool {
+	opsLock.Lock()
+	defer opsLock.Unlock()
+	op, ok := ops[id]
+	if !ok {
+		return false
+	}
+	op.cancel()
+	return true
+}
+
+// callerName returns the name of the method which called StartOp with its
+// receiver's type, ie "(*apiServer).GetFile".
+func callerName() string {
+	pc := make([]uintptr, 1)
+	runtime.Callers(3, pc)
+	name := runtime.FuncForPC(pc[0]).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	return name[strings.Index(name, ".")+1:]
+}
+
+type opsByID []*Op
+
+func (s opsByID) Len() int           { return len(s) }
+func (s opsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s opsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
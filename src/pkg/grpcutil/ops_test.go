@@ -0,0 +1,35 @@
+package grpcutil
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"go.pedge.io/google-protobuf"
+	"golang.org/x/net/context"
+)
+
+func TestOps(t *testing.T) {
+	started := make(chan uint64)
+	finished := make(chan error)
+	go testHandler(started, finished)
+	id := <-started
+
+	ops := ListOps()
+	require.Equal(t, 1, len(ops))
+	require.Equal(t, id, ops[0].ID)
+	require.Equal(t, "testHandler", ops[0].Method)
+	require.Equal(t, google_protobuf.EmptyInstance, ops[0].Request)
+
+	require.False(t, CancelOp(id+1))
+	require.True(t, CancelOp(id))
+	require.Equal(t, context.Canceled, <-finished)
+	require.Equal(t, 0, len(ListOps()))
+}
+
+func testHandler(started chan<- uint64, finished chan<- error) {
+	ctx, done := StartOp(context.Background(), google_protobuf.EmptyInstance)
+	defer done()
+	started <- ListOps()[0].ID
+	<-ctx.Done()
+	finished <- ctx.Err()
+}
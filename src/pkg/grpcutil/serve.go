@@ -0,0 +1,70 @@
+package grpcutil
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+
+	"github.com/gengo/grpc-gateway/runtime"
+	"go.pedge.io/proto/version"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// ServeOptions are the optional fields for Serve.
+type ServeOptions struct {
+	HTTPPort         uint16
+	DebugPort        uint16
+	Version          *protoversion.Version
+	HTTPRegisterFunc func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error
+}
+
+// Serve serves the grpc services registered by registerFunc on port, and the
+// HTTP gateway and debug handlers if their ports are set. Unlike
+// protoserver.Serve the server is created with ServerOptions and the gateway
+// dials it with DialOptions. It returns when any of the servers fails.
+func Serve(port uint16, registerFunc func(*grpc.Server), opts ServeOptions) error {
+	if port == 0 {
+		return fmt.Errorf("pachyderm.grpcutil: must specify port")
+	}
+	s := grpc.NewServer(append(ServerOptions(), grpc.MaxConcurrentStreams(math.MaxUint32))...)
+	registerFunc(s)
+	if opts.Version != nil {
+		protoversion.RegisterAPIServer(s, protoversion.NewAPIServer(opts.Version))
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	defer s.Stop()
+	errC := make(chan error, 3)
+	go func() { errC <- s.Serve(listener) }()
+	if opts.DebugPort != 0 {
+		go func() { errC <- http.ListenAndServe(fmt.Sprintf(":%d", opts.DebugPort), http.DefaultServeMux) }()
+	}
+	if opts.HTTPPort != 0 && (opts.Version != nil || opts.HTTPRegisterFunc != nil) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		clientConn, err := grpc.Dial(fmt.Sprintf("0.0.0.0:%d", port), DialOptions(grpc.WithInsecure())...)
+		if err != nil {
+			return err
+		}
+		defer clientConn.Close()
+		mux := runtime.NewServeMux()
+		if opts.Version != nil {
+			if err := protoversion.RegisterAPIHandler(ctx, mux, clientConn); err != nil {
+				return err
+			}
+		}
+		if opts.HTTPRegisterFunc != nil {
+			if err := opts.HTTPRegisterFunc(ctx, mux, clientConn); err != nil {
+				return err
+			}
+		}
+		go func() { errC <- http.ListenAndServe(fmt.Sprintf(":%d", opts.HTTPPort), mux) }()
+	}
+	protolog.Printf("Serving grpc on %d, http on %d, debug on %d", port, opts.HTTPPort, opts.DebugPort)
+	return <-errC
+}
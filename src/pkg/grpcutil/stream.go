@@ -0,0 +1,56 @@
+package grpcutil
+
+import (
+	"io"
+
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/stream"
+)
+
+// chunkWriter sends what's written to it to a byte stream in messages of at
+// most StreamingChunkSize bytes.
+type chunkWriter struct {
+	streamingBytesServer protostream.StreamingBytesServer
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		size := len(p)
+		if size > StreamingChunkSize {
+			size = StreamingChunkSize
+		}
+		if err := w.streamingBytesServer.Send(
+			&google_protobuf.BytesValue{
+				Value: p[:size],
+			},
+		); err != nil {
+			return written, err
+		}
+		written += size
+		p = p[size:]
+	}
+	return written, nil
+}
+
+func writeToStreamingBytesServer(reader io.Reader, streamingBytesServer protostream.StreamingBytesServer) error {
+	buffer := make([]byte, StreamingChunkSize)
+	_, err := io.CopyBuffer(&chunkWriter{streamingBytesServer}, reader, buffer)
+	return err
+}
+
+func relayFromStreamingBytesClient(streamingBytesClient protostream.StreamingBytesClient, streamingBytesServer protostream.StreamingBytesServer) error {
+	writer := &chunkWriter{streamingBytesServer}
+	for {
+		bytesValue, err := streamingBytesClient.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(bytesValue.Value); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package grpcutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// RPCTimeoutKey is the runtime config key for the longest an internal
+	// rpc may take, its value is a duration such as "30s", 0 is no limit.
+	RPCTimeoutKey = "rpc_timeout"
+	// DefaultRPCTimeout is the timeout used when RPCTimeoutKey isn't set.
+	DefaultRPCTimeout = 5 * time.Minute
+)
+
+var rpcTimeout = int64(DefaultRPCTimeout)
+
+// SetRPCTimeout is a config Setter for RPCTimeoutKey.
+func SetRPCTimeout(value string) error {
+	timeout := DefaultRPCTimeout
+	if value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("grpcutil: invalid %s %q: %s", RPCTimeoutKey, value, err.Error())
+		}
+		if timeout < 0 {
+			return fmt.Errorf("grpcutil: %s can't be negative", RPCTimeoutKey)
+		}
+	}
+	atomic.StoreInt64(&rpcTimeout, int64(timeout))
+	return nil
+}
+
+// WithTimeout returns a context which is cancelled when the rpc timeout
+// elapses, or when ctx is. ctx's own deadline is kept if it's sooner. The
+// returned cancel must be called once the rpcs made with the context are done.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(atomic.LoadInt64(&rpcTimeout))
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
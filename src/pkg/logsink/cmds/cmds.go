@@ -0,0 +1,71 @@
+package cmds
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/logsink"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+	"go.pedge.io/proto/time"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func Cmds(pfsdAddress string, ppsdAddress string) ([]*cobra.Command, error) {
+	var types []string
+	var ppsd bool
+	streamEvents := &cobra.Command{
+		Use:   "stream-events",
+		Short: "Stream the log events of a pfsd or ppsd as they happen.",
+		Long: `Stream the log events of a pfsd or ppsd as they happen.
+
+The server must be started with a stream:// sink in LOG_SINKS.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			address := pfsdAddress
+			if ppsd {
+				address = ppsdAddress
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			eventsClient, err := apiClient.StreamEvents(context.Background(), &logsink.StreamEventsRequest{Type: types})
+			if err != nil {
+				return err
+			}
+			for {
+				event, err := eventsClient.Recv()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Printf(
+					"%s %s %s %s\n",
+					prototime.TimestampToTime(event.Timestamp).Format(time.RFC3339),
+					event.Level,
+					event.Type,
+					event.Event,
+				)
+			}
+		}),
+	}
+	streamEvents.Flags().StringSliceVarP(&types, "type", "t", nil, "Only stream events of this type, ie shard.StartRegister, or of any type in a package, ie shard.")
+	streamEvents.Flags().BoolVar(&ppsd, "ppsd", false, "Stream ppsd's events rather than pfsd's.")
+
+	var result []*cobra.Command
+	result = append(result, streamEvents)
+	return result, nil
+}
+
+func getAPIClient(address string) (logsink.APIClient, error) {
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(grpc.WithInsecure())...)
+	if err != nil {
+		return nil, err
+	}
+	return logsink.NewAPIClient(clientConn), nil
+}
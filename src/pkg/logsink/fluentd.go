@@ -0,0 +1,103 @@
+package logsink
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
+)
+
+const (
+	fluentdDialTimeout  = 5 * time.Second
+	fluentdWriteTimeout = 10 * time.Second
+)
+
+// fluentdSink sends entries to fluentd with its forward protocol, each entry
+// is a msgpack encoded [tag, time, record]. It isn't safe for concurrent use,
+// it's meant to be wrapped in an asyncPusher.
+type fluentdSink struct {
+	address string
+	tag     string
+	handle  *codec.MsgpackHandle
+	conn    net.Conn
+}
+
+func newFluentdSink(address string, tag string) *fluentdSink {
+	return &fluentdSink{
+		address,
+		tag,
+		&codec.MsgpackHandle{},
+		nil,
+	}
+}
+
+func (s *fluentdSink) Push(entry *protolog.Entry) error {
+	record, err := fluentdRecord(entry)
+	if err != nil {
+		return err
+	}
+	if s.conn == nil {
+		if s.conn, err = net.DialTimeout("tcp", s.address, fluentdDialTimeout); err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+	var timestamp int64
+	if entry.Timestamp != nil {
+		timestamp = prototime.TimestampToTime(entry.Timestamp).Unix()
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(fluentdWriteTimeout)); err != nil {
+		return s.reset(err)
+	}
+	if err := codec.NewEncoder(s.conn, s.handle).Encode([]interface{}{s.tag, timestamp, record}); err != nil {
+		return s.reset(err)
+	}
+	return nil
+}
+
+func (s *fluentdSink) Flush() error {
+	return nil
+}
+
+// reset closes the connection after err so that the next push redials.
+func (s *fluentdSink) reset(err error) error {
+	_ = s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// fluentdRecord returns entry as a record with the event and its contexts
+// decoded so that fluentd can match on their fields.
+func fluentdRecord(entry *protolog.Entry) (map[string]interface{}, error) {
+	event, err := entryToEvent(entry)
+	if err != nil {
+		return nil, err
+	}
+	record := map[string]interface{}{
+		"id":    event.Id,
+		"level": event.Level,
+		"type":  event.Type,
+	}
+	if event.Event != "" {
+		var decodedEvent interface{}
+		if err := json.Unmarshal([]byte(event.Event), &decodedEvent); err != nil {
+			return nil, err
+		}
+		record["event"] = decodedEvent
+	}
+	var contexts []interface{}
+	for _, context := range event.Context {
+		var decodedContext interface{}
+		if err := json.Unmarshal([]byte(context), &decodedContext); err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, decodedContext)
+	}
+	if len(contexts) > 0 {
+		record["context"] = contexts
+	}
+	return record, nil
+}
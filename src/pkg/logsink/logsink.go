@@ -0,0 +1,145 @@
+package logsink
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.pedge.io/protolog"
+	protologsyslog "go.pedge.io/protolog/syslog"
+)
+
+// StreamSink is a protolog.Pusher which streams the entries it's pushed to
+// the clients of its APIServer.
+type StreamSink interface {
+	protolog.Pusher
+	APIServer
+}
+
+// NewStreamSink returns a new StreamSink.
+func NewStreamSink() StreamSink {
+	return newStreamSink()
+}
+
+// NewFileSink returns a protolog.Pusher which appends entries to the file at
+// path as JSON Events, one per line.
+func NewFileSink(path string) (protolog.Pusher, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return protolog.NewWritePusher(
+		protolog.NewFileFlusher(file),
+		protolog.WritePusherOptions{
+			Marshaller: jsonMarshaller{},
+			Newline:    true,
+		},
+	), nil
+}
+
+// NewSyslogSink returns a protolog.Pusher which sends entries to the syslog
+// daemon at address, the local daemon is used if network and address are "".
+func NewSyslogSink(network string, address string, tag string) (protolog.Pusher, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return protologsyslog.NewPusher(writer, protologsyslog.PusherOptions{}), nil
+}
+
+// NewFluentdSink returns a protolog.Pusher which sends entries to the fluentd
+// forward input at address with tag.
+func NewFluentdSink(address string, tag string) protolog.Pusher {
+	return newFluentdSink(address, tag)
+}
+
+// NewFilteredPusher returns a protolog.Pusher which only pushes entries with
+// events of types to pusher. A type ending in "." matches every type in that
+// package, ie "shard." matches "shard.StartRegister". Every entry is pushed if
+// types is empty.
+func NewFilteredPusher(pusher protolog.Pusher, types []string) protolog.Pusher {
+	return newFilteredPusher(pusher, types)
+}
+
+// NewAsyncPusher returns a protolog.Pusher which pushes entries to pusher in
+// the background so that a slow or unavailable sink doesn't block logging.
+// Entries are dropped when more than bufferSize are waiting and errors are
+// reported to stderr rather than returned, since protolog panics on them.
+func NewAsyncPusher(pusher protolog.Pusher, name string, bufferSize int) protolog.Pusher {
+	return newAsyncPusher(pusher, name, bufferSize)
+}
+
+// WriteRecentEvents writes the last entries logged since Setup to writer as
+// JSON Events, one per line, oldest first.
+func WriteRecentEvents(writer io.Writer) error {
+	return recent.writeEvents(writer)
+}
+
+// Setup has the global protolog logger push entries to stderr and to each of
+// the sinks in spec, the last entries are also kept for WriteRecentEvents. spec is a space separated list of sink urls:
+//
+//	file:///var/log/pfsd.log
+//	syslog://, which is the local daemon, syslog://host:514 or syslog+tcp://host:514
+//	fluentd://host:24224?tag=pachyderm
+//	stream://, which streams events to clients of the returned StreamSink
+//
+// Each url may also have a types parameter, a comma separated list of the
+// event types to export, see NewFilteredPusher. The returned StreamSink is nil
+// unless spec has a stream sink, the caller should serve it.
+func Setup(spec string) (StreamSink, error) {
+	pushers := []protolog.Pusher{
+		protolog.NewStandardWritePusher(protolog.NewFileFlusher(os.Stderr)),
+		recent,
+	}
+	var streamSink StreamSink
+	for _, rawurl := range strings.Fields(spec) {
+		sinkURL, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, fmt.Errorf("logsink: invalid sink %q: %s", rawurl, err.Error())
+		}
+		var types []string
+		if value := sinkURL.Query().Get("types"); value != "" {
+			types = strings.Split(value, ",")
+		}
+		var pusher protolog.Pusher
+		switch sinkURL.Scheme {
+		case "file":
+			if pusher, err = NewFileSink(sinkURL.Path); err != nil {
+				return nil, err
+			}
+		case "syslog", "syslog+tcp":
+			network := "udp"
+			if sinkURL.Scheme == "syslog+tcp" {
+				network = "tcp"
+			}
+			if sinkURL.Host == "" {
+				network = ""
+			}
+			if pusher, err = NewSyslogSink(network, sinkURL.Host, "pachyderm"); err != nil {
+				return nil, err
+			}
+		case "fluentd":
+			tag := sinkURL.Query().Get("tag")
+			if tag == "" {
+				tag = "pachyderm"
+			}
+			pusher = NewFluentdSink(sinkURL.Host, tag)
+		case "stream":
+			if streamSink != nil {
+				return nil, fmt.Errorf("logsink: %q is the second stream sink, there can only be one", rawurl)
+			}
+			streamSink = NewStreamSink()
+			// the stream sink never blocks, it drops events for slow clients
+			pushers = append(pushers, NewFilteredPusher(streamSink, types))
+			continue
+		default:
+			return nil, fmt.Errorf("logsink: unknown sink type %q in %q", sinkURL.Scheme, rawurl)
+		}
+		pushers = append(pushers, NewFilteredPusher(NewAsyncPusher(pusher, rawurl, defaultBufferSize), types))
+	}
+	protolog.SetLogger(protolog.NewStandardLogger(protolog.NewMultiPusher(pushers...)))
+	return streamSink, nil
+}
@@ -0,0 +1,162 @@
+// Code generated by protoc-gen-go.
+// source: pkg/logsink/logsink.proto
+// DO NOT EDIT!
+
+/*
+Package logsink is a generated protocol buffer package.
+
+It is generated from these files:
+	pkg/logsink/logsink.proto
+
+It has these top-level messages:
+	Event
+	StreamEventsRequest
+*/
+package logsink
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import google_protobuf "go.pedge.io/google-protobuf"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// Event is a protolog entry as it's exported to sinks.
+type Event struct {
+	Id        string                     `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Level     string                     `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Type      string                     `protobuf:"bytes,4,opt,name=type" json:"type,omitempty"`
+	Event     string                     `protobuf:"bytes,5,opt,name=event" json:"event,omitempty"`
+	Context   []string                   `protobuf:"bytes,6,rep,name=context" json:"context,omitempty"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+
+func (m *Event) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+type StreamEventsRequest struct {
+	// only stream events of these types, a type ending in "." matches every
+	// type in that package, all events are streamed if it's empty
+	Type []string `protobuf:"bytes,1,rep,name=type" json:"type,omitempty"`
+}
+
+func (m *StreamEventsRequest) Reset()         { *m = StreamEventsRequest{} }
+func (m *StreamEventsRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamEventsRequest) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*Event)(nil), "logsink.Event")
+	proto.RegisterType((*StreamEventsRequest)(nil), "logsink.StreamEventsRequest")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for API service
+
+type APIClient interface {
+	// StreamEvents streams events as they're logged until the client goes away.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (API_StreamEventsClient, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (API_StreamEventsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/logsink.API/StreamEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type aPIStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for API service
+
+type APIServer interface {
+	// StreamEvents streams events as they're logged until the client goes away.
+	StreamEvents(*StreamEventsRequest, API_StreamEventsServer) error
+}
+
+func RegisterAPIServer(s *grpc.Server, srv APIServer) {
+	s.RegisterService(&_API_serviceDesc, srv)
+}
+
+func _API_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).StreamEvents(m, &aPIStreamEventsServer{stream})
+}
+
+type API_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type aPIStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _API_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "logsink.API",
+	HandlerType: (*APIServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _API_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+}
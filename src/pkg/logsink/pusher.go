@@ -0,0 +1,169 @@
+package logsink
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"go.pedge.io/protolog"
+)
+
+const (
+	// the most entries an async pusher holds before it drops them
+	defaultBufferSize = 1024
+)
+
+var (
+	marshaler = &jsonpb.Marshaler{}
+)
+
+// entryToEvent converts entry to the Event sinks export.
+func entryToEvent(entry *protolog.Entry) (*Event, error) {
+	event := &Event{
+		Id:        entry.Id,
+		Level:     strings.TrimPrefix(entry.Level.String(), "LEVEL_"),
+		Timestamp: entry.Timestamp,
+	}
+	if entry.Event != nil {
+		event.Type = entry.Event.Name
+		message, err := entry.UnmarshalledEvent()
+		if err != nil {
+			return nil, err
+		}
+		if event.Event, err = marshaler.MarshalToString(message); err != nil {
+			return nil, err
+		}
+	}
+	contexts, err := entry.UnmarshalledContexts()
+	if err != nil {
+		return nil, err
+	}
+	for _, context := range contexts {
+		encodedContext, err := marshaler.MarshalToString(context)
+		if err != nil {
+			return nil, err
+		}
+		event.Context = append(event.Context, encodedContext)
+	}
+	return event, nil
+}
+
+type jsonMarshaller struct{}
+
+func (jsonMarshaller) Marshal(entry *protolog.Entry) ([]byte, error) {
+	event, err := entryToEvent(entry)
+	if err != nil {
+		return nil, err
+	}
+	encodedEvent, err := marshaler.MarshalToString(event)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encodedEvent), nil
+}
+
+type filteredPusher struct {
+	pusher protolog.Pusher
+	types  []string
+}
+
+func newFilteredPusher(pusher protolog.Pusher, types []string) *filteredPusher {
+	return &filteredPusher{pusher, types}
+}
+
+func (p *filteredPusher) Push(entry *protolog.Entry) error {
+	var eventType string
+	if entry.Event != nil {
+		eventType = entry.Event.Name
+	}
+	if !matches(p.types, eventType) {
+		return nil
+	}
+	return p.pusher.Push(entry)
+}
+
+func (p *filteredPusher) Flush() error {
+	return p.pusher.Flush()
+}
+
+// matches returns true if eventType is one of types, see NewFilteredPusher.
+func matches(types []string, eventType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == eventType || (strings.HasSuffix(t, ".") && strings.HasPrefix(eventType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+type asyncPusher struct {
+	pusher  protolog.Pusher
+	name    string
+	entries chan *protolog.Entry
+	flushes chan chan error
+	dropped chan bool
+}
+
+func newAsyncPusher(pusher protolog.Pusher, name string, bufferSize int) *asyncPusher {
+	p := &asyncPusher{
+		pusher,
+		name,
+		make(chan *protolog.Entry, bufferSize),
+		make(chan chan error),
+		make(chan bool, 1),
+	}
+	go p.run()
+	return p
+}
+
+func (p *asyncPusher) Push(entry *protolog.Entry) error {
+	select {
+	case p.entries <- entry:
+	default:
+		// only the first drop since the last report matters
+		select {
+		case p.dropped <- true:
+		default:
+		}
+	}
+	return nil
+}
+
+func (p *asyncPusher) Flush() error {
+	done := make(chan error)
+	p.flushes <- done
+	return <-done
+}
+
+func (p *asyncPusher) run() {
+	failing := false
+	push := func(entry *protolog.Entry) {
+		err := p.pusher.Push(entry)
+		// the logger can't be used to report a failing sink, it may be what's
+		// failing, so failures are reported when they start and stop
+		if err != nil && !failing {
+			fmt.Fprintf(os.Stderr, "logsink: pushing to %s failed, entries will be dropped until it recovers: %s\n", p.name, err.Error())
+		}
+		if err == nil && failing {
+			fmt.Fprintf(os.Stderr, "logsink: pushing to %s recovered\n", p.name)
+		}
+		failing = err != nil
+	}
+	for {
+		select {
+		case entry := <-p.entries:
+			push(entry)
+		case <-p.dropped:
+			fmt.Fprintf(os.Stderr, "logsink: %s is falling behind, entries were dropped\n", p.name)
+		case done := <-p.flushes:
+			for len(p.entries) > 0 {
+				push(<-p.entries)
+			}
+			done <- p.pusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logsink
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"go.pedge.io/protolog"
+)
+
+type recordingPusher struct {
+	entries []*protolog.Entry
+}
+
+func (p *recordingPusher) Push(entry *protolog.Entry) error {
+	p.entries = append(p.entries, entry)
+	return nil
+}
+
+func (p *recordingPusher) Flush() error {
+	return nil
+}
+
+func TestFilteredPusher(t *testing.T) {
+	t.Parallel()
+	recorder := &recordingPusher{}
+	logger := protolog.NewStandardLogger(NewFilteredPusher(recorder, []string{"logsink."}))
+	logger.Info(&StreamEventsRequest{Type: []string{"a"}})
+	logger.Printf("not exported")
+	require.Equal(t, 1, len(recorder.entries))
+	require.Equal(t, "logsink.StreamEventsRequest", recorder.entries[0].Event.Name)
+
+	require.True(t, matches(nil, "shard.StartRegister"))
+	require.True(t, matches([]string{"fuse.", "shard.StartRegister"}, "shard.StartRegister"))
+	require.False(t, matches([]string{"shard.Start"}, "shard.StartRegister"))
+}
+
+func TestJSONMarshaller(t *testing.T) {
+	t.Parallel()
+	recorder := &recordingPusher{}
+	logger := protolog.NewStandardLogger(recorder)
+	logger.Warn(&StreamEventsRequest{Type: []string{"a"}})
+	data, err := jsonMarshaller{}.Marshal(recorder.entries[0])
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, jsonpb.UnmarshalString(string(data), &event))
+	require.Equal(t, "WARN", event.Level)
+	require.Equal(t, "logsink.StreamEventsRequest", event.Type)
+	require.Equal(t, `{"type":["a"]}`, event.Event)
+}
+
+func TestRecentSink(t *testing.T) {
+	t.Parallel()
+	sink := newRecentSink(2)
+	require.Equal(t, 0, len(sink.recentEntries()))
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, sink.Push(&protolog.Entry{Id: id}))
+	}
+	entries := sink.recentEntries()
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, "b", entries[0].Id)
+	require.Equal(t, "c", entries[1].Id)
+}
@@ -0,0 +1,69 @@
+package logsink
+
+import (
+	"io"
+	"sync"
+
+	"go.pedge.io/protolog"
+)
+
+const (
+	// the most entries the recent sink keeps
+	recentSinkSize = 1000
+)
+
+var (
+	recent = newRecentSink(recentSinkSize)
+)
+
+// recentSink keeps the last entries it's pushed in a ring.
+type recentSink struct {
+	entries []*protolog.Entry
+	// next is where the next entry goes
+	next int
+	full bool
+	lock sync.Mutex
+}
+
+func newRecentSink(size int) *recentSink {
+	return &recentSink{entries: make([]*protolog.Entry, size)}
+}
+
+func (s *recentSink) Push(entry *protolog.Entry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+	return nil
+}
+
+func (s *recentSink) Flush() error {
+	return nil
+}
+
+// recentEntries returns the entries, oldest first.
+func (s *recentSink) recentEntries() []*protolog.Entry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.full {
+		return append([]*protolog.Entry(nil), s.entries[:s.next]...)
+	}
+	return append(append([]*protolog.Entry(nil), s.entries[s.next:]...), s.entries[:s.next]...)
+}
+
+func (s *recentSink) writeEvents(writer io.Writer) error {
+	marshaller := jsonMarshaller{}
+	for _, entry := range s.recentEntries() {
+		data, err := marshaller.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
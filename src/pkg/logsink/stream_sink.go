@@ -0,0 +1,74 @@
+package logsink
+
+import (
+	"sync"
+
+	"go.pedge.io/protolog"
+)
+
+const (
+	// the most events a client of a stream sink can fall behind by before
+	// events are dropped for it
+	streamBufferSize = 256
+)
+
+type streamSink struct {
+	clients map[chan *Event]bool
+	lock    sync.RWMutex
+}
+
+func newStreamSink() *streamSink {
+	return &streamSink{
+		make(map[chan *Event]bool),
+		sync.RWMutex{},
+	}
+}
+
+func (s *streamSink) Push(entry *protolog.Entry) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if len(s.clients) == 0 {
+		return nil
+	}
+	event, err := entryToEvent(entry)
+	if err != nil {
+		return err
+	}
+	for client := range s.clients {
+		// a slow client misses events rather than slowing down logging
+		select {
+		case client <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *streamSink) Flush() error {
+	return nil
+}
+
+func (s *streamSink) StreamEvents(request *StreamEventsRequest, server API_StreamEventsServer) error {
+	client := make(chan *Event, streamBufferSize)
+	s.lock.Lock()
+	s.clients[client] = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.clients, client)
+	}()
+	for {
+		select {
+		case event := <-client:
+			if !matches(request.Type, event.Type) {
+				continue
+			}
+			if err := server.Send(event); err != nil {
+				return err
+			}
+		case <-server.Context().Done():
+			return server.Context().Err()
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package obj
 
 import (
+	"encoding/hex"
+	"fmt"
 	"io"
 
 	"golang.org/x/net/context"
@@ -31,3 +33,25 @@ func (c *googleClient) Reader(name string) (io.ReadCloser, error) {
 func (c *googleClient) Delete(name string) error {
 	return c.bucket.Object(name).Delete(c.ctx)
 }
+
+func (c *googleClient) Walk(prefix string, fn func(*Object) error) error {
+	query := &storage.Query{Prefix: prefix}
+	for query != nil {
+		objectList, err := c.bucket.List(c.ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, objectAttrs := range objectList.Results {
+			// composite objects have no md5
+			hash := hex.EncodeToString(objectAttrs.MD5)
+			if hash == "" {
+				hash = fmt.Sprintf("crc32c-%08x", objectAttrs.CRC32C)
+			}
+			if err := fn(&Object{objectAttrs.Name, objectAttrs.Size, hash}); err != nil {
+				return err
+			}
+		}
+		query = objectList.Next
+	}
+	return nil
+}
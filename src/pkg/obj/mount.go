@@ -0,0 +1,218 @@
+package obj
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+)
+
+// Mount serves objects read only at mountPoint, each as the file named by
+// the part of its name after prefix. Objects are read from client as they're
+// read from the mount, nothing is copied ahead of time. Mount blocks until
+// mountPoint is unmounted, ready is closed once it's mounted.
+func Mount(client Client, prefix string, objects []*Object, mountPoint string, ready chan bool) (retErr error) {
+	var once sync.Once
+	defer once.Do(func() {
+		if ready != nil {
+			close(ready)
+		}
+	})
+	root, err := newMountTree(client, prefix, objects)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(mountPoint, 0777); err != nil {
+		return err
+	}
+	conn, err := fuse.Mount(
+		mountPoint,
+		fuse.FSName("obj"),
+		fuse.Subtype("obj"),
+		fuse.AllowOther(),
+		fuse.ReadOnly(),
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	once.Do(func() {
+		if ready != nil {
+			close(ready)
+		}
+	})
+	if err := fs.Serve(conn, &mountFS{root}); err != nil {
+		return err
+	}
+	<-conn.Ready
+	return conn.MountError
+}
+
+// Unmount unmounts a mountPoint mounted by Mount.
+func Unmount(mountPoint string) error {
+	return fuse.Unmount(mountPoint)
+}
+
+type mountFS struct {
+	root *mountDir
+}
+
+func (f *mountFS) Root() (fs.Node, error) {
+	return f.root, nil
+}
+
+type mountDir struct {
+	inode    uint64
+	children map[string]fs.Node // *mountDir or *mountFile
+}
+
+type mountFile struct {
+	inode  uint64
+	client Client
+	object *Object
+}
+
+// newMountTree returns the directory tree of objects, names are split into
+// directories on "/".
+func newMountTree(client Client, prefix string, objects []*Object) (*mountDir, error) {
+	// fuse reserves inode 1 for the root
+	inode := uint64(1)
+	root := &mountDir{inode, make(map[string]fs.Node)}
+	for _, object := range objects {
+		parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(object.Name, prefix), "/"), "/")
+		dir := root
+		for i, part := range parts {
+			if part == "" {
+				// "directory" placeholder objects and names with empty
+				// parts can't be served as files
+				break
+			}
+			child, ok := dir.children[part]
+			if i == len(parts)-1 {
+				if ok {
+					return nil, fmt.Errorf("%s is both a file and a directory", object.Name)
+				}
+				inode++
+				dir.children[part] = &mountFile{inode, client, object}
+				break
+			}
+			if !ok {
+				inode++
+				child = &mountDir{inode, make(map[string]fs.Node)}
+				dir.children[part] = child
+			}
+			childDir, ok := child.(*mountDir)
+			if !ok {
+				return nil, fmt.Errorf("%s is both a file and a directory", object.Name)
+			}
+			dir = childDir
+		}
+	}
+	return root, nil
+}
+
+func (d *mountDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = d.inode
+	a.Mode = os.ModeDir | 0555
+	return nil
+}
+
+func (d *mountDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	child, ok := d.children[name]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+	return child, nil
+}
+
+func (d *mountDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var names []string
+	for name := range d.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var dirents []fuse.Dirent
+	for _, name := range names {
+		switch child := d.children[name].(type) {
+		case *mountDir:
+			dirents = append(dirents, fuse.Dirent{Inode: child.inode, Name: name, Type: fuse.DT_Dir})
+		case *mountFile:
+			dirents = append(dirents, fuse.Dirent{Inode: child.inode, Name: name, Type: fuse.DT_File})
+		}
+	}
+	return dirents, nil
+}
+
+func (f *mountFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = f.inode
+	a.Mode = 0444
+	a.Size = uint64(f.object.Size)
+	return nil
+}
+
+func (f *mountFile) Open(ctx context.Context, request *fuse.OpenRequest, response *fuse.OpenResponse) (fs.Handle, error) {
+	return &mountHandle{file: f}, nil
+}
+
+// mountHandle reads an object in one stream as long as it's read in order,
+// which is how most transforms read, a read anywhere else reopens the
+// object and skips to the offset.
+type mountHandle struct {
+	file   *mountFile
+	reader io.ReadCloser
+	offset int64
+	lock   sync.Mutex
+}
+
+func (h *mountHandle) Read(ctx context.Context, request *fuse.ReadRequest, response *fuse.ReadResponse) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.reader == nil || request.Offset != h.offset {
+		if err := h.close(); err != nil {
+			return err
+		}
+		reader, err := h.file.client.Reader(h.file.object.Name)
+		if err != nil {
+			return err
+		}
+		h.reader = reader
+		if _, err := io.CopyN(ioutil.Discard, h.reader, request.Offset); err != nil && err != io.EOF {
+			return err
+		}
+		h.offset = request.Offset
+	}
+	data := make([]byte, request.Size)
+	n, err := io.ReadFull(h.reader, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	h.offset += int64(n)
+	response.Data = data[:n]
+	return nil
+}
+
+func (h *mountHandle) Release(ctx context.Context, request *fuse.ReleaseRequest) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.close()
+}
+
+func (h *mountHandle) close() error {
+	if h.reader == nil {
+		return nil
+	}
+	err := h.reader.Close()
+	h.reader = nil
+	return err
+}
@@ -1,7 +1,9 @@
 package obj
 
 import (
+	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/net/context"
 )
@@ -10,8 +12,55 @@ type Client interface {
 	Writer(name string) (io.WriteCloser, error)
 	Reader(name string) (io.ReadCloser, error)
 	Delete(name string) error
+	// Walk calls fn with each object whose name starts with prefix, in
+	// order of name.
+	Walk(prefix string, fn func(*Object) error) error
+}
+
+// Object is an object in a bucket.
+type Object struct {
+	Name string
+	Size int64
+	// Hash changes when the object's content does, it's "" if the store
+	// doesn't give one.
+	Hash string
 }
 
 func NewClientGoogleClient(ctx context.Context, bucket string) (Client, error) {
 	return newGoogleClient(ctx, bucket)
 }
+
+// NewClientFromURL returns a client for the bucket of url, which is
+// gs://bucket/prefix, and the url's prefix.
+func NewClientFromURL(ctx context.Context, url string) (Client, string, error) {
+	bucket, prefix, err := ParseURL(url)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := NewClientGoogleClient(ctx, bucket)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, prefix, nil
+}
+
+// ParseURL returns the bucket and prefix of a gs:// url.
+func ParseURL(url string) (string, string, error) {
+	if strings.HasPrefix(url, "s3://") {
+		// TODO the vendored aws-sdk-go is missing packages the s3
+		// client needs
+		return "", "", fmt.Errorf("%s: s3 isn't supported yet, only gs://", url)
+	}
+	if !strings.HasPrefix(url, "gs://") {
+		return "", "", fmt.Errorf("%s isn't a gs:// url", url)
+	}
+	rest := strings.TrimPrefix(url, "gs://")
+	parts := strings.SplitN(rest, "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("%s has no bucket", url)
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
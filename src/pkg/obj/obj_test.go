@@ -0,0 +1,39 @@
+package obj
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestParseURL(t *testing.T) {
+	bucket, prefix, err := ParseURL("gs://bucket/a/b")
+	require.NoError(t, err)
+	require.Equal(t, "bucket", bucket)
+	require.Equal(t, "a/b", prefix)
+	bucket, prefix, err = ParseURL("gs://bucket")
+	require.NoError(t, err)
+	require.Equal(t, "bucket", bucket)
+	require.Equal(t, "", prefix)
+	_, _, err = ParseURL("gs:///a")
+	require.NotNil(t, err)
+	_, _, err = ParseURL("http://bucket/a")
+	require.NotNil(t, err)
+}
+
+func TestNewMountTree(t *testing.T) {
+	root, err := newMountTree(nil, "data/", []*Object{
+		{"data/a", 1, ""},
+		{"data/dir/", 0, ""},
+		{"data/dir/b", 2, ""},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(root.children))
+	dir, ok := root.children["dir"].(*mountDir)
+	require.True(t, ok)
+	file, ok := dir.children["b"].(*mountFile)
+	require.True(t, ok)
+	require.Equal(t, int64(2), file.object.Size)
+	_, err = newMountTree(nil, "", []*Object{{"a", 1, ""}, {"a/b", 1, ""}})
+	require.NotNil(t, err)
+}
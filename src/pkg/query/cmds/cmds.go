@@ -0,0 +1,102 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/feature"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func Cmds(address string) ([]*cobra.Command, error) {
+	var tables []string
+	queryCmd := &cobra.Command{
+		Use:   "query sql",
+		Short: "Run a read-only SQL query over files in pfs.",
+		Long: `Run a read-only SQL query over files in pfs.
+
+Each table the query reads must be passed with --table name=repo/commit-id/path,
+files ending in .json, .jsonl or .ndjson are read as one JSON object per record
+and anything else as CSV with a header row.`,
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			request := &query.QueryRequest{
+				Query: args[0],
+			}
+			for _, table := range tables {
+				parsedTable, err := parseTable(table)
+				if err != nil {
+					return err
+				}
+				request.Table = append(request.Table, parsedTable)
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			result, err := apiClient.Query(context.Background(), request)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprintf(writer, "%s\t\n", strings.Join(result.Column, "\t"))
+			for _, row := range result.Row {
+				fmt.Fprintf(writer, "%s\t\n", strings.Join(row.Value, "\t"))
+			}
+			return writer.Flush()
+		}),
+	}
+	queryCmd.Flags().StringSliceVarP(&tables, "table", "t", nil, "A table the query reads, as name=repo/commit-id/path.")
+
+	var result []*cobra.Command
+	result = append(result, queryCmd)
+	return result, nil
+}
+
+func parseTable(table string) (*query.Table, error) {
+	nameAndFile := strings.SplitN(table, "=", 2)
+	if len(nameAndFile) != 2 {
+		return nil, fmt.Errorf("malformed table %s, tables must be of the form name=repo/commit-id/path", table)
+	}
+	file := strings.SplitN(nameAndFile[1], "/", 3)
+	if len(file) != 3 || file[0] == "" || file[1] == "" || file[2] == "" {
+		return nil, fmt.Errorf("malformed table %s, tables must be of the form name=repo/commit-id/path", table)
+	}
+	format := query.TableFormat_TABLE_FORMAT_CSV
+	switch path.Ext(file[2]) {
+	case ".json", ".jsonl", ".ndjson":
+		format = query.TableFormat_TABLE_FORMAT_JSON
+	}
+	return &query.Table{
+		Name: nameAndFile[0],
+		File: &pfs.File{
+			Commit: &pfs.Commit{
+				Repo: &pfs.Repo{Name: file[0]},
+				Id:   file[1],
+			},
+			Path: file[2],
+		},
+		Format: format,
+	}, nil
+}
+
+func getAPIClient(address string) (query.APIClient, error) {
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	// lets users opt in to features which are being rolled out gradually
+	if features := os.Getenv("PACHYDERM_FEATURES"); features != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(feature.NewFeatureCredentials(strings.Split(features, ",")...)))
+	}
+	clientConn, err := grpc.Dial(address, grpcutil.DialOptions(opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return query.NewAPIClient(clientConn), nil
+}
@@ -0,0 +1,183 @@
+// Code generated by protoc-gen-go.
+// source: pkg/query/query.proto
+// DO NOT EDIT!
+
+/*
+Package query is a generated protocol buffer package.
+
+It is generated from these files:
+	pkg/query/query.proto
+
+It has these top-level messages:
+	Table
+	QueryRequest
+	Row
+	Result
+*/
+package query
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import pfs "github.com/pachyderm/pachyderm/src/pfs"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// TableFormat is how a table's file is read, Parquet isn't supported.
+type TableFormat int32
+
+const (
+	TableFormat_TABLE_FORMAT_CSV  TableFormat = 0
+	TableFormat_TABLE_FORMAT_JSON TableFormat = 1
+)
+
+var TableFormat_name = map[int32]string{
+	0: "TABLE_FORMAT_CSV",
+	1: "TABLE_FORMAT_JSON",
+}
+var TableFormat_value = map[string]int32{
+	"TABLE_FORMAT_CSV":  0,
+	"TABLE_FORMAT_JSON": 1,
+}
+
+func (x TableFormat) String() string {
+	return proto.EnumName(TableFormat_name, int32(x))
+}
+
+// Table makes a file queryable under name.
+type Table struct {
+	Name   string              `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	File   *pfs.File `protobuf:"bytes,2,opt,name=file" json:"file,omitempty"`
+	Format TableFormat         `protobuf:"varint,3,opt,name=format,enum=query.TableFormat" json:"format,omitempty"`
+}
+
+func (m *Table) Reset()         { *m = Table{} }
+func (m *Table) String() string { return proto.CompactTextString(m) }
+func (*Table) ProtoMessage()    {}
+
+func (m *Table) GetFile() *pfs.File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type QueryRequest struct {
+	Query string   `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+	Table []*Table `protobuf:"bytes,2,rep,name=table" json:"table,omitempty"`
+}
+
+func (m *QueryRequest) Reset()         { *m = QueryRequest{} }
+func (m *QueryRequest) String() string { return proto.CompactTextString(m) }
+func (*QueryRequest) ProtoMessage()    {}
+
+func (m *QueryRequest) GetTable() []*Table {
+	if m != nil {
+		return m.Table
+	}
+	return nil
+}
+
+type Row struct {
+	Value []string `protobuf:"bytes,1,rep,name=value" json:"value,omitempty"`
+}
+
+func (m *Row) Reset()         { *m = Row{} }
+func (m *Row) String() string { return proto.CompactTextString(m) }
+func (*Row) ProtoMessage()    {}
+
+type Result struct {
+	Column      []string `protobuf:"bytes,1,rep,name=column" json:"column,omitempty"`
+	Row         []*Row   `protobuf:"bytes,2,rep,name=row" json:"row,omitempty"`
+	RowsScanned uint64   `protobuf:"varint,3,opt,name=rows_scanned" json:"rows_scanned,omitempty"`
+}
+
+func (m *Result) Reset()         { *m = Result{} }
+func (m *Result) String() string { return proto.CompactTextString(m) }
+func (*Result) ProtoMessage()    {}
+
+func (m *Result) GetRow() []*Row {
+	if m != nil {
+		return m.Row
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Table)(nil), "query.Table")
+	proto.RegisterType((*QueryRequest)(nil), "query.QueryRequest")
+	proto.RegisterType((*Row)(nil), "query.Row")
+	proto.RegisterType((*Result)(nil), "query.Result")
+	proto.RegisterEnum("query.TableFormat", TableFormat_name, TableFormat_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// Client API for API service
+
+type APIClient interface {
+	// Query answers a SELECT statement over files in pfs.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*Result, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := grpc.Invoke(ctx, "/query.API/Query", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for API service
+
+type APIServer interface {
+	// Query answers a SELECT statement over files in pfs.
+	Query(context.Context, *QueryRequest) (*Result, error)
+}
+
+func RegisterAPIServer(s *grpc.Server, srv APIServer) {
+	s.RegisterService(&_API_serviceDesc, srv)
+}
+
+func _API_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).Query(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var _API_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "query.API",
+	HandlerType: (*APIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Query",
+			Handler:    _API_Query_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
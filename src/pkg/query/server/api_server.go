@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/feature"
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+	"go.pedge.io/proto/rpclog"
+	"golang.org/x/net/context"
+)
+
+type apiServer struct {
+	protorpclog.Logger
+	pfsAPIClient  pfs.APIClient
+	auditRecorder audit.Recorder
+	featureFlags  feature.Flags
+}
+
+func newAPIServer(pfsAPIClient pfs.APIClient, auditRecorder audit.Recorder, featureFlags feature.Flags) *apiServer {
+	return &apiServer{
+		protorpclog.NewLogger("query.API"),
+		pfsAPIClient,
+		auditRecorder,
+		featureFlags,
+	}
+}
+
+func (a *apiServer) Query(ctx context.Context, request *query.QueryRequest) (response *query.Result, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if err := a.featureFlags.Check(ctx, feature.Query); err != nil {
+		return nil, err
+	}
+	statement, err := parse(request.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query.server: %s", err.Error())
+	}
+	var table *query.Table
+	for _, candidate := range request.Table {
+		if candidate.Name == statement.table {
+			table = candidate
+		}
+	}
+	if table == nil {
+		return nil, fmt.Errorf("query.server: table %s not found, tables must be passed with the query", statement.table)
+	}
+	if table.File == nil || table.File.Commit == nil || table.File.Commit.Repo == nil {
+		return nil, fmt.Errorf("query.server: table %s has no file", table.Name)
+	}
+	// the table is read by pfsAPIClient, which pfs audits as whoever it
+	// belongs to, this records who it's really read for
+	if repo := table.File.Commit.Repo.Name; a.auditRecorder.Sensitive(repo) {
+		defer func() {
+			a.auditRecorder.Record(ctx, "pachyderm.query.API.Query", repo, request, retErr)
+		}()
+	}
+	// there's no Parquet reader, reading one as CSV would answer with garbage
+	if path.Ext(table.File.Path) == ".parquet" {
+		return nil, fmt.Errorf("query.server: table %s is a Parquet file, only CSV and JSON tables are supported", table.Name)
+	}
+	// the file is streamed rather than read up front so that queries which
+	// stop early, ie because of a LIMIT, don't read the whole thing
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(pfsutil.GetFile(
+			a.pfsAPIClient,
+			table.File.Commit.Repo.Name,
+			table.File.Commit.Id,
+			table.File.Path,
+			0,
+			math.MaxInt64,
+			nil,
+			writer,
+		))
+	}()
+	defer func() {
+		if err := reader.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	rowReader, err := newRowReader(reader, table.Format)
+	if err != nil {
+		return nil, err
+	}
+	if table.Format == query.TableFormat_TABLE_FORMAT_CSV {
+		// JSON objects don't have to share keys so a column missing from a
+		// JSON table is just empty, CSV columns are fixed by the header
+		header := make(map[string]bool)
+		for _, column := range rowReader.Columns() {
+			header[column] = true
+		}
+		for column := range statement.columns {
+			if !header[column] {
+				return nil, fmt.Errorf("query.server: table %s has no column %s", table.Name, column)
+			}
+		}
+	}
+	return statement.execute(rowReader)
+}
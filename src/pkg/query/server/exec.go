@@ -0,0 +1,284 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+)
+
+// execute runs s over the rows read from reader.
+func (s *statement) execute(reader rowReader) (*query.Result, error) {
+	result := &query.Result{}
+	var rows [][]string
+	var err error
+	if s.grouped() {
+		rows, err = s.executeGrouped(reader, result)
+	} else {
+		rows, err = s.executeUngrouped(reader, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.fields == nil {
+		result.Column = reader.Columns()
+		// columns can first appear after earlier rows were projected
+		for i, row := range rows {
+			for len(row) < len(result.Column) {
+				row = append(row, "")
+			}
+			rows[i] = row
+		}
+	} else {
+		for _, field := range s.fields {
+			result.Column = append(result.Column, field.name())
+		}
+	}
+	columns := append(append([]string{}, result.Column...), s.sortColumns...)
+	if err := s.sort(columns, rows); err != nil {
+		return nil, err
+	}
+	if s.limit >= 0 && int64(len(rows)) > s.limit {
+		rows = rows[:s.limit]
+	}
+	for _, row := range rows {
+		result.Row = append(result.Row, &query.Row{Value: row[:len(row)-len(s.sortColumns)]})
+	}
+	return result, nil
+}
+
+func (s *statement) grouped() bool {
+	if len(s.groupBy) > 0 {
+		return true
+	}
+	for _, field := range s.fields {
+		if field.aggregate != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *statement) executeUngrouped(reader rowReader, result *query.Result) ([][]string, error) {
+	var rows [][]string
+	for {
+		// without an ORDER BY the first matches are the answer and the rest
+		// of the table doesn't need to be read
+		if s.limit >= 0 && s.orderBy == nil && int64(len(rows)) == s.limit {
+			return rows, nil
+		}
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.RowsScanned++
+		if s.where != nil && !s.where.match(row) {
+			continue
+		}
+		var values []string
+		if s.fields == nil {
+			for _, column := range reader.Columns() {
+				values = append(values, row[column])
+			}
+		} else {
+			for _, field := range s.fields {
+				values = append(values, row[field.column])
+			}
+			for _, column := range s.sortColumns {
+				values = append(values, row[column])
+			}
+		}
+		rows = append(rows, values)
+	}
+}
+
+type group struct {
+	values       []string // the group's GROUP BY values, in order
+	accumulators []*accumulator
+}
+
+func (s *statement) executeGrouped(reader rowReader, result *query.Result) ([][]string, error) {
+	groups := make(map[string]*group)
+	// groups are output in the order they're first seen
+	var keys []string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.RowsScanned++
+		if s.where != nil && !s.where.match(row) {
+			continue
+		}
+		var values []string
+		for _, column := range s.groupBy {
+			values = append(values, row[column])
+		}
+		key := strings.Join(values, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = s.newGroup(values)
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		for i, field := range s.fields {
+			if field.aggregate == "" {
+				continue
+			}
+			if err := g.accumulators[i].add(field, row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// aggregates over no rows still have an answer, ie COUNT(*) is 0
+	if len(keys) == 0 && len(s.groupBy) == 0 {
+		groups[""] = s.newGroup(nil)
+		keys = append(keys, "")
+	}
+	var rows [][]string
+	for _, key := range keys {
+		g := groups[key]
+		var values []string
+		for i, field := range s.fields {
+			if field.aggregate == "" {
+				values = append(values, g.values[indexOf(s.groupBy, field.column)])
+			} else {
+				values = append(values, g.accumulators[i].result(field))
+			}
+		}
+		for _, column := range s.sortColumns {
+			values = append(values, g.values[indexOf(s.groupBy, column)])
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func (s *statement) newGroup(values []string) *group {
+	g := &group{values: values}
+	for range s.fields {
+		g.accumulators = append(g.accumulators, &accumulator{})
+	}
+	return g
+}
+
+// accumulator computes an aggregate of a group.
+type accumulator struct {
+	count int64
+	sum   float64
+	min   string
+	max   string
+}
+
+func (a *accumulator) add(field *field, row map[string]string) error {
+	if field.column == "" {
+		a.count++
+		return nil
+	}
+	value := row[field.column]
+	// empty values are null and so are left out of aggregates
+	if value == "" {
+		return nil
+	}
+	switch field.aggregate {
+	case "SUM", "AVG":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s of %s can't include %q, it isn't a number", field.aggregate, field.column, value)
+		}
+		a.sum += number
+	case "MIN", "MAX":
+		if a.count == 0 || compareValues(value, a.min) < 0 {
+			a.min = value
+		}
+		if a.count == 0 || compareValues(value, a.max) > 0 {
+			a.max = value
+		}
+	}
+	a.count++
+	return nil
+}
+
+func (a *accumulator) result(field *field) string {
+	switch field.aggregate {
+	case "COUNT":
+		return strconv.FormatInt(a.count, 10)
+	case "SUM":
+		return formatNumber(a.sum)
+	case "AVG":
+		if a.count == 0 {
+			return ""
+		}
+		return formatNumber(a.sum / float64(a.count))
+	case "MIN":
+		return a.min
+	}
+	return a.max
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// sort sorts rows, whose columns are named columns, by s's ORDER BY.
+func (s *statement) sort(columns []string, rows [][]string) error {
+	if s.orderBy == nil {
+		return nil
+	}
+	sorter := &rowSorter{rows: rows}
+	for _, ordering := range s.orderBy {
+		index := indexOf(columns, ordering.column)
+		if index == -1 {
+			return fmt.Errorf("no column named %s", ordering.column)
+		}
+		sorter.indexes = append(sorter.indexes, index)
+		sorter.descending = append(sorter.descending, ordering.descending)
+	}
+	sort.Stable(sorter)
+	return nil
+}
+
+type rowSorter struct {
+	rows       [][]string
+	indexes    []int
+	descending []bool
+}
+
+func (s *rowSorter) Len() int {
+	return len(s.rows)
+}
+
+func (s *rowSorter) Less(i, j int) bool {
+	for k, index := range s.indexes {
+		result := compareValues(s.rows[i][index], s.rows[j][index])
+		if s.descending[k] {
+			result = -result
+		}
+		if result != 0 {
+			return result < 0
+		}
+	}
+	return false
+}
+
+func (s *rowSorter) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/feature"
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+)
+
+type APIServer interface {
+	query.APIServer
+}
+
+// NewAPIServer returns a new APIServer which reads the tables it queries from
+// pfs using pfsAPIClient. Queries of tables in sensitive repos are recorded
+// with auditRecorder as their caller. It only serves queries while
+// featureFlags has feature.Query on.
+func NewAPIServer(pfsAPIClient pfs.APIClient, auditRecorder audit.Recorder, featureFlags feature.Flags) APIServer {
+	return newAPIServer(pfsAPIClient, auditRecorder, featureFlags)
+}
@@ -0,0 +1,677 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The SQL understood here is a single table SELECT:
+//
+//	SELECT fields FROM table
+//	    [WHERE condition]
+//	    [GROUP BY column, ...]
+//	    [ORDER BY column [ASC|DESC], ...]
+//	    [LIMIT n]
+//
+// ORDER BY can name fields, by alias, or columns, grouped statements can only
+// be ordered by fields and GROUP BY columns.
+//
+// A field is *, a column or one of COUNT(*), COUNT, SUM, AVG, MIN and MAX
+// of a column, optionally followed by [AS] alias. Conditions compare columns
+// and literals with =, !=, <>, <, <=, >, >= and [NOT] LIKE and combine with
+// AND, OR, NOT and parentheses. Values are compared as numbers when both
+// sides are numbers and as strings otherwise. Empty values are null, they
+// only match = '' and are left out of aggregates.
+
+var (
+	keywords = map[string]bool{
+		"SELECT": true,
+		"FROM":   true,
+		"WHERE":  true,
+		"GROUP":  true,
+		"ORDER":  true,
+		"BY":     true,
+		"LIMIT":  true,
+		"AND":    true,
+		"OR":     true,
+		"NOT":    true,
+		"LIKE":   true,
+		"AS":     true,
+		"ASC":    true,
+		"DESC":   true,
+	}
+	aggregates = map[string]bool{
+		"COUNT": true,
+		"SUM":   true,
+		"AVG":   true,
+		"MIN":   true,
+		"MAX":   true,
+	}
+	comparisons = map[string]bool{
+		"=":  true,
+		"!=": true,
+		"<>": true,
+		"<":  true,
+		"<=": true,
+		">":  true,
+		">=": true,
+	}
+)
+
+type statement struct {
+	fields  []*field // nil for SELECT *
+	table   string
+	where   condition // nil if there's no WHERE
+	groupBy []string
+	orderBy []*ordering
+	// ORDER BY columns which aren't fields, they're added to the end of each
+	// row for sorting and dropped before the result is returned
+	sortColumns []string
+	limit       int64 // -1 if there's no LIMIT
+	// every column the statement refers to
+	columns map[string]bool
+}
+
+type field struct {
+	column    string // "" for COUNT(*)
+	aggregate string // "" if the field isn't an aggregate
+	alias     string
+}
+
+// name is the name of the result column for f.
+func (f *field) name() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	if f.aggregate == "" {
+		return f.column
+	}
+	column := f.column
+	if column == "" {
+		column = "*"
+	}
+	return fmt.Sprintf("%s(%s)", strings.ToLower(f.aggregate), column)
+}
+
+type ordering struct {
+	column     string
+	descending bool
+}
+
+// condition is a WHERE clause, or part of one.
+type condition interface {
+	match(row map[string]string) bool
+}
+
+type andCondition struct {
+	left  condition
+	right condition
+}
+
+func (c *andCondition) match(row map[string]string) bool {
+	return c.left.match(row) && c.right.match(row)
+}
+
+type orCondition struct {
+	left  condition
+	right condition
+}
+
+func (c *orCondition) match(row map[string]string) bool {
+	return c.left.match(row) || c.right.match(row)
+}
+
+type notCondition struct {
+	condition condition
+}
+
+func (c *notCondition) match(row map[string]string) bool {
+	return !c.condition.match(row)
+}
+
+type compareCondition struct {
+	left     operand
+	operator string
+	right    operand
+}
+
+func (c *compareCondition) match(row map[string]string) bool {
+	left := c.left.value(row)
+	right := c.right.value(row)
+	result := compareValues(left, right)
+	switch c.operator {
+	case "=":
+		return result == 0
+	case "!=", "<>":
+		return result != 0
+	}
+	// empty values are null, they aren't less or greater than anything
+	if left == "" || right == "" {
+		return false
+	}
+	switch c.operator {
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	default:
+		return result >= 0
+	}
+}
+
+type likeCondition struct {
+	operand operand
+	pattern *regexp.Regexp
+}
+
+func (c *likeCondition) match(row map[string]string) bool {
+	return c.pattern.MatchString(c.operand.value(row))
+}
+
+// operand is one side of a comparison.
+type operand interface {
+	value(row map[string]string) string
+}
+
+type columnOperand string
+
+func (o columnOperand) value(row map[string]string) string {
+	return row[string(o)]
+}
+
+type literalOperand string
+
+func (o literalOperand) value(row map[string]string) string {
+	return string(o)
+}
+
+// compareValues compares a and b as numbers if they both are and as strings
+// otherwise.
+func compareValues(a string, b string) int {
+	x, xErr := strconv.ParseFloat(a, 64)
+	y, yErr := strconv.ParseFloat(b, 64)
+	if xErr == nil && yErr == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// quoted identifiers are never keywords
+	quoted bool
+}
+
+func tokenize(query string) ([]token, error) {
+	var result []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			result = append(result, token{kind: tokenIdentifier, text: string(runes[start:i])})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])) || r == '.':
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, fmt.Errorf("malformed number %s", text)
+			}
+			result = append(result, token{kind: tokenNumber, text: text})
+		case r == '\'' || r == '"' || r == '`':
+			// quotes are escaped by doubling them, as in 'it''s'
+			var text []rune
+			i++
+			for {
+				if i == len(runes) {
+					return nil, fmt.Errorf("unterminated %c", r)
+				}
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						text = append(text, r)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				text = append(text, runes[i])
+				i++
+			}
+			if r == '\'' {
+				result = append(result, token{kind: tokenString, text: string(text)})
+			} else {
+				result = append(result, token{kind: tokenIdentifier, text: string(text), quoted: true})
+			}
+		default:
+			if i+1 < len(runes) && comparisons[string(runes[i:i+2])] {
+				result = append(result, token{kind: tokenSymbol, text: string(runes[i : i+2])})
+				i += 2
+				continue
+			}
+			if !comparisons[string(r)] && !strings.ContainsRune(",()*", r) {
+				return nil, fmt.Errorf("unexpected %c", r)
+			}
+			result = append(result, token{kind: tokenSymbol, text: string(r)})
+			i++
+		}
+	}
+	return append(result, token{kind: tokenEOF}), nil
+}
+
+type parser struct {
+	tokens    []token
+	position  int
+	statement *statement
+}
+
+// parse parses query, see the top of this file for what's supported.
+func parse(query string) (*statement, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		tokens: tokens,
+		statement: &statement{
+			limit:   -1,
+			columns: make(map[string]bool),
+		},
+	}
+	if err := p.parseStatement(); err != nil {
+		return nil, err
+	}
+	return p.statement, nil
+}
+
+func (p *parser) parseStatement() error {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return err
+	}
+	if err := p.parseFields(); err != nil {
+		return err
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return err
+	}
+	table, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	p.statement.table = table
+	if p.keyword("WHERE") {
+		where, err := p.parseOr()
+		if err != nil {
+			return err
+		}
+		p.statement.where = where
+	}
+	if p.keyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return err
+		}
+		for {
+			column, err := p.column()
+			if err != nil {
+				return err
+			}
+			p.statement.groupBy = append(p.statement.groupBy, column)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return err
+		}
+		for {
+			column, err := p.identifier()
+			if err != nil {
+				return err
+			}
+			ordering := &ordering{column: column}
+			if p.keyword("DESC") {
+				ordering.descending = true
+			} else {
+				p.keyword("ASC")
+			}
+			p.statement.orderBy = append(p.statement.orderBy, ordering)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		token := p.next()
+		limit, err := strconv.ParseInt(token.text, 10, 64)
+		if token.kind != tokenNumber || err != nil || limit < 0 {
+			return fmt.Errorf("LIMIT must be followed by a non-negative integer, found %s", describe(token))
+		}
+		p.statement.limit = limit
+	}
+	if token := p.next(); token.kind != tokenEOF {
+		return fmt.Errorf("unexpected %s", describe(token))
+	}
+	return p.check()
+}
+
+func (p *parser) parseFields() error {
+	if p.symbol("*") {
+		return nil
+	}
+	for {
+		field := &field{}
+		token := p.peek()
+		if token.kind == tokenIdentifier && !token.quoted && aggregates[strings.ToUpper(token.text)] &&
+			p.tokens[p.position+1].text == "(" {
+			p.position += 2
+			field.aggregate = strings.ToUpper(token.text)
+			if !p.symbol("*") {
+				column, err := p.column()
+				if err != nil {
+					return err
+				}
+				field.column = column
+			} else if field.aggregate != "COUNT" {
+				return fmt.Errorf("%s(*) isn't supported, only COUNT(*) is", field.aggregate)
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return err
+			}
+		} else {
+			column, err := p.column()
+			if err != nil {
+				return err
+			}
+			field.column = column
+		}
+		if p.keyword("AS") || (p.peek().kind == tokenIdentifier && !p.isKeyword(p.peek())) {
+			alias, err := p.identifier()
+			if err != nil {
+				return err
+			}
+			field.alias = alias
+		}
+		p.statement.fields = append(p.statement.fields, field)
+		if !p.symbol(",") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.keyword("NOT") {
+		condition, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notCondition{condition}, nil
+	}
+	if p.symbol("(") {
+		condition, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return condition, nil
+	}
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	not := p.keyword("NOT")
+	if p.keyword("LIKE") {
+		token := p.next()
+		if token.kind != tokenString {
+			return nil, fmt.Errorf("LIKE must be followed by a string, found %s", describe(token))
+		}
+		var condition condition = &likeCondition{left, likePattern(token.text)}
+		if not {
+			condition = &notCondition{condition}
+		}
+		return condition, nil
+	}
+	if not {
+		return nil, fmt.Errorf("NOT must be followed by LIKE, found %s", describe(p.peek()))
+	}
+	token := p.next()
+	if token.kind != tokenSymbol || !comparisons[token.text] {
+		return nil, fmt.Errorf("expected a comparison, found %s", describe(token))
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return &compareCondition{left, token.text, right}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	switch token := p.peek(); token.kind {
+	case tokenString, tokenNumber:
+		p.position++
+		return literalOperand(token.text), nil
+	}
+	column, err := p.column()
+	if err != nil {
+		return nil, err
+	}
+	return columnOperand(column), nil
+}
+
+// check makes sure the statement's fields, GROUP BY and ORDER BY agree.
+func (p *parser) check() error {
+	s := p.statement
+	if !s.grouped() {
+		return s.checkOrderBy()
+	}
+	if s.fields == nil {
+		return fmt.Errorf("SELECT * can't be used with GROUP BY or aggregates")
+	}
+	groupBy := make(map[string]bool)
+	for _, column := range s.groupBy {
+		groupBy[column] = true
+	}
+	for _, field := range s.fields {
+		if field.aggregate == "" && !groupBy[field.column] {
+			return fmt.Errorf("%s must be in GROUP BY or used in an aggregate", field.column)
+		}
+	}
+	return s.checkOrderBy()
+}
+
+// checkOrderBy makes sure the statement is only ordered by fields or columns
+// which have a single value per result row, grouped statements can only be
+// ordered by fields and GROUP BY columns.
+func (s *statement) checkOrderBy() error {
+	if s.fields == nil {
+		for _, ordering := range s.orderBy {
+			s.columns[ordering.column] = true
+		}
+		return nil
+	}
+	for _, ordering := range s.orderBy {
+		if s.fieldIndex(ordering.column) != -1 || indexOf(s.sortColumns, ordering.column) != -1 {
+			continue
+		}
+		if s.grouped() && indexOf(s.groupBy, ordering.column) == -1 {
+			return fmt.Errorf("ORDER BY %s must name a selected column or a GROUP BY column", ordering.column)
+		}
+		s.sortColumns = append(s.sortColumns, ordering.column)
+		s.columns[ordering.column] = true
+	}
+	return nil
+}
+
+// fieldIndex returns the index of the field named name, or -1.
+func (s *statement) fieldIndex(name string) int {
+	for i, field := range s.fields {
+		if field.name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	token := p.tokens[p.position]
+	if token.kind != tokenEOF {
+		p.position++
+	}
+	return token
+}
+
+func (p *parser) isKeyword(token token) bool {
+	return token.kind == tokenIdentifier && !token.quoted && keywords[strings.ToUpper(token.text)]
+}
+
+// keyword consumes the next token if it's word.
+func (p *parser) keyword(word string) bool {
+	if token := p.peek(); p.isKeyword(token) && strings.ToUpper(token.text) == word {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(word string) error {
+	if !p.keyword(word) {
+		return fmt.Errorf("expected %s, found %s", word, describe(p.peek()))
+	}
+	return nil
+}
+
+// symbol consumes the next token if it's s.
+func (p *parser) symbol(s string) bool {
+	if token := p.peek(); token.kind == tokenSymbol && token.text == s {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(s string) error {
+	if !p.symbol(s) {
+		return fmt.Errorf("expected %s, found %s", s, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) identifier() (string, error) {
+	token := p.peek()
+	if token.kind != tokenIdentifier || p.isKeyword(token) {
+		return "", fmt.Errorf("expected a name, found %s", describe(token))
+	}
+	p.position++
+	return token.text, nil
+}
+
+// column is identifier for names that refer to a column of the table.
+func (p *parser) column() (string, error) {
+	column, err := p.identifier()
+	if err != nil {
+		return "", err
+	}
+	p.statement.columns[column] = true
+	return column, nil
+}
+
+func describe(token token) string {
+	switch token.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenString:
+		return fmt.Sprintf("'%s'", token.text)
+	}
+	return token.text
+}
+
+// likePattern converts a LIKE pattern, where % matches any run of characters
+// and _ any single character, to a regexp.
+func likePattern(pattern string) *regexp.Regexp {
+	var expr []string
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr = append(expr, ".*")
+		case '_':
+			expr = append(expr, ".")
+		default:
+			expr = append(expr, regexp.QuoteMeta(string(r)))
+		}
+	}
+	return regexp.MustCompile("(?s)^" + strings.Join(expr, "") + "$")
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+const users = `id,name,team,age
+1,alice,red,31
+2,bob,blue,25
+3,carol,red,47
+4,dan,blue,
+5,erin,green,25
+`
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+	result := runQuery(t, "SELECT name, age FROM users WHERE team = 'red' OR age < 26 ORDER BY age DESC, name", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, []string{"name", "age"}, result.Column)
+	require.Equal(t, [][]string{{"carol", "47"}, {"alice", "31"}, {"bob", "25"}, {"erin", "25"}}, rows(result))
+	result = runQuery(t, "select * from users where name not like '%a%' and not (id = 2)", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, []string{"id", "name", "team", "age"}, result.Column)
+	require.Equal(t, [][]string{{"5", "erin", "green", "25"}}, rows(result))
+	result = runQuery(t, "SELECT name FROM users WHERE age != '' ORDER BY age, id DESC LIMIT 3", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, []string{"name"}, result.Column)
+	require.Equal(t, [][]string{{"erin"}, {"bob"}, {"alice"}}, rows(result))
+}
+
+func TestLimitStopsReading(t *testing.T) {
+	t.Parallel()
+	result := runQuery(t, "SELECT name FROM users LIMIT 2", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, [][]string{{"alice"}, {"bob"}}, rows(result))
+	require.Equal(t, uint64(2), result.RowsScanned)
+}
+
+func TestGroupBy(t *testing.T) {
+	t.Parallel()
+	result := runQuery(t, "SELECT team, COUNT(*) n, COUNT(age), AVG(age), MAX(name) FROM users GROUP BY team ORDER BY n DESC, team", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, []string{"team", "n", "count(age)", "avg(age)", "max(name)"}, result.Column)
+	require.Equal(t, [][]string{{"blue", "2", "1", "25", "dan"}, {"red", "2", "2", "39", "carol"}, {"green", "1", "1", "25", "erin"}}, rows(result))
+	result = runQuery(t, "SELECT COUNT(*) FROM users GROUP BY team ORDER BY team", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, []string{"count(*)"}, result.Column)
+	require.Equal(t, [][]string{{"2"}, {"1"}, {"2"}}, rows(result))
+	result = runQuery(t, "SELECT COUNT(*), SUM(age) FROM users WHERE age > 100", users, query.TableFormat_TABLE_FORMAT_CSV)
+	require.Equal(t, [][]string{{"0", "0"}}, rows(result))
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+	events := `{"user": "alice", "bytes": 10}
+{"user": "bob", "bytes": 2.5, "tags": ["a"]}
+{"user": "alice", "bytes": 1e2, "ok": null}
+`
+	result := runQuery(t, "SELECT * FROM events", events, query.TableFormat_TABLE_FORMAT_JSON)
+	require.Equal(t, []string{"bytes", "user", "tags", "ok"}, result.Column)
+	require.Equal(t, [][]string{{"10", "alice", "", ""}, {"2.5", "bob", `["a"]`, ""}, {"1e2", "alice", "", ""}}, rows(result))
+	result = runQuery(t, "SELECT \"user\", SUM(bytes) AS total FROM events GROUP BY \"user\" ORDER BY total", events, query.TableFormat_TABLE_FORMAT_JSON)
+	require.Equal(t, [][]string{{"bob", "2.5"}, {"alice", "110"}}, rows(result))
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+	for _, bad := range []string{
+		"DELETE FROM users",
+		"SELECT FROM users",
+		"SELECT name FROM users WHERE",
+		"SELECT name FROM users WHERE name = 'alice",
+		"SELECT name, COUNT(*) FROM users",
+		"SELECT * FROM users GROUP BY team",
+		"SELECT team, COUNT(*) FROM users GROUP BY team ORDER BY age",
+		"SELECT SUM(*) FROM users",
+		"SELECT name FROM users LIMIT -1",
+		"SELECT name FROM users; DROP TABLE users",
+	} {
+		_, err := parse(bad)
+		require.True(t, err != nil, bad)
+	}
+}
+
+func runQuery(t *testing.T, sql string, table string, format query.TableFormat) *query.Result {
+	statement, err := parse(sql)
+	require.NoError(t, err)
+	reader, err := newRowReader(strings.NewReader(table), format)
+	require.NoError(t, err)
+	result, err := statement.execute(reader)
+	require.NoError(t, err)
+	return result
+}
+
+func rows(result *query.Result) [][]string {
+	var rows [][]string
+	for _, row := range result.Row {
+		rows = append(rows, row.Value)
+	}
+	return rows
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pachyderm/pachyderm/src/pkg/query"
+)
+
+// rowReader reads a table a row at a time.
+type rowReader interface {
+	// Read returns the next row, keyed by column, or io.EOF.
+	Read() (map[string]string, error)
+	// Columns returns the columns of the rows read so far, in order.
+	Columns() []string
+}
+
+func newRowReader(reader io.Reader, format query.TableFormat) (rowReader, error) {
+	switch format {
+	case query.TableFormat_TABLE_FORMAT_CSV:
+		return newCSVRowReader(reader)
+	case query.TableFormat_TABLE_FORMAT_JSON:
+		return newJSONRowReader(reader), nil
+	}
+	return nil, fmt.Errorf("unknown table format %s", format)
+}
+
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVRowReader(reader io.Reader) (*csvRowReader, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	columns, err := csvReader.Read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &csvRowReader{csvReader, columns}, nil
+}
+
+func (r *csvRowReader) Read() (map[string]string, error) {
+	if r.columns == nil {
+		return nil, io.EOF
+	}
+	fields, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	// short rows leave the missing columns empty, extra fields are dropped
+	row := make(map[string]string, len(r.columns))
+	for i, column := range r.columns {
+		if i < len(fields) {
+			row[column] = fields[i]
+		}
+	}
+	return row, nil
+}
+
+func (r *csvRowReader) Columns() []string {
+	return r.columns
+}
+
+// jsonRowReader reads a stream of JSON objects, such as JSON lines. Objects
+// don't have to share keys, a column missing from an object is empty.
+type jsonRowReader struct {
+	decoder *json.Decoder
+	columns []string
+	seen    map[string]bool
+}
+
+func newJSONRowReader(reader io.Reader) *jsonRowReader {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	return &jsonRowReader{
+		decoder: decoder,
+		seen:    make(map[string]bool),
+	}
+}
+
+func (r *jsonRowReader) Read() (map[string]string, error) {
+	var object map[string]interface{}
+	if err := r.decoder.Decode(&object); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return nil, fmt.Errorf("JSON records must be objects: %s", err.Error())
+		}
+		return nil, err
+	}
+	// new keys are added to the columns in sorted order so that the columns
+	// don't depend on map iteration order
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	row := make(map[string]string, len(object))
+	for _, key := range keys {
+		if !r.seen[key] {
+			r.seen[key] = true
+			r.columns = append(r.columns, key)
+		}
+		value, err := jsonValueToString(object[key])
+		if err != nil {
+			return nil, err
+		}
+		row[key] = value
+	}
+	return row, nil
+}
+
+func (r *jsonRowReader) Columns() []string {
+	return r.columns
+}
+
+// jsonValueToString renders strings and numbers as they are, null as empty
+// and anything else as JSON.
+func jsonValueToString(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type limiter struct {
+	defaultRate uint64
+	rate        uint64
+	// next is when the next bytes may be sent, each caller of Wait pushes it
+	// back by the time its bytes take at the current rate
+	next time.Time
+	lock sync.Mutex
+}
+
+func newLimiter(bytesPerSecond uint64) *limiter {
+	return &limiter{
+		defaultRate: bytesPerSecond,
+		rate:        bytesPerSecond,
+	}
+}
+
+func (l *limiter) Wait(n int) {
+	l.lock.Lock()
+	if l.rate == 0 {
+		l.lock.Unlock()
+		return
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(n) * time.Second / time.Duration(l.rate))
+	l.lock.Unlock()
+	time.Sleep(wait)
+}
+
+func (l *limiter) SetRate(value string) error {
+	rate := l.defaultRate
+	if value != "" {
+		var err error
+		rate, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.rate = rate
+	return nil
+}
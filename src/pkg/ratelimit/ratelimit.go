@@ -0,0 +1,19 @@
+/*
+Package ratelimit limits the bandwidth of byte streams.
+*/
+package ratelimit
+
+// Limiter limits the rate at which bytes are sent, it's safe to share a
+// Limiter between goroutines in which case they share its rate.
+type Limiter interface {
+	// Wait blocks until n more bytes can be sent.
+	Wait(n int)
+	// SetRate is a config.Setter, value is in bytes per second, 0 disables
+	// limiting and "" goes back to the rate the Limiter was created with.
+	SetRate(value string) error
+}
+
+// NewLimiter returns a new Limiter, a bytesPerSecond of 0 is unlimited.
+func NewLimiter(bytesPerSecond uint64) Limiter {
+	return newLimiter(bytesPerSecond)
+}
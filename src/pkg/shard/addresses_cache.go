@@ -0,0 +1,101 @@
+package shard
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// addressesCacheSize is the number of versions of Addresses a sharder
+	// keeps in memory, frontends and servers only ever need the last few.
+	addressesCacheSize = 16
+	// addressesSweepInterval is how often a frontend sweeps its cache of
+	// Addresses, lookups of old versions can put them back in after the
+	// sweep when the cluster's minVersion changes.
+	addressesSweepInterval = time.Minute
+)
+
+type addressesEntry struct {
+	version   int64
+	addresses *Addresses
+}
+
+// addressesCache is an lru cache of Addresses keyed by version.
+type addressesCache struct {
+	size      int
+	entries   map[int64]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+	lock      sync.Mutex
+}
+
+func newAddressesCache(size int) *addressesCache {
+	return &addressesCache{
+		size:    size,
+		entries: make(map[int64]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *addressesCache) get(version int64) (*Addresses, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[version]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*addressesEntry).addresses, true
+}
+
+func (c *addressesCache) put(version int64, addresses *Addresses) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[version]; ok {
+		element.Value.(*addressesEntry).addresses = addresses
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[version] = c.order.PushFront(&addressesEntry{version, addresses})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// sweep removes every version below minVersion and returns how many it
+// removed.
+func (c *addressesCache) sweep(minVersion int64) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var swept uint64
+	for version, element := range c.entries {
+		if version < minVersion {
+			c.remove(element)
+			swept++
+		}
+	}
+	c.evictions += swept
+	return swept
+}
+
+func (c *addressesCache) stats() *AddressesCacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return &AddressesCacheStats{
+		Size:      uint64(c.order.Len()),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *addressesCache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*addressesEntry).version)
+	c.order.Remove(element)
+}
@@ -0,0 +1,263 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/config"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+)
+
+func Cmds(etcdAddress string, namespace string) ([]*cobra.Command, error) {
+	drainServer := &cobra.Command{
+		Use:   "drain-server address",
+		Short: "Move a pfsd's shards to the other pfsds so it can be stopped.",
+		Long:  "Move a pfsd's shards to the other pfsds so it can be stopped, the pfsd keeps running until it is.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			return getSharder(etcdAddress, namespace).Drain(args[0])
+		}),
+	}
+
+	undrainServer := &cobra.Command{
+		Use:   "undrain-server address",
+		Short: "Let a drained pfsd take shards again.",
+		Long:  "Let a drained pfsd take shards again.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			return getSharder(etcdAddress, namespace).Undrain(args[0])
+		}),
+	}
+
+	var upgradeCmd string
+	var rollbackCmd string
+	var timeout time.Duration
+	upgradeCluster := &cobra.Command{
+		Use:   "upgrade-cluster",
+		Short: "Upgrade the pfsds one at a time without losing availability.",
+		Long: `Upgrade the pfsds one at a time without losing availability.
+
+Each pfsd is drained, then --upgrade-cmd is run with $PFSD_ADDRESS set to its
+address. The command should return once the old pfsd has stopped, the new one
+may have a different address. The next pfsd is upgraded once the cluster is
+available again. If an upgrade fails the pfsds which were upgraded are
+replaced with --rollback-cmd, newest first.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			if upgradeCmd == "" {
+				return fmt.Errorf("--upgrade-cmd must be set")
+			}
+			var rollback shard.ReplaceFunc
+			if rollbackCmd != "" {
+				rollback = runReplaceCmd(rollbackCmd)
+			}
+			return shard.NewUpgradeCoordinator(
+				discovery.NewEtcdClient(etcdAddress),
+				namespace,
+				timeout,
+			).Upgrade(runReplaceCmd(upgradeCmd), rollback)
+		}),
+	}
+	upgradeCluster.Flags().StringVar(&upgradeCmd, "upgrade-cmd", "", "shell command which replaces the pfsd at $PFSD_ADDRESS with an upgraded one")
+	upgradeCluster.Flags().StringVar(&rollbackCmd, "rollback-cmd", "", "shell command which replaces the pfsd at $PFSD_ADDRESS with the previous version")
+	upgradeCluster.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "the longest to wait for a pfsd to drain or for the cluster to be available again")
+
+	listRebalance := &cobra.Command{
+		Use:   "list-rebalance",
+		Short: "List the shard rebalances of the last week.",
+		Long: `List the shard rebalances of the last week, one per version of the roles,
+with how many roles moved and how long the slowest pfsd took to pick them up.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			reports, err := getSharder(etcdAddress, namespace).RebalanceReports()
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprint(writer, "VERSION\tMOVES\tSERVERS\tSLOWEST\t\n")
+			for _, report := range reports {
+				var slowest time.Duration
+				for _, serverRebalance := range report.ServerRebalances {
+					if took := rebalanceTook(serverRebalance); took > slowest {
+						slowest = took
+					}
+				}
+				fmt.Fprintf(writer, "%d\t%d\t%d\t%s\t\n", report.Version, len(report.Moves), len(report.Servers), slowest)
+			}
+			return writer.Flush()
+		}),
+	}
+
+	inspectRebalance := &cobra.Command{
+		Use:   "inspect-rebalance version",
+		Short: "Show the roles a shard rebalance moved and how long each pfsd took.",
+		Long:  "Show the roles a shard rebalance moved and how long each pfsd took to add and remove its shards.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			version, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			reports, err := getSharder(etcdAddress, namespace).RebalanceReports()
+			if err != nil {
+				return err
+			}
+			for _, report := range reports {
+				if report.Version != version {
+					continue
+				}
+				writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+				fmt.Fprint(writer, "SHARD\tROLE\tFROM\tTO\t\n")
+				for _, move := range report.Moves {
+					role := "replica"
+					if move.Master {
+						role = "master"
+					}
+					fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t\n", move.Shard, role, orDash(move.From), orDash(move.To))
+				}
+				fmt.Fprint(writer, "\nSERVER\tADDED\tADD TIME\tREMOVED\tREMOVE TIME\tERROR\t\n")
+				for _, address := range report.Servers {
+					serverRebalance, ok := report.ServerRebalances[address]
+					if !ok {
+						// the server hasn't picked the version up yet
+						fmt.Fprintf(writer, "%s\t-\t-\t-\t-\t\t\n", address)
+						continue
+					}
+					fmt.Fprintf(
+						writer,
+						"%s\t%d\t%s\t%d\t%s\t%s\t\n",
+						address,
+						serverRebalance.ShardsAdded,
+						time.Duration(serverRebalance.AddShardsMs)*time.Millisecond,
+						serverRebalance.ShardsRemoved,
+						time.Duration(serverRebalance.RemoveShardsMs)*time.Millisecond,
+						serverRebalance.Error,
+					)
+				}
+				return writer.Flush()
+			}
+			return fmt.Errorf("no rebalance report for version %d, reports are kept for a week", version)
+		}),
+	}
+
+	listTransfers := &cobra.Command{
+		Use:   "list-transfers",
+		Short: "List the shards the pfsds are adding and removing.",
+		Long:  "List the shards the pfsds are adding and removing, with how many of each shard's diffs have been pulled.",
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			statuses, err := getSharder(etcdAddress, namespace).Transfers()
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprint(writer, "SERVER\tSHARD\tVERSION\tOPERATION\tPROGRESS\tRUNNING FOR\t\n")
+			for _, status := range statuses {
+				operation := "remove"
+				if status.Add {
+					operation = "add"
+				}
+				progress := "-"
+				if status.Total > 0 {
+					progress = fmt.Sprintf("%d/%d", status.Done, status.Total)
+				}
+				started := time.Unix(0, status.StartedUnixMs*int64(time.Millisecond))
+				fmt.Fprintf(writer, "%s\t%d\t%d\t%s\t%s\t%s\t\n", status.Address, status.Shard, status.Version, operation, progress, time.Since(started))
+			}
+			return writer.Flush()
+		}),
+	}
+
+	cancelTransfer := &cobra.Command{
+		Use:   "cancel-transfer address shard",
+		Short: "Cancel a pfsd's add of a shard.",
+		Long: `Cancel a pfsd's add of a shard. The pfsd drops what it's pulled of the
+shard and of the other shards it was adding for the same version, and stays on
+its previous roles until its roles change again, drain it to move its roles
+elsewhere.`,
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			shardNum, err := strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			return getSharder(etcdAddress, namespace).CancelTransfer(args[0], shardNum)
+		}),
+	}
+
+	listPendingRoles := &cobra.Command{
+		Use:   "list-pending-roles",
+		Short: "List the versions of the roles waiting to be deleted.",
+		Long: `List the versions of the roles which every pfsd has moved past but which
+haven't been deleted, either because a frontend is still on an older version or
+because the retained_role_versions config key keeps them.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			discoveryClient := discovery.NewEtcdClient(etcdAddress)
+			sharder := shard.NewSharder(discoveryClient, 0, 0, namespace)
+			retainedVersions, err := config.NewClient(discoveryClient, namespace).Get(shard.RetainedVersionsKey)
+			if err != nil && !strings.HasPrefix(err.Error(), "100: Key not found") {
+				return err
+			}
+			if err := sharder.SetRetainedVersions(retainedVersions); err != nil {
+				return err
+			}
+			pendingRoleVersions, err := sharder.PendingRoleVersions()
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			fmt.Fprint(writer, "VERSION\tSERVERS\tWAITING ON\t\n")
+			for _, pendingRoleVersion := range pendingRoleVersions {
+				waitingOn := strings.Join(pendingRoleVersion.Frontends, ",")
+				if pendingRoleVersion.Retained {
+					waitingOn = "retained"
+				}
+				fmt.Fprintf(writer, "%d\t%d\t%s\t\n", pendingRoleVersion.Version, len(pendingRoleVersion.Servers), orDash(waitingOn))
+			}
+			return writer.Flush()
+		}),
+	}
+
+	var result []*cobra.Command
+	result = append(result, drainServer)
+	result = append(result, undrainServer)
+	result = append(result, upgradeCluster)
+	result = append(result, listRebalance)
+	result = append(result, inspectRebalance)
+	result = append(result, listTransfers)
+	result = append(result, cancelTransfer)
+	result = append(result, listPendingRoles)
+	return result, nil
+}
+
+func getSharder(etcdAddress string, namespace string) shard.Sharder {
+	return shard.NewSharder(discovery.NewEtcdClient(etcdAddress), 0, 0, namespace)
+}
+
+// rebalanceTook returns how long a server took to add and remove its shards
+// for a rebalance.
+func rebalanceTook(serverRebalance *shard.ServerRebalance) time.Duration {
+	return time.Duration(serverRebalance.AddShardsMs+serverRebalance.RemoveShardsMs) * time.Millisecond
+}
+
+func orDash(address string) string {
+	if address == "" {
+		return "-"
+	}
+	return address
+}
+
+// runReplaceCmd returns a ReplaceFunc which runs command with sh.
+func runReplaceCmd(command string) shard.ReplaceFunc {
+	return func(address string) error {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("PFSD_ADDRESS=%s", address))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%q failed for %s: %s", command, address, err.Error())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,58 @@
+package shard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// encodingVersion tags every state value we write to discovery, values
+	// are stored as "<encodingVersion>:<base64 binary proto>". Binary protos
+	// tolerate added and removed fields so message shapes can change
+	// without breaking running clusters, the tag exists so that the
+	// encoding itself can change in the future.
+	encodingVersion = "v1"
+	// legacyPrefix is how values written before encodingVersion existed
+	// (jsonpb strings) begin, we still decode them so that clusters can be
+	// upgraded in place.
+	legacyPrefix = "{"
+)
+
+// Migrating between encodings is one-way: decodeState reads the legacy
+// encoding and every version up to encodingVersion, but encodeState only
+// writes encodingVersion, so every value a server rewrites is migrated as
+// it goes. Older binaries can't read newer encodings (they return an
+// unknown encoding error), which means a cluster can't be downgraded past
+// an encoding change once any upgraded server has written state.
+
+func encodeState(message proto.Message) (string, error) {
+	value, err := proto.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return encodingVersion + ":" + base64.StdEncoding.EncodeToString(value), nil
+}
+
+func decodeState(encodedState string, message proto.Message) error {
+	if strings.HasPrefix(encodedState, legacyPrefix) {
+		return jsonpb.UnmarshalString(encodedState, message)
+	}
+	parts := strings.SplitN(encodedState, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("shard: malformed state %q", encodedState)
+	}
+	switch parts[0] {
+	case encodingVersion:
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(value, message)
+	default:
+		return fmt.Errorf("shard: unknown state encoding %s", parts[0])
+	}
+}
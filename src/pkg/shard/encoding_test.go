@@ -0,0 +1,62 @@
+package shard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestEncodeDecodeState(t *testing.T) {
+	serverState := &ServerState{
+		Address:  "127.0.0.1:650",
+		Version:  3,
+		Shards:   map[uint64]bool{0: true, 7: true},
+		Draining: true,
+	}
+	encodedServerState, err := encodeState(serverState)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encodedServerState, encodingVersion+":"))
+	var decodedServerState ServerState
+	require.NoError(t, decodeState(encodedServerState, &decodedServerState))
+	require.Equal(t, serverState, &decodedServerState)
+
+	encodedFrontendState, err := encodeState(&FrontendState{})
+	require.NoError(t, err)
+	var decodedFrontendState FrontendState
+	require.NoError(t, decodeState(encodedFrontendState, &decodedFrontendState))
+	require.Equal(t, FrontendState{}, decodedFrontendState)
+}
+
+func TestMigrateLegacyState(t *testing.T) {
+	serverState := &ServerState{
+		Address: "127.0.0.1:650",
+		Version: 3,
+		Shards:  map[uint64]bool{0: true, 7: true},
+	}
+	legacyServerState, err := (&jsonpb.Marshaler{}).MarshalToString(serverState)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(legacyServerState, legacyPrefix))
+	var decodedServerState ServerState
+	require.NoError(t, decodeState(legacyServerState, &decodedServerState))
+	require.Equal(t, serverState, &decodedServerState)
+	// rewriting a legacy value migrates it to the current encoding
+	encodedServerState, err := encodeState(&decodedServerState)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(encodedServerState, encodingVersion+":"))
+	var migratedServerState ServerState
+	require.NoError(t, decodeState(encodedServerState, &migratedServerState))
+	require.Equal(t, serverState, &migratedServerState)
+}
+
+func TestDecodeUnknownState(t *testing.T) {
+	encodedServerState, err := encodeState(&ServerState{Address: "127.0.0.1:650"})
+	require.NoError(t, err)
+	// a value written by a newer binary can't be read, so downgrades fail
+	var serverState ServerState
+	newerServerState := "v2" + strings.TrimPrefix(encodedServerState, encodingVersion)
+	require.True(t, decodeState(newerServerState, &serverState) != nil)
+	require.True(t, decodeState("malformed", &serverState) != nil)
+	require.True(t, decodeState(encodingVersion+":!!!", &serverState) != nil)
+}
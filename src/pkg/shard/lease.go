@@ -0,0 +1,98 @@
+package shard
+
+import (
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"go.pedge.io/protolog"
+)
+
+const (
+	// maxClockSkew is how far our clock can be from the discovery backend's
+	// before we warn about it.
+	maxClockSkew = 5 * time.Second
+	// leaseMaxRetryInterval caps the time between attempts to renew a lease
+	// while discovery is unavailable.
+	leaseMaxRetryInterval = 5 * time.Second
+)
+
+// leaseHolder holds a lease on a key in discovery. Whether a node is in the
+// cluster is decided by the backend expiring its key by the backend's own
+// clock, renewals are scheduled from how long the backend says the lease has
+// left and are checked against the backend's index rather than a timestamp,
+// so nodes whose clocks drift don't flap in and out of the cluster.
+type leaseHolder struct {
+	discoveryClient discovery.Client
+	key             string
+	address         string
+	leaseID         uint64
+	skewed          bool
+	backOff         *backoff.ExponentialBackOff
+}
+
+func newLeaseHolder(discoveryClient discovery.Client, key string, address string) *leaseHolder {
+	backOff := backoff.NewExponentialBackOff()
+	backOff.MaxInterval = leaseMaxRetryInterval
+	backOff.MaxElapsedTime = 0
+	return &leaseHolder{
+		discoveryClient,
+		key,
+		address,
+		0,
+		false,
+		backOff,
+	}
+}
+
+// renew writes value to the key and returns how long to wait before renewing
+// again. Failed renewals are retried with jittered backoff so that a
+// discovery outage doesn't take the node down, the caller keeps the latest
+// value and it's written once discovery is back.
+func (h *leaseHolder) renew(value string) time.Duration {
+	start := time.Now()
+	lease, err := h.discoveryClient.SetLease(h.key, value, holdTTL, h.leaseID)
+	if err == discovery.ErrLeaseExpired {
+		// we've dropped out of the cluster, most likely because renewals
+		// were delayed, take a new lease to rejoin it
+		protolog.Warn(&LostLease{h.key, h.leaseID})
+		h.leaseID = 0
+		start = time.Now()
+		lease, err = h.discoveryClient.SetLease(h.key, value, holdTTL, 0)
+	}
+	if err != nil {
+		protolog.Printf("Error setting %s: %s", h.key, err.Error())
+		return h.backOff.NextBackOff()
+	}
+	h.backOff.Reset()
+	h.leaseID = lease.ID
+	h.checkSkew(lease, start, time.Now())
+	if lease.TTL == 0 {
+		return time.Second * time.Duration(holdTTL/2)
+	}
+	return lease.TTL / 3
+}
+
+// release deletes the key, this lets roles be reassigned right away rather
+// than after holdTTL.
+func (h *leaseHolder) release() error {
+	return h.discoveryClient.Delete(h.key)
+}
+
+// checkSkew warns when our clock has drifted from the backend's. Liveness
+// doesn't depend on it but skew makes timestamps in logs and audit events
+// misleading.
+func (h *leaseHolder) checkSkew(lease *discovery.Lease, start time.Time, end time.Time) {
+	if (lease.Expiration == time.Time{}) {
+		return
+	}
+	// the backend set the expiration to its clock plus holdTTL at some point
+	// during the request, we assume it was halfway through
+	backendNow := lease.Expiration.Add(-time.Second * time.Duration(holdTTL))
+	skew := start.Add(end.Sub(start) / 2).Sub(backendNow)
+	skewed := skew > maxClockSkew || skew < -maxClockSkew
+	if skewed && !h.skewed {
+		protolog.Warn(&ClockSkew{h.address, int64(skew / time.Millisecond)})
+	}
+	h.skewed = skewed
+}
@@ -0,0 +1,161 @@
+package shard
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"go.pedge.io/protolog"
+)
+
+// Rebalance reports are kept under rebalanceDir, each version's report at
+// <version>/report and what each server took to pick the version up at
+// <version>/server/<address>. They expire after a week.
+const rebalanceReportTTL = uint64(7 * 24 * time.Hour / time.Second)
+
+func (a *sharder) RebalanceReports() ([]*RebalanceReport, error) {
+	encodedValues, err := a.discoveryClient.GetAll(a.rebalanceDir())
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[int64]*RebalanceReport)
+	serverRebalances := make(map[int64][]*ServerRebalance)
+	for key, encodedValue := range encodedValues {
+		parts := strings.Split(strings.TrimPrefix(key, a.rebalanceDir()+"/"), "/")
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case len(parts) == 2 && parts[1] == "report":
+			var report RebalanceReport
+			if err := decodeState(encodedValue, &report); err != nil {
+				return nil, err
+			}
+			reports[version] = &report
+		case len(parts) == 3 && parts[1] == "server":
+			var serverRebalance ServerRebalance
+			if err := decodeState(encodedValue, &serverRebalance); err != nil {
+				return nil, err
+			}
+			serverRebalances[version] = append(serverRebalances[version], &serverRebalance)
+		}
+	}
+	var versions int64Slice
+	for version := range reports {
+		versions = append(versions, version)
+	}
+	sort.Sort(versions)
+	var result []*RebalanceReport
+	for _, version := range versions {
+		// servers can report a version whose report has expired, or
+		// hasn't been written yet, those are left out
+		report := reports[version]
+		report.ServerRebalances = make(map[string]*ServerRebalance)
+		for _, serverRebalance := range serverRebalances[version] {
+			report.ServerRebalances[serverRebalance.Address] = serverRebalance
+		}
+		result = append(result, report)
+	}
+	return result, nil
+}
+
+// newRebalanceReport returns the report of the roles version moved, from the
+// masters and replicas of the version before it to the new ones.
+func newRebalanceReport(
+	version int64,
+	numShards uint64,
+	oldMasters map[uint64]string,
+	oldReplicas map[uint64][]string,
+	newMasters map[uint64]string,
+	newReplicas map[uint64][]string,
+) *RebalanceReport {
+	report := &RebalanceReport{Version: version}
+	servers := make(map[string]bool)
+	move := func(shard uint64, master bool, from string, to string) {
+		report.Moves = append(report.Moves, &ShardMove{shard, master, from, to})
+		for _, address := range []string{from, to} {
+			if address != "" {
+				servers[address] = true
+			}
+		}
+	}
+	for shard := uint64(0); shard < numShards; shard++ {
+		if oldMasters[shard] != newMasters[shard] {
+			move(shard, true, oldMasters[shard], newMasters[shard])
+		}
+		// replicas aren't ordered, so a replica role moved from each server
+		// which lost one to a server which gained one
+		from := difference(oldReplicas[shard], newReplicas[shard])
+		to := difference(newReplicas[shard], oldReplicas[shard])
+		for i := 0; i < len(from) || i < len(to); i++ {
+			var fromAddress, toAddress string
+			if i < len(from) {
+				fromAddress = from[i]
+			}
+			if i < len(to) {
+				toAddress = to[i]
+			}
+			move(shard, false, fromAddress, toAddress)
+		}
+	}
+	for address := range servers {
+		report.Servers = append(report.Servers, address)
+	}
+	sort.Strings(report.Servers)
+	return report
+}
+
+// difference returns the addresses in a which aren't in b, sorted.
+func difference(a []string, b []string) []string {
+	inB := make(map[string]bool)
+	for _, address := range b {
+		inB[address] = true
+	}
+	var result []string
+	for _, address := range a {
+		if !inB[address] {
+			result = append(result, address)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// setRebalanceReport stores report, reports are informational so failing to
+// store one only gets logged.
+func (a *sharder) setRebalanceReport(report *RebalanceReport) {
+	a.setRebalanceValue(a.rebalanceReportKey(report.Version), report)
+}
+
+// setServerRebalance stores what the server at serverRebalance.Address took
+// to pick up version.
+func (a *sharder) setServerRebalance(version int64, serverRebalance *ServerRebalance) {
+	a.setRebalanceValue(a.serverRebalanceKey(version, serverRebalance.Address), serverRebalance)
+}
+
+func (a *sharder) setRebalanceValue(key string, message proto.Message) {
+	encodedValue, err := encodeState(message)
+	if err == nil {
+		err = a.discoveryClient.Set(key, encodedValue, rebalanceReportTTL)
+	}
+	if err != nil {
+		protolog.Printf("Error setting %s: %s", key, err.Error())
+	}
+}
+
+func (a *sharder) rebalanceDir() string {
+	return path.Join(a.routeDir(), "rebalance")
+}
+
+func (a *sharder) rebalanceReportKey(version int64) string {
+	return path.Join(a.rebalanceDir(), fmt.Sprint(version), "report")
+}
+
+func (a *sharder) serverRebalanceKey(version int64, address string) string {
+	return path.Join(a.rebalanceDir(), fmt.Sprint(version), "server", address)
+}
@@ -0,0 +1,26 @@
+package shard
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestNewRebalanceReport(t *testing.T) {
+	report := newRebalanceReport(
+		3,
+		2,
+		map[uint64]string{0: "a", 1: "b"},
+		map[uint64][]string{0: {"b"}, 1: {"a"}},
+		map[uint64]string{0: "a", 1: "c"},
+		map[uint64][]string{0: {"b"}, 1: {"a", "b"}},
+	)
+	require.Equal(t, &RebalanceReport{
+		Version: 3,
+		Moves: []*ShardMove{
+			{1, true, "b", "c"},
+			{1, false, "", "b"},
+		},
+		Servers: []string{"b", "c"},
+	}, report)
+}
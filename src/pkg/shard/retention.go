@@ -0,0 +1,98 @@
+package shard
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+func (a *sharder) SetRetainedVersions(value string) error {
+	retainedVersions := int64(DefaultRetainedVersions)
+	if value != "" {
+		var err error
+		if retainedVersions, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("shard: invalid %s %q: %s", RetainedVersionsKey, value, err.Error())
+		}
+		if retainedVersions < DefaultRetainedVersions {
+			return fmt.Errorf("shard: %s can't be less than %d", RetainedVersionsKey, DefaultRetainedVersions)
+		}
+	}
+	atomic.StoreInt64(&a.retainedVersions, retainedVersions)
+	return nil
+}
+
+func (a *sharder) getRetainedVersions() int64 {
+	return atomic.LoadInt64(&a.retainedVersions)
+}
+
+// minRetainedVersion returns the oldest version whose roles are kept when the
+// oldest version any server is on is minVersion. Two versions are all a
+// server needs to move from one to the next, the rest are kept for servers
+// which fall behind.
+func (a *sharder) minRetainedVersion(minVersion int64) int64 {
+	return minVersion - (a.getRetainedVersions() - DefaultRetainedVersions)
+}
+
+func (a *sharder) PendingRoleVersions() ([]*PendingRoleVersion, error) {
+	serverStates, err := a.getServerStates()
+	if err != nil {
+		return nil, err
+	}
+	if len(serverStates) == 0 {
+		return nil, nil
+	}
+	minVersion := int64(math.MaxInt64)
+	for _, serverState := range serverStates {
+		if serverState.Version < minVersion {
+			minVersion = serverState.Version
+		}
+	}
+	encodedFrontendStates, err := a.discoveryClient.GetAll(a.frontendStateDir())
+	if err != nil {
+		return nil, err
+	}
+	var frontends []string
+	for _, encodedFrontendState := range encodedFrontendStates {
+		frontendState, err := decodeFrontendState(encodedFrontendState)
+		if err != nil {
+			return nil, err
+		}
+		if frontendState.Version < minVersion {
+			frontends = append(frontends, frontendState.Address)
+		}
+	}
+	sort.Strings(frontends)
+	serverRoles, err := a.getServerRoles()
+	if err != nil {
+		return nil, err
+	}
+	pendingRoleVersions := make(map[int64]*PendingRoleVersion)
+	for address, versionToServerRole := range serverRoles {
+		for version := range versionToServerRole {
+			if version >= minVersion {
+				continue
+			}
+			if _, ok := pendingRoleVersions[version]; !ok {
+				pendingRoleVersions[version] = &PendingRoleVersion{
+					Version:   version,
+					Frontends: frontends,
+					Retained:  version >= a.minRetainedVersion(minVersion),
+				}
+			}
+			pendingRoleVersions[version].Servers = append(pendingRoleVersions[version].Servers, address)
+		}
+	}
+	var versions int64Slice
+	for version := range pendingRoleVersions {
+		versions = append(versions, version)
+	}
+	sort.Sort(versions)
+	var result []*PendingRoleVersion
+	for _, version := range versions {
+		sort.Strings(pendingRoleVersions[version].Servers)
+		result = append(result, pendingRoleVersions[version])
+	}
+	return result, nil
+}
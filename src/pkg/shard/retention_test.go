@@ -0,0 +1,19 @@
+package shard
+
+import (
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+)
+
+func TestSetRetainedVersions(t *testing.T) {
+	sharder := newSharder(nil, 0, 0, "TestSetRetainedVersions")
+	require.Equal(t, int64(10), sharder.minRetainedVersion(10))
+	require.NoError(t, sharder.SetRetainedVersions("4"))
+	require.Equal(t, int64(8), sharder.minRetainedVersion(10))
+	require.NotNil(t, sharder.SetRetainedVersions("1"))
+	require.NotNil(t, sharder.SetRetainedVersions("two"))
+	require.Equal(t, int64(8), sharder.minRetainedVersion(10))
+	require.NoError(t, sharder.SetRetainedVersions(""))
+	require.Equal(t, int64(10), sharder.minRetainedVersion(10))
+}
@@ -1,6 +1,10 @@
 package shard
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/pachyderm/pachyderm/src/pkg/discovery"
 )
 
@@ -14,8 +18,45 @@ type Sharder interface {
 	Register(cancel chan bool, address string, server Server) error
 	RegisterFrontend(cancel chan bool, address string, frontend Frontend) error
 	AssignRoles(chan bool) error
+	// Drain has the roles of the server at address moved to the other
+	// servers so it can be stopped without losing availability, the server
+	// keeps running until it is. Undrain lets it take roles again.
+	Drain(address string) error
+	Undrain(address string) error
+	// AddressesCacheStats returns stats for the sharder's cache of addresses.
+	AddressesCacheStats() *AddressesCacheStats
+	// RebalanceReports returns the reports of the versions assigned in the
+	// last week, oldest first, with what each server took to pick them up
+	// so far.
+	RebalanceReports() ([]*RebalanceReport, error)
+	// Transfers returns the AddShard and RemoveShard calls in progress on
+	// every server.
+	Transfers() ([]*TransferStatus, error)
+	// CancelTransfer cancels the AddShard or RemoveShard of shard in
+	// progress on the server at address.
+	CancelTransfer(address string, shard uint64) error
+	// SetRetainedVersions is a config Setter for RetainedVersionsKey, every
+	// sharder which registers servers or assigns roles should watch it.
+	SetRetainedVersions(value string) error
+	// PendingRoleVersions returns the versions of the roles which the
+	// servers have all moved past but which haven't been deleted, oldest
+	// first.
+	PendingRoleVersions() ([]*PendingRoleVersion, error)
 }
 
+const (
+	// RetainedVersionsKey is the runtime config key for how many versions of
+	// their roles the servers keep. Each server works through at most that
+	// many versions at once, and a version every server has moved past is
+	// kept while it's no more than that many versions, less
+	// DefaultRetainedVersions, behind the oldest version a server is on. Its
+	// value is a number, at least DefaultRetainedVersions.
+	RetainedVersionsKey = "retained_role_versions"
+	// DefaultRetainedVersions is the number of versions kept when
+	// RetainedVersionsKey isn't set.
+	DefaultRetainedVersions = 2
+)
+
 type TestSharder interface {
 	Sharder
 	WaitForAvailability(frontendIds []string, serverIds []string) error
@@ -25,15 +66,56 @@ func NewSharder(discoveryClient discovery.Client, numShards uint64, numReplicas
 	return newSharder(discoveryClient, numShards, numReplicas, namespace)
 }
 
+// RouteDir returns the discovery directory a Sharder in namespace keeps its
+// state in.
+func RouteDir(namespace string) string {
+	return fmt.Sprintf("%s/pfs/route", namespace)
+}
+
 func NewTestSharder(discoveryClient discovery.Client, numShards uint64, numReplicas uint64, namespace string) TestSharder {
 	return newSharder(discoveryClient, numShards, numReplicas, namespace)
 }
 
+// ReplaceFunc replaces the server at address, for instance with a newer
+// version of it, and returns once the old server has stopped. The new server
+// may have a different address.
+type ReplaceFunc func(address string) error
+
+// UpgradeCoordinator does rolling upgrades of the servers in a cluster.
+type UpgradeCoordinator interface {
+	// Upgrade replaces the servers one at a time with upgrade, each one is
+	// drained first and the cluster has to be available again before the next
+	// is replaced. If a server fails to upgrade the servers which were
+	// upgraded are replaced with rollback, newest first, unless it's nil.
+	Upgrade(upgrade ReplaceFunc, rollback ReplaceFunc) error
+}
+
+// NewUpgradeCoordinator returns an UpgradeCoordinator which waits at most
+// timeout for each step of an upgrade.
+func NewUpgradeCoordinator(discoveryClient discovery.Client, namespace string, timeout time.Duration) UpgradeCoordinator {
+	return newUpgradeCoordinator(discoveryClient, namespace, timeout)
+}
+
+// Transfer is passed to a Server's AddShard and RemoveShard, which report
+// their progress to it and stop if it's cancelled.
+type Transfer interface {
+	// Progress reports that done of the transfer's total steps are done,
+	// total is 0 if it isn't known yet.
+	Progress(done uint64, total uint64)
+	// Cancel is closed if an operator cancels the transfer, the Server
+	// should undo what it's done and return ErrTransferCancelled.
+	Cancel() chan bool
+}
+
+// ErrTransferCancelled is returned by a Server's AddShard and RemoveShard
+// when their Transfer is cancelled.
+var ErrTransferCancelled = errors.New("shard: transfer cancelled")
+
 type Server interface {
 	// AddShard tells the server it now has a role for a shard.
-	AddShard(shard uint64, version int64) error
+	AddShard(shard uint64, version int64, transfer Transfer) error
 	// RemoveShard tells the server it no longer has a role for a shard.
-	RemoveShard(shard uint64, version int64) error
+	RemoveShard(shard uint64, version int64, transfer Transfer) error
 	// LocalRoles asks the server which shards it has on disk and how many commits each shard has.
 	LocalShards() (map[uint64]bool, error)
 }
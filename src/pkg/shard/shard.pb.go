@@ -32,6 +32,16 @@ It has these top-level messages:
 	GetShardToMasterAddress
 	ReplicaAddresses
 	GetShardToReplicaAddresses
+	AddressesCacheStats
+	SweepAddresses
+	LostLease
+	ClockSkew
+	UpgradeServer
+	ShardMove
+	ServerRebalance
+	RebalanceReport
+	TransferStatus
+	PendingRoleVersion
 */
 package shard
 
@@ -48,6 +58,8 @@ type ServerState struct {
 	Address string          `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
 	Version int64           `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
 	Shards  map[uint64]bool `protobuf:"bytes,3,rep,name=shards" json:"shards,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// draining servers are given no roles so that they can be stopped
+	Draining bool `protobuf:"varint,6,opt,name=draining" json:"draining,omitempty"`
 }
 
 func (m *ServerState) Reset()         { *m = ServerState{} }
@@ -371,6 +383,157 @@ func (m *GetShardToReplicaAddresses) GetResult() map[uint64]*ReplicaAddresses {
 	return nil
 }
 
+type AddressesCacheStats struct {
+	Size      uint64 `protobuf:"varint,1,opt,name=size" json:"size,omitempty"`
+	Hits      uint64 `protobuf:"varint,2,opt,name=hits" json:"hits,omitempty"`
+	Misses    uint64 `protobuf:"varint,3,opt,name=misses" json:"misses,omitempty"`
+	Evictions uint64 `protobuf:"varint,4,opt,name=evictions" json:"evictions,omitempty"`
+}
+
+func (m *AddressesCacheStats) Reset()         { *m = AddressesCacheStats{} }
+func (m *AddressesCacheStats) String() string { return proto.CompactTextString(m) }
+func (*AddressesCacheStats) ProtoMessage()    {}
+
+type SweepAddresses struct {
+	MinVersion int64                `protobuf:"varint,1,opt,name=min_version" json:"min_version,omitempty"`
+	Swept      uint64               `protobuf:"varint,2,opt,name=swept" json:"swept,omitempty"`
+	Stats      *AddressesCacheStats `protobuf:"bytes,3,opt,name=stats" json:"stats,omitempty"`
+}
+
+func (m *SweepAddresses) Reset()         { *m = SweepAddresses{} }
+func (m *SweepAddresses) String() string { return proto.CompactTextString(m) }
+func (*SweepAddresses) ProtoMessage()    {}
+
+func (m *SweepAddresses) GetStats() *AddressesCacheStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+type LostLease struct {
+	Key     string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	LeaseId uint64 `protobuf:"varint,2,opt,name=lease_id" json:"lease_id,omitempty"`
+}
+
+func (m *LostLease) Reset()         { *m = LostLease{} }
+func (m *LostLease) String() string { return proto.CompactTextString(m) }
+func (*LostLease) ProtoMessage()    {}
+
+type ClockSkew struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	// how far our clock is ahead of the discovery backend's, negative if it's
+	// behind
+	SkewMs int64 `protobuf:"varint,2,opt,name=skew_ms" json:"skew_ms,omitempty"`
+}
+
+func (m *ClockSkew) Reset()         { *m = ClockSkew{} }
+func (m *ClockSkew) String() string { return proto.CompactTextString(m) }
+func (*ClockSkew) ProtoMessage()    {}
+
+type UpgradeServer struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	// the address of the server which replaced it, often the same address
+	Replacement string `protobuf:"bytes,2,opt,name=replacement" json:"replacement,omitempty"`
+	Rollback    bool   `protobuf:"varint,3,opt,name=rollback" json:"rollback,omitempty"`
+	Error       string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *UpgradeServer) Reset()         { *m = UpgradeServer{} }
+func (m *UpgradeServer) String() string { return proto.CompactTextString(m) }
+func (*UpgradeServer) ProtoMessage()    {}
+
+// ShardMove is a role which a version gave to a different server than the
+// version before it did.
+type ShardMove struct {
+	Shard  uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	Master bool   `protobuf:"varint,2,opt,name=master" json:"master,omitempty"`
+	From   string `protobuf:"bytes,3,opt,name=from" json:"from,omitempty"`
+	To     string `protobuf:"bytes,4,opt,name=to" json:"to,omitempty"`
+}
+
+func (m *ShardMove) Reset()         { *m = ShardMove{} }
+func (m *ShardMove) String() string { return proto.CompactTextString(m) }
+func (*ShardMove) ProtoMessage()    {}
+
+// ServerRebalance is how long a server took to pick up a version's roles.
+type ServerRebalance struct {
+	Address     string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	ShardsAdded uint64 `protobuf:"varint,2,opt,name=shards_added" json:"shards_added,omitempty"`
+	// how long the server's AddShard calls took, they run concurrently
+	AddShardsMs    int64  `protobuf:"varint,3,opt,name=add_shards_ms" json:"add_shards_ms,omitempty"`
+	ShardsRemoved  uint64 `protobuf:"varint,4,opt,name=shards_removed" json:"shards_removed,omitempty"`
+	RemoveShardsMs int64  `protobuf:"varint,5,opt,name=remove_shards_ms" json:"remove_shards_ms,omitempty"`
+	Error          string `protobuf:"bytes,6,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ServerRebalance) Reset()         { *m = ServerRebalance{} }
+func (m *ServerRebalance) String() string { return proto.CompactTextString(m) }
+func (*ServerRebalance) ProtoMessage()    {}
+
+// RebalanceReport is what a version of the roles changed. The servers fill in
+// how long they took as they pick the version up.
+type RebalanceReport struct {
+	Version int64        `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Moves   []*ShardMove `protobuf:"bytes,2,rep,name=moves" json:"moves,omitempty"`
+	// the servers whose roles changed
+	Servers          []string                    `protobuf:"bytes,3,rep,name=servers" json:"servers,omitempty"`
+	ServerRebalances map[string]*ServerRebalance `protobuf:"bytes,4,rep,name=server_rebalances" json:"server_rebalances,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *RebalanceReport) Reset()         { *m = RebalanceReport{} }
+func (m *RebalanceReport) String() string { return proto.CompactTextString(m) }
+func (*RebalanceReport) ProtoMessage()    {}
+
+func (m *RebalanceReport) GetMoves() []*ShardMove {
+	if m != nil {
+		return m.Moves
+	}
+	return nil
+}
+
+func (m *RebalanceReport) GetServerRebalances() map[string]*ServerRebalance {
+	if m != nil {
+		return m.ServerRebalances
+	}
+	return nil
+}
+
+// TransferStatus is an AddShard or RemoveShard in progress on a server.
+type TransferStatus struct {
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	Shard   uint64 `protobuf:"varint,2,opt,name=shard" json:"shard,omitempty"`
+	Version int64  `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	Add     bool   `protobuf:"varint,4,opt,name=add" json:"add,omitempty"`
+	// how many of the transfer's steps are done, diffs for pfsd, total is 0
+	// until the server knows it
+	Done          uint64 `protobuf:"varint,5,opt,name=done" json:"done,omitempty"`
+	Total         uint64 `protobuf:"varint,6,opt,name=total" json:"total,omitempty"`
+	StartedUnixMs int64  `protobuf:"varint,7,opt,name=started_unix_ms" json:"started_unix_ms,omitempty"`
+}
+
+func (m *TransferStatus) Reset()         { *m = TransferStatus{} }
+func (m *TransferStatus) String() string { return proto.CompactTextString(m) }
+func (*TransferStatus) ProtoMessage()    {}
+
+// PendingRoleVersion is a version of the roles which the servers have all
+// moved past but which hasn't been deleted yet.
+type PendingRoleVersion struct {
+	Version int64 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	// the servers which still have roles for the version
+	Servers []string `protobuf:"bytes,2,rep,name=servers" json:"servers,omitempty"`
+	// the frontends on versions the servers have moved past, roles aren't
+	// deleted until they catch up
+	Frontends []string `protobuf:"bytes,3,rep,name=frontends" json:"frontends,omitempty"`
+	// whether the version is kept because of the retained_role_versions config
+	// key rather than waiting on frontends
+	Retained bool `protobuf:"varint,4,opt,name=retained" json:"retained,omitempty"`
+}
+
+func (m *PendingRoleVersion) Reset()         { *m = PendingRoleVersion{} }
+func (m *PendingRoleVersion) String() string { return proto.CompactTextString(m) }
+func (*PendingRoleVersion) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterType((*ServerState)(nil), "shard.ServerState")
 	proto.RegisterType((*FrontendState)(nil), "shard.FrontendState")
@@ -395,4 +558,14 @@ func init() {
 	proto.RegisterType((*GetShardToMasterAddress)(nil), "shard.GetShardToMasterAddress")
 	proto.RegisterType((*ReplicaAddresses)(nil), "shard.ReplicaAddresses")
 	proto.RegisterType((*GetShardToReplicaAddresses)(nil), "shard.GetShardToReplicaAddresses")
+	proto.RegisterType((*AddressesCacheStats)(nil), "shard.AddressesCacheStats")
+	proto.RegisterType((*SweepAddresses)(nil), "shard.SweepAddresses")
+	proto.RegisterType((*LostLease)(nil), "shard.LostLease")
+	proto.RegisterType((*ClockSkew)(nil), "shard.ClockSkew")
+	proto.RegisterType((*UpgradeServer)(nil), "shard.UpgradeServer")
+	proto.RegisterType((*ShardMove)(nil), "shard.ShardMove")
+	proto.RegisterType((*ServerRebalance)(nil), "shard.ServerRebalance")
+	proto.RegisterType((*RebalanceReport)(nil), "shard.RebalanceReport")
+	proto.RegisterType((*TransferStatus)(nil), "shard.TransferStatus")
+	proto.RegisterType((*PendingRoleVersion)(nil), "shard.PendingRoleVersion")
 }
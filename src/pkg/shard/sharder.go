@@ -7,9 +7,9 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/golang/protobuf/jsonpb"
 	"github.com/pachyderm/pachyderm/src/pkg/discovery"
 	"go.pedge.io/protolog"
 )
@@ -18,7 +18,6 @@ const InvalidVersion int64 = -1
 
 var (
 	holdTTL      uint64 = 20
-	marshaler           = &jsonpb.Marshaler{}
 	ErrCancelled        = fmt.Errorf("cancelled by user")
 	errComplete         = fmt.Errorf("COMPLETE")
 )
@@ -28,12 +27,17 @@ type sharder struct {
 	numShards       uint64
 	numReplicas     uint64
 	namespace       string
-	addresses       map[int64]*Addresses
-	addressesLock   sync.RWMutex
+	addresses       *addressesCache
+	transfers       *transferSet
+	// accessed atomically
+	retainedVersions int64
+	// minVersion is the lowest version of any server as last seen by a
+	// frontend, accessed atomically
+	minVersion int64
 }
 
 func newSharder(discoveryClient discovery.Client, numShards uint64, numReplicas uint64, namespace string) *sharder {
-	return &sharder{discoveryClient, numShards, numReplicas, namespace, make(map[int64]*Addresses), sync.RWMutex{}}
+	return &sharder{discoveryClient, numShards, numReplicas, namespace, newAddressesCache(addressesCacheSize), newTransferSet(), DefaultRetainedVersions, InvalidVersion}
 }
 
 func (a *sharder) GetMasterAddress(shard uint64, version int64) (result string, ok bool, retErr error) {
@@ -101,6 +105,10 @@ func (a *sharder) GetShardToReplicaAddresses(version int64) (result map[uint64]m
 	return _result, nil
 }
 
+func (a *sharder) AddressesCacheStats() *AddressesCacheStats {
+	return a.addresses.stats()
+}
+
 func (a *sharder) Register(cancel chan bool, address string, server Server) (retErr error) {
 	protolog.Info(&StartRegister{address})
 	defer func() {
@@ -108,12 +116,13 @@ func (a *sharder) Register(cancel chan bool, address string, server Server) (ret
 	}()
 	var once sync.Once
 	versionChan := make(chan int64)
+	drainingChan := make(chan bool)
 	internalCancel := make(chan bool)
 	var wg sync.WaitGroup
-	wg.Add(3)
+	wg.Add(5)
 	go func() {
 		defer wg.Done()
-		if err := a.announceServer(address, server, versionChan, internalCancel); err != nil {
+		if err := a.announceServer(address, server, versionChan, drainingChan, internalCancel); err != nil {
 			once.Do(func() {
 				retErr = err
 				close(internalCancel)
@@ -129,6 +138,24 @@ func (a *sharder) Register(cancel chan bool, address string, server Server) (ret
 			})
 		}
 	}()
+	go func() {
+		defer wg.Done()
+		if err := a.watchDraining(address, drainingChan, internalCancel); err != nil {
+			once.Do(func() {
+				retErr = err
+				close(internalCancel)
+			})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := a.watchTransferCancels(address, internalCancel); err != nil {
+			once.Do(func() {
+				retErr = err
+				close(internalCancel)
+			})
+		}
+	}()
 	go func() {
 		defer wg.Done()
 		select {
@@ -149,7 +176,7 @@ func (a *sharder) RegisterFrontend(cancel chan bool, address string, frontend Fr
 	versionChan := make(chan int64)
 	internalCancel := make(chan bool)
 	var wg sync.WaitGroup
-	wg.Add(3)
+	wg.Add(4)
 	go func() {
 		defer wg.Done()
 		if err := a.announceFrontend(address, frontend, versionChan, internalCancel); err != nil {
@@ -168,6 +195,10 @@ func (a *sharder) RegisterFrontend(cancel chan bool, address string, frontend Fr
 			})
 		}
 	}()
+	go func() {
+		defer wg.Done()
+		a.sweepAddresses(internalCancel)
+	}()
 	go func() {
 		defer wg.Done()
 		select {
@@ -189,6 +220,7 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 		protolog.Info(&FinishAssignRoles{errorToString(retErr)})
 	}()
 	var version int64
+	// oldServers maps the address of each server to whether it's draining
 	oldServers := make(map[string]bool)
 	oldRoles := make(map[string]*ServerRole)
 	oldMasters := make(map[uint64]string)
@@ -206,7 +238,7 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 		}
 		if oldServerRole, ok := oldRoles[serverRole.Address]; !ok || oldServerRole.Version < serverRole.Version {
 			oldRoles[serverRole.Address] = serverRole
-			oldServers[serverRole.Address] = true
+			oldServers[serverRole.Address] = false
 		}
 		if version < serverRole.Version+1 {
 			version = serverRole.Version + 1
@@ -220,26 +252,36 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 			oldReplicas[shard] = append(oldReplicas[shard], oldServerRole.Address)
 		}
 	}
-	err = a.discoveryClient.WatchAll(a.serverStateDir(), cancel,
-		func(encodedServerStates map[string]string) error {
-			if len(encodedServerStates) == 0 {
+	// serverStates is keyed by discovery key, only the states that change
+	// get decoded on each event
+	serverStates := make(map[string]*ServerState)
+	err = a.discoveryClient.WatchAllEvents(a.serverStateDir(), cancel,
+		func(events []*discovery.Event) error {
+			if err := applyServerStateEvents(serverStates, events); err != nil {
+				return err
+			}
+			if len(serverStates) == 0 {
 				return nil
 			}
 			newServerStates := make(map[string]*ServerState)
 			shardLocations := make(map[uint64][]string)
 			newRoles := make(map[string]*ServerRole)
+			// draining servers are left out of newRoles, so nothing can be
+			// assigned to them, and get empty roles once the others are done
+			drainingRoles := make(map[string]*ServerRole)
 			newMasters := make(map[uint64]string)
 			newReplicas := make(map[uint64][]string)
-			masterRolesPerServer := a.numShards / uint64(len(encodedServerStates))
-			masterRolesRemainder := a.numShards % uint64(len(encodedServerStates))
-			replicaRolesPerServer := (a.numShards * a.numReplicas) / uint64(len(encodedServerStates))
-			replicaRolesRemainder := (a.numShards * a.numReplicas) % uint64(len(encodedServerStates))
-			for _, encodedServerState := range encodedServerStates {
-				serverState, err := decodeServerState(encodedServerState)
-				if err != nil {
-					return err
-				}
+			for _, serverState := range serverStates {
 				newServerStates[serverState.Address] = serverState
+				if serverState.Draining {
+					drainingRoles[serverState.Address] = &ServerRole{
+						Address:  serverState.Address,
+						Version:  version,
+						Masters:  make(map[uint64]bool),
+						Replicas: make(map[uint64]bool),
+					}
+					continue
+				}
 				newRoles[serverState.Address] = &ServerRole{
 					Address:  serverState.Address,
 					Version:  version,
@@ -286,7 +328,7 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 					if err != nil {
 						return err
 					}
-					if serverRole.Version < minVersion {
+					if serverRole.Version < a.minRetainedVersion(minVersion) {
 						if err := a.discoveryClient.Delete(key); err != nil {
 							return err
 						}
@@ -299,6 +341,18 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 			if sameServers(oldServers, newServerStates) {
 				return nil
 			}
+			if len(newRoles) == 0 {
+				protolog.Error(&FailedToAssignRoles{
+					ServerStates: newServerStates,
+					NumShards:    a.numShards,
+					NumReplicas:  a.numReplicas,
+				})
+				return nil
+			}
+			masterRolesPerServer := a.numShards / uint64(len(newRoles))
+			masterRolesRemainder := a.numShards % uint64(len(newRoles))
+			replicaRolesPerServer := (a.numShards * a.numReplicas) / uint64(len(newRoles))
+			replicaRolesRemainder := (a.numShards * a.numReplicas) % uint64(len(newRoles))
 		Master:
 			for shard := uint64(0); shard < a.numShards; shard++ {
 				if address, ok := oldMasters[shard]; ok {
@@ -364,6 +418,9 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 					return nil
 				}
 			}
+			for address, serverRole := range drainingRoles {
+				newRoles[address] = serverRole
+			}
 			addresses := Addresses{
 				Version:   version,
 				Addresses: make(map[uint64]*ShardAddresses),
@@ -372,7 +429,7 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 				addresses.Addresses[shard] = &ShardAddresses{Replicas: make(map[string]bool)}
 			}
 			for address, serverRole := range newRoles {
-				encodedServerRole, err := marshaler.MarshalToString(serverRole)
+				encodedServerRole, err := encodeState(serverRole)
 				if err != nil {
 					return err
 				}
@@ -392,7 +449,7 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 					addresses.Addresses[shard] = shardAddresses
 				}
 			}
-			encodedAddresses, err := marshaler.MarshalToString(&addresses)
+			encodedAddresses, err := encodeState(&addresses)
 			if err != nil {
 				return err
 			}
@@ -400,10 +457,13 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 				return err
 			}
 			protolog.Info(&SetAddresses{&addresses})
+			report := newRebalanceReport(version, a.numShards, oldMasters, oldReplicas, newMasters, newReplicas)
+			protolog.Info(report)
+			a.setRebalanceReport(report)
 			version++
 			oldServers = make(map[string]bool)
-			for address := range newServerStates {
-				oldServers[address] = true
+			for address, serverState := range newServerStates {
+				oldServers[address] = serverState.Draining
 			}
 			oldRoles = newRoles
 			oldMasters = newMasters
@@ -416,9 +476,40 @@ func (a *sharder) AssignRoles(cancel chan bool) (retErr error) {
 	return err
 }
 
+func (a *sharder) Drain(address string) error {
+	return a.discoveryClient.Set(a.drainKey(address), "true", 0)
+}
+
+func (a *sharder) Undrain(address string) error {
+	// undraining a server which isn't draining is fine
+	if err := a.discoveryClient.Delete(a.drainKey(address)); err != nil && !strings.HasPrefix(err.Error(), "100: Key not found") {
+		return err
+	}
+	return nil
+}
+
 func (a *sharder) WaitForAvailability(frontendAddresses []string, serverAddresses []string) error {
+	serversOK := exactly(serverAddresses)
+	_, err := a.waitForAvailability(nil, exactly(frontendAddresses), func(serverStates map[string]*ServerState) bool {
+		addresses := make(map[string]bool)
+		for address := range serverStates {
+			addresses[address] = true
+		}
+		return serversOK(addresses)
+	})
+	return err
+}
+
+// waitForAvailability waits until the frontends and servers which have
+// registered satisfy frontendsOK and serversOK and they're all using the
+// same version. It returns the version.
+func (a *sharder) waitForAvailability(
+	cancel chan bool,
+	frontendsOK func(addresses map[string]bool) bool,
+	serversOK func(serverStates map[string]*ServerState) bool,
+) (int64, error) {
 	version := InvalidVersion
-	if err := a.discoveryClient.WatchAll(a.serverDir(), nil,
+	if err := a.discoveryClient.WatchAll(a.serverDir(), cancel,
 		func(encodedServerStatesAndRoles map[string]string) error {
 			serverStates := make(map[string]*ServerState)
 			serverRoles := make(map[string]map[int64]*ServerRole)
@@ -441,16 +532,13 @@ func (a *sharder) WaitForAvailability(frontendAddresses []string, serverAddresse
 					serverRoles[serverRole.Address][serverRole.Version] = serverRole
 				}
 			}
-			if len(serverStates) != len(serverAddresses) {
+			if !serversOK(serverStates) {
 				return nil
 			}
-			if len(serverRoles) != len(serverAddresses) {
+			if len(serverRoles) != len(serverStates) {
 				return nil
 			}
-			for _, address := range serverAddresses {
-				if _, ok := serverStates[address]; !ok {
-					return nil
-				}
+			for address := range serverStates {
 				if _, ok := serverRoles[address]; !ok {
 					return nil
 				}
@@ -481,12 +569,12 @@ func (a *sharder) WaitForAvailability(frontendAddresses []string, serverAddresse
 			}
 			return errComplete
 		}); err != errComplete {
-		return err
+		return InvalidVersion, err
 	}
 
 	if err := a.discoveryClient.WatchAll(
 		a.frontendStateDir(),
-		nil,
+		cancel,
 		func(encodedFrontendStates map[string]string) error {
 			frontendStates := make(map[string]*FrontendState)
 			for _, encodedFrontendState := range encodedFrontendStates {
@@ -502,23 +590,38 @@ func (a *sharder) WaitForAvailability(frontendAddresses []string, serverAddresse
 				frontendStates[frontendState.Address] = frontendState
 			}
 			protolog.Printf("frontendStates: %+v", frontendStates)
-			if len(frontendStates) != len(frontendAddresses) {
-				return nil
+			addresses := make(map[string]bool)
+			for address := range frontendStates {
+				addresses[address] = true
 			}
-			for _, address := range frontendAddresses {
-				if _, ok := frontendStates[address]; !ok {
-					return nil
-				}
+			if !frontendsOK(addresses) {
+				return nil
 			}
 			return errComplete
 		}); err != nil && err != errComplete {
-		return err
+		return InvalidVersion, err
+	}
+	return version, nil
+}
+
+// exactly returns a check for waitForAvailability that the addresses are
+// expected.
+func exactly(expected []string) func(map[string]bool) bool {
+	return func(addresses map[string]bool) bool {
+		if len(addresses) != len(expected) {
+			return false
+		}
+		for _, address := range expected {
+			if !addresses[address] {
+				return false
+			}
+		}
+		return true
 	}
-	return nil
 }
 
 func (a *sharder) routeDir() string {
-	return fmt.Sprintf("%s/pfs/route", a.namespace)
+	return RouteDir(a.namespace)
 }
 
 func (a *sharder) serverDir() string {
@@ -545,6 +648,14 @@ func (a *sharder) serverRoleKeyVersion(address string, version int64) string {
 	return path.Join(a.serverRoleKey(address), fmt.Sprint(version))
 }
 
+func (a *sharder) drainDir() string {
+	return path.Join(a.routeDir(), "drain")
+}
+
+func (a *sharder) drainKey(address string) string {
+	return path.Join(a.drainDir(), address)
+}
+
 func (a *sharder) frontendDir() string {
 	return path.Join(a.routeDir(), "frontend")
 }
@@ -567,7 +678,7 @@ func (a *sharder) addressesKey(version int64) string {
 
 func decodeServerState(encodedServerState string) (*ServerState, error) {
 	var serverState ServerState
-	if err := jsonpb.UnmarshalString(encodedServerState, &serverState); err != nil {
+	if err := decodeState(encodedServerState, &serverState); err != nil {
 		return nil, err
 	}
 	return &serverState, nil
@@ -575,12 +686,27 @@ func decodeServerState(encodedServerState string) (*ServerState, error) {
 
 func decodeFrontendState(encodedFrontendState string) (*FrontendState, error) {
 	var frontendState FrontendState
-	if err := jsonpb.UnmarshalString(encodedFrontendState, &frontendState); err != nil {
+	if err := decodeState(encodedFrontendState, &frontendState); err != nil {
 		return nil, err
 	}
 	return &frontendState, nil
 }
 
+func applyServerStateEvents(serverStates map[string]*ServerState, events []*discovery.Event) error {
+	for _, event := range events {
+		if event.Type == discovery.EventTypeDelete {
+			delete(serverStates, event.Key)
+			continue
+		}
+		serverState, err := decodeServerState(event.Value)
+		if err != nil {
+			return err
+		}
+		serverStates[event.Key] = serverState
+	}
+	return nil
+}
+
 func (a *sharder) getServerStates() (map[string]*ServerState, error) {
 	encodedServerStates, err := a.discoveryClient.GetAll(a.serverStateDir())
 	if err != nil {
@@ -607,7 +733,7 @@ func (a *sharder) getServerState(address string) (*ServerState, error) {
 
 func decodeServerRole(encodedServerRole string) (*ServerRole, error) {
 	var serverRole ServerRole
-	if err := jsonpb.UnmarshalString(encodedServerRole, &serverRole); err != nil {
+	if err := decodeState(encodedServerRole, &serverRole); err != nil {
 		return nil, err
 	}
 	return &serverRole, nil
@@ -652,23 +778,18 @@ func (a *sharder) getAddresses(version int64) (*Addresses, error) {
 	if version == InvalidVersion {
 		return nil, fmt.Errorf("invalid version")
 	}
-	a.addressesLock.RLock()
-	if addresses, ok := a.addresses[version]; ok {
-		a.addressesLock.RUnlock()
+	if addresses, ok := a.addresses.get(version); ok {
 		return addresses, nil
 	}
-	a.addressesLock.RUnlock()
-	a.addressesLock.Lock()
-	defer a.addressesLock.Unlock()
 	encodedAddresses, err := a.discoveryClient.Get(a.addressesKey(version))
 	if err != nil {
 		return nil, err
 	}
 	var addresses Addresses
-	if err := jsonpb.UnmarshalString(encodedAddresses, &addresses); err != nil {
+	if err := decodeState(encodedAddresses, &addresses); err != nil {
 		return nil, err
 	}
-	a.addresses[version] = &addresses
+	a.addresses.put(version, &addresses)
 	return &addresses, nil
 }
 
@@ -795,36 +916,58 @@ func (a *sharder) announceServer(
 	address string,
 	server Server,
 	versionChan chan int64,
+	drainingChan chan bool,
 	cancel chan bool,
 ) error {
 	serverState := &ServerState{
 		Address: address,
 		Version: InvalidVersion,
 	}
+	holder := newLeaseHolder(a.discoveryClient, a.serverStateKey(address), address)
 	for {
 		shards, err := server.LocalShards()
 		if err != nil {
 			return err
 		}
 		serverState.Shards = shards
-		encodedServerState, err := marshaler.MarshalToString(serverState)
+		encodedServerState, err := encodeState(serverState)
 		if err != nil {
 			return err
 		}
-		if err := a.discoveryClient.Set(a.serverStateKey(address), encodedServerState, holdTTL); err != nil {
-			protolog.Printf("Error setting server state: %s", err.Error())
-		}
+		renewAfter := holder.renew(encodedServerState)
 		protolog.Debug(&SetServerState{serverState})
 		select {
 		case <-cancel:
-			return nil
+			return holder.release()
 		case version := <-versionChan:
 			serverState.Version = version
-		case <-time.After(time.Second * time.Duration(holdTTL/2)):
+		case draining := <-drainingChan:
+			serverState.Draining = draining
+		case <-time.After(renewAfter):
 		}
 	}
 }
 
+// watchDraining sends whether the server at address has been asked to drain
+// to drainingChan whenever it changes.
+func (a *sharder) watchDraining(address string, drainingChan chan bool, cancel chan bool) error {
+	draining := false
+	return a.discoveryClient.Watch(
+		a.drainKey(address),
+		cancel,
+		func(value string) error {
+			if (value != "") == draining {
+				return nil
+			}
+			draining = value != ""
+			select {
+			case drainingChan <- draining:
+			case <-cancel:
+			}
+			return nil
+		})
+}
+
 func (a *sharder) announceFrontend(
 	address string,
 	frontend Frontend,
@@ -835,21 +978,20 @@ func (a *sharder) announceFrontend(
 		Address: address,
 		Version: InvalidVersion,
 	}
+	holder := newLeaseHolder(a.discoveryClient, a.frontendStateKey(address), address)
 	for {
-		encodedFrontendState, err := marshaler.MarshalToString(frontendState)
+		encodedFrontendState, err := encodeState(frontendState)
 		if err != nil {
 			return err
 		}
-		if err := a.discoveryClient.Set(a.frontendStateKey(address), encodedFrontendState, holdTTL); err != nil {
-			protolog.Printf("Error setting server state: %s", err.Error())
-		}
+		renewAfter := holder.renew(encodedFrontendState)
 		protolog.Debug(&SetFrontendState{frontendState})
 		select {
 		case <-cancel:
-			return nil
+			return holder.release()
 		case version := <-versionChan:
 			frontendState.Version = version
-		case <-time.After(time.Second * time.Duration(holdTTL/2)):
+		case <-time.After(renewAfter):
 		}
 	}
 }
@@ -860,6 +1002,12 @@ func (s int64Slice) Len() int           { return len(s) }
 func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
 
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+
 func (a *sharder) fillRoles(
 	address string,
 	server Server,
@@ -867,24 +1015,36 @@ func (a *sharder) fillRoles(
 	cancel chan bool,
 ) error {
 	oldRoles := make(map[int64]ServerRole)
-	return a.discoveryClient.WatchAll(
+	// serverRoles is keyed by discovery key
+	serverRoles := make(map[string]ServerRole)
+	// what picking up each version in oldRoles took, for its rebalance
+	// report
+	rebalances := make(map[int64]*ServerRebalance)
+	return a.discoveryClient.WatchAllEvents(
 		a.serverRoleKey(address),
 		cancel,
-		func(encodedServerRoles map[string]string) error {
-			roles := make(map[int64]ServerRole)
-			var versions int64Slice
-			// Decode the roles
-			for _, encodedServerRole := range encodedServerRoles {
+		func(events []*discovery.Event) error {
+			// Decode the roles that changed
+			for _, event := range events {
+				if event.Type == discovery.EventTypeDelete {
+					delete(serverRoles, event.Key)
+					continue
+				}
 				var serverRole ServerRole
-				if err := jsonpb.UnmarshalString(encodedServerRole, &serverRole); err != nil {
+				if err := decodeState(event.Value, &serverRole); err != nil {
 					return err
 				}
+				serverRoles[event.Key] = serverRole
+			}
+			roles := make(map[int64]ServerRole)
+			var versions int64Slice
+			for _, serverRole := range serverRoles {
 				roles[serverRole.Version] = serverRole
 				versions = append(versions, serverRole.Version)
 			}
 			sort.Sort(versions)
-			if len(versions) > 2 {
-				versions = versions[0:2]
+			if retainedVersions := int(a.getRetainedVersions()); len(versions) > retainedVersions {
+				versions = versions[0:retainedVersions]
 			}
 			// For each new version bring the server up to date
 			for _, version := range versions {
@@ -893,21 +1053,29 @@ func (a *sharder) fillRoles(
 					continue
 				}
 				serverRole := roles[version]
-				var wg sync.WaitGroup
-				var addShardErr error
+				var newShards []uint64
 				for _, shard := range shards(serverRole) {
 					if !containsShard(oldRoles, shard) {
-						wg.Add(1)
-						shard := shard
-						go func() {
-							defer wg.Done()
-							if err := server.AddShard(shard, version-1); err != nil && addShardErr == nil {
-								addShardErr = err
-							}
-						}()
+						newShards = append(newShards, shard)
 					}
 				}
-				wg.Wait()
+				rebalance := &ServerRebalance{Address: address, ShardsAdded: uint64(len(newShards))}
+				start := time.Now()
+				added, addShardErr := a.transferShards(address, server, version, newShards, true)
+				rebalance.AddShardsMs = int64(time.Since(start) / time.Millisecond)
+				rebalance.Error = errorToString(addShardErr)
+				rebalances[version] = rebalance
+				a.setServerRebalance(version, rebalance)
+				if addShardErr == ErrTransferCancelled {
+					// the server stays on the roles it had, it tries this
+					// version again once its roles change, when it's
+					// drained say
+					protolog.Info(&AddServerRole{&serverRole, addShardErr.Error()})
+					if _, err := a.transferShards(address, server, version, added, false); err != nil {
+						return err
+					}
+					break
+				}
 				if addShardErr != nil {
 					protolog.Info(&AddServerRole{&serverRole, addShardErr.Error()})
 					return addShardErr
@@ -918,34 +1086,48 @@ func (a *sharder) fillRoles(
 			}
 			// See if there are any old roles that aren't needed
 			for version, serverRole := range oldRoles {
-				var wg sync.WaitGroup
-				var removeShardErr error
 				if _, ok := roles[version]; ok {
 					// these roles haven't expired yet, so nothing to do
 					continue
 				}
+				// the shards are removed because of the version which
+				// replaced these roles, so they go in its report
+				rebalance := rebalances[nextVersion(versions, version)]
+				var removed []uint64
 				for _, shard := range shards(serverRole) {
 					if !containsShard(roles, shard) {
-						wg.Add(1)
-						shard := shard
-						go func(shard uint64) {
-							defer wg.Done()
-							if err := server.RemoveShard(shard, version-1); err != nil && removeShardErr == nil {
-								removeShardErr = err
-							}
-						}(shard)
+						removed = append(removed, shard)
 					}
 				}
-				wg.Wait()
+				start := time.Now()
+				_, removeShardErr := a.transferShards(address, server, version, removed, false)
+				if rebalance != nil {
+					rebalance.ShardsRemoved += uint64(len(removed))
+					rebalance.RemoveShardsMs += int64(time.Since(start) / time.Millisecond)
+					if rebalance.Error == "" {
+						rebalance.Error = errorToString(removeShardErr)
+					}
+					a.setServerRebalance(nextVersion(versions, version), rebalance)
+				}
 				if removeShardErr != nil {
 					protolog.Info(&RemoveServerRole{&serverRole, removeShardErr.Error()})
 					return removeShardErr
 				}
 				protolog.Info(&RemoveServerRole{&serverRole, ""})
 			}
-			oldRoles = make(map[int64]ServerRole)
+			// versions whose shards were cancelled aren't kept, so they're
+			// picked up again
+			newRoles := make(map[int64]ServerRole)
 			for _, version := range versions {
-				oldRoles[version] = roles[version]
+				if _, ok := oldRoles[version]; ok {
+					newRoles[version] = roles[version]
+				}
+			}
+			oldRoles = newRoles
+			for version := range rebalances {
+				if _, ok := oldRoles[version]; !ok {
+					delete(rebalances, version)
+				}
 			}
 			return nil
 		},
@@ -959,19 +1141,19 @@ func (a *sharder) runFrontend(
 	cancel chan bool,
 ) error {
 	version := InvalidVersion
-	return a.discoveryClient.WatchAll(
+	serverStates := make(map[string]*ServerState)
+	return a.discoveryClient.WatchAllEvents(
 		a.serverStateDir(),
 		cancel,
-		func(encodedServerStates map[string]string) error {
-			if len(encodedServerStates) == 0 {
+		func(events []*discovery.Event) error {
+			if err := applyServerStateEvents(serverStates, events); err != nil {
+				return err
+			}
+			if len(serverStates) == 0 {
 				return nil
 			}
 			minVersion := int64(math.MaxInt64)
-			for _, encodedServerState := range encodedServerStates {
-				serverState, err := decodeServerState(encodedServerState)
-				if err != nil {
-					return err
-				}
+			for _, serverState := range serverStates {
 				if serverState.Version < minVersion {
 					minVersion = serverState.Version
 				}
@@ -982,11 +1164,49 @@ func (a *sharder) runFrontend(
 				}
 				version = minVersion
 				versionChan <- version
+				// no server or frontend will ask for addresses below the
+				// cluster's minVersion again
+				atomic.StoreInt64(&a.minVersion, minVersion)
+				swept := a.addresses.sweep(minVersion)
+				protolog.Info(&SweepAddresses{minVersion, swept, a.addresses.stats()})
 			}
 			return nil
 		})
 }
 
+// sweepAddresses sweeps versions below the cluster's minVersion from the
+// cache of addresses every addressesSweepInterval until cancel is closed.
+func (a *sharder) sweepAddresses(cancel chan bool) {
+	ticker := time.NewTicker(addressesSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cancel:
+			return
+		case <-ticker.C:
+		}
+		minVersion := atomic.LoadInt64(&a.minVersion)
+		if minVersion == InvalidVersion {
+			continue
+		}
+		if swept := a.addresses.sweep(minVersion); swept > 0 {
+			protolog.Info(&SweepAddresses{minVersion, swept, a.addresses.stats()})
+		}
+	}
+}
+
+// nextVersion returns the lowest of versions after version, -1 if there
+// isn't one.
+func nextVersion(versions []int64, version int64) int64 {
+	result := int64(-1)
+	for _, v := range versions {
+		if v > version && (result == -1 || v < result) {
+			result = v
+		}
+	}
+	return result
+}
+
 func shards(serverRole ServerRole) []uint64 {
 	var result []uint64
 	for shard := range serverRole.Masters {
@@ -1011,8 +1231,9 @@ func sameServers(oldServers map[string]bool, newServerStates map[string]*ServerS
 	if len(oldServers) != len(newServerStates) {
 		return false
 	}
-	for address := range oldServers {
-		if _, ok := newServerStates[address]; !ok {
+	for address, draining := range oldServers {
+		serverState, ok := newServerStates[address]
+		if !ok || serverState.Draining != draining {
 			return false
 		}
 	}
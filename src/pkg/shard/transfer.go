@@ -0,0 +1,316 @@
+package shard
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"go.pedge.io/protolog"
+)
+
+// The status of each transfer in progress is kept at
+// transferStatusKey(address, shard) and deleted once the transfer returns.
+// CancelTransfer sets transferCancelKey(address, shard) to the transfer's
+// version, which the server watches for.
+const (
+	// how often a transfer's progress is written to discovery at most
+	transferWriteInterval = time.Second
+	// cancel keys outlive the transfer they're for in case the server is
+	// slow to see them, the version in them keeps them from cancelling a
+	// later transfer of the same shard
+	transferCancelTTL = uint64(time.Hour / time.Second)
+	// how many shards a server adds or removes at once, each add is a
+	// replication pull so a server taking on thousands of shards would
+	// otherwise pull them all at the same time
+	maxConcurrentTransfers = 16
+	// how many times a shard's add or remove is tried before it's given up
+	// on, and the cap on the time between tries
+	transferMaxAttempts      = 3
+	transferMaxRetryInterval = 10 * time.Second
+)
+
+// transfer is the Transfer passed to a Server's AddShard or RemoveShard.
+type transfer struct {
+	sharder   *sharder
+	status    *TransferStatus
+	cancel    chan bool
+	once      sync.Once
+	lock      sync.Mutex
+	lastWrite time.Time
+}
+
+func (t *transfer) Progress(done uint64, total uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.Done = done
+	t.status.Total = total
+	if time.Since(t.lastWrite) < transferWriteInterval && done != total {
+		return
+	}
+	t.write()
+}
+
+func (t *transfer) Cancel() chan bool {
+	return t.cancel
+}
+
+// write writes the transfer's status, t.lock must be held.
+func (t *transfer) write() {
+	t.lastWrite = time.Now()
+	key := t.sharder.transferStatusKey(t.status.Address, t.status.Shard)
+	encodedStatus, err := encodeState(t.status)
+	if err == nil {
+		err = t.sharder.discoveryClient.Set(key, encodedStatus, 0)
+	}
+	if err != nil {
+		protolog.Printf("Error setting %s: %s", key, err.Error())
+	}
+}
+
+// transferSet is the transfers in progress on the servers registered with a
+// sharder, by address and shard.
+type transferSet struct {
+	lock      sync.Mutex
+	transfers map[string]map[uint64]*transfer
+}
+
+func newTransferSet() *transferSet {
+	return &transferSet{transfers: make(map[string]map[uint64]*transfer)}
+}
+
+// startTransfer returns the Transfer for an AddShard or RemoveShard of shard
+// by the server at address, finishTransfer has to be called once it returns.
+func (a *sharder) startTransfer(address string, shard uint64, version int64, add bool) *transfer {
+	t := &transfer{
+		sharder: a,
+		status:  &TransferStatus{address, shard, version, add, 0, 0, time.Now().UnixNano() / int64(time.Millisecond)},
+		cancel:  make(chan bool),
+	}
+	t.lock.Lock()
+	t.write()
+	t.lock.Unlock()
+	a.transfers.lock.Lock()
+	defer a.transfers.lock.Unlock()
+	if _, ok := a.transfers.transfers[address]; !ok {
+		a.transfers.transfers[address] = make(map[uint64]*transfer)
+	}
+	a.transfers.transfers[address][shard] = t
+	return t
+}
+
+func (a *sharder) finishTransfer(t *transfer) {
+	a.transfers.lock.Lock()
+	if a.transfers.transfers[t.status.Address][t.status.Shard] == t {
+		delete(a.transfers.transfers[t.status.Address], t.status.Shard)
+	}
+	a.transfers.lock.Unlock()
+	key := a.transferStatusKey(t.status.Address, t.status.Shard)
+	if err := a.discoveryClient.Delete(key); err != nil {
+		protolog.Printf("Error deleting %s: %s", key, err.Error())
+	}
+}
+
+// transferShards has server add, or remove, shards for the roles of version,
+// maxConcurrentTransfers of them at a time. It returns the shards which were
+// transferred. If any weren't the error is a TransferErrors, unless they
+// were all cancelled in which case it's ErrTransferCancelled. Once a
+// transfer is cancelled no more are started.
+func (a *sharder) transferShards(address string, server Server, version int64, shards []uint64, add bool) ([]uint64, error) {
+	shardChan := make(chan uint64)
+	cancelled := make(chan bool)
+	var once sync.Once
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var transferred []uint64
+	transferErrors := make(TransferErrors)
+	workers := maxConcurrentTransfers
+	if len(shards) < workers {
+		workers = len(shards)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range shardChan {
+				err := a.transferShard(address, server, version, shard, add)
+				if err == ErrTransferCancelled {
+					once.Do(func() { close(cancelled) })
+				}
+				lock.Lock()
+				if err != nil {
+					transferErrors[shard] = err
+				} else {
+					transferred = append(transferred, shard)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+Shards:
+	for _, shard := range shards {
+		select {
+		case shardChan <- shard:
+		case <-cancelled:
+			break Shards
+		}
+	}
+	close(shardChan)
+	wg.Wait()
+	sort.Sort(uint64Slice(transferred))
+	if len(transferErrors) == 0 {
+		return transferred, nil
+	}
+	for _, err := range transferErrors {
+		if err != ErrTransferCancelled {
+			return transferred, transferErrors
+		}
+	}
+	return transferred, ErrTransferCancelled
+}
+
+// transferShard has server add, or remove, shard, retrying with backoff up
+// to transferMaxAttempts times. Cancelled transfers aren't retried.
+func (a *sharder) transferShard(address string, server Server, version int64, shard uint64, add bool) error {
+	t := a.startTransfer(address, shard, version, add)
+	defer a.finishTransfer(t)
+	backOff := backoff.NewExponentialBackOff()
+	backOff.MaxInterval = transferMaxRetryInterval
+	for attempt := 1; ; attempt++ {
+		var err error
+		if add {
+			err = server.AddShard(shard, version-1, t)
+		} else {
+			err = server.RemoveShard(shard, version-1, t)
+		}
+		if err == nil || err == ErrTransferCancelled || attempt == transferMaxAttempts {
+			return err
+		}
+		protolog.Printf("Error transferring shard %d to %s, retrying: %s", shard, address, err.Error())
+		select {
+		case <-t.cancel:
+			return ErrTransferCancelled
+		case <-time.After(backOff.NextBackOff()):
+		}
+	}
+}
+
+// TransferErrors is the error of the shards a server failed to add or
+// remove, by shard.
+type TransferErrors map[uint64]error
+
+func (e TransferErrors) Error() string {
+	var shards []uint64
+	for shard := range e {
+		shards = append(shards, shard)
+	}
+	sort.Sort(uint64Slice(shards))
+	var errs []string
+	for _, shard := range shards {
+		errs = append(errs, fmt.Sprintf("shard %d: %s", shard, e[shard].Error()))
+	}
+	return strings.Join(errs, "; ")
+}
+
+// watchTransferCancels cancels the transfers of the server at address which
+// CancelTransfer is called for. The statuses left by a previous run of the
+// server are deleted first, those transfers died with it.
+func (a *sharder) watchTransferCancels(address string, cancel chan bool) error {
+	statuses, err := a.discoveryClient.GetAll(path.Join(a.transferStatusDir(), address))
+	if err != nil {
+		return err
+	}
+	for key := range statuses {
+		if err := a.discoveryClient.Delete(key); err != nil {
+			return err
+		}
+	}
+	cancelDir := path.Join(a.transferCancelDir(), address)
+	return a.discoveryClient.WatchAll(cancelDir, cancel,
+		func(encodedCancels map[string]string) error {
+			for key, encodedVersion := range encodedCancels {
+				shard, err := strconv.ParseUint(strings.TrimPrefix(key, cancelDir+"/"), 10, 64)
+				if err != nil {
+					continue
+				}
+				version, err := strconv.ParseInt(encodedVersion, 10, 64)
+				if err != nil {
+					continue
+				}
+				a.transfers.lock.Lock()
+				t, ok := a.transfers.transfers[address][shard]
+				a.transfers.lock.Unlock()
+				if ok && t.status.Version == version {
+					t.once.Do(func() { close(t.cancel) })
+				}
+			}
+			return nil
+		})
+}
+
+func (a *sharder) Transfers() ([]*TransferStatus, error) {
+	encodedStatuses, err := a.discoveryClient.GetAll(a.transferStatusDir())
+	if err != nil {
+		return nil, err
+	}
+	var result []*TransferStatus
+	for _, encodedStatus := range encodedStatuses {
+		var status TransferStatus
+		if err := decodeState(encodedStatus, &status); err != nil {
+			return nil, err
+		}
+		result = append(result, &status)
+	}
+	sort.Sort(sortTransferStatuses(result))
+	return result, nil
+}
+
+func (a *sharder) CancelTransfer(address string, shard uint64) error {
+	encodedStatus, err := a.discoveryClient.Get(a.transferStatusKey(address, shard))
+	if (err != nil && strings.HasPrefix(err.Error(), "100: Key not found")) || (err == nil && encodedStatus == "") {
+		return fmt.Errorf("%s has no transfer of shard %d in progress", address, shard)
+	}
+	if err != nil {
+		return err
+	}
+	var status TransferStatus
+	if err := decodeState(encodedStatus, &status); err != nil {
+		return err
+	}
+	return a.discoveryClient.Set(a.transferCancelKey(address, shard), fmt.Sprint(status.Version), transferCancelTTL)
+}
+
+func (a *sharder) transferDir() string {
+	return path.Join(a.routeDir(), "transfer")
+}
+
+func (a *sharder) transferStatusDir() string {
+	return path.Join(a.transferDir(), "status")
+}
+
+func (a *sharder) transferStatusKey(address string, shard uint64) string {
+	return path.Join(a.transferStatusDir(), address, fmt.Sprint(shard))
+}
+
+func (a *sharder) transferCancelDir() string {
+	return path.Join(a.transferDir(), "cancel")
+}
+
+func (a *sharder) transferCancelKey(address string, shard uint64) string {
+	return path.Join(a.transferCancelDir(), address, fmt.Sprint(shard))
+}
+
+type sortTransferStatuses []*TransferStatus
+
+func (s sortTransferStatuses) Len() int      { return len(s) }
+func (s sortTransferStatuses) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortTransferStatuses) Less(i, j int) bool {
+	if s[i].Address != s[j].Address {
+		return s[i].Address < s[j].Address
+	}
+	return s[i].Shard < s[j].Shard
+}
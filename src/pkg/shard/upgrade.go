@@ -0,0 +1,183 @@
+package shard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pkg/discovery"
+	"go.pedge.io/protolog"
+)
+
+type upgradeCoordinator struct {
+	sharder *sharder
+	timeout time.Duration
+}
+
+func newUpgradeCoordinator(discoveryClient discovery.Client, namespace string, timeout time.Duration) *upgradeCoordinator {
+	return &upgradeCoordinator{
+		newSharder(discoveryClient, 0, 0, namespace),
+		timeout,
+	}
+}
+
+func (u *upgradeCoordinator) Upgrade(upgrade ReplaceFunc, rollback ReplaceFunc) error {
+	serverStates, err := u.sharder.getServerStates()
+	if err != nil {
+		return err
+	}
+	if len(serverStates) < 2 {
+		return fmt.Errorf("pachyderm: a rolling upgrade needs at least 2 servers, found %d", len(serverStates))
+	}
+	var addresses []string
+	for address, serverState := range serverStates {
+		if serverState.Draining {
+			return fmt.Errorf("pachyderm: %s is draining, undrain it before upgrading", address)
+		}
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	var replacements []string
+	for _, address := range addresses {
+		replacement, err := u.replace(address, upgrade)
+		protolog.Info(&UpgradeServer{address, replacement, false, errorToString(err)})
+		if err == nil {
+			replacements = append(replacements, replacement)
+			continue
+		}
+		if rollback == nil {
+			return fmt.Errorf("pachyderm: upgrading %s failed, %d servers were upgraded before it: %s", address, len(replacements), err.Error())
+		}
+		for i := len(replacements) - 1; i >= 0; i-- {
+			rolledBack, rollbackErr := u.replace(replacements[i], rollback)
+			protolog.Info(&UpgradeServer{replacements[i], rolledBack, true, errorToString(rollbackErr)})
+			if rollbackErr != nil {
+				return fmt.Errorf("pachyderm: upgrading %s failed: %s; rolling back %s also failed: %s", address, err.Error(), replacements[i], rollbackErr.Error())
+			}
+		}
+		return fmt.Errorf("pachyderm: upgrading %s failed, %d servers were rolled back: %s", address, len(replacements), err.Error())
+	}
+	return nil
+}
+
+// replace drains the server at address, replaces it with replaceFunc and waits
+// for the cluster to be available again with the same number of servers. It
+// returns the address of the server which replaced it.
+func (u *upgradeCoordinator) replace(address string, replaceFunc ReplaceFunc) (string, error) {
+	serverStates, err := u.sharder.getServerStates()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := serverStates[address]; !ok {
+		return "", fmt.Errorf("pachyderm: %s isn't in the cluster", address)
+	}
+	frontendStates, err := u.sharder.discoveryClient.GetAll(u.sharder.frontendStateDir())
+	if err != nil {
+		return "", err
+	}
+	if err := u.sharder.Drain(address); err != nil {
+		return "", err
+	}
+	if err := u.waitForDrained(address); err != nil {
+		return "", u.undrain(address, err)
+	}
+	if err := replaceFunc(address); err != nil {
+		return "", u.undrain(address, err)
+	}
+	// the replacement may have the same address, it mustn't start drained
+	if err := u.sharder.Undrain(address); err != nil {
+		return "", err
+	}
+	replacement := address
+	if err := u.withTimeout("the cluster to be available", func(cancel chan bool) error {
+		_, err := u.sharder.waitForAvailability(
+			cancel,
+			func(addresses map[string]bool) bool {
+				return len(addresses) == len(frontendStates)
+			},
+			func(newServerStates map[string]*ServerState) bool {
+				if len(newServerStates) != len(serverStates) {
+					return false
+				}
+				for newAddress, newServerState := range newServerStates {
+					if newServerState.Draining {
+						return false
+					}
+					if _, ok := serverStates[newAddress]; !ok {
+						replacement = newAddress
+					}
+				}
+				return true
+			},
+		)
+		return err
+	}); err != nil {
+		return "", err
+	}
+	return replacement, nil
+}
+
+// waitForDrained waits until the server at address has no roles in the
+// latest version.
+func (u *upgradeCoordinator) waitForDrained(address string) error {
+	return u.withTimeout(fmt.Sprintf("%s to drain", address), func(cancel chan bool) error {
+		if err := u.sharder.discoveryClient.WatchAll(u.sharder.serverDir(), cancel,
+			func(encodedServerStatesAndRoles map[string]string) error {
+				encodedServerState, ok := encodedServerStatesAndRoles[u.sharder.serverStateKey(address)]
+				if !ok {
+					return fmt.Errorf("pachyderm: %s left the cluster while draining", address)
+				}
+				serverState, err := decodeServerState(encodedServerState)
+				if err != nil {
+					return err
+				}
+				if !serverState.Draining {
+					return nil
+				}
+				var serverRoles []*ServerRole
+				for key, encodedServerRole := range encodedServerStatesAndRoles {
+					if !strings.HasPrefix(key, u.sharder.serverRoleKey(address)+"/") {
+						continue
+					}
+					serverRole, err := decodeServerRole(encodedServerRole)
+					if err != nil {
+						return err
+					}
+					serverRoles = append(serverRoles, serverRole)
+				}
+				if len(serverRoles) != 1 || serverRoles[0].Version != serverState.Version ||
+					len(serverRoles[0].Masters) != 0 || len(serverRoles[0].Replicas) != 0 {
+					return nil
+				}
+				return errComplete
+			}); err != errComplete {
+			return err
+		}
+		return nil
+	})
+}
+
+// undrain undrains the server at address after err stopped it being
+// replaced, and returns err.
+func (u *upgradeCoordinator) undrain(address string, err error) error {
+	if undrainErr := u.sharder.Undrain(address); undrainErr != nil {
+		return fmt.Errorf("%s; undraining %s also failed: %s", err.Error(), address, undrainErr.Error())
+	}
+	return err
+}
+
+// withTimeout calls f with a channel which is closed once the coordinator's
+// timeout elapses.
+func (u *upgradeCoordinator) withTimeout(waitingFor string, f func(cancel chan bool) error) error {
+	cancel := make(chan bool)
+	timer := time.AfterFunc(u.timeout, func() { close(cancel) })
+	defer timer.Stop()
+	if err := f(cancel); err != nil {
+		if err == discovery.ErrCancelled {
+			return fmt.Errorf("pachyderm: timed out after %s waiting for %s", u.timeout, waitingFor)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmds
+
+import (
+	"os"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/top"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"github.com/spf13/cobra"
+	"go.pedge.io/pkg/cobra"
+	"google.golang.org/grpc"
+)
+
+func Cmds(pfsdAddress string, ppsdAddress string) ([]*cobra.Command, error) {
+	var interval time.Duration
+	var count int
+	topCmd := &cobra.Command{
+		Use:   "top",
+		Short: "Display the load on the cluster.",
+		Long: `Display the load on the cluster.
+
+For every pfs server the number of shards it's the master and a replica of,
+the size of the repos in its master shards and the grpc messages and bytes it's
+receiving and sending per second are shown, followed by the jobs which are
+running. The display is refreshed every --interval until it's interrupted or
+has been shown -n times.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			pfsClientConn, err := grpc.Dial(pfsdAddress, grpcutil.DialOptions(grpc.WithInsecure())...)
+			if err != nil {
+				return err
+			}
+			ppsClientConn, err := grpc.Dial(ppsdAddress, grpcutil.DialOptions(grpc.WithInsecure())...)
+			if err != nil {
+				return err
+			}
+			return top.Watch(os.Stdout, pfs.NewAPIClient(pfsClientConn), pps.NewAPIClient(ppsClientConn), interval, count)
+		}),
+	}
+	topCmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "How often to refresh the display.")
+	topCmd.Flags().IntVarP(&count, "number", "n", 0, "The number of times to refresh the display, 0 means until interrupted.")
+
+	return []*cobra.Command{topCmd}, nil
+}
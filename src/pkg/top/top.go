@@ -0,0 +1,154 @@
+/*
+Package top samples the load on a cluster, the shards, size and grpc traffic
+of each pfs server and the jobs which are running, and prints it. Traffic is
+printed as rates between two samples, and each server's address cache hit
+rate since it started.
+*/
+package top
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/pkg/units"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"github.com/pachyderm/pachyderm/src/pps/pretty"
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/time"
+	"golang.org/x/net/context"
+)
+
+// Sample is the state of a cluster at one point in time.
+type Sample struct {
+	ServerInfos []*pfs.ServerInfo
+	// RunningJobs are the jobs which were running.
+	RunningJobs []*pps.JobInfo
+}
+
+// GetSample samples the cluster.
+func GetSample(ctx context.Context, pfsAPIClient pfs.APIClient, ppsAPIClient pps.APIClient) (*Sample, error) {
+	serverInfos, err := pfsAPIClient.ListServer(ctx, google_protobuf.EmptyInstance)
+	if err != nil {
+		return nil, err
+	}
+	jobInfos, err := ppsAPIClient.ListJob(ctx, &pps.ListJobRequest{})
+	if err != nil {
+		return nil, err
+	}
+	sample := &Sample{ServerInfos: serverInfos.ServerInfo}
+	for _, jobInfo := range jobInfos.JobInfo {
+		if jobInfo.State == pps.JobState_JOB_STATE_RUNNING {
+			sample.RunningJobs = append(sample.RunningJobs, jobInfo)
+		}
+	}
+	return sample, nil
+}
+
+// Print writes sample to w. Rates are computed from previous, which is nil
+// for the first sample, in which case they're printed as "-".
+func Print(w io.Writer, previous *Sample, sample *Sample) error {
+	previousStats := make(map[string]*pfs.ServerStats)
+	if previous != nil {
+		for _, serverInfo := range previous.ServerInfos {
+			if serverInfo.ServerStats != nil {
+				previousStats[serverInfo.Server.Id] = serverInfo.ServerStats
+			}
+		}
+	}
+	writer := tabwriter.NewWriter(w, 20, 1, 3, ' ', 0)
+	fmt.Fprint(writer, "SERVER\tMASTERS\tREPLICAS\tSIZE\tMSGS IN/S\tIN/S\tMSGS OUT/S\tOUT/S\tADDRESS HITS\t\n")
+	for _, serverInfo := range sample.ServerInfos {
+		fmt.Fprintf(writer, "%s\t", serverInfo.Server.Id)
+		var masters, replicas int
+		for _, serverRole := range serverInfo.ServerRole {
+			masters += len(serverRole.Masters)
+			replicas += len(serverRole.Replicas)
+		}
+		fmt.Fprintf(writer, "%d\t%d\t", masters, replicas)
+		if serverInfo.Error != "" {
+			fmt.Fprintf(writer, "error: %s\t\t\t\t\t\t\n", serverInfo.Error)
+			continue
+		}
+		stats := serverInfo.ServerStats
+		fmt.Fprintf(writer, "%s\t", units.BytesSize(float64(stats.SizeBytes)))
+		previousStats := previousStats[serverInfo.Server.Id]
+		fmt.Fprintf(writer, "%s\t", formatRate(previousStats, stats, messagesReceived, false))
+		fmt.Fprintf(writer, "%s\t", formatRate(previousStats, stats, bytesReceived, true))
+		fmt.Fprintf(writer, "%s\t", formatRate(previousStats, stats, messagesSent, false))
+		fmt.Fprintf(writer, "%s\t", formatRate(previousStats, stats, bytesSent, true))
+		fmt.Fprintf(writer, "%s\t\n", formatHitRate(stats.AddressesCacheStats))
+	}
+	fmt.Fprint(writer, "\t\t\t\t\t\t\t\t\t\n")
+	if len(sample.RunningJobs) == 0 {
+		fmt.Fprint(writer, "No jobs running.\t\t\t\t\t\t\t\t\t\n")
+		return writer.Flush()
+	}
+	pretty.PrintJobHeader(writer)
+	for _, jobInfo := range sample.RunningJobs {
+		pretty.PrintJobInfo(writer, jobInfo)
+	}
+	return writer.Flush()
+}
+
+func messagesReceived(stats *pfs.ServerStats) uint64 { return stats.MessagesReceived }
+func bytesReceived(stats *pfs.ServerStats) uint64    { return stats.BytesReceived }
+func messagesSent(stats *pfs.ServerStats) uint64     { return stats.MessagesSent }
+func bytesSent(stats *pfs.ServerStats) uint64        { return stats.BytesSent }
+
+// formatRate formats the rate at which the counter returned by field grew
+// between previousStats and stats. There's no rate if previousStats is nil
+// or the counter went backwards because the server restarted.
+func formatRate(previousStats *pfs.ServerStats, stats *pfs.ServerStats, field func(*pfs.ServerStats) uint64, bytes bool) string {
+	if previousStats == nil {
+		return "-"
+	}
+	previous, current := field(previousStats), field(stats)
+	if current < previous {
+		return "-"
+	}
+	elapsed := prototime.TimestampToTime(stats.Time).Sub(prototime.TimestampToTime(previousStats.Time))
+	if elapsed <= 0 {
+		return "-"
+	}
+	rate := float64(current-previous) / elapsed.Seconds()
+	if bytes {
+		return units.BytesSize(rate)
+	}
+	return fmt.Sprintf("%.1f", rate)
+}
+
+// formatHitRate formats the share of lookups in a cache which were hits,
+// there's no rate if the server is too old to report it or nothing has been
+// looked up.
+func formatHitRate(stats *shard.AddressesCacheStats) string {
+	if stats == nil || stats.Hits+stats.Misses == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(stats.Hits)/float64(stats.Hits+stats.Misses))
+}
+
+// Watch prints a sample to w every interval, clearing the terminal between
+// them, until count samples have been printed or forever if count is 0.
+func Watch(w io.Writer, pfsAPIClient pfs.APIClient, ppsAPIClient pps.APIClient, interval time.Duration, count int) error {
+	var previous *Sample
+	for i := 0; count == 0 || i < count; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		sample, err := GetSample(context.Background(), pfsAPIClient, ppsAPIClient)
+		if err != nil {
+			return err
+		}
+		// move the cursor home and clear the screen
+		fmt.Fprint(w, "\033[H\033[2J")
+		if err := Print(w, previous, sample); err != nil {
+			return err
+		}
+		previous = sample
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package top
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"github.com/pachyderm/pachyderm/src/pkg/shard"
+	"go.pedge.io/proto/time"
+)
+
+func TestPrint(t *testing.T) {
+	start := time.Now()
+	previous := &Sample{ServerInfos: []*pfs.ServerInfo{testServerInfo(start, 10, 1024)}}
+	sample := &Sample{ServerInfos: []*pfs.ServerInfo{testServerInfo(start.Add(2*time.Second), 30, 5120)}}
+	sample.ServerInfos[0].ServerStats.AddressesCacheStats = &shard.AddressesCacheStats{Hits: 3, Misses: 1}
+
+	var buffer bytes.Buffer
+	require.NoError(t, Print(&buffer, nil, previous))
+	lines := strings.Split(buffer.String(), "\n")
+	require.Equal(t, []string{"server", "2", "1", "1", "MiB", "-", "-", "-", "-", "-"}, strings.Fields(lines[1]))
+
+	buffer.Reset()
+	require.NoError(t, Print(&buffer, previous, sample))
+	lines = strings.Split(buffer.String(), "\n")
+	require.Equal(t, []string{"server", "2", "1", "1", "MiB", "10.0", "2", "KiB", "0.0", "0", "B", "75.0%"}, strings.Fields(lines[1]))
+	require.True(t, strings.Contains(buffer.String(), "No jobs running."))
+
+	// the server restarted, so its counters went backwards
+	buffer.Reset()
+	require.NoError(t, Print(&buffer, sample, &Sample{ServerInfos: []*pfs.ServerInfo{testServerInfo(start.Add(4*time.Second), 5, 512)}}))
+	lines = strings.Split(buffer.String(), "\n")
+	require.Equal(t, []string{"server", "2", "1", "1", "MiB", "-", "-", "0.0", "0", "B", "-"}, strings.Fields(lines[1]))
+}
+
+func testServerInfo(now time.Time, messagesReceived uint64, bytesReceived uint64) *pfs.ServerInfo {
+	return &pfs.ServerInfo{
+		Server: &pfs.Server{Id: "server"},
+		ServerRole: map[int64]*shard.ServerRole{
+			0: {
+				Masters:  map[uint64]bool{0: true, 1: true},
+				Replicas: map[uint64]bool{2: true},
+			},
+		},
+		ServerStats: &pfs.ServerStats{
+			SizeBytes:        1024 * 1024,
+			MessagesReceived: messagesReceived,
+			BytesReceived:    bytesReceived,
+			Time:             prototime.TimeToTimestamp(now),
+		},
+	}
+}
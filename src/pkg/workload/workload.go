@@ -92,7 +92,7 @@ func (w *worker) work(pfsClient pfs.APIClient, ppsClient pps.APIClient) error {
 			}
 			i := w.rand.Intn(len(w.started))
 			commit := w.started[i]
-			if err := pfsutil.FinishCommit(pfsClient, commit.Repo.Name, commit.Id); err != nil {
+			if _, err := pfsutil.FinishCommit(pfsClient, commit.Repo.Name, commit.Id); err != nil {
 				return err
 			}
 			w.started = append(w.started[:i], w.started[i+1:]...)
@@ -4,19 +4,36 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pachyderm/pachyderm"
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/fuse"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"github.com/pachyderm/pachyderm/src/pps/example"
+	"github.com/pachyderm/pachyderm/src/pps/ppsutil"
 	"github.com/pachyderm/pachyderm/src/pps/pretty"
 	"github.com/spf13/cobra"
 	"go.pedge.io/pkg/cobra"
+	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	kube "k8s.io/kubernetes/pkg/client/unversioned"
 )
 
-func Cmds(address string) ([]*cobra.Command, error) {
+const (
+	// how often list-job and inspect-job redraw with --watch
+	watchRefresh = 250 * time.Millisecond
+)
+
+func Cmds(address string, pfsdAddress string, kubeConfig *kube.Config) ([]*cobra.Command, error) {
 	marshaller := &jsonpb.Marshaler{Indent: "  "}
 
 	exampleCreateJobRequest, err := marshaller.MarshalToString(example.CreateJobRequest())
@@ -27,7 +44,7 @@ func Cmds(address string) ([]*cobra.Command, error) {
 	createJob := &cobra.Command{
 		Use:   "create-job -f job.json",
 		Short: "Create a new job. Returns the id of the created job.",
-		Long:  fmt.Sprintf("Create a new job from a spec, the spec looks like this\n%s", exampleCreateJobRequest),
+		Long:  fmt.Sprintf("Create a new job from a json or yaml spec, the spec looks like this\n%s", exampleCreateJobRequest),
 		Run: func(cmd *cobra.Command, args []string) {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
@@ -50,7 +67,7 @@ func Cmds(address string) ([]*cobra.Command, error) {
 				jobReader = jobFile
 			}
 			var request pps.CreateJobRequest
-			if err := jsonpb.Unmarshal(jobReader, &request); err != nil {
+			if err := ppsutil.UnmarshalSpec(jobReader, &request); err != nil {
 				errorAndExit("Error reading from stdin: %s", err.Error())
 			}
 			job, err := apiClient.CreateJob(
@@ -65,15 +82,21 @@ func Cmds(address string) ([]*cobra.Command, error) {
 	}
 	createJob.Flags().StringVarP(&jobPath, "file", "f", "-", "The file containing the job, - reads from stdin.")
 
+	var watch bool
+	var spec bool
 	inspectJob := &cobra.Command{
 		Use:   "inspect-job job-id",
 		Short: "Return info about a job.",
-		Long:  "Return info about a job.",
+		Long: `Return info about a job, with --watch it's redrawn whenever it changes until the job finishes.
+With --spec the job is printed as a spec that create-job accepts.`,
 		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
 				return err
 			}
+			if watch {
+				return watchJobs(apiClient, &pps.WatchJobsRequest{Job: &pps.Job{Id: args[0]}}, true)
+			}
 			jobInfo, err := apiClient.InspectJob(
 				context.Background(),
 				&pps.InspectJobRequest{
@@ -88,6 +111,9 @@ func Cmds(address string) ([]*cobra.Command, error) {
 			if jobInfo == nil {
 				errorAndExit("Job %s not found.", args[0])
 			}
+			if spec {
+				return printSpec(marshaller, ppsutil.JobSpec(jobInfo))
+			}
 			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
 			pretty.PrintJobHeader(writer)
 			pretty.PrintJobInfo(writer, jobInfo)
@@ -95,11 +121,113 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	var waitTimeout time.Duration
+	waitJob := &cobra.Command{
+		Use:   "wait-job job-id",
+		Short: "Wait for a job to finish, exits 0 if it succeeded and 1 if it didn't.",
+		Long: `Wait for a job to finish and return info about it. Exits 0 if the job
+succeeded and 1 if it failed or didn't finish before --timeout, so scripts can
+gate on jobs without polling.`,
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+			if waitTimeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, waitTimeout)
+				defer cancel()
+			}
+			jobInfo, err := apiClient.InspectJob(
+				ctx,
+				&pps.InspectJobRequest{
+					Job: &pps.Job{
+						Id: args[0],
+					},
+					BlockState: true,
+				},
+			)
+			if err != nil {
+				if ctx.Err() == context.DeadlineExceeded {
+					errorAndExit("Timed out after %s waiting for job %s.", waitTimeout, args[0])
+				}
+				errorAndExit("Error from InspectJob: %s", err.Error())
+			}
+			if jobInfo == nil {
+				errorAndExit("Job %s not found.", args[0])
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintJobHeader(writer)
+			pretty.PrintJobInfo(writer, jobInfo)
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if jobInfo.State != pps.JobState_JOB_STATE_SUCCESS {
+				errorAndExit("Job %s failed.", args[0])
+			}
+			return nil
+		}),
+	}
+	waitJob.Flags().DurationVar(&waitTimeout, "timeout", 0, "The longest to wait for the job, ie 30m, 0 waits forever.")
+
+	inspectJob.Flags().BoolVarP(&watch, "watch", "w", false, "Redraw the job whenever it changes.")
+	inspectJob.Flags().BoolVar(&spec, "spec", false, "Print the job as a spec for create-job instead.")
+
+	reproduceJob := &cobra.Command{
+		Use:   "reproduce-job job-id",
+		Short: "Rerun a job exactly as it originally ran. Returns the id of the new job.",
+		Long:  "Rerun a job from the manifest recorded when it started, its image is pinned to the digest it originally ran.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			jobManifest, err := apiClient.InspectJobManifest(
+				context.Background(),
+				&pps.InspectJobRequest{
+					Job: &pps.Job{
+						Id: args[0],
+					},
+				},
+			)
+			if err != nil {
+				errorAndExit("Error from InspectJobManifest: %s", err.Error())
+			}
+			transform := *jobManifest.Transform
+			transform.Build = nil
+			if jobManifest.ImageDigest != "" {
+				transform.Image = jobManifest.ImageDigest
+			} else {
+				fmt.Fprintf(os.Stderr, "Job %s has no image digest, %s may have changed since it ran.\n", args[0], transform.Image)
+			}
+			if jobManifest.PachydermVersion != pachyderm.Version.VersionString() {
+				fmt.Fprintf(os.Stderr, "Job %s ran on pachyderm %s, this is %s.\n", args[0], jobManifest.PachydermVersion, pachyderm.Version.VersionString())
+			}
+			// the job isn't part of the pipeline so that its output doesn't
+			// land in the pipeline's repo
+			job, err := apiClient.CreateJob(
+				context.Background(),
+				&pps.CreateJobRequest{
+					Transform:      &transform,
+					Shards:         jobManifest.Shards,
+					Inputs:         jobManifest.Inputs,
+					ExternalInputs: jobManifest.ExternalInputs,
+				},
+			)
+			if err != nil {
+				errorAndExit("Error from CreateJob: %s", err.Error())
+			}
+			fmt.Println(job.Id)
+			return nil
+		}),
+	}
+
 	var pipelineName string
 	listJob := &cobra.Command{
 		Use:   "list-job -p pipeline-name",
 		Short: "Return info about all jobs.",
-		Long:  "Return info about all jobs.",
+		Long:  "Return info about all jobs, with --watch the table is redrawn whenever a job changes until interrupted.",
 		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
@@ -111,6 +239,9 @@ func Cmds(address string) ([]*cobra.Command, error) {
 					Name: pipelineName,
 				}
 			}
+			if watch {
+				return watchJobs(apiClient, &pps.WatchJobsRequest{Pipeline: pipeline}, false)
+			}
 			jobInfos, err := apiClient.ListJob(
 				context.Background(),
 				&pps.ListJobRequest{
@@ -129,6 +260,105 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 	listJob.Flags().StringVarP(&pipelineName, "pipeline", "p", "", "Limit to jobs made by pipeline.")
+	listJob.Flags().BoolVarP(&watch, "watch", "w", false, "Redraw the table whenever a job changes.")
+
+	var unscheduled bool
+	listQueue := &cobra.Command{
+		Use:   "list-queue",
+		Short: "Return info about jobs waiting to run.",
+		Long: `Return info about jobs waiting for their pipeline or namespace to be under
+quota, in the order they'll be started, and the quota each is waiting on. With
+--unscheduled, return the jobs which failed to be started instead.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			jobInfos, err := apiClient.ListQueue(
+				context.Background(),
+				&pps.ListQueueRequest{
+					Unscheduled: unscheduled,
+				},
+			)
+			if err != nil {
+				errorAndExit("Error from ListQueue: %s", err.Error())
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			pretty.PrintQueuedJobHeader(writer)
+			for _, jobInfo := range jobInfos.JobInfo {
+				pretty.PrintQueuedJobInfo(writer, jobInfo)
+			}
+			return writer.Flush()
+		}),
+	}
+	listQueue.Flags().BoolVarP(&unscheduled, "unscheduled", "u", false, "Return the jobs which failed to be started, which requeue-job can retry.")
+
+	requeueJob := &cobra.Command{
+		Use:   "requeue-job job-id",
+		Short: "Queue a job which failed to be started again.",
+		Long:  "Queue a new job to run a job which failed to be started, as listed by list-queue --unscheduled, once whatever stopped it is fixed. The new job's id is printed, the failed job stays failed.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			job, err := apiClient.RequeueJob(
+				context.Background(),
+				&pps.RequeueJobRequest{
+					Job: &pps.Job{Id: args[0]},
+				},
+			)
+			if err != nil {
+				errorAndExit("Error from RequeueJob: %s", err.Error())
+			}
+			fmt.Println(job.Id)
+			return nil
+		}),
+	}
+
+	listRun := &cobra.Command{
+		Use:   "list-run [run-id]",
+		Short: "Return info about runs, or about the jobs in a run.",
+		Long:  "Return info about runs, a run is every job transitively triggered by one upstream commit. With a run-id, return the jobs in that run.",
+		Run: pkgcobra.Run(func(args []string) error {
+			if len(args) > 1 {
+				return fmt.Errorf("expected at most one run-id")
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+			if len(args) == 1 {
+				jobInfos, err := apiClient.ListJob(
+					context.Background(),
+					&pps.ListJobRequest{
+						RunId: args[0],
+					},
+				)
+				if err != nil {
+					errorAndExit("Error from ListJob: %s", err.Error())
+				}
+				pretty.PrintJobHeader(writer)
+				for _, jobInfo := range jobInfos.JobInfo {
+					pretty.PrintJobInfo(writer, jobInfo)
+				}
+				return writer.Flush()
+			}
+			runInfos, err := apiClient.ListRun(
+				context.Background(),
+				&pps.ListRunRequest{},
+			)
+			if err != nil {
+				errorAndExit("Error from ListRun: %s", err.Error())
+			}
+			pretty.PrintRunHeader(writer)
+			for _, runInfo := range runInfos.RunInfo {
+				pretty.PrintRunInfo(writer, runInfo)
+			}
+			return writer.Flush()
+		}),
+	}
 
 	var pipelinePath string
 	exampleCreatePipelineRequest, err := marshaller.MarshalToString(example.CreatePipelineRequest())
@@ -138,7 +368,7 @@ func Cmds(address string) ([]*cobra.Command, error) {
 	createPipeline := &cobra.Command{
 		Use:   "create-pipeline -f pipeline.json",
 		Short: "Create a new pipeline.",
-		Long:  fmt.Sprintf("Create a new pipeline from a spec, the spec looks like this\n%s", exampleCreatePipelineRequest),
+		Long:  fmt.Sprintf("Create a new pipeline from a json or yaml spec, the spec looks like this\n%s", exampleCreatePipelineRequest),
 		Run: func(cmd *cobra.Command, args []string) {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
@@ -161,7 +391,7 @@ func Cmds(address string) ([]*cobra.Command, error) {
 				pipelineReader = pipelineFile
 			}
 			var request pps.CreatePipelineRequest
-			if err := jsonpb.Unmarshal(pipelineReader, &request); err != nil {
+			if err := ppsutil.UnmarshalSpec(pipelineReader, &request); err != nil {
 				errorAndExit("Error reading from stdin: %s", err.Error())
 			}
 			if _, err := apiClient.CreatePipeline(
@@ -177,7 +407,8 @@ func Cmds(address string) ([]*cobra.Command, error) {
 	inspectPipeline := &cobra.Command{
 		Use:   "inspect-pipeline pipeline-name",
 		Short: "Return info about a pipeline.",
-		Long:  "Return info about a pipeline.",
+		Long: `Return info about a pipeline. With --spec the pipeline is printed as a spec
+that create-pipeline accepts, so pipelines can be kept in version control.`,
 		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
 			apiClient, err := getAPIClient(address)
 			if err != nil {
@@ -197,6 +428,9 @@ func Cmds(address string) ([]*cobra.Command, error) {
 			if pipelineInfo == nil {
 				errorAndExit("Pipeline %s not found.", args[0])
 			}
+			if spec {
+				return printSpec(marshaller, ppsutil.PipelineSpec(pipelineInfo))
+			}
 			writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
 			pretty.PrintPipelineHeader(writer)
 			pretty.PrintPipelineInfo(writer, pipelineInfo)
@@ -204,6 +438,8 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	inspectPipeline.Flags().BoolVar(&spec, "spec", false, "Print the pipeline as a spec for create-pipeline instead.")
+
 	listPipeline := &cobra.Command{
 		Use:   "list-pipeline",
 		Short: "Return info about all pipelines.",
@@ -252,26 +488,354 @@ func Cmds(address string) ([]*cobra.Command, error) {
 		}),
 	}
 
+	var downstream bool
+	lineage := &cobra.Command{
+		Use:   "lineage",
+		Short: "Return the commits and jobs upstream of a file or commit.",
+		Long:  "Return the commits and jobs upstream of a file or commit, or with --downstream the commits and jobs computed from it.",
+	}
+	lineage.PersistentFlags().BoolVarP(&downstream, "downstream", "d", false, "Walk the jobs which consumed the commit instead of those which produced it.")
+	printLineage := func(commit *pfs.Commit) error {
+		apiClient, err := getAPIClient(address)
+		if err != nil {
+			return err
+		}
+		lineageResponse, err := apiClient.InspectLineage(
+			context.Background(),
+			&pps.InspectLineageRequest{
+				Commit:     commit,
+				Downstream: downstream,
+			},
+		)
+		if err != nil {
+			errorAndExit("Error from InspectLineage: %s", err.Error())
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+		pretty.PrintLineageNodeHeader(writer)
+		for _, lineageNode := range lineageResponse.Node {
+			pretty.PrintLineageNode(writer, lineageNode)
+		}
+		return writer.Flush()
+	}
+	lineage.AddCommand(&cobra.Command{
+		Use:   "file repo@commit-id:path/to/file",
+		Short: "Return the lineage of a file.",
+		Long:  "Return the lineage of a file, files share the lineage of the commit they're in.",
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			repoCommit := strings.SplitN(args[0], ":", 2)
+			split := strings.SplitN(repoCommit[0], "@", 2)
+			if len(split) != 2 || split[0] == "" || split[1] == "" {
+				return fmt.Errorf("invalid file %s, expected repo@commit-id:path/to/file", args[0])
+			}
+			return printLineage(pfsutil.NewCommit(split[0], split[1]))
+		}),
+	})
+	lineage.AddCommand(&cobra.Command{
+		Use:   "commit repo-name commit-id",
+		Short: "Return the lineage of a commit.",
+		Long:  "Return the lineage of a commit.",
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			return printLineage(pfsutil.NewCommit(args[0], args[1]))
+		}),
+	})
+
+	var flushTimeout time.Duration
+	flushCommit := &cobra.Command{
+		Use:   "flush-commit repo@commit-id...",
+		Short: "Wait for all jobs caused by the commits to finish and return their output commits.",
+		Long:  "Wait for every pipeline downstream of the commits to finish the jobs they trigger and return the commits those jobs output.",
+		Run: pkgcobra.Run(func(args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("expected at least one commit")
+			}
+			var commits []*pfs.Commit
+			for _, arg := range args {
+				split := strings.SplitN(arg, "@", 2)
+				if len(split) != 2 || split[0] == "" || split[1] == "" {
+					return fmt.Errorf("invalid commit %s, expected repo@commit-id", arg)
+				}
+				commits = append(commits, pfsutil.NewCommit(split[0], split[1]))
+			}
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			response, err := apiClient.FlushCommit(
+				context.Background(),
+				&pps.FlushCommitRequest{
+					Commit:  commits,
+					Timeout: prototime.DurationToProto(flushTimeout),
+				},
+			)
+			if err != nil {
+				errorAndExit("Error from FlushCommit: %s", err.Error())
+			}
+			for _, commit := range response.OutputCommit {
+				fmt.Printf("%s@%s\n", commit.Repo.Name, commit.Id)
+			}
+			return nil
+		}),
+	}
+	flushCommit.Flags().DurationVar(&flushTimeout, "timeout", 0, "The longest to wait for the jobs, ie 30m, 0 waits an hour.")
+
+	var mountShard uint64
+	mountJob := &cobra.Command{
+		Use:   "mount-job job-id mount-point",
+		Short: "Mount what a job saw, read-only.",
+		Long: `Mount the input commits of one of a job's shards, sharded the way the shard
+saw them, and the job's output at out, read-only. A successful job's output is
+its output commit, a failed job's is what the shard wrote before the job
+failed, which is kept for 7 days. A running job's output isn't mounted.`,
+		Run: pkgcobra.RunFixedArgs(2, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			pfsAPIClient, err := getPfsAPIClient(pfsdAddress)
+			if err != nil {
+				return err
+			}
+			job := &pps.Job{Id: args[0]}
+			jobInfo, err := apiClient.InspectJob(
+				context.Background(),
+				&pps.InspectJobRequest{
+					Job: job,
+				},
+			)
+			if err != nil {
+				errorAndExit("Error from InspectJob: %s", err.Error())
+			}
+			if mountShard >= jobInfo.Shards {
+				return fmt.Errorf("job %s has %d shards, there's no shard %d", args[0], jobInfo.Shards, mountShard)
+			}
+			commitMounts := ppsutil.InputCommitMounts(jobInfo.Inputs, jobInfo.Shards, mountShard)
+			var outputCommit *pfs.Commit
+			switch jobInfo.State {
+			case pps.JobState_JOB_STATE_SUCCESS:
+				outputCommit = jobInfo.OutputCommit
+			case pps.JobState_JOB_STATE_FAILURE:
+				outputCommit = pfsutil.NewCommit(pps.JobFailedOutputRepo(job).Name, pps.ShardTag(mountShard))
+				if _, err := pfsutil.InspectCommit(pfsAPIClient, outputCommit.Repo.Name, outputCommit.Id); err != nil {
+					fmt.Fprintf(os.Stderr, "The output of shard %d of job %s is gone, it isn't mounted.\n", mountShard, args[0])
+					outputCommit = nil
+				}
+			default:
+				fmt.Fprintf(os.Stderr, "Job %s hasn't finished, its output isn't mounted.\n", args[0])
+			}
+			if outputCommit != nil {
+				commitMounts = append(commitMounts, &fuse.CommitMount{
+					Commit: outputCommit,
+					Alias:  "out",
+				})
+			}
+			mounter := fuse.NewMounterWithOptions(pfsdAddress, pfsAPIClient, fuse.MounterOptions{ReadOnly: true})
+			return mounter.Mount(args[1], commitMounts, nil)
+		}),
+	}
+	mountJob.Flags().Uint64VarP(&mountShard, "shard", "s", 0, "The shard to mount.")
+
+	var debugShard uint64
+	var debugShell string
+	debugJobCmd := &cobra.Command{
+		Use:   "debug-job job-id",
+		Short: "Open a shell in a container set up like one of a job's.",
+		Long: `Start a container from the job's image, with its env and what one of its
+shards sees mounted at /pfs, and open a shell in it. Output written to
+/pfs/out goes to the debug-<job-id> scratch repo rather than the job's output,
+and is kept for a day. The container is deleted when the shell exits.`,
+		Run: pkgcobra.RunFixedArgs(1, func(args []string) error {
+			apiClient, err := getAPIClient(address)
+			if err != nil {
+				return err
+			}
+			return debugJob(apiClient, kubeConfig, args[0], debugShard, debugShell)
+		}),
+	}
+	debugJobCmd.Flags().Uint64VarP(&debugShard, "shard", "s", 0, "The shard to set the container up as.")
+	debugJobCmd.Flags().StringVar(&debugShell, "shell", "sh", "The shell to run, it must be in the job's image.")
+
+	var lintPath string
+	var lintJob bool
+	lint := &cobra.Command{
+		Use:   "lint -f pipeline.json",
+		Short: "Check a pipeline or job spec for mistakes.",
+		Long: `Check a pipeline spec, or a job spec with --job, for mistakes. Errors are
+mistakes pps rejects the spec for, warnings are specs pps accepts which
+probably don't do what was meant. Exits 1 if there are errors.`,
+		Run: pkgcobra.RunFixedArgs(0, func(args []string) error {
+			specReader := io.Reader(os.Stdin)
+			if lintPath != "-" {
+				specFile, err := os.Open(lintPath)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = specFile.Close()
+				}()
+				specReader = specFile
+			}
+			var problems []*ppsutil.Problem
+			if lintJob {
+				var request pps.CreateJobRequest
+				if err := ppsutil.UnmarshalSpec(specReader, &request); err != nil {
+					errorAndExit("Error reading %s: %s", lintPath, err.Error())
+				}
+				problems = ppsutil.LintJob(&request)
+			} else {
+				var request pps.CreatePipelineRequest
+				if err := ppsutil.UnmarshalSpec(specReader, &request); err != nil {
+					errorAndExit("Error reading %s: %s", lintPath, err.Error())
+				}
+				problems = ppsutil.LintPipeline(&request)
+			}
+			failed := false
+			for _, problem := range problems {
+				fmt.Println(problem.String())
+				failed = failed || problem.Error
+			}
+			if failed {
+				os.Exit(1)
+			}
+			return nil
+		}),
+	}
+	lint.Flags().StringVarP(&lintPath, "file", "f", "-", "The file containing the spec, - reads from stdin.")
+	lint.Flags().BoolVar(&lintJob, "job", false, "The spec is a job rather than a pipeline.")
+
 	var result []*cobra.Command
 	result = append(result, createJob)
 	result = append(result, inspectJob)
+	result = append(result, waitJob)
 	result = append(result, listJob)
+	result = append(result, reproduceJob)
+	result = append(result, mountJob)
+	result = append(result, debugJobCmd)
+	result = append(result, listQueue)
+	result = append(result, requeueJob)
+	result = append(result, listRun)
 	result = append(result, createPipeline)
 	result = append(result, inspectPipeline)
 	result = append(result, listPipeline)
 	result = append(result, deletePipeline)
+	result = append(result, lineage)
+	result = append(result, flushCommit)
+	result = append(result, lint)
 	return result, nil
 }
 
+// watchJobs redraws a table of the jobs matching request whenever they
+// change, at most every watchRefresh so that a burst of changes is drawn
+// once. It returns when the stream ends or, if untilFinished, once a job has
+// finished.
+func watchJobs(apiClient pps.APIClient, request *pps.WatchJobsRequest, untilFinished bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchJobsClient, err := apiClient.WatchJobs(ctx, request)
+	if err != nil {
+		return err
+	}
+	updates := make(chan *pps.JobInfo)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			jobInfo, err := watchJobsClient.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case updates <- jobInfo:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	jobInfos := make(map[string]*pps.JobInfo)
+	changed := false
+	finished := false
+	ticker := time.NewTicker(watchRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case jobInfo := <-updates:
+			jobInfos[jobInfo.Job.Id] = jobInfo
+			changed = true
+			finished = jobInfo.State != pps.JobState_JOB_STATE_RUNNING && jobInfo.State != pps.JobState_JOB_STATE_QUEUED
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+			if !changed {
+				continue
+			}
+			if err := drawJobs(jobInfos); err != nil {
+				return err
+			}
+			changed = false
+			if untilFinished && finished {
+				return nil
+			}
+		}
+	}
+}
+
+// drawJobs clears the terminal and prints jobInfos newest first.
+func drawJobs(jobInfos map[string]*pps.JobInfo) error {
+	var sorted []*pps.JobInfo
+	for _, jobInfo := range jobInfos {
+		sorted = append(sorted, jobInfo)
+	}
+	sort.Sort(jobInfosByCreatedAtDesc(sorted))
+	fmt.Print("\033[H\033[2J")
+	writer := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	pretty.PrintJobHeader(writer)
+	for _, jobInfo := range sorted {
+		pretty.PrintJobInfo(writer, jobInfo)
+	}
+	return writer.Flush()
+}
+
+type jobInfosByCreatedAtDesc []*pps.JobInfo
+
+func (s jobInfosByCreatedAtDesc) Len() int          { return len(s) }
+func (s jobInfosByCreatedAtDesc) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s jobInfosByCreatedAtDesc) Less(i int, j int) bool {
+	return prototime.TimestampLess(s[j].CreatedAt, s[i].CreatedAt)
+}
+
+// printSpec prints spec as the json that create-job and create-pipeline read.
+func printSpec(marshaller *jsonpb.Marshaler, spec proto.Message) error {
+	s, err := marshaller.MarshalToString(spec)
+	if err != nil {
+		return err
+	}
+	fmt.Println(s)
+	return nil
+}
+
 func errorAndExit(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, "%s\n", fmt.Sprintf(format, args...))
 	os.Exit(1)
 }
 
 func getAPIClient(address string) (pps.APIClient, error) {
-	clientConn, err := grpc.Dial(address, grpc.WithInsecure())
+	clientConn, err := dial(address)
 	if err != nil {
 		return nil, err
 	}
 	return pps.NewAPIClient(clientConn), nil
 }
+
+func getPfsAPIClient(address string) (pfs.APIClient, error) {
+	clientConn, err := dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return pfs.NewAPIClient(clientConn), nil
+}
+
+func dial(address string) (*grpc.ClientConn, error) {
+	return audit.Dial(address)
+}
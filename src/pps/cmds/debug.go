@@ -0,0 +1,86 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pps"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+	kube "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const (
+	// how long debug-job waits for the debug pod to start, pulling the
+	// job's image can take a while
+	debugPodTimeout = 5 * time.Minute
+	debugPodPoll    = time.Second
+)
+
+// debugJob has ppsd start a debug pod for shard of the job, execs shell in
+// it with kubectl and deletes it once the shell exits.
+func debugJob(apiClient pps.APIClient, kubeConfig *kube.Config, jobID string, shard uint64, shell string) (retErr error) {
+	kubeClient, err := kube.New(kubeConfig)
+	if err != nil {
+		return err
+	}
+	response, err := apiClient.DebugJob(
+		context.Background(),
+		&pps.DebugJobRequest{
+			Job: &pps.Job{
+				Id: jobID,
+			},
+			Shard: shard,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := kubeClient.Pods(api.NamespaceDefault).Delete(response.Pod, nil); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Waiting for pod %s to start.\n", response.Pod)
+	if err := waitForPod(kubeClient, response.Pod); err != nil {
+		return err
+	}
+	args := []string{"--server", kubeConfig.Host}
+	if kubeConfig.Username != "" {
+		args = append(args, "--username", kubeConfig.Username, "--password", kubeConfig.Password)
+	}
+	args = append(args, "exec", "-i", "-t", response.Pod, "-c", "user", "--", shell)
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// the shell's exit status is the user's business, only failing to exec
+	// is an error
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForPod blocks until pod is running.
+func waitForPod(kubeClient *kube.Client, name string) error {
+	deadline := time.Now().Add(debugPodTimeout)
+	for time.Now().Before(deadline) {
+		pod, err := kubeClient.Pods(api.NamespaceDefault).Get(name)
+		if err != nil {
+			return err
+		}
+		switch pod.Status.Phase {
+		case api.PodRunning:
+			return nil
+		case api.PodSucceeded, api.PodFailed:
+			return fmt.Errorf("pod %s exited before it could be exec'd into, its image may not have /job-shim", name)
+		}
+		time.Sleep(debugPodPoll)
+	}
+	return fmt.Errorf("pod %s didn't start within %s", name, debugPodTimeout)
+}
@@ -1,93 +1,242 @@
 package jobserver
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pachyderm/pachyderm"
 	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pfs/fuse"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/events"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
+	"github.com/pachyderm/pachyderm/src/pkg/uuid"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"github.com/pachyderm/pachyderm/src/pps/persist"
+	"github.com/pachyderm/pachyderm/src/pps/ppsutil"
 	"go.pedge.io/google-protobuf"
 	"go.pedge.io/proto/rpclog"
+	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
 	"golang.org/x/net/context"
 	"k8s.io/kubernetes/pkg/api"
+	kubeerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
 	"k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	kube "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	kubelabels "k8s.io/kubernetes/pkg/labels"
+)
+
+const (
+	gpuResourceName api.ResourceName = "alpha.kubernetes.io/nvidia-gpu"
+	// flushCommitPollInterval is how often FlushCommit checks whether a
+	// pipeline has created the job for a commit.
+	flushCommitPollInterval = time.Second
+	// defaultFlushCommitTimeout is how long FlushCommit waits when the
+	// request doesn't set a timeout.
+	defaultFlushCommitTimeout = time.Hour
+	// cacheHostPath is the directory on each node where blocks of job
+	// inputs are cached, it's mounted read only into job containers at
+	// cacheMountPath. Only the pod's cache container, which reads the blocks
+	// from pfs itself, can write it; the job container asks it for the
+	// blocks it's missing in cacheRequestMountPath.
+	cacheHostPath         = "/var/pachyderm/pfs-cache"
+	cacheMountPath        = "/pfs-cache"
+	cacheRequestMountPath = "/pfs-cache-requests"
+	cacheContainerName    = "pfs-cache"
+	// blockHostPath is objd's block directory, it's mounted read only into
+	// the cache container of job pods at blockMountPath so that blocks of
+	// inputs on objd's node are cached without going through pfs. Job
+	// containers never see it, it has the blocks of every repo.
+	blockHostPath  = "/var/pachyderm/obj/block"
+	blockMountPath = "/pfs-blocks"
+	// sharedMountPath is where an empty directory shared by the transform
+	// and its sidecars is mounted, fuse mounts such as /pfs can't be shared
+	// between containers.
+	sharedMountPath = "/shared"
+	// failedOutputTTL is how long the output of a failed job's shards is
+	// kept, for mount-job, after the job fails.
+	failedOutputTTL = 7 * 24 * time.Hour
+	// jobSweepInterval is how often running jobs are checked for having
+	// been deleted or having completed without calling FinishJob.
+	jobSweepInterval = time.Minute
+	// kubeJobDeleteTimeout is how long we wait for kubernetes to finish
+	// deleting a job before its name can be used again.
+	kubeJobDeleteTimeout = 2 * time.Minute
+	// kubeJobDeletePollInterval is how often we check whether it has.
+	kubeJobDeletePollInterval = time.Second
 )
 
 var (
-	trueVal = true
-	suite   = "pachyderm"
+	trueVal   = true
+	suite     = "pachyderm"
+	marshaler = &jsonpb.Marshaler{Indent: "  "}
 )
 
 type jobState struct {
-	start        uint64      // the number of shards started
-	finish       uint64      // the number of shards finished
-	outputCommit *pfs.Commit // the output commit
-	commitReady  chan bool   // closed when outCommit has been started (and is non nil)
-	finished     chan bool   // closed when the job has been finished, the jobState will be deleted afterward
-	success      bool
+	startedShards   map[uint64]bool        // the shards handed out since the job was last started, includes finished shards
+	finishedShards  map[uint64]bool        // the shards that have finished, these aren't rerun if the job is preempted
+	finishingShards map[uint64]bool        // the shards whose scratch commit is being finished, they're finished once it is
+	setupStarted    bool                   // true once a shard has started creating outputCommit
+	outputCommit    *pfs.Commit            // the output commit
+	scratchCommits  map[uint64]*pfs.Commit // the commit each shard writes its output to, merged into outputCommit at finish
+	commitReady     chan bool              // closed when outCommit has been started (and is non nil)
+	finished        chan bool              // closed when the job has been finished, the jobState will be deleted afterward
+	success         bool
+	stats           *pps.JobStats     // aggregated over all finished shards
+	datumHashes     map[uint64]string // the datum hash of each shard, "" if the shard's output can't be cached
+	cachedShards    map[uint64]bool   // shards whose output was served from the datum cache
+	speculation     *speculation      // the speculative runs of straggling shards
+}
+
+// nextShard returns the lowest shard that hasn't been handed out, it returns
+// false if all of them have been.
+func (j *jobState) nextShard(shards uint64) (uint64, bool) {
+	for shard := uint64(0); shard < shards; shard++ {
+		if !j.startedShards[shard] {
+			return shard, true
+		}
+	}
+	return 0, false
 }
 
 func newJobState() *jobState {
 	return &jobState{
-		start:        0,
-		finish:       0,
-		outputCommit: nil,
-		commitReady:  make(chan bool),
-		finished:     make(chan bool),
-		success:      true,
+		startedShards:   make(map[uint64]bool),
+		finishedShards:  make(map[uint64]bool),
+		finishingShards: make(map[uint64]bool),
+		setupStarted:    false,
+		outputCommit:    nil,
+		scratchCommits:  make(map[uint64]*pfs.Commit),
+		commitReady:     make(chan bool),
+		finished:        make(chan bool),
+		success:         true,
+		stats:           &pps.JobStats{},
+		datumHashes:     make(map[uint64]string),
+		cachedShards:    make(map[uint64]bool),
+		speculation:     newSpeculation(),
 	}
 }
 
+// queuedJob is a job waiting for its pipeline and namespace to be under quota.
+type queuedJob struct {
+	jobInfo           *persist.JobInfo
+	maxConcurrentJobs uint64    // the pipeline's limit, 0 means no limit
+	err               error     // why the job failed to be scheduled, if it did
+	started           time.Time // when the job was counted as running
+}
+
 type apiServer struct {
 	protorpclog.Logger
 	pfsAPIClient     pfs.APIClient
 	persistAPIServer persist.APIServer
 	kubeClient       *kube.Client
+	auditRecorder    audit.Recorder
+	eventRecorder    events.Recorder
+	scaler           Scaler
 	jobStates        map[string]*jobState
 	lock             sync.Mutex
+	queue            []*queuedJob
+	running          map[string]*queuedJob  // the jobs started by schedule, by job id
+	pipelineRunning  map[string]uint64      // the number of running jobs for each pipeline
+	namespaceRunning map[string]uint64      // the number of running jobs for each namespace
+	namespaceQuotas  map[string]uint64      // the max concurrent jobs for each namespace, missing means no limit
+	jobTimers        map[string]*time.Timer // the job_timeout timers of running jobs, by job id
+	unscheduled      map[string]*queuedJob  // the jobs schedule failed to start since ppsd started, by job id
+	queueLock        sync.Mutex
 }
 
 func newAPIServer(
 	pfsAPIClient pfs.APIClient,
 	persistAPIServer persist.APIServer,
 	kubeClient *kube.Client,
+	auditRecorder audit.Recorder,
+	eventRecorder events.Recorder,
+	scaler Scaler,
 ) *apiServer {
 	return &apiServer{
 		protorpclog.NewLogger("pachyderm.pps.JobAPI"),
 		pfsAPIClient,
 		persistAPIServer,
 		kubeClient,
+		auditRecorder,
+		eventRecorder,
+		scaler,
 		make(map[string]*jobState),
 		sync.Mutex{},
+		nil,
+		make(map[string]*queuedJob),
+		make(map[string]uint64),
+		make(map[string]uint64),
+		make(map[string]uint64),
+		make(map[string]*time.Timer),
+		make(map[string]*queuedJob),
+		sync.Mutex{},
 	}
 }
 
 func (a *apiServer) CreateJob(ctx context.Context, request *pps.CreateJobRequest) (response *pps.Job, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
-	if request.Shards == 0 {
-		return nil, fmt.Errorf("pachyderm.pps.jobserver: request.Shards cannot be 0")
+	defer func() {
+		var repo string
+		if request.Pipeline != nil {
+			repo = pps.PipelineRepo(request.Pipeline).Name
+		}
+		a.auditRecorder.Record(ctx, "pachyderm.pps.JobAPI.CreateJob", repo, request, retErr)
+	}()
+	ctx, cancel := grpcutil.WithTimeout(ctx)
+	defer cancel()
+	if err := ppsutil.ValidateJob(request); err != nil {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: %s", err.Error())
 	}
-	repoSet := make(map[string]bool)
+	// inputs may be given by tag, the job records the commits the tags refer
+	// to so that it reads the same data however it was started
 	for _, input := range request.Inputs {
-		repoSet[input.Commit.Repo.Name] = true
-	}
-	if len(repoSet) < len(request.Inputs) {
-		return nil, fmt.Errorf("pachyderm.pps.jobserver: duplicate repo in job")
+		commitInfo, err := a.pfsAPIClient.InspectCommit(ctx, &pfs.InspectCommitRequest{Commit: input.Commit})
+		if err != nil {
+			return nil, err
+		}
+		input.Commit = commitInfo.Commit
 	}
-	// TODO validate job to make sure input commits and output repo exist
+	// TODO validate job to make sure output repo exists
 	persistJobInfo := &persist.JobInfo{
-		Shards:    request.Shards,
-		Transform: request.Transform,
-		Inputs:    request.Inputs,
-		ParentJob: request.ParentJob,
+		Shards:         request.Shards,
+		Transform:      request.Transform,
+		Inputs:         request.Inputs,
+		ParentJob:      request.ParentJob,
+		State:          pps.JobState_JOB_STATE_QUEUED,
+		Priority:       request.Priority,
+		Preemptible:    request.Preemptible,
+		RunId:          request.RunId,
+		ExternalInputs: request.ExternalInputs,
 	}
+	if persistJobInfo.RunId == "" {
+		persistJobInfo.RunId = uuid.NewWithoutDashes()
+	}
+	var maxConcurrentJobs uint64
 	if request.Pipeline != nil {
 		persistJobInfo.PipelineName = request.Pipeline.Name
+		pipelineInfo, err := a.persistAPIServer.GetPipelineInfo(ctx, request.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		persistJobInfo.Namespace = pipelineInfo.Namespace
+		persistJobInfo.Priority = pipelineInfo.Priority
+		persistJobInfo.Preemptible = pipelineInfo.Preemptible
+		maxConcurrentJobs = pipelineInfo.MaxConcurrentJobs
 	}
 	if a.kubeClient == nil {
 		return nil, fmt.Errorf("pachyderm.pps.jobserver: no job backend")
@@ -96,9 +245,13 @@ func (a *apiServer) CreateJob(ctx context.Context, request *pps.CreateJobRequest
 	if err != nil {
 		return nil, err
 	}
-	if _, err := a.kubeClient.Jobs(api.NamespaceDefault).Create(job(persistJobInfo)); err != nil {
-		return nil, err
-	}
+	a.queueLock.Lock()
+	a.queue = append(a.queue, &queuedJob{
+		jobInfo:           persistJobInfo,
+		maxConcurrentJobs: maxConcurrentJobs,
+	})
+	a.queueLock.Unlock()
+	a.schedule(ctx)
 	return &pps.Job{
 		Id: persistJobInfo.JobId,
 	}, nil
@@ -119,12 +272,106 @@ func (a *apiServer) ListJob(ctx context.Context, request *pps.ListJobRequest) (r
 	if err != nil {
 		return nil, err
 	}
-	jobInfos := make([]*pps.JobInfo, len(persistJobInfos.JobInfo))
-	for i, persistJobInfo := range persistJobInfos.JobInfo {
+	var jobInfos []*pps.JobInfo
+	for _, persistJobInfo := range persistJobInfos.JobInfo {
+		if request.RunId != "" && persistJobInfo.RunId != request.RunId {
+			continue
+		}
+		jobInfo, err := newJobInfo(persistJobInfo)
+		if err != nil {
+			return nil, err
+		}
+		jobInfos = append(jobInfos, jobInfo)
+	}
+	return &pps.JobInfos{
+		JobInfo: jobInfos,
+	}, nil
+}
+
+func (a *apiServer) WatchJobs(request *pps.WatchJobsRequest, server pps.JobAPI_WatchJobsServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	return a.persistAPIServer.SubscribeJobInfos(request, &watchJobsServer{server})
+}
+
+// watchJobsServer relays the persist.JobInfos SubscribeJobInfos sends to a
+// WatchJobs client as pps.JobInfos.
+type watchJobsServer struct {
+	pps.JobAPI_WatchJobsServer
+}
+
+func (s *watchJobsServer) Send(persistJobInfo *persist.JobInfo) error {
+	jobInfo, err := newJobInfo(persistJobInfo)
+	if err != nil {
+		return err
+	}
+	return s.JobAPI_WatchJobsServer.Send(jobInfo)
+}
+
+func (a *apiServer) ListRun(ctx context.Context, request *pps.ListRunRequest) (response *pps.RunInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	persistJobInfos, err := a.persistAPIServer.ListJobInfos(ctx, &pps.ListJobRequest{})
+	if err != nil {
+		return nil, err
+	}
+	runInfos := make(map[string]*pps.RunInfo)
+	for _, persistJobInfo := range persistJobInfos.JobInfo {
+		if persistJobInfo.RunId == "" {
+			// jobs created before runs existed
+			continue
+		}
 		jobInfo, err := newJobInfo(persistJobInfo)
 		if err != nil {
 			return nil, err
 		}
+		runInfo, ok := runInfos[persistJobInfo.RunId]
+		if !ok {
+			runInfo = &pps.RunInfo{RunId: persistJobInfo.RunId}
+			runInfos[persistJobInfo.RunId] = runInfo
+		}
+		runInfo.JobInfo = append(runInfo.JobInfo, jobInfo)
+	}
+	var result []*pps.RunInfo
+	for _, runInfo := range runInfos {
+		sortJobInfosByCreated(runInfo.JobInfo)
+		result = append(result, runInfo)
+	}
+	sortRunInfosByCreated(result)
+	return &pps.RunInfos{
+		RunInfo: result,
+	}, nil
+}
+
+func (a *apiServer) ListQueue(ctx context.Context, request *pps.ListQueueRequest) (response *pps.JobInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.queueLock.Lock()
+	defer a.queueLock.Unlock()
+	if request.Unscheduled {
+		var unscheduled []*queuedJob
+		for _, queuedJob := range a.unscheduled {
+			unscheduled = append(unscheduled, queuedJob)
+		}
+		sortQueuedJobs(unscheduled)
+		var jobInfos []*pps.JobInfo
+		for _, queuedJob := range unscheduled {
+			jobInfo, err := newJobInfo(queuedJob.jobInfo)
+			if err != nil {
+				return nil, err
+			}
+			jobInfo.State = pps.JobState_JOB_STATE_FAILURE
+			jobInfo.Reason = queuedJob.err.Error()
+			jobInfos = append(jobInfos, jobInfo)
+		}
+		return &pps.JobInfos{
+			JobInfo: jobInfos,
+		}, nil
+	}
+	jobInfos := make([]*pps.JobInfo, len(a.queue))
+	for i, queuedJob := range a.queue {
+		jobInfo, err := newJobInfo(queuedJob.jobInfo)
+		if err != nil {
+			return nil, err
+		}
+		jobInfo.Reason = a.blockedReason(queuedJob)
 		jobInfos[i] = jobInfo
 	}
 	return &pps.JobInfos{
@@ -132,8 +379,277 @@ func (a *apiServer) ListJob(ctx context.Context, request *pps.ListJobRequest) (r
 	}, nil
 }
 
+// RequeueJob queues the job under a new id rather than flipping the failed
+// job's persisted state, which watchers have already seen as terminal.
+func (a *apiServer) RequeueJob(ctx context.Context, request *pps.RequeueJobRequest) (response *pps.Job, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	defer func() {
+		a.auditRecorder.Record(ctx, "pachyderm.pps.JobAPI.RequeueJob", "", request, retErr)
+	}()
+	if request.Job == nil {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: request.Job cannot be nil")
+	}
+	a.queueLock.Lock()
+	unscheduled, ok := a.unscheduled[request.Job.Id]
+	delete(a.unscheduled, request.Job.Id)
+	a.queueLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: job %s didn't fail to be scheduled", request.Job.Id)
+	}
+	// the job stays unscheduled until its replacement has been created, so
+	// that it can be requeued again if anything fails
+	defer func() {
+		if retErr != nil {
+			a.queueLock.Lock()
+			a.unscheduled[request.Job.Id] = unscheduled
+			a.queueLock.Unlock()
+		}
+	}()
+	// runJob may have created the kubernetes job before it failed
+	if err := a.deleteKubeJob(request.Job.Id); err != nil {
+		return nil, err
+	}
+	jobInfo := unscheduled.jobInfo
+	persistJobInfo := &persist.JobInfo{
+		Transform:      jobInfo.Transform,
+		PipelineName:   jobInfo.PipelineName,
+		Shards:         jobInfo.Shards,
+		Inputs:         jobInfo.Inputs,
+		ParentJob:      jobInfo.ParentJob,
+		State:          pps.JobState_JOB_STATE_QUEUED,
+		Namespace:      jobInfo.Namespace,
+		Priority:       jobInfo.Priority,
+		Preemptible:    jobInfo.Preemptible,
+		RunId:          jobInfo.RunId,
+		ExternalInputs: jobInfo.ExternalInputs,
+	}
+	if _, err := a.persistAPIServer.CreateJobInfo(ctx, persistJobInfo); err != nil {
+		return nil, err
+	}
+	a.queueLock.Lock()
+	a.queue = append(a.queue, &queuedJob{
+		jobInfo:           persistJobInfo,
+		maxConcurrentJobs: unscheduled.maxConcurrentJobs,
+	})
+	a.queueLock.Unlock()
+	a.schedule(ctx)
+	return &pps.Job{
+		Id: persistJobInfo.JobId,
+	}, nil
+}
+
+func (a *apiServer) InspectLineage(ctx context.Context, request *pps.InspectLineageRequest) (response *pps.Lineage, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.Commit == nil || request.Commit.Repo == nil {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: request.Commit cannot be nil")
+	}
+	persistJobInfos, err := a.persistAPIServer.ListJobInfos(ctx, &pps.ListJobRequest{})
+	if err != nil {
+		return nil, err
+	}
+	// producers maps each output commit to the job which wrote it, consumers
+	// maps each commit to the jobs which read it
+	producers := make(map[string]*persist.JobInfo)
+	consumers := make(map[string][]*persist.JobInfo)
+	for _, persistJobInfo := range persistJobInfos.JobInfo {
+		if persistJobInfo.OutputCommit != nil {
+			producers[commitKey(persistJobInfo.OutputCommit)] = persistJobInfo
+		}
+		for _, input := range persistJobInfo.Inputs {
+			consumers[commitKey(input.Commit)] = append(consumers[commitKey(input.Commit)], persistJobInfo)
+		}
+	}
+	var nodes []*pps.LineageNode
+	seen := make(map[string]bool)
+	commits := []*pfs.Commit{request.Commit}
+	for len(commits) > 0 {
+		commit := commits[0]
+		commits = commits[1:]
+		if seen[commitKey(commit)] {
+			continue
+		}
+		seen[commitKey(commit)] = true
+		node := &pps.LineageNode{Commit: commit}
+		if producer, ok := producers[commitKey(commit)]; ok {
+			jobInfo, err := newJobInfo(producer)
+			if err != nil {
+				return nil, err
+			}
+			node.JobInfo = jobInfo
+			for _, input := range producer.Inputs {
+				node.Inputs = append(node.Inputs, input.Commit)
+			}
+		}
+		for _, consumer := range consumers[commitKey(commit)] {
+			if consumer.OutputCommit != nil {
+				node.Outputs = append(node.Outputs, consumer.OutputCommit)
+			}
+		}
+		nodes = append(nodes, node)
+		if request.Downstream {
+			commits = append(commits, node.Outputs...)
+		} else {
+			commits = append(commits, node.Inputs...)
+		}
+	}
+	return &pps.Lineage{
+		Node: nodes,
+	}, nil
+}
+
+func (a *apiServer) FlushCommit(ctx context.Context, request *pps.FlushCommitRequest) (response *pps.FlushCommitResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	timeout := defaultFlushCommitTimeout
+	if request.Timeout != nil && prototime.DurationFromProto(request.Timeout) > 0 {
+		timeout = prototime.DurationFromProto(request.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	persistPipelineInfos, err := a.persistAPIServer.ListPipelineInfos(ctx, google_protobuf.EmptyInstance)
+	if err != nil {
+		return nil, err
+	}
+	// repoToPipelines maps each repo to the pipelines which take it as input
+	repoToPipelines := make(map[string][]*persist.PipelineInfo)
+	for _, persistPipelineInfo := range persistPipelineInfos.PipelineInfo {
+		for _, input := range persistPipelineInfo.Inputs {
+			repoToPipelines[input.Repo.Name] = append(repoToPipelines[input.Repo.Name], persistPipelineInfo)
+		}
+	}
+	var outputCommits []*pfs.Commit
+	seen := make(map[string]bool)
+	commits := request.Commit
+	for len(commits) > 0 {
+		commit := commits[0]
+		commits = commits[1:]
+		if seen[commitKey(commit)] {
+			continue
+		}
+		seen[commitKey(commit)] = true
+		for _, persistPipelineInfo := range repoToPipelines[commit.Repo.Name] {
+			jobInfo, err := a.waitForPipelineJob(ctx, persistPipelineInfo.PipelineName, commit)
+			if err != nil {
+				return nil, err
+			}
+			if jobInfo.State != pps.JobState_JOB_STATE_SUCCESS {
+				return nil, fmt.Errorf("pachyderm.pps.jobserver: job %s for commit %s/%s failed", jobInfo.JobId, commit.Repo.Name, commit.Id)
+			}
+			outputCommits = append(outputCommits, jobInfo.OutputCommit)
+			commits = append(commits, jobInfo.OutputCommit)
+		}
+	}
+	return &pps.FlushCommitResponse{
+		OutputCommit: outputCommits,
+	}, nil
+}
+
+// waitForPipelineJob waits for pipelineName to create a job which reads
+// commit and then for that job to finish, it returns ctx's error once ctx is
+// done. The job is polled rather than inspected with BlockState because a
+// blocking InspectJob doesn't return when ctx is cancelled.
+func (a *apiServer) waitForPipelineJob(ctx context.Context, pipelineName string, commit *pfs.Commit) (*persist.JobInfo, error) {
+	var jobID string
+	for {
+		if jobID == "" {
+			persistJobInfos, err := a.persistAPIServer.ListJobInfos(ctx, &pps.ListJobRequest{Pipeline: &pps.Pipeline{Name: pipelineName}})
+			if err != nil {
+				return nil, err
+			}
+		Jobs:
+			for _, persistJobInfo := range persistJobInfos.JobInfo {
+				for _, input := range persistJobInfo.Inputs {
+					if commitKey(input.Commit) == commitKey(commit) {
+						jobID = persistJobInfo.JobId
+						break Jobs
+					}
+				}
+			}
+		}
+		if jobID != "" {
+			persistJobInfo, err := a.persistAPIServer.InspectJob(ctx, &pps.InspectJobRequest{Job: &pps.Job{Id: jobID}})
+			if err != nil {
+				return nil, err
+			}
+			finished := persistJobInfo.State != pps.JobState_JOB_STATE_QUEUED &&
+				persistJobInfo.State != pps.JobState_JOB_STATE_RUNNING
+			if finished && (persistJobInfo.OutputCommit != nil || persistJobInfo.State != pps.JobState_JOB_STATE_SUCCESS) {
+				return persistJobInfo, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(flushCommitPollInterval):
+		}
+	}
+}
+
+func (a *apiServer) DebugJob(ctx context.Context, request *pps.DebugJobRequest) (response *pps.DebugJobResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if a.kubeClient == nil {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: no job backend")
+	}
+	jobInfo, err := a.persistAPIServer.InspectJob(ctx, &pps.InspectJobRequest{Job: request.Job})
+	if err != nil {
+		return nil, err
+	}
+	if request.Shard >= jobInfo.Shards {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: job %s has %d shards, there's no shard %d", request.Job.Id, jobInfo.Shards, request.Shard)
+	}
+	pod, err := a.kubeClient.Pods(api.NamespaceDefault).Create(debugPod(jobInfo, request.Shard))
+	if err != nil {
+		return nil, err
+	}
+	return &pps.DebugJobResponse{Pod: pod.Name}, nil
+}
+
+func (a *apiServer) InspectJobManifest(ctx context.Context, request *pps.InspectJobRequest) (response *pps.JobManifest, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, cancel := grpcutil.WithTimeout(ctx)
+	defer cancel()
+	repo := pps.JobManifestRepo()
+	if _, err := pfsutil.InspectCommit(a.pfsAPIClient, repo.Name, pps.JobManifestTag(request.Job)); err != nil {
+		return nil, fmt.Errorf("pachyderm.pps.jobserver: job %s has no manifest, it hasn't started", request.Job.Id)
+	}
+	var buffer bytes.Buffer
+	if err := pfsutil.GetFile(a.pfsAPIClient, repo.Name, pps.JobManifestTag(request.Job), pps.JobManifestPath, 0, 0, nil, &buffer); err != nil {
+		return nil, err
+	}
+	var jobManifest pps.JobManifest
+	if err := jsonpb.Unmarshal(&buffer, &jobManifest); err != nil {
+		return nil, err
+	}
+	return &jobManifest, nil
+}
+
+func (a *apiServer) SetNamespaceQuotas(value string) error {
+	namespaceQuotas := make(map[string]uint64)
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("pachyderm.pps.jobserver: malformed namespace quota %s, expected namespace=max", pair)
+		}
+		max, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("pachyderm.pps.jobserver: malformed namespace quota %s: %s", pair, err.Error())
+		}
+		namespaceQuotas[parts[0]] = max
+	}
+	a.queueLock.Lock()
+	a.namespaceQuotas = namespaceQuotas
+	a.queueLock.Unlock()
+	// raising a quota may have unblocked queued jobs
+	a.schedule(context.Background())
+	return nil
+}
+
 func (a *apiServer) StartJob(ctx context.Context, request *pps.StartJobRequest) (response *pps.StartJobResponse, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	ctx, cancel := grpcutil.WithTimeout(ctx)
+	defer cancel()
 	inspectJobRequest := &pps.InspectJobRequest{Job: request.Job}
 	jobInfo, err := a.persistAPIServer.InspectJob(ctx, inspectJobRequest)
 	if err != nil {
@@ -148,15 +664,27 @@ func (a *apiServer) StartJob(ctx context.Context, request *pps.StartJobRequest)
 		jobState = newJobState()
 		a.jobStates[request.Job.Id] = jobState
 	}
-	shard := jobState.start
-	if jobState.start < jobInfo.Shards {
-		jobState.start++
+	var shard uint64
+	setup := false
+	if request.Speculative {
+		shard, ok = jobState.speculation.next(jobState.finishedShards, request.PodName)
+	} else {
+		shard, ok = jobState.nextShard(jobInfo.Shards)
+		if ok {
+			jobState.startedShards[shard] = true
+			jobState.speculation.start(shard, request.PodName)
+			setup = !jobState.setupStarted
+			jobState.setupStarted = true
+		}
 	}
 	a.lock.Unlock()
-	if shard == jobInfo.Shards {
+	if !ok {
+		if request.Speculative {
+			return nil, fmt.Errorf("job %s has no shards waiting to be run again", request.Job.Id)
+		}
 		return nil, fmt.Errorf("job %s already has %d shards", request.Job.Id, jobInfo.Shards)
 	}
-	if shard == 0 {
+	if setup {
 		var parentCommit *pfs.Commit
 		if jobInfo.ParentJob == nil {
 			var repo *pfs.Repo
@@ -183,6 +711,9 @@ func (a *apiServer) StartJob(ctx context.Context, request *pps.StartJobRequest)
 		if err != nil {
 			return nil, err
 		}
+		if _, err := a.pfsAPIClient.CreateRepo(ctx, &pfs.CreateRepoRequest{Repo: pps.JobScratchRepo(request.Job)}); err != nil {
+			return nil, err
+		}
 		if _, err := a.persistAPIServer.CreateJobOutput(
 			ctx,
 			&persist.JobOutput{
@@ -191,6 +722,9 @@ func (a *apiServer) StartJob(ctx context.Context, request *pps.StartJobRequest)
 			}); err != nil {
 			return nil, err
 		}
+		if err := a.writeJobManifest(ctx, jobInfo); err != nil {
+			return nil, err
+		}
 		jobState.outputCommit = commit
 		close(jobState.commitReady)
 	}
@@ -198,34 +732,46 @@ func (a *apiServer) StartJob(ctx context.Context, request *pps.StartJobRequest)
 	if jobState.outputCommit == nil {
 		return nil, fmt.Errorf("jobState.outputCommit should not be nil (this is likely a bug)")
 	}
-	var commitMounts []*fuse.CommitMount
-	for _, jobInput := range jobInfo.Inputs {
-		commitMount := &fuse.CommitMount{
-			Commit: jobInput.Commit,
-			Shard: &pfs.Shard{
-				FileModulus:  1,
-				BlockModulus: 1,
-			},
-		}
-		if jobInput.Reduce {
-			commitMount.Shard.FileNumber = shard
-			commitMount.Shard.FileModulus = jobInfo.Shards
-		} else {
-			commitMount.Shard.BlockNumber = shard
-			commitMount.Shard.BlockModulus = jobInfo.Shards
+	// Each shard writes to its own scratch commit so that parallel shards
+	// can't trample each other, the scratch commits are merged into the
+	// output commit once all shards have finished.
+	scratchCommit, err := a.pfsAPIClient.StartCommit(ctx, &pfs.StartCommitRequest{
+		Parent: &pfs.Commit{Repo: pps.JobScratchRepo(request.Job)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.lock.Lock()
+	if request.Speculative {
+		jobState.speculation.commits[shard] = scratchCommit
+	} else {
+		jobState.scratchCommits[shard] = scratchCommit
+	}
+	a.lock.Unlock()
+	commitMounts := ppsutil.InputCommitMounts(jobInfo.Inputs, jobInfo.Shards, shard)
+	// objects in external inputs can change without their names changing,
+	// so the output of jobs which read them isn't cached, a speculative run
+	// only happens if the first run wasn't served from the cache
+	var cached bool
+	if len(jobInfo.ExternalInputs) == 0 && !request.Speculative {
+		cached, err = a.fillFromDatumCache(ctx, jobState, shard, jobInfo.Transform, commitMounts, scratchCommit)
+		if err != nil {
+			return nil, err
 		}
-		commitMounts = append(commitMounts, commitMount)
 	}
 	outputCommitMount := &fuse.CommitMount{
-		Commit: jobState.outputCommit,
+		Commit: scratchCommit,
 		Alias:  "out",
 	}
 	commitMounts = append(commitMounts, outputCommitMount)
 	return &pps.StartJobResponse{
-		Transform:    jobInfo.Transform,
-		CommitMounts: commitMounts,
-		OutputCommit: jobState.outputCommit,
-		Index:        shard,
+		Transform:      jobInfo.Transform,
+		CommitMounts:   commitMounts,
+		OutputCommit:   jobState.outputCommit,
+		Index:          shard,
+		Cached:         cached,
+		ExternalInputs: jobInfo.ExternalInputs,
+		Shards:         jobInfo.Shards,
 	}, nil
 }
 
@@ -237,6 +783,12 @@ func (a *apiServer) FinishJob(ctx context.Context, request *pps.FinishJobRequest
 		return nil, err
 	}
 	var finished bool
+	var lost bool
+	var loserPod string
+	var stats *pps.JobStats
+	var scratchCommit *pfs.Commit
+	scratchCommits := make(map[uint64]*pfs.Commit)
+	datumHashes := make(map[uint64]string)
 	persistJobState := pps.JobState_JOB_STATE_FAILURE
 	if err := func() error {
 		a.lock.Lock()
@@ -245,93 +797,1235 @@ func (a *apiServer) FinishJob(ctx context.Context, request *pps.FinishJobRequest
 		if !ok {
 			return fmt.Errorf("job %s was never started", request.Job.Id)
 		}
+		if request.Speculative {
+			scratchCommit, ok = jobState.speculation.commits[request.Index]
+		} else {
+			scratchCommit, ok = jobState.scratchCommits[request.Index]
+		}
+		if !ok {
+			return fmt.Errorf("shard %d of job %s was never started", request.Index, request.Job.Id)
+		}
+		speculated := jobState.speculation.speculated[request.Index]
+		if jobState.finishedShards[request.Index] || jobState.finishingShards[request.Index] {
+			if speculated {
+				// the shard's other run finished first
+				lost = true
+				return nil
+			}
+			return fmt.Errorf("shard %d of job %s already finished", request.Index, request.Job.Id)
+		}
+		if speculated {
+			otherRunning := request.Speculative || jobState.speculation.running(request.Index)
+			if !request.Success && otherRunning && !jobState.speculation.failed[request.Index] {
+				// the shard's other run can still succeed
+				jobState.speculation.failed[request.Index] = true
+				lost = true
+				return nil
+			}
+			if request.Speculative {
+				loserPod = jobState.speculation.pods[request.Index]
+			} else {
+				loserPod = jobState.speculation.speculativePods[request.Index]
+			}
+		}
+		// the shard isn't finished until its scratch commit is, otherwise
+		// the last shard could merge commits which are still being written
+		jobState.finishingShards[request.Index] = true
+		return nil
+	}(); err != nil {
+		return nil, err
+	}
+	if lost {
+		// the run's output is dropped, its scratch commit is gone if the job
+		// has already finished
+		if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{
+			Commit: scratchCommit,
+		}); err != nil {
+			protolog.Printf("error finishing the scratch commit of a lost run of shard %d of job %s: %s", request.Index, request.Job.Id, err.Error())
+		}
+		return google_protobuf.EmptyInstance, nil
+	}
+	if loserPod != "" {
+		a.killRun(loserPod)
+	}
+	if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{
+		Commit: scratchCommit,
+	}); err != nil {
+		a.lock.Lock()
+		if jobState, ok := a.jobStates[request.Job.Id]; ok {
+			// the shard can report back again
+			delete(jobState.finishingShards, request.Index)
+		}
+		a.lock.Unlock()
+		return nil, err
+	}
+	if err := func() error {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		jobState, ok := a.jobStates[request.Job.Id]
+		if !ok || jobState.finishedShards[request.Index] {
+			// the job timed out while the scratch commit was finished
+			lost = true
+			return nil
+		}
+		delete(jobState.finishingShards, request.Index)
+		if request.Speculative {
+			jobState.scratchCommits[request.Index] = scratchCommit
+		}
+		jobState.speculation.finish(request.Index, jobState.cachedShards[request.Index])
 		jobState.success = jobState.success && request.Success
 		if jobState.success {
 			persistJobState = pps.JobState_JOB_STATE_SUCCESS
 		}
-		jobState.finish++
-		finished = (jobState.finish == jobInfo.Shards)
+		jobState.finishedShards[request.Index] = true
+		finished = (uint64(len(jobState.finishedShards)) == jobInfo.Shards)
+		if finished {
+			jobState.speculation.stop()
+		} else {
+			a.scheduleSpeculation(jobInfo, jobState)
+		}
+		addJobStats(jobState.stats, request.Stats)
+		statsCopy := *jobState.stats
+		stats = &statsCopy
+		for shard, commit := range jobState.scratchCommits {
+			scratchCommits[shard] = commit
+		}
+		for shard, datumHash := range jobState.datumHashes {
+			if !jobState.cachedShards[shard] {
+				datumHashes[shard] = datumHash
+			}
+		}
 		return nil
 	}(); err != nil {
 		return nil, err
 	}
+	if lost {
+		return google_protobuf.EmptyInstance, nil
+	}
+	if !finished {
+		a.autoscale(jobInfo)
+	}
 	if finished {
+		defer func() {
+			a.releaseJob(jobInfo.JobId)
+			// ctx may have been cancelled by now, the jobs this starts
+			// outlive the rpc anyway
+			a.schedule(context.Background())
+		}()
 		if jobInfo.OutputCommit == nil {
 			return nil, fmt.Errorf("jobInfo.OutputCommit should not be nil (this is likely a bug)")
 		}
-		if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{
-			Commit: jobInfo.OutputCommit,
-		}); err != nil {
-			return nil, err
-		}
-		if _, err := a.persistAPIServer.CreateJobState(ctx, &persist.JobState{
-			JobId: request.Job.Id,
-			State: persistJobState,
-		}); err != nil {
+		if err := a.finishJob(ctx, jobInfo, persistJobState, scratchCommits, datumHashes, stats); err != nil {
 			return nil, err
 		}
 	}
 	return google_protobuf.EmptyInstance, nil
 }
 
-func newJobInfo(persistJobInfo *persist.JobInfo) (*pps.JobInfo, error) {
-	job := &pps.Job{Id: persistJobInfo.JobId}
-	return &pps.JobInfo{
-		Job:          job,
-		Transform:    persistJobInfo.Transform,
-		Pipeline:     &pps.Pipeline{Name: persistJobInfo.PipelineName},
-		Shards:       persistJobInfo.Shards,
-		Inputs:       persistJobInfo.Inputs,
-		ParentJob:    persistJobInfo.ParentJob,
-		CreatedAt:    persistJobInfo.CreatedAt,
-		OutputCommit: persistJobInfo.OutputCommit,
-		State:        persistJobInfo.State,
-	}, nil
+// finishJob merges the output of a job whose shards have all finished into
+// its output commit, or keeps it if the job failed, and records the job's
+// final state.
+func (a *apiServer) finishJob(
+	ctx context.Context,
+	jobInfo *persist.JobInfo,
+	persistJobState pps.JobState,
+	scratchCommits map[uint64]*pfs.Commit,
+	datumHashes map[uint64]string,
+	stats *pps.JobStats,
+) error {
+	job := &pps.Job{Id: jobInfo.JobId}
+	if persistJobState == pps.JobState_JOB_STATE_SUCCESS {
+		shardToPaths, err := a.mergeScratchCommits(ctx, jobInfo.OutputCommit, scratchCommits)
+		if err != nil {
+			protolog.Printf("error merging output of job %s: %s", job.Id, err.Error())
+			persistJobState = pps.JobState_JOB_STATE_FAILURE
+		} else if err := a.createDatumCaches(ctx, jobInfo.OutputCommit, datumHashes, shardToPaths); err != nil {
+			return err
+		}
+	}
+	if persistJobState == pps.JobState_JOB_STATE_FAILURE {
+		// failing to keep the output only costs the chance to debug it
+		if err := a.keepFailedOutput(ctx, job, scratchCommits); err != nil {
+			protolog.Printf("error keeping output of failed job %s: %s", job.Id, err.Error())
+		}
+	}
+	if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{
+		Commit: jobInfo.OutputCommit,
+	}); err != nil {
+		return err
+	}
+	// everything in the scratch repo has been merged or kept by now, failing
+	// to delete it only costs space until it's deleted by hand
+	if _, err := a.pfsAPIClient.DeleteRepo(ctx, &pfs.DeleteRepoRequest{
+		Repo:  pps.JobScratchRepo(job),
+		Purge: true,
+	}); err != nil {
+		protolog.Printf("error deleting the scratch repo of job %s: %s", job.Id, err.Error())
+	}
+	if len(jobInfo.Transform.Sidecars) > 0 {
+		// sidecars don't exit by themselves, so the job's pods never
+		// complete, they're deleted to stop them
+		if err := a.deleteKubeJob(job.Id); err != nil {
+			protolog.Printf("error stopping the sidecars of job %s: %s", job.Id, err.Error())
+		}
+	}
+	if _, err := a.persistAPIServer.CreateJobState(ctx, &persist.JobState{
+		JobId: job.Id,
+		State: persistJobState,
+		Stats: stats,
+	}); err != nil {
+		return err
+	}
+	a.eventRecorder.JobFinished(job, jobInfo.PipelineName, persistJobState, jobInfo.OutputCommit, time.Now())
+	return nil
 }
 
-func job(jobInfo *persist.JobInfo) *extensions.Job {
-	app := jobInfo.JobId
-	shards := int(jobInfo.Shards)
-	image := "pachyderm/job-shim"
-	if jobInfo.Transform.Image != "" {
-		image = jobInfo.Transform.Image
+// schedule starts every queued job whose pipeline and namespace are under
+// quota, jobs are considered from highest to lowest priority and then in the
+// order they were created. A job that's over quota preempts a lower priority,
+// preemptible job if stopping it would bring the job under quota.
+func (a *apiServer) schedule(ctx context.Context) {
+	var runnable []*queuedJob
+	var preempted []*persist.JobInfo
+	a.queueLock.Lock()
+	sortQueuedJobs(a.queue)
+	var queue []*queuedJob
+	for _, queuedJob := range a.queue {
+		if a.blocked(queuedJob) {
+			if victim := a.preemptionVictim(queuedJob); victim != nil {
+				a.releaseJobLocked(victim.jobInfo.JobId)
+				queue = append(queue, victim)
+				preempted = append(preempted, victim.jobInfo)
+			}
+		}
+		if a.blocked(queuedJob) {
+			queue = append(queue, queuedJob)
+			continue
+		}
+		a.countRunningLocked(queuedJob)
+		runnable = append(runnable, queuedJob)
 	}
-	return &extensions.Job{
-		TypeMeta: unversioned.TypeMeta{
-			Kind:       "Job",
-			APIVersion: "v1",
-		},
-		ObjectMeta: api.ObjectMeta{
-			Name:   jobInfo.JobId,
-			Labels: labels(app),
-		},
-		Spec: extensions.JobSpec{
-			Selector: &extensions.PodSelector{
-				MatchLabels: labels(app),
-			},
-			Parallelism: &shards,
-			Completions: &shards,
-			Template: api.PodTemplateSpec{
-				ObjectMeta: api.ObjectMeta{
-					Name:   jobInfo.JobId,
-					Labels: labels(app),
+	sortQueuedJobs(queue)
+	a.queue = queue
+	var scaled []*persist.JobInfo
+	for _, running := range a.running {
+		if a.queueDepthLocked(running.jobInfo.PipelineName) > 0 {
+			scaled = append(scaled, running.jobInfo)
+		}
+	}
+	a.queueLock.Unlock()
+	for _, jobInfo := range preempted {
+		if err := a.preemptJob(ctx, jobInfo); err != nil {
+			protolog.Printf("error preempting job %s: %s", jobInfo.JobId, err.Error())
+		}
+	}
+	for _, jobInfo := range scaled {
+		a.autoscale(jobInfo)
+	}
+	for _, queuedJob := range runnable {
+		jobInfo := queuedJob.jobInfo
+		if err := a.runJob(ctx, jobInfo); err != nil {
+			protolog.Printf("error starting job %s: %s", jobInfo.JobId, err.Error())
+			a.queueLock.Lock()
+			a.releaseJobLocked(jobInfo.JobId)
+			queuedJob.err = err
+			a.unscheduled[jobInfo.JobId] = queuedJob
+			a.queueLock.Unlock()
+			if _, err := a.persistAPIServer.CreateJobState(ctx, &persist.JobState{
+				JobId: jobInfo.JobId,
+				State: pps.JobState_JOB_STATE_FAILURE,
+			}); err != nil {
+				protolog.Printf("error failing job %s: %s", jobInfo.JobId, err.Error())
+			} else {
+				a.eventRecorder.JobFinished(&pps.Job{Id: jobInfo.JobId}, jobInfo.PipelineName, pps.JobState_JOB_STATE_FAILURE, nil, time.Now())
+			}
+		}
+	}
+}
+
+// countRunningLocked counts queuedJob against its pipeline's and namespace's
+// quotas, queueLock must be held.
+func (a *apiServer) countRunningLocked(queuedJob *queuedJob) {
+	a.pipelineRunning[queuedJob.jobInfo.PipelineName]++
+	a.namespaceRunning[queuedJob.jobInfo.Namespace]++
+	a.running[queuedJob.jobInfo.JobId] = queuedJob
+	queuedJob.started = time.Now()
+}
+
+// Start restores the queue from the jobs' persisted states, jobs which were
+// queued when ppsd stopped are queued again and the ones which were running
+// count against their quotas, then it starts sweeping the running jobs.
+func (a *apiServer) Start() error {
+	ctx := context.Background()
+	jobInfos, err := a.persistAPIServer.ListJobInfos(ctx, &pps.ListJobRequest{})
+	if err != nil {
+		return err
+	}
+	var queued []*queuedJob
+	var running []*queuedJob
+	maxConcurrentJobs := make(map[string]uint64)
+	for _, jobInfo := range jobInfos.JobInfo {
+		if jobInfo.State != pps.JobState_JOB_STATE_QUEUED && jobInfo.State != pps.JobState_JOB_STATE_RUNNING {
+			continue
+		}
+		if _, ok := maxConcurrentJobs[jobInfo.PipelineName]; !ok && jobInfo.PipelineName != "" {
+			pipelineInfo, err := a.persistAPIServer.GetPipelineInfo(ctx, &pps.Pipeline{Name: jobInfo.PipelineName})
+			if err != nil {
+				// the pipeline has been deleted, its jobs still run
+				protolog.Printf("error getting pipeline %s of job %s: %s", jobInfo.PipelineName, jobInfo.JobId, err.Error())
+			} else {
+				maxConcurrentJobs[jobInfo.PipelineName] = pipelineInfo.MaxConcurrentJobs
+			}
+		}
+		queuedJob := &queuedJob{
+			jobInfo:           jobInfo,
+			maxConcurrentJobs: maxConcurrentJobs[jobInfo.PipelineName],
+		}
+		if jobInfo.State == pps.JobState_JOB_STATE_QUEUED {
+			queued = append(queued, queuedJob)
+		} else {
+			running = append(running, queuedJob)
+		}
+	}
+	a.queueLock.Lock()
+	a.queue = append(a.queue, queued...)
+	for _, queuedJob := range running {
+		a.countRunningLocked(queuedJob)
+	}
+	a.queueLock.Unlock()
+	a.schedule(ctx)
+	go a.sweepJobs()
+	return nil
+}
+
+// sweepJobs releases the quota of running jobs which will never call
+// FinishJob, because their kubernetes job was deleted or has completed
+// without them finishing, ie because ppsd restarted while they ran.
+func (a *apiServer) sweepJobs() {
+	ctx := context.Background()
+	for range time.Tick(jobSweepInterval) {
+		var running []*queuedJob
+		a.queueLock.Lock()
+		for _, queuedJob := range a.running {
+			// a job's kubernetes job is created after it's counted
+			if time.Since(queuedJob.started) > jobSweepInterval {
+				running = append(running, queuedJob)
+			}
+		}
+		a.queueLock.Unlock()
+		released := false
+		for _, queuedJob := range running {
+			jobID := queuedJob.jobInfo.JobId
+			kubeJob, err := a.kubeClient.Jobs(api.NamespaceDefault).Get(jobID)
+			if err != nil && !kubeerrors.IsNotFound(err) {
+				protolog.Printf("error sweeping job %s: %s", jobID, err.Error())
+				continue
+			}
+			if err == nil && !kubeJobComplete(kubeJob) {
+				continue
+			}
+			protolog.Printf("releasing job %s, its kubernetes job was deleted or completed without it finishing", jobID)
+			a.releaseJob(jobID)
+			released = true
+		}
+		if released {
+			a.schedule(ctx)
+		}
+	}
+}
+
+func kubeJobComplete(kubeJob *extensions.Job) bool {
+	for _, condition := range kubeJob.Status.Conditions {
+		if condition.Type == extensions.JobComplete && condition.Status == api.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// blocked returns true if starting queuedJob would put its pipeline or
+// namespace over quota, queueLock must be held.
+func (a *apiServer) blocked(queuedJob *queuedJob) bool {
+	return a.blockedReason(queuedJob) != ""
+}
+
+// blockedReason returns which quota starting queuedJob would put over, it
+// returns "" if none would. queueLock must be held.
+func (a *apiServer) blockedReason(queuedJob *queuedJob) string {
+	if queuedJob.jobInfo.PipelineName != "" && queuedJob.maxConcurrentJobs != 0 &&
+		a.pipelineRunning[queuedJob.jobInfo.PipelineName] >= queuedJob.maxConcurrentJobs {
+		return fmt.Sprintf("pipeline %s is running its max of %d jobs", queuedJob.jobInfo.PipelineName, queuedJob.maxConcurrentJobs)
+	}
+	if max, ok := a.namespaceQuotas[queuedJob.jobInfo.Namespace]; ok && a.namespaceRunning[queuedJob.jobInfo.Namespace] >= max {
+		return fmt.Sprintf("namespace %s is running its max of %d jobs", queuedJob.jobInfo.Namespace, max)
+	}
+	return ""
+}
+
+// preemptionVictim returns the running job to stop so that queued can
+// start, it returns nil if there isn't one. queueLock must be held.
+func (a *apiServer) preemptionVictim(queued *queuedJob) *queuedJob {
+	pipelineName := queued.jobInfo.PipelineName
+	namespace := queued.jobInfo.Namespace
+	pipelineFull := pipelineName != "" && queued.maxConcurrentJobs != 0 &&
+		a.pipelineRunning[pipelineName] >= queued.maxConcurrentJobs
+	max, ok := a.namespaceQuotas[namespace]
+	namespaceFull := ok && a.namespaceRunning[namespace] >= max
+	// stopping a single job won't help if a quota was lowered below the
+	// number of jobs already running
+	if pipelineFull && a.pipelineRunning[pipelineName] > queued.maxConcurrentJobs {
+		return nil
+	}
+	if namespaceFull && a.namespaceRunning[namespace] > max {
+		return nil
+	}
+	var victim *queuedJob
+	for _, running := range a.running {
+		if !running.jobInfo.Preemptible || running.jobInfo.Priority >= queued.jobInfo.Priority {
+			continue
+		}
+		if pipelineFull && running.jobInfo.PipelineName != pipelineName {
+			continue
+		}
+		if namespaceFull && running.jobInfo.Namespace != namespace {
+			continue
+		}
+		// prefer the lowest priority and then the newest job, which has
+		// likely done the least work
+		if victim == nil ||
+			running.jobInfo.Priority < victim.jobInfo.Priority ||
+			(running.jobInfo.Priority == victim.jobInfo.Priority &&
+				prototime.TimestampToTime(running.jobInfo.CreatedAt).After(prototime.TimestampToTime(victim.jobInfo.CreatedAt))) {
+			victim = running
+		}
+	}
+	return victim
+}
+
+// preemptJob stops jobInfo's pods and marks it as queued. Shards that already
+// finished keep their output in their scratch commits so only the unfinished
+// shards are rerun when the job is started again. Kubernetes deletes jobs in
+// the background so we wait for it to finish, otherwise restarting the job
+// would collide with the old kubernetes job of the same name.
+func (a *apiServer) preemptJob(ctx context.Context, jobInfo *persist.JobInfo) error {
+	if err := a.deleteKubeJob(jobInfo.JobId); err != nil {
+		return err
+	}
+	if err := a.waitForKubeJobDeleted(jobInfo.JobId); err != nil {
+		return err
+	}
+	a.lock.Lock()
+	if jobState, ok := a.jobStates[jobInfo.JobId]; ok {
+		jobState.startedShards = make(map[uint64]bool)
+		for shard := range jobState.finishedShards {
+			jobState.startedShards[shard] = true
+		}
+		jobState.speculation.reset()
+	}
+	a.lock.Unlock()
+	_, err := a.persistAPIServer.CreateJobState(ctx, &persist.JobState{
+		JobId: jobInfo.JobId,
+		State: pps.JobState_JOB_STATE_QUEUED,
+	})
+	return err
+}
+
+// deleteKubeJob deletes the kubernetes job which runs jobID and its pods,
+// which kills any shards that are running. It's fine if they're already gone.
+func (a *apiServer) deleteKubeJob(jobID string) error {
+	if err := a.kubeClient.Jobs(api.NamespaceDefault).Delete(jobID, nil); err != nil && !kubeerrors.IsNotFound(err) {
+		return err
+	}
+	pods, err := a.kubeClient.Pods(api.NamespaceDefault).List(kubelabels.SelectorFromSet(labels(jobID)), fields.Everything())
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if err := a.kubeClient.Pods(api.NamespaceDefault).Delete(pod.Name, nil); err != nil && !kubeerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return a.deleteSpeculativePods(jobID)
+}
+
+// waitForKubeJobDeleted waits for the kubernetes job which runs jobID to be
+// gone, it fails after kubeJobDeleteTimeout.
+func (a *apiServer) waitForKubeJobDeleted(jobID string) error {
+	deadline := time.Now().Add(kubeJobDeleteTimeout)
+	for {
+		_, err := a.kubeClient.Jobs(api.NamespaceDefault).Get(jobID)
+		if kubeerrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("pachyderm.pps.jobserver: kubernetes job %s still exists %s after it was deleted", jobID, kubeJobDeleteTimeout)
+		}
+		time.Sleep(kubeJobDeletePollInterval)
+	}
+}
+
+func (a *apiServer) runJob(ctx context.Context, jobInfo *persist.JobInfo) error {
+	shards := jobInfo.Shards
+	a.lock.Lock()
+	if jobState, ok := a.jobStates[jobInfo.JobId]; ok {
+		shards -= uint64(len(jobState.finishedShards))
+	}
+	a.lock.Unlock()
+	parallelism := a.scaler.Parallelism(jobInfo, shards, a.queueDepth(jobInfo.PipelineName))
+	if _, err := a.kubeClient.Jobs(api.NamespaceDefault).Create(job(jobInfo, shards, parallelism)); err != nil {
+		if !kubeerrors.IsAlreadyExists(err) {
+			return err
+		}
+		// the job was preempted and its old kubernetes job is still
+		// being deleted
+		if err := a.waitForKubeJobDeleted(jobInfo.JobId); err != nil {
+			return err
+		}
+		if _, err := a.kubeClient.Jobs(api.NamespaceDefault).Create(job(jobInfo, shards, parallelism)); err != nil {
+			return err
+		}
+	}
+	if _, err := a.persistAPIServer.CreateJobState(ctx, &persist.JobState{
+		JobId: jobInfo.JobId,
+		State: pps.JobState_JOB_STATE_RUNNING,
+	}); err != nil {
+		return err
+	}
+	if jobInfo.Transform.JobTimeout != nil {
+		a.startJobTimer(jobInfo)
+	}
+	return nil
+}
+
+// startJobTimer times out jobInfo if it's still running once its job_timeout
+// has passed. Jobs which were running when ppsd restarted have no timer.
+func (a *apiServer) startJobTimer(jobInfo *persist.JobInfo) {
+	timeout := prototime.DurationFromProto(jobInfo.Transform.JobTimeout)
+	a.queueLock.Lock()
+	defer a.queueLock.Unlock()
+	if _, ok := a.running[jobInfo.JobId]; !ok {
+		// the job has already finished
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(timeout, func() {
+		a.queueLock.Lock()
+		// the job may have finished, or been preempted and restarted with a
+		// new timer, while this one was firing
+		current := a.jobTimers[jobInfo.JobId] == timer
+		if current {
+			a.releaseJobLocked(jobInfo.JobId)
+		}
+		a.queueLock.Unlock()
+		if current {
+			ctx := context.Background()
+			if err := a.timeOutJob(ctx, jobInfo, timeout); err != nil {
+				protolog.Printf("error timing out job %s: %s", jobInfo.JobId, err.Error())
+			}
+			a.schedule(ctx)
+		}
+	})
+	a.jobTimers[jobInfo.JobId] = timer
+}
+
+// timeOutJob kills a job which has run for longer than timeout and fails it,
+// the shards which had already finished keep their output for debugging
+// like those of any other failed job.
+func (a *apiServer) timeOutJob(ctx context.Context, jobInfo *persist.JobInfo, timeout time.Duration) error {
+	protolog.Printf("job %s timed out after %s", jobInfo.JobId, timeout)
+	if err := a.deleteKubeJob(jobInfo.JobId); err != nil {
+		return err
+	}
+	// the job's output commit is persisted by the first shard to start, after
+	// jobInfo was read
+	jobInfo, err := a.persistAPIServer.InspectJob(ctx, &pps.InspectJobRequest{Job: &pps.Job{Id: jobInfo.JobId}})
+	if err != nil {
+		return err
+	}
+	var finished bool
+	var unfinished []*pfs.Commit
+	scratchCommits := make(map[uint64]*pfs.Commit)
+	stats := &pps.JobStats{}
+	func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		jobState, ok := a.jobStates[jobInfo.JobId]
+		if !ok {
+			return
+		}
+		if uint64(len(jobState.finishedShards)) == jobInfo.Shards {
+			// the last shard finished as the job timed out
+			finished = true
+			return
+		}
+		for shard, commit := range jobState.scratchCommits {
+			if !jobState.finishedShards[shard] && !jobState.finishingShards[shard] {
+				unfinished = append(unfinished, commit)
+			}
+			scratchCommits[shard] = commit
+		}
+		// shards which report back after this are rejected as already
+		// finished
+		for shard := uint64(0); shard < jobInfo.Shards; shard++ {
+			jobState.finishedShards[shard] = true
+		}
+		jobState.speculation.reset()
+		jobState.success = false
+		statsCopy := *jobState.stats
+		stats = &statsCopy
+	}()
+	if finished {
+		return nil
+	}
+	if jobInfo.OutputCommit == nil {
+		// no shard got as far as starting the output commit
+		if _, err := a.persistAPIServer.CreateJobState(ctx, &persist.JobState{
+			JobId: jobInfo.JobId,
+			State: pps.JobState_JOB_STATE_FAILURE,
+		}); err != nil {
+			return err
+		}
+		a.eventRecorder.JobFinished(&pps.Job{Id: jobInfo.JobId}, jobInfo.PipelineName, pps.JobState_JOB_STATE_FAILURE, nil, time.Now())
+		return nil
+	}
+	for _, commit := range unfinished {
+		if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{
+			Commit: commit,
+		}); err != nil {
+			return err
+		}
+	}
+	return a.finishJob(ctx, jobInfo, pps.JobState_JOB_STATE_FAILURE, scratchCommits, nil, stats)
+}
+
+// releaseJob removes a job from the running counts of its pipeline and
+// namespace.
+func (a *apiServer) releaseJob(jobID string) {
+	a.queueLock.Lock()
+	defer a.queueLock.Unlock()
+	a.releaseJobLocked(jobID)
+}
+
+func (a *apiServer) releaseJobLocked(jobID string) {
+	running, ok := a.running[jobID]
+	if !ok {
+		// the job has already been released
+		return
+	}
+	delete(a.running, jobID)
+	if timer, ok := a.jobTimers[jobID]; ok {
+		timer.Stop()
+		delete(a.jobTimers, jobID)
+	}
+	a.pipelineRunning[running.jobInfo.PipelineName]--
+	a.namespaceRunning[running.jobInfo.Namespace]--
+}
+
+// mergeScratchCommits copies the files written to each shard's scratch commit
+// into outputCommit and returns the paths written by each shard. It returns an
+// error, and copies nothing, if more than one shard wrote to the same path.
+func (a *apiServer) mergeScratchCommits(ctx context.Context, outputCommit *pfs.Commit, scratchCommits map[uint64]*pfs.Commit) (map[uint64][]string, error) {
+	pathToShard := make(map[string]uint64)
+	pathToFileInfo := make(map[string]*pfs.FileInfo)
+	for shard, scratchCommit := range scratchCommits {
+		fileInfos, err := a.walkFiles(ctx, &pfs.File{Commit: scratchCommit}, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, fileInfo := range fileInfos {
+			if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				otherFileInfo, ok := pathToFileInfo[fileInfo.File.Path]
+				if !ok {
+					pathToFileInfo[fileInfo.File.Path] = fileInfo
+				} else if otherFileInfo.FileType != pfs.FileType_FILE_TYPE_DIR {
+					return nil, fmt.Errorf("%s is a directory in shard %d and a file in shard %d", fileInfo.File.Path, shard, pathToShard[fileInfo.File.Path])
+				}
+				continue
+			}
+			if otherShard, ok := pathToShard[fileInfo.File.Path]; ok {
+				return nil, fmt.Errorf("shards %d and %d both wrote to %s", otherShard, shard, fileInfo.File.Path)
+			}
+			if otherFileInfo, ok := pathToFileInfo[fileInfo.File.Path]; ok && otherFileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				return nil, fmt.Errorf("%s is a file in shard %d and a directory in another shard", fileInfo.File.Path, shard)
+			}
+			pathToShard[fileInfo.File.Path] = shard
+			pathToFileInfo[fileInfo.File.Path] = fileInfo
+		}
+	}
+	shardToPaths := make(map[uint64][]string)
+	var paths []string
+	for path := range pathToFileInfo {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fileInfo := pathToFileInfo[path]
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			if err := pfsutil.MakeDirectory(a.pfsAPIClient, outputCommit.Repo.Name, outputCommit.Id, path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := a.copyFile(fileInfo.File, &pfs.File{Commit: outputCommit, Path: path}); err != nil {
+			return nil, err
+		}
+		shardToPaths[pathToShard[path]] = append(shardToPaths[pathToShard[path]], path)
+	}
+	return shardToPaths, nil
+}
+
+// keepFailedOutput copies the output of each of a failed job's shards into a
+// commit of the job's failed output repo tagged with the shard, the repo is
+// deleted once failedOutputTTL has passed.
+func (a *apiServer) keepFailedOutput(ctx context.Context, job *pps.Job, scratchCommits map[uint64]*pfs.Commit) error {
+	repo := pps.JobFailedOutputRepo(job)
+	if _, err := a.pfsAPIClient.CreateRepo(ctx, &pfs.CreateRepoRequest{
+		Repo: repo,
+		Ttl:  prototime.DurationToProto(failedOutputTTL),
+	}); err != nil {
+		return err
+	}
+	for shard, scratchCommit := range scratchCommits {
+		fileInfos, err := a.walkFiles(ctx, &pfs.File{Commit: scratchCommit}, nil)
+		if err != nil {
+			return err
+		}
+		commit, err := a.pfsAPIClient.StartCommit(ctx, &pfs.StartCommitRequest{
+			Parent: &pfs.Commit{Repo: repo},
+		})
+		if err != nil {
+			return err
+		}
+		for _, fileInfo := range fileInfos {
+			if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				if err := pfsutil.MakeDirectory(a.pfsAPIClient, repo.Name, commit.Id, fileInfo.File.Path); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := a.copyFile(fileInfo.File, &pfs.File{Commit: commit, Path: fileInfo.File.Path}); err != nil {
+				return err
+			}
+		}
+		if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{
+			Commit: commit,
+		}); err != nil {
+			return err
+		}
+		if _, err := a.pfsAPIClient.TagCommit(ctx, &pfs.TagCommitRequest{
+			Commit: commit,
+			Tag:    pps.ShardTag(shard),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkFiles returns the FileInfos for every file and directory beneath file.
+func (a *apiServer) walkFiles(ctx context.Context, file *pfs.File, shard *pfs.Shard) ([]*pfs.FileInfo, error) {
+	listFileClient, err := a.pfsAPIClient.ListFile(ctx, &pfs.ListFileRequest{File: file, Shard: shard})
+	if err != nil {
+		return nil, err
+	}
+	var fileInfos []*pfs.FileInfo
+	for {
+		fileInfo, err := listFileClient.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fileInfos = append(fileInfos, fileInfo)
+	}
+	var result []*pfs.FileInfo
+	for _, fileInfo := range fileInfos {
+		result = append(result, fileInfo)
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			children, err := a.walkFiles(ctx, fileInfo.File, shard)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, children...)
+		}
+	}
+	return result, nil
+}
+
+// fillFromDatumCache copies the cached output for shard into scratchCommit, it
+// returns false if there was nothing in the cache or the cached files are
+// gone, in which case the transform is run as normal.
+func (a *apiServer) fillFromDatumCache(
+	ctx context.Context,
+	jobState *jobState,
+	shard uint64,
+	transform *pps.Transform,
+	commitMounts []*fuse.CommitMount,
+	scratchCommit *pfs.Commit,
+) (bool, error) {
+	datumHash, err := a.datumHash(ctx, transform, commitMounts)
+	if err != nil {
+		return false, err
+	}
+	a.lock.Lock()
+	jobState.datumHashes[shard] = datumHash
+	a.lock.Unlock()
+	if datumHash == "" {
+		return false, nil
+	}
+	datumCache, err := a.persistAPIServer.GetDatumCache(ctx, &persist.DatumHash{Hash: datumHash})
+	if err != nil {
+		return false, err
+	}
+	if datumCache.Hash == "" {
+		return false, nil
+	}
+	// The commit the cached files were written to may have been deleted
+	// since, nothing is copied unless all of them are still there.
+	for _, file := range datumCache.Files {
+		if _, err := pfsutil.InspectFile(a.pfsAPIClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, nil); err != nil {
+			protolog.Printf("datum cache %s is missing %s/%s/%s, running the transform: %s", datumHash, file.Commit.Repo.Name, file.Commit.Id, file.Path, err.Error())
+			return false, nil
+		}
+	}
+	for _, file := range datumCache.Files {
+		if err := a.copyFile(file, &pfs.File{Commit: scratchCommit, Path: file.Path}); err != nil {
+			return false, err
+		}
+	}
+	a.lock.Lock()
+	jobState.cachedShards[shard] = true
+	a.lock.Unlock()
+	return true, nil
+}
+
+// datumHash returns a hash of transform and the identity of the data in
+// commitMounts, it returns "" if the output of transform can't be cached. A
+// file is identified by the commit that last modified it and its path, so
+// the data itself is never read, and the same data in another repo, or in a
+// later commit that didn't touch it, hashes the same.
+func (a *apiServer) datumHash(ctx context.Context, transform *pps.Transform, commitMounts []*fuse.CommitMount) (string, error) {
+	// An image referenced by tag can change without its name changing so only
+	// images referenced by digest are cached.
+	if !strings.Contains(transform.Image, "@") {
+		return "", nil
+	}
+	// Sidecars, a proxy say, can feed the transform things which aren't in
+	// its inputs.
+	if len(transform.Sidecars) > 0 {
+		return "", nil
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", transform.Image, strings.Join(transform.Cmd, " "), transform.Stdin)
+	for _, name := range envNames(transform.Env) {
+		fmt.Fprintf(hash, "%s=%s\n", name, transform.Env[name])
+	}
+	for i, commitMount := range commitMounts {
+		fileInfos, err := a.walkFiles(ctx, &pfs.File{Commit: commitMount.Commit}, commitMount.Shard)
+		if err != nil {
+			return "", err
+		}
+		sortFileInfosByPath(fileInfos)
+		fmt.Fprintf(hash, "%d %v\n", i, commitMount.Shard)
+		for _, fileInfo := range fileInfos {
+			if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				continue
+			}
+			if fileInfo.CommitModified == nil {
+				return "", nil
+			}
+			fmt.Fprintf(hash, "%s %s %d\n", fileInfo.CommitModified.Id, fileInfo.File.Path, fileInfo.SizeBytes)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// createDatumCaches records the paths each shard wrote to outputCommit under
+// the shard's datum hash.
+func (a *apiServer) createDatumCaches(ctx context.Context, outputCommit *pfs.Commit, datumHashes map[uint64]string, shardToPaths map[uint64][]string) error {
+	for shard, datumHash := range datumHashes {
+		if datumHash == "" {
+			continue
+		}
+		datumCache := &persist.DatumCache{Hash: datumHash}
+		for _, path := range shardToPaths[shard] {
+			datumCache.Files = append(datumCache.Files, &pfs.File{Commit: outputCommit, Path: path})
+		}
+		if _, err := a.persistAPIServer.CreateDatumCache(ctx, datumCache); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *apiServer) copyFile(from *pfs.File, to *pfs.File) (retErr error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(pfsutil.GetFile(
+			a.pfsAPIClient,
+			from.Commit.Repo.Name,
+			from.Commit.Id,
+			from.Path,
+			0,
+			math.MaxInt64,
+			nil,
+			writer,
+		))
+	}()
+	defer func() {
+		if err := reader.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	_, err := pfsutil.PutFile(a.pfsAPIClient, to.Commit.Repo.Name, to.Commit.Id, to.Path, 0, reader)
+	return err
+}
+
+// writeJobManifest records what jobInfo is running in a commit of the manifest
+// repo tagged with the job's id, the commit is finished so it can't be changed
+// afterward.
+func (a *apiServer) writeJobManifest(ctx context.Context, jobInfo *persist.JobInfo) error {
+	job := &pps.Job{Id: jobInfo.JobId}
+	imageDigest, err := a.imageDigest(ctx, jobInfo)
+	if err != nil {
+		return err
+	}
+	jobManifest := &pps.JobManifest{
+		Job:              job,
+		Transform:        jobInfo.Transform,
+		ImageDigest:      imageDigest,
+		Shards:           jobInfo.Shards,
+		Inputs:           jobInfo.Inputs,
+		PachydermVersion: pachyderm.Version.VersionString(),
+		ExternalInputs:   jobInfo.ExternalInputs,
+	}
+	if jobInfo.PipelineName != "" {
+		jobManifest.Pipeline = &pps.Pipeline{Name: jobInfo.PipelineName}
+	}
+	var buffer bytes.Buffer
+	if err := marshaler.Marshal(&buffer, jobManifest); err != nil {
+		return err
+	}
+	repo := pps.JobManifestRepo()
+	if _, err := pfsutil.InspectRepo(a.pfsAPIClient, repo.Name); err != nil {
+		if _, err := a.pfsAPIClient.CreateRepo(ctx, &pfs.CreateRepoRequest{Repo: repo}); err != nil {
+			// another job may have created it first
+			if _, inspectErr := pfsutil.InspectRepo(a.pfsAPIClient, repo.Name); inspectErr != nil {
+				return err
+			}
+		}
+	}
+	// each manifest is a commit with no parent, so a commit holds exactly
+	// one job's manifest
+	commit, err := a.pfsAPIClient.StartCommit(ctx, &pfs.StartCommitRequest{
+		Parent: &pfs.Commit{Repo: repo},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := pfsutil.PutFile(a.pfsAPIClient, repo.Name, commit.Id, pps.JobManifestPath, 0, &buffer); err != nil {
+		return err
+	}
+	if _, err := a.pfsAPIClient.FinishCommit(ctx, &pfs.FinishCommitRequest{Commit: commit}); err != nil {
+		return err
+	}
+	_, err = a.pfsAPIClient.TagCommit(ctx, &pfs.TagCommitRequest{
+		Commit: commit,
+		Tag:    pps.JobManifestTag(job),
+	})
+	return err
+}
+
+// imageDigest returns the image jobInfo's containers run pinned by digest, ""
+// if it can't be determined.
+func (a *apiServer) imageDigest(ctx context.Context, jobInfo *persist.JobInfo) (string, error) {
+	if jobInfo.PipelineName != "" {
+		pipelineInfo, err := a.persistAPIServer.GetPipelineInfo(ctx, &pps.Pipeline{Name: jobInfo.PipelineName})
+		if err != nil {
+			return "", err
+		}
+		// images built by pps are recorded when they're built
+		if pipelineInfo.ImageDigest != "" {
+			return pipelineInfo.ImageDigest, nil
+		}
+	}
+	if strings.Contains(jobInfo.Transform.Image, "@") {
+		return jobInfo.Transform.Image, nil
+	}
+	pods, err := a.kubeClient.Pods(api.NamespaceDefault).List(kubelabels.SelectorFromSet(labels(jobInfo.JobId)), fields.Everything())
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.Name != "user" || containerStatus.ImageID == "" {
+				continue
+			}
+			// ImageIDs look like docker://sha256:... or
+			// docker-pullable://repo@sha256:...
+			imageID := containerStatus.ImageID
+			if i := strings.Index(imageID, "://"); i != -1 {
+				imageID = imageID[i+len("://"):]
+			}
+			return imageID, nil
+		}
+	}
+	return "", nil
+}
+
+func commitKey(commit *pfs.Commit) string {
+	return fmt.Sprintf("%s/%s", commit.Repo.Name, commit.Id)
+}
+
+func addJobStats(to *pps.JobStats, from *pps.JobStats) {
+	if from == nil {
+		return
+	}
+	to.Datums += from.Datums
+	to.ProcessTime = prototime.DurationToProto(prototime.DurationFromProto(to.ProcessTime) + prototime.DurationFromProto(from.ProcessTime))
+	to.BytesRead += from.BytesRead
+	to.BytesWritten += from.BytesWritten
+}
+
+func newJobInfo(persistJobInfo *persist.JobInfo) (*pps.JobInfo, error) {
+	job := &pps.Job{Id: persistJobInfo.JobId}
+	return &pps.JobInfo{
+		Job:            job,
+		Transform:      persistJobInfo.Transform,
+		Pipeline:       &pps.Pipeline{Name: persistJobInfo.PipelineName},
+		Shards:         persistJobInfo.Shards,
+		Inputs:         persistJobInfo.Inputs,
+		ParentJob:      persistJobInfo.ParentJob,
+		CreatedAt:      persistJobInfo.CreatedAt,
+		OutputCommit:   persistJobInfo.OutputCommit,
+		State:          persistJobInfo.State,
+		Stats:          persistJobInfo.Stats,
+		Namespace:      persistJobInfo.Namespace,
+		Priority:       persistJobInfo.Priority,
+		Preemptible:    persistJobInfo.Preemptible,
+		RunId:          persistJobInfo.RunId,
+		ExternalInputs: persistJobInfo.ExternalInputs,
+	}, nil
+}
+
+// job returns the kubernetes job that runs shards of jobInfo's shards,
+// parallelism of them at a time.
+func job(jobInfo *persist.JobInfo, shards uint64, parallelism uint64) *extensions.Job {
+	app := jobInfo.JobId
+	completions := int(shards)
+	kubeParallelism := int(parallelism)
+	return &extensions.Job{
+		TypeMeta: unversioned.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "v1",
+		},
+		ObjectMeta: api.ObjectMeta{
+			Name:   jobInfo.JobId,
+			Labels: labels(app),
+		},
+		Spec: extensions.JobSpec{
+			Selector: &extensions.PodSelector{
+				MatchLabels: labels(app),
+			},
+			Parallelism: &kubeParallelism,
+			Completions: &completions,
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{
+					Name:   jobInfo.JobId,
+					Labels: labels(app),
+				},
+				Spec: podSpec(jobInfo, []string{"/job-shim", jobInfo.JobId}, "OnFailure"),
+			},
+		},
+	}
+}
+
+// debugPod returns the pod which DebugJob starts for shard of jobInfo, it
+// has a different app so that it isn't mistaken for one of the job's pods.
+func debugPod(jobInfo *persist.JobInfo, shard uint64) *api.Pod {
+	app := fmt.Sprintf("debug-%s", jobInfo.JobId)
+	return &api.Pod{
+		TypeMeta: unversioned.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: api.ObjectMeta{
+			GenerateName: app + "-",
+			Labels:       labels(app),
+		},
+		Spec: podSpec(
+			jobInfo,
+			[]string{"/job-shim", "--debug-shard", strconv.FormatUint(shard, 10), jobInfo.JobId},
+			"Never",
+		),
+	}
+}
+
+// speculativePod returns a pod which runs whichever straggling shard of
+// jobInfo is waiting to be run again. It has a different app from the job's
+// pods so the job doesn't count it toward its completions.
+func speculativePod(jobInfo *persist.JobInfo) *api.Pod {
+	app := speculativeApp(jobInfo.JobId)
+	return &api.Pod{
+		TypeMeta: unversioned.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: api.ObjectMeta{
+			GenerateName: app + "-",
+			Labels:       labels(app),
+		},
+		Spec: podSpec(jobInfo, []string{"/job-shim", "--speculative", jobInfo.JobId}, "Never"),
+	}
+}
+
+func speculativeApp(jobID string) string {
+	return fmt.Sprintf("speculative-%s", jobID)
+}
+
+// podSpec returns the spec of the pods which run command for jobInfo.
+func podSpec(jobInfo *persist.JobInfo, command []string, restartPolicy api.RestartPolicy) api.PodSpec {
+	image := ppsutil.DefaultImage
+	if jobInfo.Transform.Image != "" {
+		image = jobInfo.Transform.Image
+	}
+	env := []api.EnvVar{
+		{Name: "PFS_CACHE_DIR", Value: cacheMountPath},
+		{Name: "PFS_CACHE_REQUEST_DIR", Value: cacheRequestMountPath},
+	}
+	for _, name := range envNames(jobInfo.Transform.Env) {
+		env = append(env, api.EnvVar{Name: name, Value: jobInfo.Transform.Env[name]})
+	}
+	var nodeSelector map[string]string
+	var resources api.ResourceRequirements
+	if jobInfo.Transform.Resources != nil {
+		nodeSelector = jobInfo.Transform.Resources.NodeSelector
+		if jobInfo.Transform.Resources.Gpus > 0 {
+			resources.Limits = api.ResourceList{
+				gpuResourceName: *resource.NewQuantity(int64(jobInfo.Transform.Resources.Gpus), resource.DecimalSI),
+			}
+		}
+	}
+	cacheRequestVolumeMount := api.VolumeMount{
+		Name:      "pfs-cache-requests",
+		MountPath: cacheRequestMountPath,
+	}
+	volumeMounts := []api.VolumeMount{
+		{
+			Name:      "pfs-cache",
+			MountPath: cacheMountPath,
+			ReadOnly:  true,
+		},
+		cacheRequestVolumeMount,
+	}
+	volumes := []api.Volume{
+		{
+			Name: "pfs-cache",
+			VolumeSource: api.VolumeSource{
+				HostPath: &api.HostPathVolumeSource{
+					Path: cacheHostPath,
 				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:    "user",
-							Image:   image,
-							Command: []string{"/job-shim", jobInfo.JobId},
-							SecurityContext: &api.SecurityContext{
-								Privileged: &trueVal, // god is this dumb
-							},
-						},
-					},
-					RestartPolicy: "OnFailure",
+			},
+		},
+		{
+			Name: "pfs-cache-requests",
+			VolumeSource: api.VolumeSource{
+				EmptyDir: &api.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: "pfs-blocks",
+			VolumeSource: api.VolumeSource{
+				HostPath: &api.HostPathVolumeSource{
+					Path: blockHostPath,
+				},
+			},
+		},
+	}
+	// the cache container exits once the job's container finishes
+	// requesting blocks
+	sidecars := []api.Container{
+		{
+			Name:    cacheContainerName,
+			Image:   ppsutil.DefaultImage,
+			Command: []string{"/job-shim", "--fill-cache", jobInfo.JobId},
+			Env: []api.EnvVar{
+				{Name: "PFS_CACHE_DIR", Value: cacheMountPath},
+				{Name: "PFS_CACHE_REQUEST_DIR", Value: cacheRequestMountPath},
+				{Name: "PFS_BLOCK_DIR", Value: blockMountPath},
+			},
+			VolumeMounts: []api.VolumeMount{
+				{
+					Name:      "pfs-cache",
+					MountPath: cacheMountPath,
+				},
+				cacheRequestVolumeMount,
+				{
+					Name:      "pfs-blocks",
+					MountPath: blockMountPath,
+					ReadOnly:  true,
 				},
 			},
 		},
 	}
+	if len(jobInfo.Transform.Sidecars) > 0 {
+		sharedVolumeMount := api.VolumeMount{
+			Name:      "shared",
+			MountPath: sharedMountPath,
+		}
+		volumeMounts = append(volumeMounts, sharedVolumeMount)
+		volumes = append(volumes, api.Volume{
+			Name: "shared",
+			VolumeSource: api.VolumeSource{
+				EmptyDir: &api.EmptyDirVolumeSource{},
+			},
+		})
+		for _, sidecar := range jobInfo.Transform.Sidecars {
+			var sidecarEnv []api.EnvVar
+			for _, name := range envNames(sidecar.Env) {
+				sidecarEnv = append(sidecarEnv, api.EnvVar{Name: name, Value: sidecar.Env[name]})
+			}
+			sidecars = append(sidecars, api.Container{
+				Name:         sidecar.Name,
+				Image:        sidecar.Image,
+				Command:      sidecar.Cmd,
+				Env:          sidecarEnv,
+				VolumeMounts: []api.VolumeMount{sharedVolumeMount},
+			})
+		}
+	}
+	return api.PodSpec{
+		Containers: append([]api.Container{
+			{
+				Name:    "user",
+				Image:   image,
+				Command: command,
+				SecurityContext: &api.SecurityContext{
+					Privileged: &trueVal, // god is this dumb
+				},
+				Resources:    resources,
+				Env:          env,
+				VolumeMounts: volumeMounts,
+			},
+		}, sidecars...),
+		Volumes:       volumes,
+		RestartPolicy: restartPolicy,
+		NodeSelector:  nodeSelector,
+	}
+}
+
+// envNames returns the names of the variables in env, sorted.
+func envNames(env map[string]string) []string {
+	var names []string
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func labels(app string) map[string]string {
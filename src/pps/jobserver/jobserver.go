@@ -2,24 +2,57 @@ package jobserver
 
 import (
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/events"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"github.com/pachyderm/pachyderm/src/pps/persist"
 	kube "k8s.io/kubernetes/pkg/client/unversioned"
 )
 
+// NamespaceQuotasKey is the runtime config key for the max concurrent jobs of
+// each namespace, its value looks like "team-a=4,team-b=2".
+const NamespaceQuotasKey = "namespace_max_concurrent_jobs"
+
 type CombinedJobAPIServer interface {
 	pps.JobAPIServer
 	pps.InternalJobAPIServer
+	// SetNamespaceQuotas is a config.Setter for NamespaceQuotasKey.
+	SetNamespaceQuotas(value string) error
+	// Start restores the queue from the persisted jobs, it must be called
+	// before the server is served.
+	Start() error
+}
+
+// Scaler decides how many workers, pods, run a job's shards at once. It's
+// asked again whenever one of the job's shards finishes or the queue
+// changes.
+type Scaler interface {
+	// Parallelism returns the number of workers jobInfo should have. backlog
+	// is the number of its shards which haven't finished and queueDepth is
+	// the number of its pipeline's jobs waiting for it to finish.
+	Parallelism(jobInfo *persist.JobInfo, backlog uint64, queueDepth uint64) uint64
+}
+
+// NewScaler returns a Scaler which sizes jobs by their transform's
+// autoscaling, jobs without it run all of their shards at once.
+func NewScaler() Scaler {
+	return newScaler()
 }
 
 func NewAPIServer(
 	pfsAPIClient pfs.APIClient,
 	persistAPIServer persist.APIServer,
 	client *kube.Client,
+	auditRecorder audit.Recorder,
+	eventRecorder events.Recorder,
+	scaler Scaler,
 ) CombinedJobAPIServer {
 	return newAPIServer(
 		pfsAPIClient,
 		persistAPIServer,
 		client,
+		auditRecorder,
+		eventRecorder,
+		scaler,
 	)
 }
@@ -0,0 +1,103 @@
+package jobserver
+
+import (
+	"github.com/pachyderm/pachyderm/src/pps/persist"
+	"go.pedge.io/protolog"
+	"k8s.io/kubernetes/pkg/api"
+	kubeerrors "k8s.io/kubernetes/pkg/api/errors"
+)
+
+type scaler struct{}
+
+func newScaler() *scaler {
+	return &scaler{}
+}
+
+// Parallelism gives each worker shards_per_worker of the backlog, within
+// min_workers and max_workers. A pipeline with jobs queued behind the job
+// gets max_workers so that they can start sooner. Jobs with sidecars get a
+// worker per shard since their pods never complete to take another shard,
+// lint rejects autoscaling them but pipelines created before it may have.
+func (s *scaler) Parallelism(jobInfo *persist.JobInfo, backlog uint64, queueDepth uint64) uint64 {
+	autoscaling := jobInfo.Transform.GetAutoscaling()
+	if autoscaling == nil || len(jobInfo.Transform.GetSidecars()) > 0 {
+		return backlog
+	}
+	shardsPerWorker := autoscaling.ShardsPerWorker
+	if shardsPerWorker == 0 {
+		shardsPerWorker = 1
+	}
+	workers := (backlog + shardsPerWorker - 1) / shardsPerWorker
+	if queueDepth > 0 && autoscaling.MaxWorkers != 0 {
+		workers = autoscaling.MaxWorkers
+	}
+	if workers < autoscaling.MinWorkers {
+		workers = autoscaling.MinWorkers
+	}
+	if autoscaling.MaxWorkers != 0 && workers > autoscaling.MaxWorkers {
+		workers = autoscaling.MaxWorkers
+	}
+	// workers beyond the backlog would have no shards to run
+	if workers > backlog {
+		workers = backlog
+	}
+	return workers
+}
+
+// autoscale sets the parallelism of jobInfo's kubernetes job to what
+// a.scaler wants for its backlog. Parallelism isn't lowered below the job's
+// active pods since kubernetes would kill the excess pods along with the
+// shards running in them, it's lowered further as they finish.
+func (a *apiServer) autoscale(jobInfo *persist.JobInfo) {
+	if a.kubeClient == nil {
+		return
+	}
+	backlog := jobInfo.Shards
+	a.lock.Lock()
+	if jobState, ok := a.jobStates[jobInfo.JobId]; ok {
+		backlog -= uint64(len(jobState.finishedShards))
+	}
+	a.lock.Unlock()
+	parallelism := int(a.scaler.Parallelism(jobInfo, backlog, a.queueDepth(jobInfo.PipelineName)))
+	kubeJob, err := a.kubeClient.Jobs(api.NamespaceDefault).Get(jobInfo.JobId)
+	if err != nil {
+		if !kubeerrors.IsNotFound(err) {
+			protolog.Printf("error getting the kubernetes job of job %s: %s", jobInfo.JobId, err.Error())
+		}
+		return
+	}
+	if parallelism < kubeJob.Status.Active {
+		parallelism = kubeJob.Status.Active
+	}
+	// kubernetes doesn't run more pods than the job has shards left, so
+	// parallelisms past the backlog are all the same
+	if current := kubeJob.Spec.Parallelism; current != nil &&
+		(*current == parallelism || (*current >= int(backlog) && parallelism >= int(backlog))) {
+		return
+	}
+	kubeJob.Spec.Parallelism = &parallelism
+	if _, err := a.kubeClient.Jobs(api.NamespaceDefault).Update(kubeJob); err != nil {
+		protolog.Printf("error scaling job %s to %d workers: %s", jobInfo.JobId, parallelism, err.Error())
+	}
+}
+
+// queueDepth returns the number of pipelineName's jobs which are queued.
+func (a *apiServer) queueDepth(pipelineName string) uint64 {
+	a.queueLock.Lock()
+	defer a.queueLock.Unlock()
+	return a.queueDepthLocked(pipelineName)
+}
+
+// queueDepthLocked is queueDepth for callers which hold queueLock.
+func (a *apiServer) queueDepthLocked(pipelineName string) uint64 {
+	if pipelineName == "" {
+		return 0
+	}
+	var result uint64
+	for _, queuedJob := range a.queue {
+		if queuedJob.jobInfo.PipelineName == pipelineName {
+			result++
+		}
+	}
+	return result
+}
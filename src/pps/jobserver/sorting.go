@@ -0,0 +1,65 @@
+package jobserver
+
+import (
+	"sort"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/proto/time"
+)
+
+func sortFileInfosByPath(s []*pfs.FileInfo) {
+	sort.Sort(fileInfosByPath(s))
+}
+
+type fileInfosByPath []*pfs.FileInfo
+
+func (s fileInfosByPath) Len() int          { return len(s) }
+func (s fileInfosByPath) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s fileInfosByPath) Less(i int, j int) bool {
+	return s[i].File.Path < s[j].File.Path
+}
+
+// sortQueuedJobs sorts s from highest to lowest priority and then from oldest
+// to newest.
+func sortQueuedJobs(s []*queuedJob) {
+	sort.Sort(queuedJobsByPriority(s))
+}
+
+type queuedJobsByPriority []*queuedJob
+
+func (s queuedJobsByPriority) Len() int          { return len(s) }
+func (s queuedJobsByPriority) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s queuedJobsByPriority) Less(i int, j int) bool {
+	if s[i].jobInfo.Priority != s[j].jobInfo.Priority {
+		return s[i].jobInfo.Priority > s[j].jobInfo.Priority
+	}
+	return prototime.TimestampToTime(s[i].jobInfo.CreatedAt).Before(prototime.TimestampToTime(s[j].jobInfo.CreatedAt))
+}
+
+// sortJobInfosByCreated sorts s from oldest to newest.
+func sortJobInfosByCreated(s []*pps.JobInfo) {
+	sort.Sort(jobInfosByCreated(s))
+}
+
+type jobInfosByCreated []*pps.JobInfo
+
+func (s jobInfosByCreated) Len() int          { return len(s) }
+func (s jobInfosByCreated) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s jobInfosByCreated) Less(i int, j int) bool {
+	return prototime.TimestampToTime(s[i].CreatedAt).Before(prototime.TimestampToTime(s[j].CreatedAt))
+}
+
+// sortRunInfosByCreated sorts s from oldest to newest by their first job,
+// each RunInfo's jobs must already be sorted.
+func sortRunInfosByCreated(s []*pps.RunInfo) {
+	sort.Sort(runInfosByCreated(s))
+}
+
+type runInfosByCreated []*pps.RunInfo
+
+func (s runInfosByCreated) Len() int          { return len(s) }
+func (s runInfosByCreated) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s runInfosByCreated) Less(i int, j int) bool {
+	return prototime.TimestampToTime(s[i].JobInfo[0].CreatedAt).Before(prototime.TimestampToTime(s[j].JobInfo[0].CreatedAt))
+}
@@ -0,0 +1,223 @@
+package jobserver
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pps/persist"
+	"go.pedge.io/protolog"
+	"k8s.io/kubernetes/pkg/api"
+	kubeerrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/fields"
+	kubelabels "k8s.io/kubernetes/pkg/labels"
+)
+
+// speculation is the state of a job's straggling shards. A shard whose first
+// run has gone on for the job's speculation_threshold times the median run
+// time of its finished shards is speculated, it's queued and a speculative
+// pod is started which picks it up. The first of the two runs to succeed
+// finishes the shard and the other is killed. It's guarded by apiServer.lock.
+type speculation struct {
+	starts          map[uint64]time.Time   // when the first run of each unfinished shard started
+	durations       []time.Duration        // how long each finished shard ran, cached shards aren't counted
+	pods            map[uint64]string      // the pod of the first run of each shard
+	speculated      map[uint64]bool        // the shards which have been given a speculative run
+	queue           []uint64               // speculated shards waiting for their pod to start
+	commits         map[uint64]*pfs.Commit // the scratch commit of each speculative run
+	speculativePods map[uint64]string      // the pod of each speculative run
+	failed          map[uint64]bool        // speculated shards one of whose runs failed, the other run decides the shard
+	timer           *time.Timer            // fires when the next shard would become a straggler
+}
+
+func newSpeculation() *speculation {
+	s := &speculation{}
+	s.reset()
+	return s
+}
+
+// reset forgets the runs in progress, which are killed when a job is
+// preempted or times out.
+func (s *speculation) reset() {
+	s.stop()
+	s.starts = make(map[uint64]time.Time)
+	s.pods = make(map[uint64]string)
+	s.speculated = make(map[uint64]bool)
+	s.queue = nil
+	s.commits = make(map[uint64]*pfs.Commit)
+	s.speculativePods = make(map[uint64]string)
+	s.failed = make(map[uint64]bool)
+}
+
+func (s *speculation) stop() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// start records the start of shard's first run, in pod.
+func (s *speculation) start(shard uint64, pod string) {
+	s.starts[shard] = time.Now()
+	s.pods[shard] = pod
+}
+
+// next returns the next speculated shard for the speculative run in pod, it
+// returns false if there's none waiting which hasn't already finished.
+func (s *speculation) next(finishedShards map[uint64]bool, pod string) (uint64, bool) {
+	for len(s.queue) > 0 {
+		shard := s.queue[0]
+		s.queue = s.queue[1:]
+		if !finishedShards[shard] {
+			s.speculativePods[shard] = pod
+			return shard, true
+		}
+	}
+	return 0, false
+}
+
+// unqueue removes shard from the queue, its speculative pod couldn't be
+// started.
+func (s *speculation) unqueue(shard uint64) {
+	for i, queued := range s.queue {
+		if queued == shard {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// running returns true if shard's speculative run has started.
+func (s *speculation) running(shard uint64) bool {
+	_, ok := s.speculativePods[shard]
+	return ok
+}
+
+// finish records that shard finished, its run time counts toward the median
+// unless it was served from the datum cache.
+func (s *speculation) finish(shard uint64, cached bool) {
+	if start, ok := s.starts[shard]; ok && !cached {
+		s.durations = append(s.durations, time.Since(start))
+	}
+	delete(s.starts, shard)
+}
+
+// limit returns how long a shard can run before it's a straggler, it returns
+// false if stragglers aren't looked for yet.
+func (s *speculation) limit(threshold float64, shards uint64) (time.Duration, bool) {
+	if threshold == 0 || len(s.durations) == 0 || uint64(len(s.durations))*2 < shards {
+		return 0, false
+	}
+	return time.Duration(threshold * float64(medianDuration(s.durations))), true
+}
+
+// stragglers speculates the unfinished shards which have run for longer than
+// limit and returns them.
+func (s *speculation) stragglers(limit time.Duration, finishedShards map[uint64]bool) []uint64 {
+	var result []uint64
+	for shard, start := range s.starts {
+		if s.speculated[shard] || finishedShards[shard] || time.Since(start) < limit {
+			continue
+		}
+		s.speculated[shard] = true
+		s.queue = append(s.queue, shard)
+		result = append(result, shard)
+	}
+	return result
+}
+
+// nextStraggler returns how long until the next shard becomes a straggler,
+// it returns false if none can.
+func (s *speculation) nextStraggler(limit time.Duration) (time.Duration, bool) {
+	var result time.Duration
+	found := false
+	for shard, start := range s.starts {
+		if s.speculated[shard] {
+			continue
+		}
+		wait := limit - time.Since(start)
+		if !found || wait < result {
+			result = wait
+			found = true
+		}
+	}
+	return result, found
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Sort(durationSlice(sorted))
+	return sorted[len(sorted)/2]
+}
+
+type durationSlice []time.Duration
+
+func (s durationSlice) Len() int           { return len(s) }
+func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
+
+// scheduleSpeculation sets jobState's timer to look for stragglers when the
+// next shard would become one, a.lock must be held.
+func (a *apiServer) scheduleSpeculation(jobInfo *persist.JobInfo, jobState *jobState) {
+	if a.kubeClient == nil {
+		return
+	}
+	jobState.speculation.stop()
+	limit, ok := jobState.speculation.limit(jobInfo.Transform.SpeculationThreshold, jobInfo.Shards)
+	if !ok {
+		return
+	}
+	wait, ok := jobState.speculation.nextStraggler(limit)
+	if !ok {
+		return
+	}
+	jobState.speculation.timer = time.AfterFunc(wait, func() { a.speculate(jobInfo) })
+}
+
+// speculate starts a speculative pod for each of jobInfo's stragglers.
+func (a *apiServer) speculate(jobInfo *persist.JobInfo) {
+	a.lock.Lock()
+	jobState, ok := a.jobStates[jobInfo.JobId]
+	if !ok {
+		a.lock.Unlock()
+		return
+	}
+	var stragglers []uint64
+	if limit, ok := jobState.speculation.limit(jobInfo.Transform.SpeculationThreshold, jobInfo.Shards); ok {
+		stragglers = jobState.speculation.stragglers(limit, jobState.finishedShards)
+	}
+	a.scheduleSpeculation(jobInfo, jobState)
+	a.lock.Unlock()
+	for _, shard := range stragglers {
+		protolog.Printf("shard %d of job %s is straggling, running it again", shard, jobInfo.JobId)
+		if _, err := a.kubeClient.Pods(api.NamespaceDefault).Create(speculativePod(jobInfo)); err != nil {
+			protolog.Printf("error starting a speculative run of job %s: %s", jobInfo.JobId, err.Error())
+			// the shard is left to its first run rather than retried
+			a.lock.Lock()
+			jobState.speculation.unqueue(shard)
+			a.lock.Unlock()
+		}
+	}
+}
+
+// killRun deletes the pod of a run which lost to the shard's other run.
+func (a *apiServer) killRun(pod string) {
+	if err := a.kubeClient.Pods(api.NamespaceDefault).Delete(pod, nil); err != nil && !kubeerrors.IsNotFound(err) {
+		protolog.Printf("error killing pod %s: %s", pod, err.Error())
+	}
+}
+
+// deleteSpeculativePods deletes the speculative pods of jobID.
+func (a *apiServer) deleteSpeculativePods(jobID string) error {
+	pods, err := a.kubeClient.Pods(api.NamespaceDefault).List(kubelabels.SelectorFromSet(labels(speculativeApp(jobID))), fields.Everything())
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if err := a.kubeClient.Pods(api.NamespaceDefault).Delete(pod.Name, nil); err != nil && !kubeerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package pps
 
 import (
+	"fmt"
+
 	"google.golang.org/grpc"
 
 	"golang.org/x/net/context"
@@ -25,3 +27,36 @@ func (a *localJobAPIClient) InspectJob(ctx context.Context, request *InspectJobR
 func (a *localJobAPIClient) ListJob(ctx context.Context, request *ListJobRequest, _ ...grpc.CallOption) (response *JobInfos, err error) {
 	return a.jobAPIServer.ListJob(ctx, request)
 }
+
+func (a *localJobAPIClient) ListQueue(ctx context.Context, request *ListQueueRequest, _ ...grpc.CallOption) (response *JobInfos, err error) {
+	return a.jobAPIServer.ListQueue(ctx, request)
+}
+
+func (a *localJobAPIClient) RequeueJob(ctx context.Context, request *RequeueJobRequest, _ ...grpc.CallOption) (response *Job, err error) {
+	return a.jobAPIServer.RequeueJob(ctx, request)
+}
+
+func (a *localJobAPIClient) InspectLineage(ctx context.Context, request *InspectLineageRequest, _ ...grpc.CallOption) (response *Lineage, err error) {
+	return a.jobAPIServer.InspectLineage(ctx, request)
+}
+
+func (a *localJobAPIClient) FlushCommit(ctx context.Context, request *FlushCommitRequest, _ ...grpc.CallOption) (response *FlushCommitResponse, err error) {
+	return a.jobAPIServer.FlushCommit(ctx, request)
+}
+
+func (a *localJobAPIClient) ListRun(ctx context.Context, request *ListRunRequest, _ ...grpc.CallOption) (response *RunInfos, err error) {
+	return a.jobAPIServer.ListRun(ctx, request)
+}
+
+func (a *localJobAPIClient) InspectJobManifest(ctx context.Context, request *InspectJobRequest, _ ...grpc.CallOption) (response *JobManifest, err error) {
+	return a.jobAPIServer.InspectJobManifest(ctx, request)
+}
+
+func (a *localJobAPIClient) DebugJob(ctx context.Context, request *DebugJobRequest, _ ...grpc.CallOption) (response *DebugJobResponse, err error) {
+	return a.jobAPIServer.DebugJob(ctx, request)
+}
+
+func (a *localJobAPIClient) WatchJobs(ctx context.Context, request *WatchJobsRequest, _ ...grpc.CallOption) (JobAPI_WatchJobsClient, error) {
+	// nothing in the server watches jobs, WatchJobs is for remote clients
+	return nil, fmt.Errorf("pachyderm: WatchJobs isn't supported by the local client")
+}
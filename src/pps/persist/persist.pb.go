@@ -13,6 +13,10 @@ It has these top-level messages:
 	JobInfos
 	JobOutput
 	JobState
+	DatumCache
+	DatumHash
+	ExportCheckpoint
+	ExportedFile
 	PipelineInfo
 	PipelineInfos
 */
@@ -37,16 +41,22 @@ var _ = fmt.Errorf
 var _ = math.Inf
 
 type JobInfo struct {
-	JobId        string                      `protobuf:"bytes,1,opt,name=job_id" json:"job_id,omitempty"`
-	Transform    *pachyderm_pps.Transform    `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
-	PipelineName string                      `protobuf:"bytes,3,opt,name=pipeline_name" json:"pipeline_name,omitempty"`
-	Shards       uint64                      `protobuf:"varint,4,opt,name=shards" json:"shards,omitempty"`
-	Inputs       []*pachyderm_pps.JobInput   `protobuf:"bytes,5,rep,name=inputs" json:"inputs,omitempty"`
-	ParentJob    *pachyderm_pps.Job          `protobuf:"bytes,6,opt,name=parent_job" json:"parent_job,omitempty"`
-	CreatedAt    *google_protobuf1.Timestamp `protobuf:"bytes,7,opt,name=created_at" json:"created_at,omitempty"`
-	OutputCommit *pfs.Commit                 `protobuf:"bytes,8,opt,name=output_commit" json:"output_commit,omitempty"`
-	State        pachyderm_pps.JobState      `protobuf:"varint,9,opt,name=state,enum=pachyderm.pps.JobState" json:"state,omitempty"`
-	CommitIndex  string                      `protobuf:"bytes,10,opt,name=commit_index" json:"commit_index,omitempty"`
+	JobId          string                         `protobuf:"bytes,1,opt,name=job_id" json:"job_id,omitempty"`
+	Transform      *pachyderm_pps.Transform       `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
+	PipelineName   string                         `protobuf:"bytes,3,opt,name=pipeline_name" json:"pipeline_name,omitempty"`
+	Shards         uint64                         `protobuf:"varint,4,opt,name=shards" json:"shards,omitempty"`
+	Inputs         []*pachyderm_pps.JobInput      `protobuf:"bytes,5,rep,name=inputs" json:"inputs,omitempty"`
+	ParentJob      *pachyderm_pps.Job             `protobuf:"bytes,6,opt,name=parent_job" json:"parent_job,omitempty"`
+	CreatedAt      *google_protobuf1.Timestamp    `protobuf:"bytes,7,opt,name=created_at" json:"created_at,omitempty"`
+	OutputCommit   *pfs.Commit                    `protobuf:"bytes,8,opt,name=output_commit" json:"output_commit,omitempty"`
+	State          pachyderm_pps.JobState         `protobuf:"varint,9,opt,name=state,enum=pachyderm.pps.JobState" json:"state,omitempty"`
+	CommitIndex    string                         `protobuf:"bytes,10,opt,name=commit_index" json:"commit_index,omitempty"`
+	Stats          *pachyderm_pps.JobStats        `protobuf:"bytes,11,opt,name=stats" json:"stats,omitempty"`
+	Namespace      string                         `protobuf:"bytes,12,opt,name=namespace" json:"namespace,omitempty"`
+	Priority       int64                          `protobuf:"varint,13,opt,name=priority" json:"priority,omitempty"`
+	Preemptible    bool                           `protobuf:"varint,14,opt,name=preemptible" json:"preemptible,omitempty"`
+	RunId          string                         `protobuf:"bytes,15,opt,name=run_id" json:"run_id,omitempty"`
+	ExternalInputs []*pachyderm_pps.ExternalInput `protobuf:"bytes,16,rep,name=external_inputs" json:"external_inputs,omitempty"`
 }
 
 func (m *JobInfo) Reset()         { *m = JobInfo{} }
@@ -88,6 +98,20 @@ func (m *JobInfo) GetOutputCommit() *pfs.Commit {
 	return nil
 }
 
+func (m *JobInfo) GetStats() *pachyderm_pps.JobStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+func (m *JobInfo) GetExternalInputs() []*pachyderm_pps.ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
 type JobInfos struct {
 	JobInfo []*JobInfo `protobuf:"bytes,1,rep,name=job_info" json:"job_info,omitempty"`
 }
@@ -120,21 +144,100 @@ func (m *JobOutput) GetOutputCommit() *pfs.Commit {
 }
 
 type JobState struct {
-	JobId string                 `protobuf:"bytes,1,opt,name=job_id" json:"job_id,omitempty"`
-	State pachyderm_pps.JobState `protobuf:"varint,2,opt,name=state,enum=pachyderm.pps.JobState" json:"state,omitempty"`
+	JobId string                  `protobuf:"bytes,1,opt,name=job_id" json:"job_id,omitempty"`
+	State pachyderm_pps.JobState  `protobuf:"varint,2,opt,name=state,enum=pachyderm.pps.JobState" json:"state,omitempty"`
+	Stats *pachyderm_pps.JobStats `protobuf:"bytes,3,opt,name=stats" json:"stats,omitempty"`
 }
 
 func (m *JobState) Reset()         { *m = JobState{} }
 func (m *JobState) String() string { return proto.CompactTextString(m) }
 func (*JobState) ProtoMessage()    {}
 
+func (m *JobState) GetStats() *pachyderm_pps.JobStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+// DatumCache records the output produced by running a transform over a datum,
+// hash identifies the transform and the content of the datum.
+type DatumCache struct {
+	Hash      string                      `protobuf:"bytes,1,opt,name=hash" json:"hash,omitempty"`
+	Files     []*pfs.File                 `protobuf:"bytes,2,rep,name=files" json:"files,omitempty"`
+	CreatedAt *google_protobuf1.Timestamp `protobuf:"bytes,3,opt,name=created_at" json:"created_at,omitempty"`
+}
+
+func (m *DatumCache) Reset()         { *m = DatumCache{} }
+func (m *DatumCache) String() string { return proto.CompactTextString(m) }
+func (*DatumCache) ProtoMessage()    {}
+
+func (m *DatumCache) GetFiles() []*pfs.File {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *DatumCache) GetCreatedAt() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+type DatumHash struct {
+	Hash string `protobuf:"bytes,1,opt,name=hash" json:"hash,omitempty"`
+}
+
+func (m *DatumHash) Reset()         { *m = DatumHash{} }
+func (m *DatumHash) String() string { return proto.CompactTextString(m) }
+func (*DatumHash) ProtoMessage()    {}
+
+// ExportCheckpoint records how much of its input an export pipeline has
+// written to its sink.
+type ExportCheckpoint struct {
+	PipelineName      string          `protobuf:"bytes,1,opt,name=pipeline_name" json:"pipeline_name,omitempty"`
+	ExportedCommitIds []string        `protobuf:"bytes,2,rep,name=exported_commit_ids" json:"exported_commit_ids,omitempty"`
+	CommitId          string          `protobuf:"bytes,3,opt,name=commit_id" json:"commit_id,omitempty"`
+	Files             []*ExportedFile `protobuf:"bytes,4,rep,name=files" json:"files,omitempty"`
+}
+
+func (m *ExportCheckpoint) Reset()         { *m = ExportCheckpoint{} }
+func (m *ExportCheckpoint) String() string { return proto.CompactTextString(m) }
+func (*ExportCheckpoint) ProtoMessage()    {}
+
+func (m *ExportCheckpoint) GetFiles() []*ExportedFile {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+type ExportedFile struct {
+	Path   string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	Offset int64  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+}
+
+func (m *ExportedFile) Reset()         { *m = ExportedFile{} }
+func (m *ExportedFile) String() string { return proto.CompactTextString(m) }
+func (*ExportedFile) ProtoMessage()    {}
+
 type PipelineInfo struct {
-	PipelineName string                         `protobuf:"bytes,1,opt,name=pipeline_name" json:"pipeline_name,omitempty"`
-	Transform    *pachyderm_pps.Transform       `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
-	Shards       uint64                         `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
-	Inputs       []*pachyderm_pps.PipelineInput `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
-	OutputRepo   *pfs.Repo                      `protobuf:"bytes,5,opt,name=output_repo" json:"output_repo,omitempty"`
-	CreatedAt    *google_protobuf1.Timestamp    `protobuf:"bytes,6,opt,name=created_at" json:"created_at,omitempty"`
+	PipelineName      string                         `protobuf:"bytes,1,opt,name=pipeline_name" json:"pipeline_name,omitempty"`
+	Transform         *pachyderm_pps.Transform       `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
+	Shards            uint64                         `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
+	Inputs            []*pachyderm_pps.PipelineInput `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
+	OutputRepo        *pfs.Repo                      `protobuf:"bytes,5,opt,name=output_repo" json:"output_repo,omitempty"`
+	CreatedAt         *google_protobuf1.Timestamp    `protobuf:"bytes,6,opt,name=created_at" json:"created_at,omitempty"`
+	Namespace         string                         `protobuf:"bytes,7,opt,name=namespace" json:"namespace,omitempty"`
+	MaxConcurrentJobs uint64                         `protobuf:"varint,8,opt,name=max_concurrent_jobs" json:"max_concurrent_jobs,omitempty"`
+	Priority          int64                          `protobuf:"varint,9,opt,name=priority" json:"priority,omitempty"`
+	Preemptible       bool                           `protobuf:"varint,10,opt,name=preemptible" json:"preemptible,omitempty"`
+	ImageDigest       string                         `protobuf:"bytes,11,opt,name=image_digest" json:"image_digest,omitempty"`
+	ExternalInputs    []*pachyderm_pps.ExternalInput `protobuf:"bytes,12,rep,name=external_inputs" json:"external_inputs,omitempty"`
+	Ingest            *pachyderm_pps.Ingest          `protobuf:"bytes,13,opt,name=ingest" json:"ingest,omitempty"`
+	Export            *pachyderm_pps.Export          `protobuf:"bytes,14,opt,name=export" json:"export,omitempty"`
 }
 
 func (m *PipelineInfo) Reset()         { *m = PipelineInfo{} }
@@ -169,6 +272,27 @@ func (m *PipelineInfo) GetCreatedAt() *google_protobuf1.Timestamp {
 	return nil
 }
 
+func (m *PipelineInfo) GetExternalInputs() []*pachyderm_pps.ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
+func (m *PipelineInfo) GetIngest() *pachyderm_pps.Ingest {
+	if m != nil {
+		return m.Ingest
+	}
+	return nil
+}
+
+func (m *PipelineInfo) GetExport() *pachyderm_pps.Export {
+	if m != nil {
+		return m.Export
+	}
+	return nil
+}
+
 type PipelineInfos struct {
 	PipelineInfo []*PipelineInfo `protobuf:"bytes,1,rep,name=pipeline_info" json:"pipeline_info,omitempty"`
 }
@@ -189,6 +313,10 @@ func init() {
 	proto.RegisterType((*JobInfos)(nil), "pachyderm.pps.persist.JobInfos")
 	proto.RegisterType((*JobOutput)(nil), "pachyderm.pps.persist.JobOutput")
 	proto.RegisterType((*JobState)(nil), "pachyderm.pps.persist.JobState")
+	proto.RegisterType((*DatumCache)(nil), "pachyderm.pps.persist.DatumCache")
+	proto.RegisterType((*DatumHash)(nil), "pachyderm.pps.persist.DatumHash")
+	proto.RegisterType((*ExportCheckpoint)(nil), "pachyderm.pps.persist.ExportCheckpoint")
+	proto.RegisterType((*ExportedFile)(nil), "pachyderm.pps.persist.ExportedFile")
 	proto.RegisterType((*PipelineInfo)(nil), "pachyderm.pps.persist.PipelineInfo")
 	proto.RegisterType((*PipelineInfos)(nil), "pachyderm.pps.persist.PipelineInfos")
 }
@@ -207,6 +335,9 @@ type APIClient interface {
 	InspectJob(ctx context.Context, in *pachyderm_pps.InspectJobRequest, opts ...grpc.CallOption) (*JobInfo, error)
 	// ordered by time, latest to earliest
 	ListJobInfos(ctx context.Context, in *pachyderm_pps.ListJobRequest, opts ...grpc.CallOption) (*JobInfos, error)
+	// SubscribeJobInfos streams the JobInfos matching the request as they are
+	// now, then each one again whenever it changes.
+	SubscribeJobInfos(ctx context.Context, in *pachyderm_pps.WatchJobsRequest, opts ...grpc.CallOption) (API_SubscribeJobInfosClient, error)
 	// should only be called when rolling back if a Job does not start!
 	DeleteJobInfo(ctx context.Context, in *pachyderm_pps.Job, opts ...grpc.CallOption) (*google_protobuf.Empty, error)
 	// JobOutput rpcs
@@ -219,6 +350,13 @@ type APIClient interface {
 	// ordered by time, latest to earliest
 	ListPipelineInfos(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*PipelineInfos, error)
 	DeletePipelineInfo(ctx context.Context, in *pachyderm_pps.Pipeline, opts ...grpc.CallOption) (*google_protobuf.Empty, error)
+	// DatumCache rpcs
+	// timestamp cannot be set
+	CreateDatumCache(ctx context.Context, in *DatumCache, opts ...grpc.CallOption) (*DatumCache, error)
+	GetDatumCache(ctx context.Context, in *DatumHash, opts ...grpc.CallOption) (*DatumCache, error)
+	// ExportCheckpoint rpcs
+	CreateExportCheckpoint(ctx context.Context, in *ExportCheckpoint, opts ...grpc.CallOption) (*ExportCheckpoint, error)
+	GetExportCheckpoint(ctx context.Context, in *pachyderm_pps.Pipeline, opts ...grpc.CallOption) (*ExportCheckpoint, error)
 }
 
 type aPIClient struct {
@@ -319,6 +457,74 @@ func (c *aPIClient) DeletePipelineInfo(ctx context.Context, in *pachyderm_pps.Pi
 	return out, nil
 }
 
+func (c *aPIClient) CreateDatumCache(ctx context.Context, in *DatumCache, opts ...grpc.CallOption) (*DatumCache, error) {
+	out := new(DatumCache)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.persist.API/CreateDatumCache", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) GetDatumCache(ctx context.Context, in *DatumHash, opts ...grpc.CallOption) (*DatumCache, error) {
+	out := new(DatumCache)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.persist.API/GetDatumCache", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) SubscribeJobInfos(ctx context.Context, in *pachyderm_pps.WatchJobsRequest, opts ...grpc.CallOption) (API_SubscribeJobInfosClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/pachyderm.pps.persist.API/SubscribeJobInfos", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPISubscribeJobInfosClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_SubscribeJobInfosClient interface {
+	Recv() (*JobInfo, error)
+	grpc.ClientStream
+}
+
+type aPISubscribeJobInfosClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPISubscribeJobInfosClient) Recv() (*JobInfo, error) {
+	m := new(JobInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) CreateExportCheckpoint(ctx context.Context, in *ExportCheckpoint, opts ...grpc.CallOption) (*ExportCheckpoint, error) {
+	out := new(ExportCheckpoint)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.persist.API/CreateExportCheckpoint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) GetExportCheckpoint(ctx context.Context, in *pachyderm_pps.Pipeline, opts ...grpc.CallOption) (*ExportCheckpoint, error) {
+	out := new(ExportCheckpoint)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.persist.API/GetExportCheckpoint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for API service
 
 type APIServer interface {
@@ -329,6 +535,9 @@ type APIServer interface {
 	InspectJob(context.Context, *pachyderm_pps.InspectJobRequest) (*JobInfo, error)
 	// ordered by time, latest to earliest
 	ListJobInfos(context.Context, *pachyderm_pps.ListJobRequest) (*JobInfos, error)
+	// SubscribeJobInfos streams the JobInfos matching the request as they are
+	// now, then each one again whenever it changes.
+	SubscribeJobInfos(*pachyderm_pps.WatchJobsRequest, API_SubscribeJobInfosServer) error
 	// should only be called when rolling back if a Job does not start!
 	DeleteJobInfo(context.Context, *pachyderm_pps.Job) (*google_protobuf.Empty, error)
 	// JobOutput rpcs
@@ -341,6 +550,13 @@ type APIServer interface {
 	// ordered by time, latest to earliest
 	ListPipelineInfos(context.Context, *google_protobuf.Empty) (*PipelineInfos, error)
 	DeletePipelineInfo(context.Context, *pachyderm_pps.Pipeline) (*google_protobuf.Empty, error)
+	// DatumCache rpcs
+	// timestamp cannot be set
+	CreateDatumCache(context.Context, *DatumCache) (*DatumCache, error)
+	GetDatumCache(context.Context, *DatumHash) (*DatumCache, error)
+	// ExportCheckpoint rpcs
+	CreateExportCheckpoint(context.Context, *ExportCheckpoint) (*ExportCheckpoint, error)
+	GetExportCheckpoint(context.Context, *pachyderm_pps.Pipeline) (*ExportCheckpoint, error)
 }
 
 func RegisterAPIServer(s *grpc.Server, srv APIServer) {
@@ -467,6 +683,75 @@ func _API_DeletePipelineInfo_Handler(srv interface{}, ctx context.Context, dec f
 	return out, nil
 }
 
+func _API_CreateDatumCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DatumCache)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CreateDatumCache(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_GetDatumCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DatumHash)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).GetDatumCache(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_SubscribeJobInfos_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(pachyderm_pps.WatchJobsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).SubscribeJobInfos(m, &aPISubscribeJobInfosServer{stream})
+}
+
+type API_SubscribeJobInfosServer interface {
+	Send(*JobInfo) error
+	grpc.ServerStream
+}
+
+type aPISubscribeJobInfosServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPISubscribeJobInfosServer) Send(m *JobInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_CreateExportCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ExportCheckpoint)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).CreateExportCheckpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _API_GetExportCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(pachyderm_pps.Pipeline)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(APIServer).GetExportCheckpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _API_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pachyderm.pps.persist.API",
 	HandlerType: (*APIServer)(nil),
@@ -511,6 +796,28 @@ var _API_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeletePipelineInfo",
 			Handler:    _API_DeletePipelineInfo_Handler,
 		},
+		{
+			MethodName: "CreateDatumCache",
+			Handler:    _API_CreateDatumCache_Handler,
+		},
+		{
+			MethodName: "GetDatumCache",
+			Handler:    _API_GetDatumCache_Handler,
+		},
+		{
+			MethodName: "CreateExportCheckpoint",
+			Handler:    _API_CreateExportCheckpoint_Handler,
+		},
+		{
+			MethodName: "GetExportCheckpoint",
+			Handler:    _API_GetExportCheckpoint_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeJobInfos",
+			Handler:       _API_SubscribeJobInfos_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
@@ -21,6 +21,10 @@ import (
 const (
 	jobInfosTable      Table = "JobInfos"
 	pipelineInfosTable Table = "PipelineInfos"
+	datumCachesTable   Table = "DatumCaches"
+	// ExportCheckpoints are kept here rather than in pfs, where committing
+	// them would trigger the pipelines downstream of the repo
+	exportCheckpointsTable Table = "ExportCheckpoints"
 
 	pipelineNameIndex          Index = "PipelineName"
 	pipelineNameAndCommitIndex Index = "PipelineNameAndCommitIndex"
@@ -35,6 +39,8 @@ var (
 	tables = []Table{
 		jobInfosTable,
 		pipelineInfosTable,
+		datumCachesTable,
+		exportCheckpointsTable,
 	}
 
 	tableToTableCreateOpts = map[Table][]gorethink.TableCreateOpts{
@@ -48,6 +54,16 @@ var (
 				PrimaryKey: "PipelineName",
 			},
 		},
+		datumCachesTable: []gorethink.TableCreateOpts{
+			gorethink.TableCreateOpts{
+				PrimaryKey: "Hash",
+			},
+		},
+		exportCheckpointsTable: []gorethink.TableCreateOpts{
+			gorethink.TableCreateOpts{
+				PrimaryKey: "PipelineName",
+			},
+		},
 	}
 )
 
@@ -164,7 +180,9 @@ func (a *rethinkAPIServer) InspectJob(ctx context.Context, request *pps.InspectJ
 		jobInfo,
 		func(jobInfo gorethink.Term) gorethink.Term {
 			blockOutput := jobInfo.HasFields("OutputCommit")
-			blockState := jobInfo.Field("State").Ne(pps.JobState_JOB_STATE_RUNNING)
+			blockState := jobInfo.Field("State").Ne(pps.JobState_JOB_STATE_RUNNING).And(
+				jobInfo.Field("State").Ne(pps.JobState_JOB_STATE_QUEUED),
+			)
 			if request.BlockOutput && request.BlockState {
 				return blockOutput.And(blockState)
 			} else if request.BlockOutput {
@@ -222,6 +240,58 @@ func (a *rethinkAPIServer) ListJobInfos(ctx context.Context, request *pps.ListJo
 	return result, nil
 }
 
+func (a *rethinkAPIServer) SubscribeJobInfos(request *pps.WatchJobsRequest, server persist.API_SubscribeJobInfosServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	query := a.getTerm(jobInfosTable)
+	if request.Job != nil {
+		query = query.Get(request.Job.Id)
+	} else if request.Pipeline != nil {
+		query = query.Filter(func(jobInfo gorethink.Term) gorethink.Term {
+			return jobInfo.Field("PipelineName").Eq(request.Pipeline.Name)
+		})
+	}
+	cursor, err := query.
+		Changes(gorethink.ChangesOpts{IncludeInitial: true}).
+		Field("new_val").
+		// deleted jobs have no new value
+		Filter(func(jobInfo gorethink.Term) gorethink.Term {
+			return jobInfo.Ne(nil)
+		}).
+		Run(a.session)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cursor.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		// Next blocks until the next change, closing the cursor is the only
+		// way to stop it when the client goes away
+		select {
+		case <-server.Context().Done():
+			_ = cursor.Close()
+		case <-done:
+		}
+	}()
+	for {
+		jobInfo := &persist.JobInfo{}
+		if !cursor.Next(jobInfo) {
+			break
+		}
+		if err := server.Send(jobInfo); err != nil {
+			return err
+		}
+	}
+	if err := server.Context().Err(); err != nil {
+		return err
+	}
+	return cursor.Err()
+}
+
 func (a *rethinkAPIServer) DeleteJobInfo(ctx context.Context, request *pps.Job) (response *google_protobuf.Empty, err error) {
 	defer func(start time.Time) { a.Log(request, response, err, time.Since(start)) }(time.Now())
 	if err := a.deleteMessageByPrimaryKey(jobInfosTable, request.Id); err != nil {
@@ -299,9 +369,76 @@ func (a *rethinkAPIServer) DeletePipelineInfo(ctx context.Context, request *pps.
 	if err := a.deleteMessageByPrimaryKey(pipelineInfosTable, request.Name); err != nil {
 		return nil, err
 	}
+	// a pipeline created later with the same name starts exporting afresh
+	if err := a.deleteMessageByPrimaryKey(exportCheckpointsTable, request.Name); err != nil {
+		return nil, err
+	}
 	return google_protobuf.EmptyInstance, nil
 }
 
+// timestamp cannot be set
+func (a *rethinkAPIServer) CreateDatumCache(ctx context.Context, request *persist.DatumCache) (response *persist.DatumCache, err error) {
+	defer func(start time.Time) { a.Log(request, response, err, time.Since(start)) }(time.Now())
+	if request.CreatedAt != nil {
+		return nil, ErrTimestampSet
+	}
+	request.CreatedAt = a.now()
+	if err := a.updateMessage(datumCachesTable, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// returns an empty DatumCache if nothing has been cached for request.Hash
+func (a *rethinkAPIServer) GetDatumCache(ctx context.Context, request *persist.DatumHash) (response *persist.DatumCache, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	cursor, err := a.getTerm(datumCachesTable).Get(request.Hash).Run(a.session)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := cursor.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	datumCache := &persist.DatumCache{}
+	if cursor.IsNil() {
+		return datumCache, nil
+	}
+	cursor.Next(datumCache)
+	return datumCache, cursor.Err()
+}
+
+// replaces the pipeline's checkpoint, rather than merging with it, so that
+// files can be removed from it
+func (a *rethinkAPIServer) CreateExportCheckpoint(ctx context.Context, request *persist.ExportCheckpoint) (response *persist.ExportCheckpoint, err error) {
+	defer func(start time.Time) { a.Log(request, response, err, time.Since(start)) }(time.Now())
+	if _, err := a.getTerm(exportCheckpointsTable).Insert(request, gorethink.InsertOpts{Conflict: "replace"}).RunWrite(a.session); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// returns an empty ExportCheckpoint if the pipeline hasn't exported anything
+func (a *rethinkAPIServer) GetExportCheckpoint(ctx context.Context, request *pps.Pipeline) (response *persist.ExportCheckpoint, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	cursor, err := a.getTerm(exportCheckpointsTable).Get(request.Name).Run(a.session)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := cursor.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	exportCheckpoint := &persist.ExportCheckpoint{PipelineName: request.Name}
+	if cursor.IsNil() {
+		return exportCheckpoint, nil
+	}
+	cursor.Next(exportCheckpoint)
+	return exportCheckpoint, cursor.Err()
+}
+
 func (a *rethinkAPIServer) insertMessage(table Table, message proto.Message) error {
 	_, err := a.getTerm(table).Insert(message).RunWrite(a.session)
 	return err
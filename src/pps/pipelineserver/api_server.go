@@ -2,37 +2,63 @@ package pipelineserver
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/container"
+	"github.com/pachyderm/pachyderm/src/pkg/grpcutil"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"github.com/pachyderm/pachyderm/src/pps/persist"
+	"github.com/pachyderm/pachyderm/src/pps/ppsutil"
 	"go.pedge.io/google-protobuf"
 	"go.pedge.io/proto/rpclog"
+	"go.pedge.io/proto/time"
 	"go.pedge.io/protolog"
 	"golang.org/x/net/context"
 )
 
+const (
+	// tagPollInterval is how often the commits of inputs with a tag filter
+	// which haven't matched it are looked at again.
+	tagPollInterval = 10 * time.Second
+	// untaggedExpiry is how long after it finished an untagged commit stops
+	// being looked at, so inputs which are rarely tagged don't pile them up.
+	untaggedExpiry = 24 * time.Hour
+)
+
 type apiServer struct {
 	protorpclog.Logger
 	pfsAPIClient     pfs.APIClient
 	jobAPIClient     pps.JobAPIClient
 	persistAPIServer persist.APIServer
-	cancelFuncs      map[pps.Pipeline]func()
-	lock             sync.Mutex
+	auditRecorder    audit.Recorder
+	containerClient  container.Client
+	// buildRegistry is the registry images built by containerClient are
+	// pushed to.
+	buildRegistry string
+	cancelFuncs   map[pps.Pipeline]func()
+	lock          sync.Mutex
 }
 
 func newAPIServer(
 	pfsAPIClient pfs.APIClient,
 	jobAPIClient pps.JobAPIClient,
 	persistAPIServer persist.APIServer,
+	auditRecorder audit.Recorder,
+	containerClient container.Client,
+	buildRegistry string,
 ) *apiServer {
 	return &apiServer{
 		protorpclog.NewLogger("pachyderm.pps.PipelineAPI"),
 		pfsAPIClient,
 		jobAPIClient,
 		persistAPIServer,
+		auditRecorder,
+		containerClient,
+		buildRegistry,
 		make(map[pps.Pipeline]func()),
 		sync.Mutex{},
 	}
@@ -56,29 +82,59 @@ func (a *apiServer) Start() error {
 
 func (a *apiServer) CreatePipeline(ctx context.Context, request *pps.CreatePipelineRequest) (response *google_protobuf.Empty, err error) {
 	defer func(start time.Time) { a.Log(request, response, err, time.Since(start)) }(time.Now())
-	if request.Pipeline == nil {
-		return nil, fmt.Errorf("pachyderm.pps.pipelineserver: request.Pipeline cannot be nil")
-	}
-	repoSet := make(map[string]bool)
-	for _, input := range request.Inputs {
-		repoSet[input.Repo.Name] = true
+	defer func() {
+		a.auditRecorder.Record(ctx, "pachyderm.pps.PipelineAPI.CreatePipeline", pipelineRepoName(request.Pipeline), request, err)
+	}()
+	if err := ppsutil.ValidatePipeline(request); err != nil {
+		return nil, fmt.Errorf("pachyderm.pps.pipelineserver: %s", err.Error())
 	}
-	if len(repoSet) < len(request.Inputs) {
-		return nil, fmt.Errorf("pachyderm.pps.pipelineserver: duplicate input repos")
+	transform := request.Transform
+	var imageDigest string
+	if transform != nil && transform.Build != nil {
+		image, err := a.buildImage(request.Pipeline, transform.Build)
+		if err != nil {
+			return nil, err
+		}
+		builtTransform := *transform
+		builtTransform.Image = image
+		transform = &builtTransform
+		imageDigest = image
 	}
+	// builds routinely take longer than the rpc timeout, so it only covers
+	// the rpcs made once the image is built
+	ctx, cancel := grpcutil.WithTimeout(ctx)
+	defer cancel()
 	repo := pps.PipelineRepo(request.Pipeline)
 	persistPipelineInfo := &persist.PipelineInfo{
-		PipelineName: request.Pipeline.Name,
-		Transform:    request.Transform,
-		Shards:       request.Shards,
-		Inputs:       request.Inputs,
-		OutputRepo:   repo,
+		PipelineName:      request.Pipeline.Name,
+		Transform:         transform,
+		Shards:            request.Shards,
+		Inputs:            request.Inputs,
+		OutputRepo:        repo,
+		Namespace:         request.Namespace,
+		MaxConcurrentJobs: request.MaxConcurrentJobs,
+		Priority:          request.Priority,
+		Preemptible:       request.Preemptible,
+		ImageDigest:       imageDigest,
+		ExternalInputs:    request.ExternalInputs,
+		Ingest:            request.Ingest,
+		Export:            request.Export,
 	}
 	if _, err := a.persistAPIServer.CreatePipelineInfo(ctx, persistPipelineInfo); err != nil {
 		return nil, err
 	}
-	if _, err := a.pfsAPIClient.CreateRepo(ctx, &pfs.CreateRepoRequest{Repo: repo}); err != nil {
-		return nil, err
+	// the output repo outlives its pipeline, so a pipeline which is updated by
+	// deleting and recreating it keeps its output
+	repos := []*pfs.Repo{repo}
+	if request.Ingest != nil {
+		repos = append(repos, pps.PipelineIngestRepo(request.Pipeline))
+	}
+	for _, repo := range repos {
+		if _, err := a.pfsAPIClient.InspectRepo(ctx, &pfs.InspectRepoRequest{Repo: repo}); err != nil {
+			if _, err := a.pfsAPIClient.CreateRepo(ctx, &pfs.CreateRepoRequest{Repo: repo}); err != nil {
+				return nil, err
+			}
+		}
 	}
 	go func() {
 		if err := a.runPipeline(newPipelineInfo(persistPipelineInfo)); err != nil {
@@ -113,6 +169,9 @@ func (a *apiServer) ListPipeline(ctx context.Context, request *pps.ListPipelineR
 }
 
 func (a *apiServer) DeletePipeline(ctx context.Context, request *pps.DeletePipelineRequest) (response *google_protobuf.Empty, err error) {
+	defer func() {
+		a.auditRecorder.Record(ctx, "pachyderm.pps.PipelineAPI.DeletePipeline", pipelineRepoName(request.Pipeline), request, err)
+	}()
 	if _, err := a.persistAPIServer.DeletePipelineInfo(ctx, request.Pipeline); err != nil {
 		return nil, err
 	}
@@ -123,15 +182,32 @@ func (a *apiServer) DeletePipeline(ctx context.Context, request *pps.DeletePipel
 	return google_protobuf.EmptyInstance, nil
 }
 
+// pipelineRepoName returns the name of pipeline's output repo, "" if pipeline
+// is nil.
+func pipelineRepoName(pipeline *pps.Pipeline) string {
+	if pipeline == nil {
+		return ""
+	}
+	return pps.PipelineRepo(pipeline).Name
+}
+
 func newPipelineInfo(persistPipelineInfo *persist.PipelineInfo) *pps.PipelineInfo {
 	return &pps.PipelineInfo{
 		Pipeline: &pps.Pipeline{
 			Name: persistPipelineInfo.PipelineName,
 		},
-		Transform:  persistPipelineInfo.Transform,
-		Shards:     persistPipelineInfo.Shards,
-		Inputs:     persistPipelineInfo.Inputs,
-		OutputRepo: persistPipelineInfo.OutputRepo,
+		Transform:         persistPipelineInfo.Transform,
+		Shards:            persistPipelineInfo.Shards,
+		Inputs:            persistPipelineInfo.Inputs,
+		OutputRepo:        persistPipelineInfo.OutputRepo,
+		Namespace:         persistPipelineInfo.Namespace,
+		MaxConcurrentJobs: persistPipelineInfo.MaxConcurrentJobs,
+		Priority:          persistPipelineInfo.Priority,
+		Preemptible:       persistPipelineInfo.Preemptible,
+		ImageDigest:       persistPipelineInfo.ImageDigest,
+		ExternalInputs:    persistPipelineInfo.ExternalInputs,
+		Ingest:            persistPipelineInfo.Ingest,
+		Export:            persistPipelineInfo.Export,
 	}
 }
 
@@ -140,16 +216,32 @@ func (a *apiServer) runPipeline(pipelineInfo *pps.PipelineInfo) error {
 	a.lock.Lock()
 	a.cancelFuncs[*pipelineInfo.Pipeline] = cancel
 	a.lock.Unlock()
+	if pipelineInfo.Ingest != nil {
+		return a.runIngest(ctx, pipelineInfo)
+	}
+	if pipelineInfo.Export != nil {
+		return a.runExport(ctx, pipelineInfo)
+	}
 	repoToLeaves := make(map[string]map[string]bool)
+	// the leaves jobs are run with, the same as repoToLeaves except for
+	// inputs with a tag filter which have only their latest tagged commit
+	repoToInputLeaves := make(map[string]map[string]bool)
 	repoToInput := make(map[string]*pps.PipelineInput)
+	// the commits of inputs with a tag filter which haven't matched it yet
+	// and the last commit of each which did
+	repoToUntagged := make(map[string]map[string]*pfs.CommitInfo)
+	repoToLastTagged := make(map[string]*pfs.Commit)
 	var inputRepos []*pfs.Repo
 	for _, input := range pipelineInfo.Inputs {
 		repoToLeaves[input.Repo.Name] = make(map[string]bool)
+		repoToInputLeaves[input.Repo.Name] = make(map[string]bool)
 		repoToInput[input.Repo.Name] = input
+		repoToUntagged[input.Repo.Name] = make(map[string]*pfs.CommitInfo)
 		inputRepos = append(inputRepos, &pfs.Repo{Name: input.Repo.Name})
 	}
 	for {
 		var fromCommits []*pfs.Commit
+		untagged := false
 		for repo, leaves := range repoToLeaves {
 			for leaf := range leaves {
 				fromCommits = append(
@@ -159,6 +251,7 @@ func (a *apiServer) runPipeline(pipelineInfo *pps.PipelineInfo) error {
 						Id:   leaf,
 					})
 			}
+			untagged = untagged || len(repoToUntagged[repo]) > 0
 		}
 		listCommitRequest := &pfs.ListCommitRequest{
 			Repo:       inputRepos,
@@ -166,82 +259,219 @@ func (a *apiServer) runPipeline(pipelineInfo *pps.PipelineInfo) error {
 			FromCommit: fromCommits,
 			Block:      true,
 		}
-		commitInfos, err := a.pfsAPIClient.ListCommit(ctx, listCommitRequest)
-		if err != nil {
+		// commits are tagged after they finish, so while there are untagged
+		// commits waiting for new ones is cut short to look at them again
+		var listCtx context.Context
+		var listCancel context.CancelFunc
+		if untagged {
+			listCtx, listCancel = context.WithTimeout(ctx, tagPollInterval)
+		} else {
+			listCtx, listCancel = context.WithCancel(ctx)
+		}
+		commitInfos, err := a.pfsAPIClient.ListCommit(listCtx, listCommitRequest)
+		timedOut := listCtx.Err() == context.DeadlineExceeded
+		listCancel()
+		if err != nil && (!timedOut || ctx.Err() != nil) {
 			return err
 		}
-		for _, commitInfo := range commitInfos.CommitInfo {
-			repoToLeaves[commitInfo.Commit.Repo.Name][commitInfo.Commit.Id] = true
-			if commitInfo.ParentCommit != nil {
-				delete(repoToLeaves[commitInfo.ParentCommit.Repo.Name], commitInfo.ParentCommit.Id)
-			}
-			// generate all the pemrutations of leaves we could use this commit with
-			commitSets := [][]*pfs.Commit{[]*pfs.Commit{}}
-			for repoName, leaves := range repoToLeaves {
-				if repoName == commitInfo.Commit.Repo.Name {
-					continue
-				}
-				var newCommitSets [][]*pfs.Commit
-				for _, commitSet := range commitSets {
-					for leaf := range leaves {
-						newCommitSet := make([]*pfs.Commit, len(commitSet)+1)
-						copy(newCommitSet, commitSet)
-						newCommitSet[len(commitSet)] = &pfs.Commit{
-							Repo: &pfs.Repo{Name: repoName},
-							Id:   leaf,
-						}
-						newCommitSets = append(newCommitSets, newCommitSet)
-					}
+		var triggers []*pfs.CommitInfo
+		var parents []*pfs.Commit
+		if err == nil {
+			for _, commitInfo := range commitInfos.CommitInfo {
+				repoToLeaves[commitInfo.Commit.Repo.Name][commitInfo.Commit.Id] = true
+				if commitInfo.ParentCommit != nil {
+					delete(repoToLeaves[commitInfo.ParentCommit.Repo.Name], commitInfo.ParentCommit.Id)
 				}
-				commitSets = newCommitSets
-			}
-			for _, commitSet := range commitSets {
-				// + 1 as the commitSet doesn't contain the commit we just got
-				if len(commitSet)+1 < len(pipelineInfo.Inputs) {
+				if repoToInput[commitInfo.Commit.Repo.Name].Tag != "" {
+					repoToUntagged[commitInfo.Commit.Repo.Name][commitInfo.Commit.Id] = commitInfo
 					continue
 				}
-				var parentJob *pps.Job
+				repoToInputLeaves[commitInfo.Commit.Repo.Name][commitInfo.Commit.Id] = true
 				if commitInfo.ParentCommit != nil {
-					parentJob, err = a.parentJob(ctx, pipelineInfo, append(commitSet, commitInfo.ParentCommit), commitInfo)
-					if err != nil {
-						return err
-					}
-				}
-				var inputs []*pps.JobInput
-				for _, commit := range append(commitSet, commitInfo.Commit) {
-					inputs = append(inputs, &pps.JobInput{
-						Commit: commit,
-						Reduce: repoToInput[commit.Repo.Name].Reduce,
-					})
+					delete(repoToInputLeaves[commitInfo.ParentCommit.Repo.Name], commitInfo.ParentCommit.Id)
 				}
-				if _, err = a.jobAPIClient.CreateJob(
-					ctx,
-					&pps.CreateJobRequest{
-						Transform: pipelineInfo.Transform,
-						Pipeline:  pipelineInfo.Pipeline,
-						Shards:    pipelineInfo.Shards,
-						Inputs:    inputs,
-						ParentJob: parentJob,
-					},
-				); err != nil {
-					return err
+				triggers = append(triggers, commitInfo)
+				parents = append(parents, commitInfo.ParentCommit)
+			}
+		}
+		for repo, repoUntagged := range repoToUntagged {
+			tagged, err := a.taggedCommits(ctx, repoToInput[repo].Tag, repoUntagged)
+			if err != nil {
+				return err
+			}
+			pruneUntagged(repoUntagged, tagged, time.Now())
+			if len(tagged) == 0 {
+				continue
+			}
+			for _, commitInfo := range tagged {
+				repoToInputLeaves[repo] = map[string]bool{commitInfo.Commit.Id: true}
+				triggers = append(triggers, commitInfo)
+				parents = append(parents, repoToLastTagged[repo])
+				repoToLastTagged[repo] = commitInfo.Commit
+			}
+		}
+		for i, commitInfo := range triggers {
+			if err := a.triggerJobs(ctx, pipelineInfo, repoToInputLeaves, repoToInput, commitInfo, parents[i]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// taggedCommits returns the commits in untagged which now have a tag matching
+// pattern, oldest first.
+func (a *apiServer) taggedCommits(ctx context.Context, pattern string, untagged map[string]*pfs.CommitInfo) ([]*pfs.CommitInfo, error) {
+	var result []*pfs.CommitInfo
+	for _, untaggedCommitInfo := range untagged {
+		inspectCtx, cancel := grpcutil.WithTimeout(ctx)
+		commitInfo, err := a.pfsAPIClient.InspectCommit(inspectCtx, &pfs.InspectCommitRequest{Commit: untaggedCommitInfo.Commit})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if ppsutil.MatchTag(pattern, commitInfo.Tags) {
+			result = append(result, commitInfo)
+		}
+	}
+	sort.Sort(commitInfosByStarted(result))
+	return result, nil
+}
+
+// pruneUntagged removes the commits which are no longer waited on from
+// untagged: the ones tagged, which are oldest first, and the ones started
+// before the latest of them, since commits which are tagged after a later
+// commit of the input has triggered jobs are ignored, as well as the ones
+// which finished more than untaggedExpiry before now.
+func pruneUntagged(untagged map[string]*pfs.CommitInfo, tagged []*pfs.CommitInfo, now time.Time) {
+	var latest time.Time
+	if len(tagged) > 0 {
+		latest = prototime.TimestampToTime(tagged[len(tagged)-1].Started)
+	}
+	for id, commitInfo := range untagged {
+		if !prototime.TimestampToTime(commitInfo.Started).After(latest) ||
+			prototime.TimestampToTime(commitInfo.Finished).Before(now.Add(-untaggedExpiry)) {
+			delete(untagged, id)
+		}
+	}
+}
+
+// triggerJobs creates a job for commitInfo with each combination of the
+// leaves of the pipeline's other inputs. parentCommit is the commit of
+// commitInfo's input whose job is the new jobs' parent.
+func (a *apiServer) triggerJobs(
+	ctx context.Context,
+	pipelineInfo *pps.PipelineInfo,
+	repoToLeaves map[string]map[string]bool,
+	repoToInput map[string]*pps.PipelineInput,
+	commitInfo *pfs.CommitInfo,
+	parentCommit *pfs.Commit,
+) error {
+	// jobs triggered by this commit join the run of the job which
+	// output it
+	runID, err := a.runID(ctx, commitInfo.Commit)
+	if err != nil {
+		return err
+	}
+	// generate all the pemrutations of leaves we could use this commit with
+	commitSets := [][]*pfs.Commit{[]*pfs.Commit{}}
+	for repoName, leaves := range repoToLeaves {
+		if repoName == commitInfo.Commit.Repo.Name {
+			continue
+		}
+		var newCommitSets [][]*pfs.Commit
+		for _, commitSet := range commitSets {
+			for leaf := range leaves {
+				newCommitSet := make([]*pfs.Commit, len(commitSet)+1)
+				copy(newCommitSet, commitSet)
+				newCommitSet[len(commitSet)] = &pfs.Commit{
+					Repo: &pfs.Repo{Name: repoName},
+					Id:   leaf,
 				}
+				newCommitSets = append(newCommitSets, newCommitSet)
+			}
+		}
+		commitSets = newCommitSets
+	}
+	for _, commitSet := range commitSets {
+		// + 1 as the commitSet doesn't contain the commit we just got
+		if len(commitSet)+1 < len(pipelineInfo.Inputs) {
+			continue
+		}
+		var parentJob *pps.Job
+		if parentCommit != nil {
+			parentJob, err = a.parentJob(ctx, pipelineInfo, append(commitSet, parentCommit))
+			if err != nil {
+				return err
 			}
 		}
+		var inputs []*pps.JobInput
+		for _, commit := range append(commitSet, commitInfo.Commit) {
+			inputs = append(inputs, &pps.JobInput{
+				Commit:    commit,
+				Reduce:    repoToInput[commit.Repo.Name].Reduce,
+				Directory: repoToInput[commit.Repo.Name].Directory,
+			})
+		}
+		// waiting for commits blocks for as long as it takes but
+		// creating the job mustn't
+		createCtx, createCancel := grpcutil.WithTimeout(ctx)
+		_, err = a.jobAPIClient.CreateJob(
+			createCtx,
+			&pps.CreateJobRequest{
+				Transform:      pipelineInfo.Transform,
+				Pipeline:       pipelineInfo.Pipeline,
+				Shards:         pipelineInfo.Shards,
+				Inputs:         inputs,
+				ParentJob:      parentJob,
+				RunId:          runID,
+				ExternalInputs: pipelineInfo.ExternalInputs,
+			},
+		)
+		createCancel()
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// runID returns the run of the job which output commit, "" if commit wasn't
+// output by a pipeline.
+func (a *apiServer) runID(ctx context.Context, commit *pfs.Commit) (string, error) {
+	pipeline, ok := pps.RepoPipeline(commit.Repo)
+	if !ok {
+		return "", nil
+	}
+	ctx, cancel := grpcutil.WithTimeout(ctx)
+	defer cancel()
+	jobInfos, err := a.jobAPIClient.ListJob(
+		ctx,
+		&pps.ListJobRequest{
+			Pipeline: pipeline,
+		})
+	if err != nil {
+		return "", err
+	}
+	for _, jobInfo := range jobInfos.JobInfo {
+		if jobInfo.OutputCommit != nil && jobInfo.OutputCommit.Id == commit.Id {
+			return jobInfo.RunId, nil
+		}
+	}
+	return "", nil
 }
 
 func (a *apiServer) parentJob(
 	ctx context.Context,
 	pipelineInfo *pps.PipelineInfo,
-	commitSet []*pfs.Commit,
-	newCommit *pfs.CommitInfo,
+	inputCommits []*pfs.Commit,
 ) (*pps.Job, error) {
+	ctx, cancel := grpcutil.WithTimeout(ctx)
+	defer cancel()
 	jobInfo, err := a.jobAPIClient.ListJob(
 		ctx,
 		&pps.ListJobRequest{
 			Pipeline:    pipelineInfo.Pipeline,
-			InputCommit: append(commitSet, newCommit.ParentCommit),
+			InputCommit: inputCommits,
 		})
 	if err != nil {
 		return nil, err
@@ -251,3 +481,11 @@ func (a *apiServer) parentJob(
 	}
 	return jobInfo.JobInfo[0].Job, nil
 }
+
+type commitInfosByStarted []*pfs.CommitInfo
+
+func (s commitInfosByStarted) Len() int          { return len(s) }
+func (s commitInfosByStarted) Swap(i int, j int) { s[i], s[j] = s[j], s[i] }
+func (s commitInfosByStarted) Less(i int, j int) bool {
+	return prototime.TimestampToTime(s[i].Started).Before(prototime.TimestampToTime(s[j].Started))
+}
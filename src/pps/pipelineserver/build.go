@@ -0,0 +1,87 @@
+package pipelineserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/container"
+	"github.com/pachyderm/pachyderm/src/pps"
+)
+
+// buildImage builds an image from the build context in pfs and pushes it to
+// the build registry, it returns the name of the image pinned to the digest
+// the registry stored it under.
+func (a *apiServer) buildImage(pipeline *pps.Pipeline, build *pfs.File) (_ string, retErr error) {
+	if a.containerClient == nil {
+		return "", fmt.Errorf("pachyderm.pps.pipelineserver: image builds are disabled")
+	}
+	contextDir, err := ioutil.TempDir("", "pachyderm-build")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := os.RemoveAll(contextDir); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	if err := a.getBuildContext(build, contextDir); err != nil {
+		return "", err
+	}
+	repository := fmt.Sprintf("%s/pachyderm-pipeline-%s", a.buildRegistry, strings.ToLower(pipeline.Name))
+	imageName := fmt.Sprintf("%s:%s", repository, build.Commit.Id)
+	if err := a.containerClient.Build(imageName, contextDir, container.BuildOptions{}); err != nil {
+		return "", err
+	}
+	// the image is only on this node until it's pushed, jobs run on any node
+	digest, err := a.containerClient.Push(imageName, container.PushOptions{})
+	if err != nil {
+		return "", err
+	}
+	// jobs run exactly what was built, even if the tag is pushed again
+	return fmt.Sprintf("%s@%s", repository, digest), nil
+}
+
+// getBuildContext copies the directory file, and everything under it, from pfs
+// into dir.
+func (a *apiServer) getBuildContext(file *pfs.File, dir string) error {
+	fileInfos, err := pfsutil.ListFile(a.pfsAPIClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, nil)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		filePath := filepath.Join(dir, path.Base(fileInfo.File.Path))
+		switch fileInfo.FileType {
+		case pfs.FileType_FILE_TYPE_DIR:
+			if err := os.Mkdir(filePath, 0777); err != nil {
+				return err
+			}
+			if err := a.getBuildContext(fileInfo.File, filePath); err != nil {
+				return err
+			}
+		case pfs.FileType_FILE_TYPE_REGULAR:
+			if err := a.getBuildContextFile(fileInfo.File, filePath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *apiServer) getBuildContextFile(file *pfs.File, filePath string) (retErr error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	return pfsutil.GetFile(a.pfsAPIClient, file.Commit.Repo.Name, file.Commit.Id, file.Path, 0, 0, nil, f)
+}
@@ -0,0 +1,189 @@
+package pipelineserver
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/export"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"github.com/pachyderm/pachyderm/src/pps/persist"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	// how long an export pipeline waits before retrying a commit it failed
+	// to export
+	exportRetryInterval = time.Minute
+	// how many rows are written to the sink between checkpoints
+	exportBatchSize = 500
+)
+
+// runExport writes the rows added by each commit to pipelineInfo's input to
+// its export's sink, until ctx is cancelled by deleting the pipeline. How far
+// the export has got is checkpointed in the persist store after each batch of
+// rows, so that a retried commit, or one being exported when ppsd restarts,
+// carries on from the last batch written rather than relying on the sink to
+// drop the rows it already has.
+func (a *apiServer) runExport(ctx context.Context, pipelineInfo *pps.PipelineInfo) error {
+	sink, err := export.NewSinkFromURL(ctx, pipelineInfo.Export.Url)
+	if err != nil {
+		return err
+	}
+	repoName := pipelineInfo.Inputs[0].Repo.Name
+	checkpoint, err := a.persistAPIServer.GetExportCheckpoint(ctx, pipelineInfo.Pipeline)
+	if err != nil {
+		return err
+	}
+	exported := make(map[string]bool)
+	for _, commitID := range checkpoint.ExportedCommitIds {
+		exported[commitID] = true
+	}
+	leaves := make(map[string]bool)
+	for {
+		var fromCommits []*pfs.Commit
+		for leaf := range leaves {
+			fromCommits = append(fromCommits, pfsutil.NewCommit(repoName, leaf))
+		}
+		commitInfos, err := a.pfsAPIClient.ListCommit(
+			ctx,
+			&pfs.ListCommitRequest{
+				Repo:       []*pfs.Repo{pfsutil.NewRepo(repoName)},
+				CommitType: pfs.CommitType_COMMIT_TYPE_READ,
+				FromCommit: fromCommits,
+				Block:      true,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		for _, commitInfo := range commitInfos.CommitInfo {
+			leaves[commitInfo.Commit.Id] = true
+			if commitInfo.ParentCommit != nil {
+				delete(leaves, commitInfo.ParentCommit.Id)
+			}
+			if exported[commitInfo.Commit.Id] {
+				continue
+			}
+			// a commit which can't be exported holds up the ones after it
+			// rather than being skipped, so no rows are lost
+			for {
+				err := a.exportDir(ctx, sink, checkpoint, repoName, commitInfo, "")
+				if err == nil {
+					finished := &persist.ExportCheckpoint{
+						PipelineName:      checkpoint.PipelineName,
+						ExportedCommitIds: append(checkpoint.ExportedCommitIds, commitInfo.Commit.Id),
+					}
+					if _, err = a.persistAPIServer.CreateExportCheckpoint(ctx, finished); err == nil {
+						checkpoint = finished
+						break
+					}
+				}
+				protolog.Printf("error exporting %s@%s to %s: %s", repoName, commitInfo.Commit.Id, pipelineInfo.Export.Url, err.Error())
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(exportRetryInterval):
+				}
+			}
+			exported[commitInfo.Commit.Id] = true
+		}
+	}
+}
+
+// exportDir writes the rows commitInfo added to the files under dir to sink,
+// skipping the ones checkpoint says have been written. Files can only be
+// appended to, so the rows a commit added are the ones after the end of the
+// file in its parent.
+func (a *apiServer) exportDir(ctx context.Context, sink export.Sink, checkpoint *persist.ExportCheckpoint, repoName string, commitInfo *pfs.CommitInfo, dir string) error {
+	commitID := commitInfo.Commit.Id
+	fileInfos, err := pfsutil.ListFile(a.pfsAPIClient, repoName, commitID, dir, nil)
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		filePath := fileInfo.File.Path
+		switch fileInfo.FileType {
+		case pfs.FileType_FILE_TYPE_DIR:
+			if err := a.exportDir(ctx, sink, checkpoint, repoName, commitInfo, filePath); err != nil {
+				return err
+			}
+			continue
+		case pfs.FileType_FILE_TYPE_REGULAR:
+		default:
+			continue
+		}
+		if fileInfo.CommitModified != nil && fileInfo.CommitModified.Id != commitID {
+			continue
+		}
+		var offset int64
+		if commitInfo.ParentCommit != nil {
+			parentFileInfo, err := pfsutil.InspectFile(a.pfsAPIClient, repoName, commitInfo.ParentCommit.Id, filePath, nil)
+			if err != nil && grpc.ErrorDesc(err) != pfs.ErrFileNotFound.Error() {
+				return err
+			}
+			if err == nil {
+				offset = int64(parentFileInfo.SizeBytes)
+			}
+		}
+		if checkpoint.CommitId == commitID {
+			for _, exportedFile := range checkpoint.Files {
+				if exportedFile.Path == filePath && exportedFile.Offset > offset {
+					offset = exportedFile.Offset
+				}
+			}
+		}
+		if offset >= int64(fileInfo.SizeBytes) {
+			continue
+		}
+		var buffer bytes.Buffer
+		if err := pfsutil.GetFile(a.pfsAPIClient, repoName, commitID, filePath, offset, 0, nil, &buffer); err != nil {
+			return err
+		}
+		rows, err := export.ReadRows(&buffer, fmt.Sprintf("%s%s@", commitID, path.Clean("/"+filePath)), offset)
+		if err != nil {
+			return fmt.Errorf("%s: %s", filePath, err.Error())
+		}
+		for len(rows) > 0 {
+			n := len(rows)
+			if n > exportBatchSize {
+				n = exportBatchSize
+			}
+			if err := sink.Write(rows[:n]); err != nil {
+				return err
+			}
+			if err := a.checkpointExport(ctx, checkpoint, commitID, filePath, rows[n-1].End); err != nil {
+				return err
+			}
+			rows = rows[n:]
+		}
+	}
+	return nil
+}
+
+// checkpointExport records that the rows of filePath in commitID before
+// offset have been written.
+func (a *apiServer) checkpointExport(ctx context.Context, checkpoint *persist.ExportCheckpoint, commitID string, filePath string, offset int64) error {
+	if checkpoint.CommitId != commitID {
+		checkpoint.CommitId = commitID
+		checkpoint.Files = nil
+	}
+	var exportedFile *persist.ExportedFile
+	for _, candidate := range checkpoint.Files {
+		if candidate.Path == filePath {
+			exportedFile = candidate
+		}
+	}
+	if exportedFile == nil {
+		exportedFile = &persist.ExportedFile{Path: filePath}
+		checkpoint.Files = append(checkpoint.Files, exportedFile)
+	}
+	exportedFile.Offset = offset
+	_, err := a.persistAPIServer.CreateExportCheckpoint(ctx, checkpoint)
+	return err
+}
@@ -0,0 +1,206 @@
+package pipelineserver
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pachyderm/pachyderm/src/pfs/pfsutil"
+	"github.com/pachyderm/pachyderm/src/pkg/obj"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/proto/time"
+	"go.pedge.io/protolog"
+	"golang.org/x/net/context"
+)
+
+// runIngest imports the objects under the url of pipelineInfo's ingest into
+// its repo every interval, until ctx is cancelled by deleting the pipeline.
+func (a *apiServer) runIngest(ctx context.Context, pipelineInfo *pps.PipelineInfo) error {
+	client, prefix, err := obj.NewClientFromURL(ctx, pipelineInfo.Ingest.Url)
+	if err != nil {
+		return err
+	}
+	interval := prototime.DurationFromProto(pipelineInfo.Ingest.Interval)
+	for {
+		// a failed pass leaves no commits behind, the next pass retries it
+		if err := a.ingest(pipelineInfo.Pipeline, client, prefix); err != nil {
+			protolog.Printf("error ingesting %s into pipeline %s: %s", pipelineInfo.Ingest.Url, pipelineInfo.Pipeline.Name, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ingest makes one pass over the objects under prefix. The objects which are
+// new, or have been appended to, since the last pass are imported into a
+// commit of pipeline's repo. Every object's hash is appended to the
+// checkpoint so that objects which haven't changed aren't read again.
+func (a *apiServer) ingest(pipeline *pps.Pipeline, client obj.Client, prefix string) error {
+	repoName := pps.PipelineRepo(pipeline).Name
+	checkpointRepoName := pps.PipelineIngestRepo(pipeline).Name
+	parentID, err := a.lastCommitID(repoName)
+	if err != nil {
+		return err
+	}
+	checkpointParentID, err := a.lastCommitID(checkpointRepoName)
+	if err != nil {
+		return err
+	}
+	hashes, err := a.readCheckpoint(checkpointRepoName, checkpointParentID)
+	if err != nil {
+		return err
+	}
+	var objects []*obj.Object
+	if err := client.Walk(prefix, func(object *obj.Object) error {
+		// objects without a hash are checked against pfs on every pass
+		if object.Hash == "" || hashes[object.Name] != object.Hash {
+			objects = append(objects, object)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	commit, err := pfsutil.StartCommit(a.pfsAPIClient, repoName, parentID)
+	if err != nil {
+		return err
+	}
+	var checkpoint bytes.Buffer
+	var imported int
+	if err := func() error {
+		for _, object := range objects {
+			filePath := strings.TrimPrefix(strings.TrimPrefix(object.Name, prefix), "/")
+			// "directory" placeholder objects aren't files
+			if filePath == "" || strings.HasSuffix(filePath, "/") {
+				continue
+			}
+			changed, err := a.ingestObject(client, object, repoName, parentID, commit.Id, filePath)
+			if err != nil {
+				return err
+			}
+			if changed {
+				imported++
+			}
+			if object.Hash != "" {
+				fmt.Fprintf(&checkpoint, "%s %s\n", object.Hash, strconv.Quote(object.Name))
+			}
+		}
+		if imported == 0 {
+			// an empty commit would trigger the pipelines downstream
+			return fmt.Errorf("nothing to import")
+		}
+		if _, err := pfsutil.FinishCommit(a.pfsAPIClient, repoName, commit.Id); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		_ = pfsutil.ForceFinishCommit(a.pfsAPIClient, repoName, commit.Id)
+		_ = pfsutil.DeleteCommit(a.pfsAPIClient, repoName, commit.Id)
+		if imported > 0 {
+			return err
+		}
+	} else {
+		protolog.Printf("ingested %d objects into %s@%s", imported, repoName, commit.Id)
+	}
+	if checkpoint.Len() == 0 {
+		return nil
+	}
+	// the output commit is finished before the checkpoint, a pass which
+	// fails in between finds the objects already in pfs next time
+	checkpointCommit, err := pfsutil.StartCommit(a.pfsAPIClient, checkpointRepoName, checkpointParentID)
+	if err != nil {
+		return err
+	}
+	if _, err := pfsutil.PutFile(a.pfsAPIClient, checkpointRepoName, checkpointCommit.Id, pps.IngestCheckpointPath, 0, &checkpoint); err != nil {
+		_ = pfsutil.ForceFinishCommit(a.pfsAPIClient, checkpointRepoName, checkpointCommit.Id)
+		_ = pfsutil.DeleteCommit(a.pfsAPIClient, checkpointRepoName, checkpointCommit.Id)
+		return err
+	}
+	if _, err := pfsutil.FinishCommit(a.pfsAPIClient, checkpointRepoName, checkpointCommit.Id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ingestObject imports object to filePath in commitID, sending only what was
+// appended to it if it's already in parentID. It returns false if there's
+// nothing to import, because the object's content hasn't changed or it was
+// rewritten, which can't be imported since pfs files can only be appended
+// to.
+func (a *apiServer) ingestObject(client obj.Client, object *obj.Object, repoName string, parentID string, commitID string, filePath string) (_ bool, retErr error) {
+	reader, err := client.Reader(object.Name)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	var size int64
+	prefix := true
+	if parentID != "" {
+		size, prefix, err = pfsutil.FilePrefix(a.pfsAPIClient, repoName, parentID, filePath, reader)
+		if err != nil {
+			return false, err
+		}
+	}
+	switch {
+	case !prefix || size > object.Size:
+		protolog.Printf("%s was rewritten, %s keeps its old content since pfs files can only be appended to", object.Name, filePath)
+		return false, nil
+	case size == object.Size:
+		return false, nil
+	}
+	// FilePrefix has read reader up to where the object was appended to
+	if _, err := pfsutil.PutFile(a.pfsAPIClient, repoName, commitID, filePath, 0, reader); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lastCommitID returns the most recently finished commit of repoName, "" if
+// it has none.
+func (a *apiServer) lastCommitID(repoName string) (string, error) {
+	repoInfo, err := pfsutil.InspectRepo(a.pfsAPIClient, repoName)
+	if err != nil {
+		return "", err
+	}
+	if repoInfo.LastCommit == nil {
+		return "", nil
+	}
+	return repoInfo.LastCommit.Id, nil
+}
+
+// readCheckpoint returns the hash each object had when it was last imported,
+// by name. The checkpoint is a line of "hash quoted-name" per import, later
+// lines win.
+func (a *apiServer) readCheckpoint(repoName string, commitID string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if commitID == "" {
+		return hashes, nil
+	}
+	var buffer bytes.Buffer
+	if err := pfsutil.GetFile(a.pfsAPIClient, repoName, commitID, pps.IngestCheckpointPath, 0, 0, nil, &buffer); err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(buffer.String(), "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, err := strconv.Unquote(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed checkpoint line: %s", line)
+		}
+		hashes[name] = parts[0]
+	}
+	return hashes, nil
+}
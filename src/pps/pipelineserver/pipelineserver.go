@@ -2,6 +2,8 @@ package pipelineserver
 
 import (
 	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/audit"
+	"github.com/pachyderm/pachyderm/src/pkg/container"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"github.com/pachyderm/pachyderm/src/pps/persist"
 )
@@ -11,14 +13,23 @@ type APIServer interface {
 	Start() error
 }
 
+// NewAPIServer returns an APIServer, images of pipelines with a build context
+// are built with containerClient, which may be nil to disable builds, and
+// pushed to buildRegistry.
 func NewAPIServer(
 	pfsAPIClient pfs.APIClient,
 	jobAPIClient pps.JobAPIClient,
 	persistAPIServer persist.APIServer,
+	auditRecorder audit.Recorder,
+	containerClient container.Client,
+	buildRegistry string,
 ) APIServer {
 	return newAPIServer(
 		pfsAPIClient,
 		jobAPIClient,
 		persistAPIServer,
+		auditRecorder,
+		containerClient,
+		buildRegistry,
 	)
 }
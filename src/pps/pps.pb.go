@@ -10,18 +10,39 @@ It is generated from these files:
 
 It has these top-level messages:
 	Transform
+	Resources
+	Autoscaling
+	Sidecar
 	Job
 	JobInput
+	JobStats
 	JobInfo
 	JobInfos
+	JobManifest
 	Pipeline
 	PipelineInput
+	ExternalInput
+	Ingest
+	Export
 	PipelineInfo
 	PipelineInfos
 	CreateJobRequest
 	InspectJobRequest
+	DebugJobRequest
+	DebugJobResponse
 	ListJobRequest
+	WatchJobsRequest
 	CreatePipelineRequest
+	ListQueueRequest
+	RequeueJobRequest
+	InspectLineageRequest
+	LineageNode
+	Lineage
+	FlushCommitRequest
+	FlushCommitResponse
+	ListRunRequest
+	RunInfo
+	RunInfos
 	InspectPipelineRequest
 	ListPipelineRequest
 	DeletePipelineRequest
@@ -36,6 +57,7 @@ import fmt "fmt"
 import math "math"
 import google_protobuf "go.pedge.io/google-protobuf"
 import google_protobuf1 "go.pedge.io/google-protobuf"
+import google_protobuf2 "go.pedge.io/google-protobuf"
 import pfs "github.com/pachyderm/pachyderm/src/pfs"
 import fuse "github.com/pachyderm/pachyderm/src/pfs/fuse"
 
@@ -55,17 +77,20 @@ const (
 	JobState_JOB_STATE_RUNNING JobState = 0
 	JobState_JOB_STATE_FAILURE JobState = 1
 	JobState_JOB_STATE_SUCCESS JobState = 2
+	JobState_JOB_STATE_QUEUED  JobState = 3
 )
 
 var JobState_name = map[int32]string{
 	0: "JOB_STATE_RUNNING",
 	1: "JOB_STATE_FAILURE",
 	2: "JOB_STATE_SUCCESS",
+	3: "JOB_STATE_QUEUED",
 }
 var JobState_value = map[string]int32{
 	"JOB_STATE_RUNNING": 0,
 	"JOB_STATE_FAILURE": 1,
 	"JOB_STATE_SUCCESS": 2,
+	"JOB_STATE_QUEUED":  3,
 }
 
 func (x JobState) String() string {
@@ -73,15 +98,130 @@ func (x JobState) String() string {
 }
 
 type Transform struct {
-	Image string   `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
-	Cmd   []string `protobuf:"bytes,2,rep,name=cmd" json:"cmd,omitempty"`
-	Stdin string   `protobuf:"bytes,3,opt,name=stdin" json:"stdin,omitempty"`
+	Image        string                     `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	Cmd          []string                   `protobuf:"bytes,2,rep,name=cmd" json:"cmd,omitempty"`
+	Stdin        string                     `protobuf:"bytes,3,opt,name=stdin" json:"stdin,omitempty"`
+	Resources    *Resources                 `protobuf:"bytes,4,opt,name=resources" json:"resources,omitempty"`
+	Build        *pfs.File                  `protobuf:"bytes,5,opt,name=build" json:"build,omitempty"`
+	Env          map[string]string          `protobuf:"bytes,6,rep,name=env" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	JobTimeout   *google_protobuf2.Duration `protobuf:"bytes,7,opt,name=job_timeout" json:"job_timeout,omitempty"`
+	DatumTimeout *google_protobuf2.Duration `protobuf:"bytes,8,opt,name=datum_timeout" json:"datum_timeout,omitempty"`
+	Sidecars     []*Sidecar                 `protobuf:"bytes,9,rep,name=sidecars" json:"sidecars,omitempty"`
+	// speculation_threshold runs a shard again on another pod once it's been
+	// running for this many times the median run time of the job's finished
+	// shards, whichever run finishes first is kept. Stragglers are looked for
+	// once half of the shards have finished, 0 turns speculation off.
+	SpeculationThreshold float64 `protobuf:"fixed64,10,opt,name=speculation_threshold" json:"speculation_threshold,omitempty"`
+	// autoscaling runs fewer of a job's shards at once than it has, if it's
+	// unset all of them run at once
+	Autoscaling *Autoscaling `protobuf:"bytes,11,opt,name=autoscaling" json:"autoscaling,omitempty"`
 }
 
 func (m *Transform) Reset()         { *m = Transform{} }
 func (m *Transform) String() string { return proto.CompactTextString(m) }
 func (*Transform) ProtoMessage()    {}
 
+func (m *Transform) GetResources() *Resources {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+func (m *Transform) GetBuild() *pfs.File {
+	if m != nil {
+		return m.Build
+	}
+	return nil
+}
+
+func (m *Transform) GetEnv() map[string]string {
+	if m != nil {
+		return m.Env
+	}
+	return nil
+}
+
+func (m *Transform) GetJobTimeout() *google_protobuf2.Duration {
+	if m != nil {
+		return m.JobTimeout
+	}
+	return nil
+}
+
+func (m *Transform) GetDatumTimeout() *google_protobuf2.Duration {
+	if m != nil {
+		return m.DatumTimeout
+	}
+	return nil
+}
+
+func (m *Transform) GetSidecars() []*Sidecar {
+	if m != nil {
+		return m.Sidecars
+	}
+	return nil
+}
+
+func (m *Transform) GetAutoscaling() *Autoscaling {
+	if m != nil {
+		return m.Autoscaling
+	}
+	return nil
+}
+
+// Resources constrains where a transform's containers can be placed. They're
+// passed to kubernetes as the pods' nodeSelector and nvidia-gpu limit, which
+// does the placement, shard servers don't advertise resources.
+type Resources struct {
+	Gpus         uint64            `protobuf:"varint,1,opt,name=gpus" json:"gpus,omitempty"`
+	NodeSelector map[string]string `protobuf:"bytes,2,rep,name=node_selector" json:"node_selector,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Resources) Reset()         { *m = Resources{} }
+func (m *Resources) String() string { return proto.CompactTextString(m) }
+func (*Resources) ProtoMessage()    {}
+
+func (m *Resources) GetNodeSelector() map[string]string {
+	if m != nil {
+		return m.NodeSelector
+	}
+	return nil
+}
+
+// Autoscaling bounds the workers, pods, which run a job's shards. The
+// workers are scaled with the job's backlog, its unfinished shards, so that
+// big commits get more of them, and scaled back down as the shards finish.
+type Autoscaling struct {
+	MinWorkers uint64 `protobuf:"varint,1,opt,name=min_workers" json:"min_workers,omitempty"`
+	MaxWorkers uint64 `protobuf:"varint,2,opt,name=max_workers" json:"max_workers,omitempty"`
+	// the backlog each worker is sized for, 1 if unset
+	ShardsPerWorker uint64 `protobuf:"varint,3,opt,name=shards_per_worker" json:"shards_per_worker,omitempty"`
+}
+
+func (m *Autoscaling) Reset()         { *m = Autoscaling{} }
+func (m *Autoscaling) String() string { return proto.CompactTextString(m) }
+func (*Autoscaling) ProtoMessage()    {}
+
+// Sidecar is a container which runs alongside a transform.
+type Sidecar struct {
+	Name  string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Image string            `protobuf:"bytes,2,opt,name=image" json:"image,omitempty"`
+	Cmd   []string          `protobuf:"bytes,3,rep,name=cmd" json:"cmd,omitempty"`
+	Env   map[string]string `protobuf:"bytes,4,rep,name=env" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Sidecar) Reset()         { *m = Sidecar{} }
+func (m *Sidecar) String() string { return proto.CompactTextString(m) }
+func (*Sidecar) ProtoMessage()    {}
+
+func (m *Sidecar) GetEnv() map[string]string {
+	if m != nil {
+		return m.Env
+	}
+	return nil
+}
+
 type Job struct {
 	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 }
@@ -91,8 +231,9 @@ func (m *Job) String() string { return proto.CompactTextString(m) }
 func (*Job) ProtoMessage()    {}
 
 type JobInput struct {
-	Commit *pfs.Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
-	Reduce bool        `protobuf:"varint,2,opt,name=reduce" json:"reduce,omitempty"`
+	Commit    *pfs.Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Reduce    bool        `protobuf:"varint,2,opt,name=reduce" json:"reduce,omitempty"`
+	Directory bool        `protobuf:"varint,3,opt,name=directory" json:"directory,omitempty"`
 }
 
 func (m *JobInput) Reset()         { *m = JobInput{} }
@@ -106,16 +247,43 @@ func (m *JobInput) GetCommit() *pfs.Commit {
 	return nil
 }
 
+// JobStats describes the work done by a job, either for a single datum or
+// aggregated across all of a job's datums.
+type JobStats struct {
+	Datums       uint64                     `protobuf:"varint,1,opt,name=datums" json:"datums,omitempty"`
+	ProcessTime  *google_protobuf2.Duration `protobuf:"bytes,2,opt,name=process_time" json:"process_time,omitempty"`
+	BytesRead    uint64                     `protobuf:"varint,3,opt,name=bytes_read" json:"bytes_read,omitempty"`
+	BytesWritten uint64                     `protobuf:"varint,4,opt,name=bytes_written" json:"bytes_written,omitempty"`
+}
+
+func (m *JobStats) Reset()         { *m = JobStats{} }
+func (m *JobStats) String() string { return proto.CompactTextString(m) }
+func (*JobStats) ProtoMessage()    {}
+
+func (m *JobStats) GetProcessTime() *google_protobuf2.Duration {
+	if m != nil {
+		return m.ProcessTime
+	}
+	return nil
+}
+
 type JobInfo struct {
-	Job          *Job                        `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
-	Transform    *Transform                  `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
-	Pipeline     *Pipeline                   `protobuf:"bytes,3,opt,name=pipeline" json:"pipeline,omitempty"`
-	Shards       uint64                      `protobuf:"varint,4,opt,name=shards" json:"shards,omitempty"`
-	Inputs       []*JobInput                 `protobuf:"bytes,5,rep,name=inputs" json:"inputs,omitempty"`
-	ParentJob    *Job                        `protobuf:"bytes,6,opt,name=parent_job" json:"parent_job,omitempty"`
-	CreatedAt    *google_protobuf1.Timestamp `protobuf:"bytes,7,opt,name=created_at" json:"created_at,omitempty"`
-	OutputCommit *pfs.Commit                 `protobuf:"bytes,8,opt,name=output_commit" json:"output_commit,omitempty"`
-	State        JobState                    `protobuf:"varint,9,opt,name=state,enum=pachyderm.pps.JobState" json:"state,omitempty"`
+	Job            *Job                        `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	Transform      *Transform                  `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
+	Pipeline       *Pipeline                   `protobuf:"bytes,3,opt,name=pipeline" json:"pipeline,omitempty"`
+	Shards         uint64                      `protobuf:"varint,4,opt,name=shards" json:"shards,omitempty"`
+	Inputs         []*JobInput                 `protobuf:"bytes,5,rep,name=inputs" json:"inputs,omitempty"`
+	ParentJob      *Job                        `protobuf:"bytes,6,opt,name=parent_job" json:"parent_job,omitempty"`
+	CreatedAt      *google_protobuf1.Timestamp `protobuf:"bytes,7,opt,name=created_at" json:"created_at,omitempty"`
+	OutputCommit   *pfs.Commit                 `protobuf:"bytes,8,opt,name=output_commit" json:"output_commit,omitempty"`
+	State          JobState                    `protobuf:"varint,9,opt,name=state,enum=pachyderm.pps.JobState" json:"state,omitempty"`
+	Stats          *JobStats                   `protobuf:"bytes,10,opt,name=stats" json:"stats,omitempty"`
+	Namespace      string                      `protobuf:"bytes,11,opt,name=namespace" json:"namespace,omitempty"`
+	Priority       int64                       `protobuf:"varint,12,opt,name=priority" json:"priority,omitempty"`
+	Preemptible    bool                        `protobuf:"varint,13,opt,name=preemptible" json:"preemptible,omitempty"`
+	RunId          string                      `protobuf:"bytes,14,opt,name=run_id" json:"run_id,omitempty"`
+	ExternalInputs []*ExternalInput            `protobuf:"bytes,15,rep,name=external_inputs" json:"external_inputs,omitempty"`
+	Reason         string                      `protobuf:"bytes,16,opt,name=reason" json:"reason,omitempty"`
 }
 
 func (m *JobInfo) Reset()         { *m = JobInfo{} }
@@ -171,6 +339,20 @@ func (m *JobInfo) GetOutputCommit() *pfs.Commit {
 	return nil
 }
 
+func (m *JobInfo) GetStats() *JobStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+func (m *JobInfo) GetExternalInputs() []*ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
 type JobInfos struct {
 	JobInfo []*JobInfo `protobuf:"bytes,1,rep,name=job_info" json:"job_info,omitempty"`
 }
@@ -186,6 +368,58 @@ func (m *JobInfos) GetJobInfo() []*JobInfo {
 	return nil
 }
 
+// JobManifest records what a job ran so that it can be reproduced, it's
+// written to pfs when the job starts.
+type JobManifest struct {
+	Job              *Job             `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	Pipeline         *Pipeline        `protobuf:"bytes,2,opt,name=pipeline" json:"pipeline,omitempty"`
+	Transform        *Transform       `protobuf:"bytes,3,opt,name=transform" json:"transform,omitempty"`
+	ImageDigest      string           `protobuf:"bytes,4,opt,name=image_digest" json:"image_digest,omitempty"`
+	Shards           uint64           `protobuf:"varint,5,opt,name=shards" json:"shards,omitempty"`
+	Inputs           []*JobInput      `protobuf:"bytes,6,rep,name=inputs" json:"inputs,omitempty"`
+	PachydermVersion string           `protobuf:"bytes,7,opt,name=pachyderm_version" json:"pachyderm_version,omitempty"`
+	ExternalInputs   []*ExternalInput `protobuf:"bytes,8,rep,name=external_inputs" json:"external_inputs,omitempty"`
+}
+
+func (m *JobManifest) Reset()         { *m = JobManifest{} }
+func (m *JobManifest) String() string { return proto.CompactTextString(m) }
+func (*JobManifest) ProtoMessage()    {}
+
+func (m *JobManifest) GetJob() *Job {
+	if m != nil {
+		return m.Job
+	}
+	return nil
+}
+
+func (m *JobManifest) GetPipeline() *Pipeline {
+	if m != nil {
+		return m.Pipeline
+	}
+	return nil
+}
+
+func (m *JobManifest) GetTransform() *Transform {
+	if m != nil {
+		return m.Transform
+	}
+	return nil
+}
+
+func (m *JobManifest) GetInputs() []*JobInput {
+	if m != nil {
+		return m.Inputs
+	}
+	return nil
+}
+
+func (m *JobManifest) GetExternalInputs() []*ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
 type Pipeline struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 }
@@ -195,8 +429,12 @@ func (m *Pipeline) String() string { return proto.CompactTextString(m) }
 func (*Pipeline) ProtoMessage()    {}
 
 type PipelineInput struct {
-	Repo   *pfs.Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
-	Reduce bool      `protobuf:"varint,2,opt,name=reduce" json:"reduce,omitempty"`
+	Repo      *pfs.Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	Reduce    bool      `protobuf:"varint,2,opt,name=reduce" json:"reduce,omitempty"`
+	Directory bool      `protobuf:"varint,3,opt,name=directory" json:"directory,omitempty"`
+	// tag restricts the commits which trigger jobs to those with a tag
+	// matching it, a pattern as in path.Match, "" means every commit does
+	Tag string `protobuf:"bytes,4,opt,name=tag" json:"tag,omitempty"`
 }
 
 func (m *PipelineInput) Reset()         { *m = PipelineInput{} }
@@ -210,13 +448,60 @@ func (m *PipelineInput) GetRepo() *pfs.Repo {
 	return nil
 }
 
+// ExternalInput is a prefix of an object store bucket which a job reads in
+// place, each object is a datum.
+type ExternalInput struct {
+	Url  string `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *ExternalInput) Reset()         { *m = ExternalInput{} }
+func (m *ExternalInput) String() string { return proto.CompactTextString(m) }
+func (*ExternalInput) ProtoMessage()    {}
+
+// Ingest makes a pipeline import the objects under url into its repo rather
+// than run a transform.
+type Ingest struct {
+	Url      string                     `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+	Interval *google_protobuf2.Duration `protobuf:"bytes,2,opt,name=interval" json:"interval,omitempty"`
+}
+
+func (m *Ingest) Reset()         { *m = Ingest{} }
+func (m *Ingest) String() string { return proto.CompactTextString(m) }
+func (*Ingest) ProtoMessage()    {}
+
+func (m *Ingest) GetInterval() *google_protobuf2.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+// Export makes a pipeline write the rows added by each commit to its input to
+// a warehouse rather than run a transform.
+type Export struct {
+	Url string `protobuf:"bytes,1,opt,name=url" json:"url,omitempty"`
+}
+
+func (m *Export) Reset()         { *m = Export{} }
+func (m *Export) String() string { return proto.CompactTextString(m) }
+func (*Export) ProtoMessage()    {}
+
 type PipelineInfo struct {
-	Pipeline   *Pipeline                   `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
-	Transform  *Transform                  `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
-	Shards     uint64                      `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
-	Inputs     []*PipelineInput            `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
-	OutputRepo *pfs.Repo                   `protobuf:"bytes,5,opt,name=output_repo" json:"output_repo,omitempty"`
-	CreatedAt  *google_protobuf1.Timestamp `protobuf:"bytes,6,opt,name=created_at" json:"created_at,omitempty"`
+	Pipeline          *Pipeline                   `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
+	Transform         *Transform                  `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
+	Shards            uint64                      `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
+	Inputs            []*PipelineInput            `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
+	OutputRepo        *pfs.Repo                   `protobuf:"bytes,5,opt,name=output_repo" json:"output_repo,omitempty"`
+	CreatedAt         *google_protobuf1.Timestamp `protobuf:"bytes,6,opt,name=created_at" json:"created_at,omitempty"`
+	Namespace         string                      `protobuf:"bytes,7,opt,name=namespace" json:"namespace,omitempty"`
+	MaxConcurrentJobs uint64                      `protobuf:"varint,8,opt,name=max_concurrent_jobs" json:"max_concurrent_jobs,omitempty"`
+	Priority          int64                       `protobuf:"varint,9,opt,name=priority" json:"priority,omitempty"`
+	Preemptible       bool                        `protobuf:"varint,10,opt,name=preemptible" json:"preemptible,omitempty"`
+	ImageDigest       string                      `protobuf:"bytes,11,opt,name=image_digest" json:"image_digest,omitempty"`
+	ExternalInputs    []*ExternalInput            `protobuf:"bytes,12,rep,name=external_inputs" json:"external_inputs,omitempty"`
+	Ingest            *Ingest                     `protobuf:"bytes,13,opt,name=ingest" json:"ingest,omitempty"`
+	Export            *Export                     `protobuf:"bytes,14,opt,name=export" json:"export,omitempty"`
 }
 
 func (m *PipelineInfo) Reset()         { *m = PipelineInfo{} }
@@ -258,6 +543,27 @@ func (m *PipelineInfo) GetCreatedAt() *google_protobuf1.Timestamp {
 	return nil
 }
 
+func (m *PipelineInfo) GetExternalInputs() []*ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
+func (m *PipelineInfo) GetIngest() *Ingest {
+	if m != nil {
+		return m.Ingest
+	}
+	return nil
+}
+
+func (m *PipelineInfo) GetExport() *Export {
+	if m != nil {
+		return m.Export
+	}
+	return nil
+}
+
 type PipelineInfos struct {
 	PipelineInfo []*PipelineInfo `protobuf:"bytes,1,rep,name=pipeline_info" json:"pipeline_info,omitempty"`
 }
@@ -274,11 +580,15 @@ func (m *PipelineInfos) GetPipelineInfo() []*PipelineInfo {
 }
 
 type CreateJobRequest struct {
-	Transform *Transform  `protobuf:"bytes,1,opt,name=transform" json:"transform,omitempty"`
-	Pipeline  *Pipeline   `protobuf:"bytes,2,opt,name=pipeline" json:"pipeline,omitempty"`
-	Shards    uint64      `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
-	Inputs    []*JobInput `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
-	ParentJob *Job        `protobuf:"bytes,5,opt,name=parent_job" json:"parent_job,omitempty"`
+	Transform      *Transform       `protobuf:"bytes,1,opt,name=transform" json:"transform,omitempty"`
+	Pipeline       *Pipeline        `protobuf:"bytes,2,opt,name=pipeline" json:"pipeline,omitempty"`
+	Shards         uint64           `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
+	Inputs         []*JobInput      `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
+	ParentJob      *Job             `protobuf:"bytes,5,opt,name=parent_job" json:"parent_job,omitempty"`
+	Priority       int64            `protobuf:"varint,6,opt,name=priority" json:"priority,omitempty"`
+	Preemptible    bool             `protobuf:"varint,7,opt,name=preemptible" json:"preemptible,omitempty"`
+	RunId          string           `protobuf:"bytes,8,opt,name=run_id" json:"run_id,omitempty"`
+	ExternalInputs []*ExternalInput `protobuf:"bytes,9,rep,name=external_inputs" json:"external_inputs,omitempty"`
 }
 
 func (m *CreateJobRequest) Reset()         { *m = CreateJobRequest{} }
@@ -313,6 +623,13 @@ func (m *CreateJobRequest) GetParentJob() *Job {
 	return nil
 }
 
+func (m *CreateJobRequest) GetExternalInputs() []*ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
 type InspectJobRequest struct {
 	Job         *Job `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
 	BlockOutput bool `protobuf:"varint,2,opt,name=block_output" json:"block_output,omitempty"`
@@ -330,9 +647,34 @@ func (m *InspectJobRequest) GetJob() *Job {
 	return nil
 }
 
+type DebugJobRequest struct {
+	Job   *Job   `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	Shard uint64 `protobuf:"varint,2,opt,name=shard" json:"shard,omitempty"`
+}
+
+func (m *DebugJobRequest) Reset()         { *m = DebugJobRequest{} }
+func (m *DebugJobRequest) String() string { return proto.CompactTextString(m) }
+func (*DebugJobRequest) ProtoMessage()    {}
+
+func (m *DebugJobRequest) GetJob() *Job {
+	if m != nil {
+		return m.Job
+	}
+	return nil
+}
+
+type DebugJobResponse struct {
+	Pod string `protobuf:"bytes,1,opt,name=pod" json:"pod,omitempty"`
+}
+
+func (m *DebugJobResponse) Reset()         { *m = DebugJobResponse{} }
+func (m *DebugJobResponse) String() string { return proto.CompactTextString(m) }
+func (*DebugJobResponse) ProtoMessage()    {}
+
 type ListJobRequest struct {
 	Pipeline    *Pipeline     `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
 	InputCommit []*pfs.Commit `protobuf:"bytes,2,rep,name=input_commit" json:"input_commit,omitempty"`
+	RunId       string        `protobuf:"bytes,3,opt,name=run_id" json:"run_id,omitempty"`
 }
 
 func (m *ListJobRequest) Reset()         { *m = ListJobRequest{} }
@@ -353,11 +695,41 @@ func (m *ListJobRequest) GetInputCommit() []*pfs.Commit {
 	return nil
 }
 
+type WatchJobsRequest struct {
+	Job      *Job      `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	Pipeline *Pipeline `protobuf:"bytes,2,opt,name=pipeline" json:"pipeline,omitempty"`
+}
+
+func (m *WatchJobsRequest) Reset()         { *m = WatchJobsRequest{} }
+func (m *WatchJobsRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchJobsRequest) ProtoMessage()    {}
+
+func (m *WatchJobsRequest) GetJob() *Job {
+	if m != nil {
+		return m.Job
+	}
+	return nil
+}
+
+func (m *WatchJobsRequest) GetPipeline() *Pipeline {
+	if m != nil {
+		return m.Pipeline
+	}
+	return nil
+}
+
 type CreatePipelineRequest struct {
-	Pipeline  *Pipeline        `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
-	Transform *Transform       `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
-	Shards    uint64           `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
-	Inputs    []*PipelineInput `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
+	Pipeline          *Pipeline        `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
+	Transform         *Transform       `protobuf:"bytes,2,opt,name=transform" json:"transform,omitempty"`
+	Shards            uint64           `protobuf:"varint,3,opt,name=shards" json:"shards,omitempty"`
+	Inputs            []*PipelineInput `protobuf:"bytes,4,rep,name=inputs" json:"inputs,omitempty"`
+	Namespace         string           `protobuf:"bytes,5,opt,name=namespace" json:"namespace,omitempty"`
+	MaxConcurrentJobs uint64           `protobuf:"varint,6,opt,name=max_concurrent_jobs" json:"max_concurrent_jobs,omitempty"`
+	Priority          int64            `protobuf:"varint,7,opt,name=priority" json:"priority,omitempty"`
+	Preemptible       bool             `protobuf:"varint,8,opt,name=preemptible" json:"preemptible,omitempty"`
+	ExternalInputs    []*ExternalInput `protobuf:"bytes,9,rep,name=external_inputs" json:"external_inputs,omitempty"`
+	Ingest            *Ingest          `protobuf:"bytes,10,opt,name=ingest" json:"ingest,omitempty"`
+	Export            *Export          `protobuf:"bytes,11,opt,name=export" json:"export,omitempty"`
 }
 
 func (m *CreatePipelineRequest) Reset()         { *m = CreatePipelineRequest{} }
@@ -385,6 +757,203 @@ func (m *CreatePipelineRequest) GetInputs() []*PipelineInput {
 	return nil
 }
 
+func (m *CreatePipelineRequest) GetExternalInputs() []*ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
+func (m *CreatePipelineRequest) GetIngest() *Ingest {
+	if m != nil {
+		return m.Ingest
+	}
+	return nil
+}
+
+func (m *CreatePipelineRequest) GetExport() *Export {
+	if m != nil {
+		return m.Export
+	}
+	return nil
+}
+
+type ListQueueRequest struct {
+	// unscheduled lists the jobs which failed to be scheduled, which
+	// RequeueJob can put back in the queue, rather than the queue
+	Unscheduled bool `protobuf:"varint,1,opt,name=unscheduled" json:"unscheduled,omitempty"`
+}
+
+func (m *ListQueueRequest) Reset()         { *m = ListQueueRequest{} }
+func (m *ListQueueRequest) String() string { return proto.CompactTextString(m) }
+func (*ListQueueRequest) ProtoMessage()    {}
+
+type RequeueJobRequest struct {
+	Job *Job `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+}
+
+func (m *RequeueJobRequest) Reset()         { *m = RequeueJobRequest{} }
+func (m *RequeueJobRequest) String() string { return proto.CompactTextString(m) }
+func (*RequeueJobRequest) ProtoMessage()    {}
+
+func (m *RequeueJobRequest) GetJob() *Job {
+	if m != nil {
+		return m.Job
+	}
+	return nil
+}
+
+type InspectLineageRequest struct {
+	Commit *pfs.Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	// walk the jobs which consumed commit (impact analysis) rather than the
+	// jobs which produced it
+	Downstream bool `protobuf:"varint,2,opt,name=downstream" json:"downstream,omitempty"`
+}
+
+func (m *InspectLineageRequest) Reset()         { *m = InspectLineageRequest{} }
+func (m *InspectLineageRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectLineageRequest) ProtoMessage()    {}
+
+func (m *InspectLineageRequest) GetCommit() *pfs.Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+// LineageNode is a commit in a lineage graph, job_info is the job which
+// produced the commit and is nil for commits that weren't written by a job.
+type LineageNode struct {
+	Commit  *pfs.Commit   `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	JobInfo *JobInfo      `protobuf:"bytes,2,opt,name=job_info" json:"job_info,omitempty"`
+	Inputs  []*pfs.Commit `protobuf:"bytes,3,rep,name=inputs" json:"inputs,omitempty"`
+	Outputs []*pfs.Commit `protobuf:"bytes,4,rep,name=outputs" json:"outputs,omitempty"`
+}
+
+func (m *LineageNode) Reset()         { *m = LineageNode{} }
+func (m *LineageNode) String() string { return proto.CompactTextString(m) }
+func (*LineageNode) ProtoMessage()    {}
+
+func (m *LineageNode) GetCommit() *pfs.Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *LineageNode) GetJobInfo() *JobInfo {
+	if m != nil {
+		return m.JobInfo
+	}
+	return nil
+}
+
+func (m *LineageNode) GetInputs() []*pfs.Commit {
+	if m != nil {
+		return m.Inputs
+	}
+	return nil
+}
+
+func (m *LineageNode) GetOutputs() []*pfs.Commit {
+	if m != nil {
+		return m.Outputs
+	}
+	return nil
+}
+
+type Lineage struct {
+	Node []*LineageNode `protobuf:"bytes,1,rep,name=node" json:"node,omitempty"`
+}
+
+func (m *Lineage) Reset()         { *m = Lineage{} }
+func (m *Lineage) String() string { return proto.CompactTextString(m) }
+func (*Lineage) ProtoMessage()    {}
+
+func (m *Lineage) GetNode() []*LineageNode {
+	if m != nil {
+		return m.Node
+	}
+	return nil
+}
+
+type FlushCommitRequest struct {
+	Commit  []*pfs.Commit              `protobuf:"bytes,1,rep,name=commit" json:"commit,omitempty"`
+	Timeout *google_protobuf2.Duration `protobuf:"bytes,2,opt,name=timeout" json:"timeout,omitempty"`
+}
+
+func (m *FlushCommitRequest) Reset()         { *m = FlushCommitRequest{} }
+func (m *FlushCommitRequest) String() string { return proto.CompactTextString(m) }
+func (*FlushCommitRequest) ProtoMessage()    {}
+
+func (m *FlushCommitRequest) GetCommit() []*pfs.Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *FlushCommitRequest) GetTimeout() *google_protobuf2.Duration {
+	if m != nil {
+		return m.Timeout
+	}
+	return nil
+}
+
+type FlushCommitResponse struct {
+	OutputCommit []*pfs.Commit `protobuf:"bytes,1,rep,name=output_commit" json:"output_commit,omitempty"`
+}
+
+func (m *FlushCommitResponse) Reset()         { *m = FlushCommitResponse{} }
+func (m *FlushCommitResponse) String() string { return proto.CompactTextString(m) }
+func (*FlushCommitResponse) ProtoMessage()    {}
+
+func (m *FlushCommitResponse) GetOutputCommit() []*pfs.Commit {
+	if m != nil {
+		return m.OutputCommit
+	}
+	return nil
+}
+
+type ListRunRequest struct {
+}
+
+func (m *ListRunRequest) Reset()         { *m = ListRunRequest{} }
+func (m *ListRunRequest) String() string { return proto.CompactTextString(m) }
+func (*ListRunRequest) ProtoMessage()    {}
+
+// RunInfo is the set of jobs transitively triggered by one upstream commit.
+type RunInfo struct {
+	RunId   string     `protobuf:"bytes,1,opt,name=run_id" json:"run_id,omitempty"`
+	JobInfo []*JobInfo `protobuf:"bytes,2,rep,name=job_info" json:"job_info,omitempty"`
+}
+
+func (m *RunInfo) Reset()         { *m = RunInfo{} }
+func (m *RunInfo) String() string { return proto.CompactTextString(m) }
+func (*RunInfo) ProtoMessage()    {}
+
+func (m *RunInfo) GetJobInfo() []*JobInfo {
+	if m != nil {
+		return m.JobInfo
+	}
+	return nil
+}
+
+type RunInfos struct {
+	RunInfo []*RunInfo `protobuf:"bytes,1,rep,name=run_info" json:"run_info,omitempty"`
+}
+
+func (m *RunInfos) Reset()         { *m = RunInfos{} }
+func (m *RunInfos) String() string { return proto.CompactTextString(m) }
+func (*RunInfos) ProtoMessage()    {}
+
+func (m *RunInfos) GetRunInfo() []*RunInfo {
+	if m != nil {
+		return m.RunInfo
+	}
+	return nil
+}
+
 type InspectPipelineRequest struct {
 	Pipeline *Pipeline `protobuf:"bytes,1,opt,name=pipeline" json:"pipeline,omitempty"`
 }
@@ -423,7 +992,9 @@ func (m *DeletePipelineRequest) GetPipeline() *Pipeline {
 }
 
 type StartJobRequest struct {
-	Job *Job `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	Job         *Job   `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	PodName     string `protobuf:"bytes,2,opt,name=pod_name" json:"pod_name,omitempty"`
+	Speculative bool   `protobuf:"varint,3,opt,name=speculative" json:"speculative,omitempty"`
 }
 
 func (m *StartJobRequest) Reset()         { *m = StartJobRequest{} }
@@ -441,8 +1012,11 @@ type StartJobResponse struct {
 	Transform    *Transform          `protobuf:"bytes,1,opt,name=transform" json:"transform,omitempty"`
 	CommitMounts []*fuse.CommitMount `protobuf:"bytes,2,rep,name=commit_mounts" json:"commit_mounts,omitempty"`
 	// TODO this could just be another commit mount
-	OutputCommit *pfs.Commit `protobuf:"bytes,3,opt,name=output_commit" json:"output_commit,omitempty"`
-	Index        uint64      `protobuf:"varint,4,opt,name=index" json:"index,omitempty"`
+	OutputCommit   *pfs.Commit      `protobuf:"bytes,3,opt,name=output_commit" json:"output_commit,omitempty"`
+	Index          uint64           `protobuf:"varint,4,opt,name=index" json:"index,omitempty"`
+	Cached         bool             `protobuf:"varint,5,opt,name=cached" json:"cached,omitempty"`
+	ExternalInputs []*ExternalInput `protobuf:"bytes,6,rep,name=external_inputs" json:"external_inputs,omitempty"`
+	Shards         uint64           `protobuf:"varint,7,opt,name=shards" json:"shards,omitempty"`
 }
 
 func (m *StartJobResponse) Reset()         { *m = StartJobResponse{} }
@@ -470,10 +1044,19 @@ func (m *StartJobResponse) GetOutputCommit() *pfs.Commit {
 	return nil
 }
 
+func (m *StartJobResponse) GetExternalInputs() []*ExternalInput {
+	if m != nil {
+		return m.ExternalInputs
+	}
+	return nil
+}
+
 type FinishJobRequest struct {
-	Job     *Job   `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
-	Index   uint64 `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
-	Success bool   `protobuf:"varint,3,opt,name=success" json:"success,omitempty"`
+	Job         *Job      `protobuf:"bytes,1,opt,name=job" json:"job,omitempty"`
+	Index       uint64    `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
+	Success     bool      `protobuf:"varint,3,opt,name=success" json:"success,omitempty"`
+	Stats       *JobStats `protobuf:"bytes,4,opt,name=stats" json:"stats,omitempty"`
+	Speculative bool      `protobuf:"varint,5,opt,name=speculative" json:"speculative,omitempty"`
 }
 
 func (m *FinishJobRequest) Reset()         { *m = FinishJobRequest{} }
@@ -487,20 +1070,48 @@ func (m *FinishJobRequest) GetJob() *Job {
 	return nil
 }
 
+func (m *FinishJobRequest) GetStats() *JobStats {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Transform)(nil), "pachyderm.pps.Transform")
+	proto.RegisterType((*Resources)(nil), "pachyderm.pps.Resources")
+	proto.RegisterType((*Autoscaling)(nil), "pachyderm.pps.Autoscaling")
+	proto.RegisterType((*Sidecar)(nil), "pachyderm.pps.Sidecar")
 	proto.RegisterType((*Job)(nil), "pachyderm.pps.Job")
 	proto.RegisterType((*JobInput)(nil), "pachyderm.pps.JobInput")
+	proto.RegisterType((*JobStats)(nil), "pachyderm.pps.JobStats")
 	proto.RegisterType((*JobInfo)(nil), "pachyderm.pps.JobInfo")
 	proto.RegisterType((*JobInfos)(nil), "pachyderm.pps.JobInfos")
+	proto.RegisterType((*JobManifest)(nil), "pachyderm.pps.JobManifest")
 	proto.RegisterType((*Pipeline)(nil), "pachyderm.pps.Pipeline")
 	proto.RegisterType((*PipelineInput)(nil), "pachyderm.pps.PipelineInput")
+	proto.RegisterType((*ExternalInput)(nil), "pachyderm.pps.ExternalInput")
+	proto.RegisterType((*Ingest)(nil), "pachyderm.pps.Ingest")
+	proto.RegisterType((*Export)(nil), "pachyderm.pps.Export")
 	proto.RegisterType((*PipelineInfo)(nil), "pachyderm.pps.PipelineInfo")
 	proto.RegisterType((*PipelineInfos)(nil), "pachyderm.pps.PipelineInfos")
 	proto.RegisterType((*CreateJobRequest)(nil), "pachyderm.pps.CreateJobRequest")
 	proto.RegisterType((*InspectJobRequest)(nil), "pachyderm.pps.InspectJobRequest")
+	proto.RegisterType((*DebugJobRequest)(nil), "pachyderm.pps.DebugJobRequest")
+	proto.RegisterType((*DebugJobResponse)(nil), "pachyderm.pps.DebugJobResponse")
 	proto.RegisterType((*ListJobRequest)(nil), "pachyderm.pps.ListJobRequest")
+	proto.RegisterType((*WatchJobsRequest)(nil), "pachyderm.pps.WatchJobsRequest")
 	proto.RegisterType((*CreatePipelineRequest)(nil), "pachyderm.pps.CreatePipelineRequest")
+	proto.RegisterType((*ListQueueRequest)(nil), "pachyderm.pps.ListQueueRequest")
+	proto.RegisterType((*RequeueJobRequest)(nil), "pachyderm.pps.RequeueJobRequest")
+	proto.RegisterType((*InspectLineageRequest)(nil), "pachyderm.pps.InspectLineageRequest")
+	proto.RegisterType((*LineageNode)(nil), "pachyderm.pps.LineageNode")
+	proto.RegisterType((*Lineage)(nil), "pachyderm.pps.Lineage")
+	proto.RegisterType((*FlushCommitRequest)(nil), "pachyderm.pps.FlushCommitRequest")
+	proto.RegisterType((*FlushCommitResponse)(nil), "pachyderm.pps.FlushCommitResponse")
+	proto.RegisterType((*ListRunRequest)(nil), "pachyderm.pps.ListRunRequest")
+	proto.RegisterType((*RunInfo)(nil), "pachyderm.pps.RunInfo")
+	proto.RegisterType((*RunInfos)(nil), "pachyderm.pps.RunInfos")
 	proto.RegisterType((*InspectPipelineRequest)(nil), "pachyderm.pps.InspectPipelineRequest")
 	proto.RegisterType((*ListPipelineRequest)(nil), "pachyderm.pps.ListPipelineRequest")
 	proto.RegisterType((*DeletePipelineRequest)(nil), "pachyderm.pps.DeletePipelineRequest")
@@ -520,6 +1131,26 @@ type JobAPIClient interface {
 	CreateJob(ctx context.Context, in *CreateJobRequest, opts ...grpc.CallOption) (*Job, error)
 	InspectJob(ctx context.Context, in *InspectJobRequest, opts ...grpc.CallOption) (*JobInfo, error)
 	ListJob(ctx context.Context, in *ListJobRequest, opts ...grpc.CallOption) (*JobInfos, error)
+	// ListQueue returns the jobs waiting to run, in the order they'll be considered.
+	ListQueue(ctx context.Context, in *ListQueueRequest, opts ...grpc.CallOption) (*JobInfos, error)
+	// InspectLineage returns the graph of commits and jobs upstream (or
+	// downstream) of a commit.
+	InspectLineage(ctx context.Context, in *InspectLineageRequest, opts ...grpc.CallOption) (*Lineage, error)
+	// FlushCommit blocks until every pipeline downstream of commit has finished
+	// the jobs commit triggers and returns their output commits.
+	FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*FlushCommitResponse, error)
+	ListRun(ctx context.Context, in *ListRunRequest, opts ...grpc.CallOption) (*RunInfos, error)
+	// InspectJobManifest returns the manifest recorded when the job started.
+	InspectJobManifest(ctx context.Context, in *InspectJobRequest, opts ...grpc.CallOption) (*JobManifest, error)
+	// WatchJobs streams the jobs matching the request as they are now, then
+	// each job again whenever it changes.
+	WatchJobs(ctx context.Context, in *WatchJobsRequest, opts ...grpc.CallOption) (JobAPI_WatchJobsClient, error)
+	// DebugJob starts a pod from the job's image, with the job's env, which
+	// mounts what a shard of the job sees and waits to be exec'd into.
+	DebugJob(ctx context.Context, in *DebugJobRequest, opts ...grpc.CallOption) (*DebugJobResponse, error)
+	// RequeueJob queues a new job to run the job which failed to be
+	// scheduled again and returns it, the failed job is left as it is.
+	RequeueJob(ctx context.Context, in *RequeueJobRequest, opts ...grpc.CallOption) (*Job, error)
 }
 
 type jobAPIClient struct {
@@ -557,12 +1188,127 @@ func (c *jobAPIClient) ListJob(ctx context.Context, in *ListJobRequest, opts ...
 	return out, nil
 }
 
+func (c *jobAPIClient) ListQueue(ctx context.Context, in *ListQueueRequest, opts ...grpc.CallOption) (*JobInfos, error) {
+	out := new(JobInfos)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/ListQueue", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobAPIClient) InspectLineage(ctx context.Context, in *InspectLineageRequest, opts ...grpc.CallOption) (*Lineage, error) {
+	out := new(Lineage)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/InspectLineage", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobAPIClient) FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*FlushCommitResponse, error) {
+	out := new(FlushCommitResponse)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/FlushCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobAPIClient) ListRun(ctx context.Context, in *ListRunRequest, opts ...grpc.CallOption) (*RunInfos, error) {
+	out := new(RunInfos)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/ListRun", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobAPIClient) InspectJobManifest(ctx context.Context, in *InspectJobRequest, opts ...grpc.CallOption) (*JobManifest, error) {
+	out := new(JobManifest)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/InspectJobManifest", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobAPIClient) WatchJobs(ctx context.Context, in *WatchJobsRequest, opts ...grpc.CallOption) (JobAPI_WatchJobsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_JobAPI_serviceDesc.Streams[0], c.cc, "/pachyderm.pps.JobAPI/WatchJobs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &jobAPIWatchJobsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type JobAPI_WatchJobsClient interface {
+	Recv() (*JobInfo, error)
+	grpc.ClientStream
+}
+
+type jobAPIWatchJobsClient struct {
+	grpc.ClientStream
+}
+
+func (x *jobAPIWatchJobsClient) Recv() (*JobInfo, error) {
+	m := new(JobInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *jobAPIClient) DebugJob(ctx context.Context, in *DebugJobRequest, opts ...grpc.CallOption) (*DebugJobResponse, error) {
+	out := new(DebugJobResponse)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/DebugJob", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jobAPIClient) RequeueJob(ctx context.Context, in *RequeueJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := grpc.Invoke(ctx, "/pachyderm.pps.JobAPI/RequeueJob", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for JobAPI service
 
 type JobAPIServer interface {
 	CreateJob(context.Context, *CreateJobRequest) (*Job, error)
 	InspectJob(context.Context, *InspectJobRequest) (*JobInfo, error)
 	ListJob(context.Context, *ListJobRequest) (*JobInfos, error)
+	// ListQueue returns the jobs waiting to run, in the order they'll be considered.
+	ListQueue(context.Context, *ListQueueRequest) (*JobInfos, error)
+	// InspectLineage returns the graph of commits and jobs upstream (or
+	// downstream) of a commit.
+	InspectLineage(context.Context, *InspectLineageRequest) (*Lineage, error)
+	// FlushCommit blocks until every pipeline downstream of commit has finished
+	// the jobs commit triggers and returns their output commits.
+	FlushCommit(context.Context, *FlushCommitRequest) (*FlushCommitResponse, error)
+	ListRun(context.Context, *ListRunRequest) (*RunInfos, error)
+	// InspectJobManifest returns the manifest recorded when the job started.
+	InspectJobManifest(context.Context, *InspectJobRequest) (*JobManifest, error)
+	// WatchJobs streams the jobs matching the request as they are now, then
+	// each job again whenever it changes.
+	WatchJobs(*WatchJobsRequest, JobAPI_WatchJobsServer) error
+	// DebugJob starts a pod from the job's image, with the job's env, which
+	// mounts what a shard of the job sees and waits to be exec'd into.
+	DebugJob(context.Context, *DebugJobRequest) (*DebugJobResponse, error)
+	// RequeueJob queues a new job to run the job which failed to be
+	// scheduled again and returns it, the failed job is left as it is.
+	RequeueJob(context.Context, *RequeueJobRequest) (*Job, error)
 }
 
 func RegisterJobAPIServer(s *grpc.Server, srv JobAPIServer) {
@@ -605,6 +1351,111 @@ func _JobAPI_ListJob_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return out, nil
 }
 
+func _JobAPI_ListQueue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListQueueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).ListQueue(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _JobAPI_InspectLineage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectLineageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).InspectLineage(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _JobAPI_FlushCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(FlushCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).FlushCommit(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _JobAPI_ListRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ListRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).ListRun(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _JobAPI_InspectJobManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(InspectJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).InspectJobManifest(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _JobAPI_WatchJobs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchJobsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JobAPIServer).WatchJobs(m, &jobAPIWatchJobsServer{stream})
+}
+
+type JobAPI_WatchJobsServer interface {
+	Send(*JobInfo) error
+	grpc.ServerStream
+}
+
+type jobAPIWatchJobsServer struct {
+	grpc.ServerStream
+}
+
+func (x *jobAPIWatchJobsServer) Send(m *JobInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _JobAPI_DebugJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DebugJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).DebugJob(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _JobAPI_RequeueJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(RequeueJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(JobAPIServer).RequeueJob(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _JobAPI_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pachyderm.pps.JobAPI",
 	HandlerType: (*JobAPIServer)(nil),
@@ -621,8 +1472,42 @@ var _JobAPI_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ListJob",
 			Handler:    _JobAPI_ListJob_Handler,
 		},
+		{
+			MethodName: "ListQueue",
+			Handler:    _JobAPI_ListQueue_Handler,
+		},
+		{
+			MethodName: "InspectLineage",
+			Handler:    _JobAPI_InspectLineage_Handler,
+		},
+		{
+			MethodName: "FlushCommit",
+			Handler:    _JobAPI_FlushCommit_Handler,
+		},
+		{
+			MethodName: "ListRun",
+			Handler:    _JobAPI_ListRun_Handler,
+		},
+		{
+			MethodName: "InspectJobManifest",
+			Handler:    _JobAPI_InspectJobManifest_Handler,
+		},
+		{
+			MethodName: "DebugJob",
+			Handler:    _JobAPI_DebugJob_Handler,
+		},
+		{
+			MethodName: "RequeueJob",
+			Handler:    _JobAPI_RequeueJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchJobs",
+			Handler:       _JobAPI_WatchJobs_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
 
 // Client API for PipelineAPI service
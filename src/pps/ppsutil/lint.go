@@ -0,0 +1,275 @@
+package ppsutil
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/pkg/export"
+	"github.com/pachyderm/pachyderm/src/pkg/obj"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/google-protobuf"
+	"go.pedge.io/proto/time"
+)
+
+// Problem is a mistake in a spec.
+type Problem struct {
+	// Error is true if pps rejects the spec, otherwise the spec is accepted
+	// but probably doesn't do what was meant.
+	Error bool
+	// Field is the field the problem is with, ie "transform.image".
+	Field   string
+	Message string
+}
+
+func (p *Problem) String() string {
+	level := "warning"
+	if p.Error {
+		level = "error"
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Field, p.Message)
+}
+
+// LintPipeline returns the problems with request.
+func LintPipeline(request *pps.CreatePipelineRequest) []*Problem {
+	var problems []*Problem
+	if request.Pipeline == nil || request.Pipeline.Name == "" {
+		problems = append(problems, lintError("pipeline.name", "a pipeline must have a name"))
+	}
+	if request.Ingest != nil && request.Export != nil {
+		return append(problems, lintError("export", "a pipeline can't both ingest and export"))
+	}
+	if request.Ingest != nil {
+		return append(problems, lintIngest(request)...)
+	}
+	if request.Export != nil {
+		return append(problems, lintExport(request)...)
+	}
+	if request.Shards == 0 {
+		problems = append(problems, lintError("shards", "the pipeline's jobs would have no shards, set shards to how many containers each job should run"))
+	}
+	repos := make(map[string]bool)
+	for i, input := range request.Inputs {
+		field := fmt.Sprintf("inputs[%d].repo", i)
+		if input.Repo == nil || input.Repo.Name == "" {
+			problems = append(problems, lintError(field, "an input must have a repo"))
+			continue
+		}
+		if repos[input.Repo.Name] {
+			problems = append(problems, lintError(field, fmt.Sprintf("%s is already an input, each repo can only be an input once", input.Repo.Name)))
+		}
+		repos[input.Repo.Name] = true
+		if input.Reduce && input.Directory {
+			problems = append(problems, lintError(fmt.Sprintf("inputs[%d].directory", i), "an input can't be both reduce and directory"))
+		}
+		if _, err := path.Match(input.Tag, ""); err != nil {
+			problems = append(problems, lintError(fmt.Sprintf("inputs[%d].tag", i), fmt.Sprintf("%s isn't a valid pattern: %s", input.Tag, err.Error())))
+		}
+		if request.Pipeline != nil && input.Repo.Name == pps.PipelineRepo(request.Pipeline).Name {
+			problems = append(problems, lintWarning(field, fmt.Sprintf("%s is the pipeline's output repo, every job would trigger another", input.Repo.Name)))
+		}
+	}
+	if len(request.Inputs) == 0 {
+		message := "a pipeline without inputs never runs"
+		if len(request.ExternalInputs) > 0 {
+			message += ", external inputs don't trigger jobs"
+		}
+		problems = append(problems, lintWarning("inputs", message))
+	}
+	problems = append(problems, lintExternalInputs(request.ExternalInputs)...)
+	return append(problems, lintTransform(request.Transform)...)
+}
+
+// LintJob returns the problems with request.
+func LintJob(request *pps.CreateJobRequest) []*Problem {
+	var problems []*Problem
+	if request.Shards == 0 {
+		problems = append(problems, lintError("shards", "a job must have shards, set shards to how many containers it should run"))
+	}
+	repos := make(map[string]bool)
+	for i, input := range request.Inputs {
+		field := fmt.Sprintf("inputs[%d].commit", i)
+		if input.Commit == nil || input.Commit.Repo == nil || input.Commit.Repo.Name == "" {
+			problems = append(problems, lintError(field, "an input must have a commit"))
+			continue
+		}
+		if repos[input.Commit.Repo.Name] {
+			problems = append(problems, lintError(field, fmt.Sprintf("%s is already an input, each repo can only be an input once", input.Commit.Repo.Name)))
+		}
+		repos[input.Commit.Repo.Name] = true
+		if input.Reduce && input.Directory {
+			problems = append(problems, lintError(fmt.Sprintf("inputs[%d].directory", i), "an input can't be both reduce and directory"))
+		}
+	}
+	problems = append(problems, lintExternalInputs(request.ExternalInputs)...)
+	return append(problems, lintTransform(request.Transform)...)
+}
+
+// ValidatePipeline returns an error if pps rejects request.
+func ValidatePipeline(request *pps.CreatePipelineRequest) error {
+	return firstError(LintPipeline(request))
+}
+
+// ValidateJob returns an error if pps rejects request.
+func ValidateJob(request *pps.CreateJobRequest) error {
+	return firstError(LintJob(request))
+}
+
+func lintTransform(transform *pps.Transform) []*Problem {
+	if transform == nil {
+		return []*Problem{lintError("transform", "a transform must have an image or a build")}
+	}
+	var problems []*Problem
+	problems = append(problems, lintTimeout("transform.job_timeout", transform.JobTimeout)...)
+	problems = append(problems, lintTimeout("transform.datum_timeout", transform.DatumTimeout)...)
+	problems = append(problems, lintSidecars(transform.Sidecars)...)
+	if transform.SpeculationThreshold < 0 || (transform.SpeculationThreshold > 0 && transform.SpeculationThreshold < 1) {
+		problems = append(problems, lintError("transform.speculation_threshold", "transform.speculation_threshold must be at least 1, or 0 to turn speculation off"))
+	}
+	if autoscaling := transform.Autoscaling; autoscaling != nil && autoscaling.MaxWorkers != 0 && autoscaling.MinWorkers > autoscaling.MaxWorkers {
+		problems = append(problems, lintError("transform.autoscaling", "transform.autoscaling.min_workers can't be more than max_workers"))
+	}
+	// pods with sidecars never complete so each worker only runs one shard,
+	// fewer workers than shards would leave shards which never run
+	if transform.Autoscaling != nil && len(transform.Sidecars) > 0 {
+		problems = append(problems, lintError("transform.autoscaling", "transform.autoscaling can't be used with sidecars, each shard needs its own worker"))
+	}
+	if transform.Build != nil {
+		if transform.Build.Commit == nil || transform.Build.Commit.Repo == nil {
+			problems = append(problems, lintError("transform.build", "transform.build must have a commit"))
+		}
+		return problems
+	}
+	switch {
+	case transform.Image == "":
+		problems = append(problems, lintWarning("transform.image", fmt.Sprintf("there's no image, the transform runs in %s", DefaultImage)))
+	case !imagePinned(transform.Image):
+		problems = append(problems, lintWarning("transform.image", fmt.Sprintf("%s isn't pinned, each job runs whatever it is when the job starts, use a version tag or a digest", transform.Image)))
+	}
+	return problems
+}
+
+func lintExternalInputs(externalInputs []*pps.ExternalInput) []*Problem {
+	var problems []*Problem
+	names := make(map[string]bool)
+	for i, externalInput := range externalInputs {
+		field := fmt.Sprintf("external_inputs[%d]", i)
+		if _, _, err := obj.ParseURL(externalInput.Url); err != nil {
+			problems = append(problems, lintError(field+".url", err.Error()))
+		}
+		switch {
+		case externalInput.Name == "" || strings.Contains(externalInput.Name, "/"):
+			problems = append(problems, lintError(field+".name", "an external input must have a name without /s, it's mounted at "+path.Join(ExternalMountDir, "name")))
+		case names[externalInput.Name]:
+			problems = append(problems, lintError(field+".name", fmt.Sprintf("%s is already the name of an external input", externalInput.Name)))
+		}
+		names[externalInput.Name] = true
+	}
+	return problems
+}
+
+// lintIngest returns the problems with an ingest pipeline, which has no jobs
+// so none of the fields for them can be set.
+func lintIngest(request *pps.CreatePipelineRequest) []*Problem {
+	var problems []*Problem
+	if _, _, err := obj.ParseURL(request.Ingest.Url); err != nil {
+		problems = append(problems, lintError("ingest.url", err.Error()))
+	}
+	if request.Ingest.Interval == nil || prototime.DurationFromProto(request.Ingest.Interval) <= 0 {
+		problems = append(problems, lintError("ingest.interval", "ingest.interval must be positive, it's how often the bucket is checked for new objects"))
+	}
+	if request.Transform != nil {
+		problems = append(problems, lintError("transform", "an ingest pipeline can't have a transform, it runs no jobs"))
+	}
+	if len(request.Inputs) > 0 || len(request.ExternalInputs) > 0 {
+		problems = append(problems, lintError("inputs", "an ingest pipeline can't have inputs, it's triggered by its interval"))
+	}
+	return problems
+}
+
+// lintExport returns the problems with an export pipeline, which has no jobs
+// so none of the fields for them can be set.
+func lintExport(request *pps.CreatePipelineRequest) []*Problem {
+	var problems []*Problem
+	if _, _, _, err := export.ParseURL(request.Export.Url); err != nil {
+		problems = append(problems, lintError("export.url", err.Error()))
+	}
+	if request.Transform != nil {
+		problems = append(problems, lintError("transform", "an export pipeline can't have a transform, it runs no jobs"))
+	}
+	switch {
+	case len(request.Inputs) != 1 || request.Inputs[0].Repo == nil || request.Inputs[0].Repo.Name == "":
+		problems = append(problems, lintError("inputs", "an export pipeline must have one input, the repo it exports"))
+	case request.Inputs[0].Reduce:
+		problems = append(problems, lintWarning("inputs[0].reduce", "an export pipeline exports every file, reduce is ignored"))
+	case request.Inputs[0].Directory:
+		problems = append(problems, lintWarning("inputs[0].directory", "an export pipeline exports every file, directory is ignored"))
+	case request.Inputs[0].Tag != "":
+		problems = append(problems, lintWarning("inputs[0].tag", "an export pipeline exports every commit, tag is ignored"))
+	}
+	if len(request.ExternalInputs) > 0 {
+		problems = append(problems, lintError("external_inputs", "an export pipeline can't have external inputs"))
+	}
+	return problems
+}
+
+// lintTimeout returns an error if timeout is set and isn't positive, a zero
+// timeout would fail everything immediately.
+func lintTimeout(field string, timeout *google_protobuf.Duration) []*Problem {
+	if timeout == nil || prototime.DurationFromProto(timeout) > 0 {
+		return nil
+	}
+	return []*Problem{lintError(field, fmt.Sprintf("%s must be positive, leave it out for no timeout", field))}
+}
+
+// lintSidecars returns errors for sidecars which can't be run next to the
+// transform's container, which is named user, and the pod's cache container,
+// which is named pfs-cache.
+func lintSidecars(sidecars []*pps.Sidecar) []*Problem {
+	var problems []*Problem
+	names := map[string]bool{"user": true, "pfs-cache": true}
+	for i, sidecar := range sidecars {
+		field := fmt.Sprintf("transform.sidecars[%d]", i)
+		switch {
+		case sidecar.Name == "":
+			problems = append(problems, lintError(field+".name", "a sidecar must have a name"))
+		case names[sidecar.Name]:
+			problems = append(problems, lintError(field+".name", fmt.Sprintf("there's already a container named %s", sidecar.Name)))
+		}
+		names[sidecar.Name] = true
+		if sidecar.Image == "" {
+			problems = append(problems, lintError(field+".image", "a sidecar must have an image"))
+		}
+	}
+	return problems
+}
+
+// imagePinned returns true if image has a tag other than latest or a digest.
+func imagePinned(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	// the tag comes after the last ":" unless that's the registry's port
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return false
+	}
+	return image[i+1:] != "latest"
+}
+
+func firstError(problems []*Problem) error {
+	for _, problem := range problems {
+		if problem.Error {
+			return fmt.Errorf("%s: %s", problem.Field, problem.Message)
+		}
+	}
+	return nil
+}
+
+func lintError(field string, message string) *Problem {
+	return &Problem{true, field, message}
+}
+
+func lintWarning(field string, message string) *Problem {
+	return &Problem{false, field, message}
+}
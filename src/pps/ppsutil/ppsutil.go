@@ -1,6 +1,11 @@
 package ppsutil
 
 import (
+	"hash/fnv"
+	"path"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pfs/fuse"
 	"github.com/pachyderm/pachyderm/src/pps"
 	"golang.org/x/net/context"
 )
@@ -65,3 +70,93 @@ func CreatePipeline(
 	)
 	return err
 }
+
+// PipelineSpec returns the CreatePipelineRequest that creates the pipeline
+// described by pipelineInfo, fields which pps sets itself are left out.
+func PipelineSpec(pipelineInfo *pps.PipelineInfo) *pps.CreatePipelineRequest {
+	return &pps.CreatePipelineRequest{
+		Pipeline:          pipelineInfo.Pipeline,
+		Transform:         pipelineInfo.Transform,
+		Shards:            pipelineInfo.Shards,
+		Inputs:            pipelineInfo.Inputs,
+		Namespace:         pipelineInfo.Namespace,
+		MaxConcurrentJobs: pipelineInfo.MaxConcurrentJobs,
+		Priority:          pipelineInfo.Priority,
+		Preemptible:       pipelineInfo.Preemptible,
+		ExternalInputs:    pipelineInfo.ExternalInputs,
+		Ingest:            pipelineInfo.Ingest,
+		Export:            pipelineInfo.Export,
+	}
+}
+
+// JobSpec returns the CreateJobRequest that creates the job described by
+// jobInfo. The run id is left out so that the spec starts a new run rather
+// than joining the job's.
+func JobSpec(jobInfo *pps.JobInfo) *pps.CreateJobRequest {
+	return &pps.CreateJobRequest{
+		Transform:      jobInfo.Transform,
+		Pipeline:       jobInfo.Pipeline,
+		Shards:         jobInfo.Shards,
+		Inputs:         jobInfo.Inputs,
+		ParentJob:      jobInfo.ParentJob,
+		Priority:       jobInfo.Priority,
+		Preemptible:    jobInfo.Preemptible,
+		ExternalInputs: jobInfo.ExternalInputs,
+	}
+}
+
+// InputCommitMounts returns the mounts of a job's inputs for one of its
+// shards. Reduce inputs are sharded by file, directory inputs by top level
+// directory and the others by block.
+func InputCommitMounts(inputs []*pps.JobInput, shards uint64, shard uint64) []*fuse.CommitMount {
+	var commitMounts []*fuse.CommitMount
+	for _, input := range inputs {
+		commitMount := &fuse.CommitMount{
+			Commit: input.Commit,
+			Shard: &pfs.Shard{
+				FileModulus:  1,
+				BlockModulus: 1,
+			},
+		}
+		switch {
+		case input.Reduce:
+			commitMount.Shard.FileNumber = shard
+			commitMount.Shard.FileModulus = shards
+		case input.Directory:
+			commitMount.Shard.FileNumber = shard
+			commitMount.Shard.FileModulus = shards
+			commitMount.Shard.TopDir = true
+		default:
+			commitMount.Shard.BlockNumber = shard
+			commitMount.Shard.BlockModulus = shards
+		}
+		commitMounts = append(commitMounts, commitMount)
+	}
+	return commitMounts
+}
+
+// MatchTag returns true if one of tags matches pattern, as in path.Match.
+func MatchTag(pattern string, tags []string) bool {
+	for _, tag := range tags {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultImage is the image a transform without one runs in.
+const DefaultImage = "pachyderm/job-shim"
+
+// ExternalMountDir is where a job's external inputs are mounted, each at
+// ExternalMountDir/name. They can't go under /pfs, which is a single fuse
+// mount.
+const ExternalMountDir = "/pfs-external"
+
+// ExternalObjectShard returns the shard which reads the object name of an
+// external input, objects are split between shards by a hash of their names.
+func ExternalObjectShard(name string, shards uint64) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return hash.Sum64() % shards
+}
@@ -0,0 +1,92 @@
+package ppsutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pachyderm/pachyderm/src/pfs"
+	"github.com/pachyderm/pachyderm/src/pkg/require"
+	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/google-protobuf"
+)
+
+func TestUnmarshalSpec(t *testing.T) {
+	jsonSpec := `{"pipeline": {"name": "wordcount"}, "shards": 2}`
+	yamlSpec := `
+# counts the words in data
+pipeline:
+  name: wordcount
+shards: 2
+`
+	for _, spec := range []string{jsonSpec, yamlSpec} {
+		var request pps.CreatePipelineRequest
+		require.NoError(t, UnmarshalSpec(strings.NewReader(spec), &request))
+		require.Equal(t, "wordcount", request.Pipeline.Name)
+		require.Equal(t, uint64(2), request.Shards)
+	}
+}
+
+func TestSpecToJSONErrorPosition(t *testing.T) {
+	_, err := SpecToJSON([]byte("{\n  \"shards\": 2,\n  }"))
+	require.NotNil(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "json: line 3, column 3:"))
+	_, err = SpecToJSON([]byte("pipeline:\n  name: a\n name: b\n"))
+	require.NotNil(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "yaml: line 2:"))
+}
+
+func TestMatchTag(t *testing.T) {
+	require.True(t, MatchTag("release-*", []string{"nightly", "release-42"}))
+	require.False(t, MatchTag("release-*", []string{"nightly"}))
+	require.False(t, MatchTag("release-*", nil))
+}
+
+func TestLintPipeline(t *testing.T) {
+	request := &pps.CreatePipelineRequest{
+		Pipeline:  &pps.Pipeline{Name: "wordcount"},
+		Transform: &pps.Transform{Image: "localhost:5000/wordcount"},
+		Shards:    1,
+		Inputs:    []*pps.PipelineInput{{Repo: &pfs.Repo{Name: "pipeline-wordcount"}}},
+	}
+	problems := LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "inputs[0].repo", problems[0].Field)
+	require.Equal(t, "transform.image", problems[1].Field)
+	require.NoError(t, ValidatePipeline(request))
+	request.Transform.Image = "localhost:5000/wordcount:v1"
+	request.Shards = 0
+	problems = LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "shards", problems[0].Field)
+	require.NotNil(t, ValidatePipeline(request))
+	request.Shards = 1
+	request.Transform.DatumTimeout = &google_protobuf.Duration{}
+	problems = LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "transform.datum_timeout", problems[1].Field)
+	require.NotNil(t, ValidatePipeline(request))
+	request.Transform.DatumTimeout = nil
+	request.Transform.Image = ""
+	problems = LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "transform.image", problems[1].Field)
+	require.False(t, problems[1].Error)
+	require.NoError(t, ValidatePipeline(request))
+	request.Transform.Image = "localhost:5000/wordcount:v1"
+	request.Transform.SpeculationThreshold = 0.5
+	problems = LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "transform.speculation_threshold", problems[1].Field)
+	request.Transform.SpeculationThreshold = 2
+	require.Equal(t, 1, len(LintPipeline(request)))
+	request.Transform.Autoscaling = &pps.Autoscaling{MinWorkers: 4, MaxWorkers: 2}
+	problems = LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "transform.autoscaling", problems[1].Field)
+	request.Transform.Autoscaling.MaxWorkers = 0
+	require.Equal(t, 1, len(LintPipeline(request)))
+	request.Transform.Sidecars = []*pps.Sidecar{{Name: "proxy", Image: "proxy"}}
+	problems = LintPipeline(request)
+	require.Equal(t, 2, len(problems))
+	require.Equal(t, "transform.autoscaling", problems[1].Field)
+}
@@ -0,0 +1,59 @@
+package ppsutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// UnmarshalSpec reads a spec, such as a CreatePipelineRequest, from reader
+// into message. The spec may be json or yaml.
+func UnmarshalSpec(reader io.Reader, message proto.Message) error {
+	spec, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	jsonSpec, err := SpecToJSON(spec)
+	if err != nil {
+		return err
+	}
+	return jsonpb.UnmarshalString(string(jsonSpec), message)
+}
+
+// SpecToJSON returns spec, which may be json or yaml, as json. A spec is json
+// if it starts with "{". Syntax errors include the line they're on.
+func SpecToJSON(spec []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(spec), []byte("{")) {
+		return yaml.YAMLToJSON(spec)
+	}
+	var value json.RawMessage
+	if err := json.Unmarshal(spec, &value); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line, column := position(spec, syntaxErr.Offset)
+			return nil, fmt.Errorf("json: line %d, column %d: %s", line, column, err.Error())
+		}
+		return nil, err
+	}
+	return spec, nil
+}
+
+// position returns the line and column of the byte a json.SyntaxError with
+// offset is about, which is the last byte read.
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset > 0 {
+		offset--
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndex(before, []byte("\n"))
+	return line, column
+}
@@ -3,12 +3,17 @@ package pretty
 import (
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
+	"github.com/docker/docker/pkg/units"
+	"github.com/pachyderm/pachyderm/src/pfs"
 	"github.com/pachyderm/pachyderm/src/pps"
+	"go.pedge.io/proto/time"
 )
 
 func PrintJobHeader(w io.Writer) {
-	fmt.Fprint(w, "ID\tOUTPUT\tSTATE\t\n")
+	fmt.Fprint(w, "ID\tOUTPUT\tSTATE\tDATUMS\tTIME/DATUM\tREAD\tWRITTEN\tTHROUGHPUT\t\n")
 }
 
 func PrintJobInfo(w io.Writer, jobInfo *pps.JobInfo) {
@@ -18,7 +23,45 @@ func PrintJobInfo(w io.Writer, jobInfo *pps.JobInfo) {
 	} else {
 		fmt.Fprintf(w, "-\t")
 	}
-	fmt.Fprintf(w, "%s\t\n", jobInfo.State.String())
+	fmt.Fprintf(w, "%s\t", jobInfo.State.String())
+	printJobStats(w, jobInfo.Stats)
+}
+
+func printJobStats(w io.Writer, stats *pps.JobStats) {
+	if stats == nil || stats.Datums == 0 {
+		fmt.Fprint(w, "-\t-\t-\t-\t-\t\n")
+		return
+	}
+	processTime := prototime.DurationFromProto(stats.ProcessTime)
+	fmt.Fprintf(w, "%d\t", stats.Datums)
+	fmt.Fprintf(w, "%s\t", processTime/time.Duration(stats.Datums))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(stats.BytesRead)))
+	fmt.Fprintf(w, "%s\t", units.BytesSize(float64(stats.BytesWritten)))
+	if processTime > 0 {
+		fmt.Fprintf(w, "%s/s\t\n", units.BytesSize(float64(stats.BytesRead)/processTime.Seconds()))
+	} else {
+		fmt.Fprint(w, "-\t\n")
+	}
+}
+
+func PrintQueuedJobHeader(w io.Writer) {
+	fmt.Fprint(w, "ID\tPIPELINE\tPRIORITY\tSTATE\tREASON\t\n")
+}
+
+func PrintQueuedJobInfo(w io.Writer, jobInfo *pps.JobInfo) {
+	fmt.Fprintf(w, "%s\t", jobInfo.Job.Id)
+	if jobInfo.Pipeline != nil && jobInfo.Pipeline.Name != "" {
+		fmt.Fprintf(w, "%s\t", jobInfo.Pipeline.Name)
+	} else {
+		fmt.Fprintf(w, "-\t")
+	}
+	fmt.Fprintf(w, "%d\t", jobInfo.Priority)
+	fmt.Fprintf(w, "%s\t", jobInfo.State.String())
+	if jobInfo.Reason != "" {
+		fmt.Fprintf(w, "%s\t\n", jobInfo.Reason)
+	} else {
+		fmt.Fprintf(w, "-\t\n")
+	}
 }
 
 func PrintPipelineHeader(w io.Writer) {
@@ -37,3 +80,70 @@ func PrintPipelineInfo(w io.Writer, pipelineInfo *pps.PipelineInfo) {
 	}
 	fmt.Fprintf(w, "%s\t\n", pipelineInfo.OutputRepo.Name)
 }
+
+func PrintLineageNodeHeader(w io.Writer) {
+	fmt.Fprint(w, "COMMIT\tJOB\tPIPELINE\tINPUTS\tOUTPUTS\t\n")
+}
+
+func PrintLineageNode(w io.Writer, lineageNode *pps.LineageNode) {
+	fmt.Fprintf(w, "%s/%s\t", lineageNode.Commit.Repo.Name, lineageNode.Commit.Id)
+	if lineageNode.JobInfo != nil {
+		fmt.Fprintf(w, "%s\t", lineageNode.JobInfo.Job.Id)
+		if lineageNode.JobInfo.Pipeline != nil && lineageNode.JobInfo.Pipeline.Name != "" {
+			fmt.Fprintf(w, "%s\t", lineageNode.JobInfo.Pipeline.Name)
+		} else {
+			fmt.Fprint(w, "-\t")
+		}
+	} else {
+		fmt.Fprint(w, "-\t-\t")
+	}
+	printCommits(w, lineageNode.Inputs)
+	printCommits(w, lineageNode.Outputs)
+	fmt.Fprint(w, "\n")
+}
+
+func printCommits(w io.Writer, commits []*pfs.Commit) {
+	if len(commits) == 0 {
+		fmt.Fprint(w, "-\t")
+		return
+	}
+	for i, commit := range commits {
+		fmt.Fprintf(w, "%s/%s", commit.Repo.Name, commit.Id)
+		if i == len(commits)-1 {
+			fmt.Fprint(w, "\t")
+		} else {
+			fmt.Fprint(w, ", ")
+		}
+	}
+}
+
+func PrintRunHeader(w io.Writer) {
+	fmt.Fprint(w, "RUN\tJOBS\tSTATE\tPIPELINES\t\n")
+}
+
+func PrintRunInfo(w io.Writer, runInfo *pps.RunInfo) {
+	fmt.Fprintf(w, "%s\t", runInfo.RunId)
+	fmt.Fprintf(w, "%d\t", len(runInfo.JobInfo))
+	state := pps.JobState_JOB_STATE_SUCCESS
+	for _, jobInfo := range runInfo.JobInfo {
+		if jobInfo.State == pps.JobState_JOB_STATE_FAILURE {
+			state = jobInfo.State
+			break
+		}
+		if jobInfo.State != pps.JobState_JOB_STATE_SUCCESS {
+			state = pps.JobState_JOB_STATE_RUNNING
+		}
+	}
+	fmt.Fprintf(w, "%s\t", state.String())
+	var pipelines []string
+	for _, jobInfo := range runInfo.JobInfo {
+		if jobInfo.Pipeline != nil && jobInfo.Pipeline.Name != "" {
+			pipelines = append(pipelines, jobInfo.Pipeline.Name)
+		}
+	}
+	if len(pipelines) == 0 {
+		fmt.Fprint(w, "-\t\n")
+		return
+	}
+	fmt.Fprintf(w, "%s\t\n", strings.Join(pipelines, ", "))
+}
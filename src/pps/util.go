@@ -2,6 +2,7 @@ package pps
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pachyderm/pachyderm/src/pfs"
 )
@@ -13,3 +14,75 @@ func JobRepo(job *Job) *pfs.Repo {
 func PipelineRepo(pipeline *Pipeline) *pfs.Repo {
 	return &pfs.Repo{Name: fmt.Sprintf("pipeline-%s", pipeline.Name)}
 }
+
+// RepoPipeline is the inverse of PipelineRepo, it returns false if repo isn't
+// a pipeline's output repo.
+func RepoPipeline(repo *pfs.Repo) (*Pipeline, bool) {
+	if !strings.HasPrefix(repo.Name, "pipeline-") {
+		return nil, false
+	}
+	return &Pipeline{Name: strings.TrimPrefix(repo.Name, "pipeline-")}, true
+}
+
+// JobManifestPath is the path of the manifest in a commit of JobManifestRepo.
+const JobManifestPath = "manifest"
+
+// JobManifestRepo is the repo every job's manifest is written to. Each
+// manifest is in its own commit, which is finished when the manifest is
+// written and tagged JobManifestTag(job).
+func JobManifestRepo() *pfs.Repo {
+	return &pfs.Repo{Name: "manifest-jobs"}
+}
+
+// JobManifestTag is the tag of the commit of JobManifestRepo holding job's
+// manifest.
+func JobManifestTag(job *Job) string {
+	return job.Id
+}
+
+// PipelineIngestRepo is where an ingest pipeline checkpoints the objects it
+// has imported, in the file IngestCheckpointPath.
+func PipelineIngestRepo(pipeline *Pipeline) *pfs.Repo {
+	return &pfs.Repo{Name: fmt.Sprintf("ingest-%s", pipeline.Name)}
+}
+
+// IngestCheckpointPath is the path of the checkpoint in a PipelineIngestRepo.
+const IngestCheckpointPath = "checkpoint"
+
+// JobScratchRepo is where a job's shards write their output, each to its own
+// commit, before it's merged into the job's output commit. The repo is deleted
+// once the job has finished.
+func JobScratchRepo(job *Job) *pfs.Repo {
+	return &pfs.Repo{Name: fmt.Sprintf("scratch-%s", job.Id)}
+}
+
+// JobFailedOutputRepo is where the output of a failed job's shards is kept
+// for a while, each shard's output is a commit tagged ShardTag(shard).
+func JobFailedOutputRepo(job *Job) *pfs.Repo {
+	return &pfs.Repo{Name: fmt.Sprintf("failed-%s", job.Id)}
+}
+
+// JobDebugRepo is the scratch repo the output written while debugging a job
+// goes to, so that it's kept apart from the job's own output.
+func JobDebugRepo(job *Job) *pfs.Repo {
+	return &pfs.Repo{Name: fmt.Sprintf("debug-%s", job.Id)}
+}
+
+func ShardTag(shard uint64) string {
+	return fmt.Sprintf("shard-%d", shard)
+}
+
+// ownedRepoPrefixes are the prefixes of the names of the repos pps creates
+// for pipelines and jobs.
+var ownedRepoPrefixes = []string{"pipeline-", "job-", "manifest-", "ingest-", "scratch-", "failed-", "debug-"}
+
+// OwnedRepo returns true if repo is one pps creates and deletes itself, which
+// nothing else should delete.
+func OwnedRepo(repo *pfs.Repo) bool {
+	for _, prefix := range ownedRepoPrefixes {
+		if strings.HasPrefix(repo.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}